@@ -0,0 +1,178 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	uriManagementApprovals        = apiUrlManagementV1 + "/approvals"
+	uriManagementApproval         = apiUrlManagementV1 + "/approvals/:id"
+	uriManagementApprovalApprove  = apiUrlManagementV1 + "/approvals/:id/approve"
+	uriManagementApprovalReject   = apiUrlManagementV1 + "/approvals/:id/reject"
+	uriManagementApprovalSettings = apiUrlManagementV1 + "/settings/approvals"
+)
+
+func (u *UserAdmApiHandlers) GetApprovalsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	approvals, err := u.userAdm.GetApprovals(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	for i := range approvals {
+		approvals[i].Redact()
+	}
+
+	_ = w.WriteJson(approvals)
+}
+
+func (u *UserAdmApiHandlers) GetApprovalHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	approval, err := u.userAdm.GetApproval(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		approval.Redact()
+		_ = w.WriteJson(approval)
+	case useradm.ErrApprovalNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) ApproveActionHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	u.reviewAction(w, r, u.userAdm.ApproveAction(ctx, r.PathParam("id")))
+}
+
+func (u *UserAdmApiHandlers) RejectActionHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	u.reviewAction(w, r, u.userAdm.RejectAction(ctx, r.PathParam("id")))
+}
+
+func (u *UserAdmApiHandlers) reviewAction(w rest.ResponseWriter, r *rest.Request, err error) {
+	l := log.FromContext(r.Context())
+
+	switch errors.Cause(err) {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrApprovalNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	case useradm.ErrApprovalSelfReview:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case useradm.ErrApprovalNotPending, useradm.ErrApprovalExpired,
+		useradm.ErrSAMLInvalidConfig, useradm.ErrOIDCInvalidConfig,
+		useradm.ErrUnknownRole:
+		// the configurations and the roles are checked again when the
+		// action is carried out
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	case store.ErrETagMismatch:
+		rest_utils.RestErrWithInfoMsg(w, r, l, err,
+			http.StatusPreconditionFailed, err.Error())
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) GetApprovalSettingsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	settings, err := u.userAdm.GetApprovalSettings(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	_ = w.WriteJson(settings)
+}
+
+func (u *UserAdmApiHandlers) SaveApprovalSettingsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var settings model.ApprovalSettings
+	if err := r.DecodeJsonPayload(&settings); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := settings.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action:           model.ApprovalActionSaveApprovalSettings,
+		ApprovalSettings: &settings,
+	}) {
+		return
+	}
+
+	if err := u.userAdm.SaveApprovalSettings(ctx, &settings); err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestApproval holds the action for the approval of a second admin if
+// the tenant requires it, responding with 202 Accepted; returns true if the
+// response was written and the handler must not carry out the action.
+func (u *UserAdmApiHandlers) requestApproval(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	request *model.ApprovalRequest,
+) bool {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	approval, err := u.userAdm.RequestApproval(ctx, request)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return true
+	} else if approval == nil {
+		return false
+	}
+
+	approval.Redact()
+	w.Header().Add("Location", uriManagementApprovals+"/"+approval.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = w.WriteJson(approval)
+	return true
+}
@@ -0,0 +1,426 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+var testApproval = model.Approval{
+	ID: "1",
+	ApprovalRequest: model.ApprovalRequest{
+		Action: model.ApprovalActionDeleteUser,
+		UserID: "3",
+	},
+	RequestedBy: "2",
+	Status:      model.ApprovalStatusPending,
+}
+
+func TestGetApprovals(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaApprovals []model.Approval
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaApprovals: []model.Approval{testApproval},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.Approval{testApproval},
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetApprovals", mtesting.ContextMatcher()).
+				Return(tc.uaApprovals, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementApprovals,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetApproval(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaApproval *model.Approval
+		uaError    error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaApproval: &testApproval,
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil, testApproval),
+		},
+		"error: not found": {
+			uaError: useradm.ErrApprovalNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("approval not found"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetApproval", mtesting.ContextMatcher(), testApproval.ID).
+				Return(tc.uaApproval, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+strings.Replace(
+					uriManagementApproval, ":id", testApproval.ID, 1),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestReviewAction(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		approve bool
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok, approve": {
+			approve: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, reject": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: not found": {
+			approve: true,
+			uaError: useradm.ErrApprovalNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("approval not found"),
+			),
+		},
+		"error: self review": {
+			approve: true,
+			uaError: useradm.ErrApprovalSelfReview,
+
+			checker: mt.NewJSONResponse(
+				http.StatusForbidden,
+				nil,
+				restError("action must be reviewed by another user"),
+			),
+		},
+		"error: not pending": {
+			uaError: useradm.ErrApprovalNotPending,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("approval is not pending"),
+			),
+		},
+		"error: expired": {
+			approve: true,
+			uaError: useradm.ErrApprovalExpired,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("approval expired"),
+			),
+		},
+		"error: settings changed in the meantime": {
+			approve: true,
+			uaError: errors.Wrap(store.ErrETagMismatch,
+				"useradm: failed to save settings"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusPreconditionFailed,
+				nil,
+				restError("useradm: failed to save settings: "+
+					store.ErrETagMismatch.Error()),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uri := uriManagementApprovalReject
+			method := "RejectAction"
+			if tc.approve {
+				uri = uriManagementApprovalApprove
+				method = "ApproveAction"
+			}
+			uadm.On(method, mtesting.ContextMatcher(), testApproval.ID).
+				Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+strings.Replace(uri, ":id", testApproval.ID, 1),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetApprovalSettings(t *testing.T) {
+	t.Parallel()
+
+	settings := &model.ApprovalSettings{
+		Actions: []string{model.ApprovalActionDeleteUser},
+	}
+
+	testCases := map[string]struct {
+		uaSettings *model.ApprovalSettings
+		uaError    error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaSettings: settings,
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil, settings),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetApprovalSettings", mtesting.ContextMatcher()).
+				Return(tc.uaSettings, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementApprovalSettings,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSaveApprovalSettings(t *testing.T) {
+	t.Parallel()
+
+	settings := &model.ApprovalSettings{
+		Actions: []string{
+			model.ApprovalActionDeleteUser,
+			model.ApprovalActionSaveApprovalSettings,
+		},
+	}
+	approval := &model.Approval{
+		ID: "1",
+		ApprovalRequest: model.ApprovalRequest{
+			Action:           model.ApprovalActionSaveApprovalSettings,
+			ApprovalSettings: settings,
+		},
+		Status: model.ApprovalStatusPending,
+	}
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callApproval bool
+		approval     *model.Approval
+		approvalErr  error
+
+		callSave bool
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body:         settings,
+			callApproval: true,
+			callSave:     true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, approval required": {
+			body:         settings,
+			callApproval: true,
+			approval:     approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/1",
+				},
+				approval,
+			),
+		},
+		"error: bad request": {
+			body: "foo",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("failed to decode request body: "+
+					"json: cannot unmarshal string into Go value of type "+
+					"model.ApprovalSettings"),
+			),
+		},
+		"error: unknown action": {
+			body: map[string]interface{}{
+				"actions": []string{"foo"},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("actions: (0: must be a valid value.)."),
+			),
+		},
+		"error: request approval": {
+			body:         settings,
+			callApproval: true,
+			approvalErr:  errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: useradm internal": {
+			body:         settings,
+			callApproval: true,
+			callSave:     true,
+			uaError:      errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callApproval {
+				uadm.On("RequestApproval", mtesting.ContextMatcher(),
+					&model.ApprovalRequest{
+						Action:           model.ApprovalActionSaveApprovalSettings,
+						ApprovalSettings: settings,
+					}).
+					Return(tc.approval, tc.approvalErr)
+			}
+			if tc.callSave {
+				uadm.On("SaveApprovalSettings", mtesting.ContextMatcher(),
+					settings).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+uriManagementApprovalSettings,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
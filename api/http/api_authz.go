@@ -0,0 +1,144 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/authz"
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
+)
+
+const (
+	uriManagementAuthzExplain = apiUrlManagementV1 + "/authz/explain"
+)
+
+var (
+	ErrAuthzExplainForbidden = errors.New(
+		"only the administrators can explain the authorization decisions")
+	ErrAuthzExplainTenant = errors.New("the token belongs to another tenant")
+)
+
+// AuthzExplanation is the authorization decision on the request,
+// together with the reasons.
+type AuthzExplanation struct {
+	Resource string `json:"resource"`
+	Method   string `json:"method"`
+	*authz.Decision
+}
+
+// ExplainAuthzHandler evaluates the authorizer for the given request
+// without side effects, and explains the decision.
+func (u *UserAdmApiHandlers) ExplainAuthzHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	id := identity.FromContext(ctx)
+	if id == nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, errors.New("identity not present"))
+		return
+	}
+	if u.config.Authorizer == nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, errors.New("authorizer not configured"))
+		return
+	}
+	if !id.IsUser {
+		rest_utils.RestErrWithLog(w, r, l, ErrAuthzExplainForbidden, http.StatusForbidden)
+		return
+	}
+	// same lookup as the authorizer: the groups and the active elevations
+	// of the caller count too
+	roles, err := authz.UserEffectiveRoles(ctx, u.db, id.Subject)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	isAdmin := false
+	for _, role := range roles {
+		if role == model.RoleAdmin {
+			isAdmin = true
+			break
+		}
+	}
+	if !isAdmin {
+		rest_utils.RestErrWithLog(w, r, l, ErrAuthzExplainForbidden, http.StatusForbidden)
+		return
+	}
+
+	var explainRequest model.AuthzExplainRequest
+	if err := r.DecodeJsonPayload(&explainRequest); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := explainRequest.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	resource, err := resourceFromURI(explainRequest.Path)
+	if err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	var token *jwt.Token
+	if explainRequest.Token != "" {
+		token, err = u.jwth.FromJWT(ctx, explainRequest.Token)
+		if err != nil {
+			rest_utils.RestErrWithLog(w, r, l, authz.ErrAuthzTokenInvalid,
+				http.StatusBadRequest)
+			return
+		}
+		if token.Claims.Tenant != id.Tenant {
+			rest_utils.RestErrWithLog(w, r, l, ErrAuthzExplainTenant,
+				http.StatusBadRequest)
+			return
+		}
+	} else {
+		// the user's own, unrestricted token
+		token = &jwt.Token{
+			Claims: jwt.Claims{
+				Subject: oid.FromString(explainRequest.UserID),
+				Tenant:  id.Tenant,
+				Scope:   scope.All,
+				User:    true,
+			},
+		}
+	}
+
+	decision, err := authz.Explain(ctx, u.config.Authorizer,
+		token, resource, explainRequest.Method)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	_ = w.WriteJson(AuthzExplanation{
+		Resource: resource,
+		Method:   explainRequest.Method,
+		Decision: decision,
+	})
+}
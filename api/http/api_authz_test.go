@@ -0,0 +1,395 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mendersoftware/useradm/authz"
+	mauthz "github.com/mendersoftware/useradm/authz/mocks"
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/keys"
+	"github.com/mendersoftware/useradm/model"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+func TestExplainAuthz(t *testing.T) {
+	t.Parallel()
+
+	privkey, err := keys.LoadRSAPrivate("../../crypto/private.pem")
+	require.NoError(t, err)
+	makeToken := func(tenant string) string {
+		token, err := jwt.NewJWTHandlerRS256(privkey, nil).ToJWT(context.Background(), &jwt.Token{
+			Claims: jwt.Claims{
+				ID:      oid.NewUUIDv4(),
+				Issuer:  "mender",
+				Subject: oid.FromString("2"),
+				Tenant:  tenant,
+				Scope:   "deployments:read",
+				User:    true,
+				ExpiresAt: jwt.Time{
+					Time: time.Now().Add(time.Hour),
+				},
+			},
+		})
+		require.NoError(t, err)
+		return token
+	}
+
+	policy := authz.NewPolicyAuthorizer(&authz.Policy{Rules: []authz.PolicyRule{{
+		Description: "nobody may remove the artifacts",
+		Effect:      authz.PolicyEffectDeny,
+		Resources:   []string{"deployments:artifacts:*"},
+		Methods:     []string{http.MethodDelete},
+	}, {
+		Effect:    authz.PolicyEffectAllow,
+		Resources: []string{"deployments:*"},
+		Methods:   []string{model.MethodAny},
+	}}}, nil)
+
+	testCases := map[string]struct {
+		identity   *identity.Identity
+		authorizer authz.Authorizer
+		body       interface{}
+
+		callRoles  bool
+		roles      []string
+		groups     []model.Group
+		elevations []model.Elevation
+		rolesErr   error
+
+		checker mt.ResponseChecker
+	}{
+		"ok, allow": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				UserID: "2",
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleAdmin},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				AuthzExplanation{
+					Resource: "deployments:artifacts",
+					Method:   http.MethodPost,
+					Decision: &authz.Decision{
+						Effect: authz.EffectAllow,
+						Reasons: []authz.Reason{{
+							Authorizer: "policy",
+							Effect:     authz.EffectAllow,
+							Rule:       "rules[1]",
+							Message:    "the rule matches the request",
+						}},
+					},
+				},
+			),
+		},
+		"ok, deny": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				Token:  makeToken("tenant"),
+				Path:   "/api/management/v1/deployments/artifacts/123",
+				Method: http.MethodDelete,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleReadOnly, model.RoleAdmin},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				AuthzExplanation{
+					Resource: "deployments:artifacts:123",
+					Method:   http.MethodDelete,
+					Decision: &authz.Decision{
+						Effect: authz.EffectDeny,
+						Reasons: []authz.Reason{{
+							Authorizer: "policy",
+							Effect:     authz.EffectDeny,
+							Rule:       "rules[0]",
+							Message:    "nobody may remove the artifacts",
+						}, {
+							Authorizer: "policy",
+							Effect:     authz.EffectAllow,
+							Rule:       "rules[1]",
+							Message:    "the rule matches the request",
+						}},
+					},
+				},
+			),
+		},
+		"ok, admin through a group": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				UserID: "2",
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleReadOnly},
+			groups:    []model.Group{{ID: "g1", Roles: []string{model.RoleAdmin}}},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				AuthzExplanation{
+					Resource: "deployments:artifacts",
+					Method:   http.MethodPost,
+					Decision: &authz.Decision{
+						Effect: authz.EffectAllow,
+						Reasons: []authz.Reason{{
+							Authorizer: "policy",
+							Effect:     authz.EffectAllow,
+							Rule:       "rules[1]",
+							Message:    "the rule matches the request",
+						}},
+					},
+				},
+			),
+		},
+		"ok, admin through an elevation": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				UserID: "2",
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleReadOnly},
+			elevations: []model.Elevation{{
+				ID:        "e1",
+				UserID:    "1",
+				Role:      model.RoleAdmin,
+				Status:    model.ElevationStatusActive,
+				ExpiresTs: func() *time.Time { t := time.Now().Add(time.Hour); return &t }(),
+			}},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				AuthzExplanation{
+					Resource: "deployments:artifacts",
+					Method:   http.MethodPost,
+					Decision: &authz.Decision{
+						Effect: authz.EffectAllow,
+						Reasons: []authz.Reason{{
+							Authorizer: "policy",
+							Effect:     authz.EffectAllow,
+							Rule:       "rules[1]",
+							Message:    "the rule matches the request",
+						}},
+					},
+				},
+			),
+		},
+		"error: not an admin": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				UserID: "2",
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleReadOnly},
+
+			checker: mt.NewJSONResponse(
+				http.StatusForbidden,
+				nil,
+				restError(ErrAuthzExplainForbidden.Error()),
+			),
+		},
+		"error: not a user": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant"},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				UserID: "2",
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusForbidden,
+				nil,
+				restError(ErrAuthzExplainForbidden.Error()),
+			),
+		},
+		"error: get roles": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				UserID: "2",
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			rolesErr:  errors.New("db error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: invalid request": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleAdmin},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(model.ErrAuthzExplainSubject.Error()),
+			),
+		},
+		"error: invalid path": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				UserID: "2",
+				Path:   "/api",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleAdmin},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("can't parse service name from original uri /api"),
+			),
+		},
+		"error: invalid token": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				Token:  "foo",
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleAdmin},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(authz.ErrAuthzTokenInvalid.Error()),
+			),
+		},
+		"error: token of another tenant": {
+			identity:   &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: policy,
+			body: model.AuthzExplainRequest{
+				Token:  makeToken("other"),
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleAdmin},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(ErrAuthzExplainTenant.Error()),
+			),
+		},
+		"error: authorizer internal": {
+			identity: &identity.Identity{Subject: "1", Tenant: "tenant", IsUser: true},
+			authorizer: func() authz.Authorizer {
+				a := &mauthz.Authorizer{}
+				a.On("Authorize",
+					mtesting.ContextMatcher(),
+					mock.AnythingOfType("*jwt.Token"),
+					"deployments:artifacts",
+					http.MethodPost).
+					Return(errors.New("db error"))
+				return a
+			}(),
+			body: model.AuthzExplainRequest{
+				UserID: "2",
+				Path:   "/api/management/v1/deployments/artifacts",
+				Method: http.MethodPost,
+			},
+			callRoles: true,
+			roles:     []string{model.RoleAdmin},
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := identity.WithContext(context.Background(), tc.identity)
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.callRoles {
+				var user *model.User
+				if tc.rolesErr == nil {
+					user = &model.User{ID: tc.identity.Subject, Roles: tc.roles}
+				}
+				db.On("GetUserById", mtesting.ContextMatcher(), tc.identity.Subject).
+					Return(user, tc.rolesErr)
+				if tc.rolesErr == nil {
+					db.On("GetGroupsByMember",
+						mtesting.ContextMatcher(), tc.identity.Subject).
+						Return(tc.groups, nil)
+					db.On("GetActiveElevations",
+						mtesting.ContextMatcher(), tc.identity.Subject).
+						Return(tc.elevations, nil)
+				}
+			}
+
+			api := makeMockApiHandlerWithConfig(t, nil, db, Config{
+				Authorizer: tc.authorizer,
+			})
+
+			req := makeReq(http.MethodPost,
+				"http://1.2.3.4"+uriManagementAuthzExplain,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req.WithContext(ctx))
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
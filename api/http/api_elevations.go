@@ -0,0 +1,124 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	uriManagementElevations       = apiUrlManagementV1 + "/elevations"
+	uriManagementElevation        = apiUrlManagementV1 + "/elevations/:id"
+	uriManagementElevationApprove = apiUrlManagementV1 + "/elevations/:id/approve"
+	uriManagementElevationReject  = apiUrlManagementV1 + "/elevations/:id/reject"
+)
+
+func (u *UserAdmApiHandlers) GetElevationsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	elevations, err := u.userAdm.GetElevations(ctx, r.URL.Query().Get("user_id"))
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	_ = w.WriteJson(elevations)
+}
+
+func (u *UserAdmApiHandlers) GetElevationHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	elevation, err := u.userAdm.GetElevation(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		_ = w.WriteJson(elevation)
+	case useradm.ErrElevationNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) RequestElevationHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var elevationNew model.ElevationNew
+	if err := r.DecodeJsonPayload(&elevationNew); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := elevationNew.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	elevation, err := u.userAdm.RequestElevation(ctx, &elevationNew)
+	switch err {
+	case nil:
+		w.Header().Add("Location", "elevations/"+elevation.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = w.WriteJson(elevation)
+	case useradm.ErrUnknownRole, useradm.ErrElevationTooLong:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+	case useradm.ErrElevationRoleNotAllowed:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) ApproveElevationHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	u.reviewElevation(w, r, u.userAdm.ApproveElevation(ctx, r.PathParam("id")))
+}
+
+func (u *UserAdmApiHandlers) RejectElevationHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	u.reviewElevation(w, r, u.userAdm.RejectElevation(ctx, r.PathParam("id")))
+}
+
+func (u *UserAdmApiHandlers) reviewElevation(w rest.ResponseWriter, r *rest.Request, err error) {
+	l := log.FromContext(r.Context())
+
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrElevationNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	case useradm.ErrElevationSelfReview:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case useradm.ErrElevationNotPending:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+var testElevation = model.Elevation{
+	ID:            "1",
+	UserID:        "2",
+	Role:          model.RoleAdmin,
+	Justification: "incident #42",
+	Duration:      30,
+	Status:        model.ElevationStatusPending,
+}
+
+func TestGetElevations(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		query  string
+		userID string
+
+		uaElevations []model.Elevation
+		uaError      error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaElevations: []model.Elevation{testElevation},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.Elevation{testElevation},
+			),
+		},
+		"ok, user": {
+			query:        "?user_id=2",
+			userID:       "2",
+			uaElevations: []model.Elevation{testElevation},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.Elevation{testElevation},
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetElevations", mtesting.ContextMatcher(), tc.userID).
+				Return(tc.uaElevations, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementElevations+tc.query,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetElevation(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaElevation *model.Elevation
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaElevation: &testElevation,
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil, testElevation),
+		},
+		"error: not found": {
+			uaError: useradm.ErrElevationNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("elevation not found"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetElevation", mtesting.ContextMatcher(), testElevation.ID).
+				Return(tc.uaElevation, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+strings.Replace(
+					uriManagementElevation, ":id", testElevation.ID, 1),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestRequestElevation(t *testing.T) {
+	t.Parallel()
+
+	body := model.ElevationNew{
+		Role:          testElevation.Role,
+		Justification: testElevation.Justification,
+		Duration:      testElevation.Duration,
+	}
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaElevation *model.Elevation
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body:        body,
+			callUseradm: true,
+			uaElevation: &testElevation,
+
+			checker: mt.NewJSONResponse(
+				http.StatusCreated,
+				map[string]string{"Location": "elevations/" + testElevation.ID},
+				testElevation,
+			),
+		},
+		"error: no justification": {
+			body: map[string]interface{}{
+				"role":     model.RoleAdmin,
+				"duration": 30,
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("justification: cannot be blank."),
+			),
+		},
+		"error: unknown role": {
+			body:        body,
+			callUseradm: true,
+			uaError:     useradm.ErrUnknownRole,
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("unknown role"),
+			),
+		},
+		"error: too long": {
+			body:        body,
+			callUseradm: true,
+			uaError:     useradm.ErrElevationTooLong,
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("elevation duration exceeds the maximum"),
+			),
+		},
+		"error: role not allowed": {
+			body:        body,
+			callUseradm: true,
+			uaError:     useradm.ErrElevationRoleNotAllowed,
+
+			checker: mt.NewJSONResponse(
+				http.StatusForbidden,
+				nil,
+				restError("the role may not be requested by the user"),
+			),
+		},
+		"error: useradm internal": {
+			body:        body,
+			callUseradm: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("RequestElevation", mtesting.ContextMatcher(),
+					mock.AnythingOfType("*model.ElevationNew")).
+					Return(tc.uaElevation, tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+uriManagementElevations,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestReviewElevation(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		approve bool
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok, approve": {
+			approve: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, reject": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: not found": {
+			approve: true,
+			uaError: useradm.ErrElevationNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("elevation not found"),
+			),
+		},
+		"error: self review": {
+			approve: true,
+			uaError: useradm.ErrElevationSelfReview,
+
+			checker: mt.NewJSONResponse(
+				http.StatusForbidden,
+				nil,
+				restError("elevation must be reviewed by another user"),
+			),
+		},
+		"error: not pending": {
+			uaError: useradm.ErrElevationNotPending,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("elevation is not pending approval"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uri := uriManagementElevationReject
+			method := "RejectElevation"
+			if tc.approve {
+				uri = uriManagementElevationApprove
+				method = "ApproveElevation"
+			}
+			uadm.On(method, mtesting.ContextMatcher(), testElevation.ID).
+				Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+strings.Replace(uri, ":id", testElevation.ID, 1),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
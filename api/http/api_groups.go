@@ -0,0 +1,191 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	uriManagementGroups      = apiUrlManagementV1 + "/groups"
+	uriManagementGroup       = apiUrlManagementV1 + "/groups/:id"
+	uriManagementGroupMember = apiUrlManagementV1 + "/groups/:id/members/:userid"
+	uriManagementUserGroups  = apiUrlManagementV1 + "/users/:id/groups"
+)
+
+func (u *UserAdmApiHandlers) GetGroupsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	groups, err := u.userAdm.GetGroups(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	_ = w.WriteJson(groups)
+}
+
+func (u *UserAdmApiHandlers) GetGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	group, err := u.userAdm.GetGroup(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		_ = w.WriteJson(group)
+	case useradm.ErrGroupNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) CreateGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var groupNew model.GroupNew
+	if err := r.DecodeJsonPayload(&groupNew); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := groupNew.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	group, err := u.userAdm.CreateGroup(ctx, &groupNew)
+	switch err {
+	case nil:
+		w.Header().Add("Location", "groups/"+group.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = w.WriteJson(group)
+	case useradm.ErrUnknownRole:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+	case useradm.ErrDuplicateGroupName:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) UpdateGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var groupUpdate model.GroupUpdate
+	if err := r.DecodeJsonPayload(&groupUpdate); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := groupUpdate.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	err := u.userAdm.UpdateGroup(ctx, r.PathParam("id"), &groupUpdate)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrUnknownRole:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+	case useradm.ErrGroupNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	case useradm.ErrDuplicateGroupName:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) DeleteGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := u.userAdm.DeleteGroup(ctx, r.PathParam("id"))
+	switch err {
+	case nil, useradm.ErrGroupNotFound:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) AddGroupMemberHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := u.userAdm.AddGroupMember(ctx, r.PathParam("id"), r.PathParam("userid"))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrGroupNotFound, useradm.ErrUserNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) RemoveGroupMemberHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := u.userAdm.RemoveGroupMember(ctx, r.PathParam("id"), r.PathParam("userid"))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrGroupNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) GetUserGroupsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	groups, err := u.userAdm.GetUserGroups(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		_ = w.WriteJson(groups)
+	case useradm.ErrUserNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
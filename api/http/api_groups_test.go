@@ -0,0 +1,536 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+var testGroup = model.Group{
+	ID:    "1",
+	Name:  "operators",
+	Roles: []string{model.RoleReadOnly, testRole.Name},
+}
+
+func TestGetGroups(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaGroups []model.Group
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaGroups: []model.Group{testGroup},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.Group{testGroup},
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetGroups", mtesting.ContextMatcher()).
+				Return(tc.uaGroups, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementGroups,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaGroup *model.Group
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaGroup: &testGroup,
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil, testGroup),
+		},
+		"error: not found": {
+			uaError: useradm.ErrGroupNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("group not found"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetGroup", mtesting.ContextMatcher(), testGroup.ID).
+				Return(tc.uaGroup, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+strings.Replace(uriManagementGroup, ":id", testGroup.ID, 1),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestCreateGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaGroup     *model.Group
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body:        testGroup,
+			callUseradm: true,
+			uaGroup:     &testGroup,
+
+			checker: mt.NewJSONResponse(
+				http.StatusCreated,
+				map[string]string{"Location": "groups/" + testGroup.ID},
+				testGroup,
+			),
+		},
+		"error: no name": {
+			body: map[string]interface{}{
+				"roles": testGroup.Roles,
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("name: cannot be blank."),
+			),
+		},
+		"error: unknown role": {
+			body:        testGroup,
+			callUseradm: true,
+			uaError:     useradm.ErrUnknownRole,
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("unknown role"),
+			),
+		},
+		"error: duplicate name": {
+			body:        testGroup,
+			callUseradm: true,
+			uaError:     useradm.ErrDuplicateGroupName,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("group with a given name already exists"),
+			),
+		},
+		"error: useradm internal": {
+			body:        testGroup,
+			callUseradm: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("CreateGroup", mtesting.ContextMatcher(),
+					mock.AnythingOfType("*model.GroupNew")).
+					Return(tc.uaGroup, tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+uriManagementGroups,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestUpdateGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body: map[string]interface{}{
+				"roles": []string{model.RoleAdmin},
+			},
+			callUseradm: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: empty update": {
+			body: map[string]interface{}{},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(model.ErrEmptyUpdate.Error()),
+			),
+		},
+		"error: unknown role": {
+			body: map[string]interface{}{
+				"roles": []string{"foo"},
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrUnknownRole,
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("unknown role"),
+			),
+		},
+		"error: not found": {
+			body: map[string]interface{}{
+				"name": "ops",
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrGroupNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("group not found"),
+			),
+		},
+		"error: duplicate name": {
+			body: map[string]interface{}{
+				"name": "ops",
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrDuplicateGroupName,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("group with a given name already exists"),
+			),
+		},
+		"error: useradm internal": {
+			body: map[string]interface{}{
+				"name": "ops",
+			},
+			callUseradm: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("UpdateGroup", mtesting.ContextMatcher(), testGroup.ID,
+					mock.AnythingOfType("*model.GroupUpdate")).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+strings.Replace(uriManagementGroup, ":id", testGroup.ID, 1),
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, not found": {
+			uaError: useradm.ErrGroupNotFound,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("DeleteGroup", mtesting.ContextMatcher(), testGroup.ID).
+				Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("DELETE",
+				"http://1.2.3.4"+strings.Replace(uriManagementGroup, ":id", testGroup.ID, 1),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGroupMembers(t *testing.T) {
+	t.Parallel()
+
+	uri := strings.NewReplacer(":id", testGroup.ID, ":userid", "2").
+		Replace(uriManagementGroupMember)
+
+	testCases := map[string]struct {
+		method string
+
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok, add": {
+			method: "PUT",
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: add, user not found": {
+			method:  "PUT",
+			uaError: useradm.ErrUserNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("user not found"),
+			),
+		},
+		"error: add, group not found": {
+			method:  "PUT",
+			uaError: useradm.ErrGroupNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("group not found"),
+			),
+		},
+		"error: add, useradm internal": {
+			method:  "PUT",
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"ok, remove": {
+			method: "DELETE",
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: remove, group not found": {
+			method:  "DELETE",
+			uaError: useradm.ErrGroupNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("group not found"),
+			),
+		},
+		"error: remove, useradm internal": {
+			method:  "DELETE",
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			method := "AddGroupMember"
+			if tc.method == "DELETE" {
+				method = "RemoveGroupMember"
+			}
+			uadm.On(method, mtesting.ContextMatcher(), testGroup.ID, "2").
+				Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq(tc.method, "http://1.2.3.4"+uri, "", nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetUserGroups(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaGroups []model.Group
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaGroups: []model.Group{testGroup},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.Group{testGroup},
+			),
+		},
+		"error: user not found": {
+			uaError: useradm.ErrUserNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("user not found"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetUserGroups", mtesting.ContextMatcher(), "2").
+				Return(tc.uaGroups, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+strings.Replace(uriManagementUserGroups, ":id", "2", 1),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
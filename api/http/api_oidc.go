@@ -0,0 +1,253 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	uriManagementOIDCConfig   = apiUrlManagementV1 + "/settings/oidc"
+	uriManagementOIDC         = uriManagementSSO + "oidc/"
+	uriManagementOIDCLogin    = uriManagementOIDC + ":tenant_id/login"
+	uriManagementOIDCCallback = uriManagementOIDC + ":tenant_id/callback"
+
+	// cookie keeping the authorization request until the user agent
+	// returns with the code
+	cookieOIDCAuthRequest = "oidc_auth_request"
+	oidcRequestMaxAge     = 10 * 60
+)
+
+func (u *UserAdmApiHandlers) GetOIDCConfigHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	config, err := u.userAdm.GetOIDCConfig(ctx)
+	switch err {
+	case nil:
+		// the client secret is write-only
+		config.ClientSecret = ""
+		_ = w.WriteJson(config)
+	case useradm.ErrOIDCNotConfigured:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) SaveOIDCConfigHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var config model.OIDCConfig
+	if err := r.DecodeJsonPayload(&config); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := config.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action:     model.ApprovalActionSaveOIDCConfig,
+		OIDCConfig: &config,
+	}) {
+		return
+	}
+
+	err := u.userAdm.SaveOIDCConfig(ctx, &config)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrOIDCInvalidConfig, useradm.ErrUnknownRole:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) DeleteOIDCConfigHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action: model.ApprovalActionDeleteOIDCConfig,
+	}) {
+		return
+	}
+
+	if err := u.userAdm.DeleteOIDCConfig(ctx); err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// OIDCLoginHandler starts the single sign-on, redirecting the browser to
+// the authorization endpoint of the tenant's identity provider.
+func (u *UserAdmApiHandlers) OIDCLoginHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	rp := u.oidcRelyingParty(r)
+	if rp == nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			useradm.ErrOIDCNotConfigured, http.StatusNotFound)
+		return
+	}
+	redirect := r.URL.Query().Get("redirect")
+	if redirect != "" && !isLocalRedirect(redirect) {
+		rest_utils.RestErrWithLog(w, r, l, ErrInvalidRedirect, http.StatusBadRequest)
+		return
+	}
+
+	request, err := u.userAdm.MakeOIDCAuthRequest(ctx, rp, redirect)
+	switch err {
+	case nil:
+	case useradm.ErrOIDCNotConfigured:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	value, err := json.Marshal(request)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	// the identity provider redirects back with a top-level navigation
+	writer := w.(http.ResponseWriter)
+	http.SetCookie(writer, &http.Cookie{
+		Name:     cookieOIDCAuthRequest,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     oidcCallbackPath(rp),
+		MaxAge:   oidcRequestMaxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(writer, r.Request, request.URL, http.StatusFound)
+}
+
+// OIDCCallbackHandler completes the single sign-on with the authorization
+// code and issues the session token, the same way as the login does; the
+// browser is redirected to the path it started from, if any.
+func (u *UserAdmApiHandlers) OIDCCallbackHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	rp := u.oidcRelyingParty(r)
+	if rp == nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			useradm.ErrOIDCNotConfigured, http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	if idpErr := query.Get("error"); idpErr != "" {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Errorf("identity provider error: %s", idpErr),
+			http.StatusUnauthorized)
+		return
+	}
+	var request *model.OIDCAuthRequest
+	if cookie, err := r.Cookie(cookieOIDCAuthRequest); err == nil {
+		if value, err := base64.RawURLEncoding.DecodeString(cookie.Value); err == nil {
+			if err := json.Unmarshal(value, &request); err != nil {
+				request = nil
+			}
+		}
+	}
+
+	token, err := u.userAdm.LoginOIDC(ctx, rp,
+		query.Get("code"), query.Get("state"), request)
+	switch err {
+	case nil:
+	case useradm.ErrUnauthorized, useradm.ErrTenantAccountSuspended,
+		useradm.ErrUserDisabled:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
+		return
+	case useradm.ErrOIDCNotConfigured:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	raw, err := u.userAdm.SignToken(ctx, token)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	writer := w.(http.ResponseWriter)
+	http.SetCookie(writer, &http.Cookie{
+		Name:   cookieOIDCAuthRequest,
+		Path:   oidcCallbackPath(rp),
+		MaxAge: -1,
+	})
+	setTokenCookie(writer, token, raw)
+	if isLocalRedirect(request.Redirect) {
+		http.Redirect(writer, r.Request, request.Redirect, http.StatusSeeOther)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/jwt")
+	_, _ = writer.Write([]byte(raw))
+}
+
+// oidcRelyingParty returns the relying party endpoints of the tenant; nil
+// if the server URL is not configured.
+func (u *UserAdmApiHandlers) oidcRelyingParty(r *rest.Request) *model.OIDCRelyingParty {
+	if u.config.ServerURL == "" {
+		return nil
+	}
+	tenantID := r.PathParam("tenant_id")
+	return &model.OIDCRelyingParty{
+		TenantID: tenantID,
+		RedirectURL: strings.TrimSuffix(u.config.ServerURL, "/") +
+			uriManagementOIDC + url.PathEscape(tenantID) + "/callback",
+	}
+}
+
+func oidcCallbackPath(rp *model.OIDCRelyingParty) string {
+	callbackURL, err := url.Parse(rp.RedirectURL)
+	if err != nil {
+		return uriUIRoot
+	}
+	return callbackURL.Path
+}
@@ -0,0 +1,688 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+var testOIDCRelyingParty = &model.OIDCRelyingParty{
+	TenantID: "tenant1",
+	RedirectURL: "https://mender.example.com/api/management/v1/useradm" +
+		"/auth/sso/oidc/tenant1/callback",
+}
+
+func TestGetOIDCConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaConfig *model.OIDCConfig
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaConfig: &model.OIDCConfig{
+				Issuer:       "https://accounts.google.com",
+				ClientID:     "client",
+				ClientSecret: "secret",
+			},
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil,
+				map[string]interface{}{
+					"issuer":                 "https://accounts.google.com",
+					"client_id":              "client",
+					"disable_password_login": false,
+				}),
+		},
+		"error: not configured": {
+			uaError: useradm.ErrOIDCNotConfigured,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrOIDCNotConfigured.Error()),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetOIDCConfig", mtesting.ContextMatcher()).
+				Return(tc.uaConfig, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementOIDCConfig,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSaveOIDCConfig(t *testing.T) {
+	t.Parallel()
+
+	config := &model.OIDCConfig{
+		Issuer:               "https://accounts.google.com",
+		ClientID:             "client",
+		ClientSecret:         "secret",
+		DisablePasswordLogin: true,
+	}
+
+	approval := &model.Approval{
+		ID: "a1",
+		ApprovalRequest: model.ApprovalRequest{
+			Action:     model.ApprovalActionSaveOIDCConfig,
+			OIDCConfig: config,
+		},
+		Status: model.ApprovalStatusPending,
+	}
+	redacted := *config
+	redacted.ClientSecret = ""
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callSave    bool
+		approval    *model.Approval
+		approvalErr error
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body:     config,
+			callSave: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, approval required, the client secret is not exposed": {
+			body:     config,
+			approval: approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				&model.Approval{
+					ID: "a1",
+					ApprovalRequest: model.ApprovalRequest{
+						Action:     model.ApprovalActionSaveOIDCConfig,
+						OIDCConfig: &redacted,
+					},
+					Status: model.ApprovalStatusPending,
+				},
+			),
+		},
+		"error: request approval": {
+			body:        config,
+			approvalErr: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: bad request": {
+			body: "foo",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("failed to decode request body: "+
+					"json: cannot unmarshal string into Go value of type "+
+					"model.OIDCConfig"),
+			),
+		},
+		"error: no issuer": {
+			body: map[string]interface{}{
+				"client_id": "client",
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("issuer: cannot be blank."),
+			),
+		},
+		"error: invalid configuration": {
+			body:     config,
+			callSave: true,
+			uaError:  useradm.ErrOIDCInvalidConfig,
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(useradm.ErrOIDCInvalidConfig.Error()),
+			),
+		},
+		"error: unknown role": {
+			body:     config,
+			callSave: true,
+			uaError:  useradm.ErrUnknownRole,
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(useradm.ErrUnknownRole.Error()),
+			),
+		},
+		"error: useradm internal": {
+			body:     config,
+			callSave: true,
+			uaError:  errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callSave || tc.approval != nil || tc.approvalErr != nil {
+				uadm.On("RequestApproval", mtesting.ContextMatcher(),
+					&model.ApprovalRequest{
+						Action:     model.ApprovalActionSaveOIDCConfig,
+						OIDCConfig: config,
+					}).Return(tc.approval, tc.approvalErr)
+			}
+			if tc.callSave {
+				uadm.On("SaveOIDCConfig", mtesting.ContextMatcher(), config).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+uriManagementOIDCConfig,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestDeleteOIDCConfig(t *testing.T) {
+	t.Parallel()
+
+	approval := &model.Approval{
+		ID:              "a1",
+		ApprovalRequest: model.ApprovalRequest{Action: model.ApprovalActionDeleteOIDCConfig},
+		Status:          model.ApprovalStatusPending,
+	}
+
+	testCases := map[string]struct {
+		approval    *model.Approval
+		approvalErr error
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, approval required": {
+			approval: approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				approval,
+			),
+		},
+		"error: request approval": {
+			approvalErr: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("RequestApproval", mtesting.ContextMatcher(),
+				&model.ApprovalRequest{Action: model.ApprovalActionDeleteOIDCConfig}).
+				Return(tc.approval, tc.approvalErr)
+			if tc.approval == nil && tc.approvalErr == nil {
+				uadm.On("DeleteOIDCConfig", mtesting.ContextMatcher()).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("DELETE",
+				"http://1.2.3.4"+uriManagementOIDCConfig,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	t.Parallel()
+
+	authRequest := &model.OIDCAuthRequest{
+		URL:          "https://idp.example.com/authorize?client_id=client",
+		State:        "state",
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+		Redirect:     "/ui/devices",
+	}
+
+	testCases := map[string]struct {
+		serverURL string
+		redirect  string
+
+		callRequest bool
+		uaRequest   *model.OIDCAuthRequest
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			serverURL:   testServerURL,
+			redirect:    "/ui/devices",
+			callRequest: true,
+			uaRequest:   authRequest,
+		},
+		"error: no server URL": {
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrOIDCNotConfigured.Error()),
+			),
+		},
+		"error: foreign redirect": {
+			serverURL: testServerURL,
+			redirect:  "https://evil.example.com/",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(ErrInvalidRedirect.Error()),
+			),
+		},
+		"error: not configured": {
+			serverURL:   testServerURL,
+			callRequest: true,
+			uaError:     useradm.ErrOIDCNotConfigured,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrOIDCNotConfigured.Error()),
+			),
+		},
+		"error: useradm internal": {
+			serverURL:   testServerURL,
+			callRequest: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callRequest {
+				uadm.On("MakeOIDCAuthRequest",
+					mtesting.ContextMatcher(),
+					testOIDCRelyingParty,
+					tc.redirect).
+					Return(tc.uaRequest, tc.uaError)
+			}
+
+			api := makeMockApiHandlerWithConfig(t, uadm, nil,
+				Config{ServerURL: tc.serverURL})
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV1+
+					"/auth/sso/oidc/tenant1/login?redirect="+
+					url.QueryEscape(tc.redirect),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			if tc.checker != nil {
+				mt.CheckResponse(t, tc.checker, recorded)
+				return
+			}
+			recorded.CodeIs(http.StatusFound)
+			recorded.HeaderIs("Location", tc.uaRequest.URL)
+			cookies := recorded.Recorder.Result().Cookies()
+			if assert.Len(t, cookies, 1) {
+				assert.Equal(t, cookieOIDCAuthRequest, cookies[0].Name)
+				assert.Equal(t,
+					apiUrlManagementV1+"/auth/sso/oidc/tenant1/callback",
+					cookies[0].Path)
+				assert.True(t, cookies[0].HttpOnly)
+				assert.True(t, cookies[0].Secure)
+				assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+
+				// the request is kept without its URL
+				value, err := base64.RawURLEncoding.DecodeString(cookies[0].Value)
+				assert.NoError(t, err)
+				var request model.OIDCAuthRequest
+				assert.NoError(t, json.Unmarshal(value, &request))
+				expected := *tc.uaRequest
+				expected.URL = ""
+				assert.Equal(t, expected, request)
+			}
+		})
+	}
+}
+
+func TestOIDCCallback(t *testing.T) {
+	t.Parallel()
+
+	token := &jwt.Token{
+		Claims: jwt.Claims{
+			ExpiresAt: jwt.Time{
+				Time: time.Now().Add(time.Hour).Round(time.Second),
+			},
+		},
+	}
+	request := &model.OIDCAuthRequest{
+		State:        "state",
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+	}
+	encode := func(request *model.OIDCAuthRequest) string {
+		value, _ := json.Marshal(request)
+		return base64.RawURLEncoding.EncodeToString(value)
+	}
+
+	testCases := map[string]struct {
+		serverURL string
+		query     string
+		cookie    string
+
+		callLogin bool
+		uaRequest *model.OIDCAuthRequest
+		uaToken   *jwt.Token
+		uaError   error
+
+		callSign bool
+		signed   string
+		signErr  error
+
+		checker  mt.ResponseChecker
+		location string
+	}{
+		"ok": {
+			serverURL: testServerURL,
+			query:     "?code=code&state=state",
+			cookie:    encode(request),
+			callLogin: true,
+			uaRequest: request,
+			uaToken:   token,
+			callSign:  true,
+			signed:    "dummytoken",
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/jwt",
+				Body:        "dummytoken",
+			},
+		},
+		"ok, redirect": {
+			serverURL: testServerURL,
+			query:     "?code=code&state=state",
+			cookie: encode(&model.OIDCAuthRequest{
+				State:    "state",
+				Redirect: "/ui/devices",
+			}),
+			callLogin: true,
+			uaRequest: &model.OIDCAuthRequest{
+				State:    "state",
+				Redirect: "/ui/devices",
+			},
+			uaToken:  token,
+			callSign: true,
+			signed:   "dummytoken",
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusSeeOther,
+				ContentType: "text/html",
+			},
+			location: "/ui/devices",
+		},
+		"error: no server URL": {
+			query: "?code=code&state=state",
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrOIDCNotConfigured.Error()),
+			),
+		},
+		"error: identity provider": {
+			serverURL: testServerURL,
+			query:     "?error=access_denied&state=state",
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError("identity provider error: access_denied"),
+			),
+		},
+		"error: no request cookie": {
+			serverURL: testServerURL,
+			query:     "?code=code&state=state",
+			callLogin: true,
+			uaError:   useradm.ErrUnauthorized,
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError(useradm.ErrUnauthorized.Error()),
+			),
+		},
+		"error: malformed request cookie": {
+			serverURL: testServerURL,
+			query:     "?code=code&state=state",
+			cookie:    "foo",
+			callLogin: true,
+			uaError:   useradm.ErrUnauthorized,
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError(useradm.ErrUnauthorized.Error()),
+			),
+		},
+		"error: tenant suspended": {
+			serverURL: testServerURL,
+			query:     "?code=code&state=state",
+			cookie:    encode(request),
+			callLogin: true,
+			uaRequest: request,
+			uaError:   useradm.ErrTenantAccountSuspended,
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError(useradm.ErrTenantAccountSuspended.Error()),
+			),
+		},
+		"error: not configured": {
+			serverURL: testServerURL,
+			query:     "?code=code&state=state",
+			cookie:    encode(request),
+			callLogin: true,
+			uaRequest: request,
+			uaError:   useradm.ErrOIDCNotConfigured,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrOIDCNotConfigured.Error()),
+			),
+		},
+		"error: useradm internal": {
+			serverURL: testServerURL,
+			query:     "?code=code&state=state",
+			cookie:    encode(request),
+			callLogin: true,
+			uaRequest: request,
+			uaError:   errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: sign token": {
+			serverURL: testServerURL,
+			query:     "?code=code&state=state",
+			cookie:    encode(request),
+			callLogin: true,
+			uaRequest: request,
+			uaToken:   token,
+			callSign:  true,
+			signErr:   errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callLogin {
+				uadm.On("LoginOIDC",
+					mtesting.ContextMatcher(),
+					testOIDCRelyingParty,
+					"code",
+					"state",
+					mock.MatchedBy(func(r *model.OIDCAuthRequest) bool {
+						return assert.ObjectsAreEqual(tc.uaRequest, r)
+					})).
+					Return(tc.uaToken, tc.uaError)
+			}
+			if tc.callSign {
+				uadm.On("SignToken", mtesting.ContextMatcher(), tc.uaToken).
+					Return(tc.signed, tc.signErr)
+			}
+
+			api := makeMockApiHandlerWithConfig(t, uadm, nil,
+				Config{ServerURL: tc.serverURL})
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV1+
+					"/auth/sso/oidc/tenant1/callback"+tc.query,
+				"",
+				nil)
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{
+					Name:  cookieOIDCAuthRequest,
+					Value: tc.cookie,
+				})
+			}
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+			if tc.location != "" {
+				recorded.HeaderIs("Location", tc.location)
+			}
+			if tc.signed == "" {
+				return
+			}
+			cookies := map[string]*http.Cookie{}
+			for _, cookie := range recorded.Recorder.Result().Cookies() {
+				cookies[cookie.Name] = cookie
+			}
+			if assert.Contains(t, cookies, "JWT") {
+				assert.Equal(t, tc.signed, cookies["JWT"].Value)
+				assert.Equal(t, uriUIRoot, cookies["JWT"].Path)
+			}
+			if assert.Contains(t, cookies, cookieOIDCAuthRequest) {
+				assert.Equal(t, -1, cookies[cookieOIDCAuthRequest].MaxAge)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	uriInternalTokenRevocations = apiUrlInternalV1 + "/tokens/revocations"
+)
+
+var (
+	errInvalidRevocationVersion = errors.New("since: must be a non-negative integer")
+)
+
+// GetRevocationListHandler returns the signed list of the revoked tokens,
+// the full list or the delta since the version given as "since"
+func (u *UserAdmApiHandlers) GetRevocationListHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var since int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		since, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || since < 0 {
+			rest_utils.RestErrWithLog(w, r, l, errInvalidRevocationVersion,
+				http.StatusBadRequest)
+			return
+		}
+	}
+
+	list, err := u.userAdm.GetRevocationList(ctx, since)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	writer := w.(http.ResponseWriter)
+	writer.Header().Set("Content-Type", "application/jwt")
+	writer.Header().Set("Cache-Control", "no-store")
+	_, _ = writer.Write([]byte(list))
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+func TestGetRevocationList(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		params string
+
+		callUseradm bool
+		since       int64
+		list        string
+		err         error
+
+		checker mt.ResponseChecker
+	}{
+		"ok, full list": {
+			callUseradm: true,
+			list:        "signed.list",
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/jwt",
+				Body:        "signed.list",
+				Headers:     map[string]string{"Cache-Control": "no-store"},
+			},
+		},
+		"ok, delta": {
+			params:      "?since=42",
+			callUseradm: true,
+			since:       42,
+			list:        "signed.delta",
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/jwt",
+				Body:        "signed.delta",
+			},
+		},
+		"error, invalid version": {
+			params: "?since=foo",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(errInvalidRevocationVersion.Error()),
+			),
+		},
+		"error, negative version": {
+			params: "?since=-1",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(errInvalidRevocationVersion.Error()),
+			),
+		},
+		"error, internal": {
+			callUseradm: true,
+			err:         errors.New("db error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("GetRevocationList", mtesting.ContextMatcher(), tc.since).
+					Return(tc.list, tc.err)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+			req := makeReq(http.MethodGet,
+				"http://1.2.3.4"+uriInternalTokenRevocations+tc.params,
+				"",
+				nil)
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	uriManagementRoles     = apiUrlManagementV1 + "/roles"
+	uriManagementRole      = apiUrlManagementV1 + "/roles/:name"
+	uriManagementUserRoles = apiUrlManagementV1 + "/users/:id/roles"
+)
+
+func (u *UserAdmApiHandlers) GetRolesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	roles, err := u.userAdm.GetRoles(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	_ = w.WriteJson(roles)
+}
+
+func (u *UserAdmApiHandlers) GetRoleHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	role, err := u.userAdm.GetRole(ctx, r.PathParam("name"))
+	switch err {
+	case nil:
+		_ = w.WriteJson(role)
+	case useradm.ErrRoleNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) CreateRoleHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var roleNew model.RoleNew
+	if err := r.DecodeJsonPayload(&roleNew); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := roleNew.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	role, err := u.userAdm.CreateRole(ctx, &roleNew)
+	switch err {
+	case nil:
+		w.Header().Add("Location", "roles/"+role.Name)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = w.WriteJson(role)
+	case useradm.ErrDuplicateRoleName:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) UpdateRoleHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var roleUpdate model.RoleUpdate
+	if err := r.DecodeJsonPayload(&roleUpdate); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := roleUpdate.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	err := u.userAdm.UpdateRole(ctx, r.PathParam("name"), &roleUpdate)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrRoleNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	case useradm.ErrBuiltInRole:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) DeleteRoleHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := u.userAdm.DeleteRole(ctx, r.PathParam("name"))
+	switch err {
+	case nil, useradm.ErrRoleNotFound:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrBuiltInRole:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) GetUserRolesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	roles, err := u.userAdm.GetUserRoles(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		_ = w.WriteJson(model.UserRoles{Roles: roles})
+	case useradm.ErrUserNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) SetUserRolesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var userRoles model.UserRoles
+	if err := r.DecodeJsonPayload(&userRoles); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := userRoles.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	err := u.userAdm.SetUserRoles(ctx, r.PathParam("id"), userRoles.Roles)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrUnknownRole:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+	case useradm.ErrUserNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
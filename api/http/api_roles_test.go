@@ -0,0 +1,522 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+var testRole = model.Role{
+	Name: "device-operator",
+	Permissions: []model.Permission{
+		{Resource: "deviceconnect:*", Methods: []string{model.MethodAny}},
+	},
+}
+
+func TestGetRoles(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaRoles []model.Role
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaRoles: append(model.BuiltInRoles(), testRole),
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				append(model.BuiltInRoles(), testRole),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetRoles", mtesting.ContextMatcher()).
+				Return(tc.uaRoles, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementRoles,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetRole(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaRole  *model.Role
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaRole: &testRole,
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil, testRole),
+		},
+		"not found": {
+			uaError: useradm.ErrRoleNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("role not found"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetRole", mtesting.ContextMatcher(), testRole.Name).
+				Return(tc.uaRole, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV1+"/roles/"+testRole.Name,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestCreateRole(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaRole      *model.Role
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body:        testRole,
+			callUseradm: true,
+			uaRole:      &testRole,
+
+			checker: mt.NewJSONResponse(
+				http.StatusCreated,
+				map[string]string{"Location": "roles/" + testRole.Name},
+				testRole,
+			),
+		},
+		"error: built-in role name": {
+			body: map[string]interface{}{
+				"name":        model.RoleAdmin,
+				"permissions": testRole.Permissions,
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("name: name is reserved for a built-in role."),
+			),
+		},
+		"error: no permissions": {
+			body: map[string]interface{}{
+				"name": testRole.Name,
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("permissions: cannot be blank."),
+			),
+		},
+		"error: duplicate name": {
+			body:        testRole,
+			callUseradm: true,
+			uaError:     useradm.ErrDuplicateRoleName,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("role with a given name already exists"),
+			),
+		},
+		"error: useradm internal": {
+			body:        testRole,
+			callUseradm: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("CreateRole", mtesting.ContextMatcher(),
+					mock.AnythingOfType("*model.RoleNew")).
+					Return(tc.uaRole, tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+uriManagementRoles,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestUpdateRole(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body: map[string]interface{}{
+				"description": "remote terminal",
+			},
+			callUseradm: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: empty update": {
+			body: map[string]interface{}{},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(model.ErrEmptyUpdate.Error()),
+			),
+		},
+		"error: built-in role": {
+			body: map[string]interface{}{
+				"description": "remote terminal",
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrBuiltInRole,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("built-in roles cannot be modified"),
+			),
+		},
+		"error: not found": {
+			body: map[string]interface{}{
+				"description": "remote terminal",
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrRoleNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("role not found"),
+			),
+		},
+		"error: useradm internal": {
+			body: map[string]interface{}{
+				"description": "remote terminal",
+			},
+			callUseradm: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("UpdateRole", mtesting.ContextMatcher(), testRole.Name,
+					mock.AnythingOfType("*model.RoleUpdate")).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+apiUrlManagementV1+"/roles/"+testRole.Name,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, not found": {
+			uaError: useradm.ErrRoleNotFound,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: built-in role": {
+			uaError: useradm.ErrBuiltInRole,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("built-in roles cannot be modified"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("DeleteRole", mtesting.ContextMatcher(), testRole.Name).
+				Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("DELETE",
+				"http://1.2.3.4"+apiUrlManagementV1+"/roles/"+testRole.Name,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetUserRoles(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaRoles []string
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaRoles: []string{model.RoleReadOnly, testRole.Name},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				model.UserRoles{Roles: []string{model.RoleReadOnly, testRole.Name}},
+			),
+		},
+		"not found": {
+			uaError: useradm.ErrUserNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("user not found"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetUserRoles", mtesting.ContextMatcher(), "1").
+				Return(tc.uaRoles, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV1+"/users/1/roles",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSetUserRoles(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body: model.UserRoles{
+				Roles: []string{model.RoleReadOnly, testRole.Name},
+			},
+			callUseradm: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: no roles": {
+			body: model.UserRoles{Roles: []string{}},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("roles: cannot be blank."),
+			),
+		},
+		"error: unknown role": {
+			body:        model.UserRoles{Roles: []string{"foo"}},
+			callUseradm: true,
+			uaError:     useradm.ErrUnknownRole,
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("unknown role"),
+			),
+		},
+		"error: user not found": {
+			body:        model.UserRoles{Roles: []string{model.RoleAdmin}},
+			callUseradm: true,
+			uaError:     useradm.ErrUserNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("user not found"),
+			),
+		},
+		"error: useradm internal": {
+			body:        model.UserRoles{Roles: []string{model.RoleAdmin}},
+			callUseradm: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("SetUserRoles", mtesting.ContextMatcher(), "1",
+					tc.body.(model.UserRoles).Roles).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+apiUrlManagementV1+"/users/1/roles",
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	uriManagementSAMLConfig = apiUrlManagementV1 + "/settings/saml"
+
+	// the single sign-on endpoints are reached by the browsers and the
+	// identity providers without a token
+	uriManagementSSO          = apiUrlManagementV1 + "/auth/sso/"
+	uriManagementSAML         = uriManagementSSO + "saml/"
+	uriManagementSAMLMetadata = uriManagementSAML + ":tenant_id/metadata"
+	uriManagementSAMLLogin    = uriManagementSAML + ":tenant_id/login"
+	uriManagementSAMLACS      = uriManagementSAML + ":tenant_id/acs"
+
+	// cookie tracking the ID of the authentication request, matched
+	// against the response of the identity provider
+	cookieSAMLRequestID = "saml_request_id"
+	samlRequestMaxAge   = 10 * 60
+)
+
+var (
+	ErrSAMLNoResponse  = errors.New("missing SAMLResponse")
+	ErrInvalidRedirect = errors.New("redirect must be a path on this server")
+)
+
+func (u *UserAdmApiHandlers) GetSAMLConfigHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	config, err := u.userAdm.GetSAMLConfig(ctx)
+	switch err {
+	case nil:
+		_ = w.WriteJson(config)
+	case useradm.ErrSAMLNotConfigured:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) SaveSAMLConfigHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var config model.SAMLConfig
+	if err := r.DecodeJsonPayload(&config); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := config.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action:     model.ApprovalActionSaveSAMLConfig,
+		SAMLConfig: &config,
+	}) {
+		return
+	}
+
+	err := u.userAdm.SaveSAMLConfig(ctx, &config)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrSAMLInvalidConfig:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) DeleteSAMLConfigHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action: model.ApprovalActionDeleteSAMLConfig,
+	}) {
+		return
+	}
+
+	if err := u.userAdm.DeleteSAMLConfig(ctx); err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (u *UserAdmApiHandlers) SAMLMetadataHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	sp := u.samlServiceProvider(r)
+	if sp == nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			useradm.ErrSAMLNotConfigured, http.StatusNotFound)
+		return
+	}
+
+	metadata, err := u.userAdm.GetSAMLMetadata(ctx, sp)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	writer := w.(http.ResponseWriter)
+	writer.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = writer.Write(metadata)
+}
+
+// SAMLLoginHandler starts the single sign-on, redirecting the browser to
+// the tenant's identity provider.
+func (u *UserAdmApiHandlers) SAMLLoginHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	sp := u.samlServiceProvider(r)
+	if sp == nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			useradm.ErrSAMLNotConfigured, http.StatusNotFound)
+		return
+	}
+	redirect := r.URL.Query().Get("redirect")
+	if redirect != "" && !isLocalRedirect(redirect) {
+		rest_utils.RestErrWithLog(w, r, l, ErrInvalidRedirect, http.StatusBadRequest)
+		return
+	}
+
+	request, err := u.userAdm.MakeSAMLAuthnRequest(ctx, sp, redirect)
+	switch err {
+	case nil:
+	case useradm.ErrSAMLNotConfigured:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	// the identity provider posts the response cross-site
+	writer := w.(http.ResponseWriter)
+	http.SetCookie(writer, &http.Cookie{
+		Name:     cookieSAMLRequestID,
+		Value:    request.ID,
+		Path:     samlACSPath(sp),
+		MaxAge:   samlRequestMaxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+	http.Redirect(writer, r.Request, request.URL, http.StatusFound)
+}
+
+// SAMLACSHandler is the assertion consumer service: it validates the
+// response of the identity provider and issues the session token, the same
+// way as the login does; the browser is redirected to the relay state if
+// it is a path on this server.
+func (u *UserAdmApiHandlers) SAMLACSHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	sp := u.samlServiceProvider(r)
+	if sp == nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			useradm.ErrSAMLNotConfigured, http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to parse request body"),
+			http.StatusBadRequest)
+		return
+	}
+	response, err := base64.StdEncoding.DecodeString(r.PostForm.Get("SAMLResponse"))
+	if err != nil || len(response) == 0 {
+		rest_utils.RestErrWithLog(w, r, l, ErrSAMLNoResponse, http.StatusBadRequest)
+		return
+	}
+	var requestIDs []string
+	if cookie, err := r.Cookie(cookieSAMLRequestID); err == nil {
+		requestIDs = []string{cookie.Value}
+	}
+
+	token, err := u.userAdm.LoginSAML(ctx, sp, response, requestIDs)
+	switch err {
+	case nil:
+	case useradm.ErrUnauthorized, useradm.ErrTenantAccountSuspended,
+		useradm.ErrUserDisabled:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
+		return
+	case useradm.ErrSAMLNotConfigured:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	raw, err := u.userAdm.SignToken(ctx, token)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	writer := w.(http.ResponseWriter)
+	http.SetCookie(writer, &http.Cookie{
+		Name:   cookieSAMLRequestID,
+		Path:   samlACSPath(sp),
+		MaxAge: -1,
+	})
+	setTokenCookie(writer, token, raw)
+	if relayState := r.PostForm.Get("RelayState"); isLocalRedirect(relayState) {
+		http.Redirect(writer, r.Request, relayState, http.StatusSeeOther)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/jwt")
+	_, _ = writer.Write([]byte(raw))
+}
+
+// samlServiceProvider returns the service provider endpoints of the
+// tenant; nil if the server URL is not configured.
+func (u *UserAdmApiHandlers) samlServiceProvider(r *rest.Request) *model.SAMLServiceProvider {
+	if u.config.ServerURL == "" {
+		return nil
+	}
+	tenantID := r.PathParam("tenant_id")
+	base := strings.TrimSuffix(u.config.ServerURL, "/") +
+		uriManagementSAML + url.PathEscape(tenantID)
+	return &model.SAMLServiceProvider{
+		TenantID:    tenantID,
+		MetadataURL: base + "/metadata",
+		ACSURL:      base + "/acs",
+	}
+}
+
+func samlACSPath(sp *model.SAMLServiceProvider) string {
+	acsURL, err := url.Parse(sp.ACSURL)
+	if err != nil {
+		return uriUIRoot
+	}
+	return acsURL.Path
+}
+
+// isLocalRedirect checks that the redirect does not leave the server
+func isLocalRedirect(redirect string) bool {
+	return strings.HasPrefix(redirect, "/") &&
+		!strings.HasPrefix(redirect, "//") &&
+		!strings.HasPrefix(redirect, "/\\")
+}
@@ -0,0 +1,758 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+const testServerURL = "https://mender.example.com/"
+
+var testSAMLServiceProvider = &model.SAMLServiceProvider{
+	TenantID: "tenant1",
+	MetadataURL: "https://mender.example.com/api/management/v1/useradm" +
+		"/auth/sso/saml/tenant1/metadata",
+	ACSURL: "https://mender.example.com/api/management/v1/useradm" +
+		"/auth/sso/saml/tenant1/acs",
+}
+
+func TestGetSAMLConfig(t *testing.T) {
+	t.Parallel()
+
+	config := &model.SAMLConfig{
+		IdPMetadata: "<EntityDescriptor/>",
+		AttributeMapping: model.SAMLAttributeMapping{
+			Email: "mail",
+		},
+	}
+
+	testCases := map[string]struct {
+		uaConfig *model.SAMLConfig
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaConfig: config,
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil, config),
+		},
+		"error: not configured": {
+			uaError: useradm.ErrSAMLNotConfigured,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrSAMLNotConfigured.Error()),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetSAMLConfig", mtesting.ContextMatcher()).
+				Return(tc.uaConfig, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementSAMLConfig,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSaveSAMLConfig(t *testing.T) {
+	t.Parallel()
+
+	config := &model.SAMLConfig{
+		IdPMetadata: "<EntityDescriptor/>",
+	}
+
+	approval := &model.Approval{
+		ID: "a1",
+		ApprovalRequest: model.ApprovalRequest{
+			Action:     model.ApprovalActionSaveSAMLConfig,
+			SAMLConfig: config,
+		},
+		Status: model.ApprovalStatusPending,
+	}
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callSave    bool
+		approval    *model.Approval
+		approvalErr error
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body:     config,
+			callSave: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, approval required": {
+			body:     config,
+			approval: approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				approval,
+			),
+		},
+		"error: request approval": {
+			body:        config,
+			approvalErr: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: bad request": {
+			body: "foo",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("failed to decode request body: "+
+					"json: cannot unmarshal string into Go value of type "+
+					"model.SAMLConfig"),
+			),
+		},
+		"error: no metadata": {
+			body: map[string]interface{}{},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("idp_metadata: cannot be blank."),
+			),
+		},
+		"error: invalid metadata": {
+			body:     config,
+			callSave: true,
+			uaError:  useradm.ErrSAMLInvalidConfig,
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(useradm.ErrSAMLInvalidConfig.Error()),
+			),
+		},
+		"error: useradm internal": {
+			body:     config,
+			callSave: true,
+			uaError:  errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callSave || tc.approval != nil || tc.approvalErr != nil {
+				uadm.On("RequestApproval", mtesting.ContextMatcher(),
+					&model.ApprovalRequest{
+						Action:     model.ApprovalActionSaveSAMLConfig,
+						SAMLConfig: config,
+					}).Return(tc.approval, tc.approvalErr)
+			}
+			if tc.callSave {
+				uadm.On("SaveSAMLConfig", mtesting.ContextMatcher(), config).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+uriManagementSAMLConfig,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestDeleteSAMLConfig(t *testing.T) {
+	t.Parallel()
+
+	approval := &model.Approval{
+		ID:              "a1",
+		ApprovalRequest: model.ApprovalRequest{Action: model.ApprovalActionDeleteSAMLConfig},
+		Status:          model.ApprovalStatusPending,
+	}
+
+	testCases := map[string]struct {
+		approval    *model.Approval
+		approvalErr error
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, approval required": {
+			approval: approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				approval,
+			),
+		},
+		"error: request approval": {
+			approvalErr: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("RequestApproval", mtesting.ContextMatcher(),
+				&model.ApprovalRequest{Action: model.ApprovalActionDeleteSAMLConfig}).
+				Return(tc.approval, tc.approvalErr)
+			if tc.approval == nil && tc.approvalErr == nil {
+				uadm.On("DeleteSAMLConfig", mtesting.ContextMatcher()).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("DELETE",
+				"http://1.2.3.4"+uriManagementSAMLConfig,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSAMLMetadata(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		serverURL string
+
+		callMetadata bool
+		uaMetadata   []byte
+		uaError      error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			serverURL:    testServerURL,
+			callMetadata: true,
+			uaMetadata:   []byte("<EntityDescriptor/>"),
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/samlmetadata+xml",
+				Body:        "<EntityDescriptor/>",
+			},
+		},
+		"error: no server URL": {
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrSAMLNotConfigured.Error()),
+			),
+		},
+		"error: useradm internal": {
+			serverURL:    testServerURL,
+			callMetadata: true,
+			uaError:      errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callMetadata {
+				uadm.On("GetSAMLMetadata",
+					mtesting.ContextMatcher(),
+					testSAMLServiceProvider).
+					Return(tc.uaMetadata, tc.uaError)
+			}
+
+			api := makeMockApiHandlerWithConfig(t, uadm, nil,
+				Config{ServerURL: tc.serverURL})
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV1+
+					"/auth/sso/saml/tenant1/metadata",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSAMLLogin(t *testing.T) {
+	t.Parallel()
+
+	authnRequest := &model.SAMLAuthnRequest{
+		ID:  "id-1",
+		URL: "https://idp.example.com/sso?SAMLRequest=foo",
+	}
+
+	testCases := map[string]struct {
+		serverURL string
+		redirect  string
+
+		callRequest bool
+		uaRequest   *model.SAMLAuthnRequest
+		uaError     error
+
+		status  int
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			serverURL:   testServerURL,
+			callRequest: true,
+			uaRequest:   authnRequest,
+
+			status: http.StatusFound,
+		},
+		"ok, redirect": {
+			serverURL:   testServerURL,
+			redirect:    "/ui/devices",
+			callRequest: true,
+			uaRequest:   authnRequest,
+
+			status: http.StatusFound,
+		},
+		"error: no server URL": {
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrSAMLNotConfigured.Error()),
+			),
+		},
+		"error: foreign redirect": {
+			serverURL: testServerURL,
+			redirect:  "//evil.example.com/",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(ErrInvalidRedirect.Error()),
+			),
+		},
+		"error: not configured": {
+			serverURL:   testServerURL,
+			callRequest: true,
+			uaError:     useradm.ErrSAMLNotConfigured,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrSAMLNotConfigured.Error()),
+			),
+		},
+		"error: useradm internal": {
+			serverURL:   testServerURL,
+			callRequest: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callRequest {
+				uadm.On("MakeSAMLAuthnRequest",
+					mtesting.ContextMatcher(),
+					testSAMLServiceProvider,
+					tc.redirect).
+					Return(tc.uaRequest, tc.uaError)
+			}
+
+			api := makeMockApiHandlerWithConfig(t, uadm, nil,
+				Config{ServerURL: tc.serverURL})
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV1+
+					"/auth/sso/saml/tenant1/login?redirect="+
+					url.QueryEscape(tc.redirect),
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			if tc.checker != nil {
+				mt.CheckResponse(t, tc.checker, recorded)
+				return
+			}
+			recorded.CodeIs(tc.status)
+			recorded.HeaderIs("Location", tc.uaRequest.URL)
+			cookies := recorded.Recorder.Result().Cookies()
+			if assert.Len(t, cookies, 1) {
+				assert.Equal(t, cookieSAMLRequestID, cookies[0].Name)
+				assert.Equal(t, tc.uaRequest.ID, cookies[0].Value)
+				assert.Equal(t,
+					apiUrlManagementV1+"/auth/sso/saml/tenant1/acs",
+					cookies[0].Path)
+				assert.True(t, cookies[0].HttpOnly)
+				assert.True(t, cookies[0].Secure)
+				assert.Equal(t, http.SameSiteNoneMode, cookies[0].SameSite)
+			}
+		})
+	}
+}
+
+func TestSAMLACS(t *testing.T) {
+	t.Parallel()
+
+	token := &jwt.Token{
+		Claims: jwt.Claims{
+			ExpiresAt: jwt.Time{
+				Time: time.Now().Add(time.Hour).Round(time.Second),
+			},
+		},
+	}
+	response := base64.StdEncoding.EncodeToString([]byte("<Response/>"))
+
+	testCases := map[string]struct {
+		serverURL  string
+		form       url.Values
+		requestIDs []string
+
+		callLogin bool
+		uaToken   *jwt.Token
+		uaError   error
+
+		callSign bool
+		signed   string
+		signErr  error
+
+		checker  mt.ResponseChecker
+		location string
+	}{
+		"ok": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{response},
+			},
+			requestIDs: []string{"id-1"},
+			callLogin:  true,
+			uaToken:    token,
+			callSign:   true,
+			signed:     "dummytoken",
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/jwt",
+				Body:        "dummytoken",
+			},
+		},
+		"ok, relay state": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{response},
+				"RelayState":   []string{"/ui/devices"},
+			},
+			callLogin: true,
+			uaToken:   token,
+			callSign:  true,
+			signed:    "dummytoken",
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusSeeOther,
+				ContentType: "application/json",
+			},
+			location: "/ui/devices",
+		},
+		"ok, foreign relay state": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{response},
+				"RelayState":   []string{"https://evil.example.com/"},
+			},
+			callLogin: true,
+			uaToken:   token,
+			callSign:  true,
+			signed:    "dummytoken",
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/jwt",
+				Body:        "dummytoken",
+			},
+		},
+		"error: no server URL": {
+			form: url.Values{
+				"SAMLResponse": []string{response},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrSAMLNotConfigured.Error()),
+			),
+		},
+		"error: no response": {
+			serverURL: testServerURL,
+			form:      url.Values{},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(ErrSAMLNoResponse.Error()),
+			),
+		},
+		"error: response not base64": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{"<Response/>"},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(ErrSAMLNoResponse.Error()),
+			),
+		},
+		"error: unauthorized": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{response},
+			},
+			callLogin: true,
+			uaError:   useradm.ErrUnauthorized,
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError(useradm.ErrUnauthorized.Error()),
+			),
+		},
+		"error: tenant suspended": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{response},
+			},
+			callLogin: true,
+			uaError:   useradm.ErrTenantAccountSuspended,
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError(useradm.ErrTenantAccountSuspended.Error()),
+			),
+		},
+		"error: not configured": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{response},
+			},
+			callLogin: true,
+			uaError:   useradm.ErrSAMLNotConfigured,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(useradm.ErrSAMLNotConfigured.Error()),
+			),
+		},
+		"error: useradm internal": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{response},
+			},
+			callLogin: true,
+			uaError:   errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: sign token": {
+			serverURL: testServerURL,
+			form: url.Values{
+				"SAMLResponse": []string{response},
+			},
+			callLogin: true,
+			uaToken:   token,
+			callSign:  true,
+			signErr:   errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callLogin {
+				uadm.On("LoginSAML",
+					mtesting.ContextMatcher(),
+					testSAMLServiceProvider,
+					[]byte("<Response/>"),
+					tc.requestIDs).
+					Return(tc.uaToken, tc.uaError)
+			}
+			if tc.callSign {
+				uadm.On("SignToken", mtesting.ContextMatcher(), tc.uaToken).
+					Return(tc.signed, tc.signErr)
+			}
+
+			api := makeMockApiHandlerWithConfig(t, uadm, nil,
+				Config{ServerURL: tc.serverURL})
+
+			req, _ := http.NewRequest(http.MethodPost,
+				"http://1.2.3.4"+apiUrlManagementV1+
+					"/auth/sso/saml/tenant1/acs",
+				strings.NewReader(tc.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			for _, id := range tc.requestIDs {
+				req.AddCookie(&http.Cookie{
+					Name:  cookieSAMLRequestID,
+					Value: id,
+				})
+			}
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+			if tc.location != "" {
+				recorded.HeaderIs("Location", tc.location)
+			}
+			if tc.signed == "" {
+				return
+			}
+			cookies := map[string]*http.Cookie{}
+			for _, cookie := range recorded.Recorder.Result().Cookies() {
+				cookies[cookie.Name] = cookie
+			}
+			if assert.Contains(t, cookies, "JWT") {
+				assert.Equal(t, tc.signed, cookies["JWT"].Value)
+				assert.Equal(t, uriUIRoot, cookies["JWT"].Path)
+				assert.True(t,
+					token.ExpiresAt.Time.Equal(cookies["JWT"].Expires))
+			}
+			if assert.Contains(t, cookies, cookieSAMLRequestID) {
+				assert.Equal(t, -1, cookies[cookieSAMLRequestID].MaxAge)
+			}
+		})
+	}
+}
+
+func TestIsLocalRedirect(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]bool{
+		"/ui/devices":          true,
+		"/":                    true,
+		"":                     false,
+		"ui/devices":           false,
+		"//evil.example.com/":  false,
+		"/\\evil.example.com/": false,
+		"https://example.com/": false,
+	}
+
+	for redirect, local := range testCases {
+		assert.Equal(t, local, isLocalRedirect(redirect), redirect)
+	}
+}
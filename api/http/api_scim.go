@@ -0,0 +1,1002 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	uriManagementSCIMToken = apiUrlManagementV1 + "/settings/scim/token"
+
+	// the SCIM endpoints authenticate the clients with the tenant's
+	// SCIM token rather than with the user tokens
+	uriManagementSCIM     = apiUrlManagementV1 + "/scim/v2/"
+	uriSCIMUsers          = uriManagementSCIM + "Users"
+	uriSCIMUser           = uriSCIMUsers + "/:id"
+	uriSCIMGroups         = uriManagementSCIM + "Groups"
+	uriSCIMGroup          = uriSCIMGroups + "/:id"
+	uriSCIMServiceConfig  = uriManagementSCIM + "ServiceProviderConfig"
+	contentTypeSCIM       = "application/scim+json"
+	hdrIfNoneMatch        = "If-None-Match"
+	scimDefaultCount      = 100
+	scimMaxCount          = 1000
+	scimEnvToken          = "scim_token"
+	scimResourceTypeUser  = "User"
+	scimResourceTypeGroup = "Group"
+)
+
+var (
+	errSCIMUnauthorized = model.NewSCIMError(http.StatusUnauthorized, "",
+		"invalid or missing SCIM token")
+	errSCIMUnsupportedMediaType = model.NewSCIMError(http.StatusUnsupportedMediaType, "",
+		"Content-Type must be "+contentTypeSCIM+" or application/json")
+	errSCIMUserNotFound  = model.NewSCIMError(http.StatusNotFound, "", "user not found")
+	errSCIMGroupNotFound = model.NewSCIMError(http.StatusNotFound, "", "group not found")
+	errSCIMVersion       = model.NewSCIMError(http.StatusPreconditionFailed, "",
+		"the resource has been modified")
+	errSCIMInactiveUser = model.NewSCIMError(http.StatusBadRequest,
+		model.SCIMErrInvalidValue, "users cannot be created inactive")
+	errSCIMUnknownMember = model.NewSCIMError(http.StatusBadRequest,
+		model.SCIMErrInvalidValue, "unknown group member")
+)
+
+func (u *UserAdmApiHandlers) GetSCIMTokenHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	token, err := u.userAdm.GetSCIMToken(ctx)
+	switch err {
+	case nil:
+		_ = w.WriteJson(token)
+	case useradm.ErrSCIMNotConfigured:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) CreateSCIMTokenHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var req model.SCIMTokenRequest
+	if r.ContentLength != 0 {
+		if err := r.DecodeJsonPayload(&req); err != nil {
+			rest_utils.RestErrWithLog(w, r, l,
+				errors.Wrap(err, "failed to decode request body"),
+				http.StatusBadRequest)
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	token, err := u.userAdm.NewSCIMToken(ctx, &req)
+	switch err {
+	case nil:
+	case useradm.ErrUnknownRole:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	// the token is returned with the approval request, and becomes
+	// valid once approved
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action:    model.ApprovalActionCreateSCIMToken,
+		SCIMToken: token,
+	}) {
+		return
+	}
+
+	if err := u.userAdm.SaveSCIMToken(ctx, token); err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = w.WriteJson(token)
+}
+
+func (u *UserAdmApiHandlers) DeleteSCIMTokenHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action: model.ApprovalActionDeleteSCIMToken,
+	}) {
+		return
+	}
+
+	if err := u.userAdm.DeleteSCIMToken(ctx); err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scimAuth authenticates the SCIM client with the tenant's SCIM token,
+// setting up the identity of the tenant for the wrapped handler.
+func (u *UserAdmApiHandlers) scimAuth(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		ctx := r.Context()
+		l := log.FromContext(ctx)
+
+		var secret string
+		auth := strings.Fields(r.Header.Get("Authorization"))
+		if len(auth) == 2 && strings.EqualFold(auth[0], "Bearer") {
+			secret = auth[1]
+		}
+		token, err := u.userAdm.AuthenticateSCIM(ctx, secret)
+		switch err {
+		case nil:
+		case useradm.ErrUnauthorized:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="SCIM"`)
+			writeSCIMError(w, l, errSCIMUnauthorized)
+			return
+		default:
+			writeSCIMError(w, l, err)
+			return
+		}
+
+		if r.ContentLength != 0 && r.Method != http.MethodGet {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType != contentTypeSCIM && mediaType != "application/json" {
+				writeSCIMError(w, l, errSCIMUnsupportedMediaType)
+				return
+			}
+		}
+
+		ctx = identity.WithContext(ctx, &identity.Identity{
+			Tenant: token.TenantID,
+		})
+		if token.TenantID != "" {
+			ctx = log.WithContext(ctx, l.F(log.Ctx{"tenant_id": token.TenantID}))
+		}
+		r.Request = r.Request.WithContext(ctx)
+		r.Env[scimEnvToken] = token
+		h(w, r)
+	}
+}
+
+func writeSCIM(w rest.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentTypeSCIM)
+	w.WriteHeader(status)
+	_ = w.WriteJson(v)
+}
+
+// writeSCIMError writes the SCIM errors as they are, and the other
+// errors as internal errors
+func writeSCIMError(w rest.ResponseWriter, l *log.Logger, err error) {
+	scimErr, ok := errors.Cause(err).(*model.SCIMError)
+	if !ok {
+		l.Error(err.Error())
+		scimErr = model.NewSCIMError(http.StatusInternalServerError, "",
+			"internal error")
+	} else {
+		l.Warn(scimErr.Detail)
+	}
+	status, _ := strconv.Atoi(scimErr.Status)
+	writeSCIM(w, status, scimErr)
+}
+
+// scimError translates the errors of the UserAdm methods into SCIM
+// errors
+func scimError(err error) error {
+	switch errors.Cause(err) {
+	case store.ErrDuplicateEmail:
+		return model.NewSCIMError(http.StatusConflict, model.SCIMErrUniqueness,
+			err.Error())
+	case useradm.ErrDuplicateGroupName:
+		return model.NewSCIMError(http.StatusConflict, model.SCIMErrUniqueness,
+			err.Error())
+	case useradm.ErrUnknownRole:
+		return model.NewSCIMError(http.StatusBadRequest, model.SCIMErrInvalidValue,
+			err.Error())
+	case store.ErrUserNotFound, useradm.ErrUserNotFound:
+		return errSCIMUserNotFound
+	case useradm.ErrGroupNotFound:
+		return errSCIMGroupNotFound
+	default:
+		return err
+	}
+}
+
+func decodeSCIM(r *rest.Request, v interface{}) error {
+	if err := r.DecodeJsonPayload(v); err != nil {
+		return model.NewSCIMError(http.StatusBadRequest, model.SCIMErrInvalidSyntax,
+			"failed to decode request body: "+err.Error())
+	}
+	return nil
+}
+
+func validateSCIM(v interface{ Validate() error }) error {
+	err := v.Validate()
+	if _, ok := err.(*model.SCIMError); ok || err == nil {
+		return err
+	}
+	return model.NewSCIMError(http.StatusBadRequest, model.SCIMErrInvalidValue,
+		err.Error())
+}
+
+// scimVersion computes the weak ETag of the resource
+func scimVersion(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// checkSCIMVersion checks the If-Match precondition
+func checkSCIMVersion(r *rest.Request, version string) error {
+	ifMatch := r.Header.Get(hdrIfMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return nil
+	}
+	for _, etag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(etag) == version {
+			return nil
+		}
+	}
+	return errSCIMVersion
+}
+
+// notModified checks the If-None-Match precondition of the GET requests
+func notModified(r *rest.Request, version string) bool {
+	for _, etag := range strings.Split(r.Header.Get(hdrIfNoneMatch), ",") {
+		if strings.TrimSpace(etag) == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *UserAdmApiHandlers) scimLocation(uri, id string) string {
+	return strings.TrimSuffix(u.config.ServerURL, "/") + uri + "/" + id
+}
+
+// scimPage parses the pagination parameters (1-based startIndex and
+// count) and returns the page of n resources
+func scimPage(r *rest.Request, n int) (start, end int, err error) {
+	query := r.URL.Query()
+	start, count := 1, scimDefaultCount
+	if s := query.Get("startIndex"); s != "" {
+		start, err = strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, model.NewSCIMError(http.StatusBadRequest,
+				model.SCIMErrInvalidValue, "invalid startIndex")
+		}
+		if start < 1 {
+			start = 1
+		}
+	}
+	if c := query.Get("count"); c != "" {
+		count, err = strconv.Atoi(c)
+		if err != nil {
+			return 0, 0, model.NewSCIMError(http.StatusBadRequest,
+				model.SCIMErrInvalidValue, "invalid count")
+		}
+		if count < 0 {
+			count = 0
+		} else if count > scimMaxCount {
+			count = scimMaxCount
+		}
+	}
+	start--
+	if start > n {
+		start = n
+	}
+	end = start + count
+	if end > n {
+		end = n
+	}
+	return start, end, nil
+}
+
+func scimListResponse(start, total int, resources interface{}, n int) *model.SCIMListResponse {
+	return &model.SCIMListResponse{
+		Schemas:      []string{model.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   start + 1,
+		ItemsPerPage: n,
+		Resources:    resources,
+	}
+}
+
+// scimUser returns the SCIM representation of the user
+func (u *UserAdmApiHandlers) scimUser(user *model.User, groups []model.Group) *model.SCIMUser {
+	active := !user.IsDisabled()
+	resource := &model.SCIMUser{
+		Schemas:  []string{model.SCIMSchemaUser},
+		ID:       user.ID,
+		UserName: string(user.Email),
+		Active:   &active,
+		Emails: []model.SCIMEmail{{
+			Value:   string(user.Email),
+			Primary: true,
+		}},
+	}
+	version := scimVersion(resource)
+	for _, group := range groups {
+		resource.Groups = append(resource.Groups, model.SCIMMember{
+			Value:   group.ID,
+			Display: group.Name,
+		})
+	}
+	resource.Meta = &model.SCIMMeta{
+		ResourceType: scimResourceTypeUser,
+		Created:      user.CreatedTs,
+		LastModified: user.UpdatedTs,
+		Location:     u.scimLocation(uriSCIMUsers, user.ID),
+		Version:      version,
+	}
+	return resource
+}
+
+func writeSCIMUser(w rest.ResponseWriter, status int, user *model.SCIMUser) {
+	w.Header().Set(hdrETag, user.Meta.Version)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", user.Meta.Location)
+	}
+	writeSCIM(w, status, user)
+}
+
+func (u *UserAdmApiHandlers) SCIMGetUsersHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	filter, err := model.ParseSCIMFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	var fltr model.UserFilter
+	if filter != nil {
+		switch filter.Attribute {
+		case "username", "emails", "emails.value":
+			fltr.Email = []model.Email{model.Email(strings.ToLower(filter.Value))}
+		case "id":
+			fltr.ID = []string{filter.Value}
+		default:
+			writeSCIMError(w, l, model.NewSCIMError(http.StatusBadRequest,
+				model.SCIMErrInvalidFilter,
+				"unsupported filter attribute: "+filter.Attribute))
+			return
+		}
+	}
+
+	users, err := u.userAdm.GetUsers(ctx, fltr)
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	start, end, err := scimPage(r, len(users))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	resources := make([]*model.SCIMUser, 0, end-start)
+	for i := start; i < end; i++ {
+		resources = append(resources, u.scimUser(&users[i], nil))
+	}
+	writeSCIM(w, http.StatusOK,
+		scimListResponse(start, len(users), resources, len(resources)))
+}
+
+func (u *UserAdmApiHandlers) SCIMCreateUserHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var resource model.SCIMUser
+	if err := decodeSCIM(r, &resource); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := validateSCIM(resource); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if !resource.IsActive() {
+		writeSCIMError(w, l, errSCIMInactiveUser)
+		return
+	}
+
+	token := r.Env[scimEnvToken].(*model.SCIMToken)
+	user := &model.User{
+		Email:    resource.Email(),
+		Password: resource.Password,
+		Roles:    token.ProvisioningRoles,
+	}
+	if err := u.userAdm.CreateUser(ctx, user); err != nil {
+		writeSCIMError(w, l, scimError(err))
+		return
+	}
+	writeSCIMUser(w, http.StatusCreated, u.scimUser(user, nil))
+}
+
+// getSCIMUser returns the user together with the user's groups
+func (u *UserAdmApiHandlers) getSCIMUser(
+	ctx context.Context,
+	id string,
+) (*model.User, []model.Group, error) {
+	user, err := u.userAdm.GetUser(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	} else if user == nil {
+		return nil, nil, errSCIMUserNotFound
+	}
+	groups, err := u.userAdm.GetUserGroups(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, groups, nil
+}
+
+func (u *UserAdmApiHandlers) SCIMGetUserHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	user, groups, err := u.getSCIMUser(ctx, r.PathParam("id"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	resource := u.scimUser(user, groups)
+	if notModified(r, resource.Meta.Version) {
+		w.Header().Set(hdrETag, resource.Meta.Version)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeSCIMUser(w, http.StatusOK, resource)
+}
+
+func (u *UserAdmApiHandlers) SCIMReplaceUserHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var desired model.SCIMUser
+	if err := decodeSCIM(r, &desired); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := validateSCIM(desired); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	user, groups, err := u.getSCIMUser(ctx, r.PathParam("id"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := checkSCIMVersion(r, u.scimUser(user, groups).Meta.Version); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+
+	resource, err := u.updateSCIMUser(ctx, user, &desired)
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	writeSCIMUser(w, http.StatusOK, resource)
+}
+
+func (u *UserAdmApiHandlers) SCIMPatchUserHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var patch model.SCIMPatchRequest
+	if err := decodeSCIM(r, &patch); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := validateSCIM(patch); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	user, groups, err := u.getSCIMUser(ctx, r.PathParam("id"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	desired := u.scimUser(user, groups)
+	if err := checkSCIMVersion(r, desired.Meta.Version); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := patch.ApplyToUser(desired); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := validateSCIM(desired); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+
+	resource, err := u.updateSCIMUser(ctx, user, desired)
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	writeSCIMUser(w, http.StatusOK, resource)
+}
+
+// updateSCIMUser applies the desired state of the user; deactivating the
+// user disables the user account, which can be reactivated later
+func (u *UserAdmApiHandlers) updateSCIMUser(
+	ctx context.Context,
+	user *model.User,
+	desired *model.SCIMUser,
+) (*model.SCIMUser, error) {
+	if active := desired.IsActive(); active == user.IsDisabled() {
+		status := &model.UserStatusUpdate{
+			Status: model.UserStatusActive,
+			Reason: "activated through SCIM",
+		}
+		if !active {
+			status.Status = model.UserStatusDisabled
+			status.Reason = "deactivated through SCIM"
+		}
+		if err := u.userAdm.SetUserStatus(ctx, user.ID, status); err != nil {
+			return nil, scimError(err)
+		}
+	}
+
+	email := desired.Email()
+	if email != user.Email {
+		err := u.userAdm.UpdateUser(ctx, user.ID, &model.UserUpdate{
+			Email: email,
+		})
+		if err != nil {
+			return nil, scimError(err)
+		}
+	}
+	if desired.Password != "" {
+		err := u.userAdm.SetPassword(ctx, model.UserUpdate{
+			Email:    email,
+			Password: desired.Password,
+		})
+		if err != nil {
+			return nil, scimError(err)
+		}
+	}
+
+	user, groups, err := u.getSCIMUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return u.scimUser(user, groups), nil
+}
+
+func (u *UserAdmApiHandlers) SCIMDeleteUserHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	user, groups, err := u.getSCIMUser(ctx, r.PathParam("id"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := checkSCIMVersion(r, u.scimUser(user, groups).Meta.Version); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := u.userAdm.DeleteUser(ctx, user.ID); err != nil {
+		writeSCIMError(w, l, scimError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scimGroup returns the SCIM representation of the group; emails maps
+// the IDs of the members to their email addresses
+func (u *UserAdmApiHandlers) scimGroup(
+	group *model.Group,
+	emails map[string]model.Email,
+) *model.SCIMGroup {
+	members := append([]string{}, group.Members...)
+	sort.Strings(members)
+	resource := &model.SCIMGroup{
+		Schemas:     []string{model.SCIMSchemaGroup},
+		ID:          group.ID,
+		DisplayName: group.Name,
+	}
+	for _, id := range members {
+		resource.Members = append(resource.Members, model.SCIMMember{
+			Value:   id,
+			Display: string(emails[id]),
+		})
+	}
+	resource.Meta = &model.SCIMMeta{
+		ResourceType: scimResourceTypeGroup,
+		Created:      group.CreatedTs,
+		LastModified: group.UpdatedTs,
+		Location:     u.scimLocation(uriSCIMGroups, group.ID),
+		Version:      scimVersion(resource),
+	}
+	return resource
+}
+
+// memberEmails looks up the email addresses of the users
+func (u *UserAdmApiHandlers) memberEmails(
+	ctx context.Context,
+	ids []string,
+) (map[string]model.Email, error) {
+	emails := make(map[string]model.Email, len(ids))
+	if len(ids) == 0 {
+		return emails, nil
+	}
+	users, err := u.userAdm.GetUsers(ctx, model.UserFilter{ID: ids})
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		emails[user.ID] = user.Email
+	}
+	return emails, nil
+}
+
+func writeSCIMGroup(w rest.ResponseWriter, status int, group *model.SCIMGroup) {
+	w.Header().Set(hdrETag, group.Meta.Version)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", group.Meta.Location)
+	}
+	writeSCIM(w, status, group)
+}
+
+func (u *UserAdmApiHandlers) SCIMGetGroupsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	filter, err := model.ParseSCIMFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if filter != nil && filter.Attribute != "displayname" && filter.Attribute != "id" {
+		writeSCIMError(w, l, model.NewSCIMError(http.StatusBadRequest,
+			model.SCIMErrInvalidFilter,
+			"unsupported filter attribute: "+filter.Attribute))
+		return
+	}
+
+	all, err := u.userAdm.GetGroups(ctx)
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	groups := all[:0]
+	for _, group := range all {
+		switch {
+		case filter == nil,
+			filter.Attribute == "id" && group.ID == filter.Value,
+			filter.Attribute == "displayname" &&
+				strings.EqualFold(group.Name, filter.Value):
+			groups = append(groups, group)
+		}
+	}
+	start, end, err := scimPage(r, len(groups))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	var ids []string
+	for _, group := range groups[start:end] {
+		ids = append(ids, group.Members...)
+	}
+	emails, err := u.memberEmails(ctx, ids)
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	resources := make([]*model.SCIMGroup, 0, end-start)
+	for i := start; i < end; i++ {
+		resources = append(resources, u.scimGroup(&groups[i], emails))
+	}
+	writeSCIM(w, http.StatusOK,
+		scimListResponse(start, len(groups), resources, len(resources)))
+}
+
+// checkSCIMMembers checks that the members are known users
+func (u *UserAdmApiHandlers) checkSCIMMembers(
+	ctx context.Context,
+	members []model.SCIMMember,
+) (map[string]model.Email, error) {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.Value)
+	}
+	emails, err := u.memberEmails(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := emails[id]; !ok {
+			return nil, errSCIMUnknownMember
+		}
+	}
+	return emails, nil
+}
+
+func (u *UserAdmApiHandlers) SCIMCreateGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var resource model.SCIMGroup
+	if err := decodeSCIM(r, &resource); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := validateSCIM(resource); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	emails, err := u.checkSCIMMembers(ctx, resource.Members)
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+
+	group, err := u.userAdm.CreateGroup(ctx, &model.GroupNew{
+		Name: resource.DisplayName,
+	})
+	if err != nil {
+		writeSCIMError(w, l, scimError(err))
+		return
+	}
+	for _, m := range resource.Members {
+		if err := u.userAdm.AddGroupMember(ctx, group.ID, m.Value); err != nil {
+			writeSCIMError(w, l, scimError(err))
+			return
+		}
+		group.Members = append(group.Members, m.Value)
+	}
+	writeSCIMGroup(w, http.StatusCreated, u.scimGroup(group, emails))
+}
+
+// getSCIMGroup returns the group with the email addresses of the members
+func (u *UserAdmApiHandlers) getSCIMGroup(
+	ctx context.Context,
+	id string,
+) (*model.SCIMGroup, *model.Group, error) {
+	group, err := u.userAdm.GetGroup(ctx, id)
+	if err != nil {
+		return nil, nil, scimError(err)
+	}
+	emails, err := u.memberEmails(ctx, group.Members)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u.scimGroup(group, emails), group, nil
+}
+
+func (u *UserAdmApiHandlers) SCIMGetGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	resource, _, err := u.getSCIMGroup(ctx, r.PathParam("id"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if notModified(r, resource.Meta.Version) {
+		w.Header().Set(hdrETag, resource.Meta.Version)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeSCIMGroup(w, http.StatusOK, resource)
+}
+
+func (u *UserAdmApiHandlers) SCIMReplaceGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var desired model.SCIMGroup
+	if err := decodeSCIM(r, &desired); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := validateSCIM(desired); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	current, group, err := u.getSCIMGroup(ctx, r.PathParam("id"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := checkSCIMVersion(r, current.Meta.Version); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+
+	resource, err := u.updateSCIMGroup(ctx, group, &desired)
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	writeSCIMGroup(w, http.StatusOK, resource)
+}
+
+func (u *UserAdmApiHandlers) SCIMPatchGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var patch model.SCIMPatchRequest
+	if err := decodeSCIM(r, &patch); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := validateSCIM(patch); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	desired, group, err := u.getSCIMGroup(ctx, r.PathParam("id"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := checkSCIMVersion(r, desired.Meta.Version); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := patch.ApplyToGroup(desired); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := validateSCIM(desired); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+
+	resource, err := u.updateSCIMGroup(ctx, group, desired)
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	writeSCIMGroup(w, http.StatusOK, resource)
+}
+
+// updateSCIMGroup applies the desired state of the group, renaming it and
+// adding or removing the members
+func (u *UserAdmApiHandlers) updateSCIMGroup(
+	ctx context.Context,
+	group *model.Group,
+	desired *model.SCIMGroup,
+) (*model.SCIMGroup, error) {
+	if _, err := u.checkSCIMMembers(ctx, desired.Members); err != nil {
+		return nil, err
+	}
+	if desired.DisplayName != group.Name {
+		err := u.userAdm.UpdateGroup(ctx, group.ID, &model.GroupUpdate{
+			Name: &desired.DisplayName,
+		})
+		if err != nil {
+			return nil, scimError(err)
+		}
+	}
+
+	current := make(map[string]bool, len(group.Members))
+	for _, id := range group.Members {
+		current[id] = true
+	}
+	for _, m := range desired.Members {
+		if current[m.Value] {
+			delete(current, m.Value)
+			continue
+		}
+		if err := u.userAdm.AddGroupMember(ctx, group.ID, m.Value); err != nil {
+			return nil, scimError(err)
+		}
+		current[m.Value] = false
+	}
+	for id, remove := range current {
+		if !remove {
+			continue
+		}
+		if err := u.userAdm.RemoveGroupMember(ctx, group.ID, id); err != nil {
+			return nil, scimError(err)
+		}
+	}
+
+	resource, _, err := u.getSCIMGroup(ctx, group.ID)
+	return resource, err
+}
+
+func (u *UserAdmApiHandlers) SCIMDeleteGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	resource, _, err := u.getSCIMGroup(ctx, r.PathParam("id"))
+	if err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := checkSCIMVersion(r, resource.Meta.Version); err != nil {
+		writeSCIMError(w, l, err)
+		return
+	}
+	if err := u.userAdm.DeleteGroup(ctx, resource.ID); err != nil {
+		writeSCIMError(w, l, scimError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SCIMServiceProviderConfigHandler describes the supported SCIM features
+func (u *UserAdmApiHandlers) SCIMServiceProviderConfigHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	supported := func(s bool) map[string]interface{} {
+		return map[string]interface{}{"supported": s}
+	}
+	writeSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{model.SCIMSchemaSPConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(true),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "SCIM token",
+			"description": "The tenant's SCIM token, generated with " + uriManagementSCIMToken,
+			"primary":     true,
+		}},
+		"meta": map[string]interface{}{
+			"resourceType": "ServiceProviderConfig",
+			"location":     strings.TrimSuffix(u.config.ServerURL, "/") + uriSCIMServiceConfig,
+		},
+	})
+}
@@ -0,0 +1,1146 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+const testSCIMToken = "Bearer scimsecret"
+
+var testSCIMTs = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func scimResponse(status int, headers map[string]string, body interface{}) mt.ResponseChecker {
+	checker := mt.NewJSONResponse(status, headers, body)
+	checker.ContentType = contentTypeSCIM
+	return checker
+}
+
+func scimErrorResponse(status int, scimType, detail string) mt.ResponseChecker {
+	return scimResponse(status, nil, model.NewSCIMError(status, scimType, detail))
+}
+
+// makeSCIMApp returns the mocked App authenticating the SCIM token
+func makeSCIMApp() *museradm.App {
+	uadm := &museradm.App{}
+	uadm.On("AuthenticateSCIM", mtesting.ContextMatcher(), "scimsecret").
+		Return(&model.SCIMToken{
+			TenantID:          "tenant1",
+			ProvisioningRoles: []string{"RBAC_ROLE_OBSERVER"},
+		}, nil).Maybe()
+	uadm.On("AuthenticateSCIM", mtesting.ContextMatcher(),
+		mock.AnythingOfType("string")).
+		Return(nil, useradm.ErrUnauthorized).Maybe()
+	return uadm
+}
+
+func makeSCIMReq(method, path, auth string, body interface{}) *http.Request {
+	req := makeReq(method, "http://1.2.3.4"+path, auth, body)
+	if body != nil {
+		req.Header.Set("Content-Type", contentTypeSCIM)
+	}
+	return req
+}
+
+func testSCIMUser() *model.User {
+	return &model.User{
+		ID:        "1",
+		Email:     "foo@acme.com",
+		CreatedTs: &testSCIMTs,
+		UpdatedTs: &testSCIMTs,
+	}
+}
+
+func decodeSCIMBody(t *testing.T, recorded *test.Recorded, v interface{}) {
+	assert.Equal(t, contentTypeSCIM,
+		recorded.Recorder.Header().Get("Content-Type"))
+	assert.NoError(t, json.Unmarshal(recorded.Recorder.Body.Bytes(), v))
+}
+
+func TestSCIMAuth(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		auth        string
+		contentType string
+
+		checker mt.ResponseChecker
+	}{
+		"error: missing token": {
+			checker: scimResponse(http.StatusUnauthorized,
+				map[string]string{"WWW-Authenticate": `Bearer realm="SCIM"`},
+				errSCIMUnauthorized),
+		},
+		"error: invalid token": {
+			auth: "Bearer foo",
+
+			checker: scimResponse(http.StatusUnauthorized, nil,
+				errSCIMUnauthorized),
+		},
+		"error: user token": {
+			auth: "Basic c2NpbXNlY3JldA==",
+
+			checker: scimResponse(http.StatusUnauthorized, nil,
+				errSCIMUnauthorized),
+		},
+		"error: content type": {
+			auth:        testSCIMToken,
+			contentType: "text/plain",
+
+			checker: scimResponse(http.StatusUnsupportedMediaType, nil,
+				errSCIMUnsupportedMediaType),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := makeSCIMApp()
+			defer uadm.AssertExpectations(t)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeSCIMReq(http.MethodPost, uriSCIMUsers, tc.auth,
+				map[string]interface{}{"userName": "foo@acme.com"})
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSCIMGetUsers(t *testing.T) {
+	t.Parallel()
+
+	users := []model.User{
+		{ID: "1", Email: "foo@acme.com"},
+		{ID: "2", Email: "bar@acme.com"},
+		{ID: "3", Email: "baz@acme.com"},
+	}
+
+	testCases := map[string]struct {
+		query string
+
+		filter  *model.UserFilter
+		users   []model.User
+		uaError error
+
+		status     int
+		total      int
+		startIndex int
+		ids        []string
+		checker    mt.ResponseChecker
+	}{
+		"ok": {
+			filter: &model.UserFilter{},
+			users:  users,
+
+			status:     http.StatusOK,
+			total:      3,
+			startIndex: 1,
+			ids:        []string{"1", "2", "3"},
+		},
+		"ok, userName filter": {
+			query:  `?filter=userName+eq+"Foo@acme.com"`,
+			filter: &model.UserFilter{Email: []model.Email{"foo@acme.com"}},
+			users:  users[:1],
+
+			status:     http.StatusOK,
+			total:      1,
+			startIndex: 1,
+			ids:        []string{"1"},
+		},
+		"ok, paginated": {
+			query:  "?startIndex=2&count=1",
+			filter: &model.UserFilter{},
+			users:  users,
+
+			status:     http.StatusOK,
+			total:      3,
+			startIndex: 2,
+			ids:        []string{"2"},
+		},
+		"ok, past the last page": {
+			query:  "?startIndex=10",
+			filter: &model.UserFilter{},
+			users:  users,
+
+			status:     http.StatusOK,
+			total:      3,
+			startIndex: 4,
+			ids:        []string{},
+		},
+		"error: invalid filter": {
+			query: `?filter=userName+co+"foo"`,
+
+			checker: scimErrorResponse(http.StatusBadRequest,
+				model.SCIMErrInvalidFilter,
+				`only the filters of the form: attribute eq "value" are supported`),
+		},
+		"error: unsupported filter attribute": {
+			query: `?filter=title+eq+"foo"`,
+
+			checker: scimErrorResponse(http.StatusBadRequest,
+				model.SCIMErrInvalidFilter,
+				"unsupported filter attribute: title"),
+		},
+		"error: useradm internal": {
+			filter:  &model.UserFilter{},
+			uaError: errors.New("some internal error"),
+
+			checker: scimErrorResponse(http.StatusInternalServerError, "",
+				"internal error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := makeSCIMApp()
+			defer uadm.AssertExpectations(t)
+			if tc.filter != nil {
+				uadm.On("GetUsers", mtesting.ContextMatcher(), *tc.filter).
+					Return(tc.users, tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeSCIMReq(http.MethodGet, uriSCIMUsers+tc.query,
+				testSCIMToken, nil)
+
+			recorded := test.RunRequest(t, api, req)
+			if tc.checker != nil {
+				mt.CheckResponse(t, tc.checker, recorded)
+				return
+			}
+			recorded.CodeIs(tc.status)
+			var list struct {
+				model.SCIMListResponse
+				Resources []model.SCIMUser `json:"Resources"`
+			}
+			decodeSCIMBody(t, recorded, &list)
+			assert.Equal(t, []string{model.SCIMSchemaListResponse}, list.Schemas)
+			assert.Equal(t, tc.total, list.TotalResults)
+			assert.Equal(t, tc.startIndex, list.StartIndex)
+			assert.Equal(t, len(tc.ids), list.ItemsPerPage)
+			ids := []string{}
+			for _, user := range list.Resources {
+				ids = append(ids, user.ID)
+			}
+			assert.Equal(t, tc.ids, ids)
+		})
+	}
+}
+
+func TestSCIMCreateUser(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callCreate bool
+		uaError    error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body: map[string]interface{}{
+				"schemas":  []string{model.SCIMSchemaUser},
+				"userName": "Foo@acme.com",
+				"password": "correcthorsebatterystaple",
+				"active":   true,
+			},
+			callCreate: true,
+		},
+		"ok, primary email": {
+			body: map[string]interface{}{
+				"schemas": []string{model.SCIMSchemaUser},
+				"emails": []map[string]interface{}{
+					{"value": "bar@acme.com"},
+					{"value": "foo@acme.com", "primary": true},
+				},
+			},
+			callCreate: true,
+		},
+		"error: inactive": {
+			body: map[string]interface{}{
+				"userName": "foo@acme.com",
+				"active":   false,
+			},
+
+			checker: scimResponse(http.StatusBadRequest, nil, errSCIMInactiveUser),
+		},
+		"error: missing user name": {
+			body: map[string]interface{}{},
+
+			checker: scimErrorResponse(http.StatusBadRequest,
+				model.SCIMErrInvalidValue, "userName: cannot be blank."),
+		},
+		"error: duplicate email": {
+			body: map[string]interface{}{
+				"userName": "foo@acme.com",
+			},
+			callCreate: true,
+			uaError:    store.ErrDuplicateEmail,
+
+			checker: scimErrorResponse(http.StatusConflict,
+				model.SCIMErrUniqueness, store.ErrDuplicateEmail.Error()),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := makeSCIMApp()
+			defer uadm.AssertExpectations(t)
+			if tc.callCreate {
+				uadm.On("CreateUser", mtesting.ContextMatcher(),
+					mock.MatchedBy(func(user *model.User) bool {
+						return user.Email == "foo@acme.com" &&
+							assert.Equal(t, []string{"RBAC_ROLE_OBSERVER"},
+								user.Roles)
+					})).
+					Run(func(args mock.Arguments) {
+						user := args.Get(1).(*model.User)
+						user.ID = "1"
+						user.CreatedTs = &testSCIMTs
+						user.UpdatedTs = &testSCIMTs
+					}).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandlerWithConfig(t, uadm, nil,
+				Config{ServerURL: testServerURL})
+
+			req := makeSCIMReq(http.MethodPost, uriSCIMUsers, testSCIMToken,
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			if tc.checker != nil {
+				mt.CheckResponse(t, tc.checker, recorded)
+				return
+			}
+			recorded.CodeIs(http.StatusCreated)
+			var user model.SCIMUser
+			decodeSCIMBody(t, recorded, &user)
+			assert.Equal(t, "1", user.ID)
+			assert.Equal(t, "foo@acme.com", user.UserName)
+			assert.Empty(t, user.Password)
+			assert.True(t, user.IsActive())
+			if assert.NotNil(t, user.Meta) {
+				assert.Equal(t, "https://mender.example.com"+uriSCIMUsers+"/1",
+					user.Meta.Location)
+				assert.Equal(t, user.Meta.Version,
+					recorded.Recorder.Header().Get(hdrETag))
+				assert.Equal(t, user.Meta.Location,
+					recorded.Recorder.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestSCIMGetUser(t *testing.T) {
+	t.Parallel()
+
+	handlers := &UserAdmApiHandlers{}
+	version := handlers.scimUser(testSCIMUser(), nil).Meta.Version
+
+	testCases := map[string]struct {
+		ifNoneMatch string
+
+		user    *model.User
+		uaError error
+
+		status  int
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			user: testSCIMUser(),
+
+			status: http.StatusOK,
+		},
+		"ok, not modified": {
+			ifNoneMatch: version,
+			user:        testSCIMUser(),
+
+			status: http.StatusNotModified,
+		},
+		"ok, modified": {
+			ifNoneMatch: `W/"foo"`,
+			user:        testSCIMUser(),
+
+			status: http.StatusOK,
+		},
+		"error: not found": {
+			checker: scimResponse(http.StatusNotFound, nil, errSCIMUserNotFound),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: scimErrorResponse(http.StatusInternalServerError, "",
+				"internal error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := makeSCIMApp()
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetUser", mtesting.ContextMatcher(), "1").
+				Return(tc.user, tc.uaError)
+			if tc.user != nil {
+				uadm.On("GetUserGroups", mtesting.ContextMatcher(), "1").
+					Return([]model.Group{{ID: "g1", Name: "devs"}}, nil)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeSCIMReq(http.MethodGet, uriSCIMUsers+"/1",
+				testSCIMToken, nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set(hdrIfNoneMatch, tc.ifNoneMatch)
+			}
+
+			recorded := test.RunRequest(t, api, req)
+			if tc.checker != nil {
+				mt.CheckResponse(t, tc.checker, recorded)
+				return
+			}
+			recorded.CodeIs(tc.status)
+			assert.Equal(t, version, recorded.Recorder.Header().Get(hdrETag))
+			if tc.status == http.StatusNotModified {
+				return
+			}
+			var user model.SCIMUser
+			decodeSCIMBody(t, recorded, &user)
+			assert.Equal(t, "foo@acme.com", user.UserName)
+			assert.Equal(t, []model.SCIMMember{{Value: "g1", Display: "devs"}},
+				user.Groups)
+			assert.Equal(t, version, user.Meta.Version)
+		})
+	}
+}
+
+func TestSCIMPatchUser(t *testing.T) {
+	t.Parallel()
+
+	handlers := &UserAdmApiHandlers{}
+	version := handlers.scimUser(testSCIMUser(), nil).Meta.Version
+
+	testCases := map[string]struct {
+		ifMatch string
+		body    interface{}
+
+		disabled bool
+		status   *model.UserStatusUpdate
+		update   *model.UserUpdate
+		password *model.UserUpdate
+		uaError  error
+
+		active  bool
+		checker mt.ResponseChecker
+	}{
+		"ok, deactivate": {
+			ifMatch: version,
+			body: model.SCIMPatchRequest{
+				Schemas: []string{model.SCIMSchemaPatchOp},
+				Operations: []model.SCIMPatchOperation{{
+					Op:    "replace",
+					Value: json.RawMessage(`{"active": false}`),
+				}},
+			},
+			status: &model.UserStatusUpdate{
+				Status: model.UserStatusDisabled,
+				Reason: "deactivated through SCIM",
+			},
+		},
+		"ok, reactivate": {
+			body: model.SCIMPatchRequest{
+				Schemas: []string{model.SCIMSchemaPatchOp},
+				Operations: []model.SCIMPatchOperation{{
+					Op:    "replace",
+					Path:  "active",
+					Value: json.RawMessage(`true`),
+				}},
+			},
+			disabled: true,
+			status: &model.UserStatusUpdate{
+				Status: model.UserStatusActive,
+				Reason: "activated through SCIM",
+			},
+
+			active: true,
+		},
+		"ok, change email and password": {
+			body: model.SCIMPatchRequest{
+				Operations: []model.SCIMPatchOperation{{
+					Op:    "replace",
+					Path:  "userName",
+					Value: json.RawMessage(`"bar@acme.com"`),
+				}, {
+					Op:    "replace",
+					Path:  "password",
+					Value: json.RawMessage(`"correcthorsebatterystaple"`),
+				}},
+			},
+			update: &model.UserUpdate{Email: "bar@acme.com"},
+			password: &model.UserUpdate{
+				Email:    "bar@acme.com",
+				Password: "correcthorsebatterystaple",
+			},
+
+			active: true,
+		},
+		"error: version mismatch": {
+			ifMatch: `W/"foo"`,
+			body: model.SCIMPatchRequest{
+				Operations: []model.SCIMPatchOperation{{
+					Op:    "replace",
+					Path:  "active",
+					Value: json.RawMessage(`false`),
+				}},
+			},
+
+			checker: scimResponse(http.StatusPreconditionFailed, nil,
+				errSCIMVersion),
+		},
+		"error: unsupported path": {
+			body: model.SCIMPatchRequest{
+				Operations: []model.SCIMPatchOperation{{
+					Op:    "replace",
+					Path:  "title",
+					Value: json.RawMessage(`"CEO"`),
+				}},
+			},
+
+			checker: scimErrorResponse(http.StatusBadRequest,
+				model.SCIMErrInvalidPath, "unsupported attribute: title"),
+		},
+		"error: no operations": {
+			body: model.SCIMPatchRequest{},
+
+			checker: scimErrorResponse(http.StatusBadRequest,
+				model.SCIMErrInvalidValue, "no operations provided"),
+		},
+		"error: duplicate email": {
+			body: model.SCIMPatchRequest{
+				Operations: []model.SCIMPatchOperation{{
+					Op:    "replace",
+					Path:  "emails[type eq \"work\"].value",
+					Value: json.RawMessage(`"bar@acme.com"`),
+				}},
+			},
+			update:  &model.UserUpdate{Email: "bar@acme.com"},
+			uaError: store.ErrDuplicateEmail,
+
+			checker: scimErrorResponse(http.StatusConflict,
+				model.SCIMErrUniqueness, store.ErrDuplicateEmail.Error()),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := makeSCIMApp()
+			defer uadm.AssertExpectations(t)
+			if _, ok := tc.body.(model.SCIMPatchRequest); ok &&
+				len(tc.body.(model.SCIMPatchRequest).Operations) > 0 {
+				user := testSCIMUser()
+				if tc.disabled {
+					user.Status = model.UserStatusDisabled
+				}
+				uadm.On("GetUser", mtesting.ContextMatcher(), "1").
+					Return(user, nil).Once()
+				if tc.status != nil {
+					// the user is fetched again with the new status
+					user = testSCIMUser()
+					user.Status = tc.status.Status
+				}
+				uadm.On("GetUser", mtesting.ContextMatcher(), "1").
+					Return(user, nil)
+				uadm.On("GetUserGroups", mtesting.ContextMatcher(), "1").
+					Return([]model.Group{}, nil)
+			}
+			if tc.status != nil {
+				uadm.On("SetUserStatus", mtesting.ContextMatcher(), "1",
+					tc.status).
+					Return(tc.uaError)
+			}
+			if tc.update != nil {
+				uadm.On("UpdateUser", mtesting.ContextMatcher(), "1",
+					tc.update).
+					Return(tc.uaError)
+			}
+			if tc.password != nil {
+				uadm.On("SetPassword", mtesting.ContextMatcher(),
+					*tc.password).
+					Return(nil)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeSCIMReq(http.MethodPatch, uriSCIMUsers+"/1",
+				testSCIMToken, tc.body)
+			if tc.ifMatch != "" {
+				req.Header.Set(hdrIfMatch, tc.ifMatch)
+			}
+
+			recorded := test.RunRequest(t, api, req)
+			if tc.checker != nil {
+				mt.CheckResponse(t, tc.checker, recorded)
+				return
+			}
+			recorded.CodeIs(http.StatusOK)
+			var user model.SCIMUser
+			decodeSCIMBody(t, recorded, &user)
+			assert.Equal(t, tc.active, user.IsActive())
+		})
+	}
+}
+
+func TestSCIMDeleteUser(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		user    *model.User
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			user: testSCIMUser(),
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: not found": {
+			checker: scimResponse(http.StatusNotFound, nil, errSCIMUserNotFound),
+		},
+		"error: useradm internal": {
+			user:    testSCIMUser(),
+			uaError: errors.New("some internal error"),
+
+			checker: scimErrorResponse(http.StatusInternalServerError, "",
+				"internal error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := makeSCIMApp()
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetUser", mtesting.ContextMatcher(), "1").
+				Return(tc.user, nil)
+			if tc.user != nil {
+				uadm.On("GetUserGroups", mtesting.ContextMatcher(), "1").
+					Return([]model.Group{}, nil)
+				uadm.On("DeleteUser", mtesting.ContextMatcher(), "1").
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeSCIMReq(http.MethodDelete, uriSCIMUsers+"/1",
+				testSCIMToken, nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSCIMCreateGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		members    []model.User
+		callCreate bool
+		uaError    error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body: model.SCIMGroup{
+				Schemas:     []string{model.SCIMSchemaGroup},
+				DisplayName: "devs",
+				Members:     []model.SCIMMember{{Value: "1"}},
+			},
+			members:    []model.User{*testSCIMUser()},
+			callCreate: true,
+		},
+		"error: unknown member": {
+			body: model.SCIMGroup{
+				DisplayName: "devs",
+				Members:     []model.SCIMMember{{Value: "1"}},
+			},
+			members: []model.User{},
+
+			checker: scimResponse(http.StatusBadRequest, nil,
+				errSCIMUnknownMember),
+		},
+		"error: duplicate name": {
+			body: model.SCIMGroup{
+				DisplayName: "devs",
+			},
+			callCreate: true,
+			uaError:    useradm.ErrDuplicateGroupName,
+
+			checker: scimErrorResponse(http.StatusConflict,
+				model.SCIMErrUniqueness,
+				useradm.ErrDuplicateGroupName.Error()),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := makeSCIMApp()
+			defer uadm.AssertExpectations(t)
+			if tc.members != nil {
+				uadm.On("GetUsers", mtesting.ContextMatcher(),
+					model.UserFilter{ID: []string{"1"}}).
+					Return(tc.members, nil)
+			}
+			if tc.callCreate {
+				group := &model.Group{
+					ID:        "g1",
+					Name:      "devs",
+					CreatedTs: &testSCIMTs,
+					UpdatedTs: &testSCIMTs,
+				}
+				if tc.uaError != nil {
+					group = nil
+				}
+				uadm.On("CreateGroup", mtesting.ContextMatcher(),
+					&model.GroupNew{Name: "devs"}).
+					Return(group, tc.uaError)
+			}
+			if tc.checker == nil {
+				uadm.On("AddGroupMember", mtesting.ContextMatcher(),
+					"g1", "1").
+					Return(nil)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeSCIMReq(http.MethodPost, uriSCIMGroups, testSCIMToken,
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			if tc.checker != nil {
+				mt.CheckResponse(t, tc.checker, recorded)
+				return
+			}
+			recorded.CodeIs(http.StatusCreated)
+			var group model.SCIMGroup
+			decodeSCIMBody(t, recorded, &group)
+			assert.Equal(t, "g1", group.ID)
+			assert.Equal(t, []model.SCIMMember{{Value: "1", Display: "foo@acme.com"}},
+				group.Members)
+		})
+	}
+}
+
+func TestSCIMPatchGroup(t *testing.T) {
+	t.Parallel()
+
+	group := &model.Group{
+		ID:        "g1",
+		Name:      "devs",
+		Members:   []string{"1"},
+		CreatedTs: &testSCIMTs,
+		UpdatedTs: &testSCIMTs,
+	}
+
+	uadm := makeSCIMApp()
+	defer uadm.AssertExpectations(t)
+	uadm.On("GetGroup", mtesting.ContextMatcher(), "g1").
+		Return(group, nil).Once()
+	uadm.On("GetUsers", mtesting.ContextMatcher(),
+		model.UserFilter{ID: []string{"1"}}).
+		Return([]model.User{*testSCIMUser()}, nil).Once()
+	uadm.On("GetUsers", mtesting.ContextMatcher(),
+		model.UserFilter{ID: []string{"2"}}).
+		Return([]model.User{{ID: "2", Email: "bar@acme.com"}}, nil).Twice()
+	name := "developers"
+	uadm.On("UpdateGroup", mtesting.ContextMatcher(), "g1",
+		&model.GroupUpdate{Name: &name}).
+		Return(nil)
+	uadm.On("AddGroupMember", mtesting.ContextMatcher(), "g1", "2").
+		Return(nil)
+	uadm.On("RemoveGroupMember", mtesting.ContextMatcher(), "g1", "1").
+		Return(nil)
+	uadm.On("GetGroup", mtesting.ContextMatcher(), "g1").
+		Return(&model.Group{
+			ID:        "g1",
+			Name:      "developers",
+			Members:   []string{"2"},
+			CreatedTs: &testSCIMTs,
+			UpdatedTs: &testSCIMTs,
+		}, nil).Once()
+
+	api := makeMockApiHandler(t, uadm, nil)
+
+	req := makeSCIMReq(http.MethodPatch, uriSCIMGroups+"/g1", testSCIMToken,
+		model.SCIMPatchRequest{
+			Schemas: []string{model.SCIMSchemaPatchOp},
+			Operations: []model.SCIMPatchOperation{{
+				Op:    "replace",
+				Path:  "displayName",
+				Value: json.RawMessage(`"developers"`),
+			}, {
+				Op:    "add",
+				Path:  "members",
+				Value: json.RawMessage(`[{"value": "2"}]`),
+			}, {
+				Op:   "remove",
+				Path: `members[value eq "1"]`,
+			}},
+		})
+
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	var resource model.SCIMGroup
+	decodeSCIMBody(t, recorded, &resource)
+	assert.Equal(t, "developers", resource.DisplayName)
+	assert.Equal(t, []model.SCIMMember{{Value: "2", Display: "bar@acme.com"}},
+		resource.Members)
+	assert.Equal(t, resource.Meta.Version, recorded.Recorder.Header().Get(hdrETag))
+}
+
+func TestSCIMDeleteGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: not found": {
+			uaError: useradm.ErrGroupNotFound,
+
+			checker: scimResponse(http.StatusNotFound, nil, errSCIMGroupNotFound),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := makeSCIMApp()
+			defer uadm.AssertExpectations(t)
+			if tc.uaError != nil {
+				uadm.On("GetGroup", mtesting.ContextMatcher(), "g1").
+					Return(nil, tc.uaError)
+			} else {
+				uadm.On("GetGroup", mtesting.ContextMatcher(), "g1").
+					Return(&model.Group{ID: "g1", Name: "devs"}, nil)
+				uadm.On("DeleteGroup", mtesting.ContextMatcher(), "g1").
+					Return(nil)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeSCIMReq(http.MethodDelete, uriSCIMGroups+"/g1",
+				testSCIMToken, nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestCreateSCIMToken(t *testing.T) {
+	t.Parallel()
+
+	token := &model.SCIMToken{
+		Token:     "secret",
+		CreatedTs: testSCIMTs,
+	}
+	approval := &model.Approval{
+		ID: "a1",
+		ApprovalRequest: model.ApprovalRequest{
+			Action:    model.ApprovalActionCreateSCIMToken,
+			SCIMToken: token,
+		},
+		Status: model.ApprovalStatusPending,
+	}
+
+	testCases := map[string]struct {
+		body interface{}
+
+		request     *model.SCIMTokenRequest
+		uaError     error
+		approval    *model.Approval
+		approvalErr error
+		saveError   error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			request: &model.SCIMTokenRequest{},
+
+			checker: mt.NewJSONResponse(http.StatusCreated, nil, token),
+		},
+		"ok, provisioning roles": {
+			body: map[string]interface{}{
+				"provisioning_roles": []string{"RBAC_ROLE_OBSERVER"},
+			},
+			request: &model.SCIMTokenRequest{
+				ProvisioningRoles: []string{"RBAC_ROLE_OBSERVER"},
+			},
+
+			checker: mt.NewJSONResponse(http.StatusCreated, nil, token),
+		},
+		"ok, approval required": {
+			request:  &model.SCIMTokenRequest{},
+			approval: approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				approval,
+			),
+		},
+		"error: unknown role": {
+			body: map[string]interface{}{
+				"provisioning_roles": []string{"foo"},
+			},
+			request: &model.SCIMTokenRequest{
+				ProvisioningRoles: []string{"foo"},
+			},
+			uaError: useradm.ErrUnknownRole,
+
+			checker: mt.NewJSONResponse(http.StatusBadRequest, nil,
+				restError(useradm.ErrUnknownRole.Error())),
+		},
+		"error: useradm internal": {
+			request: &model.SCIMTokenRequest{},
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(http.StatusInternalServerError, nil,
+				restError("internal error")),
+		},
+		"error: request approval": {
+			request:     &model.SCIMTokenRequest{},
+			approvalErr: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(http.StatusInternalServerError, nil,
+				restError("internal error")),
+		},
+		"error: save token": {
+			request:   &model.SCIMTokenRequest{},
+			saveError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(http.StatusInternalServerError, nil,
+				restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			var ret *model.SCIMToken
+			if tc.uaError == nil {
+				ret = token
+			}
+			uadm.On("NewSCIMToken", mtesting.ContextMatcher(), tc.request).
+				Return(ret, tc.uaError)
+			if tc.uaError == nil {
+				uadm.On("RequestApproval", mtesting.ContextMatcher(),
+					&model.ApprovalRequest{
+						Action:    model.ApprovalActionCreateSCIMToken,
+						SCIMToken: token,
+					}).Return(tc.approval, tc.approvalErr)
+			}
+			if tc.uaError == nil && tc.approval == nil && tc.approvalErr == nil {
+				uadm.On("SaveSCIMToken", mtesting.ContextMatcher(), token).
+					Return(tc.saveError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq(http.MethodPost,
+				"http://1.2.3.4"+uriManagementSCIMToken,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestDeleteSCIMToken(t *testing.T) {
+	t.Parallel()
+
+	approval := &model.Approval{
+		ID:              "a1",
+		ApprovalRequest: model.ApprovalRequest{Action: model.ApprovalActionDeleteSCIMToken},
+		Status:          model.ApprovalStatusPending,
+	}
+
+	testCases := map[string]struct {
+		approval    *model.Approval
+		approvalErr error
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, approval required": {
+			approval: approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				approval,
+			),
+		},
+		"error: request approval": {
+			approvalErr: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(http.StatusInternalServerError, nil,
+				restError("internal error")),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(http.StatusInternalServerError, nil,
+				restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("RequestApproval", mtesting.ContextMatcher(),
+				&model.ApprovalRequest{Action: model.ApprovalActionDeleteSCIMToken}).
+				Return(tc.approval, tc.approvalErr)
+			if tc.approval == nil && tc.approvalErr == nil {
+				uadm.On("DeleteSCIMToken", mtesting.ContextMatcher()).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq(http.MethodDelete,
+				"http://1.2.3.4"+uriManagementSCIMToken,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetSCIMToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		token   *model.SCIMToken
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			token: &model.SCIMToken{CreatedTs: testSCIMTs},
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil,
+				map[string]interface{}{"created_ts": testSCIMTs}),
+		},
+		"error: not configured": {
+			uaError: useradm.ErrSCIMNotConfigured,
+
+			checker: mt.NewJSONResponse(http.StatusNotFound, nil,
+				restError(useradm.ErrSCIMNotConfigured.Error())),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetSCIMToken", mtesting.ContextMatcher()).
+				Return(tc.token, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq(http.MethodGet,
+				"http://1.2.3.4"+uriManagementSCIMToken,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	uriManagementServiceAccounts      = apiUrlManagementV1 + "/service-accounts"
+	uriManagementServiceAccount       = apiUrlManagementV1 + "/service-accounts/:id"
+	uriManagementServiceAccountTokens = apiUrlManagementV1 + "/service-accounts/:id/tokens"
+	uriManagementServiceAccountToken  = apiUrlManagementV1 +
+		"/service-accounts/:id/tokens/:tid"
+	uriManagementServiceAccountSecrets = apiUrlManagementV1 + "/service-accounts/:id/secrets"
+
+	uriManagementOAuth2Token = apiUrlManagementV1 + "/oauth2/token"
+)
+
+func (u *UserAdmApiHandlers) CreateServiceAccountHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var saNew model.ServiceAccountNew
+	if err := r.DecodeJsonPayload(&saNew); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := saNew.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	sa, err := u.userAdm.CreateServiceAccount(ctx, &saNew)
+	switch err {
+	case nil:
+		w.Header().Add("Location", "service-accounts/"+sa.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = w.WriteJson(sa)
+	case useradm.ErrDuplicateServiceAccountName:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) GetServiceAccountsHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	serviceAccounts, err := u.userAdm.GetServiceAccounts(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	_ = w.WriteJson(serviceAccounts)
+}
+
+func (u *UserAdmApiHandlers) GetServiceAccountHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	sa, err := u.userAdm.GetServiceAccount(ctx, r.PathParam("id"))
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	if sa == nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			useradm.ErrServiceAccountNotFound, http.StatusNotFound)
+		return
+	}
+
+	_ = w.WriteJson(sa)
+}
+
+func (u *UserAdmApiHandlers) UpdateServiceAccountHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var saUpdate model.ServiceAccountUpdate
+	if err := r.DecodeJsonPayload(&saUpdate); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := saUpdate.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	err := u.userAdm.UpdateServiceAccount(ctx, r.PathParam("id"), &saUpdate)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case useradm.ErrServiceAccountNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	case useradm.ErrDuplicateServiceAccountName:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) DeleteServiceAccountHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := u.userAdm.DeleteServiceAccount(ctx, r.PathParam("id"))
+	switch err {
+	case nil, useradm.ErrServiceAccountNotFound:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) IssueServiceAccountTokenHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var tokenRequest model.TokenRequest
+	if err := r.DecodeJsonPayload(&tokenRequest); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.New("cannot parse request body as json"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := tokenRequest.Validate(u.config.TokenMaxExpSeconds); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	ctx, err := u.withTokenScope(r)
+	if err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
+		return
+	}
+
+	token, err := u.userAdm.IssueServiceAccountToken(ctx, r.PathParam("id"), &tokenRequest)
+	switch err {
+	case nil:
+		writer := w.(http.ResponseWriter)
+		writer.Header().Set("Content-Type", "application/jwt")
+		_, _ = writer.Write([]byte(token))
+	case useradm.ErrServiceAccountNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	case useradm.ErrTooManyTokens:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnprocessableEntity)
+	case useradm.ErrDuplicateTokenName:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	case useradm.ErrScopeNotPermitted:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) GetServiceAccountTokensHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	tokens, err := u.userAdm.GetServiceAccountTokens(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		_ = w.WriteJson(tokens)
+	case useradm.ErrServiceAccountNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) DeleteServiceAccountTokenHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := u.userAdm.DeleteServiceAccountToken(ctx, r.PathParam("id"), r.PathParam("tid"))
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (u *UserAdmApiHandlers) RotateServiceAccountSecretHandler(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	credentials, err := u.userAdm.RotateServiceAccountSecret(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		w.Header().Set("Cache-Control", "no-store")
+		_ = w.WriteJson(credentials)
+	case useradm.ErrServiceAccountNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// OAuth2TokenHandler is the OAuth 2.0 token endpoint (RFC 6749, section
+// 3.2) supporting the client credentials grant; the service accounts
+// authenticate with their client secret (HTTP Basic auth or form
+// parameters) or with a JWT assertion (RFC 7523).
+func (u *UserAdmApiHandlers) OAuth2TokenHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuth2Error(w, &model.OAuth2Error{
+			Code:        model.OAuth2ErrInvalidRequest,
+			Description: "malformed request body",
+		})
+		return
+	}
+	clientID, clientSecret, basicAuth := r.BasicAuth()
+	req, oerr := model.ParseClientCredentialsRequest(
+		r.PostForm, clientID, clientSecret, basicAuth)
+	if oerr != nil {
+		writeOAuth2Error(w, oerr)
+		return
+	}
+
+	token, err := u.userAdm.IssueClientCredentialsToken(ctx, req)
+	switch err {
+	case nil:
+	case useradm.ErrInvalidClient:
+		l.Warnf("client credentials grant: client %q: %s", req.ClientID, err.Error())
+		writeOAuth2Error(w, &model.OAuth2Error{
+			Code:        model.OAuth2ErrInvalidClient,
+			Description: err.Error(),
+		})
+		return
+	case useradm.ErrInvalidScope:
+		writeOAuth2Error(w, &model.OAuth2Error{
+			Code:        model.OAuth2ErrInvalidScope,
+			Description: err.Error(),
+		})
+		return
+	default:
+		l.Errorf("client credentials grant: %s", err.Error())
+		writeOAuth2Error(w, &model.OAuth2Error{Code: model.OAuth2ErrServerError})
+		return
+	}
+
+	raw, err := u.userAdm.SignToken(ctx, token)
+	if err != nil {
+		l.Errorf("client credentials grant: %s", err.Error())
+		writeOAuth2Error(w, &model.OAuth2Error{Code: model.OAuth2ErrServerError})
+		return
+	}
+
+	_ = w.WriteJson(&model.AccessTokenResponse{
+		AccessToken: raw,
+		TokenType:   model.TokenTypeBearer,
+		ExpiresIn: int64(token.ExpiresAt.Sub(token.IssuedAt.Time).
+			Round(time.Second) / time.Second),
+		Scope: token.Scope,
+	})
+}
+
+// writeOAuth2Error writes the error response of the token endpoint
+// (RFC 6749, section 5.2), which differs from the usual error format.
+func writeOAuth2Error(w rest.ResponseWriter, oerr *model.OAuth2Error) {
+	status := http.StatusBadRequest
+	switch oerr.Code {
+	case model.OAuth2ErrInvalidClient:
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="useradm"`)
+	case model.OAuth2ErrServerError:
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = w.WriteJson(oerr)
+}
@@ -0,0 +1,810 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+func TestCreateServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	sa := &model.ServiceAccountWithCredentials{
+		ServiceAccount: model.ServiceAccount{
+			ID:        "1",
+			Name:      "ci",
+			CreatedTs: &now,
+			UpdatedTs: &now,
+		},
+		ServiceAccountCredentials: model.ServiceAccountCredentials{
+			ClientID:     "1",
+			ClientSecret: "secret",
+		},
+	}
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaSA        *model.ServiceAccountWithCredentials
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body: map[string]interface{}{
+				"name": "ci",
+			},
+			callUseradm: true,
+			uaSA:        sa,
+
+			checker: mt.NewJSONResponse(
+				http.StatusCreated,
+				map[string]string{"Location": "service-accounts/1"},
+				sa,
+			),
+		},
+		"error: missing name": {
+			body: map[string]interface{}{
+				"description": "pipeline",
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("name: cannot be blank."),
+			),
+		},
+		"error: duplicate name": {
+			body: map[string]interface{}{
+				"name": "ci",
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrDuplicateServiceAccountName,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("service account with a given name already exists"),
+			),
+		},
+		"error: useradm internal": {
+			body: map[string]interface{}{
+				"name": "ci",
+			},
+			callUseradm: true,
+			uaError:     errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("CreateServiceAccount", mtesting.ContextMatcher(),
+					mock.AnythingOfType("*model.ServiceAccountNew")).
+					Return(tc.uaSA, tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+uriManagementServiceAccounts,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetServiceAccounts(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaSAs   []model.ServiceAccount
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaSAs: []model.ServiceAccount{
+				{ID: "1", Name: "ci"},
+				{ID: "2", Name: "monitoring"},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.ServiceAccount{
+					{ID: "1", Name: "ci"},
+					{ID: "2", Name: "monitoring"},
+				},
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetServiceAccounts", mtesting.ContextMatcher()).
+				Return(tc.uaSAs, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementServiceAccounts,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaSA    *model.ServiceAccount
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaSA: &model.ServiceAccount{
+				ID:   "1",
+				Name: "ci",
+				Secrets: []model.ServiceAccountSecret{
+					{Hash: "hash"},
+				},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				map[string]interface{}{
+					"id":   "1",
+					"name": "ci",
+				},
+			),
+		},
+		"not found": {
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("service account not found"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetServiceAccount", mtesting.ContextMatcher(), "1").
+				Return(tc.uaSA, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV1+"/service-accounts/1",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestUpdateServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body: map[string]interface{}{
+				"description": "pipeline",
+			},
+			callUseradm: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: empty update": {
+			body: map[string]interface{}{},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError(model.ErrEmptyUpdate.Error()),
+			),
+		},
+		"error: not found": {
+			body: map[string]interface{}{
+				"name": "ci",
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrServiceAccountNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("service account not found"),
+			),
+		},
+		"error: duplicate name": {
+			body: map[string]interface{}{
+				"name": "ci",
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrDuplicateServiceAccountName,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("service account with a given name already exists"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("UpdateServiceAccount", mtesting.ContextMatcher(), "1",
+					mock.AnythingOfType("*model.ServiceAccountUpdate")).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+apiUrlManagementV1+"/service-accounts/1",
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestDeleteServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"ok, not found": {
+			uaError: useradm.ErrServiceAccountNotFound,
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("DeleteServiceAccount", mtesting.ContextMatcher(), "1").
+				Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("DELETE",
+				"http://1.2.3.4"+apiUrlManagementV1+"/service-accounts/1",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestIssueServiceAccountToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callUseradm bool
+		uaError     error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body: map[string]interface{}{
+				"name":       "foo",
+				"expires_in": 3600,
+			},
+			callUseradm: true,
+
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/jwt",
+				Body:        "foo",
+			},
+		},
+		"error: expires_in too high": {
+			body: map[string]interface{}{
+				"name":       "foo",
+				"expires_in": 31536001,
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("expires_in: must be no greater than 31536000.")),
+		},
+		"error: service account not found": {
+			body: map[string]interface{}{
+				"name":       "foo",
+				"expires_in": 3600,
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrServiceAccountNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("service account not found")),
+		},
+		"error: token with the same name already exist": {
+			body: map[string]interface{}{
+				"name":       "foo",
+				"expires_in": 3600,
+			},
+			callUseradm: true,
+			uaError:     useradm.ErrDuplicateTokenName,
+
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				restError("Personal Access Token with a given name already exists")),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callUseradm {
+				uadm.On("IssueServiceAccountToken", mtesting.ContextMatcher(), "1",
+					mock.AnythingOfType("*model.TokenRequest")).
+					Return("foo", tc.uaError)
+			}
+
+			api := makeMockApiHandlerWithConfig(t, uadm, nil, Config{
+				TokenMaxExpSeconds: 31536000,
+			})
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+apiUrlManagementV1+"/service-accounts/1/tokens",
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetServiceAccountTokens(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaTokens []model.PersonalAccessToken
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaTokens: []model.PersonalAccessToken{
+				{
+					ID:   oid.FromString("1"),
+					Name: strPtr("foo"),
+				},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.PersonalAccessToken{
+					{
+						ID:   oid.FromString("1"),
+						Name: strPtr("foo"),
+					},
+				},
+			),
+		},
+		"error: service account not found": {
+			uaError: useradm.ErrServiceAccountNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("service account not found"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetServiceAccountTokens", mtesting.ContextMatcher(), "1").
+				Return(tc.uaTokens, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV1+"/service-accounts/1/tokens",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestDeleteServiceAccountToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("DeleteServiceAccountToken", mtesting.ContextMatcher(), "1", "2").
+				Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("DELETE",
+				"http://1.2.3.4"+apiUrlManagementV1+"/service-accounts/1/tokens/2",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestRotateServiceAccountSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaCreds *model.ServiceAccountCredentials
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaCreds: &model.ServiceAccountCredentials{
+				ClientID:     "1",
+				ClientSecret: "secret",
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				map[string]string{"Cache-Control": "no-store"},
+				&model.ServiceAccountCredentials{
+					ClientID:     "1",
+					ClientSecret: "secret",
+				},
+			),
+		},
+		"error: not found": {
+			uaError: useradm.ErrServiceAccountNotFound,
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError("service account not found"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("RotateServiceAccountSecret", mtesting.ContextMatcher(), "1").
+				Return(tc.uaCreds, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+apiUrlManagementV1+"/service-accounts/1/secrets",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestOAuth2Token(t *testing.T) {
+	t.Parallel()
+
+	issuedAt := time.Now()
+	token := &jwt.Token{
+		Claims: jwt.Claims{
+			ID:        oid.NewUUIDv5("token-1"),
+			Subject:   oid.NewUUIDv5("sa-1"),
+			IssuedAt:  jwt.Time{Time: issuedAt},
+			ExpiresAt: jwt.Time{Time: issuedAt.Add(time.Hour)},
+		},
+	}
+
+	testCases := map[string]struct {
+		form       url.Values
+		basicAuth  []string
+		uaRequest  *model.ClientCredentialsRequest
+		uaToken    *jwt.Token
+		uaError    error
+		uaSignErr  error
+		callSigner bool
+
+		checker mt.ResponseChecker
+	}{
+		"ok, basic auth": {
+			form: url.Values{
+				"grant_type": {"client_credentials"},
+			},
+			basicAuth: []string{"client-1", "secret"},
+			uaRequest: &model.ClientCredentialsRequest{
+				ClientID:     "client-1",
+				ClientSecret: "secret",
+			},
+			uaToken:    token,
+			callSigner: true,
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				map[string]string{"Cache-Control": "no-store"},
+				&model.AccessTokenResponse{
+					AccessToken: "signed",
+					TokenType:   "Bearer",
+					ExpiresIn:   3600,
+				},
+			),
+		},
+		"ok, client assertion": {
+			form: url.Values{
+				"grant_type":            {"client_credentials"},
+				"client_assertion_type": {model.ClientAssertionTypeJWTBearer},
+				"client_assertion":      {"assertion"},
+			},
+			uaRequest: &model.ClientCredentialsRequest{
+				ClientAssertionType: model.ClientAssertionTypeJWTBearer,
+				ClientAssertion:     "assertion",
+			},
+			uaToken:    token,
+			callSigner: true,
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				&model.AccessTokenResponse{
+					AccessToken: "signed",
+					TokenType:   "Bearer",
+					ExpiresIn:   3600,
+				},
+			),
+		},
+		"error: unsupported grant": {
+			form: url.Values{
+				"grant_type": {"password"},
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				&model.OAuth2Error{
+					Code:        "unsupported_grant_type",
+					Description: "only the client_credentials grant is supported",
+				},
+			),
+		},
+		"error: invalid client": {
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {"client-1"},
+				"client_secret": {"wrong"},
+			},
+			uaRequest: &model.ClientCredentialsRequest{
+				ClientID:     "client-1",
+				ClientSecret: "wrong",
+			},
+			uaError: useradm.ErrInvalidClient,
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				map[string]string{"WWW-Authenticate": `Basic realm="useradm"`},
+				&model.OAuth2Error{
+					Code:        "invalid_client",
+					Description: "client authentication failed",
+				},
+			),
+		},
+		"error: invalid scope": {
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {"client-1"},
+				"client_secret": {"secret"},
+				"scope":         {"foo"},
+			},
+			uaRequest: &model.ClientCredentialsRequest{
+				ClientID:     "client-1",
+				ClientSecret: "secret",
+				Scope:        "foo",
+			},
+			uaError: useradm.ErrInvalidScope,
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				&model.OAuth2Error{
+					Code:        "invalid_scope",
+					Description: "requested scope is invalid or unknown",
+				},
+			),
+		},
+		"error: internal": {
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {"client-1"},
+				"client_secret": {"secret"},
+			},
+			uaRequest: &model.ClientCredentialsRequest{
+				ClientID:     "client-1",
+				ClientSecret: "secret",
+			},
+			uaError: errors.New("db error"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				&model.OAuth2Error{Code: "server_error"},
+			),
+		},
+		"error: signing": {
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {"client-1"},
+				"client_secret": {"secret"},
+			},
+			uaRequest: &model.ClientCredentialsRequest{
+				ClientID:     "client-1",
+				ClientSecret: "secret",
+			},
+			uaToken:    token,
+			callSigner: true,
+			uaSignErr:  errors.New("signer unavailable"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				&model.OAuth2Error{Code: "server_error"},
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.uaRequest != nil {
+				uadm.On("IssueClientCredentialsToken", mtesting.ContextMatcher(),
+					tc.uaRequest).
+					Return(tc.uaToken, tc.uaError)
+			}
+			if tc.callSigner {
+				uadm.On("SignToken", mtesting.ContextMatcher(), tc.uaToken).
+					Return("signed", tc.uaSignErr)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req, _ := http.NewRequest(http.MethodPost,
+				"http://1.2.3.4"+uriManagementOAuth2Token,
+				strings.NewReader(tc.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.basicAuth != nil {
+				req.SetBasicAuth(tc.basicAuth[0], tc.basicAuth[1])
+			}
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
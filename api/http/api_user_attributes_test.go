@@ -0,0 +1,229 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest/test"
+	mt "github.com/mendersoftware/go-lib-micro/testing"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	useradm "github.com/mendersoftware/useradm/user"
+	museradm "github.com/mendersoftware/useradm/user/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+func TestGetUserAttributeSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := &model.UserAttributeSchema{
+		Attributes: []model.UserAttribute{{
+			Name:       "department",
+			Type:       model.UserAttributeTypeString,
+			Searchable: true,
+		}},
+	}
+
+	testCases := map[string]struct {
+		uaSchema *model.UserAttributeSchema
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			uaSchema: schema,
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil, schema),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("GetUserAttributeSchema", mtesting.ContextMatcher()).
+				Return(tc.uaSchema, tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+uriManagementUserAttributes,
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestSaveUserAttributeSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := &model.UserAttributeSchema{
+		Attributes: []model.UserAttribute{{
+			Name:     "employee_id",
+			Type:     model.UserAttributeTypeNumber,
+			Required: true,
+			Unique:   true,
+		}},
+	}
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callSave bool
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body:     schema,
+			callSave: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: bad request": {
+			body: "foo",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("failed to decode request body: "+
+					"json: cannot unmarshal string into Go value of type "+
+					"model.UserAttributeSchema"),
+			),
+		},
+		"error: invalid type": {
+			body: map[string]interface{}{
+				"attributes": []interface{}{
+					map[string]interface{}{
+						"name": "employee_id",
+						"type": "integer",
+					},
+				},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("attributes: (0: (type: must be a valid value.).)."),
+			),
+		},
+		"error: useradm internal": {
+			body:     schema,
+			callSave: true,
+			uaError:  errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callSave {
+				uadm.On("SaveUserAttributeSchema",
+					mtesting.ContextMatcher(), schema).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+uriManagementUserAttributes,
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestAddUserAttributeErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"error: invalid attribute": {
+			uaError: errors.Wrap(model.ErrInvalidUserAttribute,
+				"department: cannot be blank"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("department: cannot be blank: "+
+					model.ErrInvalidUserAttribute.Error()),
+			),
+		},
+		"error: duplicate attribute": {
+			uaError: errors.Wrap(useradm.ErrDuplicateUserAttribute,
+				"employee_id"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnprocessableEntity,
+				nil,
+				restError("employee_id: "+
+					useradm.ErrDuplicateUserAttribute.Error()),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("CreateUser", mtesting.ContextMatcher(), &model.User{
+				Email:      "foo@acme.com",
+				Password:   "correcthorsebatterystaple",
+				Attributes: model.UserAttributes{"employee_id": float64(42)},
+			}).Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+uriManagementUsers,
+				"",
+				map[string]interface{}{
+					"email":      "foo@acme.com",
+					"password":   "correcthorsebatterystaple",
+					"attributes": map[string]interface{}{"employee_id": 42},
+				})
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
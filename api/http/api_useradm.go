@@ -15,7 +15,11 @@ package http
 
 import (
 	"context"
+	"crypto/subtle"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,30 +34,35 @@ import (
 	"github.com/mendersoftware/useradm/authz"
 	"github.com/mendersoftware/useradm/jwt"
 	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
 	"github.com/mendersoftware/useradm/store"
 	useradm "github.com/mendersoftware/useradm/user"
 )
 
 const (
-	apiUrlManagementV1      = "/api/management/v1/useradm"
-	uriManagementAuthLogin  = apiUrlManagementV1 + "/auth/login"
-	uriManagementAuthLogout = apiUrlManagementV1 + "/auth/logout"
-	uriManagementUser       = apiUrlManagementV1 + "/users/:id"
-	uriManagementUsers      = apiUrlManagementV1 + "/users"
-	uriManagementSettings   = apiUrlManagementV1 + "/settings"
-	uriManagementSettingsMe = apiUrlManagementV1 + "/settings/me"
-	uriManagementTokens     = apiUrlManagementV1 + "/settings/tokens"
-	uriManagementToken      = apiUrlManagementV1 + "/settings/tokens/:id"
+	apiUrlManagementV1       = "/api/management/v1/useradm"
+	uriManagementAuthLogin   = apiUrlManagementV1 + "/auth/login"
+	uriManagementAuthLogout  = apiUrlManagementV1 + "/auth/logout"
+	uriManagementUser        = apiUrlManagementV1 + "/users/:id"
+	uriManagementUsers       = apiUrlManagementV1 + "/users"
+	uriManagementUserStatus  = apiUrlManagementV1 + "/users/:id/status"
+	uriManagementUserRestore = apiUrlManagementV1 + "/users/:id/restore"
+	uriManagementSettings    = apiUrlManagementV1 + "/settings"
+	uriManagementSettingsMe  = apiUrlManagementV1 + "/settings/me"
+	uriManagementTokens      = apiUrlManagementV1 + "/settings/tokens"
+	uriManagementToken       = apiUrlManagementV1 + "/settings/tokens/:id"
+	uriManagementAllTokens   = apiUrlManagementV1 + "/tokens"
 
 	apiUrlInternalV1  = "/api/internal/v1/useradm"
 	uriInternalAlive  = apiUrlInternalV1 + "/alive"
 	uriInternalHealth = apiUrlInternalV1 + "/health"
 
-	uriInternalAuthVerify  = apiUrlInternalV1 + "/auth/verify"
-	uriInternalTenants     = apiUrlInternalV1 + "/tenants"
-	uriInternalTenantUsers = apiUrlInternalV1 + "/tenants/:id/users"
-	uriInternalTenantUser  = apiUrlInternalV1 + "/tenants/:id/users/:userid"
-	uriInternalTokens      = apiUrlInternalV1 + "/tokens"
+	uriInternalAuthVerify     = apiUrlInternalV1 + "/auth/verify"
+	uriInternalAuthIntrospect = apiUrlInternalV1 + "/auth/introspect"
+	uriInternalTenants        = apiUrlInternalV1 + "/tenants"
+	uriInternalTenantUsers    = apiUrlInternalV1 + "/tenants/:id/users"
+	uriInternalTenantUser     = apiUrlInternalV1 + "/tenants/:id/users/:userid"
+	uriInternalTokens         = apiUrlInternalV1 + "/tokens"
 )
 
 const (
@@ -61,8 +70,34 @@ const (
 	pathParamMe    = "me"
 	hdrETag        = "ETag"
 	hdrIfMatch     = "If-Match"
+	hdrTotalCount  = "X-Total-Count"
+	paramSort      = "sort"
+	paramCursor    = "cursor"
+	linkLast       = "last"
 )
 
+// the identity of the verified token's subject, exposed in the
+// /auth/verify response headers
+const (
+	IdentityUserID    = "user_id"
+	IdentityTenantID  = "tenant_id"
+	IdentityEmail     = "email"
+	IdentityRoles     = "roles"
+	IdentityTokenType = "token_type"
+	IdentityTokenID   = "token_id"
+)
+
+// IdentityHeaders maps the identity attributes to the headers exposing
+// them; multiple roles are separated by commas.
+var IdentityHeaders = map[string]string{
+	IdentityUserID:    "X-MEN-User-ID",
+	IdentityTenantID:  "X-MEN-Tenant-ID",
+	IdentityEmail:     "X-MEN-User-Email",
+	IdentityRoles:     "X-MEN-User-Roles",
+	IdentityTokenType: "X-MEN-Token-Type",
+	IdentityTokenID:   "X-MEN-Token-ID",
+}
+
 const (
 	uriUIRoot = "/"
 )
@@ -70,6 +105,14 @@ const (
 var (
 	ErrAuthHeader   = errors.New("invalid or missing auth header")
 	ErrUserNotFound = errors.New("user not found")
+
+	ErrIntrospectionClientUnauthorized = errors.New("client authentication failed")
+	ErrIntrospectionNoToken            = errors.New("missing token parameter")
+
+	ErrUnknownIdentityHeader = errors.New("unknown identity header")
+
+	errPageWithCursor = errors.New("page and cursor parameters are mutually exclusive")
+	errCursorSort     = errors.New("sort: must be the sort of the cursor")
 )
 
 type UserAdmApiHandlers struct {
@@ -82,6 +125,28 @@ type UserAdmApiHandlers struct {
 type Config struct {
 	// maximum expiration time for Personal Access Token
 	TokenMaxExpSeconds int
+	// client ID to client secret map of the clients allowed to use
+	// the token introspection endpoint
+	IntrospectionClients map[string]string
+	// authorizer of the requests verified through the API gateway,
+	// explained by the authz explain endpoint
+	Authorizer authz.Authorizer
+	// public URL of the server, the base of the single sign-on
+	// endpoints registered with the identity providers
+	ServerURL string
+	// identity attributes returned in the /auth/verify response headers,
+	// see IdentityHeaders; none if empty
+	IdentityHeaders []string
+}
+
+// ValidateIdentityHeaders checks the allowlist of the identity headers.
+func ValidateIdentityHeaders(names []string) error {
+	for _, name := range names {
+		if _, ok := IdentityHeaders[name]; !ok {
+			return errors.Wrap(ErrUnknownIdentityHeader, name)
+		}
+	}
+	return nil
 }
 
 // return an ApiHandler for user administration and authentiacation app
@@ -106,11 +171,13 @@ func (i *UserAdmApiHandlers) GetApp() (rest.App, error) {
 
 		rest.Get(uriInternalAuthVerify, i.AuthVerifyHandler),
 		rest.Post(uriInternalAuthVerify, i.AuthVerifyHandler),
+		rest.Post(uriInternalAuthIntrospect, i.IntrospectTokenHandler),
 		rest.Post(uriInternalTenants, i.CreateTenantHandler),
 		rest.Post(uriInternalTenantUsers, i.CreateTenantUserHandler),
 		rest.Delete(uriInternalTenantUser, i.DeleteTenantUserHandler),
 		rest.Get(uriInternalTenantUsers, i.GetTenantUsersHandler),
 		rest.Delete(uriInternalTokens, i.DeleteTokensHandler),
+		rest.Get(uriInternalTokenRevocations, i.GetRevocationListHandler),
 
 		rest.Post(uriManagementAuthLogin, i.AuthLoginHandler),
 		rest.Post(uriManagementAuthLogout, i.AuthLogoutHandler),
@@ -119,6 +186,8 @@ func (i *UserAdmApiHandlers) GetApp() (rest.App, error) {
 		rest.Get(uriManagementUser, i.GetUserHandler),
 		rest.Put(uriManagementUser, i.UpdateUserHandler),
 		rest.Delete(uriManagementUser, i.DeleteUserHandler),
+		rest.Put(uriManagementUserStatus, i.SetUserStatusHandler),
+		rest.Post(uriManagementUserRestore, i.RestoreUserHandler),
 		rest.Post(uriManagementSettings, i.SaveSettingsHandler),
 		rest.Get(uriManagementSettings, i.GetSettingsHandler),
 		rest.Post(uriManagementSettingsMe, i.SaveSettingsMeHandler),
@@ -126,6 +195,74 @@ func (i *UserAdmApiHandlers) GetApp() (rest.App, error) {
 		rest.Post(uriManagementTokens, i.IssueTokenHandler),
 		rest.Get(uriManagementTokens, i.GetTokensHandler),
 		rest.Delete(uriManagementToken, i.DeleteTokenHandler),
+		rest.Delete(uriManagementAllTokens, i.RevokeTokensHandler),
+		rest.Post(uriManagementServiceAccounts, i.CreateServiceAccountHandler),
+		rest.Get(uriManagementServiceAccounts, i.GetServiceAccountsHandler),
+		rest.Get(uriManagementServiceAccount, i.GetServiceAccountHandler),
+		rest.Put(uriManagementServiceAccount, i.UpdateServiceAccountHandler),
+		rest.Delete(uriManagementServiceAccount, i.DeleteServiceAccountHandler),
+		rest.Post(uriManagementServiceAccountTokens, i.IssueServiceAccountTokenHandler),
+		rest.Get(uriManagementServiceAccountTokens, i.GetServiceAccountTokensHandler),
+		rest.Delete(uriManagementServiceAccountToken, i.DeleteServiceAccountTokenHandler),
+		rest.Post(uriManagementServiceAccountSecrets, i.RotateServiceAccountSecretHandler),
+		rest.Post(uriManagementOAuth2Token, i.OAuth2TokenHandler),
+		rest.Get(uriManagementRoles, i.GetRolesHandler),
+		rest.Post(uriManagementRoles, i.CreateRoleHandler),
+		rest.Get(uriManagementRole, i.GetRoleHandler),
+		rest.Put(uriManagementRole, i.UpdateRoleHandler),
+		rest.Delete(uriManagementRole, i.DeleteRoleHandler),
+		rest.Get(uriManagementUserRoles, i.GetUserRolesHandler),
+		rest.Put(uriManagementUserRoles, i.SetUserRolesHandler),
+		rest.Get(uriManagementGroups, i.GetGroupsHandler),
+		rest.Post(uriManagementGroups, i.CreateGroupHandler),
+		rest.Get(uriManagementGroup, i.GetGroupHandler),
+		rest.Put(uriManagementGroup, i.UpdateGroupHandler),
+		rest.Delete(uriManagementGroup, i.DeleteGroupHandler),
+		rest.Put(uriManagementGroupMember, i.AddGroupMemberHandler),
+		rest.Delete(uriManagementGroupMember, i.RemoveGroupMemberHandler),
+		rest.Get(uriManagementUserGroups, i.GetUserGroupsHandler),
+		rest.Get(uriManagementElevations, i.GetElevationsHandler),
+		rest.Post(uriManagementElevations, i.RequestElevationHandler),
+		rest.Get(uriManagementElevation, i.GetElevationHandler),
+		rest.Post(uriManagementElevationApprove, i.ApproveElevationHandler),
+		rest.Post(uriManagementElevationReject, i.RejectElevationHandler),
+		rest.Get(uriManagementApprovals, i.GetApprovalsHandler),
+		rest.Get(uriManagementApproval, i.GetApprovalHandler),
+		rest.Post(uriManagementApprovalApprove, i.ApproveActionHandler),
+		rest.Post(uriManagementApprovalReject, i.RejectActionHandler),
+		rest.Get(uriManagementApprovalSettings, i.GetApprovalSettingsHandler),
+		rest.Put(uriManagementApprovalSettings, i.SaveApprovalSettingsHandler),
+		rest.Get(uriManagementSAMLConfig, i.GetSAMLConfigHandler),
+		rest.Put(uriManagementSAMLConfig, i.SaveSAMLConfigHandler),
+		rest.Delete(uriManagementSAMLConfig, i.DeleteSAMLConfigHandler),
+		rest.Get(uriManagementSAMLMetadata, i.SAMLMetadataHandler),
+		rest.Get(uriManagementSAMLLogin, i.SAMLLoginHandler),
+		rest.Post(uriManagementSAMLACS, i.SAMLACSHandler),
+		rest.Get(uriManagementOIDCConfig, i.GetOIDCConfigHandler),
+		rest.Put(uriManagementOIDCConfig, i.SaveOIDCConfigHandler),
+		rest.Delete(uriManagementOIDCConfig, i.DeleteOIDCConfigHandler),
+		rest.Get(uriManagementOIDCLogin, i.OIDCLoginHandler),
+		rest.Get(uriManagementOIDCCallback, i.OIDCCallbackHandler),
+		rest.Get(uriManagementUserAttributes, i.GetUserAttributeSchemaHandler),
+		rest.Put(uriManagementUserAttributes, i.SaveUserAttributeSchemaHandler),
+		rest.Get(uriManagementSCIMToken, i.GetSCIMTokenHandler),
+		rest.Post(uriManagementSCIMToken, i.CreateSCIMTokenHandler),
+		rest.Delete(uriManagementSCIMToken, i.DeleteSCIMTokenHandler),
+		rest.Get(uriSCIMUsers, i.scimAuth(i.SCIMGetUsersHandler)),
+		rest.Post(uriSCIMUsers, i.scimAuth(i.SCIMCreateUserHandler)),
+		rest.Get(uriSCIMUser, i.scimAuth(i.SCIMGetUserHandler)),
+		rest.Put(uriSCIMUser, i.scimAuth(i.SCIMReplaceUserHandler)),
+		rest.Patch(uriSCIMUser, i.scimAuth(i.SCIMPatchUserHandler)),
+		rest.Delete(uriSCIMUser, i.scimAuth(i.SCIMDeleteUserHandler)),
+		rest.Get(uriSCIMGroups, i.scimAuth(i.SCIMGetGroupsHandler)),
+		rest.Post(uriSCIMGroups, i.scimAuth(i.SCIMCreateGroupHandler)),
+		rest.Get(uriSCIMGroup, i.scimAuth(i.SCIMGetGroupHandler)),
+		rest.Put(uriSCIMGroup, i.scimAuth(i.SCIMReplaceGroupHandler)),
+		rest.Patch(uriSCIMGroup, i.scimAuth(i.SCIMPatchGroupHandler)),
+		rest.Delete(uriSCIMGroup, i.scimAuth(i.SCIMDeleteGroupHandler)),
+		rest.Get(uriSCIMServiceConfig,
+			i.scimAuth(i.SCIMServiceProviderConfigHandler)),
+		rest.Post(uriManagementAuthzExplain, i.ExplainAuthzHandler),
 	}
 
 	app, err := rest.MakeRouter(
@@ -174,7 +311,8 @@ func (u *UserAdmApiHandlers) AuthLoginHandler(w rest.ResponseWriter, r *rest.Req
 	token, err := u.userAdm.Login(ctx, email, pass)
 	if err != nil {
 		switch {
-		case err == useradm.ErrUnauthorized || err == useradm.ErrTenantAccountSuspended:
+		case err == useradm.ErrUnauthorized || err == useradm.ErrTenantAccountSuspended ||
+			err == useradm.ErrPasswordLoginDisabled || err == useradm.ErrUserDisabled:
 			rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
 		default:
 			rest_utils.RestErrWithLogInternal(w, r, l, err)
@@ -190,7 +328,13 @@ func (u *UserAdmApiHandlers) AuthLoginHandler(w rest.ResponseWriter, r *rest.Req
 
 	writer := w.(http.ResponseWriter)
 	writer.Header().Set("Content-Type", "application/jwt")
-	http.SetCookie(writer, &http.Cookie{
+	setTokenCookie(writer, token, raw)
+	_, _ = writer.Write([]byte(raw))
+}
+
+// setTokenCookie sets the session token for the UI
+func setTokenCookie(w http.ResponseWriter, token *jwt.Token, raw string) {
+	http.SetCookie(w, &http.Cookie{
 		Name:     "JWT",
 		Value:    raw,
 		Path:     uriUIRoot,
@@ -198,7 +342,6 @@ func (u *UserAdmApiHandlers) AuthLoginHandler(w rest.ResponseWriter, r *rest.Req
 		Secure:   true,
 		Expires:  token.ExpiresAt.Time,
 	})
-	_, _ = writer.Write([]byte(raw))
 }
 
 func (u *UserAdmApiHandlers) AuthLogoutHandler(w rest.ResponseWriter, r *rest.Request) {
@@ -206,7 +349,7 @@ func (u *UserAdmApiHandlers) AuthLogoutHandler(w rest.ResponseWriter, r *rest.Re
 	l := log.FromContext(ctx)
 
 	if tokenStr, err := authz.ExtractToken(r.Request); err == nil {
-		token, err := u.jwth.FromJWT(tokenStr)
+		token, err := u.jwth.FromJWT(ctx, tokenStr)
 		if err != nil {
 			rest_utils.RestErrWithLogInternal(w, r, l, err)
 			return
@@ -228,7 +371,7 @@ func (u *UserAdmApiHandlers) AuthVerifyHandler(w rest.ResponseWriter, r *rest.Re
 	// note that the request has passed through authz - the token is valid
 	token := authz.GetRequestToken(r.Env)
 
-	err := u.userAdm.Verify(ctx, token)
+	ti, err := u.userAdm.Verify(ctx, token)
 	if err != nil {
 		if err == useradm.ErrUnauthorized {
 			rest_utils.RestErrWithLog(w, r, l, useradm.ErrUnauthorized, http.StatusUnauthorized)
@@ -238,9 +381,102 @@ func (u *UserAdmApiHandlers) AuthVerifyHandler(w rest.ResponseWriter, r *rest.Re
 		return
 	}
 
+	// the identity is exposed only once the token has passed all the
+	// checks, so that the gateway never forwards an unverified identity
+	for _, name := range u.config.IdentityHeaders {
+		var value string
+		switch name {
+		case IdentityUserID:
+			value = ti.UserID
+		case IdentityTenantID:
+			value = ti.Tenant
+		case IdentityEmail:
+			value = string(ti.Email)
+		case IdentityRoles:
+			value = strings.Join(ti.Roles, ",")
+		case IdentityTokenType:
+			value = ti.TokenType
+		case IdentityTokenID:
+			value = ti.TokenID
+		}
+		if value != "" {
+			w.Header().Set(IdentityHeaders[name], value)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// TokenVerifier returns the authz.TokenVerifier running the checks of
+// /auth/verify, for authorizing the management API in-process.
+func TokenVerifier(ua useradm.App) authz.TokenVerifier {
+	return func(ctx context.Context, token *jwt.Token) error {
+		_, err := ua.Verify(ctx, token)
+		switch errors.Cause(err) {
+		case nil:
+			return nil
+		case useradm.ErrUnauthorized, jwt.ErrTokenInvalid, jwt.ErrTokenExpired:
+			return authz.ErrAuthzTokenInvalid
+		default:
+			return err
+		}
+	}
+}
+
+// IntrospectTokenHandler implements OAuth 2.0 token introspection
+// (RFC 7662); the calling client authenticates with HTTP Basic auth.
+func (u *UserAdmApiHandlers) IntrospectTokenHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	if !u.authenticateIntrospectionClient(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="useradm"`)
+		rest_utils.RestErrWithLog(w, r, l,
+			ErrIntrospectionClientUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	tokenStr := r.PostForm.Get("token")
+	if tokenStr == "" {
+		rest_utils.RestErrWithLog(w, r, l, ErrIntrospectionNoToken, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	// malformed, expired or foreign tokens are simply inactive
+	token, err := u.jwth.FromJWT(ctx, tokenStr)
+	if err != nil {
+		_ = w.WriteJson(&model.TokenIntrospection{Active: false})
+		return
+	}
+
+	introspection, err := u.userAdm.IntrospectToken(ctx, token)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	_ = w.WriteJson(introspection)
+}
+
+func (u *UserAdmApiHandlers) authenticateIntrospectionClient(r *rest.Request) bool {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	secret, ok := u.config.IntrospectionClients[clientID]
+	if !ok || secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) == 1
+}
+
 func (u *UserAdmApiHandlers) CreateTenantUserHandler(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 
@@ -260,8 +496,12 @@ func (u *UserAdmApiHandlers) CreateTenantUserHandler(w rest.ResponseWriter, r *r
 	ctx = getTenantContext(ctx, tenantId)
 	err = u.userAdm.CreateUserInternal(ctx, user)
 	if err != nil {
-		if err == store.ErrDuplicateEmail {
+		if err == store.ErrDuplicateEmail ||
+			errors.Cause(err) == useradm.ErrDuplicateUserAttribute {
 			rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnprocessableEntity)
+		} else if err == useradm.ErrUnknownRole ||
+			errors.Cause(err) == model.ErrInvalidUserAttribute {
+			rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		} else {
 			rest_utils.RestErrWithLogInternal(w, r, l, err)
 		}
@@ -289,8 +529,14 @@ func (u *UserAdmApiHandlers) AddUserHandler(w rest.ResponseWriter, r *rest.Reque
 
 	err = u.userAdm.CreateUser(ctx, user)
 	if err != nil {
-		if err == store.ErrDuplicateEmail {
+		if err == store.ErrDuplicateEmail ||
+			errors.Cause(err) == useradm.ErrDuplicateUserAttribute {
 			rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnprocessableEntity)
+		} else if err == useradm.ErrRoleNotPermitted {
+			rest_utils.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+		} else if err == useradm.ErrUnknownRole ||
+			errors.Cause(err) == model.ErrInvalidUserAttribute {
+			rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		} else {
 			rest_utils.RestErrWithLogInternal(w, r, l, err)
 		}
@@ -319,16 +565,144 @@ func (u *UserAdmApiHandlers) GetUsersHandler(w rest.ResponseWriter, r *rest.Requ
 		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
+	page, err := parseUsersPage(r, &fltr)
+	if err != nil {
+		err = errors.Wrap(err, "api: invalid form values")
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
 
 	users, err := u.userAdm.GetUsers(ctx, fltr)
-	if err != nil {
+	if errors.Cause(err) == model.ErrInvalidUserAttribute {
+		err = errors.Wrap(err, "api: invalid form values")
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	} else if err != nil {
 		rest_utils.RestErrWithLogInternal(w, r, l, err)
 		return
 	}
+	if page == nil {
+		w.Header().Set(hdrTotalCount, strconv.Itoa(len(users)))
+		_ = w.WriteJson(users)
+		return
+	}
 
+	total, err := u.userAdm.CountUsers(ctx, fltr)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.Header().Set(hdrTotalCount, strconv.FormatInt(total, 10))
+	var links []string
+	if page.cursor {
+		// one more user than the page was fetched to tell if there
+		// is a next page
+		if uint64(len(users)) > page.perPage {
+			users = users[:page.perPage]
+			next := model.NewUserCursor(*fltr.Sort, &users[len(users)-1])
+			links = append(links,
+				makeCursorLink(r, rest_utils.LinkNext, next.String(), page.perPage))
+		}
+		links = append(links,
+			makeCursorLink(r, rest_utils.LinkFirst, "", page.perPage))
+	} else {
+		hasNext := (page.page-1)*page.perPage+uint64(len(users)) < uint64(total)
+		links = rest_utils.MakePageLinkHdrs(r, page.page, page.perPage, hasNext)
+		last := (uint64(total) + page.perPage - 1) / page.perPage
+		if last < 1 {
+			last = 1
+		}
+		links = append(links, rest_utils.MakeLink(linkLast, r, last, page.perPage))
+	}
+	for _, link := range links {
+		w.Header().Add(rest_utils.LinkHdr, link)
+	}
 	_ = w.WriteJson(users)
 }
 
+// usersPage is the pagination of the users listing
+type usersPage struct {
+	page    uint64
+	perPage uint64
+	// cursor is set for the cursor-based pagination
+	cursor bool
+}
+
+// parseUsersPage parses the sort and the pagination parameters into the
+// filter; the users are not paginated (nil page) if none of page,
+// per_page and cursor is set. The pagination is cursor-based unless the
+// page is set.
+func parseUsersPage(r *rest.Request, fltr *model.UserFilter) (*usersPage, error) {
+	q := r.URL.Query()
+	if s := q.Get(paramSort); s != "" {
+		sort, err := model.ParseUserSort(s)
+		if err != nil {
+			return nil, err
+		}
+		fltr.Sort = sort
+	}
+
+	_, hasPage := q[rest_utils.PageName]
+	_, hasPerPage := q[rest_utils.PerPageName]
+	cursor := q.Get(paramCursor)
+	if !hasPage && !hasPerPage && cursor == "" {
+		return nil, nil
+	}
+	pageNo, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		return nil, err
+	}
+	page := &usersPage{
+		page:    pageNo,
+		perPage: perPage,
+		cursor:  !hasPage,
+	}
+
+	if cursor != "" {
+		if hasPage {
+			return nil, errPageWithCursor
+		}
+		after, err := model.ParseUserCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if fltr.Sort != nil && *fltr.Sort != after.Sort {
+			return nil, errCursorSort
+		}
+		fltr.After = after
+		fltr.Sort = &after.Sort
+	}
+	if fltr.Sort == nil {
+		fltr.Sort = &model.UserSort{Field: model.UserSortEmail}
+	}
+
+	if page.cursor {
+		fltr.Limit = int64(perPage) + 1
+	} else {
+		fltr.Skip = int64((pageNo - 1) * perPage)
+		fltr.Limit = int64(perPage)
+	}
+	return page, nil
+}
+
+// makeCursorLink returns the Link header value of the page after the
+// cursor, or of the first page if the cursor is empty
+func makeCursorLink(r *rest.Request, rel, cursor string, perPage uint64) string {
+	q := r.URL.Query()
+	q.Set(rest_utils.PerPageName, strconv.FormatUint(perPage, 10))
+	if cursor != "" {
+		q.Set(paramCursor, cursor)
+	} else {
+		q.Del(paramCursor)
+	}
+	link := url.URL{
+		Path:     r.URL.Path,
+		RawPath:  r.URL.RawPath,
+		RawQuery: q.Encode(),
+	}
+	return fmt.Sprintf(rest_utils.LinkTmpl, link.String(), rel)
+}
+
 func (u *UserAdmApiHandlers) GetTenantUsersHandler(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	ctx = identity.WithContext(ctx, &identity.Identity{
@@ -383,7 +757,7 @@ func (u *UserAdmApiHandlers) UpdateUserHandler(w rest.ResponseWriter, r *rest.Re
 
 	// extract the token used to update the user
 	if tokenStr, err := authz.ExtractToken(r.Request); err == nil {
-		token, err := u.jwth.FromJWT(tokenStr)
+		token, err := u.jwth.FromJWT(ctx, tokenStr)
 		if err != nil {
 			rest_utils.RestErrWithLogInternal(w, r, l, err)
 			return
@@ -394,9 +768,12 @@ func (u *UserAdmApiHandlers) UpdateUserHandler(w rest.ResponseWriter, r *rest.Re
 	id := getUserIdFromPath(r)
 	err = u.userAdm.UpdateUser(ctx, id, userUpdate)
 	if err != nil {
-		switch err {
-		case store.ErrDuplicateEmail, store.ErrCurrentPasswordMismatch:
+		switch errors.Cause(err) {
+		case store.ErrDuplicateEmail, store.ErrCurrentPasswordMismatch,
+			useradm.ErrDuplicateUserAttribute:
 			rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnprocessableEntity)
+		case model.ErrInvalidUserAttribute:
+			rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		case store.ErrUserNotFound:
 			rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
 		default:
@@ -416,7 +793,7 @@ func (u *UserAdmApiHandlers) DeleteTenantUserHandler(w rest.ResponseWriter, r *r
 	}
 
 	l := log.FromContext(ctx)
-	err := u.userAdm.DeleteUser(ctx, r.PathParam("userid"))
+	err := u.userAdm.PurgeUser(ctx, r.PathParam("userid"))
 	if err != nil {
 		rest_utils.RestErrWithLogInternal(w, r, l, err)
 		return
@@ -430,6 +807,13 @@ func (u *UserAdmApiHandlers) DeleteUserHandler(w rest.ResponseWriter, r *rest.Re
 
 	l := log.FromContext(ctx)
 
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action: model.ApprovalActionDeleteUser,
+		UserID: r.PathParam("id"),
+	}) {
+		return
+	}
+
 	err := u.userAdm.DeleteUser(ctx, r.PathParam("id"))
 	if err != nil {
 		rest_utils.RestErrWithLogInternal(w, r, l, err)
@@ -439,6 +823,50 @@ func (u *UserAdmApiHandlers) DeleteUserHandler(w rest.ResponseWriter, r *rest.Re
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (u *UserAdmApiHandlers) SetUserStatusHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var status model.UserStatusUpdate
+	if err := r.DecodeJsonPayload(&status); err != nil {
+		rest_utils.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := status.Validate(); err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	err := u.userAdm.SetUserStatus(ctx, r.PathParam("id"), &status)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case store.ErrUserNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (u *UserAdmApiHandlers) RestoreUserHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := u.userAdm.RestoreUser(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case store.ErrUserNotFound:
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
 func parseUser(r *rest.Request) (*model.User, error) {
 	user := model.User{}
 
@@ -558,6 +986,36 @@ func (u *UserAdmApiHandlers) DeleteTokensHandler(w rest.ResponseWriter, r *rest.
 	}
 }
 
+// RevokeTokensHandler revokes the tokens of the user, or of the whole
+// tenant if no user is given; unlike the internal endpoint, the tenant may
+// require an approval for it.
+func (u *UserAdmApiHandlers) RevokeTokensHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var tenantId string
+	if id := identity.FromContext(ctx); id != nil {
+		tenantId = id.Tenant
+	}
+	userId := r.URL.Query().Get("user_id")
+
+	if u.requestApproval(w, r, &model.ApprovalRequest{
+		Action: model.ApprovalActionDeleteTokens,
+		UserID: userId,
+	}) {
+		return
+	}
+
+	err := u.userAdm.DeleteTokens(ctx, tenantId, userId)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
 func (u *UserAdmApiHandlers) SaveSettingsHandler(w rest.ResponseWriter, r *rest.Request) {
 	u.saveSettingsHandler(w, r, false)
 }
@@ -600,6 +1058,13 @@ func (u *UserAdmApiHandlers) saveSettingsHandler(w rest.ResponseWriter, r *rest.
 		}
 		err = u.db.SaveUserSettings(ctx, id.Subject, settings, ifMatchHeader)
 	} else {
+		if u.requestApproval(w, r, &model.ApprovalRequest{
+			Action:       model.ApprovalActionSaveSettings,
+			Settings:     settings,
+			SettingsETag: ifMatchHeader,
+		}) {
+			return
+		}
 		err = u.db.SaveSettings(ctx, settings, ifMatchHeader)
 	}
 	if err == store.ErrETagMismatch {
@@ -682,6 +1147,12 @@ func (u *UserAdmApiHandlers) IssueTokenHandler(w rest.ResponseWriter, r *rest.Re
 		return
 	}
 
+	ctx, err := u.withTokenScope(r)
+	if err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
+		return
+	}
+
 	token, err := u.userAdm.IssuePersonalAccessToken(ctx, &tokenRequest)
 	switch err {
 	case nil:
@@ -704,11 +1175,36 @@ func (u *UserAdmApiHandlers) IssueTokenHandler(w rest.ResponseWriter, r *rest.Re
 			err,
 			http.StatusConflict,
 		)
+	case useradm.ErrScopeNotPermitted:
+		rest_utils.RestErrWithLog(
+			w,
+			r,
+			l,
+			err,
+			http.StatusForbidden,
+		)
 	default:
 		rest_utils.RestErrWithLogInternal(w, r, l, err)
 	}
 }
 
+// withTokenScope stores the scopes of the token the request was made with
+// in the request context, so that the tokens issued on its behalf do not
+// exceed them.
+func (u *UserAdmApiHandlers) withTokenScope(r *rest.Request) (context.Context, error) {
+	ctx := r.Context()
+	tokenStr, err := authz.ExtractToken(r.Request)
+	if err != nil {
+		// the request has been authenticated by the API gateway
+		return ctx, nil
+	}
+	token, err := u.jwth.FromJWT(ctx, tokenStr)
+	if err != nil {
+		return nil, authz.ErrAuthzTokenInvalid
+	}
+	return scope.WithContext(ctx, scope.Parse(token.Scope)), nil
+}
+
 func (u *UserAdmApiHandlers) GetTokensHandler(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := log.FromContext(ctx)
@@ -42,6 +42,7 @@ import (
 	"github.com/mendersoftware/useradm/jwt"
 	"github.com/mendersoftware/useradm/keys"
 	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
 	"github.com/mendersoftware/useradm/store"
 	mstore "github.com/mendersoftware/useradm/store/mocks"
 	useradm "github.com/mendersoftware/useradm/user"
@@ -169,6 +170,16 @@ func TestUserAdmApiLogin(t *testing.T) {
 				nil,
 				restError("unauthorized")),
 		},
+		"error: password login disabled": {
+			//"email:pass"
+			inAuthHeader: "Basic ZW1haWw6cGFzcw==",
+			uaError:      useradm.ErrPasswordLoginDisabled,
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError(useradm.ErrPasswordLoginDisabled.Error())),
+		},
 		"error: corrupt auth header": {
 			inAuthHeader: "ZW1haWw6cGFzcw==",
 			checker: mt.NewJSONResponse(
@@ -214,6 +225,16 @@ func TestUserAdmApiLogin(t *testing.T) {
 				nil,
 				restError(useradm.ErrTenantAccountSuspended.Error())),
 		},
+		"error: user account disabled": {
+			inAuthHeader: "Basic ZW1haWw6cGFzcw==",
+			signed:       "initial",
+			uaError:      useradm.ErrUserDisabled,
+
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError(useradm.ErrUserDisabled.Error())),
+		},
 	}
 
 	for name, tc := range testCases {
@@ -359,6 +380,23 @@ func TestCreateUser(t *testing.T) {
 				restError(store.ErrDuplicateEmail.Error()),
 			),
 		},
+		"role not permitted": {
+			inReq: test.MakeSimpleRequest("POST",
+				"http://1.2.3.4/api/management/v1/useradm/users",
+				map[string]interface{}{
+					"email":    "foo@foo.com",
+					"password": "foobarbar",
+					"roles":    []string{model.RoleAdmin},
+				},
+			),
+			createUserErr: useradm.ErrRoleNotPermitted,
+
+			checker: mt.NewJSONResponse(
+				http.StatusForbidden,
+				nil,
+				restError(useradm.ErrRoleNotPermitted.Error()),
+			),
+		},
 		"ok, email with ('+')": {
 			inReq: test.MakeSimpleRequest("POST",
 				"http://1.2.3.4/api/management/v1/useradm/users",
@@ -759,6 +797,15 @@ func TestUpdateUser(t *testing.T) {
 }
 
 func makeMockApiHandler(t *testing.T, uadm useradm.App, db store.DataStore) http.Handler {
+	return makeMockApiHandlerWithConfig(t, uadm, db, Config{})
+}
+
+func makeMockApiHandlerWithConfig(
+	t *testing.T,
+	uadm useradm.App,
+	db store.DataStore,
+	config Config,
+) http.Handler {
 	// JWT handler
 	privkey, err := keys.LoadRSAPrivate("../../crypto/private.pem")
 	if !assert.NoError(t, err) {
@@ -767,7 +814,7 @@ func makeMockApiHandler(t *testing.T, uadm useradm.App, db store.DataStore) http
 	jwth := jwt.NewJWTHandlerRS256(privkey, nil)
 
 	// API handler
-	handlers := NewUserAdmApiHandlers(uadm, db, jwth, Config{})
+	handlers := NewUserAdmApiHandlers(uadm, db, jwth, config)
 	assert.NotNil(t, handlers)
 
 	app, err := handlers.GetApp()
@@ -829,15 +876,29 @@ func TestUserAdmApiPostVerify(t *testing.T) {
 		"4hEu7REd3s-2TpoIl6ztbbFDYUwz6lg1jD_q0Sbx89gw1R-auZPPZOH49szk" +
 		"8bb75uaEce4BQfgIwvVyVN0NXhfN7bq6ucObZdUbNhuXmN1R6MQ"
 
+	identity := &model.TokenIdentity{
+		UserID:    "781ec2c3-36a6-4c14-9215-75cfcfd83136",
+		Tenant:    "tenant1",
+		Email:     "foo@acme.com",
+		Roles:     []string{model.RoleReadOnly, "devs"},
+		TokenType: model.TokenTypeSession,
+		TokenID:   "9734f752-09fd-466b-bcca-8e1f4407bf65",
+	}
+
 	testCases := map[string]struct {
+		identityHeaders []string
+
 		uaVerifyError error
 
-		uaError error
+		uaIdentity *model.TokenIdentity
+		uaError    error
 
+		headers map[string]string
 		checker mt.ResponseChecker
 	}{
 		"ok": {
 			uaVerifyError: nil,
+			uaIdentity:    identity,
 			uaError:       nil,
 
 			checker: mt.NewJSONResponse(
@@ -846,7 +907,58 @@ func TestUserAdmApiPostVerify(t *testing.T) {
 				nil,
 			),
 		},
+		"ok, identity headers": {
+			identityHeaders: []string{
+				IdentityUserID,
+				IdentityTenantID,
+				IdentityRoles,
+				IdentityTokenType,
+			},
+			uaIdentity: identity,
+
+			headers: map[string]string{
+				"X-MEN-User-ID":    "781ec2c3-36a6-4c14-9215-75cfcfd83136",
+				"X-MEN-Tenant-ID":  "tenant1",
+				"X-MEN-User-Roles": model.RoleReadOnly + ",devs",
+				"X-MEN-Token-Type": model.TokenTypeSession,
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				nil,
+			),
+		},
+		"ok, all identity headers": {
+			identityHeaders: []string{
+				IdentityUserID,
+				IdentityTenantID,
+				IdentityEmail,
+				IdentityRoles,
+				IdentityTokenType,
+				IdentityTokenID,
+			},
+			uaIdentity: &model.TokenIdentity{
+				UserID:    "781ec2c3-36a6-4c14-9215-75cfcfd83136",
+				Email:     "foo@acme.com",
+				TokenType: model.TokenTypePersonalAccessToken,
+				TokenID:   "9734f752-09fd-466b-bcca-8e1f4407bf65",
+			},
+
+			headers: map[string]string{
+				"X-MEN-User-ID":    "781ec2c3-36a6-4c14-9215-75cfcfd83136",
+				"X-MEN-User-Email": "foo@acme.com",
+				"X-MEN-Token-Type": model.TokenTypePersonalAccessToken,
+				"X-MEN-Token-ID":   "9734f752-09fd-466b-bcca-8e1f4407bf65",
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				nil,
+			),
+		},
 		"error: useradm unauthorized": {
+			identityHeaders: []string{IdentityUserID, IdentityTenantID},
+
 			uaVerifyError: nil,
 			uaError:       useradm.ErrUnauthorized,
 
@@ -876,10 +988,11 @@ func TestUserAdmApiPostVerify(t *testing.T) {
 			uadm := &museradm.App{}
 			uadm.On("Verify", ctx,
 				mock.AnythingOfType("*jwt.Token")).
-				Return(tc.uaError)
+				Return(tc.uaIdentity, tc.uaError)
 
 			//make handler
-			api := makeMockApiHandler(t, uadm, nil)
+			api := makeMockApiHandlerWithConfig(t, uadm, nil,
+				Config{IdentityHeaders: tc.identityHeaders})
 
 			//make request
 			req := makeReq("POST",
@@ -894,6 +1007,10 @@ func TestUserAdmApiPostVerify(t *testing.T) {
 			//test
 			recorded := test.RunRequest(t, api, req)
 			mt.CheckResponse(t, tc.checker, recorded)
+			for _, hdr := range IdentityHeaders {
+				assert.Equal(t, tc.headers[hdr],
+					recorded.Recorder.Header().Get(hdr), hdr)
+			}
 
 			//make request
 			req = makeReq("GET",
@@ -926,6 +1043,151 @@ func TestUserAdmApiPostVerify(t *testing.T) {
 	}
 }
 
+func TestValidateIdentityHeaders(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidateIdentityHeaders(nil))
+	assert.NoError(t, ValidateIdentityHeaders([]string{
+		IdentityUserID, IdentityTenantID, IdentityEmail,
+		IdentityRoles, IdentityTokenType, IdentityTokenID,
+	}))
+	assert.EqualError(t,
+		ValidateIdentityHeaders([]string{IdentityUserID, "password"}),
+		"password: unknown identity header")
+}
+
+func TestUserAdmApiIntrospect(t *testing.T) {
+	t.Parallel()
+
+	token := "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.eyJleHAiOjQ0ODE4OTM5MD" +
+		"AsImlzcyI6Im1lbmRlciIsInN1YiI6Ijc4MWVjMmMzLTM2YTYtNGMxNC05Mj" +
+		"E1LTc1Y2ZjZmQ4MzEzNiIsInNjcCI6Im1lbmRlci4qIiwiaWF0IjoxNDQ1Mj" +
+		"EyODAwLCJqdGkiOiI5NzM0Zjc1Mi0wOWZkLTQ2NmItYmNjYS04ZTFmNDQwN2" +
+		"JmNjUifQ.HRff3mxlygPl4ZlCA0uEalcEUrSb_xi_dnp6uDZWwAGVp-AL7NW" +
+		"MhVfRw9mVNXeM2nUom7z0JUgIDGxB-24gejssiZSuZPCDJ01oyutm2xqdQKW" +
+		"2LlHR5zD0m8KbNHtbHO9dPGUJATa7lHi3_QxGAqqXQYf-Jg7LwXRNqHT1EvY" +
+		"gZMffuqx5i5pwpoCm9a7bTlfKxYkwuMVps3zjuliJxgqbMP3zFN9IlNB0Atb" +
+		"4hEu7REd3s-2TpoIl6ztbbFDYUwz6lg1jD_q0Sbx89gw1R-auZPPZOH49szk" +
+		"8bb75uaEce4BQfgIwvVyVN0NXhfN7bq6ucObZdUbNhuXmN1R6MQ"
+
+	active := &model.TokenIntrospection{
+		Active:    true,
+		ID:        "9734f752-09fd-466b-bcca-8e1f4407bf65",
+		Subject:   "781ec2c3-36a6-4c14-9215-75cfcfd83136",
+		Scope:     "mender.*",
+		ExpiresAt: 4481893900,
+		IssuedAt:  1445212800,
+		Issuer:    "mender",
+		TokenType: model.TokenTypeSession,
+	}
+
+	testCases := map[string]struct {
+		clientID     string
+		clientSecret string
+		token        string
+
+		callsApp bool
+		uaRes    *model.TokenIntrospection
+		uaError  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok, active": {
+			clientID:     "gateway",
+			clientSecret: "secret",
+			token:        token,
+
+			callsApp: true,
+			uaRes:    active,
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil, active),
+		},
+		"ok, inactive": {
+			clientID:     "gateway",
+			clientSecret: "secret",
+			token:        token,
+
+			callsApp: true,
+			uaRes:    &model.TokenIntrospection{Active: false},
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil,
+				map[string]interface{}{"active": false}),
+		},
+		"ok, inactive: malformed token": {
+			clientID:     "gateway",
+			clientSecret: "secret",
+			token:        "not-a-token",
+
+			checker: mt.NewJSONResponse(http.StatusOK, nil,
+				map[string]interface{}{"active": false}),
+		},
+		"error: missing token": {
+			clientID:     "gateway",
+			clientSecret: "secret",
+
+			checker: mt.NewJSONResponse(http.StatusBadRequest, nil,
+				restError("missing token parameter")),
+		},
+		"error: wrong client secret": {
+			clientID:     "gateway",
+			clientSecret: "wrong",
+			token:        token,
+
+			checker: mt.NewJSONResponse(http.StatusUnauthorized, nil,
+				restError("client authentication failed")),
+		},
+		"error: unknown client": {
+			clientID:     "other",
+			clientSecret: "secret",
+			token:        token,
+
+			checker: mt.NewJSONResponse(http.StatusUnauthorized, nil,
+				restError("client authentication failed")),
+		},
+		"error: useradm internal": {
+			clientID:     "gateway",
+			clientSecret: "secret",
+			token:        token,
+
+			callsApp: true,
+			uaError:  errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(http.StatusInternalServerError, nil,
+				restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callsApp {
+				uadm.On("IntrospectToken", mtesting.ContextMatcher(),
+					mock.AnythingOfType("*jwt.Token")).
+					Return(tc.uaRes, tc.uaError)
+			}
+
+			api := makeMockApiHandlerWithConfig(t, uadm, nil, Config{
+				IntrospectionClients: map[string]string{"gateway": "secret"},
+			})
+
+			form := url.Values{}
+			if tc.token != "" {
+				form.Set("token", tc.token)
+			}
+			req, _ := http.NewRequest(http.MethodPost,
+				"http://1.2.3.4/api/internal/v1/useradm/auth/introspect",
+				strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			req.SetBasicAuth(tc.clientID, tc.clientSecret)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
 func TestUserAdmApiGetUsers(t *testing.T) {
 	t.Parallel()
 
@@ -969,7 +1231,7 @@ func TestUserAdmApiGetUsers(t *testing.T) {
 
 			checker: mt.NewJSONResponse(
 				http.StatusOK,
-				nil,
+				map[string]string{hdrTotalCount: "2"},
 				[]model.User{
 					{
 						ID:    "1",
@@ -984,6 +1246,47 @@ func TestUserAdmApiGetUsers(t *testing.T) {
 				},
 			),
 		},
+		"ok, group": {
+			queryString: "group=1",
+			uaUsers: []model.User{
+				{
+					ID:    "1",
+					Email: "foo@acme.com",
+				},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.User{
+					{
+						ID:    "1",
+						Email: "foo@acme.com",
+					},
+				},
+			),
+		},
+		"ok, search": {
+			queryString: "email_prefix=Foo&email_contains=acme" +
+				"&login_after=1234567890&never_logged_in=false",
+			uaUsers: []model.User{
+				{
+					ID:    "1",
+					Email: "foo@acme.com",
+				},
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]model.User{
+					{
+						ID:    "1",
+						Email: "foo@acme.com",
+					},
+				},
+			),
+		},
 		"ok: empty": {
 			uaUsers: []model.User{},
 			uaError: nil,
@@ -1067,6 +1370,188 @@ func TestUserAdmApiGetUsers(t *testing.T) {
 	}
 }
 
+func TestUserAdmApiGetUsersPagination(t *testing.T) {
+	t.Parallel()
+
+	users := []model.User{
+		{ID: "1", Email: "a@acme.com"},
+		{ID: "2", Email: "b@acme.com"},
+		{ID: "3", Email: "c@acme.com"},
+	}
+	emailSort := &model.UserSort{Field: model.UserSortEmail}
+	createdSort := &model.UserSort{Field: model.UserSortCreatedTs, Desc: true}
+	cursor := model.NewUserCursor(*emailSort, &users[1])
+
+	testCases := map[string]struct {
+		uri string
+
+		fltr    *model.UserFilter
+		uaUsers []model.User
+		uaTotal int64
+
+		status int
+		users  []model.User
+		total  string
+		links  []string
+		err    string
+	}{
+		"ok, page": {
+			uri: uriManagementUsers + "?page=2&per_page=2&email=a@acme.com",
+			fltr: &model.UserFilter{
+				Email: []model.Email{"a@acme.com"},
+				Sort:  emailSort,
+				Skip:  2,
+				Limit: 2,
+			},
+			uaUsers: users[2:],
+			uaTotal: 5,
+
+			status: http.StatusOK,
+			users:  users[2:],
+			total:  "5",
+			links: []string{
+				`<` + uriManagementUsers + `?email=a%40acme.com&page=1&per_page=2>; rel="prev"`,
+				`<` + uriManagementUsers + `?email=a%40acme.com&page=3&per_page=2>; rel="next"`,
+				`<` + uriManagementUsers + `?email=a%40acme.com&page=1&per_page=2>; rel="first"`,
+				`<` + uriManagementUsers + `?email=a%40acme.com&page=3&per_page=2>; rel="last"`,
+			},
+		},
+		"ok, last page": {
+			uri: uriManagementUsers + "?page=1&sort=created_ts:desc",
+			fltr: &model.UserFilter{
+				Sort:  createdSort,
+				Limit: 20,
+			},
+			uaUsers: users,
+			uaTotal: 3,
+
+			status: http.StatusOK,
+			users:  users,
+			total:  "3",
+			links: []string{
+				`<` + uriManagementUsers + `?page=1&per_page=20&sort=created_ts%3Adesc>; rel="first"`,
+				`<` + uriManagementUsers + `?page=1&per_page=20&sort=created_ts%3Adesc>; rel="last"`,
+			},
+		},
+		"ok, first cursor page": {
+			uri: uriManagementUsers + "?per_page=2",
+			fltr: &model.UserFilter{
+				Sort:  emailSort,
+				Limit: 3,
+			},
+			uaUsers: users,
+			uaTotal: 3,
+
+			status: http.StatusOK,
+			users:  users[:2],
+			total:  "3",
+			links: []string{
+				`<` + uriManagementUsers + `?cursor=` + cursor.String() +
+					`&per_page=2>; rel="next"`,
+				`<` + uriManagementUsers + `?per_page=2>; rel="first"`,
+			},
+		},
+		"ok, last cursor page": {
+			uri: uriManagementUsers + "?per_page=2&cursor=" + cursor.String(),
+			fltr: &model.UserFilter{
+				Sort:  emailSort,
+				After: cursor,
+				Limit: 3,
+			},
+			uaUsers: users[2:],
+			uaTotal: 3,
+
+			status: http.StatusOK,
+			users:  users[2:],
+			total:  "3",
+			links: []string{
+				`<` + uriManagementUsers + `?per_page=2>; rel="first"`,
+			},
+		},
+		"ok, tenant users": {
+			uri: "/api/internal/v1/useradm/tenants/t1/users?page=1&per_page=10",
+			fltr: &model.UserFilter{
+				Sort:  emailSort,
+				Limit: 10,
+			},
+			uaUsers: users,
+			uaTotal: 3,
+
+			status: http.StatusOK,
+			users:  users,
+			total:  "3",
+			links: []string{
+				`</api/internal/v1/useradm/tenants/t1/users?page=1&per_page=10>; rel="first"`,
+				`</api/internal/v1/useradm/tenants/t1/users?page=1&per_page=10>; rel="last"`,
+			},
+		},
+		"error, per_page": {
+			uri: uriManagementUsers + "?per_page=501",
+
+			status: http.StatusBadRequest,
+			err:    "api: invalid form values: Param per_page is out of bounds",
+		},
+		"error, sort": {
+			uri: uriManagementUsers + "?sort=password",
+
+			status: http.StatusBadRequest,
+			err:    "api: invalid form values: " + model.ErrInvalidUserSort.Error(),
+		},
+		"error, cursor": {
+			uri: uriManagementUsers + "?cursor=foo",
+
+			status: http.StatusBadRequest,
+			err:    "api: invalid form values: " + model.ErrInvalidUserCursor.Error(),
+		},
+		"error, page and cursor": {
+			uri: uriManagementUsers + "?page=2&cursor=" + cursor.String(),
+
+			status: http.StatusBadRequest,
+			err:    "api: invalid form values: " + errPageWithCursor.Error(),
+		},
+		"error, cursor sort": {
+			uri: uriManagementUsers + "?sort=login_ts&cursor=" + cursor.String(),
+
+			status: http.StatusBadRequest,
+			err:    "api: invalid form values: " + errCursorSort.Error(),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := mtesting.ContextMatcher()
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.fltr != nil {
+				uadm.On("GetUsers", ctx, *tc.fltr).
+					Return(tc.uaUsers, nil)
+				uadm.On("CountUsers", ctx, *tc.fltr).
+					Return(tc.uaTotal, nil)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+			req := makeReq(http.MethodGet, "http://1.2.3.4"+tc.uri, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			if tc.err != "" {
+				mt.CheckResponse(t, mt.NewJSONResponse(
+					tc.status, nil, restError(tc.err),
+				), recorded)
+				return
+			}
+			mt.CheckResponse(t, mt.NewJSONResponse(
+				tc.status,
+				map[string]string{hdrTotalCount: tc.total},
+				tc.users,
+			), recorded)
+			assert.Equal(t, tc.links, recorded.Recorder.Header().Values("Link"))
+		})
+	}
+}
+
 func TestUserAdmApiTenantsGetUsers(t *testing.T) {
 	t.Parallel()
 
@@ -1333,7 +1818,7 @@ func TestUserAdmApiDeleteTenantUser(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			//make mock useradm
 			uadm := &museradm.App{}
-			uadm.On("DeleteUser",
+			uadm.On("PurgeUser",
 				mock.MatchedBy(func(ctx context.Context) bool {
 					if tc.tenantID == "" {
 						return true
@@ -1379,11 +1864,43 @@ func TestUserAdmApiDeleteUser(t *testing.T) {
 		"j3zWev8zKVH0Sef0lB6SAapVs1GS3rK3-oy6wk" +
 		"ACNbKY1tB7Ox6CKiJ9F8Hhvh_icOtfvjCuiY-HkJL55T4wziFQNv2xU_2W7Lw"
 
+	approval := &model.Approval{
+		ID: "a1",
+		ApprovalRequest: model.ApprovalRequest{
+			Action: model.ApprovalActionDeleteUser,
+			UserID: "foo",
+		},
+		Status: model.ApprovalStatusPending,
+	}
+
 	testCases := map[string]struct {
+		approval    *model.Approval
+		approvalErr error
+
 		uaError error
 
 		checker mt.ResponseChecker
 	}{
+		"ok, approval required": {
+			approval: approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				approval,
+			),
+		},
+		"error: request approval": {
+			approvalErr: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
 		"ok": {
 			uaError: nil,
 
@@ -1410,7 +1927,13 @@ func TestUserAdmApiDeleteUser(t *testing.T) {
 
 			//make mock useradm
 			uadm := &museradm.App{}
-			uadm.On("DeleteUser", ctx, "foo").Return(tc.uaError)
+			uadm.On("RequestApproval", ctx, &model.ApprovalRequest{
+				Action: model.ApprovalActionDeleteUser,
+				UserID: "foo",
+			}).Return(tc.approval, tc.approvalErr)
+			if tc.approval == nil && tc.approvalErr == nil {
+				uadm.On("DeleteUser", ctx, "foo").Return(tc.uaError)
+			}
 
 			//make handler
 			api := makeMockApiHandler(t, uadm, nil)
@@ -1524,10 +2047,44 @@ func TestUserAdmApiSaveSettings(t *testing.T) {
 		body     interface{}
 		settings *model.Settings
 
+		approval    *model.Approval
+		approvalErr error
+
 		dbError error
 
 		checker mt.ResponseChecker
 	}{
+		"ok, approval required": {
+			etag: "etag",
+			body: map[string]interface{}{
+				"foo": "foo-val",
+			},
+			approval: &model.Approval{
+				ID:     "a1",
+				Status: model.ApprovalStatusPending,
+			},
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				&model.Approval{
+					ID:     "a1",
+					Status: model.ApprovalStatusPending,
+				},
+			),
+		},
+		"error, request approval": {
+			body:        map[string]interface{}{},
+			approvalErr: errors.New("generic"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
 		"ok": {
 			body: map[string]interface{}{
 				"foo": "foo-val",
@@ -1615,6 +2172,15 @@ func TestUserAdmApiSaveSettings(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			ctx := mtesting.ContextMatcher()
 
+			//make mock useradm
+			uadm := &museradm.App{}
+			uadm.On("RequestApproval", ctx,
+				mock.MatchedBy(func(r *model.ApprovalRequest) bool {
+					return r.Action == model.ApprovalActionSaveSettings &&
+						r.Settings != nil && r.SettingsETag == tc.etag
+				})).
+				Return(tc.approval, tc.approvalErr)
+
 			//make mock store
 			db := &mstore.DataStore{}
 			if tc.settings != nil {
@@ -1627,7 +2193,7 @@ func TestUserAdmApiSaveSettings(t *testing.T) {
 			}
 
 			//make handler
-			api := makeMockApiHandler(t, nil, db)
+			api := makeMockApiHandler(t, uadm, db)
 
 			//make request
 			req := makeReq(http.MethodPost,
@@ -1635,7 +2201,7 @@ func TestUserAdmApiSaveSettings(t *testing.T) {
 				"",
 				tc.body)
 			if tc.etag != "" {
-				req.Header.Add(hdrETag, tc.etag)
+				req.Header.Add(hdrIfMatch, tc.etag)
 			}
 
 			//test
@@ -1805,9 +2371,14 @@ func TestUserAdmApiDeleteTokens(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			ctx := mtesting.ContextMatcher()
 
-			//make mock useradm
+			//make mock useradm; the internal endpoint is never held
+			//for approval
 			uadm := &museradm.App{}
-			uadm.On("DeleteTokens", ctx, mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(tc.uaError)
+			defer uadm.AssertExpectations(t)
+			if tc.params != "" {
+				uadm.On("DeleteTokens", ctx, "foo",
+					mock.AnythingOfType("string")).Return(tc.uaError)
+			}
 
 			//make handler
 			api := makeMockApiHandler(t, uadm, nil)
@@ -1825,30 +2396,179 @@ func TestUserAdmApiDeleteTokens(t *testing.T) {
 	}
 }
 
-func TestIssueToken(t *testing.T) {
+func TestUserAdmApiRevokeTokens(t *testing.T) {
 	t.Parallel()
 
+	approval := &model.Approval{
+		ID: "a1",
+		ApprovalRequest: model.ApprovalRequest{
+			Action: model.ApprovalActionDeleteTokens,
+			UserID: "bar",
+		},
+		Status: model.ApprovalStatusPending,
+	}
+
 	testCases := map[string]struct {
-		inReq *http.Request
+		approval    *model.Approval
+		approvalErr error
 
-		issueTokenErr error
+		uaError error
 
 		checker mt.ResponseChecker
 	}{
 		"ok": {
+			checker: mt.NewJSONResponse(
+				http.StatusNoContent,
+				nil,
+				nil,
+			),
+		},
+		"ok, approval required": {
+			approval: approval,
+
+			checker: mt.NewJSONResponse(
+				http.StatusAccepted,
+				map[string]string{
+					"Location": uriManagementApprovals + "/a1",
+				},
+				approval,
+			),
+		},
+		"error: request approval": {
+			approvalErr: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := mtesting.ContextMatcher()
+
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("RequestApproval", ctx, &model.ApprovalRequest{
+				Action: model.ApprovalActionDeleteTokens,
+				UserID: "bar",
+			}).Return(tc.approval, tc.approvalErr)
+			if tc.approval == nil && tc.approvalErr == nil {
+				uadm.On("DeleteTokens", ctx, "", "bar").Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("DELETE",
+				"http://1.2.3.4"+uriManagementAllTokens+"?user_id=bar",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestIssueToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		inReq        *http.Request
+		callerScopes []string
+
+		issueTokenErr error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			inReq: test.MakeSimpleRequest("POST",
+				"http://1.2.3.4/api/management/v1/useradm/settings/tokens",
+				map[string]interface{}{
+					"name":       "foo",
+					"expires_in": 3600,
+				},
+			),
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/jwt",
+				Body:        "foo",
+			},
+		},
+		"ok, scoped": {
 			inReq: test.MakeSimpleRequest("POST",
 				"http://1.2.3.4/api/management/v1/useradm/settings/tokens",
 				map[string]interface{}{
 					"name":       "foo",
 					"expires_in": 3600,
+					"scopes":     []string{"mender.devices.read"},
 				},
 			),
+			callerScopes: []string{scope.DevicesWrite, scope.UsersWrite},
 			checker: &mt.BaseResponse{
 				Status:      http.StatusOK,
 				ContentType: "application/jwt",
 				Body:        "foo",
 			},
 		},
+		"error: scope exceeds the owner's scope": {
+			inReq: test.MakeSimpleRequest("POST",
+				"http://1.2.3.4/api/management/v1/useradm/settings/tokens",
+				map[string]interface{}{
+					"name":       "foo",
+					"expires_in": 3600,
+					"scopes":     []string{"mender.*"},
+				},
+			),
+			callerScopes:  []string{scope.DevicesWrite},
+			issueTokenErr: useradm.ErrScopeNotPermitted,
+			checker: mt.NewJSONResponse(
+				http.StatusForbidden,
+				nil,
+				restError("requested scope exceeds the scope of the token owner")),
+		},
+		"error: unknown scope": {
+			inReq: test.MakeSimpleRequest("POST",
+				"http://1.2.3.4/api/management/v1/useradm/settings/tokens",
+				map[string]interface{}{
+					"name":       "foo",
+					"expires_in": 3600,
+					"scopes":     []string{"mender.devices.read", "foo"},
+				},
+			),
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("scopes: (1: unknown scope.).")),
+		},
+		"error: invalid token": {
+			inReq: func() *http.Request {
+				req := test.MakeSimpleRequest("POST",
+					"http://1.2.3.4/api/management/v1/useradm/settings/tokens",
+					map[string]interface{}{
+						"name":       "foo",
+						"expires_in": 3600,
+					},
+				)
+				req.Header.Set("Authorization", "Bearer foo.bar.baz")
+				return req
+			}(),
+			checker: mt.NewJSONResponse(
+				http.StatusUnauthorized,
+				nil,
+				restError("invalid jwt")),
+		},
 		"error: token with the same name already exist": {
 			inReq: test.MakeSimpleRequest("POST",
 				"http://1.2.3.4/api/management/v1/useradm/settings/tokens",
@@ -1909,12 +2629,21 @@ func TestIssueToken(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			//make mock useradm
 			uadm := &museradm.App{}
-			uadm.On("IssuePersonalAccessToken", mtesting.ContextMatcher(),
+			uadm.On("IssuePersonalAccessToken",
+				mock.MatchedBy(func(ctx context.Context) bool {
+					return assert.ObjectsAreEqual(tc.callerScopes,
+						scope.FromContext(ctx))
+				}),
 				mock.AnythingOfType("*model.TokenRequest")).
 				Return("foo", tc.issueTokenErr)
 
 			api := makeMockApiHandler(t, uadm, nil)
 
+			if tc.callerScopes != nil {
+				tc.inReq.Header.Set("Authorization",
+					"Bearer "+makeToken(t, scope.Join(tc.callerScopes)))
+			}
+
 			tc.inReq.Header.Add(requestid.RequestIdHeader, "test")
 			recorded := test.RunRequest(t, api, tc.inReq)
 
@@ -1923,6 +2652,31 @@ func TestIssueToken(t *testing.T) {
 	}
 }
 
+// makeToken returns a user token with the given scope, signed with the key
+// used by the mock API handler
+func makeToken(t *testing.T, tokenScope string) string {
+	privkey, err := keys.LoadRSAPrivate("../../crypto/private.pem")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	now := time.Now()
+	raw, err := jwt.NewJWTHandlerRS256(privkey, nil).ToJWT(context.Background(), &jwt.Token{
+		Claims: jwt.Claims{
+			ID:        oid.NewUUIDv4(),
+			Subject:   oid.NewUUIDv4(),
+			Issuer:    "mender",
+			IssuedAt:  jwt.Time{Time: now},
+			ExpiresAt: jwt.Time{Time: now.Add(time.Hour)},
+			Scope:     tokenScope,
+			User:      true,
+		},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return raw
+}
+
 func strPtr(s string) *string {
 	return &s
 }
@@ -2004,3 +2758,284 @@ func TestUserAdmApiGetTokens(t *testing.T) {
 		})
 	}
 }
+
+func TestUserAdmApiSetUserStatus(t *testing.T) {
+	t.Parallel()
+
+	status := &model.UserStatusUpdate{
+		Status: model.UserStatusDisabled,
+		Reason: "left the company",
+	}
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callSet bool
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			body:    status,
+			callSet: true,
+
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: bad request": {
+			body: "foo",
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("failed to decode request body: "+
+					"json: cannot unmarshal string into Go value of type "+
+					"model.UserStatusUpdate"),
+			),
+		},
+		"error: unknown status": {
+			body: map[string]interface{}{"status": "suspended"},
+
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				restError("status: must be a valid value."),
+			),
+		},
+		"error: not found": {
+			body:    status,
+			callSet: true,
+			uaError: store.ErrUserNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(store.ErrUserNotFound.Error()),
+			),
+		},
+		"error: useradm internal": {
+			body:    status,
+			callSet: true,
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			if tc.callSet {
+				uadm.On("SetUserStatus", mtesting.ContextMatcher(), "1", status).
+					Return(tc.uaError)
+			}
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("PUT",
+				"http://1.2.3.4"+apiUrlManagementV1+"/users/1/status",
+				"",
+				tc.body)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestUserAdmApiRestoreUser(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		uaError error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"error: not found": {
+			uaError: store.ErrUserNotFound,
+
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				restError(store.ErrUserNotFound.Error()),
+			),
+		},
+		"error: useradm internal": {
+			uaError: errors.New("some internal error"),
+
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error"),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("RestoreUser", mtesting.ContextMatcher(), "1").
+				Return(tc.uaError)
+
+			api := makeMockApiHandler(t, uadm, nil)
+
+			req := makeReq("POST",
+				"http://1.2.3.4"+apiUrlManagementV1+"/users/1/restore",
+				"",
+				nil)
+
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestUserAdmApiSelfServiceReadOnly(t *testing.T) {
+	t.Parallel()
+
+	privkey, err := keys.LoadRSAPrivate("../../crypto/private.pem")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	jwth := jwt.NewJWTHandlerRS256(privkey, nil)
+
+	subject := oid.NewUUIDv4()
+	now := time.Now()
+	token, err := jwth.ToJWT(context.Background(), &jwt.Token{
+		Claims: jwt.Claims{
+			ID:        oid.NewUUIDv4(),
+			Subject:   subject,
+			Issuer:    "mender",
+			IssuedAt:  jwt.Time{Time: now},
+			ExpiresAt: jwt.Time{Time: now.Add(time.Hour)},
+			Scope:     scope.All,
+			User:      true,
+		},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		inReq *http.Request
+
+		checker mt.ResponseChecker
+	}{
+		"ok, change own password": {
+			inReq: makeReq("PUT",
+				"http://1.2.3.4/api/management/v1/useradm/users/me",
+				"Bearer "+token,
+				map[string]interface{}{
+					"password":         "foobarbar",
+					"current_password": "barbazbaz",
+				},
+			),
+			checker: mt.NewJSONResponse(
+				http.StatusNoContent,
+				nil,
+				nil,
+			),
+		},
+		"ok, create personal access token": {
+			inReq: makeReq("POST",
+				"http://1.2.3.4/api/management/v1/useradm/settings/tokens",
+				"Bearer "+token,
+				map[string]interface{}{
+					"name":       "foo",
+					"expires_in": 3600,
+					"scopes":     []string{scope.DevicesRead},
+				},
+			),
+			checker: &mt.BaseResponse{
+				Status:      http.StatusOK,
+				ContentType: "application/jwt",
+				Body:        "foo",
+			},
+		},
+		"error: change another user's password": {
+			inReq: makeReq("PUT",
+				"http://1.2.3.4/api/management/v1/useradm/users/123",
+				"Bearer "+token,
+				map[string]interface{}{
+					"password": "foobarbar",
+				},
+			),
+			checker: mt.NewJSONResponse(
+				http.StatusForbidden,
+				nil,
+				restError(authz.ErrAuthzUnauthorized.Error()),
+			),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db := &mstore.DataStore{}
+			db.On("GetUserById", mtesting.ContextMatcher(), subject.String()).
+				Return(&model.User{
+					ID:    subject.String(),
+					Roles: []string{model.RoleReadOnly},
+				}, nil)
+			db.On("GetGroupsByMember", mtesting.ContextMatcher(), subject.String()).
+				Return([]model.Group{}, nil)
+			db.On("GetActiveElevations", mtesting.ContextMatcher(), subject.String()).
+				Return([]model.Elevation{}, nil)
+
+			uadm := &museradm.App{}
+			defer uadm.AssertExpectations(t)
+			uadm.On("Verify", mtesting.ContextMatcher(),
+				mock.AnythingOfType("*jwt.Token")).
+				Return(&model.TokenIdentity{}, nil)
+			switch tc.inReq.URL.Path {
+			case "/api/management/v1/useradm/users/me":
+				uadm.On("UpdateUser", mtesting.ContextMatcher(),
+					subject.String(),
+					mock.AnythingOfType("*model.UserUpdate")).
+					Return(nil)
+			case "/api/management/v1/useradm/settings/tokens":
+				uadm.On("IssuePersonalAccessToken", mtesting.ContextMatcher(),
+					mock.AnythingOfType("*model.TokenRequest")).
+					Return("foo", nil)
+			}
+
+			handlers := NewUserAdmApiHandlers(uadm, db, jwth, Config{})
+			app, err := handlers.GetApp()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			// authorize the management API with the built-in roles, as
+			// the server does
+			api := rest.NewApi()
+			api.Use(
+				&requestlog.RequestLogMiddleware{},
+				&requestid.RequestIdMiddleware{},
+				&identity.IdentityMiddleware{},
+				&rest.IfMiddleware{
+					Condition: IsManagementEndpoint,
+					IfTrue: &authz.AuthzMiddleware{
+						Authz:      authz.NewRBACAuthorizer(db),
+						ResFunc:    ExtractManagementResourceAction,
+						JWTHandler: jwth,
+						Verify:     TokenVerifier(uadm),
+					},
+				},
+			)
+			api.SetApp(app)
+			rest.ErrorFieldName = "error"
+
+			tc.inReq.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), tc.inReq)
+
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
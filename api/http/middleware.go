@@ -32,6 +32,49 @@ func IsVerificationEndpoint(r *rest.Request) bool {
 	}
 }
 
+// IsManagementEndpoint checks if the request targets the management API
+// requiring an authenticated user; the login, single sign-on, token and
+// SCIM endpoints accepting other credentials are excluded.
+func IsManagementEndpoint(r *rest.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, apiUrlManagementV1+"/") ||
+		strings.HasPrefix(r.URL.Path, uriManagementSSO) ||
+		IsSCIMEndpoint(r) {
+		return false
+	}
+	switch r.URL.Path {
+	case uriManagementAuthLogin, uriManagementOAuth2Token:
+		return false
+	default:
+		return true
+	}
+}
+
+// IsFormEndpoint checks if the request targets an endpoint which accepts
+// form-encoded bodies (as mandated by the OAuth 2.0 RFCs and the SAML
+// HTTP-POST binding) rather than JSON.
+func IsFormEndpoint(r *rest.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, uriManagementSAML) &&
+		strings.HasSuffix(r.URL.Path, "/acs") {
+		return true
+	}
+	switch r.URL.Path {
+	case uriInternalAuthIntrospect, uriManagementOAuth2Token:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsSCIMEndpoint checks if the request targets the SCIM provisioning API,
+// which authenticates the clients with the tenant's SCIM token and
+// accepts application/scim+json bodies.
+func IsSCIMEndpoint(r *rest.Request) bool {
+	return strings.HasPrefix(r.URL.Path, uriManagementSCIM)
+}
+
 // ExtractResourceAction extracts resource action from the request url
 func ExtractResourceAction(r *rest.Request) (*authz.Action, error) {
 	action := authz.Action{}
@@ -41,13 +84,11 @@ func ExtractResourceAction(r *rest.Request) (*authz.Action, error) {
 	if uri == "" {
 		uri = r.Header.Get("X-Forwarded-URI")
 	}
-	uriItems := strings.Split(uri, "/")
-
-	if uri == "" || len(uriItems) < 4 {
-		return nil, errors.New("can't parse service name from original uri " + uri)
+	resource, err := resourceFromURI(uri)
+	if err != nil {
+		return nil, err
 	}
-
-	action.Resource = strings.Join(uriItems[4:], ":")
+	action.Resource = resource
 
 	// extract original http method
 	action.Method = r.Header.Get("X-Forwarded-Method")
@@ -60,3 +101,30 @@ func ExtractResourceAction(r *rest.Request) (*authz.Action, error) {
 
 	return &action, nil
 }
+
+// ExtractManagementResourceAction extracts the resource action from the
+// management request itself rather than from the headers forwarded by the
+// gateway.
+func ExtractManagementResourceAction(r *rest.Request) (*authz.Action, error) {
+	resource, err := resourceFromURI(r.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &authz.Action{
+		Resource: resource,
+		Method:   r.Method,
+	}, nil
+}
+
+// resourceFromURI returns the resource of the authorization rules for the
+// original request uri, e.g. "deployments:deployments:123" for
+// "/api/management/v1/deployments/deployments/123".
+func resourceFromURI(uri string) (string, error) {
+	uriItems := strings.Split(uri, "/")
+
+	if uri == "" || len(uriItems) < 4 {
+		return "", errors.New("can't parse service name from original uri " + uri)
+	}
+
+	return strings.Join(uriItems[4:], ":"), nil
+}
@@ -26,6 +26,10 @@ var (
 	ErrAuthzNoAuth       = errors.New("authorization not present in header")
 	ErrInvalidAuthHeader = errors.New("malformed Authorization header")
 	ErrAuthzTokenInvalid = errors.New("invalid jwt")
+	// ErrAuthzNotApplicable is returned by the authorizers which have
+	// no rule for the request; the request is denied unless another
+	// authorizer grants it (see FirstApplicable and DenyOverrides).
+	ErrAuthzNotApplicable = errors.New("no applicable authorization rule")
 )
 
 // Authorizer defines the interface for checking the permissions of a given user(token) vs an action
@@ -37,6 +41,7 @@ type Authorizer interface {
 	// returns:
 	// nil if authorization is granted
 	// ErrAuthzUnauthorized otherwise
+	// ErrAuthzNotApplicable if the authorizer has no rule for the request
 	// ErrAuthzTokenInvalid if can't parse token
 	Authorize(ctx context.Context, token *jwt.Token, resource, action string) error
 }
@@ -0,0 +1,121 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"context"
+
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+const (
+	CombiningFirstApplicable = "first-applicable"
+	CombiningDenyOverrides   = "deny-overrides"
+)
+
+type firstApplicable []Authorizer
+
+// FirstApplicable combines the authorizers so that the first one with an
+// applicable rule decides; the request is denied if none of them has.
+// E.g. FirstApplicable(policy, rbac) lets the policy file both grant and
+// deny the requests, with RBAC deciding the rest.
+func FirstApplicable(authorizers ...Authorizer) Authorizer {
+	return firstApplicable(authorizers)
+}
+
+func (authorizers firstApplicable) Authorize(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) error {
+	decision, err := authorizers.Explain(ctx, token, resource, action)
+	if err != nil {
+		return err
+	}
+	return decision.Err()
+}
+
+func (authorizers firstApplicable) Explain(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) (*Decision, error) {
+	result := &Decision{Effect: EffectDeny}
+	for _, a := range authorizers {
+		decision, err := Explain(ctx, a, token, resource, action)
+		if err != nil {
+			return nil, err
+		}
+		result.Reasons = append(result.Reasons, decision.Reasons...)
+		if decision.Effect != EffectNotApplicable {
+			result.Effect = decision.Effect
+			break
+		}
+	}
+	return result, nil
+}
+
+type denyOverrides []Authorizer
+
+// DenyOverrides combines the authorizers so that a request is granted
+// only if none of them denies it and at least one grants it.
+// E.g. DenyOverrides(policy, rbac) lets the policy file restrict what
+// RBAC allows.
+func DenyOverrides(authorizers ...Authorizer) Authorizer {
+	return denyOverrides(authorizers)
+}
+
+func (authorizers denyOverrides) Authorize(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) error {
+	decision, err := authorizers.Explain(ctx, token, resource, action)
+	if err != nil {
+		return err
+	}
+	return decision.Err()
+}
+
+func (authorizers denyOverrides) Explain(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) (*Decision, error) {
+	result := &Decision{Effect: EffectNotApplicable}
+	for _, a := range authorizers {
+		decision, err := Explain(ctx, a, token, resource, action)
+		if err != nil {
+			return nil, err
+		}
+		result.Reasons = append(result.Reasons, decision.Reasons...)
+		switch decision.Effect {
+		case EffectDeny:
+			result.Effect = EffectDeny
+		case EffectAllow:
+			if result.Effect != EffectDeny {
+				result.Effect = EffectAllow
+			}
+		}
+	}
+	if result.Effect == EffectNotApplicable {
+		result.Effect = EffectDeny
+	}
+	return result, nil
+}
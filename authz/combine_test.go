@@ -0,0 +1,113 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/useradm/authz"
+	mauthz "github.com/mendersoftware/useradm/authz/mocks"
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+func TestCombiningAuthorizers(t *testing.T) {
+	internalErr := errors.New("internal error")
+
+	testCases := map[string]struct {
+		first  error
+		second error
+
+		// the second authorizer is not consulted if the first decides
+		firstApplicable error
+		callSecond      bool
+		denyOverrides   error
+	}{
+		"first grants": {
+			first:           nil,
+			second:          ErrAuthzUnauthorized,
+			firstApplicable: nil,
+			denyOverrides:   ErrAuthzUnauthorized,
+		},
+		"first denies": {
+			first:           ErrAuthzUnauthorized,
+			second:          nil,
+			firstApplicable: ErrAuthzUnauthorized,
+			denyOverrides:   ErrAuthzUnauthorized,
+		},
+		"first not applicable, second grants": {
+			first:           ErrAuthzNotApplicable,
+			second:          nil,
+			firstApplicable: nil,
+			callSecond:      true,
+			denyOverrides:   nil,
+		},
+		"first not applicable, second denies": {
+			first:           ErrAuthzNotApplicable,
+			second:          ErrAuthzUnauthorized,
+			firstApplicable: ErrAuthzUnauthorized,
+			callSecond:      true,
+			denyOverrides:   ErrAuthzUnauthorized,
+		},
+		"none applicable": {
+			first:           ErrAuthzNotApplicable,
+			second:          ErrAuthzNotApplicable,
+			firstApplicable: ErrAuthzUnauthorized,
+			callSecond:      true,
+			denyOverrides:   ErrAuthzUnauthorized,
+		},
+		"both grant": {
+			first:           nil,
+			second:          nil,
+			firstApplicable: nil,
+			denyOverrides:   nil,
+		},
+		"internal error": {
+			first:           internalErr,
+			second:          nil,
+			firstApplicable: internalErr,
+			denyOverrides:   internalErr,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			token := &jwt.Token{}
+
+			first := &mauthz.Authorizer{}
+			first.On("Authorize", ctx, token, "useradm:users", "GET").
+				Return(tc.first)
+			second := &mauthz.Authorizer{}
+			second.On("Authorize", ctx, token, "useradm:users", "GET").
+				Return(tc.second)
+
+			err := FirstApplicable(first, second).
+				Authorize(ctx, token, "useradm:users", "GET")
+			assert.Equal(t, tc.firstApplicable, err)
+			if !tc.callSecond {
+				second.AssertNotCalled(t, "Authorize",
+					ctx, token, "useradm:users", "GET")
+			}
+
+			err = DenyOverrides(first, second).
+				Authorize(ctx, token, "useradm:users", "GET")
+			assert.Equal(t, tc.denyOverrides, err)
+		})
+	}
+}
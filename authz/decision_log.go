@@ -0,0 +1,73 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+type denialLogger struct {
+	authorizer Authorizer
+}
+
+// WithDenialLog returns an authorizer logging the denied requests with
+// the reasons of the denials.
+func WithDenialLog(authorizer Authorizer) Authorizer {
+	return &denialLogger{authorizer: authorizer}
+}
+
+func (a *denialLogger) Authorize(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) error {
+	decision, err := a.Explain(ctx, token, resource, action)
+	if err != nil {
+		return err
+	}
+	if err = decision.Err(); err != nil {
+		reasons := make([]string, len(decision.Reasons))
+		for i, reason := range decision.Reasons {
+			reasons[i] = reason.String()
+		}
+		fields := log.Ctx{
+			"resource": resource,
+			"action":   action,
+		}
+		if token != nil {
+			fields["subject"] = token.Claims.Subject.String()
+			fields["tenant_id"] = token.Claims.Tenant
+		}
+		log.FromContext(ctx).F(fields).Warnf("authorization denied: %s",
+			strings.Join(reasons, "; "))
+	}
+	return err
+}
+
+// Explain passes through to the wrapped authorizer.
+func (a *denialLogger) Explain(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) (*Decision, error) {
+	return Explain(ctx, a.authorizer, token, resource, action)
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+const (
+	EffectAllow         = "allow"
+	EffectDeny          = "deny"
+	EffectNotApplicable = "not_applicable"
+)
+
+// Decision is an authorization decision together with the rules which
+// led to it.
+type Decision struct {
+	Effect  string   `json:"effect"`
+	Reasons []Reason `json:"reasons"`
+}
+
+// Reason describes a rule which contributed to a Decision.
+type Reason struct {
+	// Authorizer is the name of the authorizer the rule belongs to
+	Authorizer string `json:"authorizer"`
+	Effect     string `json:"effect"`
+	// Rule identifies the rule, e.g. the role or the policy rule
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+func (r Reason) String() string {
+	if r.Rule != "" {
+		return fmt.Sprintf("%s: %s (%s): %s", r.Authorizer, r.Effect, r.Rule, r.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", r.Authorizer, r.Effect, r.Message)
+}
+
+// Err returns the error the Authorize method returns for the decision.
+func (d *Decision) Err() error {
+	switch d.Effect {
+	case EffectAllow:
+		return nil
+	case EffectNotApplicable:
+		return ErrAuthzNotApplicable
+	default:
+		return ErrAuthzUnauthorized
+	}
+}
+
+// Explainer is implemented by the authorizers which can explain their
+// decisions; Explain must not have side effects.
+type Explainer interface {
+	Explain(ctx context.Context, token *jwt.Token, resource, action string) (*Decision, error)
+}
+
+// Explain evaluates the authorizer and explains the decision; the
+// authorizers which do not implement Explainer are reported with their
+// decision only.
+func Explain(
+	ctx context.Context,
+	a Authorizer,
+	token *jwt.Token,
+	resource,
+	action string,
+) (*Decision, error) {
+	if e, ok := a.(Explainer); ok {
+		return e.Explain(ctx, token, resource, action)
+	}
+
+	reason := Reason{Authorizer: fmt.Sprintf("%T", a)}
+	switch err := a.Authorize(ctx, token, resource, action); err {
+	case nil:
+		reason.Effect = EffectAllow
+		reason.Message = "the request is allowed"
+	case ErrAuthzUnauthorized:
+		reason.Effect = EffectDeny
+		reason.Message = "the request is denied"
+	case ErrAuthzNotApplicable:
+		reason.Effect = EffectNotApplicable
+		reason.Message = ErrAuthzNotApplicable.Error()
+	default:
+		return nil, err
+	}
+	return &Decision{Effect: reason.Effect, Reasons: []Reason{reason}}, nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/mendersoftware/useradm/authz"
+	mauthz "github.com/mendersoftware/useradm/authz/mocks"
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+func TestExplain(t *testing.T) {
+	subject := oid.NewUUIDv5("testsubject")
+	token := &jwt.Token{
+		Claims: jwt.Claims{
+			Subject: subject,
+			Tenant:  "tenant",
+			Scope:   scope.All,
+			User:    true,
+		},
+	}
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		combine  func(...Authorizer) Authorizer
+		resource string
+		action   string
+		roles    []string
+
+		outDecision *Decision
+	}{
+		"allow, role": {
+			combine:  DenyOverrides,
+			resource: "deployments:deployments",
+			action:   "GET",
+			roles:    []string{model.RoleReadOnly},
+
+			outDecision: &Decision{
+				Effect: EffectAllow,
+				Reasons: []Reason{{
+					Authorizer: "policy",
+					Effect:     EffectNotApplicable,
+					Message:    "no rule matches the request",
+				}, {
+					Authorizer: "rbac",
+					Effect:     EffectAllow,
+					Rule:       "role read-only",
+					Message:    "the role permits the request",
+				}},
+			},
+		},
+		"deny, policy rule": {
+			combine:  DenyOverrides,
+			resource: "deployments:artifacts:1234",
+			action:   "DELETE",
+			roles:    []string{model.RoleAdmin},
+
+			outDecision: &Decision{
+				Effect: EffectDeny,
+				Reasons: []Reason{{
+					Authorizer: "policy",
+					Effect:     EffectDeny,
+					Rule:       "rules[2]",
+					Message:    "nobody may remove the artifacts",
+				}, {
+					Authorizer: "rbac",
+					Effect:     EffectAllow,
+					Rule:       "role admin",
+					Message:    "the role permits the request",
+				}},
+			},
+		},
+		"deny, no role": {
+			combine:  FirstApplicable,
+			resource: "inventory:devices",
+			action:   "PUT",
+			roles:    []string{model.RoleReadOnly},
+
+			outDecision: &Decision{
+				Effect: EffectDeny,
+				Reasons: []Reason{{
+					Authorizer: "policy",
+					Effect:     EffectNotApplicable,
+					Message:    "no rule matches the request",
+				}, {
+					Authorizer: "rbac",
+					Effect:     EffectDeny,
+					Message:    `none of the roles ["read-only"] permits the request`,
+				}},
+			},
+		},
+		"allow, first applicable": {
+			combine:  FirstApplicable,
+			resource: "deployments:deployments",
+			action:   "POST",
+			roles:    []string{model.RoleReleaseManager},
+
+			outDecision: &Decision{
+				Effect: EffectAllow,
+				Reasons: []Reason{{
+					Authorizer: "policy",
+					Effect:     EffectAllow,
+					Rule:       "rules[0]",
+					Message:    "release managers may manage the deployments",
+				}},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db := &mstore.DataStore{}
+			db.On("GetUserById",
+				mock.MatchedBy(func(context.Context) bool { return true }),
+				subject.String()).
+				Return(&model.User{Roles: tc.roles}, nil)
+			db.On("GetGroupsByMember",
+				mock.MatchedBy(func(context.Context) bool { return true }),
+				subject.String()).
+				Return([]model.Group{}, nil)
+			db.On("GetActiveElevations",
+				mock.MatchedBy(func(context.Context) bool { return true }),
+				subject.String()).
+				Return([]model.Elevation{}, nil)
+
+			authorizer := tc.combine(
+				NewPolicyAuthorizer(policy, db),
+				NewRBACAuthorizer(db),
+			)
+			decision, err := Explain(context.Background(), authorizer,
+				token, tc.resource, tc.action)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.outDecision, decision)
+			assert.Equal(t, decision.Err(), authorizer.Authorize(
+				context.Background(), token, tc.resource, tc.action))
+		})
+	}
+}
+
+func TestExplainNotExplainer(t *testing.T) {
+	ctx := context.Background()
+	token := &jwt.Token{}
+
+	a := &mauthz.Authorizer{}
+	a.On("Authorize", ctx, token, "useradm:users", "GET").Return(nil).Once()
+	a.On("Authorize", ctx, token, "useradm:users", "GET").
+		Return(ErrAuthzUnauthorized).Once()
+	a.On("Authorize", ctx, token, "useradm:users", "GET").
+		Return(errors.New("internal error")).Once()
+
+	decision, err := Explain(ctx, a, token, "useradm:users", "GET")
+	assert.NoError(t, err)
+	assert.Equal(t, EffectAllow, decision.Effect)
+
+	decision, err = Explain(ctx, a, token, "useradm:users", "GET")
+	assert.NoError(t, err)
+	assert.Equal(t, EffectDeny, decision.Effect)
+	assert.Equal(t, ErrAuthzUnauthorized, decision.Err())
+
+	_, err = Explain(ctx, a, token, "useradm:users", "GET")
+	assert.EqualError(t, err, "internal error")
+}
+
+func TestWithDenialLog(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &out
+	ctx := log.WithContext(context.Background(), log.NewFromLogger(logger, log.Ctx{}))
+
+	token := &jwt.Token{Claims: jwt.Claims{Tenant: "tenant", User: true}}
+	policy := NewPolicyAuthorizer(&Policy{Rules: []PolicyRule{{
+		Effect:      PolicyEffectDeny,
+		Description: "no deletes",
+		Resources:   []string{"*"},
+		Methods:     []string{"DELETE"},
+	}, {
+		Effect:    PolicyEffectAllow,
+		Resources: []string{"*"},
+		Methods:   []string{"*"},
+	}}}, nil)
+	authorizer := WithDenialLog(policy)
+
+	assert.NoError(t, authorizer.Authorize(ctx, token, "useradm:users", "GET"))
+	assert.Empty(t, out.String())
+
+	err := authorizer.Authorize(ctx, token, "useradm:users:1", "DELETE")
+	assert.Equal(t, ErrAuthzUnauthorized, err)
+	assert.Contains(t, out.String(),
+		"authorization denied: policy: deny (rules[0]): no deletes")
+	assert.Contains(t, out.String(), "tenant_id=tenant")
+	assert.Contains(t, out.String(), "resource=\"useradm:users:1\"")
+
+	// the wrapper is transparent for explaining
+	decision, err := Explain(ctx, authorizer, token, "useradm:users:1", "DELETE")
+	assert.NoError(t, err)
+	assert.Equal(t, EffectDeny, decision.Effect)
+}
@@ -14,6 +14,7 @@
 package authz
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -36,8 +37,31 @@ type AuthzMiddleware struct {
 	Authz      Authorizer
 	ResFunc    ResourceActionExtractor
 	JWTHandler jwt.Handler
+	// Verify, if set, checks the token beyond its signature and
+	// expiration before the request is authorized
+	Verify TokenVerifier
+	// Cache, if set, holds the requests allowed to the tokens, which are
+	// then not authorized again
+	Cache DecisionCache
 }
 
+// DecisionCache caches the requests allowed to the verified tokens, for
+// as long as the verifications are cached; the denials are not cached,
+// so that they are logged.
+type DecisionCache interface {
+	// Epoch must be read before authorizing the request, see AddAllowed
+	Epoch() uint64
+	Allowed(tokenID, resource, action string) bool
+	// AddAllowed caches the allowed request unless anything has been
+	// invalidated since the epoch
+	AddAllowed(tokenID, resource, action string, epoch uint64)
+}
+
+// TokenVerifier checks that the token has not been revoked and that its
+// subject is still allowed to use it; it returns ErrAuthzTokenInvalid if
+// the token is rejected.
+type TokenVerifier func(ctx context.Context, token *jwt.Token) error
+
 // Action combines info about the requested resourd + http method.
 type Action struct {
 	Resource string
@@ -60,7 +84,7 @@ func (mw *AuthzMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
 		}
 
 		// parse token, insert into env
-		token, err := mw.JWTHandler.FromJWT(tokstr)
+		token, err := mw.JWTHandler.FromJWT(r.Context(), tokstr)
 		if err != nil {
 			rest_utils.RestErrWithLog(w, r, l, ErrAuthzTokenInvalid, http.StatusUnauthorized)
 			return
@@ -68,6 +92,19 @@ func (mw *AuthzMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
 
 		r.Env[ReqToken] = token
 
+		ctx := r.Context()
+
+		if mw.Verify != nil {
+			err = mw.Verify(ctx, token)
+			if err == ErrAuthzTokenInvalid {
+				rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
+				return
+			} else if err != nil {
+				rest_utils.RestErrWithLogInternal(w, r, l, err)
+				return
+			}
+		}
+
 		// extract resource action
 		action, err := mw.ResFunc(r)
 		if err != nil {
@@ -75,12 +112,19 @@ func (mw *AuthzMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
 			return
 		}
 
-		ctx := r.Context()
+		var epoch uint64
+		if mw.Cache != nil {
+			if mw.Cache.Allowed(token.ID.String(), action.Resource, action.Method) {
+				h(w, r)
+				return
+			}
+			epoch = mw.Cache.Epoch()
+		}
 
 		//authorize, no authz = http 403
 		err = mw.Authz.Authorize(ctx, token, action.Resource, action.Method)
 		if err != nil {
-			if err == ErrAuthzUnauthorized {
+			if err == ErrAuthzUnauthorized || err == ErrAuthzNotApplicable {
 				rest_utils.RestErrWithLog(w, r, l,
 					ErrAuthzUnauthorized, http.StatusForbidden)
 			} else if err == ErrAuthzTokenInvalid {
@@ -91,6 +135,9 @@ func (mw *AuthzMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
 			}
 			return
 		}
+		if mw.Cache != nil {
+			mw.Cache.AddAllowed(token.ID.String(), action.Resource, action.Method, epoch)
+		}
 
 		h(w, r)
 	}
@@ -257,6 +257,36 @@ func TestAuthzMiddleware(t *testing.T) {
 
 		authErr: ErrAuthzUnauthorized,
 
+		checker: mt.NewJSONResponse(
+			http.StatusForbidden,
+			nil,
+			restError(ErrAuthzUnauthorized.Error()),
+		),
+	}, {
+		Name: "error: no applicable rule",
+		Header: http.Header{"Authorization": []string{
+			"Bearer eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.eyJleHAi" +
+				"OjQxMDExMDQwNjksImlzcyI6Im1lbmRlciIsInN1YiI6" +
+				"Ijc0YzYwMmI1LWJlMWYtNTgwMC04MTRkLWJlMzE0MzEy" +
+				"MjMwZSIsImp0aSI6Ijc0YzYwMmI1LWJlMWYtNTgwMC04" +
+				"MTRkLWJlMzE0MzEyMjMwZSIsInNjcCI6Im1lbmRlci5m" +
+				"b28ifQ.KsL6UqOwqE1sdVqHWUj1DNlagv_Gk9AI76zBt" +
+				"vlSH8cP-8EV24bD6xdHowoN1TPHdPnvwYsCP6u0KOdRw" +
+				"MDHVkJojNEUyMNAZAAKNvyjdEBlKNIbLIrPvFZY4zIyP" +
+				"wOljmFhdACahVtPsn3x8g4fkCcd29Bpy21jWs5Y8N4nA" +
+				"yJdfCNOaXyQwhY6mAGodAyKy0YKUqJicbpU4rmYUzGGc" +
+				"vQqtivUIDXswb80vfsuhOGYJKT6XJMPEeTCK4lPrLIyU" +
+				"U5gXjxl0Ym_61MVvPIohOreimeDFMN0TbV_ljDzWxLlM" +
+				"d2At6zLVWIOkFc0YLeZAsNlD3JxGqwVfKiWRfj_bg",
+		}},
+		action: &Action{
+			Resource: "foo:bar",
+			Method:   "GET",
+		},
+		actionErr: nil,
+
+		authErr: ErrAuthzNotApplicable,
+
 		checker: mt.NewJSONResponse(
 			http.StatusForbidden,
 			nil,
@@ -359,6 +389,119 @@ func TestAuthzMiddleware(t *testing.T) {
 	}
 }
 
+func TestAuthzMiddlewareCache(t *testing.T) {
+	const tokenID = "74c602b5-be1f-5800-814d-be314312230e"
+	header := http.Header{"Authorization": []string{
+		"Bearer eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.eyJleHAi" +
+			"OjQxMDExMDQwNjksImlzcyI6Im1lbmRlciIsInN1YiI6" +
+			"Ijc0YzYwMmI1LWJlMWYtNTgwMC04MTRkLWJlMzE0MzEy" +
+			"MjMwZSIsImp0aSI6Ijc0YzYwMmI1LWJlMWYtNTgwMC04" +
+			"MTRkLWJlMzE0MzEyMjMwZSIsInNjcCI6Im1lbmRlci5m" +
+			"b28ifQ.KsL6UqOwqE1sdVqHWUj1DNlagv_Gk9AI76zBt" +
+			"vlSH8cP-8EV24bD6xdHowoN1TPHdPnvwYsCP6u0KOdRw" +
+			"MDHVkJojNEUyMNAZAAKNvyjdEBlKNIbLIrPvFZY4zIyP" +
+			"wOljmFhdACahVtPsn3x8g4fkCcd29Bpy21jWs5Y8N4nA" +
+			"yJdfCNOaXyQwhY6mAGodAyKy0YKUqJicbpU4rmYUzGGc" +
+			"vQqtivUIDXswb80vfsuhOGYJKT6XJMPEeTCK4lPrLIyU" +
+			"U5gXjxl0Ym_61MVvPIohOreimeDFMN0TbV_ljDzWxLlM" +
+			"d2At6zLVWIOkFc0YLeZAsNlD3JxGqwVfKiWRfj_bg",
+	}}
+	action := &Action{
+		Resource: "foo:bar",
+		Method:   "GET",
+	}
+
+	testCases := []struct {
+		Name string
+
+		allowed bool
+		authErr error
+
+		checker mt.ResponseChecker
+	}{{
+		Name:    "ok, cached",
+		allowed: true,
+
+		checker: mt.NewJSONResponse(
+			http.StatusOK,
+			nil,
+			map[string]string{"foo": "bar"},
+		),
+	}, {
+		Name: "ok, not cached",
+
+		checker: mt.NewJSONResponse(
+			http.StatusOK,
+			nil,
+			map[string]string{"foo": "bar"},
+		),
+	}, {
+		Name:    "error: unauthorized, not cached",
+		authErr: ErrAuthzUnauthorized,
+
+		checker: mt.NewJSONResponse(
+			http.StatusForbidden,
+			nil,
+			restError("unauthorized"),
+		),
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			api := rest.NewApi()
+			api.Use(
+				&requestlog.RequestLogMiddleware{
+					BaseLogger: &logrus.Logger{Out: ioutil.Discard},
+				},
+				&requestid.RequestIdMiddleware{},
+			)
+			rest.ErrorFieldName = "error"
+
+			a := &mauthz.Authorizer{}
+			defer a.AssertExpectations(t)
+			cache := &mauthz.DecisionCache{}
+			defer cache.AssertExpectations(t)
+
+			cache.On("Allowed", tokenID, action.Resource, action.Method).
+				Return(tc.allowed)
+			if !tc.allowed {
+				cache.On("Epoch").Return(uint64(7))
+				a.On("Authorize",
+					mtest.ContextMatcher(),
+					mock.AnythingOfType("*jwt.Token"),
+					action.Resource,
+					action.Method).Return(tc.authErr)
+				if tc.authErr == nil {
+					cache.On("AddAllowed", tokenID,
+						action.Resource, action.Method, uint64(7))
+				}
+			}
+
+			privkey := loadPrivKey("../crypto/private.pem", t)
+			mw := AuthzMiddleware{
+				Authz: a,
+				ResFunc: func(r *rest.Request) (*Action, error) {
+					return action, nil
+				},
+				JWTHandler: jwt.NewJWTHandlerRS256(privkey, nil),
+				Cache:      cache,
+			}
+			api.Use(&mw)
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.WriteJson(map[string]string{"foo": "bar"})
+			}))
+
+			req, _ := http.NewRequest(action.Method, "localhost", nil)
+			req.Header = header.Clone()
+			req.Header.Set("X-MEN-RequestID", "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
 func restError(status string) map[string]interface{} {
 	return map[string]interface{}{"error": status, "request_id": "test"}
 }
@@ -0,0 +1,338 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+const (
+	PolicyEffectAllow = EffectAllow
+	PolicyEffectDeny  = EffectDeny
+
+	// the token types a policy rule can apply to
+	TokenTypeUser           = "user"
+	TokenTypeServiceAccount = "service_account"
+
+	policyMethodAny = "*"
+)
+
+// Policy is a set of authorization rules defined in a policy file.
+// A request is denied if any of the deny rules matches it, and allowed
+// if any of the allow rules does; otherwise the policy does not apply
+// to the request.
+type Policy struct {
+	Rules []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// PolicyRule allows or denies the methods on the resources matching the
+// patterns. The resources are named the same way as in the Authorizer
+// interface (e.g. "deployments:deployments:<id>"); the patterns support
+// the '*', '?' and '[...]' wildcards.
+// The rule applies to the tokens matching all of the subject, role and
+// token type selectors which are set; a rule without selectors applies
+// to all the tokens.
+type PolicyRule struct {
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Effect      string `json:"effect" yaml:"effect"`
+
+	// Subjects are the IDs of the users and service accounts
+	Subjects []string `json:"subjects,omitempty" yaml:"subjects,omitempty"`
+	// Roles are the names of the roles assigned to the users
+	Roles []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+	// TokenTypes are "user" and "service_account"
+	TokenTypes []string `json:"token_types,omitempty" yaml:"token_types,omitempty"`
+
+	Resources []string `json:"resources" yaml:"resources"`
+	Methods   []string `json:"methods" yaml:"methods"`
+}
+
+var validPattern = validation.By(func(value interface{}) error {
+	pattern, _ := value.(string)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return errors.New("must be a valid pattern")
+	}
+	return nil
+})
+
+func (p Policy) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Rules, validation.Required),
+	)
+}
+
+func (r PolicyRule) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Effect, validation.Required,
+			validation.In(PolicyEffectAllow, PolicyEffectDeny)),
+		validation.Field(&r.Subjects, validation.Each(validation.Required)),
+		validation.Field(&r.Roles, validation.Each(validation.Required)),
+		validation.Field(&r.TokenTypes, validation.Each(validation.Required,
+			validation.In(TokenTypeUser, TokenTypeServiceAccount))),
+		validation.Field(&r.Resources, validation.Required,
+			validation.Each(validation.Required, validPattern)),
+		validation.Field(&r.Methods, validation.Required,
+			validation.Each(validation.Required, validation.In(
+				policyMethodAny, "GET", "HEAD", "POST", "PUT",
+				"PATCH", "DELETE", "OPTIONS",
+			))),
+	)
+}
+
+// ParsePolicy decodes and validates a YAML policy. Unknown fields are
+// rejected to catch typos in the rules.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&policy); err != nil {
+		return nil, errors.Wrap(err, "failed to decode policy")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid policy")
+	}
+	return &policy, nil
+}
+
+// ParsePolicyJSON decodes and validates a JSON policy.
+func ParsePolicyJSON(data []byte) (*Policy, error) {
+	var policy Policy
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&policy); err != nil {
+		return nil, errors.Wrap(err, "failed to decode policy")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid policy")
+	}
+	return &policy, nil
+}
+
+// LoadPolicyFile reads a policy file; files with the ".json" extension
+// are parsed as JSON, all the others as YAML.
+func LoadPolicyFile(filename string) (*Policy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read policy file")
+	}
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return ParsePolicyJSON(data)
+	}
+	return ParsePolicy(data)
+}
+
+func matchesAny(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r PolicyRule) matchesRequest(resource, action string) bool {
+	methodMatch := false
+	for _, method := range r.Methods {
+		if method == policyMethodAny || strings.EqualFold(method, action) {
+			methodMatch = true
+			break
+		}
+	}
+	if !methodMatch {
+		return false
+	}
+	for _, pattern := range r.Resources {
+		if ok, _ := path.Match(pattern, resource); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyAuthorizer authorizes the requests according to a Policy; the
+// policy can be replaced at run time with SetPolicy.
+// The authorizer returns ErrAuthzNotApplicable if no rule matches the
+// request, see FirstApplicable and DenyOverrides for combining it with
+// other authorizers.
+type PolicyAuthorizer struct {
+	roles RoleStore
+
+	mutex  sync.RWMutex
+	policy *Policy
+}
+
+// NewPolicyAuthorizer returns the policy-based authorizer; roles is used
+// to look up the roles of the users, and may be nil if the policy does
+// not select the rules by role.
+func NewPolicyAuthorizer(policy *Policy, roles RoleStore) *PolicyAuthorizer {
+	return &PolicyAuthorizer{
+		roles:  roles,
+		policy: policy,
+	}
+}
+
+// SetPolicy replaces the policy.
+func (a *PolicyAuthorizer) SetPolicy(policy *Policy) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.policy = policy
+}
+
+func (a *PolicyAuthorizer) getPolicy() *Policy {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.policy
+}
+
+const policyAuthorizerName = "policy"
+
+// Authorize makes PolicyAuthorizer implement the Authorizer interface.
+func (a *PolicyAuthorizer) Authorize(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) error {
+	decision, err := a.Explain(ctx, token, resource, action)
+	if err != nil {
+		return err
+	}
+	return decision.Err()
+}
+
+// Explain makes PolicyAuthorizer implement the Explainer interface; all
+// the rules matching the request are reported.
+func (a *PolicyAuthorizer) Explain(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) (*Decision, error) {
+	decision := func(effect, message string) *Decision {
+		return &Decision{
+			Effect: effect,
+			Reasons: []Reason{{
+				Authorizer: policyAuthorizerName,
+				Effect:     effect,
+				Message:    message,
+			}},
+		}
+	}
+	if token == nil {
+		return decision(EffectDeny, "no token"), nil
+	}
+	policy := a.getPolicy()
+	if policy == nil {
+		return decision(EffectNotApplicable, "no policy is loaded"), nil
+	}
+
+	tokenType := TokenTypeUser
+	if token.Claims.ServiceAccount {
+		tokenType = TokenTypeServiceAccount
+	}
+	subject := token.Claims.Subject.String()
+
+	// the roles are looked up only if a rule needs them
+	var (
+		roles       []string
+		rolesLoaded bool
+	)
+	result := &Decision{Effect: EffectNotApplicable}
+	for i, rule := range policy.Rules {
+		if !rule.matchesRequest(resource, action) {
+			continue
+		}
+		if len(rule.Subjects) > 0 && !matchesAny(rule.Subjects, subject) {
+			continue
+		}
+		if len(rule.TokenTypes) > 0 && !matchesAny(rule.TokenTypes, tokenType) {
+			continue
+		}
+		if len(rule.Roles) > 0 {
+			if !rolesLoaded {
+				var err error
+				roles, err = a.getRoleNames(ctx, token)
+				if err != nil {
+					return nil, err
+				}
+				rolesLoaded = true
+			}
+			roleMatch := false
+			for _, role := range roles {
+				if matchesAny(rule.Roles, role) {
+					roleMatch = true
+					break
+				}
+			}
+			if !roleMatch {
+				continue
+			}
+		}
+
+		reason := Reason{
+			Authorizer: policyAuthorizerName,
+			Effect:     rule.Effect,
+			Rule:       fmt.Sprintf("rules[%d]", i),
+			Message:    "the rule matches the request",
+		}
+		if rule.Description != "" {
+			reason.Message = rule.Description
+		}
+		result.Reasons = append(result.Reasons, reason)
+
+		// explicit deny takes precedence over any allow rule
+		if rule.Effect == PolicyEffectDeny {
+			result.Effect = EffectDeny
+		} else if result.Effect != EffectDeny {
+			result.Effect = EffectAllow
+		}
+	}
+	if result.Effect == EffectNotApplicable {
+		return decision(EffectNotApplicable, "no rule matches the request"), nil
+	}
+	return result, nil
+}
+
+// getRoleNames returns the names of the effective roles of the token's
+// subject, including the roles of the user's groups; service accounts have
+// no roles.
+func (a *PolicyAuthorizer) getRoleNames(ctx context.Context, token *jwt.Token) ([]string, error) {
+	if token.Claims.ServiceAccount || a.roles == nil {
+		return nil, nil
+	}
+	ctx = tokenContext(ctx, token)
+	user, err := getUser(ctx, a.roles, token)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, nil
+	}
+	names, _, err := EffectiveRoles(ctx, a.roles, token, user)
+	return names, err
+}
@@ -0,0 +1,338 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/mendersoftware/useradm/authz"
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+const testPolicy = `
+rules:
+  - description: release managers may manage the deployments
+    effect: allow
+    roles: [release-manager]
+    resources: ["deployments:*"]
+    methods: ["*"]
+  - description: the CI pipeline may upload artifacts
+    effect: allow
+    token_types: [service_account]
+    resources: ["deployments:artifacts"]
+    methods: [POST]
+  - description: nobody may remove the artifacts
+    effect: deny
+    resources: ["deployments:artifacts:*"]
+    methods: [DELETE]
+  - description: the contractor has no access to the device terminals
+    effect: deny
+    subjects: ["b867bd79-02af-5673-a275-b451358c30e5"]
+    resources: ["deviceconnect:*"]
+    methods: ["*"]
+`
+
+func TestParsePolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy string
+		json   bool
+
+		outErr string
+	}{
+		"ok": {
+			policy: testPolicy,
+		},
+		"ok, json": {
+			policy: `{"rules": [{"effect": "allow", "resources": ["*"], "methods": ["GET"]}]}`,
+			json:   true,
+		},
+		"error: no rules": {
+			policy: "rules: []",
+			outErr: "invalid policy: rules: cannot be blank.",
+		},
+		"error: unknown field": {
+			policy: `
+rules:
+  - effect: allow
+    resource: ["*"]
+    methods: ["*"]
+`,
+			outErr: "failed to decode policy: yaml: unmarshal errors:\n" +
+				"  line 4: field resource not found in type authz.PolicyRule",
+		},
+		"error: unknown field, json": {
+			policy: `{"rules": [{"effect": "allow", "resource": ["*"], "methods": ["GET"]}]}`,
+			json:   true,
+			outErr: `failed to decode policy: json: unknown field "resource"`,
+		},
+		"error: invalid effect": {
+			policy: `
+rules:
+  - effect: permit
+    resources: ["*"]
+    methods: ["*"]
+`,
+			outErr: "invalid policy: rules: (0: (effect: must be a valid value.).).",
+		},
+		"error: invalid pattern": {
+			policy: `
+rules:
+  - effect: allow
+    resources: ["deployments:["]
+    methods: ["*"]
+`,
+			outErr: "invalid policy: rules: (0: (resources: (0: must be a valid pattern.).).).",
+		},
+		"error: invalid token type": {
+			policy: `
+rules:
+  - effect: allow
+    token_types: [device]
+    resources: ["*"]
+    methods: ["*"]
+`,
+			outErr: "invalid policy: rules: (0: (token_types: (0: must be a valid value.).).).",
+		},
+		"error: no methods": {
+			policy: `
+rules:
+  - effect: allow
+    resources: ["*"]
+`,
+			outErr: "invalid policy: rules: (0: (methods: cannot be blank.).).",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var (
+				policy *Policy
+				err    error
+			)
+			if tc.json {
+				policy, err = ParsePolicyJSON([]byte(tc.policy))
+			} else {
+				policy, err = ParsePolicy([]byte(tc.policy))
+			}
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+				assert.NotNil(t, policy)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+				assert.Nil(t, policy)
+			}
+		})
+	}
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, ioutil.WriteFile(yamlPath, []byte(testPolicy), 0600))
+	policy, err := LoadPolicyFile(yamlPath)
+	assert.NoError(t, err)
+	if assert.NotNil(t, policy) {
+		assert.Len(t, policy.Rules, 4)
+	}
+
+	// JSON files are parsed strictly as JSON
+	jsonPath := filepath.Join(dir, "policy.json")
+	require.NoError(t, ioutil.WriteFile(jsonPath, []byte(testPolicy), 0600))
+	_, err = LoadPolicyFile(jsonPath)
+	assert.Error(t, err)
+
+	_, err = LoadPolicyFile(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestPolicyAuthorizerAuthorize(t *testing.T) {
+	// the subject of the deny rule in testPolicy
+	contractor := oid.NewUUIDv5("contractor")
+	subject := oid.NewUUIDv5("testsubject")
+
+	userToken := func(sub oid.ObjectID) *jwt.Token {
+		return &jwt.Token{
+			Claims: jwt.Claims{
+				Subject: sub,
+				Tenant:  "tenant",
+				User:    true,
+			},
+		}
+	}
+	serviceAccountToken := &jwt.Token{
+		Claims: jwt.Claims{
+			Subject:        subject,
+			Tenant:         "tenant",
+			ServiceAccount: true,
+		},
+	}
+
+	policy, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		token    *jwt.Token
+		resource string
+		action   string
+
+		callDb  bool
+		user    *model.User
+		userErr error
+		groups  []model.Group
+
+		outErr error
+	}{
+		"ok, role": {
+			token:    userToken(subject),
+			resource: "deployments:deployments",
+			action:   "POST",
+			callDb:   true,
+			user:     &model.User{Roles: []string{model.RoleReleaseManager}},
+		},
+		"ok, token type": {
+			token:    serviceAccountToken,
+			resource: "deployments:artifacts",
+			action:   "POST",
+		},
+		"error: deny takes precedence": {
+			token:    userToken(subject),
+			resource: "deployments:artifacts:1234",
+			action:   "DELETE",
+			callDb:   true,
+			user:     &model.User{Roles: []string{model.RoleReleaseManager}},
+			outErr:   ErrAuthzUnauthorized,
+		},
+		"error: subject denied": {
+			token:    userToken(contractor),
+			resource: "deviceconnect:devices:1234:connect",
+			action:   "GET",
+			outErr:   ErrAuthzUnauthorized,
+		},
+		"not applicable: other subject": {
+			token:    userToken(subject),
+			resource: "deviceconnect:devices:1234:connect",
+			action:   "GET",
+			outErr:   ErrAuthzNotApplicable,
+		},
+		"not applicable: other role": {
+			token:    userToken(subject),
+			resource: "deployments:deployments",
+			action:   "POST",
+			callDb:   true,
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+			outErr:   ErrAuthzNotApplicable,
+		},
+		"ok, group role": {
+			token:    userToken(subject),
+			resource: "deployments:deployments",
+			action:   "POST",
+			callDb:   true,
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+			groups: []model.Group{
+				{Name: "release", Roles: []string{model.RoleReleaseManager}},
+			},
+		},
+		"not applicable: user not found": {
+			token:    userToken(subject),
+			resource: "deployments:deployments",
+			action:   "POST",
+			callDb:   true,
+			outErr:   ErrAuthzNotApplicable,
+		},
+		"not applicable: service account roles": {
+			token:    serviceAccountToken,
+			resource: "deployments:deployments",
+			action:   "GET",
+			outErr:   ErrAuthzNotApplicable,
+		},
+		"error: get user": {
+			token:    userToken(subject),
+			resource: "deployments:deployments",
+			action:   "POST",
+			callDb:   true,
+			userErr:  errors.New("db error"),
+			outErr:   errors.New("authz: failed to get user: db error"),
+		},
+		"error: no token": {
+			resource: "deployments:deployments",
+			action:   "POST",
+			outErr:   ErrAuthzUnauthorized,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.callDb {
+				db.On("GetUserById",
+					mock.MatchedBy(func(context.Context) bool { return true }),
+					subject.String()).
+					Return(tc.user, tc.userErr).
+					Once()
+				if tc.user != nil {
+					db.On("GetGroupsByMember",
+						mock.MatchedBy(func(context.Context) bool { return true }),
+						subject.String()).
+						Return(tc.groups, nil).
+						Once()
+					db.On("GetActiveElevations",
+						mock.MatchedBy(func(context.Context) bool { return true }),
+						subject.String()).
+						Return([]model.Elevation{}, nil).
+						Once()
+				}
+			}
+
+			authorizer := NewPolicyAuthorizer(policy, db)
+			err := authorizer.Authorize(context.Background(),
+				tc.token, tc.resource, tc.action)
+
+			if tc.outErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr.Error())
+			}
+		})
+	}
+}
+
+func TestPolicyAuthorizerSetPolicy(t *testing.T) {
+	token := &jwt.Token{Claims: jwt.Claims{User: true}}
+
+	authorizer := NewPolicyAuthorizer(nil, nil)
+	err := authorizer.Authorize(context.Background(), token, "useradm:users", "GET")
+	assert.Equal(t, ErrAuthzNotApplicable, err)
+
+	authorizer.SetPolicy(&Policy{Rules: []PolicyRule{{
+		Effect:    PolicyEffectAllow,
+		Resources: []string{"useradm:*"},
+		Methods:   []string{"GET"},
+	}}})
+	err = authorizer.Authorize(context.Background(), token, "useradm:users", "GET")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,294 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
+)
+
+// RoleStore provides the roles of the users; implemented by
+// store.DataStore.
+type RoleStore interface {
+	// GetUserById returns nil,nil if not found
+	GetUserById(ctx context.Context, id string) (*model.User, error)
+	// GetRolesByNames returns the tenant's custom roles with the given names
+	GetRolesByNames(ctx context.Context, names []string) ([]model.Role, error)
+	// GetGroupsByMember returns the groups the user belongs to
+	GetGroupsByMember(ctx context.Context, userID string) ([]model.Group, error)
+	// GetActiveElevations returns the user's unexpired elevations
+	GetActiveElevations(ctx context.Context, userID string) ([]model.Elevation, error)
+}
+
+// RBACAuthorizer grants the users the permissions of their roles and of
+// the roles of their groups, within the scopes of their tokens.
+type RBACAuthorizer struct {
+	roles RoleStore
+}
+
+// NewRBACAuthorizer returns the role-based authorizer.
+func NewRBACAuthorizer(roles RoleStore) *RBACAuthorizer {
+	return &RBACAuthorizer{
+		roles: roles,
+	}
+}
+
+const rbacAuthorizerName = "rbac"
+
+// Authorize makes RBACAuthorizer implement the Authorizer interface.
+func (a *RBACAuthorizer) Authorize(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) error {
+	decision, err := a.Explain(ctx, token, resource, action)
+	if err != nil {
+		return err
+	}
+	return decision.Err()
+}
+
+// Explain makes RBACAuthorizer implement the Explainer interface.
+func (a *RBACAuthorizer) Explain(
+	ctx context.Context,
+	token *jwt.Token,
+	resource,
+	action string,
+) (*Decision, error) {
+	deny := func(rule, message string) *Decision {
+		return &Decision{
+			Effect: EffectDeny,
+			Reasons: []Reason{{
+				Authorizer: rbacAuthorizerName,
+				Effect:     EffectDeny,
+				Rule:       rule,
+				Message:    message,
+			}},
+		}
+	}
+	if token == nil {
+		return deny("", "no token"), nil
+	}
+
+	// the token's scopes limit what the subject is allowed to do
+	if !scopePermits(token, resource, action) {
+		return deny("scope", fmt.Sprintf(
+			"the token scope %q does not permit the request",
+			token.Claims.Scope)), nil
+	}
+
+	// service accounts are not assigned roles, their access is limited
+	// by the scopes only
+	if token.Claims.ServiceAccount {
+		return &Decision{
+			Effect: EffectAllow,
+			Reasons: []Reason{{
+				Authorizer: rbacAuthorizerName,
+				Effect:     EffectAllow,
+				Rule:       "scope",
+				Message: fmt.Sprintf(
+					"the token scope %q permits the request to the service account",
+					token.Claims.Scope),
+			}},
+		}, nil
+	}
+
+	roles, err := a.getRoles(ctx, token)
+	if err == ErrAuthzUnauthorized {
+		return deny("", "the user does not exist or is disabled"), nil
+	} else if err != nil {
+		return nil, err
+	}
+	decision := &Decision{Effect: EffectDeny}
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+		if role.Permits(resource, action) {
+			decision.Effect = EffectAllow
+			decision.Reasons = append(decision.Reasons, Reason{
+				Authorizer: rbacAuthorizerName,
+				Effect:     EffectAllow,
+				Rule:       "role " + role.Name,
+				Message:    "the role permits the request",
+			})
+		}
+	}
+	// the users manage their own account whatever their roles
+	if model.PermitsSelfService(resource, action) {
+		decision.Effect = EffectAllow
+		decision.Reasons = append(decision.Reasons, Reason{
+			Authorizer: rbacAuthorizerName,
+			Effect:     EffectAllow,
+			Rule:       "self-service",
+			Message:    "the users are permitted the request on their own account",
+		})
+	}
+	if decision.Effect == EffectDeny {
+		return deny("", fmt.Sprintf(
+			"none of the roles %q permits the request", names)), nil
+	}
+	return decision, nil
+}
+
+// scopePermits checks if the token's scope permits the request
+func scopePermits(token *jwt.Token, resource, action string) bool {
+	return token.Claims.Scope == scope.All ||
+		scope.Permits(scope.Parse(token.Claims.Scope), resource, action)
+}
+
+// getRoles returns the roles of the token's subject
+func (a *RBACAuthorizer) getRoles(ctx context.Context, token *jwt.Token) ([]model.Role, error) {
+	ctx = tokenContext(ctx, token)
+	user, err := getUser(ctx, a.roles, token)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, ErrAuthzUnauthorized
+	}
+
+	names, _, err := EffectiveRoles(ctx, a.roles, token, user)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]model.Role, 0, len(names))
+	custom := make([]string, 0, len(names))
+	for _, name := range names {
+		if role := model.BuiltInRole(name); role != nil {
+			roles = append(roles, *role)
+		} else {
+			custom = append(custom, name)
+		}
+	}
+	if len(custom) > 0 {
+		customRoles, err := a.roles.GetRolesByNames(ctx, custom)
+		if err != nil {
+			return nil, errors.Wrap(err, "authz: failed to get roles")
+		}
+		roles = append(roles, customRoles...)
+	}
+	return roles, nil
+}
+
+// tokenContext sets the identity of the token's subject in the context,
+// which selects the tenant's database for the store queries.
+func tokenContext(ctx context.Context, token *jwt.Token) context.Context {
+	return identity.WithContext(ctx, &identity.Identity{
+		Subject: token.Claims.Subject.String(),
+		Tenant:  token.Claims.Tenant,
+		IsUser:  token.Claims.User,
+	})
+}
+
+// getUser returns the user the token was issued to, or nil if the user
+// does not exist (anymore) or is disabled or deleted; ctx must come from
+// tokenContext.
+func getUser(ctx context.Context, roles RoleStore, token *jwt.Token) (*model.User, error) {
+	user, err := roles.GetUserById(ctx, token.Claims.Subject.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "authz: failed to get user")
+	}
+	if user != nil && (user.IsDisabled() || user.IsDeleted()) {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// EffectiveRoles returns the names of the user's own roles together
+// with the roles of the user's groups and active elevations, and the time
+// the first of the elevated roles expires (zero if there are none); ctx
+// must carry the identity of the token's subject.
+func EffectiveRoles(
+	ctx context.Context,
+	roles RoleStore,
+	token *jwt.Token,
+	user *model.User,
+) ([]string, time.Time, error) {
+	return effectiveRoles(ctx, roles, token.Claims.Subject.String(), user)
+}
+
+// UserEffectiveRoles returns the effective roles of the user, see
+// EffectiveRoles, or nil if the user does not exist (anymore) or is
+// disabled or deleted; ctx must carry the tenant of the user.
+func UserEffectiveRoles(
+	ctx context.Context,
+	roles RoleStore,
+	userID string,
+) ([]string, error) {
+	user, err := roles.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "authz: failed to get user")
+	} else if user == nil || user.IsDisabled() || user.IsDeleted() {
+		return nil, nil
+	}
+	names, _, err := effectiveRoles(ctx, roles, userID, user)
+	return names, err
+}
+
+func effectiveRoles(
+	ctx context.Context,
+	roles RoleStore,
+	userID string,
+	user *model.User,
+) ([]string, time.Time, error) {
+	groups, err := roles.GetGroupsByMember(ctx, userID)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "authz: failed to get groups")
+	}
+	elevations, err := roles.GetActiveElevations(ctx, userID)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "authz: failed to get elevations")
+	}
+	if len(groups) == 0 && len(elevations) == 0 {
+		return user.Roles, time.Time{}, nil
+	}
+
+	names := make([]string, 0, len(user.Roles))
+	seen := make(map[string]bool)
+	add := func(roles ...string) {
+		for _, name := range roles {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	add(user.Roles...)
+	for _, group := range groups {
+		add(group.Roles...)
+	}
+	var expires time.Time
+	now := time.Now()
+	for _, elevation := range elevations {
+		// the store filters by expiration, but the role must not
+		// outlive the window in any case
+		if elevation.IsActive(now) {
+			add(elevation.Role)
+			if expires.IsZero() || elevation.ExpiresTs.Before(expires) {
+				expires = *elevation.ExpiresTs
+			}
+		}
+	}
+	return names, expires, nil
+}
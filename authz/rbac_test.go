@@ -0,0 +1,369 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/mendersoftware/useradm/authz"
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+func TestRBACAuthorizerAuthorize(t *testing.T) {
+	subject := oid.NewUUIDv5("testsubject")
+	makeToken := func(tokenScope string) *jwt.Token {
+		return &jwt.Token{
+			Claims: jwt.Claims{
+				Subject: subject,
+				Issuer:  "mender",
+				ExpiresAt: jwt.Time{
+					Time: time.Now().Add(time.Hour),
+				},
+				Tenant: "tenant",
+				Scope:  tokenScope,
+				User:   true,
+			},
+		}
+	}
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Hour)
+	customRole := model.Role{
+		Name: "device-operator",
+		Permissions: []model.Permission{
+			{Resource: "deviceconnect:*", Methods: []string{"*"}},
+		},
+	}
+
+	testCases := map[string]struct {
+		token    *jwt.Token
+		resource string
+		action   string
+
+		user         *model.User
+		userErr      error
+		userNotFound bool
+		inactiveUser *model.User
+
+		groups    []model.Group
+		groupsErr error
+
+		elevations    []model.Elevation
+		elevationsErr error
+
+		customRoles      []string
+		dbCustomRoles    []model.Role
+		dbCustomRolesErr error
+
+		outErr string
+	}{
+		"ok, admin": {
+			token:    makeToken(scope.All),
+			resource: "useradm:some:resource:id",
+			action:   "POST",
+			user:     &model.User{Roles: []string{model.RoleAdmin}},
+		},
+		"ok, read-only": {
+			token:    makeToken(scope.All),
+			resource: "otherservice:some:resource:id",
+			action:   "GET",
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+		},
+		"error: read-only": {
+			token:    makeToken(scope.All),
+			resource: "otherservice:some:resource:id",
+			action:   "POST",
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+			outErr:   "unauthorized",
+		},
+		"ok, custom role": {
+			token:         makeToken(scope.All),
+			resource:      "deviceconnect:devices:1234:connect",
+			action:        "GET",
+			user:          &model.User{Roles: []string{model.RoleReadOnly, "device-operator"}},
+			customRoles:   []string{"device-operator"},
+			dbCustomRoles: []model.Role{customRole},
+		},
+		"ok, custom role write": {
+			token:         makeToken(scope.All),
+			resource:      "deviceconnect:devices:1234:connect",
+			action:        "POST",
+			user:          &model.User{Roles: []string{model.RoleReadOnly, "device-operator"}},
+			customRoles:   []string{"device-operator"},
+			dbCustomRoles: []model.Role{customRole},
+		},
+		"error: custom role removed": {
+			token:         makeToken(scope.All),
+			resource:      "deviceconnect:devices:1234:connect",
+			action:        "POST",
+			user:          &model.User{Roles: []string{"device-operator"}},
+			customRoles:   []string{"device-operator"},
+			dbCustomRoles: []model.Role{},
+			outErr:        "unauthorized",
+		},
+		"ok, group role": {
+			token:    makeToken(scope.All),
+			resource: "deployments:deployments",
+			action:   "POST",
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+			groups: []model.Group{
+				{Name: "release", Roles: []string{model.RoleReleaseManager}},
+			},
+		},
+		"ok, group custom role": {
+			token:    makeToken(scope.All),
+			resource: "deviceconnect:devices:1234:connect",
+			action:   "POST",
+			user:     &model.User{},
+			groups: []model.Group{
+				{Name: "operators", Roles: []string{model.RoleReadOnly, "device-operator"}},
+				{Name: "viewers", Roles: []string{model.RoleReadOnly}},
+			},
+			customRoles:   []string{"device-operator"},
+			dbCustomRoles: []model.Role{customRole},
+		},
+		"error: group roles": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users",
+			action:   "POST",
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+			groups: []model.Group{
+				{Name: "release", Roles: []string{model.RoleReleaseManager}},
+			},
+			outErr: "unauthorized",
+		},
+		"error: get groups": {
+			token:     makeToken(scope.All),
+			resource:  "useradm:users",
+			action:    "GET",
+			user:      &model.User{Roles: []string{model.RoleAdmin}},
+			groupsErr: errors.New("db error"),
+			outErr:    "authz: failed to get groups: db error",
+		},
+		"ok, elevated role": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users:1",
+			action:   "DELETE",
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+			elevations: []model.Elevation{{
+				Role:      model.RoleAdmin,
+				Status:    model.ElevationStatusActive,
+				ExpiresTs: &later,
+			}},
+		},
+		"error: elevation expired": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users:1",
+			action:   "DELETE",
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+			elevations: []model.Elevation{{
+				Role:      model.RoleAdmin,
+				Status:    model.ElevationStatusActive,
+				ExpiresTs: &earlier,
+			}},
+			outErr: "unauthorized",
+		},
+		"error: get elevations": {
+			token:         makeToken(scope.All),
+			resource:      "useradm:users",
+			action:        "GET",
+			user:          &model.User{Roles: []string{model.RoleAdmin}},
+			elevationsErr: errors.New("db error"),
+			outErr:        "authz: failed to get elevations: db error",
+		},
+		"error: no roles": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users",
+			action:   "GET",
+			user:     &model.User{},
+			outErr:   "unauthorized",
+		},
+		"ok, self-service without roles": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users:me",
+			action:   "PUT",
+			user:     &model.User{},
+		},
+		"ok, self-service with custom role": {
+			token:         makeToken(scope.All),
+			resource:      "useradm:settings:tokens",
+			action:        "POST",
+			user:          &model.User{Roles: []string{"device-operator"}},
+			customRoles:   []string{"device-operator"},
+			dbCustomRoles: []model.Role{customRole},
+		},
+		"ok, logout with custom role": {
+			token:         makeToken(scope.All),
+			resource:      "useradm:auth:logout",
+			action:        "POST",
+			user:          &model.User{Roles: []string{"device-operator"}},
+			customRoles:   []string{"device-operator"},
+			dbCustomRoles: []model.Role{customRole},
+		},
+		"error: self-service on another user": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users:1234",
+			action:   "PUT",
+			user:     &model.User{},
+			outErr:   "unauthorized",
+		},
+		"error: self-service, scoped token": {
+			token:    makeToken(scope.DevicesRead),
+			resource: "useradm:users:me",
+			action:   "PUT",
+			outErr:   "unauthorized",
+		},
+		"ok, scoped token": {
+			token:    makeToken(scope.DevicesWrite + " " + scope.DeploymentsRead),
+			resource: "deployments:deployments",
+			action:   "GET",
+			user:     &model.User{Roles: []string{model.RoleAdmin}},
+		},
+		"error: scoped token, read-only scope": {
+			token:    makeToken(scope.DevicesWrite + " " + scope.DeploymentsRead),
+			resource: "deployments:deployments",
+			action:   "POST",
+			outErr:   "unauthorized",
+		},
+		"error: scoped token exceeds the role": {
+			token:    makeToken(scope.DeploymentsWrite),
+			resource: "deployments:deployments",
+			action:   "POST",
+			user:     &model.User{Roles: []string{model.RoleReadOnly}},
+			outErr:   "unauthorized",
+		},
+		"error: unknown/incompatible scope": {
+			token:    makeToken("foobar"),
+			resource: "useradm:some:resource:id",
+			action:   "POST",
+			outErr:   "unauthorized",
+		},
+		"error: initial user creation scope": {
+			token:    makeToken(scope.InitialUserCreate),
+			resource: "useradm:users:initial",
+			action:   "POST",
+			outErr:   "unauthorized",
+		},
+		"ok, service account": {
+			token: func() *jwt.Token {
+				token := makeToken(scope.All)
+				token.Claims.User = false
+				token.Claims.ServiceAccount = true
+				return token
+			}(),
+			resource: "deployments:deployments",
+			action:   "POST",
+		},
+		"error: no token": {
+			resource: "useradm:users",
+			action:   "GET",
+			outErr:   "unauthorized",
+		},
+		"error: user not found": {
+			token:        makeToken(scope.All),
+			resource:     "useradm:users",
+			action:       "GET",
+			userNotFound: true,
+			outErr:       "unauthorized",
+		},
+		"error: user disabled": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users",
+			action:   "GET",
+			inactiveUser: &model.User{
+				Roles:  []string{model.RoleAdmin},
+				Status: model.UserStatusDisabled,
+			},
+			outErr: "unauthorized",
+		},
+		"error: user deleted": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users",
+			action:   "GET",
+			inactiveUser: &model.User{
+				Roles:     []string{model.RoleAdmin},
+				DeletedTs: &earlier,
+			},
+			outErr: "unauthorized",
+		},
+		"error: get user": {
+			token:    makeToken(scope.All),
+			resource: "useradm:users",
+			action:   "GET",
+			userErr:  errors.New("db error"),
+			outErr:   "authz: failed to get user: db error",
+		},
+		"error: get roles": {
+			token:            makeToken(scope.All),
+			resource:         "useradm:users",
+			action:           "GET",
+			user:             &model.User{Roles: []string{"device-operator"}},
+			customRoles:      []string{"device-operator"},
+			dbCustomRolesErr: errors.New("db error"),
+			outErr:           "authz: failed to get roles: db error",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tenantCtx := mock.MatchedBy(func(ctx context.Context) bool {
+				id := identity.FromContext(ctx)
+				return id != nil && id.Tenant == "tenant"
+			})
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.inactiveUser != nil {
+				db.On("GetUserById", tenantCtx, subject.String()).
+					Return(tc.inactiveUser, nil)
+			} else if tc.user != nil || tc.userErr != nil || tc.userNotFound {
+				db.On("GetUserById", tenantCtx, subject.String()).
+					Return(tc.user, tc.userErr)
+			}
+			if tc.user != nil {
+				db.On("GetGroupsByMember", tenantCtx, subject.String()).
+					Return(tc.groups, tc.groupsErr)
+			}
+			if tc.user != nil && tc.groupsErr == nil {
+				db.On("GetActiveElevations", tenantCtx, subject.String()).
+					Return(tc.elevations, tc.elevationsErr)
+			}
+			if tc.customRoles != nil {
+				db.On("GetRolesByNames", tenantCtx, tc.customRoles).
+					Return(tc.dbCustomRoles, tc.dbCustomRolesErr)
+			}
+
+			authorizer := NewRBACAuthorizer(db)
+			err := authorizer.Authorize(context.Background(),
+				tc.token, tc.resource, tc.action)
+
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
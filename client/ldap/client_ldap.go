@@ -0,0 +1,356 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+)
+
+const (
+	DefaultUserFilter           = "(objectClass=person)"
+	DefaultEmailAttribute       = "mail"
+	DefaultNameAttribute        = "displayName"
+	DefaultGroupMemberAttribute = "member"
+	// default request timeout, 10s
+	defaultReqTimeout = time.Duration(10) * time.Second
+)
+
+var (
+	ErrMissingURL    = errors.New("LDAP server URL is required")
+	ErrInvalidURL    = errors.New("LDAP server URL must use the ldap or ldaps scheme")
+	ErrMissingBaseDN = errors.New("LDAP base DN is required")
+	ErrStartTLSLDAPS = errors.New("StartTLS cannot be used with an ldaps URL")
+)
+
+// Config conveys the LDAP backend configuration
+type Config struct {
+	// LDAP server URL, ldap://host:port or ldaps://host:port
+	URL string
+	// upgrade plain ldap:// connections with StartTLS
+	StartTLS bool
+	// TLS settings used for ldaps:// and StartTLS
+	TLSConfig *tls.Config
+
+	// service account used to look up the users, anonymous if empty
+	BindDN       string
+	BindPassword string
+
+	// subtree holding the user and group entries
+	BaseDN string
+	// filter selecting the user entries
+	UserFilter string
+	// attribute holding the email address of the users
+	EmailAttribute string
+	// attribute holding the display name of the users
+	NameAttribute string
+
+	// filter selecting the groups whose members may log in;
+	// any user matching UserFilter may log in if empty
+	GroupFilter string
+	// group attribute listing the DNs of the members
+	GroupMemberAttribute string
+
+	// user attribute holding the values mapped to useradm roles;
+	// the roles are not managed by LDAP if empty
+	RolesAttribute string
+	// maps the values of RolesAttribute (case insensitive) to role
+	// names, the values are used as role names if empty
+	RoleMapping map[string]string
+
+	// request timeout
+	Timeout time.Duration
+}
+
+// Validate checks the configuration and fills in the defaults.
+func (conf *Config) Validate() error {
+	if conf.URL == "" {
+		return ErrMissingURL
+	}
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return errors.Wrap(err, "invalid LDAP server URL")
+	}
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		if conf.StartTLS {
+			return ErrStartTLSLDAPS
+		}
+	default:
+		return ErrInvalidURL
+	}
+	if conf.BaseDN == "" {
+		return ErrMissingBaseDN
+	}
+	if conf.UserFilter == "" {
+		conf.UserFilter = DefaultUserFilter
+	}
+	if conf.EmailAttribute == "" {
+		conf.EmailAttribute = DefaultEmailAttribute
+	}
+	if conf.NameAttribute == "" {
+		conf.NameAttribute = DefaultNameAttribute
+	}
+	if conf.GroupMemberAttribute == "" {
+		conf.GroupMemberAttribute = DefaultGroupMemberAttribute
+	}
+	if conf.Timeout == 0 {
+		conf.Timeout = defaultReqTimeout
+	}
+	if len(conf.RoleMapping) > 0 {
+		mapping := make(map[string]string, len(conf.RoleMapping))
+		for value, role := range conf.RoleMapping {
+			mapping[strings.ToLower(value)] = role
+		}
+		conf.RoleMapping = mapping
+	}
+	return nil
+}
+
+// Client is an LDAP / Active Directory credential checker using
+// search-then-bind: the user entry is looked up by email address with
+// the service account, then the user's password is verified by binding
+// as the user's DN.
+type Client struct {
+	mutex sync.RWMutex
+	conf  Config
+}
+
+func NewClient(conf Config) (*Client, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return &Client{
+		conf: conf,
+	}, nil
+}
+
+// SetConfig replaces the client configuration; logins already in
+// flight keep using the previous configuration.
+func (c *Client) SetConfig(conf Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.conf = conf
+	return nil
+}
+
+func (c *Client) config() Config {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conf
+}
+
+// CheckCredentials verifies the email/password pair against the
+// directory. It returns the email address, the name and, if
+// RolesAttribute is configured, the roles of the user; nil if the
+// credentials are invalid or the user is not allowed to log in, and
+// model.ErrCredentialsNotFound if no entry matches the email address.
+func (c *Client) CheckCredentials(
+	ctx context.Context,
+	email model.Email,
+	password string,
+	local *model.User,
+) (*model.User, error) {
+	conf := c.config()
+	l := log.FromContext(ctx)
+
+	// an empty password results in an unauthenticated bind,
+	// which most servers accept
+	if email == "" || password == "" {
+		return nil, nil
+	}
+
+	conn, err := dial(&conf)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err = bindServiceAccount(conn, &conf); err != nil {
+		return nil, err
+	}
+
+	attributes := []string{conf.EmailAttribute, conf.NameAttribute}
+	if conf.RolesAttribute != "" {
+		attributes = append(attributes, conf.RolesAttribute)
+	}
+	res, err := conn.Search(ldapv3.NewSearchRequest(
+		conf.BaseDN,
+		ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases,
+		2, int(conf.Timeout/time.Second), false,
+		fmt.Sprintf("(&%s(%s=%s))",
+			conf.UserFilter,
+			conf.EmailAttribute,
+			ldapv3.EscapeFilter(string(email)),
+		),
+		attributes,
+		nil,
+	))
+	if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultSizeLimitExceeded) {
+		l.Warnf("LDAP login: multiple entries match the email address")
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to search the user entry")
+	}
+	switch len(res.Entries) {
+	case 1:
+	case 0:
+		return nil, model.ErrCredentialsNotFound
+	default:
+		l.Warnf("LDAP login: multiple entries match the email address")
+		return nil, nil
+	}
+	entry := res.Entries[0]
+
+	err = conn.Bind(entry.DN, password)
+	if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultInvalidCredentials) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to bind as the user")
+	}
+
+	if conf.GroupFilter != "" {
+		// the user may not be allowed to read the groups
+		if err = bindServiceAccount(conn, &conf); err != nil {
+			return nil, err
+		}
+		member, err := isGroupMember(conn, &conf, entry.DN)
+		if err != nil {
+			return nil, err
+		} else if !member {
+			l.Infof("LDAP login: %s is not a member of the allowed groups",
+				entry.DN)
+			return nil, nil
+		}
+	}
+
+	user := &model.User{
+		Email: model.Email(strings.ToLower(
+			entry.GetAttributeValue(conf.EmailAttribute),
+		)),
+	}
+	if user.Email == "" {
+		user.Email = email
+	}
+	user.Name = entry.GetAttributeValue(conf.NameAttribute)
+	if conf.RolesAttribute != "" {
+		user.Roles = mapRoles(&conf, entry.GetAttributeValues(conf.RolesAttribute))
+		if len(user.Roles) == 0 {
+			l.Infof("LDAP login: no role is assigned to %s", entry.DN)
+			return nil, nil
+		}
+	}
+	return user, nil
+}
+
+func dial(conf *Config) (*ldapv3.Conn, error) {
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid LDAP server URL")
+	}
+	tlsConfig := &tls.Config{}
+	if conf.TLSConfig != nil {
+		tlsConfig = conf.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldapv3.DialURL(conf.URL,
+		ldapv3.DialWithDialer(&net.Dialer{Timeout: conf.Timeout}),
+		ldapv3.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the LDAP server")
+	}
+	conn.SetTimeout(conf.Timeout)
+
+	if conf.StartTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "failed to start TLS")
+		}
+	}
+	return conn, nil
+}
+
+func bindServiceAccount(conn *ldapv3.Conn, conf *Config) error {
+	var err error
+	if conf.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(conf.BindDN, conf.BindPassword)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to bind the service account")
+	}
+	return nil
+}
+
+func isGroupMember(conn *ldapv3.Conn, conf *Config, dn string) (bool, error) {
+	res, err := conn.Search(ldapv3.NewSearchRequest(
+		conf.BaseDN,
+		ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases,
+		0, int(conf.Timeout/time.Second), false,
+		fmt.Sprintf("(&%s(%s=%s))",
+			conf.GroupFilter,
+			conf.GroupMemberAttribute,
+			ldapv3.EscapeFilter(dn),
+		),
+		[]string{"dn"},
+		nil,
+	))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to search the user's groups")
+	}
+	return len(res.Entries) > 0, nil
+}
+
+func mapRoles(conf *Config, values []string) []string {
+	roles := make([]string, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		role := value
+		if len(conf.RoleMapping) > 0 {
+			var ok bool
+			role, ok = conf.RoleMapping[strings.ToLower(value)]
+			if !ok {
+				continue
+			}
+		}
+		if _, ok := seen[role]; ok {
+			continue
+		}
+		seen[role] = struct{}{}
+		roles = append(roles, role)
+	}
+	return roles
+}
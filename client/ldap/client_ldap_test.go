@@ -0,0 +1,326 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mendersoftware/useradm/model"
+	mt "github.com/mendersoftware/useradm/utils/testing"
+)
+
+var ldapTestEntries = []mt.LDAPEntry{{
+	DN:       "cn=useradm,ou=services,dc=example,dc=org",
+	Password: "service-secret",
+	Attributes: map[string][]string{
+		"objectClass": {"organizationalRole"},
+	},
+}, {
+	DN:       "uid=alice,ou=people,dc=example,dc=org",
+	Password: "alice-secret",
+	Attributes: map[string][]string{
+		"objectClass":  {"person", "inetOrgPerson"},
+		"mail":         {"Alice@Example.org"},
+		"displayName":  {"Alice Liddell"},
+		"businessRole": {"Admins", "unknown"},
+	},
+}, {
+	DN:       "uid=bob,ou=people,dc=example,dc=org",
+	Password: "bob-secret",
+	Attributes: map[string][]string{
+		"objectClass": {"person"},
+		"mail":        {"bob@example.org"},
+	},
+}, {
+	DN:       "uid=bob2,ou=people,dc=example,dc=org",
+	Password: "bob-secret",
+	Attributes: map[string][]string{
+		"objectClass": {"person"},
+		"mail":        {"bob@example.org"},
+	},
+}, {
+	DN: "cn=mender,ou=groups,dc=example,dc=org",
+	Attributes: map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"cn":          {"mender"},
+		"member":      {"uid=alice,ou=people,dc=example,dc=org"},
+	},
+}}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Name   string
+		Config Config
+		Error  error
+	}{{
+		Name: "ok",
+		Config: Config{
+			URL:    "ldap://localhost",
+			BaseDN: "dc=example,dc=org",
+		},
+	}, {
+		Name:   "error, missing URL",
+		Config: Config{BaseDN: "dc=example,dc=org"},
+		Error:  ErrMissingURL,
+	}, {
+		Name: "error, invalid scheme",
+		Config: Config{
+			URL:    "http://localhost",
+			BaseDN: "dc=example,dc=org",
+		},
+		Error: ErrInvalidURL,
+	}, {
+		Name:   "error, missing base DN",
+		Config: Config{URL: "ldaps://localhost"},
+		Error:  ErrMissingBaseDN,
+	}, {
+		Name: "error, StartTLS over ldaps",
+		Config: Config{
+			URL:      "ldaps://localhost",
+			BaseDN:   "dc=example,dc=org",
+			StartTLS: true,
+		},
+		Error: ErrStartTLSLDAPS,
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Config.Validate()
+			assert.Equal(t, tc.Error, err)
+			if tc.Error == nil {
+				assert.Equal(t, DefaultUserFilter, tc.Config.UserFilter)
+				assert.Equal(t, DefaultEmailAttribute, tc.Config.EmailAttribute)
+				assert.Equal(t, DefaultGroupMemberAttribute,
+					tc.Config.GroupMemberAttribute)
+				assert.NotZero(t, tc.Config.Timeout)
+			}
+		})
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	t.Parallel()
+
+	ldapServer, err := mt.NewLDAPServer(ldapTestEntries...)
+	require.NoError(t, err)
+	defer ldapServer.Close()
+	ldapsServer, err := mt.NewLDAPSServer(ldapTestEntries...)
+	require.NoError(t, err)
+	defer ldapsServer.Close()
+
+	baseConfig := func(url string) Config {
+		return Config{
+			URL:          url,
+			TLSConfig:    &tls.Config{RootCAs: ldapServer.CertPool()},
+			BindDN:       "cn=useradm,ou=services,dc=example,dc=org",
+			BindPassword: "service-secret",
+			BaseDN:       "ou=people,dc=example,dc=org",
+		}
+	}
+
+	testCases := []struct {
+		Name string
+
+		Config   Config
+		Email    model.Email
+		Password string
+
+		User  *model.User
+		Error string
+	}{{
+		Name:     "ok",
+		Config:   baseConfig(ldapServer.URL()),
+		Email:    "alice@example.org",
+		Password: "alice-secret",
+		User: &model.User{
+			Email: "alice@example.org",
+			Name:  "Alice Liddell",
+		},
+	}, {
+		Name: "ok, StartTLS",
+		Config: func() Config {
+			conf := baseConfig(ldapServer.URL())
+			conf.StartTLS = true
+			return conf
+		}(),
+		Email:    "alice@example.org",
+		Password: "alice-secret",
+		User: &model.User{
+			Email: "alice@example.org",
+			Name:  "Alice Liddell",
+		},
+	}, {
+		Name: "ok, LDAPS",
+		Config: func() Config {
+			conf := baseConfig(ldapsServer.URL())
+			conf.TLSConfig = &tls.Config{RootCAs: ldapsServer.CertPool()}
+			return conf
+		}(),
+		Email:    "alice@example.org",
+		Password: "alice-secret",
+		User: &model.User{
+			Email: "alice@example.org",
+			Name:  "Alice Liddell",
+		},
+	}, {
+		Name: "ok, group member with mapped roles",
+		Config: func() Config {
+			conf := baseConfig(ldapServer.URL())
+			conf.BaseDN = "dc=example,dc=org"
+			conf.GroupFilter = "(&(objectClass=groupOfNames)(cn=mender))"
+			conf.RolesAttribute = "businessRole"
+			conf.RoleMapping = map[string]string{
+				"ADMINS": "admin",
+			}
+			return conf
+		}(),
+		Email:    "alice@example.org",
+		Password: "alice-secret",
+		User: &model.User{
+			Email: "alice@example.org",
+			Name:  "Alice Liddell",
+			Roles: []string{"admin"},
+		},
+	}, {
+		Name: "ok, roles without mapping",
+		Config: func() Config {
+			conf := baseConfig(ldapServer.URL())
+			conf.RolesAttribute = "businessRole"
+			return conf
+		}(),
+		Email:    "alice@example.org",
+		Password: "alice-secret",
+		User: &model.User{
+			Email: "alice@example.org",
+			Name:  "Alice Liddell",
+			Roles: []string{"Admins", "unknown"},
+		},
+	}, {
+		Name: "denied, no mapped role",
+		Config: func() Config {
+			conf := baseConfig(ldapServer.URL())
+			conf.RolesAttribute = "businessRole"
+			conf.RoleMapping = map[string]string{"operators": "read-only"}
+			return conf
+		}(),
+		Email:    "alice@example.org",
+		Password: "alice-secret",
+	}, {
+		Name: "denied, not a group member",
+		Config: func() Config {
+			conf := baseConfig(ldapServer.URL())
+			conf.BaseDN = "dc=example,dc=org"
+			conf.GroupFilter = "(objectClass=groupOfNames)"
+			return conf
+		}(),
+		Email:    "bob@example.org",
+		Password: "bob-secret",
+	}, {
+		Name:     "denied, wrong password",
+		Config:   baseConfig(ldapServer.URL()),
+		Email:    "alice@example.org",
+		Password: "wrong",
+	}, {
+		Name:   "denied, empty password",
+		Config: baseConfig(ldapServer.URL()),
+		Email:  "alice@example.org",
+	}, {
+		Name:     "not found, unknown user",
+		Config:   baseConfig(ldapServer.URL()),
+		Email:    "eve@example.org",
+		Password: "alice-secret",
+		Error:    model.ErrCredentialsNotFound.Error(),
+	}, {
+		Name:     "denied, ambiguous email",
+		Config:   baseConfig(ldapServer.URL()),
+		Email:    "bob@example.org",
+		Password: "bob-secret",
+	}, {
+		Name: "not found, filtered out",
+		Config: func() Config {
+			conf := baseConfig(ldapServer.URL())
+			conf.UserFilter = "(objectClass=inetOrgPerson)"
+			return conf
+		}(),
+		Email:    "bob@example.org",
+		Password: "bob-secret",
+		Error:    model.ErrCredentialsNotFound.Error(),
+	}, {
+		Name: "error, service account",
+		Config: func() Config {
+			conf := baseConfig(ldapServer.URL())
+			conf.BindPassword = "wrong"
+			return conf
+		}(),
+		Email:    "alice@example.org",
+		Password: "alice-secret",
+		Error:    "failed to bind the service account",
+	}, {
+		Name: "error, untrusted certificate",
+		Config: func() Config {
+			conf := baseConfig(ldapsServer.URL())
+			conf.TLSConfig = nil
+			return conf
+		}(),
+		Email:    "alice@example.org",
+		Password: "alice-secret",
+		Error:    "failed to connect to the LDAP server",
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			client, err := NewClient(tc.Config)
+			require.NoError(t, err)
+
+			user, err := client.CheckCredentials(
+				context.Background(), tc.Email, tc.Password, nil,
+			)
+			if tc.Error != "" {
+				assert.Contains(t, err.Error(), tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.User, user)
+		})
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(Config{
+		URL:    "ldap://localhost",
+		BaseDN: "dc=example,dc=org",
+	})
+	require.NoError(t, err)
+
+	err = client.SetConfig(Config{URL: "ldap://localhost"})
+	assert.Equal(t, ErrMissingBaseDN, err)
+	assert.Equal(t, "dc=example,dc=org", client.config().BaseDN)
+
+	err = client.SetConfig(Config{
+		URL:    "ldaps://ldap.example.org",
+		BaseDN: "ou=people,dc=example,dc=org",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ldaps://ldap.example.org", client.config().URL)
+}
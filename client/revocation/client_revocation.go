@@ -0,0 +1,225 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package revocation keeps the list of the tokens revoked by useradm in
+// sync, for the services verifying the tokens locally with the public
+// key: a token deleted before its expiration (e.g. on logout) is still
+// correctly signed and must be checked against the list.
+package revocation
+
+import (
+	"context"
+	"crypto/rsa"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+const (
+	URIRevocations = "/api/internal/v1/useradm/tokens/revocations"
+	// default request timeout, 10s
+	defaultReqTimeout = time.Duration(10) * time.Second
+	// the list is a few bytes per revoked token
+	maxListSize = 64 * 1024 * 1024
+)
+
+var (
+	ErrTokenRevoked     = errors.New("revocation: token revoked")
+	ErrNotSynced        = errors.New("revocation: list not synced")
+	ErrMissingPublicKey = errors.New("revocation: public key is required")
+	ErrListReplayed     = errors.New("revocation: list older than the current one")
+	ErrUnexpectedDelta  = errors.New("revocation: delta to an unexpected version")
+)
+
+// Config conveys the client configuration
+type Config struct {
+	// useradm service address
+	UseradmAddr string
+	// PublicKeys verify the signature of the list: the keys verifying
+	// the tokens
+	PublicKeys []*rsa.PublicKey
+	// request timeout
+	Timeout time.Duration
+	// HTTPClient sends the requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client keeps a copy of the revocation list, updated with the deltas
+// since the version last synced. It is safe for concurrent use.
+type Client struct {
+	conf Config
+
+	mutex    sync.RWMutex
+	synced   bool
+	version  int64
+	issuedAt time.Time
+	revoked  map[string]time.Time
+}
+
+func NewClient(conf Config) (*Client, error) {
+	if len(conf.PublicKeys) == 0 {
+		return nil, ErrMissingPublicKey
+	}
+	if conf.Timeout == 0 {
+		conf.Timeout = defaultReqTimeout
+	}
+	if conf.HTTPClient == nil {
+		conf.HTTPClient = http.DefaultClient
+	}
+	return &Client{
+		conf:    conf,
+		revoked: map[string]time.Time{},
+	}, nil
+}
+
+// Sync fetches the revocations since the last sync, or the full list on
+// the first sync, and applies them.
+func (c *Client) Sync(ctx context.Context) error {
+	c.mutex.RLock()
+	since := c.version
+	c.mutex.RUnlock()
+
+	list, err := c.fetch(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if list.IssuedAt.Before(c.issuedAt) {
+		return ErrListReplayed
+	}
+	switch list.Since {
+	case 0:
+		// the full list
+		c.revoked = make(map[string]time.Time, len(list.Revoked))
+	case c.version:
+	default:
+		// synced concurrently
+		return ErrUnexpectedDelta
+	}
+	now := time.Now()
+	for _, r := range list.Revoked {
+		if r.ExpiresAt.After(now) {
+			c.revoked[r.ID.String()] = r.ExpiresAt.Time
+		}
+	}
+	for id, exp := range c.revoked {
+		if !exp.After(now) {
+			delete(c.revoked, id)
+		}
+	}
+	c.version = list.Version
+	c.issuedAt = list.IssuedAt.Time
+	c.synced = true
+	return nil
+}
+
+func (c *Client) fetch(ctx context.Context, since int64) (*jwt.RevocationList, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+	defer cancel()
+
+	uri := strings.TrimRight(c.conf.UseradmAddr, "/") + URIRevocations
+	if since > 0 {
+		uri += "?since=" + strconv.FormatInt(since, 10)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "revocation: failed to prepare request")
+	}
+	rsp, err := c.conf.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "revocation: GET request failed")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(
+			"revocation: GET request failed with unexpected status %v",
+			rsp.StatusCode,
+		)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(rsp.Body, maxListSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "revocation: failed to read the list")
+	}
+	list, err := jwt.ParseRevocationList(string(body), c.conf.PublicKeys...)
+	if err != nil {
+		return nil, errors.Wrap(err, "revocation: invalid list")
+	}
+	if list.Since != 0 && list.Since != since {
+		return nil, ErrUnexpectedDelta
+	}
+	return list, nil
+}
+
+// Run syncs the list every interval until the context is canceled; the
+// errors are passed to onError (if not nil), the list is synced again at
+// the next interval.
+func (c *Client) Run(
+	ctx context.Context,
+	interval time.Duration,
+	onError func(error),
+) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Sync(ctx); err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Version returns the version of the list and the time it was issued at;
+// the caller decides how stale a list it accepts.
+func (c *Client) Version() (int64, time.Time) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.version, c.issuedAt
+}
+
+// IsRevoked tells whether the token with the given ID (jti) is revoked.
+func (c *Client) IsRevoked(tokenID string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	_, revoked := c.revoked[tokenID]
+	return revoked
+}
+
+// Check returns ErrTokenRevoked if the token is revoked, ErrNotSynced if
+// the list was never synced.
+func (c *Client) Check(token *jwt.Token) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if !c.synced {
+		return ErrNotSynced
+	}
+	if _, revoked := c.revoked[token.Claims.ID.String()]; revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
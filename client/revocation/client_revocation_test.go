@@ -0,0 +1,226 @@
+// Copyright 2020 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package revocation
+
+import (
+	"context"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/keys"
+)
+
+func loadKey(t *testing.T, path string) *rsa.PrivateKey {
+	key, err := keys.LoadRSAPrivate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// serveLists serves the lists keyed by the "since" query parameter
+func serveLists(
+	t *testing.T,
+	key *rsa.PrivateKey,
+	lists map[string]*jwt.RevocationList,
+) *httptest.Server {
+	handler := jwt.NewJWTHandlerRS256(key, nil)
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, URIRevocations, r.URL.Path)
+			list, ok := lists[r.URL.Query().Get("since")]
+			if !ok {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			signed, err := handler.SignRevocationList(context.Background(), list)
+			assert.NoError(t, err)
+			w.Header().Set("Content-Type", "application/jwt")
+			_, _ = w.Write([]byte(signed))
+		},
+	))
+}
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewClient(Config{UseradmAddr: "http://useradm:8080"})
+	assert.Equal(t, ErrMissingPublicKey, err)
+	assert.Nil(t, c)
+}
+
+func TestClientSync(t *testing.T) {
+	t.Parallel()
+
+	key := loadKey(t, "../../crypto/private.pem")
+	now := time.Now()
+	exp := jwt.Time{Time: now.Add(time.Hour)}
+	tokenA := oid.NewUUIDv5("token-a")
+	tokenB := oid.NewUUIDv5("token-b")
+	tokenC := oid.NewUUIDv5("token-c")
+	tokenD := oid.NewUUIDv5("token-d")
+
+	srv := serveLists(t, key, map[string]*jwt.RevocationList{
+		"": {
+			IssuedAt: jwt.Time{Time: now},
+			Version:  2,
+			Revoked: []jwt.RevokedToken{
+				{ID: tokenA, ExpiresAt: exp},
+				{ID: tokenB, ExpiresAt: jwt.Time{Time: now.Add(-time.Minute)}},
+			},
+		},
+		"2": {
+			IssuedAt: jwt.Time{Time: now.Add(time.Second)},
+			Version:  3,
+			Since:    2,
+			Revoked:  []jwt.RevokedToken{{ID: tokenC, ExpiresAt: exp}},
+		},
+		// the version is not known anymore: full list
+		"3": {
+			IssuedAt: jwt.Time{Time: now.Add(2 * time.Second)},
+			Version:  1,
+			Revoked:  []jwt.RevokedToken{{ID: tokenD, ExpiresAt: exp}},
+		},
+	})
+	defer srv.Close()
+
+	c, err := NewClient(Config{
+		UseradmAddr: srv.URL,
+		PublicKeys:  []*rsa.PublicKey{&key.PublicKey},
+	})
+	assert.NoError(t, err)
+
+	tokenAJWT := &jwt.Token{Claims: jwt.Claims{ID: tokenA}}
+	assert.Equal(t, ErrNotSynced, c.Check(tokenAJWT))
+
+	ctx := context.Background()
+	assert.NoError(t, c.Sync(ctx))
+	version, _ := c.Version()
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, ErrTokenRevoked, c.Check(tokenAJWT))
+	assert.False(t, c.IsRevoked(tokenB.String()))
+
+	assert.NoError(t, c.Sync(ctx))
+	version, _ = c.Version()
+	assert.Equal(t, int64(3), version)
+	assert.True(t, c.IsRevoked(tokenA.String()))
+	assert.True(t, c.IsRevoked(tokenC.String()))
+
+	assert.NoError(t, c.Sync(ctx))
+	version, issuedAt := c.Version()
+	assert.Equal(t, int64(1), version)
+	assert.Equal(t, now.Add(2*time.Second).Unix(), issuedAt.Unix())
+	assert.NoError(t, c.Check(tokenAJWT))
+	assert.False(t, c.IsRevoked(tokenC.String()))
+	assert.True(t, c.IsRevoked(tokenD.String()))
+}
+
+func TestClientSyncError(t *testing.T) {
+	t.Parallel()
+
+	key := loadKey(t, "../../crypto/private.pem")
+	altKey := loadKey(t, "../../crypto/private_alternative.pem")
+	now := time.Now()
+
+	testCases := map[string]struct {
+		key   *rsa.PrivateKey
+		lists map[string]*jwt.RevocationList
+
+		err string
+	}{
+		"error, unknown key": {
+			key: altKey,
+			lists: map[string]*jwt.RevocationList{
+				"": {IssuedAt: jwt.Time{Time: now}},
+			},
+
+			err: "revocation: invalid list: jwt: token invalid",
+		},
+		"error, status": {
+			key: key,
+
+			err: "revocation: GET request failed with unexpected status 500",
+		},
+		"error, unexpected delta": {
+			key: key,
+			lists: map[string]*jwt.RevocationList{
+				"": {IssuedAt: jwt.Time{Time: now}, Version: 5, Since: 4},
+			},
+
+			err: ErrUnexpectedDelta.Error(),
+		},
+		"error, replayed": {
+			key: key,
+			lists: map[string]*jwt.RevocationList{
+				"":  {IssuedAt: jwt.Time{Time: now}, Version: 1},
+				"1": {IssuedAt: jwt.Time{Time: now.Add(-time.Hour)}, Version: 1},
+			},
+
+			err: ErrListReplayed.Error(),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := serveLists(t, tc.key, tc.lists)
+			defer srv.Close()
+
+			c, err := NewClient(Config{
+				UseradmAddr: srv.URL,
+				PublicKeys:  []*rsa.PublicKey{&key.PublicKey},
+			})
+			assert.NoError(t, err)
+
+			ctx := context.Background()
+			if _, ok := tc.lists["1"]; ok {
+				assert.NoError(t, c.Sync(ctx))
+			}
+			assert.EqualError(t, c.Sync(ctx), tc.err)
+		})
+	}
+}
+
+func TestClientRun(t *testing.T) {
+	t.Parallel()
+
+	key := loadKey(t, "../../crypto/private.pem")
+	srv := serveLists(t, key, map[string]*jwt.RevocationList{})
+	defer srv.Close()
+
+	c, err := NewClient(Config{
+		UseradmAddr: srv.URL,
+		PublicKeys:  []*rsa.PublicKey{&key.PublicKey},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := 0
+	err = c.Run(ctx, time.Millisecond, func(err error) {
+		if errs++; errs == 3 {
+			cancel()
+		}
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 3, errs)
+}
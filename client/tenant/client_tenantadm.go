@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mendersoftware/go-lib-micro/apiclient"
@@ -72,7 +73,8 @@ type ClientRunner interface {
 // Client is an opaque implementation of tenantadm api client.
 // Implements ClientRunner interface
 type Client struct {
-	conf Config
+	mutex sync.RWMutex
+	conf  Config
 }
 
 // Tenant is the tenantadm's api struct
@@ -91,7 +93,9 @@ type User struct {
 
 // UserUpdate is the tenantadm's api struct
 type UserUpdate struct {
-	Name string `json:"name"`
+	Name string `json:"name,omitempty"`
+	// Status is the status of the user account, "active" or "disabled"
+	Status string `json:"status,omitempty"`
 }
 
 func NewClient(conf Config) *Client {
@@ -104,7 +108,25 @@ func NewClient(conf Config) *Client {
 	}
 }
 
+// SetConfig replaces the client configuration; requests already in
+// flight keep using the previous configuration.
+func (c *Client) SetConfig(conf Config) {
+	if conf.Timeout == 0 {
+		conf.Timeout = defaultReqTimeout
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.conf = conf
+}
+
+func (c *Client) config() Config {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conf
+}
+
 func (c *Client) CheckHealth(ctx context.Context) error {
+	conf := c.config()
 	var (
 		client http.Client
 		apiErr rest_utils.ApiError
@@ -115,13 +137,13 @@ func (c *Client) CheckHealth(ctx context.Context) error {
 		ctx = context.Background()
 	}
 	if _, ok := ctx.Deadline(); !ok {
-		ctx, cancel = context.WithTimeout(ctx, c.conf.Timeout)
+		ctx, cancel = context.WithTimeout(ctx, conf.Timeout)
 		defer cancel()
 	}
 
 	req, _ := http.NewRequestWithContext(
 		ctx, "GET",
-		JoinURL(c.conf.TenantAdmAddr, URIHealth), nil,
+		JoinURL(conf.TenantAdmAddr, URIHealth), nil,
 	)
 
 	rsp, err := client.Do(req)
@@ -145,15 +167,16 @@ func (c *Client) GetTenant(
 	username string,
 	client apiclient.HttpRunner,
 ) (*Tenant, error) {
+	conf := c.config()
 	usernameQ := url.QueryEscape(username)
 	req, err := http.NewRequest(http.MethodGet,
-		JoinURL(c.conf.TenantAdmAddr, GetTenantsUri+"?username="+url.QueryEscape(username)),
+		JoinURL(conf.TenantAdmAddr, GetTenantsUri+"?username="+url.QueryEscape(username)),
 		nil)
 	if err != nil {
 		return nil, errors.New("failed to prepare request to tenantadm")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, conf.Timeout)
 	defer cancel()
 
 	rsp, err := client.Do(req.WithContext(ctx))
@@ -187,6 +210,7 @@ func (c *Client) GetTenant(
 }
 
 func (c *Client) CreateUser(ctx context.Context, user *User, client apiclient.HttpRunner) error {
+	conf := c.config()
 	// prepare request body
 	userJson, err := json.Marshal(user)
 	if err != nil {
@@ -196,7 +220,7 @@ func (c *Client) CreateUser(ctx context.Context, user *User, client apiclient.Ht
 	reader := bytes.NewReader(userJson)
 
 	req, err := http.NewRequest(http.MethodPost,
-		JoinURL(c.conf.TenantAdmAddr, UsersUri),
+		JoinURL(conf.TenantAdmAddr, UsersUri),
 		reader)
 	if err != nil {
 		return errors.Wrap(err, "failed to create request for POST /users")
@@ -204,7 +228,7 @@ func (c *Client) CreateUser(ctx context.Context, user *User, client apiclient.Ht
 
 	req.Header.Set("Content-Type", "application/json")
 
-	ctx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, conf.Timeout)
 	defer cancel()
 
 	// send
@@ -231,6 +255,7 @@ func (c *Client) UpdateUser(
 	u *UserUpdate,
 	client apiclient.HttpRunner,
 ) error {
+	conf := c.config()
 	// prepare request body
 	json, err := json.Marshal(u)
 	if err != nil {
@@ -243,7 +268,7 @@ func (c *Client) UpdateUser(
 	uri := repl.Replace(TenantsUsersUri)
 
 	req, err := http.NewRequest(http.MethodPut,
-		JoinURL(c.conf.TenantAdmAddr, uri),
+		JoinURL(conf.TenantAdmAddr, uri),
 		reader)
 	if err != nil {
 		return errors.Wrap(err, "failed to create request for PUT /tenants/:id/users/:id")
@@ -251,7 +276,7 @@ func (c *Client) UpdateUser(
 
 	req.Header.Set("Content-Type", "application/json")
 
-	ctx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, conf.Timeout)
 	defer cancel()
 
 	// send
@@ -282,17 +307,18 @@ func (c *Client) DeleteUser(
 	userId string,
 	client apiclient.HttpRunner,
 ) error {
+	conf := c.config()
 
 	repl := strings.NewReplacer(":tid", tenantId, ":uid", userId)
 	uri := repl.Replace(TenantsUsersUri)
 
 	req, err := http.NewRequest(http.MethodDelete,
-		JoinURL(c.conf.TenantAdmAddr, uri), nil)
+		JoinURL(conf.TenantAdmAddr, uri), nil)
 	if err != nil {
 		return errors.Wrapf(err, "failed to create request for DELETE %s", uri)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, conf.Timeout)
 	defer cancel()
 
 	// send
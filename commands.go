@@ -67,9 +67,11 @@ func commandCreateUser(
 		}
 	}
 
+	// the users created from the command line administer the tenant
 	u := model.User{
 		Email:    username,
 		Password: password,
+		Roles:    []string{model.RoleAdmin},
 	}
 
 	if userId != "" {
@@ -85,7 +87,7 @@ func commandCreateUser(
 		return errors.Wrap(err, "database connection failed")
 	}
 
-	ua := useradm.NewUserAdm(nil, db, useradm.Config{})
+	ua := useradm.NewUserAdm(nil, db, useradmConfigFromAppConfig(c))
 	if tadmAddr := c.GetString(SettingTenantAdmAddr); tadmAddr != "" {
 		l.Infof("setting up tenant verification")
 
@@ -179,9 +181,11 @@ func commandSetPassword(
 
 	ua := useradm.NewUserAdm(nil, db, useradm.Config{})
 
+	// the users created from the command line administer the tenant
 	u := model.User{
 		Email:    username,
 		Password: password,
+		Roles:    []string{model.RoleAdmin},
 	}
 
 	if err := u.Validate(); err != nil {
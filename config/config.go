@@ -31,6 +31,14 @@ const (
 	SettingServerFallbackPrivKeyPath        = "server_fallback_priv_key_path"
 	SettingServerFallbackPrivKeyPathDefault = ""
 
+	// SettingSignerURL is the address of the remote signing service;
+	// when set, the private keys are not loaded by useradm at all
+	SettingSignerURL        = "jwt_signer_url"
+	SettingSignerURLDefault = ""
+
+	SettingSignerToken        = "jwt_signer_token"
+	SettingSignerTokenDefault = ""
+
 	SettingJWTIssuer        = "jwt_issuer"
 	SettingJWTIssuerDefault = "mender.useradm"
 
@@ -60,6 +68,150 @@ const (
 
 	SettingTokenMaxExpirationSeconds        = "token_max_expiration_seconds"
 	SettingTokenMaxExpirationSecondsDefault = 31536000
+
+	// SettingClientCredentialsExpirationTimeout is the expiration time
+	// of the access tokens issued with the client credentials grant
+	SettingClientCredentialsExpirationTimeout        = "client_credentials_exp_timeout"
+	SettingClientCredentialsExpirationTimeoutDefault = 3600
+
+	// SettingClientSecretRotationOverlap is how long the previous client
+	// secrets of a service account remain valid after a rotation
+	SettingClientSecretRotationOverlap        = "client_secret_rotation_overlap"
+	SettingClientSecretRotationOverlapDefault = 86400
+
+	// SettingIntrospectionClients maps the client IDs to the client
+	// secrets of the clients allowed to introspect tokens
+	SettingIntrospectionClients = "introspection_clients"
+
+	// SettingVerifyIdentityHeaders is the allowlist of the identity
+	// attributes of the token's subject returned in the headers of the
+	// /auth/verify response, for the gateway to forward
+	SettingVerifyIdentityHeaders = "verify_identity_headers"
+
+	// SettingVerifyCacheSize is the maximum number of the successful
+	// token verifications cached in memory; 0 disables the cache
+	SettingVerifyCacheSize        = "verify_cache_size"
+	SettingVerifyCacheSizeDefault = 10000
+
+	// SettingVerifyCacheTTL is how long the token verifications are
+	// cached for, in seconds
+	SettingVerifyCacheTTL        = "verify_cache_ttl"
+	SettingVerifyCacheTTLDefault = 30
+
+	// SettingAuthzPolicyPath is the YAML or JSON file with the
+	// authorization policy applied together with RBAC
+	SettingAuthzPolicyPath        = "authz_policy_path"
+	SettingAuthzPolicyPathDefault = ""
+
+	// SettingAuthzPolicyCombining is how the policy file decisions are
+	// combined with RBAC: "deny-overrides" or "first-applicable"
+	SettingAuthzPolicyCombining        = "authz_policy_combining"
+	SettingAuthzPolicyCombiningDefault = "deny-overrides"
+
+	// SettingAuthzLogDenials enables logging the denied requests
+	// together with the reasons
+	SettingAuthzLogDenials        = "authz_log_denials"
+	SettingAuthzLogDenialsDefault = false
+
+	// SettingElevationMaxDuration is the maximum length of a privilege
+	// elevation, in minutes (0 - no limit)
+	SettingElevationMaxDuration        = "elevation_max_duration"
+	SettingElevationMaxDurationDefault = 60
+
+	// SettingElevationApproval makes the privilege elevations wait for
+	// the approval of another admin
+	SettingElevationApproval        = "elevation_approval"
+	SettingElevationApprovalDefault = true
+
+	// SettingElevationRoles maps the roles of the users to the
+	// comma-separated roles they may request with a privilege elevation
+	SettingElevationRoles = "elevation_roles"
+
+	// SettingApprovalExpirationTimeout is how long the actions wait for
+	// the approval of a second admin, in seconds
+	SettingApprovalExpirationTimeout        = "approval_exp_timeout"
+	SettingApprovalExpirationTimeoutDefault = 86400
+
+	// SettingUserRetention is how long the deleted users are kept, and
+	// can be restored, before they are purged, in seconds; zero removes
+	// the users right away
+	SettingUserRetention        = "user_retention"
+	SettingUserRetentionDefault = 2592000
+
+	// SettingUserPurgeInterval is how often the deleted users whose
+	// retention period expired are purged, in seconds
+	SettingUserPurgeInterval        = "user_purge_interval"
+	SettingUserPurgeIntervalDefault = 3600
+
+	// SettingServerURL is the public URL of the server, used to build
+	// the single sign-on endpoints registered with the identity providers
+	SettingServerURL        = "server_url"
+	SettingServerURLDefault = ""
+
+	// SettingLDAPURL is the URL of the LDAP / Active Directory server
+	// checking the credentials on login, ldap:// or ldaps://; the
+	// passwords stored by useradm are used if empty
+	SettingLDAPURL        = "ldap_url"
+	SettingLDAPURLDefault = ""
+
+	// SettingLDAPStartTLS upgrades the ldap:// connections with StartTLS
+	SettingLDAPStartTLS        = "ldap_start_tls"
+	SettingLDAPStartTLSDefault = false
+
+	// SettingLDAPCACertificate is the path to the PEM-encoded CA
+	// certificates trusted for the LDAP server, the system ones if empty
+	SettingLDAPCACertificate        = "ldap_ca_certificate"
+	SettingLDAPCACertificateDefault = ""
+
+	// SettingLDAPBindDN is the DN of the service account looking up
+	// the users, anonymous if empty
+	SettingLDAPBindDN        = "ldap_bind_dn"
+	SettingLDAPBindDNDefault = ""
+
+	// SettingLDAPBindPassword is the password of the service account
+	SettingLDAPBindPassword        = "ldap_bind_password"
+	SettingLDAPBindPasswordDefault = ""
+
+	// SettingLDAPBaseDN is the subtree holding the users and groups
+	SettingLDAPBaseDN        = "ldap_base_dn"
+	SettingLDAPBaseDNDefault = ""
+
+	// SettingLDAPUserFilter is the filter selecting the user entries
+	SettingLDAPUserFilter        = "ldap_user_filter"
+	SettingLDAPUserFilterDefault = "(objectClass=person)"
+
+	// SettingLDAPEmailAttribute is the user attribute holding the email
+	// address used to log in
+	SettingLDAPEmailAttribute        = "ldap_email_attribute"
+	SettingLDAPEmailAttributeDefault = "mail"
+
+	// SettingLDAPNameAttribute is the user attribute holding the
+	// display name of the user
+	SettingLDAPNameAttribute        = "ldap_name_attribute"
+	SettingLDAPNameAttributeDefault = "displayName"
+
+	// SettingLDAPGroupFilter is the filter selecting the groups whose
+	// members may log in, anyone matching the user filter if empty
+	SettingLDAPGroupFilter        = "ldap_group_filter"
+	SettingLDAPGroupFilterDefault = ""
+
+	// SettingLDAPGroupMemberAttribute is the group attribute listing
+	// the DNs of the members
+	SettingLDAPGroupMemberAttribute        = "ldap_group_member_attribute"
+	SettingLDAPGroupMemberAttributeDefault = "member"
+
+	// SettingLDAPRolesAttribute is the user attribute mapped to the
+	// roles of the user; the roles are managed in useradm if empty
+	SettingLDAPRolesAttribute        = "ldap_roles_attribute"
+	SettingLDAPRolesAttributeDefault = ""
+
+	// SettingLDAPRoleMapping maps the values of the roles attribute
+	// to role names
+	SettingLDAPRoleMapping = "ldap_role_mapping"
+
+	// SettingLDAPLocalUsers is the list of the email addresses of the
+	// users checked against the passwords stored by useradm only
+	SettingLDAPLocalUsers = "ldap_local_users"
 )
 
 var (
@@ -68,6 +220,8 @@ var (
 		{Key: SettingMiddleware, Value: SettingMiddlewareDefault},
 		{Key: SettingPrivKeyPath, Value: SettingPrivKeyPathDefault},
 		{Key: SettingServerFallbackPrivKeyPath, Value: SettingServerFallbackPrivKeyPathDefault},
+		{Key: SettingSignerURL, Value: SettingSignerURLDefault},
+		{Key: SettingSignerToken, Value: SettingSignerTokenDefault},
 		{Key: SettingJWTIssuer, Value: SettingJWTIssuerDefault},
 		{Key: SettingJWTExpirationTimeout, Value: SettingJWTExpirationTimeoutDefault},
 		{Key: SettingDb, Value: SettingDbDefault},
@@ -79,5 +233,34 @@ var (
 			Value: SettingTokenLastUsedUpdateFreqMinutesDefault},
 		{Key: SettingTokenMaxExpirationSeconds,
 			Value: SettingTokenMaxExpirationSecondsDefault},
+		{Key: SettingClientCredentialsExpirationTimeout,
+			Value: SettingClientCredentialsExpirationTimeoutDefault},
+		{Key: SettingClientSecretRotationOverlap,
+			Value: SettingClientSecretRotationOverlapDefault},
+		{Key: SettingAuthzPolicyPath, Value: SettingAuthzPolicyPathDefault},
+		{Key: SettingAuthzPolicyCombining, Value: SettingAuthzPolicyCombiningDefault},
+		{Key: SettingAuthzLogDenials, Value: SettingAuthzLogDenialsDefault},
+		{Key: SettingElevationMaxDuration, Value: SettingElevationMaxDurationDefault},
+		{Key: SettingElevationApproval, Value: SettingElevationApprovalDefault},
+		{Key: SettingApprovalExpirationTimeout,
+			Value: SettingApprovalExpirationTimeoutDefault},
+		{Key: SettingUserRetention, Value: SettingUserRetentionDefault},
+		{Key: SettingUserPurgeInterval, Value: SettingUserPurgeIntervalDefault},
+		{Key: SettingVerifyCacheSize, Value: SettingVerifyCacheSizeDefault},
+		{Key: SettingVerifyCacheTTL, Value: SettingVerifyCacheTTLDefault},
+		{Key: SettingServerURL, Value: SettingServerURLDefault},
+		{Key: SettingLDAPURL, Value: SettingLDAPURLDefault},
+		{Key: SettingLDAPStartTLS, Value: SettingLDAPStartTLSDefault},
+		{Key: SettingLDAPCACertificate, Value: SettingLDAPCACertificateDefault},
+		{Key: SettingLDAPBindDN, Value: SettingLDAPBindDNDefault},
+		{Key: SettingLDAPBindPassword, Value: SettingLDAPBindPasswordDefault},
+		{Key: SettingLDAPBaseDN, Value: SettingLDAPBaseDNDefault},
+		{Key: SettingLDAPUserFilter, Value: SettingLDAPUserFilterDefault},
+		{Key: SettingLDAPEmailAttribute, Value: SettingLDAPEmailAttributeDefault},
+		{Key: SettingLDAPNameAttribute, Value: SettingLDAPNameAttributeDefault},
+		{Key: SettingLDAPGroupFilter, Value: SettingLDAPGroupFilterDefault},
+		{Key: SettingLDAPGroupMemberAttribute,
+			Value: SettingLDAPGroupMemberAttributeDefault},
+		{Key: SettingLDAPRolesAttribute, Value: SettingLDAPRolesAttributeDefault},
 	}
 )
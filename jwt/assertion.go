@@ -0,0 +1,106 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package jwt
+
+import (
+	"crypto/rsa"
+	"time"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxAssertionLifetime is the longest accepted validity of a client
+	// assertion; the assertions are meant to be used once, right after
+	// they are created.
+	MaxAssertionLifetime = 5 * time.Minute
+)
+
+// AssertionKeyFunc returns the public key of the client identified by
+// clientID.
+type AssertionKeyFunc func(clientID string) (*rsa.PublicKey, error)
+
+// Assertion is a verified client assertion.
+type Assertion struct {
+	// ClientID is the client the assertion was issued by and for
+	ClientID string
+	// ID is the unique identifier of the assertion (jti), which the
+	// caller must not accept twice before the assertion expires
+	ID string
+	// ExpiresAt is the expiration time of the assertion
+	ExpiresAt time.Time
+}
+
+// VerifyAssertion verifies a JWT client assertion (RFC 7523, section 3)
+// signed with RS256 by the client.
+// The assertion must be issued by the client for itself (iss and sub set
+// to the client ID), for the given audience, carry its unique ID and the
+// issue time, and be valid for at most MaxAssertionLifetime. The errors
+// returned by keyFunc are passed through as is; other failures are
+// reported as ErrTokenInvalid or ErrTokenExpired.
+func VerifyAssertion(
+	assertion, audience string,
+	keyFunc AssertionKeyFunc,
+) (*Assertion, error) {
+	var keyErr error
+	parser := jwtgo.NewParser(
+		jwtgo.WithValidMethods([]string{jwtgo.SigningMethodRS256.Alg()}),
+	)
+	claims := &jwtgo.RegisteredClaims{}
+	_, err := parser.ParseWithClaims(assertion, claims,
+		func(token *jwtgo.Token) (interface{}, error) {
+			claims := token.Claims.(*jwtgo.RegisteredClaims)
+			if claims.Subject == "" || claims.Issuer != claims.Subject {
+				return nil, ErrTokenInvalid
+			}
+			key, err := keyFunc(claims.Subject)
+			if err != nil {
+				keyErr = err
+				return nil, err
+			}
+			return key, nil
+		},
+	)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		if verr, ok := err.(*jwtgo.ValidationError); ok &&
+			verr.Errors&jwtgo.ValidationErrorExpired != 0 {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrTokenInvalid
+	}
+
+	// the IDs of the assertions are kept until they expire: the
+	// lifetime bounds how long
+	if claims.ExpiresAt == nil || claims.IssuedAt == nil ||
+		claims.ExpiresAt.Sub(claims.IssuedAt.Time) > MaxAssertionLifetime ||
+		time.Until(claims.ExpiresAt.Time) > MaxAssertionLifetime {
+		return nil, errors.Wrap(ErrTokenInvalid, "assertion lifetime too long")
+	}
+	if claims.ID == "" {
+		return nil, errors.Wrap(ErrTokenInvalid, "missing assertion ID")
+	}
+	if !claims.VerifyAudience(audience, true) {
+		return nil, errors.Wrap(ErrTokenInvalid, "invalid assertion audience")
+	}
+
+	return &Assertion{
+		ClientID:  claims.Subject,
+		ID:        claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
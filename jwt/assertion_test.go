@@ -0,0 +1,151 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package jwt
+
+import (
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyAssertion(t *testing.T) {
+	privKey := loadPrivKey("../crypto/private.pem", t)
+	otherKey := loadPrivKey("../crypto/private_alternative.pem", t)
+	errNotFound := errors.New("client not found")
+
+	sign := func(claims jwtgo.RegisteredClaims, method jwtgo.SigningMethod,
+		key interface{}) string {
+		raw, err := jwtgo.NewWithClaims(method, claims).SignedString(key)
+		assert.NoError(t, err)
+		return raw
+	}
+	claims := func(modify func(c *jwtgo.RegisteredClaims)) jwtgo.RegisteredClaims {
+		c := jwtgo.RegisteredClaims{
+			Issuer:    "client-1",
+			Subject:   "client-1",
+			Audience:  jwtgo.ClaimStrings{"mender.useradm"},
+			ExpiresAt: jwtgo.NewNumericDate(time.Now().Add(time.Minute)),
+			IssuedAt:  jwtgo.NewNumericDate(time.Now()),
+			ID:        "jti-1",
+		}
+		if modify != nil {
+			modify(&c)
+		}
+		return c
+	}
+
+	testCases := map[string]struct {
+		assertion string
+
+		clientID string
+		err      error
+	}{
+		"ok": {
+			assertion: sign(claims(nil), jwtgo.SigningMethodRS256, privKey),
+			clientID:  "client-1",
+		},
+		"error: no ID": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.ID = ""
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: ErrTokenInvalid,
+		},
+		"error: no issue time": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.IssuedAt = nil
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: ErrTokenInvalid,
+		},
+		"error: issued too long before the expiration": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.IssuedAt = jwtgo.NewNumericDate(time.Now().Add(-time.Hour))
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: ErrTokenInvalid,
+		},
+		"error: issuer is not the subject": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.Issuer = "client-2"
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: ErrTokenInvalid,
+		},
+		"error: unknown client": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.Issuer = "client-2"
+				c.Subject = "client-2"
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: errNotFound,
+		},
+		"error: wrong key": {
+			assertion: sign(claims(nil), jwtgo.SigningMethodRS256, otherKey),
+			err:       ErrTokenInvalid,
+		},
+		"error: wrong algorithm": {
+			assertion: sign(claims(nil), jwtgo.SigningMethodHS256, []byte("secret")),
+			err:       ErrTokenInvalid,
+		},
+		"error: expired": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.ExpiresAt = jwtgo.NewNumericDate(time.Now().Add(-time.Minute))
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: ErrTokenExpired,
+		},
+		"error: no expiration": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.ExpiresAt = nil
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: ErrTokenInvalid,
+		},
+		"error: lifetime too long": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.ExpiresAt = jwtgo.NewNumericDate(time.Now().Add(time.Hour))
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: ErrTokenInvalid,
+		},
+		"error: wrong audience": {
+			assertion: sign(claims(func(c *jwtgo.RegisteredClaims) {
+				c.Audience = jwtgo.ClaimStrings{"someone.else"}
+			}), jwtgo.SigningMethodRS256, privKey),
+			err: ErrTokenInvalid,
+		},
+		"error: malformed": {
+			assertion: "foo.bar.baz",
+			err:       ErrTokenInvalid,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assertion, err := VerifyAssertion(tc.assertion, "mender.useradm",
+				func(clientID string) (*rsa.PublicKey, error) {
+					if clientID != "client-1" {
+						return nil, errNotFound
+					}
+					return &privKey.PublicKey, nil
+				})
+			if tc.err != nil {
+				assert.Equal(t, tc.err, errors.Cause(err))
+			} else {
+				if assert.NoError(t, err) {
+					assert.Equal(t, tc.clientID, assertion.ClientID)
+					assert.Equal(t, "jti-1", assertion.ID)
+					assert.False(t, assertion.ExpiresAt.IsZero())
+				}
+			}
+		})
+	}
+}
@@ -33,12 +33,29 @@ type Claims struct {
 	Tenant string `json:"mender.tenant,omitempty" bson:"tenant,omitempty"`
 	// User claims that this token is for the management API.
 	User bool `json:"mender.user,omitempty" bson:"user,omitempty"`
+	// ServiceAccount claims that the subject is a service account rather
+	// than a human user.
+	ServiceAccount bool `json:"mender.service_account,omitempty" bson:"service_account,omitempty"`
 	// Issuer contains the configured Issuer claim (defaults to "Mender")
 	Issuer string `json:"iss,omitempty" bson:"iss,omitempty"`
 	// Scope determines the API scope of the token (defaults to "mender.*")
 	Scope     string `json:"scp,omitempty" bson:"scp,omitempty"`
 	Audience  string `json:"aud,omitempty" bson:"aud,omitempty"`
 	NotBefore Time   `json:"nbf,omitempty" bson:"nbf,omitempty"`
+	// Elevation holds the privilege elevation the user had when the
+	// token was issued.
+	Elevation *Elevation `json:"mender.elevation,omitempty" bson:"elevation,omitempty"`
+	// Attributes holds the custom attributes of the user the tenant
+	// chose to embed in the tokens.
+	Attributes map[string]interface{} `json:"mender.attributes,omitempty" bson:"attributes,omitempty"`
+}
+
+// Elevation describes the roles temporarily granted to the user.
+type Elevation struct {
+	// Roles are the names of the elevated roles.
+	Roles []string `json:"roles" bson:"roles"`
+	// ExpiresAt is the end of the (earliest ending) elevation window.
+	ExpiresAt Time `json:"exp" bson:"exp"`
 }
 
 // Time is a simple wrapper of time.Time that marshals/unmarshals JSON
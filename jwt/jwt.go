@@ -14,6 +14,7 @@
 package jwt
 
 import (
+	"context"
 	"crypto/rsa"
 
 	jwtgo "github.com/golang-jwt/jwt/v4"
@@ -28,55 +29,82 @@ var (
 // JWTHandler jwt generator/verifier
 //go:generate ../utils/mockgen.sh
 type Handler interface {
-	ToJWT(t *Token) (string, error)
+	ToJWT(ctx context.Context, t *Token) (string, error)
 	// FromJWT parses the token and does basic validity checks (Claims.Valid().
 	// returns:
 	// ErrTokenExpired when the token is valid but expired
 	// ErrTokenInvalid when the token is invalid (malformed, missing required claims, etc.)
-	FromJWT(string) (*Token, error)
+	FromJWT(ctx context.Context, tokstr string) (*Token, error)
+	// SignRevocationList returns the signed revocation list.
+	SignRevocationList(ctx context.Context, list *RevocationList) (string, error)
 }
 
 // JWTHandlerRS256 is an RS256-specific JWTHandler
 type JWTHandlerRS256 struct {
-	privKey         *rsa.PrivateKey
-	fallbackPrivKey *rsa.PrivateKey
+	signer Signer
 }
 
+// NewJWTHandlerRS256 creates a handler signing the tokens in-process with
+// privKey; fallbackPrivKey (optional) is only used for verification.
 func NewJWTHandlerRS256(privKey *rsa.PrivateKey, fallbackPrivKey *rsa.PrivateKey) *JWTHandlerRS256 {
+	return NewJWTHandlerRS256WithSigner(NewLocalSigner(privKey, fallbackPrivKey))
+}
+
+// NewJWTHandlerRS256WithSigner creates a handler delegating the signing
+// of the tokens to signer.
+func NewJWTHandlerRS256WithSigner(signer Signer) *JWTHandlerRS256 {
 	return &JWTHandlerRS256{
-		privKey:         privKey,
-		fallbackPrivKey: fallbackPrivKey,
+		signer: signer,
 	}
 }
 
-func (j *JWTHandlerRS256) ToJWT(token *Token) (string, error) {
+func (j *JWTHandlerRS256) ToJWT(ctx context.Context, token *Token) (string, error) {
+	return j.sign(ctx, &token.Claims)
+}
+
+func (j *JWTHandlerRS256) SignRevocationList(
+	ctx context.Context,
+	list *RevocationList,
+) (string, error) {
+	list.Subject = RevocationListSubject
+	return j.sign(ctx, list)
+}
+
+func (j *JWTHandlerRS256) sign(ctx context.Context, claims jwtgo.Claims) (string, error) {
 	//generate
-	jt := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, &token.Claims)
+	jt := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims)
+	signingString, err := jt.SigningString()
+	if err != nil {
+		return "", err
+	}
 
 	//sign
-	data, err := jt.SignedString(j.privKey)
-	return data, err
+	sig, err := j.signer.Sign(ctx, signingString)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign the token")
+	}
+	return signingString + "." + jwtgo.EncodeSegment(sig), nil
 }
 
-func (j *JWTHandlerRS256) FromJWT(tokstr string) (*Token, error) {
-	var err error
+func (j *JWTHandlerRS256) FromJWT(ctx context.Context, tokstr string) (*Token, error) {
 	var jwttoken *jwtgo.Token
-	for _, privKey := range []*rsa.PrivateKey{
-		j.privKey,
-		j.fallbackPrivKey,
-	} {
-		if privKey != nil {
-			jwttoken, err = jwtgo.ParseWithClaims(tokstr, &Claims{},
-				func(token *jwtgo.Token) (interface{}, error) {
-					if _, ok := token.Method.(*jwtgo.SigningMethodRSA); !ok {
-						return nil, errors.New("unexpected signing method: " + token.Method.Alg())
-					}
-					return &privKey.PublicKey, nil
-				},
-			)
-			if jwttoken != nil && err == nil {
-				break
-			}
+	pubKeys, err := j.signer.PublicKeys(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the public keys")
+	}
+	err = ErrTokenInvalid
+	for _, pubKey := range pubKeys {
+		pubKey := pubKey
+		jwttoken, err = jwtgo.ParseWithClaims(tokstr, &Claims{},
+			func(token *jwtgo.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwtgo.SigningMethodRSA); !ok {
+					return nil, errors.New("unexpected signing method: " + token.Method.Alg())
+				}
+				return pubKey, nil
+			},
+		)
+		if jwttoken != nil && err == nil {
+			break
 		}
 	}
 
@@ -14,6 +14,7 @@
 package jwt
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -55,7 +56,7 @@ func TestJWTHandlerRS256GenerateToken(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			jwtHandler := NewJWTHandlerRS256(tc.privKey, nil)
 
-			raw, err := jwtHandler.ToJWT(&Token{
+			raw, err := jwtHandler.ToJWT(context.Background(), &Token{
 				Claims: tc.claims,
 			})
 			assert.NoError(t, err)
@@ -263,7 +264,7 @@ func TestJWTHandlerRS256FromJWT(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			jwtHandler := NewJWTHandlerRS256(tc.privKey, tc.fallbackPrivKey)
 
-			token, err := jwtHandler.FromJWT(tc.inToken)
+			token, err := jwtHandler.FromJWT(context.Background(), tc.inToken)
 			if tc.outErr == nil {
 				assert.NoError(t, err)
 				assert.Equal(t, tc.outToken.Claims, (*token).Claims)
@@ -275,6 +276,43 @@ func TestJWTHandlerRS256FromJWT(t *testing.T) {
 	}
 }
 
+func TestLocalSignerSetKeys(t *testing.T) {
+	privKey := loadPrivKey("../crypto/private.pem", t)
+	altPrivKey := loadPrivKey("../crypto/private_alternative.pem", t)
+	signer := NewLocalSigner(privKey, nil)
+	jwtHandler := NewJWTHandlerRS256WithSigner(signer)
+
+	token := &Token{Claims: Claims{
+		ID:      oid.NewUUIDv4(),
+		Issuer:  "Mender",
+		Subject: oid.NewUUIDv5("foo"),
+		Scope:   "mender.*",
+		ExpiresAt: Time{
+			Time: time.Now().Add(time.Hour),
+		},
+	}}
+	oldRaw, err := jwtHandler.ToJWT(context.Background(), token)
+	assert.NoError(t, err)
+
+	// rotate: the alternative key signs, the old key is the fallback
+	signer.SetKeys(altPrivKey, privKey)
+	newRaw, err := jwtHandler.ToJWT(context.Background(), token)
+	assert.NoError(t, err)
+	_ = parseGeneratedTokenRS256(t, newRaw, altPrivKey)
+
+	_, err = jwtHandler.FromJWT(context.Background(), oldRaw)
+	assert.NoError(t, err)
+	_, err = jwtHandler.FromJWT(context.Background(), newRaw)
+	assert.NoError(t, err)
+
+	// drop the old key: tokens signed with it are no longer valid
+	signer.SetKeys(altPrivKey, nil)
+	_, err = jwtHandler.FromJWT(context.Background(), oldRaw)
+	assert.Error(t, err)
+	_, err = jwtHandler.FromJWT(context.Background(), newRaw)
+	assert.NoError(t, err)
+}
+
 func loadPrivKey(path string, t *testing.T) *rsa.PrivateKey {
 	pem_data, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -17,6 +17,7 @@
 package mocks
 
 import (
+	context "context"
 	jwt "github.com/mendersoftware/useradm/jwt"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -26,13 +27,13 @@ type Handler struct {
 	mock.Mock
 }
 
-// FromJWT provides a mock function with given fields: _a0
-func (_m *Handler) FromJWT(_a0 string) (*jwt.Token, error) {
-	ret := _m.Called(_a0)
+// FromJWT provides a mock function with given fields: ctx, tokstr
+func (_m *Handler) FromJWT(ctx context.Context, tokstr string) (*jwt.Token, error) {
+	ret := _m.Called(ctx, tokstr)
 
 	var r0 *jwt.Token
-	if rf, ok := ret.Get(0).(func(string) *jwt.Token); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *jwt.Token); ok {
+		r0 = rf(ctx, tokstr)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*jwt.Token)
@@ -40,8 +41,8 @@ func (_m *Handler) FromJWT(_a0 string) (*jwt.Token, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokstr)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -49,20 +50,41 @@ func (_m *Handler) FromJWT(_a0 string) (*jwt.Token, error) {
 	return r0, r1
 }
 
-// ToJWT provides a mock function with given fields: t
-func (_m *Handler) ToJWT(t *jwt.Token) (string, error) {
-	ret := _m.Called(t)
+// SignRevocationList provides a mock function with given fields: ctx, list
+func (_m *Handler) SignRevocationList(ctx context.Context, list *jwt.RevocationList) (string, error) {
+	ret := _m.Called(ctx, list)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func(*jwt.Token) string); ok {
-		r0 = rf(t)
+	if rf, ok := ret.Get(0).(func(context.Context, *jwt.RevocationList) string); ok {
+		r0 = rf(ctx, list)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*jwt.Token) error); ok {
-		r1 = rf(t)
+	if rf, ok := ret.Get(1).(func(context.Context, *jwt.RevocationList) error); ok {
+		r1 = rf(ctx, list)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ToJWT provides a mock function with given fields: ctx, t
+func (_m *Handler) ToJWT(ctx context.Context, t *jwt.Token) (string, error) {
+	ret := _m.Called(ctx, t)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, *jwt.Token) string); ok {
+		r0 = rf(ctx, t)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *jwt.Token) error); ok {
+		r1 = rf(ctx, t)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,90 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package jwt
+
+import (
+	"crypto/rsa"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+)
+
+// RevocationListSubject is the subject (sub) of the revocation lists,
+// which tells them apart from the tokens signed with the same keys.
+const RevocationListSubject = "revocation-list"
+
+// RevocationList holds the IDs of the tokens revoked (deleted) before
+// their expiration, signed like the tokens themselves so that the
+// services verifying the tokens locally can trust it.
+type RevocationList struct {
+	// Issuer is the issuer of the revoked tokens.
+	Issuer string `json:"iss,omitempty"`
+	// Subject is always RevocationListSubject, set when the list is
+	// signed.
+	Subject string `json:"sub"`
+	// IssuedAt is the time the list was produced.
+	IssuedAt Time `json:"iat"`
+	// Version is the version of the list; the revocations are numbered
+	// consecutively, the version being the last revocation included.
+	Version int64 `json:"ver"`
+	// Since is the version the list is a delta to, i.e. the list only
+	// holds the revocations after Since; 0 for the full list.
+	Since int64 `json:"since,omitempty"`
+	// Revoked are the revoked tokens not yet expired.
+	Revoked []RevokedToken `json:"revoked"`
+}
+
+// RevokedToken is an entry of the revocation list.
+type RevokedToken struct {
+	// ID is the ID (jti) of the revoked token.
+	ID oid.ObjectID `json:"jti"`
+	// ExpiresAt is the expiration of the revoked token, after which the
+	// entry can be dropped.
+	ExpiresAt Time `json:"exp"`
+}
+
+// Valid implements jwtgo.Claims; the list does not expire, the issuance
+// time tells how recent it is. The tokens are rejected, as they lack the
+// subject of the lists.
+func (l *RevocationList) Valid() error {
+	if l.Subject != RevocationListSubject ||
+		l.IssuedAt.IsZero() || l.Version < l.Since {
+		return ErrTokenInvalid
+	}
+	return nil
+}
+
+// ParseRevocationList verifies the signature of the revocation list with
+// any of the public keys and returns the list.
+func ParseRevocationList(
+	list string,
+	pubKeys ...*rsa.PublicKey,
+) (*RevocationList, error) {
+	parser := jwtgo.NewParser(
+		jwtgo.WithValidMethods([]string{jwtgo.SigningMethodRS256.Alg()}),
+	)
+	for _, pubKey := range pubKeys {
+		pubKey := pubKey
+		claims := &RevocationList{}
+		_, err := parser.ParseWithClaims(list, claims,
+			func(*jwtgo.Token) (interface{}, error) {
+				return pubKey, nil
+			},
+		)
+		if err == nil {
+			return claims, nil
+		}
+	}
+	return nil, ErrTokenInvalid
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package jwt
+
+import (
+	"context"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationList(t *testing.T) {
+	privKey := loadPrivKey("../crypto/private.pem", t)
+	altPrivKey := loadPrivKey("../crypto/private_alternative.pem", t)
+	now := time.Unix(time.Now().Unix(), 0)
+
+	testCases := map[string]struct {
+		list *RevocationList
+
+		signer  *JWTHandlerRS256
+		pubKeys []*rsa.PublicKey
+
+		err error
+	}{
+		"ok": {
+			list: &RevocationList{
+				Issuer:   "mender",
+				IssuedAt: Time{Time: now},
+				Version:  5,
+				Since:    3,
+				Revoked: []RevokedToken{{
+					ID:        oid.NewUUIDv5("token-1"),
+					ExpiresAt: Time{Time: now.Add(time.Hour)},
+				}},
+			},
+			signer: NewJWTHandlerRS256(privKey, nil),
+		},
+		"ok, signed with the fallback key": {
+			list: &RevocationList{
+				IssuedAt: Time{Time: now},
+				Revoked:  []RevokedToken{},
+			},
+			signer: NewJWTHandlerRS256(altPrivKey, nil),
+		},
+		"error, unknown key": {
+			list: &RevocationList{
+				IssuedAt: Time{Time: now},
+			},
+			signer:  NewJWTHandlerRS256(privKey, nil),
+			pubKeys: []*rsa.PublicKey{&altPrivKey.PublicKey},
+			err:     ErrTokenInvalid,
+		},
+		"error, not issued": {
+			list: &RevocationList{
+				Version: 1,
+			},
+			signer: NewJWTHandlerRS256(privKey, nil),
+			err:    ErrTokenInvalid,
+		},
+		"error, version before since": {
+			list: &RevocationList{
+				IssuedAt: Time{Time: now},
+				Version:  1,
+				Since:    2,
+			},
+			signer: NewJWTHandlerRS256(privKey, nil),
+			err:    ErrTokenInvalid,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			signed, err := tc.signer.SignRevocationList(context.Background(), tc.list)
+			assert.NoError(t, err)
+			assert.Equal(t, RevocationListSubject, tc.list.Subject)
+
+			pubKeys := tc.pubKeys
+			if pubKeys == nil {
+				pubKeys = []*rsa.PublicKey{&privKey.PublicKey, &altPrivKey.PublicKey}
+			}
+			list, err := ParseRevocationList(signed, pubKeys...)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				assert.Nil(t, list)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.list, list)
+			}
+		})
+	}
+}
+
+func TestRevocationListNotToken(t *testing.T) {
+	privKey := loadPrivKey("../crypto/private.pem", t)
+	now := time.Now()
+
+	// the tokens are signed with the same keys, but are no lists
+	token, err := NewJWTHandlerRS256(privKey, nil).ToJWT(context.Background(),
+		&Token{Claims: Claims{
+			ID:        oid.NewUUIDv4(),
+			Subject:   oid.NewUUIDv4(),
+			Issuer:    "mender",
+			IssuedAt:  Time{Time: now},
+			ExpiresAt: Time{Time: now.Add(time.Hour)},
+			User:      true,
+		}})
+	assert.NoError(t, err)
+	list, err := ParseRevocationList(token, &privKey.PublicKey)
+	assert.Equal(t, ErrTokenInvalid, err)
+	assert.Nil(t, list)
+}
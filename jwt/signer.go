@@ -0,0 +1,112 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrSignerNoKey = errors.New("jwt: signer: no private key")
+)
+
+// Signer produces the RS256 token signatures, so that the JWT handler
+// does not need to hold the private keys itself.
+//go:generate ../utils/mockgen.sh
+type Signer interface {
+	// Sign returns the RS256 (RSASSA-PKCS1-v1_5 using SHA-256) signature
+	// of the JWT signing string (the encoded header and claims).
+	Sign(ctx context.Context, signingString string) ([]byte, error)
+	// PublicKeys returns the keys accepted when verifying the tokens,
+	// starting with the key currently used for signing.
+	PublicKeys(ctx context.Context) ([]*rsa.PublicKey, error)
+}
+
+// LocalSigner signs the tokens in-process with the private key held in
+// memory.
+type LocalSigner struct {
+	mutex           sync.RWMutex
+	privKey         *rsa.PrivateKey
+	fallbackPrivKey *rsa.PrivateKey
+}
+
+// NewLocalSigner creates a signer using privKey; fallbackPrivKey
+// (optional) is never used for signing, only for verification.
+func NewLocalSigner(privKey *rsa.PrivateKey, fallbackPrivKey *rsa.PrivateKey) *LocalSigner {
+	return &LocalSigner{
+		privKey:         privKey,
+		fallbackPrivKey: fallbackPrivKey,
+	}
+}
+
+// SetKeys atomically replaces the signing key and the fallback key
+// used for verification; tokens being signed or verified concurrently
+// use either the old or the new pair, never a mix of both.
+func (s *LocalSigner) SetKeys(privKey *rsa.PrivateKey, fallbackPrivKey *rsa.PrivateKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.privKey = privKey
+	s.fallbackPrivKey = fallbackPrivKey
+}
+
+func (s *LocalSigner) keys() (*rsa.PrivateKey, *rsa.PrivateKey) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.privKey, s.fallbackPrivKey
+}
+
+func (s *LocalSigner) Sign(ctx context.Context, signingString string) ([]byte, error) {
+	privKey, _ := s.keys()
+	if privKey == nil {
+		return nil, ErrSignerNoKey
+	}
+	return SignRS256(privKey, signingString)
+}
+
+func (s *LocalSigner) PublicKeys(ctx context.Context) ([]*rsa.PublicKey, error) {
+	privKey, fallbackPrivKey := s.keys()
+	keys := make([]*rsa.PublicKey, 0, 2)
+	for _, key := range []*rsa.PrivateKey{privKey, fallbackPrivKey} {
+		if key != nil {
+			keys = append(keys, &key.PublicKey)
+		}
+	}
+	return keys, nil
+}
+
+// SignRS256 computes the RS256 signature of the JWT signing string.
+func SignRS256(privKey *rsa.PrivateKey, signingString string) ([]byte, error) {
+	digest := sha256.Sum256([]byte(signingString))
+	return rsa.SignPKCS1v15(rand.Reader, privKey, crypto.SHA256, digest[:])
+}
+
+// KeyID returns the identifier of the public key: the hex-encoded SHA-256
+// digest of its PKIX, DER-encoded form.
+func KeyID(pubKey *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return ""
+	}
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,328 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+)
+
+// The remote signing protocol: a JSON over HTTP API exposed by a service
+// holding the private keys (e.g. a thin wrapper around an HSM or a cloud
+// KMS).
+//
+//	POST <url>/api/v1/sign
+//	  request:  {"algorithm": "RS256", "payload": "<base64 signing string>"}
+//	  response: {"signature": "<base64 signature>", "key_id": "<key ID>"}
+//
+//	GET <url>/api/v1/keys
+//	  response: {"keys": [{"key_id": "<key ID>", "public_key": "<PEM>"}]}
+//
+// The key IDs are computed with KeyID; the keys are listed starting with
+// the one currently used for signing. If configured, requests carry
+// an "Authorization: Bearer <token>" header.
+const (
+	SignerURISign = "/api/v1/sign"
+	SignerURIKeys = "/api/v1/keys"
+
+	SignerAlgorithmRS256 = "RS256"
+
+	defaultSignerTimeout         = 10 * time.Second
+	defaultSignerKeysRefreshTime = 5 * time.Minute
+
+	// signerKeysRetryTime is the delay before fetching the public keys
+	// again after the first failure; doubled after every further one,
+	// up to the keys refresh time
+	signerKeysRetryTime = time.Second
+)
+
+// SignRequest is the body of the signing request.
+type SignRequest struct {
+	Algorithm string `json:"algorithm"`
+	Payload   []byte `json:"payload"`
+}
+
+// SignResponse is the body of the signing response.
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+	KeyID     string `json:"key_id"`
+}
+
+// PublicKey is a single entry of the public keys response.
+type PublicKey struct {
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+}
+
+// PublicKeysResponse is the body of the public keys response.
+type PublicKeysResponse struct {
+	Keys []PublicKey `json:"keys"`
+}
+
+// RemoteSignerConfig conveys the remote signer configuration.
+type RemoteSignerConfig struct {
+	// URL of the signing service
+	URL string
+	// Token (optional) sent as a bearer token to the signing service
+	Token string
+	// Timeout of the requests to the signing service
+	Timeout time.Duration
+	// KeysRefreshTime is how long the public keys are cached for
+	KeysRefreshTime time.Duration
+}
+
+// RemoteSigner delegates signing to a remote service, so that the
+// private keys never leave it.
+type RemoteSigner struct {
+	conf   RemoteSignerConfig
+	client *http.Client
+
+	mutex       sync.Mutex
+	keys        []*rsa.PublicKey
+	keyIDs      map[string]bool
+	keysFetched time.Time
+
+	// fetching is closed once the running fetch of the public keys is
+	// done; nil when the keys are not being fetched
+	fetching chan struct{}
+	// fetchErr, fetchFailures and retryAfter describe the failures of
+	// the last fetches, which are not retried before retryAfter
+	fetchErr      error
+	fetchFailures int
+	retryAfter    time.Time
+}
+
+func NewRemoteSigner(conf RemoteSignerConfig) *RemoteSigner {
+	if conf.Timeout == 0 {
+		conf.Timeout = defaultSignerTimeout
+	}
+	if conf.KeysRefreshTime == 0 {
+		conf.KeysRefreshTime = defaultSignerKeysRefreshTime
+	}
+	return &RemoteSigner{
+		conf:   conf,
+		client: &http.Client{Timeout: conf.Timeout},
+	}
+}
+
+func (s *RemoteSigner) do(
+	ctx context.Context,
+	method, uri string,
+	body interface{},
+	res interface{},
+) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return errors.Wrapf(err, "failed to prepare body for %s %s", method, uri)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method,
+		strings.TrimSuffix(s.conf.URL, "/")+uri, &reqBody)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request for %s %s", method, uri)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.conf.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.conf.Token)
+	}
+
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s %s request failed", method, uri)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s %s request failed with unexpected status %v",
+			method, uri, rsp.StatusCode)
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(res); err != nil {
+		return errors.Wrapf(err, "error parsing %s %s response", method, uri)
+	}
+	return nil
+}
+
+func (s *RemoteSigner) Sign(ctx context.Context, signingString string) ([]byte, error) {
+	var rsp SignResponse
+	err := s.do(ctx, http.MethodPost, SignerURISign, SignRequest{
+		Algorithm: SignerAlgorithmRS256,
+		Payload:   []byte(signingString),
+	}, &rsp)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp.Signature) == 0 {
+		return nil, errors.New("signing service returned an empty signature")
+	}
+
+	// the signing key was rotated: refresh the public keys on next
+	// use, or the new tokens would fail verification
+	s.mutex.Lock()
+	if !s.keyIDs[rsp.KeyID] {
+		s.keysFetched = time.Time{}
+		s.retryAfter = time.Time{}
+	}
+	s.mutex.Unlock()
+
+	return rsp.Signature, nil
+}
+
+// PublicKeys returns the cached public keys, fetching them if they are
+// stale. Only one fetch runs at a time, and the callers wait for it as
+// long as their context allows; while the signing service fails, the
+// fetches are retried with an increasing delay, and the last keys known
+// are used meanwhile.
+func (s *RemoteSigner) PublicKeys(ctx context.Context) ([]*rsa.PublicKey, error) {
+	l := log.FromContext(ctx)
+
+	s.mutex.Lock()
+	fresh := time.Since(s.keysFetched) < s.conf.KeysRefreshTime
+	if fresh || time.Now().Before(s.retryAfter) {
+		keys, err := s.keys, s.fetchErr
+		s.mutex.Unlock()
+		if len(keys) == 0 {
+			return nil, err
+		}
+		if !fresh {
+			l.Warnf("using the previous public keys, the signing service "+
+				"failed: %s", err.Error())
+		}
+		return keys, nil
+	}
+	fetching := s.fetching
+	if fetching == nil {
+		fetching = make(chan struct{})
+		s.fetching = fetching
+		go s.refreshKeys(fetching)
+	}
+	s.mutex.Unlock()
+
+	select {
+	case <-fetching:
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "failed to fetch the public keys")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.keys) == 0 {
+		return nil, s.fetchErr
+	}
+	if s.fetchErr != nil {
+		// better verify with the keys we know than reject every token
+		// while the signing service is unreachable
+		l.Warnf("using the previous public keys, the signing service "+
+			"failed: %s", s.fetchErr.Error())
+	}
+	return s.keys, nil
+}
+
+// refreshKeys fetches the public keys and closes done; the fetch is not
+// bound to the context of any of the waiting callers.
+func (s *RemoteSigner) refreshKeys(done chan struct{}) {
+	keys, keyIDs, err := s.fetchKeys(context.Background())
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err != nil {
+		retry := s.conf.KeysRefreshTime
+		if s.fetchFailures < 16 &&
+			signerKeysRetryTime<<s.fetchFailures < retry {
+			retry = signerKeysRetryTime << s.fetchFailures
+		}
+		s.fetchFailures++
+		s.fetchErr = err
+		s.retryAfter = time.Now().Add(retry)
+	} else {
+		s.keys = keys
+		s.keyIDs = keyIDs
+		s.keysFetched = time.Now()
+		s.fetchFailures = 0
+		s.fetchErr = nil
+		s.retryAfter = time.Time{}
+	}
+	s.fetching = nil
+	close(done)
+}
+
+func (s *RemoteSigner) fetchKeys(
+	ctx context.Context,
+) ([]*rsa.PublicKey, map[string]bool, error) {
+	var rsp PublicKeysResponse
+	if err := s.do(ctx, http.MethodGet, SignerURIKeys, nil, &rsp); err != nil {
+		return nil, nil, err
+	}
+
+	keys := make([]*rsa.PublicKey, 0, len(rsp.Keys))
+	keyIDs := make(map[string]bool, len(rsp.Keys))
+	for _, k := range rsp.Keys {
+		key, err := ParseRSAPublicKey([]byte(k.PublicKey))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid public key %q", k.KeyID)
+		}
+		keys = append(keys, key)
+		keyIDs[KeyID(key)] = true
+	}
+	if len(keys) == 0 {
+		return nil, nil, errors.New("signing service returned no public keys")
+	}
+	return keys, keyIDs, nil
+}
+
+// ParseRSAPublicKey parses a PEM-encoded (PKIX or PKCS#1) RSA public key.
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("key type not supported")
+		}
+		return rsaKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("invalid PEM block header: %s", block.Type)
+	}
+}
+
+// EncodeRSAPublicKey returns the PEM-encoded (PKIX) form of the key.
+func EncodeRSAPublicKey(key *rsa.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
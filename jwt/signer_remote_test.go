@@ -0,0 +1,206 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package jwt_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/jwt/signertest"
+	"github.com/mendersoftware/useradm/keys"
+)
+
+func newToken() *jwt.Token {
+	return &jwt.Token{Claims: jwt.Claims{
+		ID:      oid.NewUUIDv4(),
+		Issuer:  "Mender",
+		Subject: oid.NewUUIDv5("foo"),
+		Scope:   "mender.*",
+		ExpiresAt: jwt.Time{
+			Time: time.Now().Add(time.Hour),
+		},
+	}}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	privKey, err := keys.LoadRSAPrivate("../crypto/private.pem")
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		serverToken string
+		clientToken string
+
+		err string
+	}{
+		"ok": {},
+		"ok, authenticated": {
+			serverToken: "secret",
+			clientToken: "secret",
+		},
+		"error, unauthenticated": {
+			serverToken: "secret",
+			clientToken: "wrong",
+			err: "failed to sign the token: POST /api/v1/sign request " +
+				"failed with unexpected status 401",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := signertest.NewServer(privKey, nil)
+			defer srv.Close()
+			srv.Token = tc.serverToken
+
+			jwtHandler := jwt.NewJWTHandlerRS256WithSigner(
+				jwt.NewRemoteSigner(jwt.RemoteSignerConfig{
+					URL:   srv.URL,
+					Token: tc.clientToken,
+				}))
+
+			raw, err := jwtHandler.ToJWT(context.Background(), newToken())
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 1, srv.Signed())
+
+			// signed by the remote key, verifiable locally
+			_, err = jwt.NewJWTHandlerRS256(privKey, nil).FromJWT(context.Background(), raw)
+			assert.NoError(t, err)
+			_, err = jwtHandler.FromJWT(context.Background(), raw)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRemoteSignerKeyRotation(t *testing.T) {
+	privKey, err := keys.LoadRSAPrivate("../crypto/private.pem")
+	assert.NoError(t, err)
+	altPrivKey, err := keys.LoadRSAPrivate("../crypto/private_alternative.pem")
+	assert.NoError(t, err)
+
+	srv := signertest.NewServer(privKey, nil)
+	defer srv.Close()
+
+	jwtHandler := jwt.NewJWTHandlerRS256WithSigner(
+		jwt.NewRemoteSigner(jwt.RemoteSignerConfig{
+			URL:             srv.URL,
+			KeysRefreshTime: time.Hour,
+		}))
+
+	oldRaw, err := jwtHandler.ToJWT(context.Background(), newToken())
+	assert.NoError(t, err)
+	_, err = jwtHandler.FromJWT(context.Background(), oldRaw)
+	assert.NoError(t, err)
+
+	// the new key is picked up although the cached keys did not expire
+	srv.SetKeys(altPrivKey, privKey)
+	newRaw, err := jwtHandler.ToJWT(context.Background(), newToken())
+	assert.NoError(t, err)
+	_, err = jwtHandler.FromJWT(context.Background(), newRaw)
+	assert.NoError(t, err)
+	_, err = jwtHandler.FromJWT(context.Background(), oldRaw)
+	assert.NoError(t, err)
+
+	// the cached keys are used while the service is unreachable
+	srv.Close()
+	_, err = jwtHandler.FromJWT(context.Background(), newRaw)
+	assert.NoError(t, err)
+	_, err = jwtHandler.ToJWT(context.Background(), newToken())
+	assert.Error(t, err)
+}
+
+func TestRemoteSignerPublicKeysFetch(t *testing.T) {
+	privKey, err := keys.LoadRSAPrivate("../crypto/private.pem")
+	assert.NoError(t, err)
+
+	srv := signertest.NewServer(privKey, nil)
+	defer srv.Close()
+	srv.SetKeysDelay(50 * time.Millisecond)
+
+	signer := jwt.NewRemoteSigner(jwt.RemoteSignerConfig{
+		URL:             srv.URL,
+		KeysRefreshTime: time.Hour,
+	})
+
+	// a caller gives up on its own deadline, without failing the fetch
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = signer.PublicKeys(ctx)
+	assert.EqualError(t, err, "failed to fetch the public keys: "+
+		"context deadline exceeded")
+
+	// the concurrent callers share a single fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keys, err := signer.PublicKeys(context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, keys, 1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, srv.KeysFetched())
+}
+
+func TestRemoteSignerPublicKeysBackoff(t *testing.T) {
+	privKey, err := keys.LoadRSAPrivate("../crypto/private.pem")
+	assert.NoError(t, err)
+
+	srv := signertest.NewServer(privKey, nil)
+	defer srv.Close()
+
+	signer := jwt.NewRemoteSigner(jwt.RemoteSignerConfig{
+		URL:             srv.URL,
+		KeysRefreshTime: 200 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	// no keys known yet: the failure is reported, and not retried
+	// right away
+	srv.SetKeysFailing(true)
+	_, err = signer.PublicKeys(ctx)
+	assert.EqualError(t, err, "GET /api/v1/keys request failed "+
+		"with unexpected status 503")
+	_, err = signer.PublicKeys(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, srv.KeysFetched())
+
+	time.Sleep(200 * time.Millisecond)
+	srv.SetKeysFailing(false)
+	keys, err := signer.PublicKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, 2, srv.KeysFetched())
+
+	// the keys expire while the service fails: the previous keys are
+	// used, without fetching the keys on every call
+	time.Sleep(200 * time.Millisecond)
+	srv.SetKeysFailing(true)
+	for i := 0; i < 10; i++ {
+		keys, err = signer.PublicKeys(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+	}
+	assert.Equal(t, 3, srv.KeysFetched())
+}
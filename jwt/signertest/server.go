@@ -0,0 +1,179 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package signertest provides an in-process stand-in for a remote signing
+// service implementing the protocol used by jwt.RemoteSigner.
+package signertest
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+// Server is a signing service holding the private keys in memory.
+type Server struct {
+	*httptest.Server
+
+	// Token, if set, is the bearer token the clients must present.
+	Token string
+
+	mutex           sync.RWMutex
+	privKey         *rsa.PrivateKey
+	fallbackPrivKey *rsa.PrivateKey
+	signed          int
+	keysFetched     int
+	keysFailing     bool
+	keysDelay       time.Duration
+}
+
+// NewServer starts a signing service using privKey for signing, and
+// publishing the public keys of both privKey and fallbackPrivKey
+// (optional). The caller must call Close when done.
+func NewServer(privKey *rsa.PrivateKey, fallbackPrivKey *rsa.PrivateKey) *Server {
+	s := &Server{
+		privKey:         privKey,
+		fallbackPrivKey: fallbackPrivKey,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(jwt.SignerURISign, s.handleSign)
+	mux.HandleFunc(jwt.SignerURIKeys, s.handleKeys)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetKeys rotates the keys of the signing service.
+func (s *Server) SetKeys(privKey *rsa.PrivateKey, fallbackPrivKey *rsa.PrivateKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.privKey = privKey
+	s.fallbackPrivKey = fallbackPrivKey
+}
+
+// Signed returns the number of signatures produced so far.
+func (s *Server) Signed() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.signed
+}
+
+// KeysFetched returns the number of public keys requests so far.
+func (s *Server) KeysFetched() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.keysFetched
+}
+
+// SetKeysFailing makes the public keys requests fail (or succeed again).
+func (s *Server) SetKeysFailing(failing bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keysFailing = failing
+}
+
+// SetKeysDelay delays the responses to the public keys requests.
+func (s *Server) SetKeysDelay(delay time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keysDelay = delay
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleSign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(w, r) {
+		return
+	}
+	var req jwt.SignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil ||
+		req.Algorithm != jwt.SignerAlgorithmRS256 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	privKey := s.privKey
+	s.signed++
+	s.mutex.Unlock()
+
+	sig, err := jwt.SignRS256(privKey, string(req.Payload))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, jwt.SignResponse{
+		Signature: sig,
+		KeyID:     jwt.KeyID(&privKey.PublicKey),
+	})
+}
+
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(w, r) {
+		return
+	}
+
+	s.mutex.Lock()
+	privKeys := []*rsa.PrivateKey{s.privKey, s.fallbackPrivKey}
+	s.keysFetched++
+	failing, delay := s.keysFailing, s.keysDelay
+	s.mutex.Unlock()
+
+	time.Sleep(delay)
+	if failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var rsp jwt.PublicKeysResponse
+	for _, key := range privKeys {
+		if key == nil {
+			continue
+		}
+		data, err := jwt.EncodeRSAPublicKey(&key.PublicKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rsp.Keys = append(rsp.Keys, jwt.PublicKey{
+			KeyID:     jwt.KeyID(&key.PublicKey),
+			PublicKey: string(data),
+		})
+	}
+	writeJSON(w, rsp)
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -130,17 +130,13 @@ func doMain(args []string) error {
 	app.Before = func(args *cli.Context) error {
 		log.Setup(debug)
 
-		err := config.FromConfigFile(configPath, ConfigDefaults)
+		err := loadConfig(config.Config, configPath)
 		if err != nil {
 			return cli.NewExitError(
 				fmt.Sprintf("error loading configuration: %s", err),
 				1)
 		}
 
-		// Enable setting conig values by environment variables
-		config.Config.SetEnvPrefix("USERADM")
-		config.Config.AutomaticEnv()
-
 		return nil
 	}
 	err := app.Run(args)
@@ -185,7 +181,7 @@ func runServer(args *cli.Context) error {
 			3)
 	}
 
-	err = RunServer(config.Config)
+	err = RunServer(config.Config, args.GlobalString("config"))
 	if err != nil {
 		return cli.NewExitError(err.Error(), 4)
 	}
@@ -61,8 +61,14 @@ var (
 	commonStack = []rest.Middleware{
 		// verifies the request Content-Type header
 		// The expected Content-Type is 'application/json'
-		// if the content is non-null
-		&rest.ContentTypeCheckerMiddleware{},
+		// if the content is non-null, except for the endpoints
+		// accepting form-encoded or SCIM bodies
+		&rest.IfMiddleware{
+			Condition: func(r *rest.Request) bool {
+				return api_http.IsFormEndpoint(r) || api_http.IsSCIMEndpoint(r)
+			},
+			IfFalse: &rest.ContentTypeCheckerMiddleware{},
+		},
 		&requestid.RequestIdMiddleware{},
 		&identity.IdentityMiddleware{
 			UpdateLogger: true,
@@ -80,6 +86,8 @@ func SetupMiddleware(
 	mwtype string,
 	authorizer authz.Authorizer,
 	jwth jwt.Handler,
+	verify authz.TokenVerifier,
+	decisions authz.DecisionCache,
 ) error {
 
 	l := log.New(log.Ctx{})
@@ -101,6 +109,7 @@ func SetupMiddleware(
 		Authz:      authorizer,
 		ResFunc:    api_http.ExtractResourceAction,
 		JWTHandler: jwth,
+		Cache:      decisions,
 	}
 
 	//force authz only on verification endpoint
@@ -111,5 +120,22 @@ func SetupMiddleware(
 
 	api.Use(ifmw)
 
+	// authorize the management API in-process as well, so that the
+	// permissions hold even if a request bypasses the gateway; the
+	// tokens are verified as on /auth/verify, so that the revoked tokens
+	// and the tokens of the disabled or deleted users are rejected
+	mgmtmw := &rest.IfMiddleware{
+		Condition: api_http.IsManagementEndpoint,
+		IfTrue: &authz.AuthzMiddleware{
+			Authz:      authorizer,
+			ResFunc:    api_http.ExtractManagementResourceAction,
+			JWTHandler: jwth,
+			Verify:     verify,
+			Cache:      decisions,
+		},
+	}
+
+	api.Use(mgmtmw)
+
 	return nil
 }
@@ -14,9 +14,26 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	api_http "github.com/mendersoftware/useradm/api/http"
+	"github.com/mendersoftware/useradm/authz"
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/keys"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+	useradm "github.com/mendersoftware/useradm/user"
 )
 
 func TestSetupMiddleware(t *testing.T) {
@@ -33,7 +50,7 @@ func TestSetupMiddleware(t *testing.T) {
 	for _, td := range tdata {
 		api := rest.NewApi()
 
-		err := SetupMiddleware(api, td.mwtype, nil, nil)
+		err := SetupMiddleware(api, td.mwtype, nil, nil, nil, nil)
 		if err != nil && !td.experr {
 			t.Errorf("dod not expect error: %s", err)
 		} else if err == nil && td.experr {
@@ -41,3 +58,229 @@ func TestSetupMiddleware(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupMiddlewareManagementAuthz(t *testing.T) {
+	privkey, err := keys.LoadRSAPrivate("crypto/private.pem")
+	require.NoError(t, err)
+	jwth := jwt.NewJWTHandlerRS256(privkey, nil)
+
+	subject := oid.NewUUIDv4()
+	tokenID := oid.NewUUIDv4()
+	makeToken := func(tokenScope string) string {
+		token, err := jwth.ToJWT(context.Background(), &jwt.Token{
+			Claims: jwt.Claims{
+				ID:        tokenID,
+				Subject:   subject,
+				Issuer:    "mender",
+				ExpiresAt: jwt.Time{Time: time.Now().Add(time.Hour)},
+				Tenant:    "tenant",
+				Scope:     tokenScope,
+				User:      true,
+			},
+		})
+		require.NoError(t, err)
+		return token
+	}
+
+	testCases := map[string]struct {
+		method   string
+		path     string
+		auth     bool
+		scope    string
+		roles    []string
+		disabled bool
+		revoked  bool
+
+		outStatus int
+	}{
+		"ok, admin deletes a user": {
+			method: http.MethodDelete,
+			path:   "/api/management/v1/useradm/users/1",
+			auth:   true,
+			roles:  []string{model.RoleAdmin},
+
+			outStatus: http.StatusNoContent,
+		},
+		"ok, read-only lists the users": {
+			method: http.MethodGet,
+			path:   "/api/management/v1/useradm/users",
+			auth:   true,
+			roles:  []string{model.RoleReadOnly},
+
+			outStatus: http.StatusNoContent,
+		},
+		"error: read-only deletes a user": {
+			method: http.MethodDelete,
+			path:   "/api/management/v1/useradm/users/1",
+			auth:   true,
+			roles:  []string{model.RoleReadOnly},
+
+			outStatus: http.StatusForbidden,
+		},
+		"error: read-only changes the settings": {
+			method: http.MethodPost,
+			path:   "/api/management/v1/useradm/settings",
+			auth:   true,
+			roles:  []string{model.RoleReadOnly},
+
+			outStatus: http.StatusForbidden,
+		},
+		"ok, user without the permissions changes own password": {
+			method: http.MethodPut,
+			path:   "/api/management/v1/useradm/users/me",
+			auth:   true,
+			roles:  []string{"viewer"},
+
+			outStatus: http.StatusNoContent,
+		},
+		"ok, user without the permissions changes own settings": {
+			method: http.MethodPost,
+			path:   "/api/management/v1/useradm/settings/me",
+			auth:   true,
+			roles:  []string{"viewer"},
+
+			outStatus: http.StatusNoContent,
+		},
+		"ok, user without the permissions issues a personal access token": {
+			method: http.MethodPost,
+			path:   "/api/management/v1/useradm/settings/tokens",
+			auth:   true,
+			roles:  []string{"viewer"},
+
+			outStatus: http.StatusNoContent,
+		},
+		"ok, user without the permissions deletes own token": {
+			method: http.MethodDelete,
+			path:   "/api/management/v1/useradm/settings/tokens/1",
+			auth:   true,
+			roles:  []string{"viewer"},
+
+			outStatus: http.StatusNoContent,
+		},
+		"error: user without the permissions changes another user": {
+			method: http.MethodPut,
+			path:   "/api/management/v1/useradm/users/1",
+			auth:   true,
+			roles:  []string{"viewer"},
+
+			outStatus: http.StatusForbidden,
+		},
+		"error: token scope does not permit changing own password": {
+			method: http.MethodPut,
+			path:   "/api/management/v1/useradm/users/me",
+			auth:   true,
+			scope:  scope.DevicesRead,
+			roles:  []string{model.RoleAdmin},
+
+			outStatus: http.StatusForbidden,
+		},
+		"error: disabled user changes own password": {
+			method:   http.MethodPut,
+			path:     "/api/management/v1/useradm/users/me",
+			auth:     true,
+			roles:    []string{"viewer"},
+			disabled: true,
+
+			outStatus: http.StatusUnauthorized,
+		},
+		"error: revoked token": {
+			method:  http.MethodGet,
+			path:    "/api/management/v1/useradm/users",
+			auth:    true,
+			roles:   []string{model.RoleAdmin},
+			revoked: true,
+
+			outStatus: http.StatusUnauthorized,
+		},
+		"error: user disabled": {
+			method:   http.MethodGet,
+			path:     "/api/management/v1/useradm/users",
+			auth:     true,
+			roles:    []string{model.RoleAdmin},
+			disabled: true,
+
+			outStatus: http.StatusUnauthorized,
+		},
+		"error: no token": {
+			method: http.MethodDelete,
+			path:   "/api/management/v1/useradm/users/1",
+
+			outStatus: http.StatusUnauthorized,
+		},
+		"ok, login is not authorized": {
+			method: http.MethodPost,
+			path:   "/api/management/v1/useradm/auth/login",
+
+			outStatus: http.StatusNoContent,
+		},
+		"ok, internal api is not authorized": {
+			method: http.MethodGet,
+			path:   "/api/internal/v1/useradm/health",
+
+			outStatus: http.StatusNoContent,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			anyCtx := mock.MatchedBy(func(context.Context) bool { return true })
+			if tc.roles != nil {
+				user := &model.User{ID: subject.String(), Roles: tc.roles}
+				if tc.disabled {
+					user.Status = model.UserStatusDisabled
+				}
+				db.On("GetUserById", anyCtx, subject.String()).
+					Return(user, nil)
+			}
+			if tc.roles != nil && !tc.disabled {
+				var dbToken *jwt.Token
+				if !tc.revoked {
+					dbToken = &jwt.Token{Claims: jwt.Claims{ID: tokenID}}
+				}
+				db.On("GetTokenById", anyCtx, tokenID).
+					Return(dbToken, nil)
+			}
+			if tc.roles != nil && !tc.disabled && !tc.revoked {
+				db.On("GetGroupsByMember", anyCtx, subject.String()).
+					Return([]model.Group{}, nil)
+				db.On("GetActiveElevations", anyCtx, subject.String()).
+					Return([]model.Elevation{}, nil)
+				if model.BuiltInRole(tc.roles[0]) == nil {
+					db.On("GetRolesByNames", anyCtx, tc.roles).
+						Return([]model.Role{{
+							Name: tc.roles[0],
+							Permissions: []model.Permission{{
+								Resource: "*",
+								Methods:  []string{http.MethodGet},
+							}},
+						}}, nil)
+				}
+			}
+			ua := useradm.NewUserAdm(jwth, db, useradm.Config{Issuer: "mender"}).
+				WithTenantVerification(nil)
+
+			api := rest.NewApi()
+			err := SetupMiddleware(api, EnvProd, authz.NewRBACAuthorizer(db), jwth,
+				api_http.TokenVerifier(ua), nil)
+			require.NoError(t, err)
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			req := httptest.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+			if tc.auth {
+				tokenScope := tc.scope
+				if tokenScope == "" {
+					tokenScope = scope.All
+				}
+				req.Header.Set("Authorization", "Bearer "+makeToken(tokenScope))
+			}
+			w := httptest.NewRecorder()
+			api.MakeHandler().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.outStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	// actions which may require the approval of a second admin
+	ApprovalActionDeleteUser           = "delete_user"
+	ApprovalActionDeleteTokens         = "delete_tokens"
+	ApprovalActionSaveSettings         = "save_settings"
+	ApprovalActionSaveApprovalSettings = "save_approval_settings"
+	ApprovalActionSaveSAMLConfig       = "save_saml_config"
+	ApprovalActionDeleteSAMLConfig     = "delete_saml_config"
+	ApprovalActionSaveOIDCConfig       = "save_oidc_config"
+	ApprovalActionDeleteOIDCConfig     = "delete_oidc_config"
+	ApprovalActionCreateSCIMToken      = "create_scim_token"
+	ApprovalActionDeleteSCIMToken      = "delete_scim_token"
+
+	// the action waits for the approval
+	ApprovalStatusPending = "pending"
+	// the action was approved and carried out
+	ApprovalStatusApproved = "approved"
+	// the action was rejected
+	ApprovalStatusRejected = "rejected"
+	// the action was approved, but carrying it out failed
+	ApprovalStatusFailed = "failed"
+	// the action was not reviewed in time; the status is not stored but
+	// derived from the expiration time
+	ApprovalStatusExpired = "expired"
+)
+
+var approvalActions = []interface{}{
+	ApprovalActionDeleteUser,
+	ApprovalActionDeleteTokens,
+	ApprovalActionSaveSettings,
+	ApprovalActionSaveApprovalSettings,
+	ApprovalActionSaveSAMLConfig,
+	ApprovalActionDeleteSAMLConfig,
+	ApprovalActionSaveOIDCConfig,
+	ApprovalActionDeleteOIDCConfig,
+	ApprovalActionCreateSCIMToken,
+	ApprovalActionDeleteSCIMToken,
+}
+
+// ApprovalSettings lists the actions which need the approval of a second
+// admin in the tenant.
+type ApprovalSettings struct {
+	Actions []string `json:"actions" bson:"actions"`
+}
+
+func (s ApprovalSettings) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Actions,
+			validation.Each(validation.Required, validation.In(approvalActions...))),
+	)
+}
+
+// Requires checks if the action needs an approval.
+func (s ApprovalSettings) Requires(action string) bool {
+	for _, a := range s.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalRequest is an action to approve, together with its arguments.
+type ApprovalRequest struct {
+	Action string `json:"action" bson:"action"`
+
+	// ID of the user to delete, or of the user whose tokens to revoke
+	// (all the tokens of the tenant, if empty)
+	UserID string `json:"user_id,omitempty" bson:"user_id,omitempty"`
+
+	// tenant settings to save
+	Settings *Settings `json:"settings,omitempty" bson:"settings,omitempty"`
+
+	// the ETag the saved settings must match (If-Match)
+	SettingsETag string `json:"-" bson:"settings_etag,omitempty"`
+
+	// approval settings to save
+	ApprovalSettings *ApprovalSettings `json:"approval_settings,omitempty" bson:"approval_settings,omitempty"`
+
+	// single sign-on configuration to save
+	SAMLConfig *SAMLConfig `json:"saml_config,omitempty" bson:"saml_config,omitempty"`
+	OIDCConfig *OIDCConfig `json:"oidc_config,omitempty" bson:"oidc_config,omitempty"`
+
+	// SCIM token to save; the token itself is not stored, only returned
+	// to the requester
+	SCIMToken *SCIMToken `json:"scim_token,omitempty" bson:"scim_token,omitempty"`
+}
+
+// Approval is an action waiting for, or decided by, the approval of
+// a second admin.
+type Approval struct {
+	// system-generated approval ID
+	ID string `json:"id" bson:"_id"`
+
+	ApprovalRequest `bson:",inline"`
+
+	// ID of the user who requested the action
+	RequestedBy string `json:"requested_by,omitempty" bson:"requested_by,omitempty"`
+
+	// status of the approval
+	Status string `json:"status" bson:"status"`
+
+	// ID of the admin who approved or rejected the action
+	ReviewedBy string `json:"reviewed_by,omitempty" bson:"reviewed_by,omitempty"`
+
+	// error of the failed action
+	Error string `json:"error,omitempty" bson:"error,omitempty"`
+
+	// timestamp of the request
+	CreatedTs *time.Time `json:"created_ts,omitempty" bson:"created_ts,omitempty"`
+
+	// timestamp of the approval or rejection
+	ReviewedTs *time.Time `json:"reviewed_ts,omitempty" bson:"reviewed_ts,omitempty"`
+
+	// the action can't be approved after this time
+	ExpiresTs *time.Time `json:"expires_ts,omitempty" bson:"expires_ts,omitempty"`
+}
+
+// Redact removes the write-only secrets from the approval before it is
+// exposed through the API.
+func (a *Approval) Redact() {
+	if a.OIDCConfig != nil && a.OIDCConfig.ClientSecret != "" {
+		config := *a.OIDCConfig
+		config.ClientSecret = ""
+		a.OIDCConfig = &config
+	}
+}
+
+// IsExpired checks if the pending approval expired at the given time.
+func (a Approval) IsExpired(now time.Time) bool {
+	return a.Status == ApprovalStatusPending &&
+		a.ExpiresTs != nil && !now.Before(*a.ExpiresTs)
+}
+
+// ApprovalReview is a change of the approval status.
+type ApprovalReview struct {
+	Status     string     `bson:"status"`
+	ReviewedBy string     `bson:"reviewed_by,omitempty"`
+	ReviewedTs *time.Time `bson:"reviewed_ts,omitempty"`
+	Error      string     `bson:"error,omitempty"`
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalSettings(t *testing.T) {
+	settings := ApprovalSettings{
+		Actions: []string{ApprovalActionDeleteUser, ApprovalActionSaveSettings},
+	}
+	assert.NoError(t, settings.Validate())
+	assert.True(t, settings.Requires(ApprovalActionDeleteUser))
+	assert.False(t, settings.Requires(ApprovalActionDeleteTokens))
+
+	assert.NoError(t, ApprovalSettings{}.Validate())
+	assert.EqualError(t, ApprovalSettings{Actions: []string{"foo"}}.Validate(),
+		"actions: (0: must be a valid value.).")
+}
+
+func TestApprovalIsExpired(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Minute)
+
+	assert.False(t, Approval{
+		Status:    ApprovalStatusPending,
+		ExpiresTs: &later,
+	}.IsExpired(now))
+	assert.True(t, Approval{
+		Status:    ApprovalStatusPending,
+		ExpiresTs: &later,
+	}.IsExpired(later))
+	assert.False(t, Approval{
+		Status:    ApprovalStatusApproved,
+		ExpiresTs: &later,
+	}.IsExpired(later))
+}
+
+func TestApprovalRedact(t *testing.T) {
+	config := &OIDCConfig{
+		ClientID:     "client",
+		ClientSecret: "secret",
+	}
+	approval := &Approval{
+		ApprovalRequest: ApprovalRequest{
+			Action:     ApprovalActionSaveOIDCConfig,
+			OIDCConfig: config,
+		},
+	}
+	approval.Redact()
+	assert.Equal(t, "client", approval.OIDCConfig.ClientID)
+	assert.Empty(t, approval.OIDCConfig.ClientSecret)
+	// the stored request is left intact
+	assert.Equal(t, "secret", config.ClientSecret)
+
+	approval = &Approval{}
+	approval.Redact()
+	assert.Nil(t, approval.OIDCConfig)
+}
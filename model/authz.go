@@ -0,0 +1,57 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"errors"
+	"net/http"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+var (
+	ErrAuthzExplainSubject = errors.New("exactly one of user_id and token is required")
+
+	httpMethods = []interface{}{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+)
+
+// AuthzExplainRequest asks for the explanation of the authorization
+// decision on a request, made either by a user or with a token.
+type AuthzExplainRequest struct {
+	// ID of the user making the request, with an unrestricted token
+	UserID string `json:"user_id,omitempty"`
+
+	// token used for the request
+	Token string `json:"token,omitempty"`
+
+	// path of the request, as seen by the API gateway
+	Path string `json:"path"`
+
+	// HTTP method of the request
+	Method string `json:"method"`
+}
+
+func (r AuthzExplainRequest) Validate() error {
+	if (r.UserID == "") == (r.Token == "") {
+		return ErrAuthzExplainSubject
+	}
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Path, validation.Required, lessThan4096),
+		validation.Field(&r.Method, validation.Required, validation.In(httpMethods...)),
+	)
+}
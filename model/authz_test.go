@@ -0,0 +1,86 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthzExplainRequestValidate(t *testing.T) {
+	testCases := map[string]struct {
+		req AuthzExplainRequest
+
+		outErr string
+	}{
+		"ok, user": {
+			req: AuthzExplainRequest{
+				UserID: "1",
+				Path:   "/api/management/v1/deployments/deployments",
+				Method: "POST",
+			},
+		},
+		"ok, token": {
+			req: AuthzExplainRequest{
+				Token:  "token",
+				Path:   "/api/management/v1/deployments/deployments",
+				Method: "GET",
+			},
+		},
+		"error: no subject": {
+			req: AuthzExplainRequest{
+				Path:   "/api/management/v1/deployments/deployments",
+				Method: "GET",
+			},
+			outErr: ErrAuthzExplainSubject.Error(),
+		},
+		"error: both user and token": {
+			req: AuthzExplainRequest{
+				UserID: "1",
+				Token:  "token",
+				Path:   "/api/management/v1/deployments/deployments",
+				Method: "GET",
+			},
+			outErr: ErrAuthzExplainSubject.Error(),
+		},
+		"error: no path": {
+			req: AuthzExplainRequest{
+				UserID: "1",
+				Method: "GET",
+			},
+			outErr: "path: cannot be blank.",
+		},
+		"error: invalid method": {
+			req: AuthzExplainRequest{
+				UserID: "1",
+				Path:   "/api/management/v1/deployments/deployments",
+				Method: "get",
+			},
+			outErr: "method: must be a valid value.",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.req.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
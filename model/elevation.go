@@ -0,0 +1,101 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	// the elevation waits for the approval of another admin
+	ElevationStatusPending = "pending"
+	// the role is granted until the expiration of the elevation
+	ElevationStatusActive = "active"
+	// the elevation was rejected by an admin
+	ElevationStatusRejected = "rejected"
+	// the elevation was active, but its window has passed; the status is
+	// not stored but derived from the expiration time
+	ElevationStatusExpired = "expired"
+)
+
+// Elevation grants a role to the user for a limited time window; the roles
+// of the active elevations are part of the user's effective roles.
+type Elevation struct {
+	// system-generated elevation ID
+	ID string `json:"id" bson:"_id"`
+
+	// ID of the user the role is granted to
+	UserID string `json:"user_id" bson:"user_id"`
+
+	// name of the granted role
+	Role string `json:"role" bson:"role"`
+
+	// why the user needs the role
+	Justification string `json:"justification" bson:"justification"`
+
+	// length of the elevation window, in minutes
+	Duration int `json:"duration" bson:"duration"`
+
+	// status of the elevation
+	Status string `json:"status" bson:"status"`
+
+	// ID of the admin who approved or rejected the elevation
+	ReviewedBy string `json:"reviewed_by,omitempty" bson:"reviewed_by,omitempty"`
+
+	// timestamp of the elevation request
+	CreatedTs *time.Time `json:"created_ts,omitempty" bson:"created_ts,omitempty"`
+
+	// timestamp of the approval or rejection
+	ReviewedTs *time.Time `json:"reviewed_ts,omitempty" bson:"reviewed_ts,omitempty"`
+
+	// end of the elevation window, set once the role is granted
+	ExpiresTs *time.Time `json:"expires_ts,omitempty" bson:"expires_ts,omitempty"`
+}
+
+// Window returns the length of the elevation window.
+func (e Elevation) Window() time.Duration {
+	return time.Duration(e.Duration) * time.Minute
+}
+
+// IsActive checks if the elevation grants its role at the given time.
+func (e Elevation) IsActive(now time.Time) bool {
+	return e.Status == ElevationStatusActive &&
+		e.ExpiresTs != nil && now.Before(*e.ExpiresTs)
+}
+
+type ElevationNew struct {
+	Role          string `json:"role"`
+	Justification string `json:"justification"`
+	// length of the elevation window, in minutes
+	Duration int `json:"duration"`
+}
+
+func (e ElevationNew) Validate() error {
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.Role, validation.Required, lessThan128),
+		validation.Field(&e.Justification, validation.Required, lessThan4096),
+		validation.Field(&e.Duration, validation.Required, validation.Min(1)),
+	)
+}
+
+// ElevationReview is the outcome of the approval of an elevation.
+type ElevationReview struct {
+	Status     string     `bson:"status"`
+	ReviewedBy string     `bson:"reviewed_by"`
+	ReviewedTs *time.Time `bson:"reviewed_ts"`
+	ExpiresTs  *time.Time `bson:"expires_ts,omitempty"`
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElevationNewValidate(t *testing.T) {
+	testCases := map[string]struct {
+		elevation ElevationNew
+
+		outErr string
+	}{
+		"ok": {
+			elevation: ElevationNew{
+				Role:          RoleAdmin,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+		},
+		"error: no role": {
+			elevation: ElevationNew{
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			outErr: "role: cannot be blank.",
+		},
+		"error: no justification": {
+			elevation: ElevationNew{
+				Role:     RoleAdmin,
+				Duration: 30,
+			},
+			outErr: "justification: cannot be blank.",
+		},
+		"error: no duration": {
+			elevation: ElevationNew{
+				Role:          RoleAdmin,
+				Justification: "incident #42",
+			},
+			outErr: "duration: cannot be blank.",
+		},
+		"error: negative duration": {
+			elevation: ElevationNew{
+				Role:          RoleAdmin,
+				Justification: "incident #42",
+				Duration:      -1,
+			},
+			outErr: "duration: must be no less than 1.",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.elevation.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestElevationIsActive(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Minute)
+
+	assert.True(t, Elevation{
+		Status:    ElevationStatusActive,
+		ExpiresTs: &later,
+	}.IsActive(now))
+	assert.False(t, Elevation{
+		Status:    ElevationStatusActive,
+		ExpiresTs: &later,
+	}.IsActive(later))
+	assert.False(t, Elevation{
+		Status: ElevationStatusPending,
+	}.IsActive(now))
+}
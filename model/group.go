@@ -0,0 +1,87 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Group is a named set of users sharing the roles assigned to the group;
+// the effective roles of a user are the user's own roles together with the
+// roles of all the user's groups.
+type Group struct {
+	// system-generated group ID
+	ID string `json:"id" bson:"_id"`
+
+	// group name, unique within the tenant
+	Name string `json:"name" bson:"name"`
+
+	// free-form description
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+
+	// names of the roles assigned to the group
+	Roles []string `json:"roles" bson:"roles"`
+
+	// IDs of the users in the group; managed with the membership
+	// endpoints, the members are listed with the users' group filter
+	Members []string `json:"-" bson:"members,omitempty"`
+
+	// timestamp of the group creation
+	CreatedTs *time.Time `json:"created_ts,omitempty" bson:"created_ts,omitempty"`
+
+	// timestamp of the last group update
+	UpdatedTs *time.Time `json:"updated_ts,omitempty" bson:"updated_ts,omitempty"`
+}
+
+type GroupNew struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+func (g GroupNew) Validate() error {
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.Name, validation.Required, lessThan128),
+		validation.Field(&g.Description, lessThan4096),
+		validation.Field(&g.Roles,
+			validation.Each(validation.Required, lessThan128)),
+	)
+}
+
+type GroupUpdate struct {
+	Name        *string   `json:"name,omitempty" bson:"name,omitempty"`
+	Description *string   `json:"description,omitempty" bson:"description,omitempty"`
+	Roles       *[]string `json:"roles,omitempty" bson:"roles,omitempty"`
+
+	// timestamp of the last group update
+	UpdatedTs *time.Time `json:"-" bson:"updated_ts,omitempty"`
+}
+
+func (g GroupUpdate) Validate() error {
+	if g.Name == nil && g.Description == nil && g.Roles == nil {
+		return ErrEmptyUpdate
+	}
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.Name, validation.NilOrNotEmpty, lessThan128),
+		validation.Field(&g.Description, lessThan4096),
+		validation.Field(&g.Roles, validation.When(g.Roles != nil,
+			validation.By(func(interface{}) error {
+				return validation.Validate(*g.Roles,
+					validation.Each(validation.Required, lessThan128))
+			}))),
+	)
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupNewValidate(t *testing.T) {
+	testCases := map[string]struct {
+		group GroupNew
+
+		outErr string
+	}{
+		"ok": {
+			group: GroupNew{
+				Name:  "operators",
+				Roles: []string{RoleReadOnly, "device-operator"},
+			},
+		},
+		"ok, no roles": {
+			group: GroupNew{
+				Name: "operators",
+			},
+		},
+		"error: no name": {
+			group: GroupNew{
+				Roles: []string{RoleReadOnly},
+			},
+			outErr: "name: cannot be blank.",
+		},
+		"error: name too long": {
+			group: GroupNew{
+				Name: strings.Repeat("a", 129),
+			},
+			outErr: "name: the length must be no more than 128.",
+		},
+		"error: empty role": {
+			group: GroupNew{
+				Name:  "operators",
+				Roles: []string{""},
+			},
+			outErr: "roles: (0: cannot be blank.).",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.group.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestGroupUpdateValidate(t *testing.T) {
+	name := "operators"
+	empty := ""
+	roles := []string{RoleReadOnly}
+
+	assert.EqualError(t, GroupUpdate{}.Validate(), ErrEmptyUpdate.Error())
+	assert.NoError(t, GroupUpdate{Name: &name}.Validate())
+	assert.NoError(t, GroupUpdate{Roles: &roles}.Validate())
+	assert.EqualError(t, GroupUpdate{Name: &empty}.Validate(),
+		"name: cannot be blank.")
+	assert.EqualError(t, GroupUpdate{Roles: &[]string{""}}.Validate(),
+		"roles: (0: cannot be blank.).")
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+const (
+	// TokenTypeSession is the type of the tokens issued on login
+	TokenTypeSession = "session"
+	// TokenTypePersonalAccessToken is the type of the personal access tokens
+	TokenTypePersonalAccessToken = "personal_access_token"
+	// TokenTypeServiceAccount is the type of the service account tokens
+	TokenTypeServiceAccount = "service_account"
+)
+
+// TokenIntrospection is the token introspection response (RFC 7662).
+// Inactive tokens are described by the Active flag alone.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	ID        string `json:"jti,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	TokenName string `json:"token_name,omitempty"`
+}
+
+// NewTokenIntrospection describes an active token; dbToken is the
+// token as stored in the database, which tells personal access tokens
+// apart from session tokens.
+func NewTokenIntrospection(token *jwt.Token, dbToken *jwt.Token) *TokenIntrospection {
+	ti := &TokenIntrospection{
+		Active:    true,
+		ID:        token.Claims.ID.String(),
+		Subject:   token.Claims.Subject.String(),
+		Scope:     token.Claims.Scope,
+		ExpiresAt: unixTime(token.Claims.ExpiresAt),
+		IssuedAt:  unixTime(token.Claims.IssuedAt),
+		Issuer:    token.Claims.Issuer,
+		Tenant:    token.Claims.Tenant,
+		TokenType: TokenTypeSession,
+	}
+	if dbToken != nil && dbToken.TokenName != nil {
+		ti.TokenType = TokenTypePersonalAccessToken
+		ti.TokenName = *dbToken.TokenName
+	}
+	return ti
+}
+
+func unixTime(t jwt.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// TokenIdentity describes the subject of a verified token; the API
+// gateway forwards it to the other services.
+type TokenIdentity struct {
+	UserID    string
+	Tenant    string
+	Email     Email
+	Roles     []string
+	TokenType string
+	TokenID   string
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"net/url"
+)
+
+const (
+	// GrantTypeClientCredentials is the OAuth 2.0 client credentials
+	// grant (RFC 6749, section 4.4)
+	GrantTypeClientCredentials = "client_credentials"
+	// ClientAssertionTypeJWTBearer is the type of the JWT client
+	// assertions (RFC 7523, section 2.2)
+	ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	TokenTypeBearer = "Bearer"
+
+	// OAuth 2.0 error codes (RFC 6749, section 5.2)
+	OAuth2ErrInvalidRequest       = "invalid_request"
+	OAuth2ErrInvalidClient        = "invalid_client"
+	OAuth2ErrInvalidScope         = "invalid_scope"
+	OAuth2ErrUnsupportedGrantType = "unsupported_grant_type"
+	OAuth2ErrServerError          = "server_error"
+)
+
+// ClientCredentialsRequest is the access token request of the client
+// credentials grant; the client authenticates either with its secret or
+// with a signed JWT assertion.
+type ClientCredentialsRequest struct {
+	ClientID            string
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+	Scope               string
+}
+
+// ParseClientCredentialsRequest reads the request parameters from the
+// form; the client secret (client_secret_basic) may be passed separately,
+// in which case the client ID and secret form parameters are ignored.
+func ParseClientCredentialsRequest(
+	form url.Values,
+	basicID, basicSecret string,
+	basicOK bool,
+) (*ClientCredentialsRequest, *OAuth2Error) {
+	if form.Get("grant_type") == "" {
+		return nil, &OAuth2Error{
+			Code:        OAuth2ErrInvalidRequest,
+			Description: "missing grant_type parameter",
+		}
+	}
+	if form.Get("grant_type") != GrantTypeClientCredentials {
+		return nil, &OAuth2Error{
+			Code:        OAuth2ErrUnsupportedGrantType,
+			Description: "only the client_credentials grant is supported",
+		}
+	}
+
+	req := &ClientCredentialsRequest{
+		ClientID:            form.Get("client_id"),
+		ClientSecret:        form.Get("client_secret"),
+		ClientAssertionType: form.Get("client_assertion_type"),
+		ClientAssertion:     form.Get("client_assertion"),
+		Scope:               form.Get("scope"),
+	}
+	if basicOK {
+		if req.ClientSecret != "" || req.ClientAssertion != "" {
+			return nil, &OAuth2Error{
+				Code:        OAuth2ErrInvalidRequest,
+				Description: "multiple client authentication methods used",
+			}
+		}
+		// RFC 6749, section 2.3.1: the credentials are form-encoded
+		// before being used in the Authorization header
+		var err error
+		if req.ClientID, err = url.QueryUnescape(basicID); err != nil {
+			req.ClientID = basicID
+		}
+		if req.ClientSecret, err = url.QueryUnescape(basicSecret); err != nil {
+			req.ClientSecret = basicSecret
+		}
+	}
+
+	switch {
+	case req.ClientAssertion != "" || req.ClientAssertionType != "":
+		if req.ClientAssertionType != ClientAssertionTypeJWTBearer {
+			return nil, &OAuth2Error{
+				Code:        OAuth2ErrInvalidRequest,
+				Description: "unsupported client_assertion_type",
+			}
+		}
+		if req.ClientAssertion == "" {
+			return nil, &OAuth2Error{
+				Code:        OAuth2ErrInvalidRequest,
+				Description: "missing client_assertion parameter",
+			}
+		}
+		if req.ClientSecret != "" {
+			return nil, &OAuth2Error{
+				Code:        OAuth2ErrInvalidRequest,
+				Description: "multiple client authentication methods used",
+			}
+		}
+	case req.ClientID == "" || req.ClientSecret == "":
+		return nil, &OAuth2Error{
+			Code:        OAuth2ErrInvalidClient,
+			Description: "missing client credentials",
+		}
+	}
+
+	return req, nil
+}
+
+// AccessTokenResponse is the successful access token response
+// (RFC 6749, section 5.1).
+type AccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// OAuth2Error is the error response of the token endpoint
+// (RFC 6749, section 5.2).
+type OAuth2Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (err *OAuth2Error) Error() string {
+	if err.Description == "" {
+		return err.Code
+	}
+	return err.Code + ": " + err.Description
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClientCredentialsRequest(t *testing.T) {
+	testCases := map[string]struct {
+		form        url.Values
+		basicID     string
+		basicSecret string
+		basicOK     bool
+
+		req *ClientCredentialsRequest
+		err string
+	}{
+		"ok, client_secret_basic": {
+			form: url.Values{
+				"grant_type": {"client_credentials"},
+			},
+			basicID:     "client%3A1",
+			basicSecret: "secret",
+			basicOK:     true,
+			req: &ClientCredentialsRequest{
+				ClientID:     "client:1",
+				ClientSecret: "secret",
+			},
+		},
+		"ok, client_secret_post": {
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {"client-1"},
+				"client_secret": {"secret"},
+				"scope":         {"mender.*"},
+			},
+			req: &ClientCredentialsRequest{
+				ClientID:     "client-1",
+				ClientSecret: "secret",
+				Scope:        "mender.*",
+			},
+		},
+		"ok, client assertion": {
+			form: url.Values{
+				"grant_type":            {"client_credentials"},
+				"client_assertion_type": {ClientAssertionTypeJWTBearer},
+				"client_assertion":      {"assertion"},
+			},
+			req: &ClientCredentialsRequest{
+				ClientAssertionType: ClientAssertionTypeJWTBearer,
+				ClientAssertion:     "assertion",
+			},
+		},
+		"error: no grant type": {
+			form: url.Values{},
+			err:  "invalid_request: missing grant_type parameter",
+		},
+		"error: unsupported grant type": {
+			form: url.Values{
+				"grant_type": {"password"},
+			},
+			err: "unsupported_grant_type: only the client_credentials grant is supported",
+		},
+		"error: no credentials": {
+			form: url.Values{
+				"grant_type": {"client_credentials"},
+				"client_id":  {"client-1"},
+			},
+			err: "invalid_client: missing client credentials",
+		},
+		"error: unsupported assertion type": {
+			form: url.Values{
+				"grant_type":            {"client_credentials"},
+				"client_assertion_type": {"urn:foo"},
+				"client_assertion":      {"assertion"},
+			},
+			err: "invalid_request: unsupported client_assertion_type",
+		},
+		"error: secret and assertion": {
+			form: url.Values{
+				"grant_type":            {"client_credentials"},
+				"client_id":             {"client-1"},
+				"client_secret":         {"secret"},
+				"client_assertion_type": {ClientAssertionTypeJWTBearer},
+				"client_assertion":      {"assertion"},
+			},
+			err: "invalid_request: multiple client authentication methods used",
+		},
+		"error: basic and post": {
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_secret": {"secret"},
+			},
+			basicID:     "client-1",
+			basicSecret: "secret",
+			basicOK:     true,
+			err:         "invalid_request: multiple client authentication methods used",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := ParseClientCredentialsRequest(tc.form,
+				tc.basicID, tc.basicSecret, tc.basicOK)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				assert.Nil(t, req)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.req, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// OIDCConfig is the tenant's configuration of the OpenID Connect single
+// sign-on, with useradm acting as the relying party.
+type OIDCConfig struct {
+	// issuer URL of the identity provider, used for the discovery of
+	// its endpoints and keys
+	Issuer string `json:"issuer" bson:"issuer"`
+
+	// credentials of useradm registered at the identity provider
+	ClientID     string `json:"client_id" bson:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty" bson:"client_secret,omitempty"`
+
+	// scopes requested in addition to "openid"; "email" if empty
+	Scopes []string `json:"scopes,omitempty" bson:"scopes,omitempty"`
+
+	// name of the ID token claim holding the user's email; "email"
+	// if empty
+	EmailClaim string `json:"email_claim,omitempty" bson:"email_claim,omitempty"`
+
+	// roles of the users provisioned on their first login; they get
+	// read-only access if empty, as the users created through the API
+	ProvisioningRoles []string `json:"provisioning_roles,omitempty" bson:"provisioning_roles,omitempty"`
+
+	// whether the users of the tenant may only log in through the
+	// identity provider
+	DisablePasswordLogin bool `json:"disable_password_login" bson:"disable_password_login"`
+}
+
+func (c OIDCConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Issuer, validation.Required, lessThan4096, is.URL),
+		validation.Field(&c.ClientID, validation.Required, lessThan4096),
+		validation.Field(&c.ClientSecret, lessThan4096),
+		validation.Field(&c.Scopes, validation.Length(0, 32),
+			validation.Each(validation.Required, lessThan128)),
+		validation.Field(&c.EmailClaim, lessThan128),
+		validation.Field(&c.ProvisioningRoles,
+			validation.Each(validation.Required, lessThan128)),
+	)
+}
+
+// OIDCAuthRequest is the state of an authorization code request, kept by
+// the user agent until it returns with the code.
+type OIDCAuthRequest struct {
+	// URL of the authorization endpoint carrying the request
+	URL string `json:"-"`
+
+	// the state and nonce bind the response to the request; the code
+	// verifier is the PKCE secret
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+
+	// path to return to after logging in
+	Redirect string `json:"redirect,omitempty"`
+}
+
+// OIDCRelyingParty describes the endpoints of useradm acting as the
+// relying party for the tenant.
+type OIDCRelyingParty struct {
+	TenantID string
+	// public URL the identity provider redirects back to
+	RedirectURL string
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDCConfigValidate(t *testing.T) {
+	testCases := map[string]struct {
+		config OIDCConfig
+
+		outErr string
+	}{
+		"ok": {
+			config: OIDCConfig{
+				Issuer:   "https://accounts.google.com",
+				ClientID: "client",
+			},
+		},
+		"ok, all fields": {
+			config: OIDCConfig{
+				Issuer:               "https://keycloak.example.com/realms/mender",
+				ClientID:             "client",
+				ClientSecret:         "secret",
+				Scopes:               []string{"email", "profile"},
+				EmailClaim:           "upn",
+				ProvisioningRoles:    []string{RoleReadOnly},
+				DisablePasswordLogin: true,
+			},
+		},
+		"error: no issuer": {
+			config: OIDCConfig{
+				ClientID: "client",
+			},
+			outErr: "issuer: cannot be blank.",
+		},
+		"error: bad issuer": {
+			config: OIDCConfig{
+				Issuer:   "not an URL",
+				ClientID: "client",
+			},
+			outErr: "issuer: must be a valid URL.",
+		},
+		"error: no client ID": {
+			config: OIDCConfig{
+				Issuer: "https://accounts.google.com",
+			},
+			outErr: "client_id: cannot be blank.",
+		},
+		"error: empty scope": {
+			config: OIDCConfig{
+				Issuer:   "https://accounts.google.com",
+				ClientID: "client",
+				Scopes:   []string{"email", ""},
+			},
+			outErr: "scopes: (1: cannot be blank.).",
+		},
+		"error: claim name too long": {
+			config: OIDCConfig{
+				Issuer:     "https://accounts.google.com",
+				ClientID:   "client",
+				EmailClaim: strings.Repeat("a", 129),
+			},
+			outErr: "email_claim: the length must be no more than 128.",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
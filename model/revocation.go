@@ -0,0 +1,41 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+)
+
+// RevokedToken records a token deleted before its expiration.
+type RevokedToken struct {
+	// ID is the ID of the token.
+	ID oid.ObjectID `bson:"_id"`
+	// ExpiresAt is the expiration of the token; the record is removed
+	// once the token has expired.
+	ExpiresAt time.Time `bson:"exp"`
+	// Version numbers the revocations consecutively.
+	Version int64 `bson:"version"`
+	// RevokedAt is the time the version was allocated.
+	RevokedAt time.Time `bson:"revoked_ts"`
+}
+
+// RevocationVersion is the version of the last revocation.
+type RevocationVersion struct {
+	Version int64 `bson:"version"`
+	// UpdatedAt is the time the version was last allocated.
+	UpdatedAt time.Time `bson:"updated_ts"`
+}
@@ -0,0 +1,252 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// built-in roles
+	RoleAdmin          = "admin"
+	RoleReadOnly       = "read-only"
+	RoleReleaseManager = "release-manager"
+
+	// MethodAny matches any HTTP method in a permission
+	MethodAny = "*"
+)
+
+var (
+	ErrBuiltInRoleName = errors.New("name is reserved for a built-in role")
+
+	roleNameFormat = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+	readMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+	// logging out, and managing their own account, settings and personal
+	// access tokens, is allowed to every user whatever their roles, see
+	// PermitsSelfService
+	selfServicePermissions = []Permission{
+		{Resource: "useradm:auth:logout", Methods: []string{http.MethodPost}},
+		{Resource: "useradm:users:me", Methods: []string{http.MethodGet, http.MethodPut}},
+		{Resource: "useradm:settings:me", Methods: []string{http.MethodGet, http.MethodPost}},
+		{Resource: "useradm:settings:tokens", Methods: []string{http.MethodGet, http.MethodPost}},
+		{Resource: "useradm:settings:tokens:*", Methods: []string{http.MethodDelete}},
+	}
+
+	// requesting a privilege elevation is allowed to everyone, too;
+	// approving it is left to the admins
+	elevationPermission = Permission{
+		Resource: "useradm:elevations",
+		Methods:  []string{http.MethodPost},
+	}
+
+	builtInRoles = []Role{
+		{
+			Name:        RoleAdmin,
+			Description: "Full access to all the resources",
+			Permissions: []Permission{
+				{Resource: "*", Methods: []string{MethodAny}},
+			},
+			BuiltIn: true,
+		},
+		{
+			Name:        RoleReadOnly,
+			Description: "Read-only access to all the resources",
+			Permissions: []Permission{
+				{Resource: "*", Methods: readMethods},
+				elevationPermission,
+			},
+			BuiltIn: true,
+		},
+		{
+			Name: RoleReleaseManager,
+			Description: "Read-only access to all the resources, " +
+				"full access to the deployments and artifacts",
+			Permissions: []Permission{
+				{Resource: "*", Methods: readMethods},
+				{Resource: "deployments", Methods: []string{MethodAny}},
+				{Resource: "deployments:*", Methods: []string{MethodAny}},
+				elevationPermission,
+			},
+			BuiltIn: true,
+		},
+	}
+)
+
+// Permission allows the HTTP methods on the resources matching the pattern;
+// the resource is the ':'-separated path of the request, starting with the
+// name of the service (e.g. "deployments:deployments:<id>"), and the
+// pattern follows the path.Match syntax (e.g. "deployments:*").
+type Permission struct {
+	Resource string   `json:"resource" bson:"resource"`
+	Methods  []string `json:"methods" bson:"methods"`
+}
+
+func (p Permission) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Resource, validation.Required, lessThan4096,
+			validation.By(func(value interface{}) error {
+				if _, err := path.Match(value.(string), ""); err != nil {
+					return errors.New("must be a valid pattern")
+				}
+				return nil
+			})),
+		validation.Field(&p.Methods, validation.Required,
+			validation.Each(validation.In(
+				MethodAny,
+				http.MethodGet,
+				http.MethodHead,
+				http.MethodPost,
+				http.MethodPut,
+				http.MethodPatch,
+				http.MethodDelete,
+				http.MethodOptions,
+			))),
+	)
+}
+
+// Permits checks if the permission allows the method on the resource.
+func (p Permission) Permits(resource, method string) bool {
+	if ok, _ := path.Match(p.Resource, resource); !ok {
+		return false
+	}
+	for _, m := range p.Methods {
+		if m == MethodAny || strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of permissions assigned to the users; besides the
+// built-in roles, the tenants can define their own.
+type Role struct {
+	// role name, unique within the tenant
+	Name string `json:"name" bson:"name"`
+
+	// free-form description
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+
+	// permission rules of the role
+	Permissions []Permission `json:"permissions" bson:"permissions"`
+
+	// built-in roles cannot be modified or removed
+	BuiltIn bool `json:"built_in" bson:"-"`
+
+	// timestamp of the role creation
+	CreatedTs *time.Time `json:"created_ts,omitempty" bson:"created_ts,omitempty"`
+
+	// timestamp of the last role update
+	UpdatedTs *time.Time `json:"updated_ts,omitempty" bson:"updated_ts,omitempty"`
+}
+
+// Permits checks if any of the role's permissions allows the method on the
+// resource.
+func (r Role) Permits(resource, method string) bool {
+	for _, p := range r.Permissions {
+		if p.Permits(resource, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermitsSelfService checks if the method on the resource is one the users
+// are allowed on their own account, whatever their roles.
+func PermitsSelfService(resource, method string) bool {
+	for _, p := range selfServicePermissions {
+		if p.Permits(resource, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltInRoles returns the roles available to all the tenants.
+func BuiltInRoles() []Role {
+	ret := make([]Role, len(builtInRoles))
+	copy(ret, builtInRoles)
+	return ret
+}
+
+// BuiltInRole returns the built-in role with the given name or nil.
+func BuiltInRole(name string) *Role {
+	for i := range builtInRoles {
+		if builtInRoles[i].Name == name {
+			role := builtInRoles[i]
+			return &role
+		}
+	}
+	return nil
+}
+
+type RoleNew struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+func (r RoleNew) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, lessThan128,
+			validation.Match(roleNameFormat),
+			validation.By(func(value interface{}) error {
+				if BuiltInRole(value.(string)) != nil {
+					return ErrBuiltInRoleName
+				}
+				return nil
+			})),
+		validation.Field(&r.Description, lessThan4096),
+		validation.Field(&r.Permissions, validation.Required),
+	)
+}
+
+type RoleUpdate struct {
+	Description *string       `json:"description,omitempty" bson:"description,omitempty"`
+	Permissions *[]Permission `json:"permissions,omitempty" bson:"permissions,omitempty"`
+
+	// timestamp of the last role update
+	UpdatedTs *time.Time `json:"-" bson:"updated_ts,omitempty"`
+}
+
+func (r RoleUpdate) Validate() error {
+	if r.Description == nil && r.Permissions == nil {
+		return ErrEmptyUpdate
+	}
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Description, lessThan4096),
+		validation.Field(&r.Permissions, validation.NilOrNotEmpty),
+	)
+}
+
+// UserRoles is the list of the roles assigned to a user.
+type UserRoles struct {
+	Roles []string `json:"roles"`
+}
+
+func (r UserRoles) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Roles, validation.Required,
+			validation.Each(validation.Required, lessThan128)),
+	)
+}
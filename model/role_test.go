@@ -0,0 +1,202 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionPermits(t *testing.T) {
+	testCases := map[string]struct {
+		permission Permission
+		resource   string
+		method     string
+
+		permitted bool
+	}{
+		"any resource, any method": {
+			permission: Permission{Resource: "*", Methods: []string{MethodAny}},
+			resource:   "useradm:users:1234",
+			method:     "DELETE",
+			permitted:  true,
+		},
+		"service prefix": {
+			permission: Permission{Resource: "deployments:*", Methods: []string{"GET"}},
+			resource:   "deployments:deployments:1234",
+			method:     "get",
+			permitted:  true,
+		},
+		"service prefix, other service": {
+			permission: Permission{Resource: "deployments:*", Methods: []string{"GET"}},
+			resource:   "inventory:devices",
+			method:     "GET",
+		},
+		"method not allowed": {
+			permission: Permission{Resource: "deployments:*", Methods: []string{"GET"}},
+			resource:   "deployments:deployments",
+			method:     "POST",
+		},
+		"exact resource": {
+			permission: Permission{Resource: "useradm:auth:logout", Methods: []string{"POST"}},
+			resource:   "useradm:auth:logout",
+			method:     "POST",
+			permitted:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.permitted, tc.permission.Permits(tc.resource, tc.method))
+		})
+	}
+}
+
+func TestBuiltInRoles(t *testing.T) {
+	admin := BuiltInRole(RoleAdmin)
+	if assert.NotNil(t, admin) {
+		assert.True(t, admin.BuiltIn)
+		assert.True(t, admin.Permits("useradm:users", "POST"))
+	}
+
+	readOnly := BuiltInRole(RoleReadOnly)
+	if assert.NotNil(t, readOnly) {
+		assert.True(t, readOnly.Permits("inventory:devices", "GET"))
+		assert.False(t, readOnly.Permits("inventory:devices", "PUT"))
+		assert.True(t, readOnly.Permits("useradm:elevations", "POST"))
+		assert.False(t, readOnly.Permits("useradm:elevations:1:approve", "POST"))
+	}
+
+	releaseManager := BuiltInRole(RoleReleaseManager)
+	if assert.NotNil(t, releaseManager) {
+		assert.True(t, releaseManager.Permits("deployments:artifacts", "POST"))
+		assert.True(t, releaseManager.Permits("inventory:devices", "GET"))
+		assert.False(t, releaseManager.Permits("useradm:users", "POST"))
+		assert.True(t, releaseManager.Permits("useradm:elevations", "POST"))
+		assert.False(t, releaseManager.Permits("useradm:elevations:1:approve", "POST"))
+	}
+
+	assert.Nil(t, BuiltInRole("foo"))
+	assert.Len(t, BuiltInRoles(), 3)
+}
+
+func TestPermitsSelfService(t *testing.T) {
+	assert.True(t, PermitsSelfService("useradm:auth:logout", "POST"))
+	assert.True(t, PermitsSelfService("useradm:users:me", "GET"))
+	assert.True(t, PermitsSelfService("useradm:users:me", "PUT"))
+	assert.False(t, PermitsSelfService("useradm:users:me", "DELETE"))
+	assert.False(t, PermitsSelfService("useradm:users:1", "PUT"))
+	assert.True(t, PermitsSelfService("useradm:settings:me", "POST"))
+	assert.False(t, PermitsSelfService("useradm:settings", "POST"))
+	assert.True(t, PermitsSelfService("useradm:settings:tokens", "POST"))
+	assert.True(t, PermitsSelfService("useradm:settings:tokens:1", "DELETE"))
+	assert.False(t, PermitsSelfService("useradm:tokens", "DELETE"))
+}
+
+func TestRoleNewValidate(t *testing.T) {
+	testCases := map[string]struct {
+		role RoleNew
+
+		outErr string
+	}{
+		"ok": {
+			role: RoleNew{
+				Name: "device-operator",
+				Permissions: []Permission{
+					{Resource: "deviceconnect:*", Methods: []string{"*"}},
+				},
+			},
+		},
+		"error: no name": {
+			role: RoleNew{
+				Permissions: []Permission{
+					{Resource: "*", Methods: []string{"GET"}},
+				},
+			},
+			outErr: "name: cannot be blank.",
+		},
+		"error: invalid name": {
+			role: RoleNew{
+				Name: "device operator",
+				Permissions: []Permission{
+					{Resource: "*", Methods: []string{"GET"}},
+				},
+			},
+			outErr: "name: must be in a valid format.",
+		},
+		"error: built-in role name": {
+			role: RoleNew{
+				Name: RoleAdmin,
+				Permissions: []Permission{
+					{Resource: "*", Methods: []string{"GET"}},
+				},
+			},
+			outErr: "name: name is reserved for a built-in role.",
+		},
+		"error: no permissions": {
+			role: RoleNew{
+				Name: "foo",
+			},
+			outErr: "permissions: cannot be blank.",
+		},
+		"error: invalid pattern": {
+			role: RoleNew{
+				Name: "foo",
+				Permissions: []Permission{
+					{Resource: "deployments:[", Methods: []string{"GET"}},
+				},
+			},
+			outErr: "permissions: (0: (resource: must be a valid pattern.).).",
+		},
+		"error: invalid method": {
+			role: RoleNew{
+				Name: "foo",
+				Permissions: []Permission{
+					{Resource: "*", Methods: []string{"FOO"}},
+				},
+			},
+			outErr: "permissions: (0: (methods: (0: must be a valid value.).).).",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.role.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestRoleUpdateValidate(t *testing.T) {
+	description := "foo"
+	noPermissions := []Permission{}
+
+	assert.EqualError(t, RoleUpdate{}.Validate(), ErrEmptyUpdate.Error())
+	assert.NoError(t, RoleUpdate{Description: &description}.Validate())
+	assert.EqualError(t, RoleUpdate{Permissions: &noPermissions}.Validate(),
+		"permissions: cannot be blank.")
+}
+
+func TestUserRolesValidate(t *testing.T) {
+	assert.NoError(t, UserRoles{Roles: []string{RoleAdmin}}.Validate())
+	assert.EqualError(t, UserRoles{}.Validate(), "roles: cannot be blank.")
+	assert.EqualError(t, UserRoles{Roles: []string{""}}.Validate(),
+		"roles: (0: cannot be blank.).")
+}
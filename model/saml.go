@@ -0,0 +1,77 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// SAMLConfig is the tenant's configuration of the SAML 2.0 single sign-on,
+// with useradm acting as the service provider.
+type SAMLConfig struct {
+	// XML metadata of the identity provider
+	IdPMetadata string `json:"idp_metadata" bson:"idp_metadata"`
+
+	// PEM-encoded signing certificate of the identity provider; replaces
+	// the certificates of the metadata if set
+	IdPCertificate string `json:"idp_certificate,omitempty" bson:"idp_certificate,omitempty"`
+
+	// how the assertion attributes map to the user's properties
+	AttributeMapping SAMLAttributeMapping `json:"attribute_mapping" bson:"attribute_mapping"`
+
+	// whether to accept the logins started at the identity provider,
+	// not in response to an authentication request of useradm
+	AllowIdPInitiated bool `json:"allow_idp_initiated" bson:"allow_idp_initiated"`
+}
+
+func (c SAMLConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.IdPMetadata, validation.Required, lessThan1M),
+		validation.Field(&c.IdPCertificate, lessThan1M, pemCertificate),
+		validation.Field(&c.AttributeMapping),
+	)
+}
+
+type SAMLAttributeMapping struct {
+	// name of the attribute holding the user's email; the subject's
+	// NameID is used if empty
+	Email string `json:"email,omitempty" bson:"email,omitempty"`
+}
+
+func (m SAMLAttributeMapping) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Email, lessThan4096),
+	)
+}
+
+// SAMLAuthnRequest is the authentication request sent to the identity
+// provider with the HTTP-Redirect binding.
+type SAMLAuthnRequest struct {
+	// ID of the request, matched against the response
+	ID string
+	// URL of the identity provider carrying the request
+	URL string
+}
+
+// SAMLServiceProvider describes the endpoints of useradm acting as the
+// service provider for the tenant.
+type SAMLServiceProvider struct {
+	TenantID string
+	// public URL of the service provider metadata, also used as
+	// the entity ID
+	MetadataURL string
+	// public URL of the assertion consumer service
+	ACSURL string
+}
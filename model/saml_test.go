@@ -0,0 +1,88 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSAMLConfigValidate(t *testing.T) {
+	testCases := map[string]struct {
+		config SAMLConfig
+
+		outErr string
+	}{
+		"ok": {
+			config: SAMLConfig{
+				IdPMetadata: "<EntityDescriptor/>",
+			},
+		},
+		"ok, certificate and mapping": {
+			config: SAMLConfig{
+				IdPMetadata: "<EntityDescriptor/>",
+				IdPCertificate: "-----BEGIN CERTIFICATE-----\n" +
+					"MIIBszCCAV2gAwIBAgIJAJ==\n" +
+					"-----END CERTIFICATE-----\n",
+				AttributeMapping: SAMLAttributeMapping{
+					Email: "mail",
+				},
+			},
+		},
+		"error: no metadata": {
+			config: SAMLConfig{},
+			outErr: "idp_metadata: cannot be blank.",
+		},
+		"error: bad certificate": {
+			config: SAMLConfig{
+				IdPMetadata:    "<EntityDescriptor/>",
+				IdPCertificate: "foo",
+			},
+			outErr: "idp_certificate: must be a PEM-encoded certificate.",
+		},
+		"error: not a certificate": {
+			config: SAMLConfig{
+				IdPMetadata: "<EntityDescriptor/>",
+				IdPCertificate: "-----BEGIN PUBLIC KEY-----\n" +
+					"MIIBszCCAV2gAwIBAgIJAJ==\n" +
+					"-----END PUBLIC KEY-----\n",
+			},
+			outErr: "idp_certificate: must be a PEM-encoded certificate.",
+		},
+		"error: attribute name too long": {
+			config: SAMLConfig{
+				IdPMetadata: "<EntityDescriptor/>",
+				AttributeMapping: SAMLAttributeMapping{
+					Email: strings.Repeat("a", 4097),
+				},
+			},
+			outErr: "attribute_mapping: (email: the length must be " +
+				"no more than 4096.).",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// SCIM 2.0 (RFC 7643, RFC 7644) schema URNs
+const (
+	SCIMSchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMSchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMSchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMSchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMSchemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// SCIM error types (RFC 7644, section 3.12)
+const (
+	SCIMErrInvalidFilter = "invalidFilter"
+	SCIMErrInvalidSyntax = "invalidSyntax"
+	SCIMErrInvalidPath   = "invalidPath"
+	SCIMErrInvalidValue  = "invalidValue"
+	SCIMErrNoTarget      = "noTarget"
+	SCIMErrUniqueness    = "uniqueness"
+	SCIMErrMutability    = "mutability"
+)
+
+// SCIMToken is the bearer token authenticating the tenant's SCIM client,
+// e.g. the provisioning agent of the identity provider.
+type SCIMToken struct {
+	// the token itself; returned only once, when generated
+	Token string `json:"token,omitempty" bson:"-"`
+
+	// SHA-256 hash of the token, hex-encoded; never exposed
+	Hash string `json:"-" bson:"hash"`
+
+	// ID of the owning tenant; set only when the token is looked up
+	// by its hash
+	TenantID string `json:"-" bson:"tenant_id,omitempty"`
+
+	// roles of the users created through SCIM; they get read-only access
+	// if empty, as the users created through the API
+	ProvisioningRoles []string `json:"provisioning_roles,omitempty" bson:"provisioning_roles,omitempty"`
+
+	// timestamp of the token creation
+	CreatedTs time.Time `json:"created_ts" bson:"created_ts"`
+}
+
+// SCIMTokenRequest holds the settings of a new SCIM token.
+type SCIMTokenRequest struct {
+	ProvisioningRoles []string `json:"provisioning_roles,omitempty"`
+}
+
+func (r SCIMTokenRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.ProvisioningRoles,
+			validation.Each(validation.Required, lessThan128)),
+	)
+}
+
+// SCIMError is the SCIM representation of an error; it is also returned
+// by the functions processing the SCIM requests.
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func NewSCIMError(status int, scimType, detail string) *SCIMError {
+	return &SCIMError{
+		Schemas:  []string{SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+func (err *SCIMError) Error() string {
+	return err.Detail
+}
+
+// SCIMMeta holds the resource metadata; Version is the weak ETag of the
+// resource.
+type SCIMMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+	Version      string     `json:"version,omitempty"`
+}
+
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMMember references a user (member of a group) or a group (of which
+// the user is a member).
+type SCIMMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// SCIMUser is the SCIM representation of a user; the user name is the
+// email address of the user.
+type SCIMUser struct {
+	Schemas  []string     `json:"schemas"`
+	ID       string       `json:"id,omitempty"`
+	UserName string       `json:"userName"`
+	Password string       `json:"password,omitempty"`
+	Active   *bool        `json:"active,omitempty"`
+	Emails   []SCIMEmail  `json:"emails,omitempty"`
+	Groups   []SCIMMember `json:"groups,omitempty"`
+	Meta     *SCIMMeta    `json:"meta,omitempty"`
+}
+
+// Email returns the email address of the user: the user name, or the
+// primary email address if the user name is not set.
+func (u SCIMUser) Email() Email {
+	if u.UserName != "" || len(u.Emails) == 0 {
+		return Email(strings.ToLower(u.UserName))
+	}
+	email := u.Emails[0].Value
+	for _, e := range u.Emails {
+		if e.Primary {
+			email = e.Value
+			break
+		}
+	}
+	return Email(strings.ToLower(email))
+}
+
+// IsActive returns false only if the user is explicitly deactivated.
+func (u SCIMUser) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+func (u SCIMUser) Validate() error {
+	err := validation.Errors{
+		"userName": validation.Validate(u.Email(), validation.Required),
+		"password": validation.Validate(u.Password, lessThan4096),
+	}.Filter()
+	if err != nil {
+		return err
+	}
+	if u.Password != "" && len(u.Password) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	return nil
+}
+
+// SCIMGroup is the SCIM representation of a group.
+type SCIMGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members,omitempty"`
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+func (g SCIMGroup) Validate() error {
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.DisplayName, validation.Required, lessThan128),
+		validation.Field(&g.Members, validation.Each(
+			validation.By(func(value interface{}) error {
+				member, _ := value.(SCIMMember)
+				return validation.Validate(member.Value, validation.Required)
+			}),
+		)),
+	)
+}
+
+// SCIMListResponse is a page of the resources matching a query.
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// SCIMFilter is an equality filter, the only kind of filter supported:
+// <attribute> eq "<value>".
+type SCIMFilter struct {
+	// attribute name, lowercase
+	Attribute string
+	Value     string
+}
+
+var scimFilterRegexp = regexp.MustCompile(
+	`^\s*([A-Za-z][A-Za-z0-9.]*)\s+(?i:eq)\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// ParseSCIMFilter parses the filter query parameter; the empty filter
+// results in nil.
+func ParseSCIMFilter(filter string) (*SCIMFilter, error) {
+	if filter == "" {
+		return nil, nil
+	}
+	match := scimFilterRegexp.FindStringSubmatch(filter)
+	if match == nil {
+		return nil, NewSCIMError(http.StatusBadRequest, SCIMErrInvalidFilter,
+			`only the filters of the form: attribute eq "value" are supported`)
+	}
+	value, err := strconv.Unquote(match[2])
+	if err != nil {
+		return nil, NewSCIMError(http.StatusBadRequest, SCIMErrInvalidFilter,
+			"invalid filter value")
+	}
+	return &SCIMFilter{
+		Attribute: strings.ToLower(match[1]),
+		Value:     value,
+	}, nil
+}
+
+// SCIMPatchRequest is a list of modifications of a resource.
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+type SCIMPatchOperation struct {
+	// add, remove or replace (case insensitive)
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (p SCIMPatchRequest) Validate() error {
+	if len(p.Operations) == 0 {
+		return NewSCIMError(http.StatusBadRequest, SCIMErrInvalidValue,
+			"no operations provided")
+	}
+	for _, op := range p.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if len(op.Value) == 0 {
+				return NewSCIMError(http.StatusBadRequest, SCIMErrInvalidValue,
+					"missing value of the "+op.Op+" operation")
+			}
+		case "remove":
+			if op.Path == "" {
+				return NewSCIMError(http.StatusBadRequest, SCIMErrNoTarget,
+					"missing path of the remove operation")
+			}
+		default:
+			return NewSCIMError(http.StatusBadRequest, SCIMErrInvalidSyntax,
+				"unsupported operation: "+op.Op)
+		}
+	}
+	return nil
+}
+
+var scimMemberPathRegexp = regexp.MustCompile(
+	`^(?i:members)\[\s*(?i:value)\s+(?i:eq)\s+("(?:[^"\\]|\\.)*")\s*\]$`)
+
+var scimEmailPathRegexp = regexp.MustCompile(`^(?i:emails)(\[[^\]]*\])?(?i:\.value)?$`)
+
+// ApplyToUser applies the operations to the user; the supported
+// attributes are userName, emails, password and active.
+func (p SCIMPatchRequest) ApplyToUser(user *SCIMUser) error {
+	for _, op := range p.Operations {
+		if strings.EqualFold(op.Op, "remove") {
+			return NewSCIMError(http.StatusBadRequest, SCIMErrMutability,
+				"the attribute cannot be removed: "+op.Path)
+		}
+		if op.Path == "" {
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return NewSCIMError(http.StatusBadRequest, SCIMErrInvalidValue,
+					"the value must be an object")
+			}
+			for name, value := range attrs {
+				if err := setSCIMUserAttribute(user, name, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := setSCIMUserAttribute(user, op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setSCIMUserAttribute(user *SCIMUser, path string, value json.RawMessage) error {
+	invalid := NewSCIMError(http.StatusBadRequest, SCIMErrInvalidValue,
+		"invalid value of "+path)
+	switch {
+	case strings.EqualFold(path, "userName"):
+		if err := json.Unmarshal(value, &user.UserName); err != nil {
+			return invalid
+		}
+	case strings.EqualFold(path, "password"):
+		if err := json.Unmarshal(value, &user.Password); err != nil {
+			return invalid
+		}
+	case strings.EqualFold(path, "active"):
+		active, err := parseSCIMBool(value)
+		if err != nil {
+			return invalid
+		}
+		user.Active = &active
+	case strings.EqualFold(path, "emails"):
+		var emails []SCIMEmail
+		if err := json.Unmarshal(value, &emails); err != nil || len(emails) == 0 {
+			return invalid
+		}
+		user.UserName = ""
+		user.Emails = emails
+		user.UserName = string(user.Email())
+	case scimEmailPathRegexp.MatchString(path):
+		var email string
+		if err := json.Unmarshal(value, &email); err != nil {
+			return invalid
+		}
+		user.UserName = email
+	case strings.EqualFold(path, "schemas"), strings.EqualFold(path, "externalId"):
+		// not stored
+	default:
+		return NewSCIMError(http.StatusBadRequest, SCIMErrInvalidPath,
+			"unsupported attribute: "+path)
+	}
+	return nil
+}
+
+// parseSCIMBool accepts the booleans sent as strings by some clients
+func parseSCIMBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(s))
+}
+
+// ApplyToGroup applies the operations to the group; the supported
+// attributes are displayName and members.
+func (p SCIMPatchRequest) ApplyToGroup(group *SCIMGroup) error {
+	for _, op := range p.Operations {
+		if op.Path == "" {
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return NewSCIMError(http.StatusBadRequest, SCIMErrInvalidValue,
+					"the value must be an object")
+			}
+			for name, value := range attrs {
+				err := patchSCIMGroupAttribute(group, op.Op, name, value)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := patchSCIMGroupAttribute(group, op.Op, op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func patchSCIMGroupAttribute(
+	group *SCIMGroup,
+	op, path string,
+	value json.RawMessage,
+) error {
+	op = strings.ToLower(op)
+	invalid := NewSCIMError(http.StatusBadRequest, SCIMErrInvalidValue,
+		"invalid value of "+path)
+
+	if match := scimMemberPathRegexp.FindStringSubmatch(path); match != nil {
+		if op != "remove" {
+			return NewSCIMError(http.StatusBadRequest, SCIMErrInvalidPath,
+				"members can only be removed by value")
+		}
+		id, err := strconv.Unquote(match[1])
+		if err != nil {
+			return invalid
+		}
+		group.Members = removeSCIMMembers(group.Members, id)
+		return nil
+	}
+
+	switch {
+	case strings.EqualFold(path, "displayName"):
+		if op == "remove" {
+			return NewSCIMError(http.StatusBadRequest, SCIMErrMutability,
+				"the attribute cannot be removed: "+path)
+		}
+		if err := json.Unmarshal(value, &group.DisplayName); err != nil {
+			return invalid
+		}
+	case strings.EqualFold(path, "members"):
+		var members []SCIMMember
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &members); err != nil {
+				return invalid
+			}
+		}
+		switch op {
+		case "add":
+			for _, m := range members {
+				group.Members = append(
+					removeSCIMMembers(group.Members, m.Value), m)
+			}
+		case "replace":
+			group.Members = members
+		case "remove":
+			if len(value) == 0 {
+				group.Members = nil
+			}
+			for _, m := range members {
+				group.Members = removeSCIMMembers(group.Members, m.Value)
+			}
+		}
+	case strings.EqualFold(path, "schemas"), strings.EqualFold(path, "externalId"):
+		// not stored
+	default:
+		return NewSCIMError(http.StatusBadRequest, SCIMErrInvalidPath,
+			"unsupported attribute: "+path)
+	}
+	return nil
+}
+
+func removeSCIMMembers(members []SCIMMember, id string) []SCIMMember {
+	result := make([]SCIMMember, 0, len(members))
+	for _, m := range members {
+		if m.Value != id {
+			result = append(result, m)
+		}
+	}
+	return result
+}
@@ -0,0 +1,259 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSCIMFilter(t *testing.T) {
+	testCases := map[string]struct {
+		filter string
+
+		outFilter *SCIMFilter
+		outErr    string
+	}{
+		"ok, empty": {},
+		"ok": {
+			filter:    `userName eq "foo@acme.com"`,
+			outFilter: &SCIMFilter{Attribute: "username", Value: "foo@acme.com"},
+		},
+		"ok, case insensitive": {
+			filter:    ` emails.value EQ "foo \"bar\"" `,
+			outFilter: &SCIMFilter{Attribute: "emails.value", Value: `foo "bar"`},
+		},
+		"error: unsupported operator": {
+			filter: `userName co "foo"`,
+			outErr: `only the filters of the form: attribute eq "value" are supported`,
+		},
+		"error: compound filter": {
+			filter: `userName eq "foo" and active eq "true"`,
+			outErr: `only the filters of the form: attribute eq "value" are supported`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			filter, err := ParseSCIMFilter(tc.filter)
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.outFilter, filter)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+				assert.IsType(t, &SCIMError{}, err)
+			}
+		})
+	}
+}
+
+func TestSCIMUserValidate(t *testing.T) {
+	testCases := map[string]struct {
+		user SCIMUser
+
+		outErr string
+	}{
+		"ok": {
+			user: SCIMUser{UserName: "foo@acme.com"},
+		},
+		"ok, email": {
+			user: SCIMUser{Emails: []SCIMEmail{{Value: "foo@acme.com"}}},
+		},
+		"error: no user name": {
+			user:   SCIMUser{},
+			outErr: "userName: cannot be blank.",
+		},
+		"error: password too short": {
+			user:   SCIMUser{UserName: "foo@acme.com", Password: "foo"},
+			outErr: ErrPasswordTooShort.Error(),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.user.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestSCIMPatchRequestApplyToUser(t *testing.T) {
+	active := true
+
+	testCases := map[string]struct {
+		ops []SCIMPatchOperation
+
+		outUser SCIMUser
+		outErr  string
+	}{
+		"ok, deactivate": {
+			ops: []SCIMPatchOperation{{
+				Op:    "Replace",
+				Path:  "active",
+				Value: json.RawMessage(`"False"`),
+			}},
+			outUser: SCIMUser{UserName: "foo@acme.com", Active: new(bool)},
+		},
+		"ok, no path": {
+			ops: []SCIMPatchOperation{{
+				Op: "replace",
+				Value: json.RawMessage(
+					`{"userName": "bar@acme.com", "externalId": "42"}`),
+			}},
+			outUser: SCIMUser{UserName: "bar@acme.com", Active: &active},
+		},
+		"ok, primary email": {
+			ops: []SCIMPatchOperation{{
+				Op:   "replace",
+				Path: "emails",
+				Value: json.RawMessage(`[{"value": "bar@acme.com"},` +
+					`{"value": "Baz@acme.com", "primary": true}]`),
+			}},
+			outUser: SCIMUser{
+				UserName: "baz@acme.com",
+				Active:   &active,
+				Emails: []SCIMEmail{
+					{Value: "bar@acme.com"},
+					{Value: "Baz@acme.com", Primary: true},
+				},
+			},
+		},
+		"ok, email value": {
+			ops: []SCIMPatchOperation{{
+				Op:    "replace",
+				Path:  `emails[type eq "work"].value`,
+				Value: json.RawMessage(`"bar@acme.com"`),
+			}},
+			outUser: SCIMUser{UserName: "bar@acme.com", Active: &active},
+		},
+		"error: remove": {
+			ops: []SCIMPatchOperation{{
+				Op:   "remove",
+				Path: "userName",
+			}},
+			outErr: "the attribute cannot be removed: userName",
+		},
+		"error: invalid value": {
+			ops: []SCIMPatchOperation{{
+				Op:    "replace",
+				Path:  "active",
+				Value: json.RawMessage(`"maybe"`),
+			}},
+			outErr: "invalid value of active",
+		},
+		"error: unsupported attribute": {
+			ops: []SCIMPatchOperation{{
+				Op:    "add",
+				Path:  "nickName",
+				Value: json.RawMessage(`"foo"`),
+			}},
+			outErr: "unsupported attribute: nickName",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			user := SCIMUser{UserName: "foo@acme.com", Active: &active}
+			err := SCIMPatchRequest{Operations: tc.ops}.ApplyToUser(&user)
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.outUser, user)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestSCIMPatchRequestApplyToGroup(t *testing.T) {
+	testCases := map[string]struct {
+		ops []SCIMPatchOperation
+
+		outGroup SCIMGroup
+		outErr   string
+	}{
+		"ok, add members": {
+			ops: []SCIMPatchOperation{{
+				Op:    "add",
+				Path:  "members",
+				Value: json.RawMessage(`[{"value": "2"}, {"value": "1"}]`),
+			}},
+			outGroup: SCIMGroup{
+				DisplayName: "devs",
+				Members:     []SCIMMember{{Value: "2"}, {Value: "1"}},
+			},
+		},
+		"ok, replace members": {
+			ops: []SCIMPatchOperation{{
+				Op:    "replace",
+				Path:  "members",
+				Value: json.RawMessage(`[{"value": "2"}]`),
+			}},
+			outGroup: SCIMGroup{
+				DisplayName: "devs",
+				Members:     []SCIMMember{{Value: "2"}},
+			},
+		},
+		"ok, remove member": {
+			ops: []SCIMPatchOperation{{
+				Op:   "remove",
+				Path: `members[value eq "1"]`,
+			}},
+			outGroup: SCIMGroup{
+				DisplayName: "devs",
+				Members:     []SCIMMember{},
+			},
+		},
+		"ok, rename": {
+			ops: []SCIMPatchOperation{{
+				Op:    "replace",
+				Value: json.RawMessage(`{"displayName": "developers"}`),
+			}},
+			outGroup: SCIMGroup{
+				DisplayName: "developers",
+				Members:     []SCIMMember{{Value: "1"}},
+			},
+		},
+		"error: remove name": {
+			ops: []SCIMPatchOperation{{
+				Op:   "remove",
+				Path: "displayName",
+			}},
+			outErr: "the attribute cannot be removed: displayName",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			group := SCIMGroup{
+				DisplayName: "devs",
+				Members:     []SCIMMember{{Value: "1"}},
+			}
+			err := SCIMPatchRequest{Operations: tc.ops}.ApplyToGroup(&group)
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.outGroup, group)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ServiceAccount is a non-human principal owned by the tenant, meant for
+// machine-to-machine access (e.g. CI pipelines). It has no password and
+// cannot log in; it authenticates with its client secrets instead.
+type ServiceAccount struct {
+	// system-generated service account ID, also used as the client ID
+	ID string `json:"id" bson:"_id"`
+
+	// service account name, unique within the tenant
+	Name string `json:"name" bson:"name"`
+
+	// free-form description
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+
+	// PEM-encoded RSA public key used to verify the client assertions
+	// (RFC 7523) signed by the service account
+	PublicKey string `json:"public_key,omitempty" bson:"public_key,omitempty"`
+
+	// hashed client secrets; never exposed through the API
+	Secrets []ServiceAccountSecret `json:"-" bson:"secrets,omitempty"`
+
+	// ID of the owning tenant; set only when the service account
+	// is looked up by its client ID
+	TenantID string `json:"-" bson:"tenant_id,omitempty"`
+
+	// timestamp of the service account creation
+	CreatedTs *time.Time `json:"created_ts,omitempty" bson:"created_ts,omitempty"`
+
+	// timestamp of the last service account update
+	UpdatedTs *time.Time `json:"updated_ts,omitempty" bson:"updated_ts,omitempty"`
+}
+
+// VerifySecret checks the client secret against the secrets of the
+// service account which are still active.
+func (sa ServiceAccount) VerifySecret(secret string, now time.Time) bool {
+	for _, s := range sa.Secrets {
+		if !s.Active(now) {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(s.Hash), []byte(secret)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceAccountSecret is a hashed client secret of a service account.
+type ServiceAccountSecret struct {
+	// bcrypt hash of the secret
+	Hash string `json:"-" bson:"hash"`
+
+	// timestamp of the secret creation
+	CreatedTs time.Time `json:"created_ts" bson:"created_ts"`
+
+	// the secret is not accepted after this time; set on the previous
+	// secrets when the secret is rotated
+	ExpiresTs *time.Time `json:"expires_ts,omitempty" bson:"expires_ts,omitempty"`
+}
+
+// Active checks if the secret can still be used at the given time.
+func (s ServiceAccountSecret) Active(now time.Time) bool {
+	return s.ExpiresTs == nil || now.Before(*s.ExpiresTs)
+}
+
+type ServiceAccountNew struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PublicKey   string `json:"public_key"`
+}
+
+func (sa ServiceAccountNew) Validate() error {
+	return validation.ValidateStruct(&sa,
+		validation.Field(&sa.Name, validation.Required, lessThan128),
+		validation.Field(&sa.Description, lessThan4096),
+		validation.Field(&sa.PublicKey, rsaPublicKey),
+	)
+}
+
+type ServiceAccountUpdate struct {
+	Name        *string `json:"name,omitempty" bson:"name,omitempty"`
+	Description *string `json:"description,omitempty" bson:"description,omitempty"`
+	PublicKey   *string `json:"public_key,omitempty" bson:"public_key,omitempty"`
+
+	// timestamp of the last service account update
+	UpdatedTs *time.Time `json:"-" bson:"updated_ts,omitempty"`
+}
+
+func (sa ServiceAccountUpdate) Validate() error {
+	if sa.Name == nil && sa.Description == nil && sa.PublicKey == nil {
+		return ErrEmptyUpdate
+	}
+	return validation.ValidateStruct(&sa,
+		validation.Field(&sa.Name, validation.NilOrNotEmpty, lessThan128),
+		validation.Field(&sa.Description, lessThan4096),
+		validation.Field(&sa.PublicKey, rsaPublicKey),
+	)
+}
+
+// ServiceAccountCredentials are the credentials of a service account;
+// the client secret is only available when it is generated.
+type ServiceAccountCredentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// ServiceAccountWithCredentials is returned when a service account is
+// created, the only time its client secret is visible.
+type ServiceAccountWithCredentials struct {
+	ServiceAccount
+	ServiceAccountCredentials
+}
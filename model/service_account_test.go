@@ -0,0 +1,149 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestServiceAccountNewValidate(t *testing.T) {
+	testCases := map[string]struct {
+		sa ServiceAccountNew
+
+		outErr string
+	}{
+		"ok": {
+			sa: ServiceAccountNew{
+				Name:        "ci",
+				Description: "pipeline",
+			},
+		},
+		"error: no name": {
+			sa: ServiceAccountNew{
+				Description: "pipeline",
+			},
+			outErr: "name: cannot be blank.",
+		},
+		"error: name too long": {
+			sa: ServiceAccountNew{
+				Name: strings.Repeat("a", 129),
+			},
+			outErr: "name: the length must be no more than 128.",
+		},
+		"ok, public key": {
+			sa: ServiceAccountNew{
+				Name:      "ci",
+				PublicKey: "public",
+			},
+		},
+		"error: invalid public key": {
+			sa: ServiceAccountNew{
+				Name:      "ci",
+				PublicKey: "not a key",
+			},
+			outErr: "public_key: must be a PEM-encoded RSA public key.",
+		},
+	}
+
+	publicKey, err := ioutil.ReadFile("../crypto/public.pem")
+	assert.NoError(t, err)
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if tc.sa.PublicKey == "public" {
+				tc.sa.PublicKey = string(publicKey)
+			}
+			err := tc.sa.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestServiceAccountUpdateValidate(t *testing.T) {
+	name := "ci"
+	empty := ""
+
+	testCases := map[string]struct {
+		sa ServiceAccountUpdate
+
+		outErr string
+	}{
+		"ok": {
+			sa: ServiceAccountUpdate{
+				Name: &name,
+			},
+		},
+		"ok, clear description": {
+			sa: ServiceAccountUpdate{
+				Description: &empty,
+			},
+		},
+		"error: empty update": {
+			sa:     ServiceAccountUpdate{},
+			outErr: ErrEmptyUpdate.Error(),
+		},
+		"error: empty name": {
+			sa: ServiceAccountUpdate{
+				Name: &empty,
+			},
+			outErr: "name: cannot be blank.",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.sa.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestServiceAccountVerifySecret(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	expiring := now.Add(time.Minute)
+	hash := func(secret string) string {
+		h, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+		assert.NoError(t, err)
+		return string(h)
+	}
+
+	sa := ServiceAccount{
+		Secrets: []ServiceAccountSecret{
+			{Hash: hash("expired"), ExpiresTs: &expired},
+			{Hash: hash("previous"), ExpiresTs: &expiring},
+			{Hash: hash("current")},
+		},
+	}
+
+	assert.True(t, sa.VerifySecret("current", now))
+	assert.True(t, sa.VerifySecret("previous", now))
+	assert.False(t, sa.VerifySecret("previous", expiring))
+	assert.False(t, sa.VerifySecret("expired", now))
+	assert.False(t, sa.VerifySecret("foo", now))
+}
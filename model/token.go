@@ -22,11 +22,15 @@ import (
 	"github.com/mendersoftware/go-lib-micro/mongo/oid"
 
 	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/scope"
 )
 
 type TokenRequest struct {
 	Name      *string `json:"name"`
 	ExpiresIn int64   `json:"expires_in"`
+	// Scopes limits the token to a subset of the owner's permissions,
+	// defaults to the full scope of the owner
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 const defaultTokenMaxExpiration = 31536000
@@ -38,7 +42,8 @@ func (tr TokenRequest) Validate(maxExpiration int) error {
 	return validation.ValidateStruct(&tr,
 		validation.Field(&tr.Name, validation.Required, lessThan4096),
 		validation.Field(
-			&tr.ExpiresIn, validation.Required, validation.Min(1), validation.Max(maxExpiration)))
+			&tr.ExpiresIn, validation.Required, validation.Min(1), validation.Max(maxExpiration)),
+		validation.Field(&tr.Scopes, validation.Each(knownScope)))
 }
 
 type PersonalAccessToken struct {
@@ -52,6 +57,8 @@ type PersonalAccessToken struct {
 	ExpirationDate jwt.Time `json:"expiration_date,omitempty" bson:"exp,omitempty"`
 	// CreatedTs is the absolute time the token was created.
 	CreatedTs jwt.Time `json:"created_ts,omitempty" bson:"iat,omitempty"`
+	// Scope is the space-delimited list of the token's scopes
+	Scope string `json:"-" bson:"scp,omitempty"`
 }
 
 type apiToken struct {
@@ -65,6 +72,8 @@ type apiToken struct {
 	ExpirationDate *time.Time `json:"expiration_date,omitempty"`
 	// timestamp of the token creation
 	CreatedTs *time.Time `json:"created_ts,omitempty"`
+	// scopes of the token
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 func newApiToken(t PersonalAccessToken) apiToken {
@@ -74,6 +83,7 @@ func newApiToken(t PersonalAccessToken) apiToken {
 		t.LastUsed,
 		&t.ExpirationDate.Time,
 		&t.CreatedTs.Time,
+		scope.Parse(t.Scope),
 	}
 }
 
@@ -17,9 +17,12 @@ package model
 import (
 	"encoding/json"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	// the time zones are validated regardless of the host's database
+	_ "time/tzdata"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
@@ -30,6 +33,10 @@ import (
 
 const (
 	MinPasswordLength = 8
+
+	// userAttributeParamPrefix prefixes the names of the custom
+	// attributes filtering the users, e.g. "attributes.department"
+	userAttributeParamPrefix = "attributes."
 )
 
 var (
@@ -38,8 +45,42 @@ var (
 		MinPasswordLength,
 	)
 	ErrEmptyUpdate = errors.New("no update information provided")
+	// ErrCredentialsNotFound is returned by the credential backends
+	// which have no entry for the user
+	ErrCredentialsNotFound = errors.New("no credentials found for the user")
+
+	localeFormat = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{1,8})*$`)
+	locale       = validation.Match(localeFormat).Error(
+		"must be a language tag, e.g. en-US")
+	timezone = validation.By(func(value interface{}) error {
+		value, _ = validation.Indirect(value)
+		name, _ := value.(string)
+		if name == "" {
+			return nil
+		}
+		if _, err := time.LoadLocation(name); err != nil {
+			return errors.New("must be a time zone name, e.g. Europe/Oslo")
+		}
+		return nil
+	})
 )
 
+// UserStatus is the status of a user account; the disabled users can
+// neither log in nor use their tokens.
+type UserStatus string
+
+const (
+	UserStatusActive   UserStatus = "active"
+	UserStatusDisabled UserStatus = "disabled"
+)
+
+func (s UserStatus) Validate() error {
+	return validation.Validate(string(s), validation.In(
+		string(UserStatusActive),
+		string(UserStatusDisabled),
+	))
+}
+
 type Email string
 
 func (email *Email) UnmarshalJSON(b []byte) error {
@@ -76,12 +117,53 @@ type User struct {
 
 	// LoginTs is the timestamp of the last login for this user.
 	LoginTs *time.Time `json:"login_ts,omitempty" bson:"login_ts,omitempty"`
+
+	// Roles are the names of the roles assigned to the user; users
+	// also get the roles of their groups. The users created without
+	// any roles are assigned the read-only role.
+	Roles []string `json:"roles,omitempty" bson:"roles,omitempty"`
+
+	// profile of the user: display name, preferred language (e.g.
+	// en-US) and time zone (e.g. Europe/Oslo)
+	Name     string `json:"name,omitempty" bson:"name,omitempty"`
+	Locale   string `json:"locale,omitempty" bson:"locale,omitempty"`
+	Timezone string `json:"timezone,omitempty" bson:"timezone,omitempty"`
+
+	// Attributes are the custom attributes defined by the tenant's
+	// UserAttributeSchema
+	Attributes UserAttributes `json:"attributes,omitempty" bson:"attributes,omitempty"`
+
+	// Status of the user account, active if not set; the reason and
+	// the timestamp are the ones of the last change of the status.
+	Status       UserStatus `json:"status,omitempty" bson:"status,omitempty"`
+	StatusReason string     `json:"status_reason,omitempty" bson:"status_reason,omitempty"`
+	StatusTs     *time.Time `json:"status_ts,omitempty" bson:"status_ts,omitempty"`
+
+	// DeletedTs is the time the user was deleted at; the deleted users
+	// are kept, with their email reserved, until the retention period
+	// expires and they are purged, and can be restored until then.
+	DeletedTs *time.Time `json:"deleted_ts,omitempty" bson:"deleted_ts,omitempty"`
+}
+
+// IsDisabled tells if the user account is disabled.
+func (u User) IsDisabled() bool {
+	return u.Status == UserStatusDisabled
+}
+
+// IsDeleted tells if the user is deleted, waiting to be purged.
+func (u User) IsDeleted() bool {
+	return u.DeletedTs != nil
 }
 
 func (u User) Validate() error {
 	if err := validation.ValidateStruct(&u,
 		validation.Field(&u.Email, validation.Required),
 		validation.Field(&u.Password, validation.Required, lessThan4096),
+		validation.Field(&u.Roles, validation.Each(validation.Required, lessThan128)),
+		validation.Field(&u.Name, lessThan4096),
+		validation.Field(&u.Locale, lessThan128, locale),
+		validation.Field(&u.Timezone, lessThan128, timezone),
+		validation.Field(&u.Attributes),
 	); err != nil {
 		return err
 	}
@@ -138,10 +220,21 @@ type UserUpdate struct {
 	Token *jwt.Token `json:"-" bson:"-"`
 
 	LoginTs *time.Time `json:"-" bson:"login_ts,omitempty"`
+
+	// profile of the user
+	Name     string `json:"name,omitempty" bson:"name,omitempty"`
+	Locale   string `json:"locale,omitempty" bson:"locale,omitempty"`
+	Timezone string `json:"timezone,omitempty" bson:"timezone,omitempty"`
+
+	// Attributes changes the given attributes only; the null values
+	// remove the attributes
+	Attributes UserAttributes `json:"attributes,omitempty" bson:"-"`
 }
 
 func (u UserUpdate) Validate() error {
-	if u.Email == "" && u.Password == "" {
+	if u.Email == "" && u.Password == "" &&
+		u.Name == "" && u.Locale == "" && u.Timezone == "" &&
+		len(u.Attributes) == 0 {
 		return ErrEmptyUpdate
 	}
 
@@ -150,6 +243,10 @@ func (u UserUpdate) Validate() error {
 		validation.Field(&u.Password,
 			validation.When(len(u.Password) > 0, lessThan4096),
 		),
+		validation.Field(&u.Name, lessThan4096),
+		validation.Field(&u.Locale, lessThan128, locale),
+		validation.Field(&u.Timezone, lessThan128, timezone),
+		validation.Field(&u.Attributes),
 	); err != nil {
 		return err
 	}
@@ -160,15 +257,70 @@ func (u UserUpdate) Validate() error {
 	return nil
 }
 
+// UserStatusUpdate changes the status of a user account.
+type UserStatusUpdate struct {
+	Status UserStatus `json:"status" bson:"status"`
+	// Reason tells why the status was changed, e.g. for the audit
+	Reason string `json:"reason,omitempty" bson:"status_reason"`
+
+	StatusTs *time.Time `json:"-" bson:"status_ts"`
+}
+
+func (u UserStatusUpdate) Validate() error {
+	return validation.ValidateStruct(&u,
+		validation.Field(&u.Status, validation.Required),
+		validation.Field(&u.Reason, lessThan4096),
+	)
+}
+
 type UserFilter struct {
 	ID    []string `json:"id,omitempty"`
 	Email []Email  `json:"email,omitempty"`
+	// IDs of the groups the users belong to (any of)
+	Group []string `json:"group,omitempty"`
 
 	CreatedAfter  *time.Time `json:"created_after,omitempty"`
 	CreatedBefore *time.Time `json:"created_before,omitempty"`
 
 	UpdatedAfter  *time.Time `json:"updated_after,omitempty"`
 	UpdatedBefore *time.Time `json:"updated_before,omitempty"`
+
+	// EmailPrefix and EmailContains match the emails starting with or
+	// containing the (lower case) string
+	EmailPrefix   string `json:"email_prefix,omitempty"`
+	EmailContains string `json:"email_contains,omitempty"`
+
+	// LoginAfter and LoginBefore match the time of the last login; the
+	// users who never logged in do not match
+	LoginAfter  *time.Time `json:"login_after,omitempty"`
+	LoginBefore *time.Time `json:"login_before,omitempty"`
+	// InactiveSince matches the users who did not log in since the
+	// time, including the users who never logged in
+	InactiveSince *time.Time `json:"inactive_since,omitempty"`
+	// NeverLoggedIn matches the users who never logged in if true, the
+	// users who did if false
+	NeverLoggedIn *bool `json:"never_logged_in,omitempty"`
+
+	// Status matches the status of the user accounts
+	Status UserStatus `json:"status,omitempty"`
+
+	// Deleted lists the deleted users instead of the other users
+	Deleted bool `json:"deleted,omitempty"`
+	// DeletedBefore lists the users deleted before the time only
+	DeletedBefore *time.Time `json:"-"`
+
+	// Attributes match the values of the custom attributes; the values
+	// parsed from a form are strings until converted with the tenant's
+	// UserAttributeSchema
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// Sort orders the users; by email if not set
+	Sort *UserSort `json:"-"`
+	// After lists the users after the cursor only
+	After *UserCursor `json:"-"`
+	// Skip and Limit select a page of the users; no limit if 0
+	Skip  int64 `json:"-"`
+	Limit int64 `json:"-"`
 }
 
 func (fltr *UserFilter) ParseForm(form url.Values) error {
@@ -181,6 +333,9 @@ func (fltr *UserFilter) ParseForm(form url.Values) error {
 			fltr.Email[i] = Email(strings.ToLower(emails[i]))
 		}
 	}
+	if groups, ok := form["group"]; ok {
+		fltr.Group = groups
+	}
 	if ca := form.Get("created_after"); ca != "" {
 		caInt, err := strconv.ParseInt(ca, 10, 64)
 		if err != nil {
@@ -220,5 +375,60 @@ func (fltr *UserFilter) ParseForm(form url.Values) error {
 		ubUnix := time.Unix(ubInt, 0)
 		fltr.UpdatedBefore = &ubUnix
 	}
+
+	fltr.EmailPrefix = strings.ToLower(form.Get("email_prefix"))
+	fltr.EmailContains = strings.ToLower(form.Get("email_contains"))
+
+	for _, param := range []struct {
+		name string
+		ts   **time.Time
+	}{
+		{name: "login_after", ts: &fltr.LoginAfter},
+		{name: "login_before", ts: &fltr.LoginBefore},
+		{name: "inactive_since", ts: &fltr.InactiveSince},
+	} {
+		if v := form.Get(param.name); v != "" {
+			vInt, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return errors.Wrapf(err,
+					`invalid form parameter "%s"`, param.name)
+			}
+			vUnix := time.Unix(vInt, 0)
+			*param.ts = &vUnix
+		}
+	}
+
+	if nl := form.Get("never_logged_in"); nl != "" {
+		nlBool, err := strconv.ParseBool(nl)
+		if err != nil {
+			return errors.Wrap(err,
+				`invalid form parameter "never_logged_in"`)
+		}
+		fltr.NeverLoggedIn = &nlBool
+	}
+
+	if status := form.Get("status"); status != "" {
+		fltr.Status = UserStatus(status)
+		if err := fltr.Status.Validate(); err != nil {
+			return errors.Wrap(err, `invalid form parameter "status"`)
+		}
+	}
+
+	if deleted := form.Get("deleted"); deleted != "" {
+		var err error
+		fltr.Deleted, err = strconv.ParseBool(deleted)
+		if err != nil {
+			return errors.Wrap(err, `invalid form parameter "deleted"`)
+		}
+	}
+
+	for key := range form {
+		if name := strings.TrimPrefix(key, userAttributeParamPrefix); name != key {
+			if fltr.Attributes == nil {
+				fltr.Attributes = make(map[string]interface{})
+			}
+			fltr.Attributes[name] = form.Get(key)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,226 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"regexp"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+type UserAttributeType string
+
+const (
+	UserAttributeTypeString UserAttributeType = "string"
+	UserAttributeTypeNumber UserAttributeType = "number"
+	UserAttributeTypeBool   UserAttributeType = "bool"
+
+	maxUserAttributes = 64
+)
+
+var (
+	// ErrInvalidUserAttribute is the cause of the errors of the user
+	// attributes not matching the tenant's schema
+	ErrInvalidUserAttribute = errors.New("invalid user attribute")
+
+	userAttributeNameFormat = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+	userAttributeName       = validation.Match(userAttributeNameFormat).Error(
+		"must start with a letter and contain only letters, digits and underscores")
+)
+
+// UserAttributes are the custom attributes of a user, by name; the
+// values are strings, numbers or booleans.
+type UserAttributes map[string]interface{}
+
+func (attrs UserAttributes) Validate() error {
+	if len(attrs) > maxUserAttributes {
+		return errors.Errorf("must have at most %d attributes",
+			maxUserAttributes)
+	}
+	errs := validation.Errors{}
+	for name, value := range attrs {
+		err := validation.Validate(name, lessThan128, userAttributeName)
+		if err == nil {
+			switch v := value.(type) {
+			case nil, float64, bool:
+			case string:
+				err = validation.Validate(v, lessThan4096)
+			default:
+				err = errors.New("must be a string, a number or a boolean")
+			}
+		}
+		if err != nil {
+			errs[name] = err
+		}
+	}
+	return errs.Filter()
+}
+
+// UserAttribute defines a custom attribute of the tenant's users.
+type UserAttribute struct {
+	Name string            `json:"name" bson:"name"`
+	Type UserAttributeType `json:"type" bson:"type"`
+
+	// Required attributes must be set when creating the users and
+	// cannot be removed
+	Required bool `json:"required" bson:"required"`
+	// Unique attributes cannot have the same value for two users
+	Unique bool `json:"unique" bson:"unique"`
+	// Searchable attributes can filter the lists of users
+	Searchable bool `json:"searchable" bson:"searchable"`
+	// Claim embeds the attribute in the login tokens of the users
+	Claim bool `json:"claim" bson:"claim"`
+}
+
+func (a UserAttribute) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Name, validation.Required, lessThan128,
+			userAttributeName),
+		validation.Field(&a.Type, validation.Required, validation.In(
+			UserAttributeTypeString,
+			UserAttributeTypeNumber,
+			UserAttributeTypeBool,
+		)),
+	)
+}
+
+// checkValue checks the type of the value set to the attribute.
+func (a UserAttribute) checkValue(value interface{}) bool {
+	switch value.(type) {
+	case string:
+		return a.Type == UserAttributeTypeString
+	case float64:
+		return a.Type == UserAttributeTypeNumber
+	case bool:
+		return a.Type == UserAttributeTypeBool
+	}
+	return false
+}
+
+// ParseValue converts the string form of a value of the attribute,
+// e.g. from a query parameter.
+func (a UserAttribute) ParseValue(s string) (interface{}, error) {
+	var (
+		value interface{}
+		err   error
+	)
+	switch a.Type {
+	case UserAttributeTypeNumber:
+		value, err = strconv.ParseFloat(s, 64)
+	case UserAttributeTypeBool:
+		value, err = strconv.ParseBool(s)
+	default:
+		value = s
+	}
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidUserAttribute,
+			"%s: must be a %s", a.Name, a.Type)
+	}
+	return value, nil
+}
+
+// UserAttributeSchema defines the custom attributes of the tenant's
+// users.
+type UserAttributeSchema struct {
+	Attributes []UserAttribute `json:"attributes" bson:"attributes"`
+}
+
+func (s UserAttributeSchema) Validate() error {
+	err := validation.ValidateStruct(&s,
+		validation.Field(&s.Attributes,
+			validation.Length(0, maxUserAttributes)),
+	)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(s.Attributes))
+	for i, a := range s.Attributes {
+		if err := a.Validate(); err != nil {
+			return validation.Errors{
+				"attributes": validation.Errors{
+					strconv.Itoa(i): err,
+				},
+			}
+		}
+		if names[a.Name] {
+			return errors.Errorf("attributes: duplicate attribute %q",
+				a.Name)
+		}
+		names[a.Name] = true
+	}
+	return nil
+}
+
+// Attribute returns the definition of the attribute, nil if not defined.
+func (s *UserAttributeSchema) Attribute(name string) *UserAttribute {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Attributes {
+		if s.Attributes[i].Name == name {
+			return &s.Attributes[i]
+		}
+	}
+	return nil
+}
+
+// CheckAttributes checks the attributes of a new user against the
+// schema, or the change of the attributes of a user if update is set; the
+// null values of a change remove the attributes.
+func (s *UserAttributeSchema) CheckAttributes(
+	attrs UserAttributes,
+	update bool,
+) error {
+	for name, value := range attrs {
+		a := s.Attribute(name)
+		switch {
+		case a == nil:
+			return errors.Wrapf(ErrInvalidUserAttribute,
+				"%s: not defined", name)
+		case value == nil && a.Required:
+			return errors.Wrapf(ErrInvalidUserAttribute,
+				"%s: cannot be removed", name)
+		case value != nil && !a.checkValue(value):
+			return errors.Wrapf(ErrInvalidUserAttribute,
+				"%s: must be a %s", name, a.Type)
+		}
+	}
+	if update || s == nil {
+		return nil
+	}
+	for _, a := range s.Attributes {
+		if a.Required && attrs[a.Name] == nil {
+			return errors.Wrapf(ErrInvalidUserAttribute,
+				"%s: %s", a.Name, validation.ErrRequired.Error())
+		}
+	}
+	return nil
+}
+
+// Claims returns the attributes embedded in the login tokens.
+func (s *UserAttributeSchema) Claims(attrs UserAttributes) map[string]interface{} {
+	var claims map[string]interface{}
+	for name, value := range attrs {
+		if a := s.Attribute(name); a != nil && a.Claim && a.checkValue(value) {
+			if claims == nil {
+				claims = make(map[string]interface{})
+			}
+			claims[name] = value
+		}
+	}
+	return claims
+}
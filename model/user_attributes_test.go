@@ -0,0 +1,198 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserAttributeSchemaValidate(t *testing.T) {
+	testCases := map[string]struct {
+		schema UserAttributeSchema
+
+		outErr string
+	}{
+		"ok": {
+			schema: UserAttributeSchema{Attributes: []UserAttribute{{
+				Name:       "department",
+				Type:       UserAttributeTypeString,
+				Required:   true,
+				Searchable: true,
+				Claim:      true,
+			}, {
+				Name:   "employee_id",
+				Type:   UserAttributeTypeNumber,
+				Unique: true,
+			}}},
+		},
+		"ok, empty": {
+			schema: UserAttributeSchema{},
+		},
+		"error: bad name": {
+			schema: UserAttributeSchema{Attributes: []UserAttribute{{
+				Name: "employee-id",
+				Type: UserAttributeTypeNumber,
+			}}},
+			outErr: "attributes: (0: (name: must start with a letter and " +
+				"contain only letters, digits and underscores.).).",
+		},
+		"error: bad type": {
+			schema: UserAttributeSchema{Attributes: []UserAttribute{{
+				Name: "department",
+				Type: "list",
+			}}},
+			outErr: "attributes: (0: (type: must be a valid value.).).",
+		},
+		"error: duplicate": {
+			schema: UserAttributeSchema{Attributes: []UserAttribute{{
+				Name: "department",
+				Type: UserAttributeTypeString,
+			}, {
+				Name: "department",
+				Type: UserAttributeTypeBool,
+			}}},
+			outErr: `attributes: duplicate attribute "department"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.schema.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestUserAttributeSchemaCheckAttributes(t *testing.T) {
+	schema := &UserAttributeSchema{Attributes: []UserAttribute{{
+		Name:     "department",
+		Type:     UserAttributeTypeString,
+		Required: true,
+	}, {
+		Name: "level",
+		Type: UserAttributeTypeNumber,
+	}, {
+		Name: "contractor",
+		Type: UserAttributeTypeBool,
+	}}}
+
+	testCases := map[string]struct {
+		schema *UserAttributeSchema
+		attrs  UserAttributes
+		update bool
+
+		outErr string
+	}{
+		"ok": {
+			schema: schema,
+			attrs: UserAttributes{
+				"department": "R&D",
+				"level":      float64(2),
+				"contractor": false,
+			},
+		},
+		"ok, update": {
+			schema: schema,
+			attrs:  UserAttributes{"level": nil},
+			update: true,
+		},
+		"ok, no schema": {},
+		"error, no schema": {
+			attrs:  UserAttributes{"level": float64(2)},
+			outErr: "level: not defined: invalid user attribute",
+		},
+		"error, required": {
+			schema: schema,
+			attrs:  UserAttributes{"level": float64(2)},
+			outErr: "department: cannot be blank: invalid user attribute",
+		},
+		"error, required removed": {
+			schema: schema,
+			attrs:  UserAttributes{"department": nil},
+			update: true,
+			outErr: "department: cannot be removed: invalid user attribute",
+		},
+		"error, type": {
+			schema: schema,
+			attrs: UserAttributes{
+				"department": "R&D",
+				"contractor": "no",
+			},
+			outErr: "contractor: must be a bool: invalid user attribute",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.schema.CheckAttributes(tc.attrs, tc.update)
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
+
+func TestUserAttributeParseValue(t *testing.T) {
+	number := UserAttribute{Name: "level", Type: UserAttributeTypeNumber}
+	value, err := number.ParseValue("2.5")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, value)
+	_, err = number.ParseValue("two")
+	assert.EqualError(t, err, "level: must be a number: invalid user attribute")
+
+	flag := UserAttribute{Name: "contractor", Type: UserAttributeTypeBool}
+	value, err = flag.ParseValue("true")
+	assert.NoError(t, err)
+	assert.Equal(t, true, value)
+
+	str := UserAttribute{Name: "department", Type: UserAttributeTypeString}
+	value, err = str.ParseValue("42")
+	assert.NoError(t, err)
+	assert.Equal(t, "42", value)
+}
+
+func TestUserAttributeSchemaClaims(t *testing.T) {
+	schema := &UserAttributeSchema{Attributes: []UserAttribute{{
+		Name:  "department",
+		Type:  UserAttributeTypeString,
+		Claim: true,
+	}, {
+		Name: "level",
+		Type: UserAttributeTypeNumber,
+	}, {
+		Name:  "contractor",
+		Type:  UserAttributeTypeBool,
+		Claim: true,
+	}}}
+
+	claims := schema.Claims(UserAttributes{
+		"department": "R&D",
+		"level":      float64(2),
+		// the type changed since the value was set
+		"contractor": "no",
+	})
+	assert.Equal(t, map[string]interface{}{"department": "R&D"}, claims)
+
+	assert.Nil(t, schema.Claims(UserAttributes{"level": float64(2)}))
+	assert.Nil(t, (*UserAttributeSchema)(nil).Claims(UserAttributes{"level": 1.0}))
+}
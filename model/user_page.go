@@ -0,0 +1,145 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	UserSortEmail     = "email"
+	UserSortCreatedTs = "created_ts"
+	UserSortUpdatedTs = "updated_ts"
+	UserSortLoginTs   = "login_ts"
+
+	sortAsc  = "asc"
+	sortDesc = "desc"
+)
+
+var (
+	ErrInvalidUserSort = errors.New(
+		"sort: must be one of email, created_ts, updated_ts or login_ts, " +
+			"optionally followed by :asc or :desc",
+	)
+	ErrInvalidUserCursor = errors.New("cursor: invalid value")
+)
+
+// UserSort orders the users by the field, and by ID for the users with
+// the same value.
+type UserSort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseUserSort parses the "field[:asc|:desc]" sort parameter.
+func ParseUserSort(s string) (*UserSort, error) {
+	field, order := s, sortAsc
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		field, order = s[:i], s[i+1:]
+	}
+	switch field {
+	case UserSortEmail, UserSortCreatedTs, UserSortUpdatedTs, UserSortLoginTs:
+	default:
+		return nil, ErrInvalidUserSort
+	}
+	switch order {
+	case sortAsc, sortDesc:
+	default:
+		return nil, ErrInvalidUserSort
+	}
+	return &UserSort{Field: field, Desc: order == sortDesc}, nil
+}
+
+func (s UserSort) String() string {
+	if s.Desc {
+		return s.Field + ":" + sortDesc
+	}
+	return s.Field + ":" + sortAsc
+}
+
+// UserCursor points at the last user of a page, the listing resuming
+// after it; the users must be sorted the same way.
+type UserCursor struct {
+	Sort UserSort
+	ID   string
+	// Email is the sort value for UserSortEmail
+	Email Email
+	// Time is the sort value for the timestamps; nil if not set
+	Time *time.Time
+}
+
+type userCursor struct {
+	Sort  string     `json:"s"`
+	ID    string     `json:"id"`
+	Email Email      `json:"e,omitempty"`
+	Time  *time.Time `json:"t,omitempty"`
+}
+
+// NewUserCursor returns the cursor resuming the listing after the user.
+func NewUserCursor(sort UserSort, user *User) *UserCursor {
+	c := &UserCursor{
+		Sort: sort,
+		ID:   user.ID,
+	}
+	switch sort.Field {
+	case UserSortEmail:
+		c.Email = user.Email
+	case UserSortCreatedTs:
+		c.Time = user.CreatedTs
+	case UserSortUpdatedTs:
+		c.Time = user.UpdatedTs
+	case UserSortLoginTs:
+		c.Time = user.LoginTs
+	}
+	return c
+}
+
+// ParseUserCursor decodes the opaque cursor returned by String.
+func ParseUserCursor(s string) (*UserCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidUserCursor
+	}
+	var raw userCursor
+	if err := json.Unmarshal(b, &raw); err != nil || raw.ID == "" {
+		return nil, ErrInvalidUserCursor
+	}
+	sort, err := ParseUserSort(raw.Sort)
+	if err != nil {
+		return nil, ErrInvalidUserCursor
+	}
+	return &UserCursor{
+		Sort:  *sort,
+		ID:    raw.ID,
+		Email: raw.Email,
+		Time:  raw.Time,
+	}, nil
+}
+
+// String encodes the cursor as an opaque, URL-safe string.
+func (c UserCursor) String() string {
+	b, _ := json.Marshal(userCursor{
+		Sort:  c.Sort.String(),
+		ID:    c.ID,
+		Email: c.Email,
+		Time:  c.Time,
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
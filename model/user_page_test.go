@@ -0,0 +1,124 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserSort(t *testing.T) {
+	testCases := map[string]struct {
+		sort string
+
+		out *UserSort
+		err error
+	}{
+		"ok, default order": {
+			sort: "email",
+			out:  &UserSort{Field: UserSortEmail},
+		},
+		"ok, ascending": {
+			sort: "login_ts:asc",
+			out:  &UserSort{Field: UserSortLoginTs},
+		},
+		"ok, descending": {
+			sort: "created_ts:desc",
+			out:  &UserSort{Field: UserSortCreatedTs, Desc: true},
+		},
+		"error, field": {
+			sort: "password",
+			err:  ErrInvalidUserSort,
+		},
+		"error, order": {
+			sort: "updated_ts:up",
+			err:  ErrInvalidUserSort,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sort, err := ParseUserSort(tc.sort)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.out, sort)
+			if sort != nil && tc.sort != UserSortEmail {
+				assert.Equal(t, tc.sort, sort.String())
+			}
+		})
+	}
+}
+
+func TestUserCursor(t *testing.T) {
+	now := time.Now().UTC()
+	user := &User{
+		ID:        "1",
+		Email:     "foo@acme.com",
+		CreatedTs: &now,
+	}
+
+	testCases := map[string]struct {
+		sort UserSort
+
+		out *UserCursor
+	}{
+		"ok, email": {
+			sort: UserSort{Field: UserSortEmail},
+			out: &UserCursor{
+				Sort:  UserSort{Field: UserSortEmail},
+				ID:    "1",
+				Email: "foo@acme.com",
+			},
+		},
+		"ok, timestamp": {
+			sort: UserSort{Field: UserSortCreatedTs, Desc: true},
+			out: &UserCursor{
+				Sort: UserSort{Field: UserSortCreatedTs, Desc: true},
+				ID:   "1",
+				Time: &now,
+			},
+		},
+		"ok, timestamp not set": {
+			sort: UserSort{Field: UserSortLoginTs},
+			out: &UserCursor{
+				Sort: UserSort{Field: UserSortLoginTs},
+				ID:   "1",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cursor := NewUserCursor(tc.sort, user)
+			assert.Equal(t, tc.out, cursor)
+
+			parsed, err := ParseUserCursor(cursor.String())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.out, parsed)
+		})
+	}
+
+	for _, invalid := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("{")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":"email"}`)),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":"roles","id":"1"}`)),
+	} {
+		_, err := ParseUserCursor(invalid)
+		assert.Equal(t, ErrInvalidUserCursor, err, invalid)
+	}
+}
@@ -72,6 +72,43 @@ func TestValidateNew(t *testing.T) {
 			},
 			outErr: "password: must be minimum 8 characters long",
 		},
+		"profile ok": {
+			inUser: User{
+				Email:    "foo@bar.com",
+				Password: "correcthorsebatterystaple",
+				Name:     "Foo Bar",
+				Locale:   "nb-NO",
+				Timezone: "Europe/Oslo",
+				Attributes: UserAttributes{
+					"department": "R&D",
+					"level":      float64(3),
+				},
+			},
+			outErr: "",
+		},
+		"profile invalid": {
+			inUser: User{
+				Email:    "foo@bar.com",
+				Password: "correcthorsebatterystaple",
+				Locale:   "Norwegian",
+				Timezone: "Europe/Bergen",
+			},
+			outErr: "locale: must be a language tag, e.g. en-US; " +
+				"timezone: must be a time zone name, e.g. Europe/Oslo.",
+		},
+		"attributes invalid": {
+			inUser: User{
+				Email:    "foo@bar.com",
+				Password: "correcthorsebatterystaple",
+				Attributes: UserAttributes{
+					"1st":    "foo",
+					"groups": []interface{}{"foo"},
+				},
+			},
+			outErr: "attributes: (1st: must start with a letter and " +
+				"contain only letters, digits and underscores; " +
+				"groups: must be a string, a number or a boolean.).",
+		},
 	}
 
 	for name, tc := range testCases {
@@ -105,6 +142,7 @@ func TestUserFilterParseForm(t *testing.T) {
 				"user2@acme.io",
 				"user3@acme.io",
 			},
+			"group":          []string{"4"},
 			"created_before": []string{"1234567890"},
 			"created_after":  []string{"123456789"},
 			"updated_before": []string{"9876543210"},
@@ -117,6 +155,7 @@ func TestUserFilterParseForm(t *testing.T) {
 				"user2@acme.io",
 				"user3@acme.io",
 			},
+			Group: []string{"4"},
 			CreatedBefore: func() *time.Time {
 				ret := time.Unix(1234567890, 0)
 				return &ret
@@ -134,6 +173,50 @@ func TestUserFilterParseForm(t *testing.T) {
 				return &ret
 			}(),
 		},
+	}, {
+		Name: "ok, search",
+
+		Form: url.Values{
+			"email_prefix":     []string{"User"},
+			"email_contains":   []string{"@ACME."},
+			"login_after":      []string{"123456789"},
+			"login_before":     []string{"1234567890"},
+			"inactive_since":   []string{"234567890"},
+			"never_logged_in":  []string{"false"},
+			"attributes.level": []string{"2"},
+			"status":           []string{"disabled"},
+		},
+		Result: UserFilter{
+			EmailPrefix:   "user",
+			EmailContains: "@acme.",
+			LoginAfter: func() *time.Time {
+				ret := time.Unix(123456789, 0)
+				return &ret
+			}(),
+			LoginBefore: func() *time.Time {
+				ret := time.Unix(1234567890, 0)
+				return &ret
+			}(),
+			InactiveSince: func() *time.Time {
+				ret := time.Unix(234567890, 0)
+				return &ret
+			}(),
+			NeverLoggedIn: func() *bool {
+				ret := false
+				return &ret
+			}(),
+			Attributes: map[string]interface{}{"level": "2"},
+			Status:     UserStatusDisabled,
+		},
+	}, {
+		Name: "ok, deleted",
+
+		Form: url.Values{
+			"deleted": []string{"true"},
+		},
+		Result: UserFilter{
+			Deleted: true,
+		},
 	}, {
 		Name: "error, created_after not an int",
 
@@ -166,6 +249,46 @@ func TestUserFilterParseForm(t *testing.T) {
 		},
 		Error: errors.New(`invalid form parameter "updated_before": ` +
 			`strconv.ParseInt: parsing "foo": invalid syntax`),
+	}, {
+		Name: "error, login_after not an int",
+
+		Form: url.Values{
+			"login_after": []string{"foo"},
+		},
+		Error: errors.New(`invalid form parameter "login_after": ` +
+			`strconv.ParseInt: parsing "foo": invalid syntax`),
+	}, {
+		Name: "error, inactive_since not an int",
+
+		Form: url.Values{
+			"inactive_since": []string{"foo"},
+		},
+		Error: errors.New(`invalid form parameter "inactive_since": ` +
+			`strconv.ParseInt: parsing "foo": invalid syntax`),
+	}, {
+		Name: "error, never_logged_in not a bool",
+
+		Form: url.Values{
+			"never_logged_in": []string{"foo"},
+		},
+		Error: errors.New(`invalid form parameter "never_logged_in": ` +
+			`strconv.ParseBool: parsing "foo": invalid syntax`),
+	}, {
+		Name: "error, unknown status",
+
+		Form: url.Values{
+			"status": []string{"suspended"},
+		},
+		Error: errors.New(`invalid form parameter "status": ` +
+			`must be a valid value`),
+	}, {
+		Name: "error, deleted not a bool",
+
+		Form: url.Values{
+			"deleted": []string{"foo"},
+		},
+		Error: errors.New(`invalid form parameter "deleted": ` +
+			`strconv.ParseBool: parsing "foo": invalid syntax`),
 	}}
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
@@ -195,3 +318,40 @@ func TestEmailType(t *testing.T) {
 	assert.NoError(t, email.Validate())
 	assert.Error(t, email.UnmarshalJSON([]byte(invalidEmailJSON)))
 }
+
+func TestUserStatusUpdateValidate(t *testing.T) {
+	testCases := map[string]struct {
+		status UserStatusUpdate
+
+		outErr string
+	}{
+		"ok, disable": {
+			status: UserStatusUpdate{
+				Status: UserStatusDisabled,
+				Reason: "left the company",
+			},
+		},
+		"ok, enable": {
+			status: UserStatusUpdate{Status: UserStatusActive},
+		},
+		"error: no status": {
+			status: UserStatusUpdate{Reason: "left the company"},
+			outErr: "status: cannot be blank.",
+		},
+		"error: unknown status": {
+			status: UserStatusUpdate{Status: "suspended"},
+			outErr: "status: must be a valid value.",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.status.Validate()
+			if tc.outErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.outErr)
+			}
+		})
+	}
+}
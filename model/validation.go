@@ -15,16 +15,54 @@
 package model
 
 import (
+	"encoding/pem"
+	"errors"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/scope"
 )
 
 const (
 	maxLength128  = 128
 	maxLength4096 = 4096
+	maxLength1M   = 1024 * 1024
 )
 
 // Reusable validation rules
 var (
 	lessThan128  = validation.Length(0, maxLength128)
 	lessThan4096 = validation.Length(0, maxLength4096)
+	lessThan1M   = validation.Length(0, maxLength1M)
+	knownScope   = validation.By(func(value interface{}) error {
+		name, _ := value.(string)
+		if !scope.IsValid(name) {
+			return errors.New("unknown scope")
+		}
+		return nil
+	})
+	rsaPublicKey = validation.By(func(value interface{}) error {
+		value, _ = validation.Indirect(value)
+		key, _ := value.(string)
+		if key == "" {
+			return nil
+		}
+		if _, err := jwt.ParseRSAPublicKey([]byte(key)); err != nil {
+			return errors.New("must be a PEM-encoded RSA public key")
+		}
+		return nil
+	})
+	pemCertificate = validation.By(func(value interface{}) error {
+		value, _ = validation.Indirect(value)
+		cert, _ := value.(string)
+		if cert == "" {
+			return nil
+		}
+		if block, _ := pem.Decode([]byte(cert)); block == nil ||
+			block.Type != "CERTIFICATE" {
+			return errors.New("must be a PEM-encoded certificate")
+		}
+		return nil
+	})
 )
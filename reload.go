@@ -0,0 +1,369 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mendersoftware/go-lib-micro/config"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/mendersoftware/useradm/authz"
+	"github.com/mendersoftware/useradm/client/tenant"
+	. "github.com/mendersoftware/useradm/config"
+	"github.com/mendersoftware/useradm/keys"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+const (
+	// reloadDebounce is the time to wait for further file system
+	// events before reloading; editors and secret managers often
+	// replace a file in several steps.
+	reloadDebounce = time.Second
+)
+
+// KeySetter is implemented by the signers which support replacing the
+// signing keys at run time (jwt.LocalSigner).
+type KeySetter interface {
+	SetKeys(privKey *rsa.PrivateKey, fallbackPrivKey *rsa.PrivateKey)
+}
+
+// ConfigLoader reads a fresh copy of the service configuration.
+type ConfigLoader func() (config.Reader, error)
+
+// loadConfig reads the configuration file at path (if any) into c, with
+// the defaults and the environment overrides; used both on startup and
+// on every reload.
+func loadConfig(c *viper.Viper, path string) error {
+	config.SetDefaults(c, ConfigDefaults)
+	if path != "" {
+		c.SetConfigFile(path)
+		if err := c.ReadInConfig(); err != nil {
+			return errors.Wrap(err, "failed to read configuration")
+		}
+	}
+
+	// Enable setting config values by environment variables
+	c.SetEnvPrefix("USERADM")
+	c.AutomaticEnv()
+	return nil
+}
+
+// NewConfigLoader returns a ConfigLoader reading the configuration file
+// at path (if any) the same way as on startup.
+func NewConfigLoader(path string) ConfigLoader {
+	return func() (config.Reader, error) {
+		c := viper.New()
+		if err := loadConfig(c, path); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// runtimeConfig holds the settings which can be changed without
+// restarting the service.
+type runtimeConfig struct {
+	privKeyPath         string
+	fallbackPrivKeyPath string
+	privKey             *rsa.PrivateKey
+	fallbackPrivKey     *rsa.PrivateKey
+	useradm             useradm.Config
+	tenantAdmAddr       string
+	policyPath          string
+	policy              *authz.Policy
+}
+
+// Reloader re-reads the signing keys, the authorization policy and the
+// safe-to-change settings and applies them to the running service.
+type Reloader struct {
+	load         ConfigLoader
+	keySetter    KeySetter
+	userAdm      *useradm.UserAdm
+	tenantClient *tenant.Client
+	policy       *authz.PolicyAuthorizer
+
+	// issuer is fixed for the lifetime of the process; changing it
+	// would invalidate every token issued so far
+	issuer string
+
+	mutex   sync.Mutex
+	current *runtimeConfig
+}
+
+// NewReloader creates a Reloader; keySetter may be nil when the tokens
+// are signed by a remote service, tenantClient may be nil when the
+// service does not run in multi-tenant mode, and policy may be nil when
+// no policy file is configured.
+func NewReloader(
+	load ConfigLoader,
+	keySetter KeySetter,
+	userAdm *useradm.UserAdm,
+	tenantClient *tenant.Client,
+	policy *authz.PolicyAuthorizer,
+	c config.Reader,
+) (*Reloader, error) {
+	r := &Reloader{
+		load:         load,
+		keySetter:    keySetter,
+		userAdm:      userAdm,
+		tenantClient: tenantClient,
+		policy:       policy,
+		issuer:       c.GetString(SettingJWTIssuer),
+	}
+	current, err := r.parse(c)
+	if err != nil {
+		return nil, err
+	}
+	r.current = current
+	return r, nil
+}
+
+// parse reads and validates the reloadable settings, including loading
+// the private keys and the policy file.
+func (r *Reloader) parse(c config.Reader) (*runtimeConfig, error) {
+	rc := &runtimeConfig{
+		privKeyPath:         c.GetString(SettingPrivKeyPath),
+		fallbackPrivKeyPath: c.GetString(SettingServerFallbackPrivKeyPath),
+		useradm:             useradmConfigFromAppConfig(c),
+		tenantAdmAddr:       c.GetString(SettingTenantAdmAddr),
+		policyPath:          c.GetString(SettingAuthzPolicyPath),
+	}
+
+	// the issuer is fixed for the lifetime of the process
+	rc.useradm.Issuer = r.issuer
+
+	if r.keySetter != nil {
+		var err error
+		rc.privKey, err = keys.LoadRSAPrivate(rc.privKeyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read rsa private key")
+		}
+		if rc.fallbackPrivKeyPath != "" {
+			rc.fallbackPrivKey, err = keys.LoadRSAPrivate(rc.fallbackPrivKeyPath)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read fallback rsa private key")
+			}
+		}
+	} else {
+		rc.privKeyPath = ""
+		rc.fallbackPrivKeyPath = ""
+	}
+
+	if rc.useradm.ExpirationTime <= 0 {
+		return nil, errors.Errorf("%s must be a positive number", SettingJWTExpirationTimeout)
+	}
+	if rc.useradm.LimitTokensPerUser < 0 {
+		return nil, errors.Errorf("%s must not be negative", SettingLimitTokensPerUser)
+	}
+	if rc.useradm.TokenLastUsedUpdateFreqMinutes < 0 {
+		return nil, errors.Errorf("%s must not be negative",
+			SettingTokenLastUsedUpdateFreqMinutes)
+	}
+	if rc.useradm.ClientCredentialsExpirationTime <= 0 {
+		return nil, errors.Errorf("%s must be a positive number",
+			SettingClientCredentialsExpirationTimeout)
+	}
+	if rc.useradm.ClientSecretRotationOverlap < 0 {
+		return nil, errors.Errorf("%s must not be negative",
+			SettingClientSecretRotationOverlap)
+	}
+	if rc.useradm.UserRetention < 0 {
+		return nil, errors.Errorf("%s must not be negative", SettingUserRetention)
+	}
+	// switching between single- and multi-tenant mode requires
+	// a restart (and database migrations)
+	if (r.tenantClient != nil) != (rc.tenantAdmAddr != "") {
+		return nil, errors.Errorf(
+			"%s cannot be set or unset without restarting the service",
+			SettingTenantAdmAddr)
+	}
+	// the policy authorizer is set up on startup
+	if (r.policy != nil) != (rc.policyPath != "") {
+		return nil, errors.Errorf(
+			"%s cannot be set or unset without restarting the service",
+			SettingAuthzPolicyPath)
+	}
+	if r.policy != nil {
+		var err error
+		rc.policy, err = authz.LoadPolicyFile(rc.policyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load %s", rc.policyPath)
+		}
+	}
+
+	return rc, nil
+}
+
+// Reload reads the configuration and applies it; if reading or
+// validating fails, the service keeps running with the previous state.
+func (r *Reloader) Reload() error {
+	l := log.NewEmpty()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	c, err := r.load()
+	if err == nil {
+		var rc *runtimeConfig
+		rc, err = r.parse(c)
+		if err == nil {
+			r.apply(rc)
+			r.current = rc
+			l.Infof("configuration reloaded: %s=%d, %s=%d, %s=%d, %s=%q, %s=%q",
+				SettingJWTExpirationTimeout, rc.useradm.ExpirationTime,
+				SettingLimitTokensPerUser, rc.useradm.LimitTokensPerUser,
+				SettingTokenLastUsedUpdateFreqMinutes,
+				rc.useradm.TokenLastUsedUpdateFreqMinutes,
+				SettingTenantAdmAddr, rc.tenantAdmAddr,
+				SettingAuthzPolicyPath, rc.policyPath)
+			return nil
+		}
+	}
+	l.Errorf("configuration reload failed, keeping previous configuration: %s",
+		err.Error())
+	return err
+}
+
+func (r *Reloader) apply(rc *runtimeConfig) {
+	if r.keySetter != nil {
+		r.keySetter.SetKeys(rc.privKey, rc.fallbackPrivKey)
+	}
+	// the policy is set first, as updating the configuration purges the
+	// cached verifications together with the requests allowed to them
+	if r.policy != nil {
+		r.policy.SetPolicy(rc.policy)
+	}
+	r.userAdm.UpdateConfig(rc.useradm)
+	if r.tenantClient != nil {
+		r.tenantClient.SetConfig(tenant.Config{
+			TenantAdmAddr: rc.tenantAdmAddr,
+		})
+	}
+}
+
+// watchedFiles returns the key and policy files the reloader currently
+// watches.
+func (r *Reloader) watchedFiles() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var files []string
+	for _, file := range []string{
+		r.current.privKeyPath,
+		r.current.fallbackPrivKeyPath,
+		r.current.policyPath,
+	} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Run reloads the configuration on SIGHUP and whenever one of the key
+// or policy files changes, until the context is canceled.
+func (r *Reloader) Run(ctx context.Context) error {
+	l := log.NewEmpty()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create file watcher")
+	}
+	defer watcher.Close()
+
+	// watch the directories rather than the files: the files are
+	// usually replaced (renamed over or re-linked, as with Kubernetes
+	// secrets) rather than written in place
+	watched := map[string]bool{}
+	watch := func() {
+		for _, file := range r.watchedFiles() {
+			dir := filepath.Dir(file)
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				l.Warnf("failed to watch %s for changes: %s", dir, err.Error())
+				continue
+			}
+			watched[dir] = true
+		}
+	}
+	watch()
+
+	debounce := time.NewTimer(reloadDebounce)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-sigs:
+			l.Info("received SIGHUP, reloading configuration")
+			if r.Reload() == nil {
+				watch()
+			}
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if r.isWatchedFile(event.Name) {
+				debounce.Reset(reloadDebounce)
+			}
+
+		case <-debounce.C:
+			l.Info("watched files changed, reloading configuration")
+			if r.Reload() == nil {
+				watch()
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			l.Warnf("file watcher error: %s", err.Error())
+		}
+	}
+}
+
+// isWatchedFile checks if the file system event concerns one of the
+// watched files, either directly or through a symbolic link living in the same
+// directory (e.g. the "..data" link used for Kubernetes secrets).
+func (r *Reloader) isWatchedFile(name string) bool {
+	for _, file := range r.watchedFiles() {
+		if filepath.Clean(name) == filepath.Clean(file) {
+			return true
+		}
+		if filepath.Dir(name) == filepath.Dir(file) &&
+			filepath.Base(name) == "..data" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mendersoftware/useradm/authz"
+	"github.com/mendersoftware/useradm/client/tenant"
+	"github.com/mendersoftware/useradm/jwt"
+	useradm "github.com/mendersoftware/useradm/user"
+)
+
+type keySetter struct {
+	sync.Mutex
+	privKey         *rsa.PrivateKey
+	fallbackPrivKey *rsa.PrivateKey
+	calls           int
+}
+
+func (k *keySetter) SetKeys(privKey *rsa.PrivateKey, fallbackPrivKey *rsa.PrivateKey) {
+	k.Lock()
+	defer k.Unlock()
+	k.privKey = privKey
+	k.fallbackPrivKey = fallbackPrivKey
+	k.calls++
+}
+
+func (k *keySetter) Calls() int {
+	k.Lock()
+	defer k.Unlock()
+	return k.calls
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	tmp := dst + ".tmp"
+	require.NoError(t, ioutil.WriteFile(tmp, data, 0600))
+	require.NoError(t, os.Rename(tmp, dst))
+}
+
+func writeConfig(t *testing.T, path string, keyPath string, expTimeout int, tadmAddr string) {
+	data := fmt.Sprintf("server_priv_key_path: %s\njwt_exp_timeout: %d\n",
+		keyPath, expTimeout)
+	if tadmAddr != "" {
+		data += fmt.Sprintf("tenantadm_addr: %s\n", tadmAddr)
+	}
+	require.NoError(t, ioutil.WriteFile(path, []byte(data), 0600))
+}
+
+func TestReloader(t *testing.T) {
+	testCases := map[string]struct {
+		tenantAdmAddr    string
+		newKey           string
+		newExpTimeout    int
+		newTenantAdmAddr string
+
+		err error
+	}{
+		"ok": {
+			newKey:        "crypto/private_alternative.pem",
+			newExpTimeout: 60,
+		},
+		"ok, multitenant": {
+			tenantAdmAddr:    "http://tenantadm:8080",
+			newKey:           "crypto/private_alternative.pem",
+			newExpTimeout:    60,
+			newTenantAdmAddr: "http://tenantadm.other:8080",
+		},
+		"error, invalid key": {
+			newKey:        "config.yaml",
+			newExpTimeout: 60,
+			err:           fmt.Errorf("failed to read rsa private key"),
+		},
+		"error, invalid expiration": {
+			newKey:        "crypto/private_alternative.pem",
+			newExpTimeout: -1,
+			err:           fmt.Errorf("jwt_exp_timeout must be a positive number"),
+		},
+		"error, enabling multitenancy": {
+			newKey:           "crypto/private_alternative.pem",
+			newExpTimeout:    60,
+			newTenantAdmAddr: "http://tenantadm:8080",
+			err: fmt.Errorf("tenantadm_addr cannot be set or unset " +
+				"without restarting the service"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			keyPath := filepath.Join(dir, "private.pem")
+			configPath := filepath.Join(dir, "config.yaml")
+			copyFile(t, "crypto/private.pem", keyPath)
+			writeConfig(t, configPath, keyPath, 3600, tc.tenantAdmAddr)
+
+			load := NewConfigLoader(configPath)
+			c, err := load()
+			require.NoError(t, err)
+
+			var tenantClient *tenant.Client
+			if tc.tenantAdmAddr != "" {
+				tenantClient = tenant.NewClient(tenant.Config{
+					TenantAdmAddr: tc.tenantAdmAddr,
+				})
+			}
+			keys := &keySetter{}
+			r, err := NewReloader(load, keys, useradm.NewUserAdm(nil, nil, useradm.Config{}),
+				tenantClient, nil, c)
+			require.NoError(t, err)
+			initial := r.current
+
+			copyFile(t, tc.newKey, keyPath)
+			writeConfig(t, configPath, keyPath, tc.newExpTimeout, tc.newTenantAdmAddr)
+
+			err = r.Reload()
+			if tc.err != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err.Error())
+				assert.Equal(t, initial, r.current)
+				assert.Equal(t, 0, keys.Calls())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 1, keys.Calls())
+			assert.NotEqual(t, initial.privKey, keys.privKey)
+			assert.Equal(t, r.current.privKey, keys.privKey)
+			assert.Equal(t, int64(tc.newExpTimeout), r.current.useradm.ExpirationTime)
+			assert.Equal(t, tc.newTenantAdmAddr, r.current.tenantAdmAddr)
+		})
+	}
+}
+
+func TestReloaderRemoteSigner(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	// the key files are irrelevant when signing remotely
+	writeConfig(t, configPath, filepath.Join(dir, "missing.pem"), 3600, "")
+
+	load := NewConfigLoader(configPath)
+	c, err := load()
+	require.NoError(t, err)
+
+	r, err := NewReloader(load, nil, useradm.NewUserAdm(nil, nil, useradm.Config{}), nil, nil, c)
+	require.NoError(t, err)
+	assert.Empty(t, r.watchedFiles())
+
+	writeConfig(t, configPath, filepath.Join(dir, "missing.pem"), 60, "")
+	assert.NoError(t, r.Reload())
+	assert.Equal(t, int64(60), r.current.useradm.ExpirationTime)
+	assert.Nil(t, r.current.privKey)
+}
+
+func TestReloaderPolicy(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "private.pem")
+	policyPath := filepath.Join(dir, "policy.yaml")
+	configPath := filepath.Join(dir, "config.yaml")
+	copyFile(t, "crypto/private.pem", keyPath)
+	writeConfig(t, configPath, keyPath, 3600, "")
+	f, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString("authz_policy_path: " + policyPath + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	writePolicy := func(effect string) {
+		require.NoError(t, ioutil.WriteFile(policyPath, []byte(
+			"rules:\n"+
+				"  - effect: "+effect+"\n"+
+				"    resources: [\"deployments:*\"]\n"+
+				"    methods: [\"*\"]\n"), 0600))
+	}
+	writePolicy(authz.PolicyEffectAllow)
+
+	load := NewConfigLoader(configPath)
+	c, err := load()
+	require.NoError(t, err)
+
+	policy := authz.NewPolicyAuthorizer(nil, nil)
+	r, err := NewReloader(load, &keySetter{},
+		useradm.NewUserAdm(nil, nil, useradm.Config{}), nil, policy, c)
+	require.NoError(t, err)
+	r.apply(r.current)
+	assert.Contains(t, r.watchedFiles(), policyPath)
+
+	token := &jwt.Token{Claims: jwt.Claims{User: true}}
+	authorize := func() error {
+		return policy.Authorize(context.Background(),
+			token, "deployments:deployments", "GET")
+	}
+	assert.NoError(t, authorize())
+
+	writePolicy(authz.PolicyEffectDeny)
+	assert.NoError(t, r.Reload())
+	assert.Equal(t, authz.ErrAuthzUnauthorized, authorize())
+
+	// an invalid policy keeps the previous one in effect
+	writePolicy("maybe")
+	assert.Error(t, r.Reload())
+	assert.Equal(t, authz.ErrAuthzUnauthorized, authorize())
+
+	// the policy file cannot be disabled without a restart
+	writeConfig(t, configPath, keyPath, 3600, "")
+	err = r.Reload()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "authz_policy_path cannot be set or unset")
+	}
+}
+
+func TestReloaderRun(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "private.pem")
+	configPath := filepath.Join(dir, "config.yaml")
+	copyFile(t, "crypto/private.pem", keyPath)
+	writeConfig(t, configPath, keyPath, 3600, "")
+
+	load := NewConfigLoader(configPath)
+	c, err := load()
+	require.NoError(t, err)
+
+	keys := &keySetter{}
+	r, err := NewReloader(load, keys, useradm.NewUserAdm(nil, nil, useradm.Config{}), nil, nil, c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = r.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// give the watcher a moment to start
+	time.Sleep(100 * time.Millisecond)
+
+	// replacing the key file triggers a reload
+	copyFile(t, "crypto/private_alternative.pem", keyPath)
+	assert.Eventually(t, func() bool {
+		return keys.Calls() == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// so does SIGHUP
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		return keys.Calls() == 2
+	}, 5*time.Second, 50*time.Millisecond)
+}
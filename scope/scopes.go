@@ -13,9 +13,224 @@
 //    limitations under the License.
 package scope
 
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
 var (
 	// inital user creation
 	InitialUserCreate = "mender.users.initial.create"
 	// full permissions for the tenant admin
 	All = "mender.*"
+	// read-only access to all the services
+	ReadOnly = "mender.read"
+	// access to the deployments and artifacts
+	DeploymentsRead  = "mender.deployments.read"
+	DeploymentsWrite = "mender.deployments.write"
+	// access to the devices: authentication, inventory, configuration,
+	// monitoring and remote terminal
+	DevicesRead  = "mender.devices.read"
+	DevicesWrite = "mender.devices.write"
+	// access to the users, tokens and settings
+	UsersRead  = "mender.users.read"
+	UsersWrite = "mender.users.write"
+)
+
+var (
+	deploymentsServices = []string{"deployments"}
+	devicesServices     = []string{
+		"deviceauth",
+		"deviceconfig",
+		"deviceconnect",
+		"devicemonitor",
+		"inventory",
+		"iot-manager",
+	}
+	usersServices = []string{"useradm"}
 )
+
+// definition describes what a scope grants: the read-only or full access
+// to the resources of a set of services (all of them if nil).
+type definition struct {
+	description string
+	services    []string
+	write       bool
+}
+
+// catalogue lists the scopes which can be granted to a token
+var catalogue = map[string]definition{
+	All: {
+		description: "Full access to all the services",
+		write:       true,
+	},
+	ReadOnly: {
+		description: "Read-only access to all the services",
+	},
+	DeploymentsRead: {
+		description: "Read-only access to deployments and artifacts",
+		services:    deploymentsServices,
+	},
+	DeploymentsWrite: {
+		description: "Full access to deployments and artifacts",
+		services:    deploymentsServices,
+		write:       true,
+	},
+	DevicesRead: {
+		description: "Read-only access to devices",
+		services:    devicesServices,
+	},
+	DevicesWrite: {
+		description: "Full access to devices",
+		services:    devicesServices,
+		write:       true,
+	},
+	UsersRead: {
+		description: "Read-only access to users and their settings",
+		services:    usersServices,
+	},
+	UsersWrite: {
+		description: "Full access to users and their settings",
+		services:    usersServices,
+		write:       true,
+	},
+}
+
+// Catalogue returns the names and the descriptions of all the scopes which
+// can be granted to a token.
+func Catalogue() map[string]string {
+	ret := make(map[string]string, len(catalogue))
+	for name, def := range catalogue {
+		ret[name] = def.description
+	}
+	return ret
+}
+
+// Names returns the names of all the scopes which can be granted to a token.
+func Names() []string {
+	ret := make([]string, 0, len(catalogue))
+	for name := range catalogue {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// IsValid checks if the scope is a part of the catalogue.
+func IsValid(name string) bool {
+	_, ok := catalogue[name]
+	return ok
+}
+
+// Parse splits the space-delimited list of scopes, as stored in the
+// "scp" claim of the tokens.
+func Parse(s string) []string {
+	return strings.Fields(s)
+}
+
+// Join builds the space-delimited list of scopes.
+func Join(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+type scopesContextKey struct{}
+
+// WithContext stores the scopes of the token the request was made with.
+func WithContext(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesContextKey{}, scopes)
+}
+
+// FromContext returns the scopes of the token the request was made with,
+// or nil if not known.
+func FromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesContextKey{}).([]string)
+	return scopes
+}
+
+// Permits checks if any of the scopes allows the action (HTTP method) on the
+// resource; the resource is the ':'-separated path of the request, starting
+// with the name of the service, e.g. "deployments:deployments:<id>".
+func Permits(scopes []string, resource, action string) bool {
+	service := strings.SplitN(resource, ":", 2)[0]
+	write := !isReadAction(action)
+	for _, name := range scopes {
+		def, ok := catalogue[name]
+		if !ok {
+			continue
+		}
+		if write && !def.write {
+			continue
+		}
+		if def.services == nil || contains(def.services, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers checks if the granted scopes include all the requested scopes,
+// that is, if a token with the requested scopes would not be allowed to do
+// anything the granted scopes do not allow.
+func Covers(granted, requested []string) bool {
+	for _, name := range requested {
+		if !covered(granted, name) {
+			return false
+		}
+	}
+	return true
+}
+
+func covered(granted []string, name string) bool {
+	req, ok := catalogue[name]
+	if !ok {
+		return false
+	}
+	for _, g := range granted {
+		def, ok := catalogue[g]
+		if !ok {
+			continue
+		}
+		if req.write && !def.write {
+			continue
+		}
+		if def.services == nil {
+			return true
+		}
+		if req.services == nil {
+			continue
+		}
+		if containsAll(def.services, req.services) {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadAction(action string) bool {
+	switch strings.ToUpper(action) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(list []string, items []string) bool {
+	for _, s := range items {
+		if !contains(list, s) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package scope
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogue(t *testing.T) {
+	names := Names()
+	assert.Len(t, Catalogue(), len(names))
+	for _, name := range names {
+		assert.True(t, IsValid(name))
+		assert.NotEmpty(t, Catalogue()[name])
+	}
+	assert.False(t, IsValid(InitialUserCreate))
+	assert.False(t, IsValid("mender.foo"))
+}
+
+func TestParseJoin(t *testing.T) {
+	scopes := Parse(" mender.devices.read  mender.deployments.write ")
+	assert.Equal(t, []string{DevicesRead, DeploymentsWrite}, scopes)
+	assert.Equal(t, "mender.devices.read mender.deployments.write", Join(scopes))
+	assert.Empty(t, Parse(""))
+}
+
+func TestPermits(t *testing.T) {
+	testCases := map[string]struct {
+		scopes   []string
+		resource string
+		action   string
+
+		permitted bool
+	}{
+		"all": {
+			scopes:    []string{All},
+			resource:  "useradm:users:1234",
+			action:    "DELETE",
+			permitted: true,
+		},
+		"read-only, read": {
+			scopes:    []string{ReadOnly},
+			resource:  "inventory:devices",
+			action:    "GET",
+			permitted: true,
+		},
+		"read-only, write": {
+			scopes:   []string{ReadOnly},
+			resource: "inventory:devices",
+			action:   "POST",
+		},
+		"devices read": {
+			scopes:    []string{DevicesRead},
+			resource:  "deviceauth:devices:1234",
+			action:    "head",
+			permitted: true,
+		},
+		"devices read, other service": {
+			scopes:   []string{DevicesRead},
+			resource: "deployments:deployments",
+			action:   "GET",
+		},
+		"deployments write": {
+			scopes:    []string{DeploymentsWrite},
+			resource:  "deployments:deployments",
+			action:    "POST",
+			permitted: true,
+		},
+		"multiple scopes": {
+			scopes:    []string{DevicesRead, UsersWrite},
+			resource:  "useradm:settings:tokens",
+			action:    "POST",
+			permitted: true,
+		},
+		"initial user creation": {
+			scopes:   []string{InitialUserCreate},
+			resource: "useradm:users:initial",
+			action:   "POST",
+		},
+		"unknown scope": {
+			scopes:   []string{"mender.foo"},
+			resource: "useradm:users",
+			action:   "GET",
+		},
+		"no scopes": {
+			resource: "useradm:users",
+			action:   "GET",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.permitted, Permits(tc.scopes, tc.resource, tc.action))
+		})
+	}
+}
+
+func TestCovers(t *testing.T) {
+	testCases := map[string]struct {
+		granted   []string
+		requested []string
+
+		covered bool
+	}{
+		"all covers everything": {
+			granted:   []string{All},
+			requested: []string{All, ReadOnly, DevicesWrite, UsersRead},
+			covered:   true,
+		},
+		"read-only covers reads": {
+			granted:   []string{ReadOnly},
+			requested: []string{DevicesRead, DeploymentsRead},
+			covered:   true,
+		},
+		"read-only does not cover writes": {
+			granted:   []string{ReadOnly},
+			requested: []string{DevicesWrite},
+		},
+		"write covers read": {
+			granted:   []string{DevicesWrite},
+			requested: []string{DevicesRead},
+			covered:   true,
+		},
+		"read does not cover write": {
+			granted:   []string{DevicesRead},
+			requested: []string{DevicesWrite},
+		},
+		"service scope does not cover all services": {
+			granted:   []string{DevicesRead, DeploymentsRead, UsersRead},
+			requested: []string{ReadOnly},
+		},
+		"other service": {
+			granted:   []string{DevicesWrite},
+			requested: []string{DeploymentsRead},
+		},
+		"unknown scope": {
+			granted:   []string{All},
+			requested: []string{"mender.foo"},
+		},
+		"nothing requested": {
+			covered: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.covered, Covers(tc.granted, tc.requested))
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, FromContext(ctx))
+
+	ctx = WithContext(ctx, []string{DevicesRead})
+	assert.Equal(t, []string{DevicesRead}, FromContext(ctx))
+}
@@ -14,8 +14,13 @@
 package main
 
 import (
-	"crypto/rsa"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/mendersoftware/go-lib-micro/config"
@@ -24,17 +29,25 @@ import (
 
 	api_http "github.com/mendersoftware/useradm/api/http"
 	"github.com/mendersoftware/useradm/authz"
+	"github.com/mendersoftware/useradm/client/ldap"
 	"github.com/mendersoftware/useradm/client/tenant"
 	. "github.com/mendersoftware/useradm/config"
 	"github.com/mendersoftware/useradm/jwt"
-	"github.com/mendersoftware/useradm/keys"
+	"github.com/mendersoftware/useradm/model"
 	"github.com/mendersoftware/useradm/store/mongo"
 	useradm "github.com/mendersoftware/useradm/user"
 )
 
-func SetupAPI(stacktype string, authz authz.Authorizer, jwth jwt.Handler) (*rest.Api, error) {
+func SetupAPI(
+	stacktype string,
+	authz authz.Authorizer,
+	jwth jwt.Handler,
+	verify authz.TokenVerifier,
+	decisions authz.DecisionCache,
+) (*rest.Api, error) {
 	api := rest.NewApi()
-	if err := SetupMiddleware(api, stacktype, authz, jwth); err != nil {
+	if err := SetupMiddleware(api, stacktype, authz, jwth, verify,
+		decisions); err != nil {
 		return nil, errors.Wrap(err, "failed to setup middleware")
 	}
 
@@ -47,56 +60,226 @@ func SetupAPI(stacktype string, authz authz.Authorizer, jwth jwt.Handler) (*rest
 	return api, nil
 }
 
-func RunServer(c config.Reader) error {
+// setupAuthorizer returns the RBAC authorizer, combined with the policy
+// file authorizer if a policy file is configured; the policy itself is
+// loaded (and reloaded) by the Reloader.
+func setupAuthorizer(
+	c config.Reader,
+	roles authz.RoleStore,
+) (authz.Authorizer, *authz.PolicyAuthorizer, error) {
+	var (
+		authorizer authz.Authorizer = authz.NewRBACAuthorizer(roles)
+		policy     *authz.PolicyAuthorizer
+	)
+	if c.GetString(SettingAuthzPolicyPath) != "" {
+		rbac := authorizer
+		policy = authz.NewPolicyAuthorizer(nil, roles)
+		switch combining := c.GetString(SettingAuthzPolicyCombining); combining {
+		case authz.CombiningDenyOverrides:
+			authorizer = authz.DenyOverrides(policy, rbac)
+		case authz.CombiningFirstApplicable:
+			authorizer = authz.FirstApplicable(policy, rbac)
+		default:
+			return nil, nil, errors.Errorf("invalid %s: %q",
+				SettingAuthzPolicyCombining, combining)
+		}
+	}
+	if c.GetBool(SettingAuthzLogDenials) {
+		authorizer = authz.WithDenialLog(authorizer)
+	}
+	return authorizer, policy, nil
+}
 
-	l := log.New(log.Ctx{})
+// useradmConfigFromAppConfig reads the settings of the user
+// administration logic; used both on startup and on every reload.
+func useradmConfigFromAppConfig(c config.Reader) useradm.Config {
+	return useradm.Config{
+		Issuer:                         c.GetString(SettingJWTIssuer),
+		ExpirationTime:                 int64(c.GetInt(SettingJWTExpirationTimeout)),
+		LimitTokensPerUser:             c.GetInt(SettingLimitTokensPerUser),
+		TokenLastUsedUpdateFreqMinutes: c.GetInt(SettingTokenLastUsedUpdateFreqMinutes),
+		ClientCredentialsExpirationTime: int64(
+			c.GetInt(SettingClientCredentialsExpirationTimeout)),
+		ClientSecretRotationOverlap: int64(c.GetInt(SettingClientSecretRotationOverlap)),
+		ElevationMaxDuration:        c.GetInt(SettingElevationMaxDuration),
+		ElevationApproval:           c.GetBool(SettingElevationApproval),
+		ElevationRoles:              elevationRolesFromAppConfig(c),
+		ApprovalExpiration:          int64(c.GetInt(SettingApprovalExpirationTimeout)),
+		UserRetention:               int64(c.GetInt(SettingUserRetention)),
+	}
+}
 
-	privKey, err := keys.LoadRSAPrivate(c.GetString(SettingPrivKeyPath))
-	if err != nil {
-		return errors.Wrap(err, "failed to read rsa private key")
+// elevationRolesFromAppConfig reads the comma-separated roles the users
+// may request with a privilege elevation, by the names of their roles.
+func elevationRolesFromAppConfig(c config.Reader) map[string][]string {
+	mapping := c.GetStringMapString(SettingElevationRoles)
+	if len(mapping) == 0 {
+		return nil
+	}
+	elevationRoles := make(map[string][]string, len(mapping))
+	for role, value := range mapping {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				elevationRoles[strings.ToLower(role)] = append(
+					elevationRoles[strings.ToLower(role)], name)
+			}
+		}
 	}
+	return elevationRoles
+}
 
-	fallbackPrivKeyPath := c.GetString(SettingServerFallbackPrivKeyPath)
-	var fallbackPrivKey *rsa.PrivateKey
-	if fallbackPrivKeyPath != "" {
-		fallbackPrivKey, err = keys.LoadRSAPrivate(fallbackPrivKeyPath)
+// ldapConfigFromAppConfig reads the LDAP backend settings, loading the
+// CA certificates trusted for the server.
+func ldapConfigFromAppConfig(c config.Reader) (*ldap.Config, error) {
+	conf := &ldap.Config{
+		URL:                  c.GetString(SettingLDAPURL),
+		StartTLS:             c.GetBool(SettingLDAPStartTLS),
+		BindDN:               c.GetString(SettingLDAPBindDN),
+		BindPassword:         c.GetString(SettingLDAPBindPassword),
+		BaseDN:               c.GetString(SettingLDAPBaseDN),
+		UserFilter:           c.GetString(SettingLDAPUserFilter),
+		EmailAttribute:       c.GetString(SettingLDAPEmailAttribute),
+		NameAttribute:        c.GetString(SettingLDAPNameAttribute),
+		GroupFilter:          c.GetString(SettingLDAPGroupFilter),
+		GroupMemberAttribute: c.GetString(SettingLDAPGroupMemberAttribute),
+		RolesAttribute:       c.GetString(SettingLDAPRolesAttribute),
+		RoleMapping:          c.GetStringMapString(SettingLDAPRoleMapping),
+	}
+	if path := c.GetString(SettingLDAPCACertificate); path != "" {
+		pem, err := ioutil.ReadFile(path)
 		if err != nil {
-			return errors.Wrap(err, "failed to read fallback rsa private key")
+			return nil, errors.Wrap(err, "failed to read the LDAP CA certificate")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in %s", path)
 		}
+		conf.TLSConfig = &tls.Config{RootCAs: pool}
 	}
+	return conf, nil
+}
+
+// RunServer starts the HTTP server; configPath is the configuration file
+// that gets re-read when the configuration is reloaded.
+func RunServer(c config.Reader, configPath string) error {
 
-	authz := &SimpleAuthz{}
-	jwth := jwt.NewJWTHandlerRS256(privKey, fallbackPrivKey)
+	l := log.New(log.Ctx{})
+
+	// with a remote signer, the private keys never enter this process;
+	// otherwise the keys are loaded and set by the reloader below
+	var (
+		signer    jwt.Signer
+		keySetter KeySetter
+	)
+	if signerURL := c.GetString(SettingSignerURL); signerURL != "" {
+		l.Infof("using remote signer %s", signerURL)
+		signer = jwt.NewRemoteSigner(jwt.RemoteSignerConfig{
+			URL:   signerURL,
+			Token: c.GetString(SettingSignerToken),
+		})
+	} else {
+		localSigner := jwt.NewLocalSigner(nil, nil)
+		signer, keySetter = localSigner, localSigner
+	}
+	jwth := jwt.NewJWTHandlerRS256WithSigner(signer)
 
 	db, err := mongo.GetDataStoreMongo(dataStoreMongoConfigFromAppConfig(c))
 	if err != nil {
 		return errors.Wrap(err, "database connection failed")
 	}
 
-	ua := useradm.NewUserAdm(jwth, db,
-		useradm.Config{
-			Issuer:                         c.GetString(SettingJWTIssuer),
-			ExpirationTime:                 int64(c.GetInt(SettingJWTExpirationTimeout)),
-			LimitTokensPerUser:             c.GetInt(SettingLimitTokensPerUser),
-			TokenLastUsedUpdateFreqMinutes: c.GetInt(SettingTokenLastUsedUpdateFreqMinutes),
-		})
+	authorizer, policyAuthorizer, err := setupAuthorizer(c, db)
+	if err != nil {
+		return err
+	}
+
+	ua := useradm.NewUserAdm(jwth, db, useradmConfigFromAppConfig(c))
 
+	var tc *tenant.Client
 	if tadmAddr := c.GetString(SettingTenantAdmAddr); tadmAddr != "" {
 		l.Infof("settting up tenant verification")
 
-		tc := tenant.NewClient(tenant.Config{
+		tc = tenant.NewClient(tenant.Config{
 			TenantAdmAddr: tadmAddr,
 		})
 
 		ua = ua.WithTenantVerification(tc)
 	}
 
+	if c.GetString(SettingLDAPURL) != "" {
+		conf, err := ldapConfigFromAppConfig(c)
+		if err != nil {
+			return err
+		}
+		l.Infof("checking the credentials with LDAP server %s", conf.URL)
+		lc, err := ldap.NewClient(*conf)
+		if err != nil {
+			return errors.Wrap(err, "invalid LDAP configuration")
+		}
+		var localUsers []model.Email
+		for _, email := range c.GetStringSlice(SettingLDAPLocalUsers) {
+			localUsers = append(localUsers, model.Email(email))
+		}
+		ua = ua.WithCredentialChecker(lc, localUsers...)
+	}
+
+	var decisions authz.DecisionCache
+	if size := c.GetInt(SettingVerifyCacheSize); size > 0 {
+		ttl := time.Duration(c.GetInt(SettingVerifyCacheTTL)) * time.Second
+		l.Infof("caching up to %d token verifications for %s", size, ttl)
+		verifyCache := useradm.NewVerificationCache(size, ttl)
+		ua = ua.WithVerificationCache(verifyCache)
+		decisions = verifyCache
+		go func() {
+			if err := ua.WatchVerificationCache(context.Background()); err != nil {
+				l.Warnf("verification cache disabled: %s", err.Error())
+			}
+		}()
+	}
+
+	if interval := c.GetInt(SettingUserPurgeInterval); interval > 0 {
+		// the failures are logged and the purge is retried on the next
+		// tick; the replicas may purge concurrently
+		go func() {
+			ctx := log.WithContext(context.Background(), l)
+			ticker := time.NewTicker(time.Duration(interval) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := ua.PurgeDeletedUsers(ctx); err != nil {
+					l.Errorf("failed to purge the deleted users: %s", err.Error())
+				}
+			}
+		}()
+	}
+
+	reloader, err := NewReloader(NewConfigLoader(configPath),
+		keySetter, ua, tc, policyAuthorizer, c)
+	if err != nil {
+		return err
+	}
+	reloader.apply(reloader.current)
+	go func() {
+		if err := reloader.Run(context.Background()); err != nil {
+			l.Errorf("configuration reloading disabled: %s", err.Error())
+		}
+	}()
+
+	identityHeaders := c.GetStringSlice(SettingVerifyIdentityHeaders)
+	if err := api_http.ValidateIdentityHeaders(identityHeaders); err != nil {
+		return errors.Wrapf(err, "invalid %s", SettingVerifyIdentityHeaders)
+	}
+
 	useradmapi := api_http.NewUserAdmApiHandlers(ua, db, jwth,
 		api_http.Config{
-			TokenMaxExpSeconds: c.GetInt(SettingTokenMaxExpirationSeconds),
+			TokenMaxExpSeconds:   c.GetInt(SettingTokenMaxExpirationSeconds),
+			IntrospectionClients: c.GetStringMapString(SettingIntrospectionClients),
+			Authorizer:           authorizer,
+			ServerURL:            c.GetString(SettingServerURL),
+			IdentityHeaders:      identityHeaders,
 		})
 
-	api, err := SetupAPI(c.GetString(SettingMiddleware), authz, jwth)
+	api, err := SetupAPI(c.GetString(SettingMiddleware), authorizer, jwth,
+		api_http.TokenVerifier(ua), decisions)
 	if err != nil {
 		return errors.Wrap(err, "API setup failed")
 	}
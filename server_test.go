@@ -14,18 +14,100 @@
 package main
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
+	"github.com/mendersoftware/go-lib-micro/config"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/useradm/authz"
+	. "github.com/mendersoftware/useradm/config"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
 )
 
 func TestSetupApi(t *testing.T) {
 	// expecting an error
-	api, err := SetupAPI("foo", nil, nil)
+	api, err := SetupAPI("foo", nil, nil, nil, nil)
 	assert.Nil(t, api)
 	assert.Error(t, err)
 
-	api, err = SetupAPI(EnvDev, nil, nil)
+	api, err = SetupAPI(EnvDev, nil, nil, nil, nil)
 	assert.NotNil(t, api)
 	assert.Nil(t, err)
 }
+
+func TestSetupAuthorizer(t *testing.T) {
+	c := viper.New()
+	config.SetDefaults(c, ConfigDefaults)
+
+	authorizer, policy, err := setupAuthorizer(c, nil)
+	assert.NoError(t, err)
+	assert.IsType(t, &authz.RBACAuthorizer{}, authorizer)
+	assert.Nil(t, policy)
+
+	c.Set(SettingAuthzPolicyPath, "policy.yaml")
+	authorizer, policy, err = setupAuthorizer(c, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, authorizer)
+	assert.NotNil(t, policy)
+
+	c.Set(SettingAuthzPolicyCombining, authz.CombiningFirstApplicable)
+	_, _, err = setupAuthorizer(c, nil)
+	assert.NoError(t, err)
+
+	c.Set(SettingAuthzPolicyCombining, "permit-overrides")
+	_, _, err = setupAuthorizer(c, nil)
+	assert.EqualError(t, err, `invalid authz_policy_combining: "permit-overrides"`)
+
+	c.Set(SettingAuthzPolicyCombining, authz.CombiningDenyOverrides)
+	c.Set(SettingAuthzLogDenials, true)
+	authorizer, policy, err = setupAuthorizer(c, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, policy)
+	_, ok := authorizer.(authz.Explainer)
+	assert.True(t, ok)
+	assert.Equal(t, authz.WithDenialLog(authz.DenyOverrides(policy,
+		authz.NewRBACAuthorizer(nil))), authorizer)
+}
+
+func TestLDAPConfigFromAppConfig(t *testing.T) {
+	c := viper.New()
+	config.SetDefaults(c, ConfigDefaults)
+	c.Set(SettingLDAPURL, "ldaps://ldap.example.com")
+	c.Set(SettingLDAPBaseDN, "dc=example,dc=com")
+	c.Set(SettingLDAPRoleMapping, map[string]string{
+		"cn=admins,dc=example,dc=com": "admin",
+	})
+
+	conf, err := ldapConfigFromAppConfig(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "ldaps://ldap.example.com", conf.URL)
+	assert.Equal(t, "(objectClass=person)", conf.UserFilter)
+	assert.Equal(t, "mail", conf.EmailAttribute)
+	assert.Equal(t, "displayName", conf.NameAttribute)
+	assert.Equal(t, map[string]string{
+		"cn=admins,dc=example,dc=com": "admin",
+	}, conf.RoleMapping)
+	assert.Nil(t, conf.TLSConfig)
+
+	dir := t.TempDir()
+	c.Set(SettingLDAPCACertificate, filepath.Join(dir, "missing.pem"))
+	_, err = ldapConfigFromAppConfig(c)
+	assert.Error(t, err)
+
+	path := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("not a certificate"), 0600))
+	c.Set(SettingLDAPCACertificate, path)
+	_, err = ldapConfigFromAppConfig(c)
+	assert.EqualError(t, err, "no certificate found in "+path)
+
+	srv, err := mtesting.NewLDAPSServer()
+	assert.NoError(t, err)
+	defer srv.Close()
+	assert.NoError(t, ioutil.WriteFile(path, []byte(srv.Certificate()), 0600))
+	conf, err = ldapConfigFromAppConfig(c)
+	assert.NoError(t, err)
+	assert.NotNil(t, conf.TLSConfig.RootCAs)
+}
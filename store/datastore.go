@@ -17,6 +17,7 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/mendersoftware/go-lib-micro/mongo/oid"
 
@@ -37,6 +38,27 @@ var (
 	ErrDuplicateTokenName = errors.New("Personal Access Token with a given name already exists")
 	// etag doesn't match
 	ErrETagMismatch = errors.New("ETag doesn't match")
+	// service account not found
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	// duplicated service account name
+	ErrDuplicateServiceAccountName = errors.New(
+		"service account with a given name already exists")
+	// role not found
+	ErrRoleNotFound = errors.New("role not found")
+	// duplicated role name
+	ErrDuplicateRoleName = errors.New("role with a given name already exists")
+	// group not found
+	ErrGroupNotFound = errors.New("group not found")
+	// duplicated group name
+	ErrDuplicateGroupName = errors.New("group with a given name already exists")
+	// elevation not found
+	ErrElevationNotFound = errors.New("elevation not found")
+	// approval not found
+	ErrApprovalNotFound = errors.New("approval not found")
+	// SAML assertion already consumed
+	ErrSAMLAssertionReplayed = errors.New("SAML assertion already consumed")
+	// client assertion already consumed
+	ErrClientAssertionReplayed = errors.New("client assertion already consumed")
 )
 
 //go:generate ../utils/mockgen.sh
@@ -49,12 +71,31 @@ type DataStore interface {
 	// returns the updated user
 	UpdateUser(ctx context.Context, id string, u *model.UserUpdate) (*model.User, error)
 	UpdateLoginTs(ctx context.Context, id string) error
+	// SetUserStatus changes the status of the user account; returns
+	// ErrUserNotFound if the user does not exist
+	SetUserStatus(ctx context.Context, id string, status *model.UserStatusUpdate) error
 	//GetUserByEmail returns nil,nil if not found
 	GetUserByEmail(ctx context.Context, email model.Email) (*model.User, error)
 	GetUserById(ctx context.Context, id string) (*model.User, error)
 	GetUserAndPasswordById(ctx context.Context, id string) (*model.User, error)
 	GetUsers(ctx context.Context, fltr model.UserFilter) ([]model.User, error)
+	// CountUsers counts the users matching the filter, regardless of
+	// the pagination
+	CountUsers(ctx context.Context, fltr model.UserFilter) (int64, error)
 	DeleteUser(ctx context.Context, id string) error
+	// SoftDeleteUser marks the user deleted, and RestoreUser restores
+	// the deleted user; both return ErrUserNotFound if there is no such
+	// (deleted) user
+	SoftDeleteUser(ctx context.Context, id string) error
+	RestoreUser(ctx context.Context, id string) error
+	// PurgeDeletedUser removes the user deleted before the time; it
+	// returns ErrUserNotFound if there is no such user, e.g. when the
+	// user was restored or purged by another replica meanwhile
+	PurgeDeletedUser(ctx context.Context, id string, before time.Time) error
+	// GetDeletedUserTenants returns the IDs of the tenants with users
+	// deleted before the time, regardless of the identity in context;
+	// the users without a tenant are reported as the empty tenant ID
+	GetDeletedUserTenants(ctx context.Context, before time.Time) ([]string, error)
 	SaveToken(ctx context.Context, token *jwt.Token) error
 	GetTokenById(ctx context.Context, id oid.ObjectID) (*jwt.Token, error)
 	DeleteToken(ctx context.Context, userID, tokenID oid.ObjectID) error
@@ -78,4 +119,151 @@ type DataStore interface {
 	GetSettings(ctx context.Context) (*model.Settings, error)
 	SaveUserSettings(ctx context.Context, userID string, s *model.Settings, etag string) error
 	GetUserSettings(ctx context.Context, userID string) (*model.Settings, error)
+	DeleteUserSettings(ctx context.Context, userID string) error
+
+	CreateServiceAccount(ctx context.Context, sa *model.ServiceAccount) error
+	// GetServiceAccount returns nil,nil if not found
+	GetServiceAccount(ctx context.Context, id string) (*model.ServiceAccount, error)
+	GetServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error)
+	UpdateServiceAccount(ctx context.Context, id string, sa *model.ServiceAccountUpdate) error
+	DeleteServiceAccount(ctx context.Context, id string) error
+	// GetServiceAccountByClientID looks the service account up by its
+	// client ID, regardless of the tenant in the context; the returned
+	// service account has its TenantID set. Returns nil,nil if not found.
+	GetServiceAccountByClientID(
+		ctx context.Context,
+		clientID string,
+	) (*model.ServiceAccount, error)
+	// SetServiceAccountSecrets replaces the hashed client secrets
+	SetServiceAccountSecrets(
+		ctx context.Context,
+		id string,
+		secrets []model.ServiceAccountSecret,
+	) error
+	// SaveClientAssertionID records the client assertion consumed by the
+	// service account until it expires, regardless of the tenant in the
+	// context; returns ErrClientAssertionReplayed if it was already
+	// recorded
+	SaveClientAssertionID(
+		ctx context.Context,
+		clientID string,
+		id string,
+		expiresAt time.Time,
+	) error
+
+	CreateRole(ctx context.Context, role *model.Role) error
+	// GetRole returns nil,nil if not found
+	GetRole(ctx context.Context, name string) (*model.Role, error)
+	GetRoles(ctx context.Context) ([]model.Role, error)
+	// GetRolesByNames returns the roles with the given names; unknown
+	// names are ignored
+	GetRolesByNames(ctx context.Context, names []string) ([]model.Role, error)
+	UpdateRole(ctx context.Context, name string, role *model.RoleUpdate) error
+	DeleteRole(ctx context.Context, name string) error
+	// SetUserRoles replaces the roles assigned to the user
+	SetUserRoles(ctx context.Context, userID string, roles []string) error
+	// RemoveRoleFromUsers unassigns the role from all the users
+	RemoveRoleFromUsers(ctx context.Context, name string) error
+
+	CreateGroup(ctx context.Context, group *model.Group) error
+	// GetGroup returns nil,nil if not found
+	GetGroup(ctx context.Context, id string) (*model.Group, error)
+	GetGroups(ctx context.Context) ([]model.Group, error)
+	// GetGroupsByMember returns the groups the user belongs to
+	GetGroupsByMember(ctx context.Context, userID string) ([]model.Group, error)
+	UpdateGroup(ctx context.Context, id string, group *model.GroupUpdate) error
+	DeleteGroup(ctx context.Context, id string) error
+	AddGroupMember(ctx context.Context, id, userID string) error
+	RemoveGroupMember(ctx context.Context, id, userID string) error
+	// RemoveUserFromGroups removes the user from all the groups
+	RemoveUserFromGroups(ctx context.Context, userID string) error
+	// RemoveRoleFromGroups unassigns the role from all the groups
+	RemoveRoleFromGroups(ctx context.Context, name string) error
+
+	CreateElevation(ctx context.Context, elevation *model.Elevation) error
+	// GetElevation returns nil,nil if not found
+	GetElevation(ctx context.Context, id string) (*model.Elevation, error)
+	// GetElevations returns the elevations of the user, or of all the
+	// users if userID is empty, the most recent first
+	GetElevations(ctx context.Context, userID string) ([]model.Elevation, error)
+	// GetActiveElevations returns the elevations of the user which
+	// are granted and not expired yet
+	GetActiveElevations(ctx context.Context, userID string) ([]model.Elevation, error)
+	// ReviewElevation approves or rejects a pending elevation; returns
+	// ErrElevationNotFound if there's no such pending elevation
+	ReviewElevation(ctx context.Context, id string, review *model.ElevationReview) error
+
+	CreateApproval(ctx context.Context, approval *model.Approval) error
+	// GetApproval returns nil,nil if not found
+	GetApproval(ctx context.Context, id string) (*model.Approval, error)
+	// GetApprovals returns the approvals, the most recent first
+	GetApprovals(ctx context.Context) ([]model.Approval, error)
+	// UpdateApprovalStatus updates the approval in the given status;
+	// returns ErrApprovalNotFound if there's no such approval
+	UpdateApprovalStatus(
+		ctx context.Context,
+		id string,
+		status string,
+		review *model.ApprovalReview,
+	) error
+	// GetApprovalSettings returns nil,nil if not set
+	GetApprovalSettings(ctx context.Context) (*model.ApprovalSettings, error)
+	SaveApprovalSettings(ctx context.Context, settings *model.ApprovalSettings) error
+
+	// GetSAMLConfig returns nil,nil if not set
+	GetSAMLConfig(ctx context.Context) (*model.SAMLConfig, error)
+	SaveSAMLConfig(ctx context.Context, config *model.SAMLConfig) error
+	DeleteSAMLConfig(ctx context.Context) error
+	// SaveSAMLAssertionID records the consumed assertion until it expires;
+	// returns ErrSAMLAssertionReplayed if it was already recorded
+	SaveSAMLAssertionID(ctx context.Context, id string, expiresAt time.Time) error
+
+	// GetOIDCConfig returns nil,nil if not set
+	GetOIDCConfig(ctx context.Context) (*model.OIDCConfig, error)
+	SaveOIDCConfig(ctx context.Context, config *model.OIDCConfig) error
+	DeleteOIDCConfig(ctx context.Context) error
+
+	// GetUserAttributeSchema returns nil,nil if not set
+	GetUserAttributeSchema(ctx context.Context) (*model.UserAttributeSchema, error)
+	SaveUserAttributeSchema(ctx context.Context, schema *model.UserAttributeSchema) error
+
+	// GetSCIMToken returns nil,nil if not set
+	GetSCIMToken(ctx context.Context) (*model.SCIMToken, error)
+	// GetSCIMTokenByHash looks up the token of any tenant, setting
+	// its TenantID; returns nil,nil if not found
+	GetSCIMTokenByHash(ctx context.Context, hash string) (*model.SCIMToken, error)
+	SaveSCIMToken(ctx context.Context, token *model.SCIMToken) error
+	DeleteSCIMToken(ctx context.Context) error
+
+	// WatchInvalidations streams the changes invalidating the token
+	// verifications, made by any replica, across all tenants
+	WatchInvalidations(ctx context.Context) (InvalidationStream, error)
+
+	// GetRevokedTokens returns the unexpired tokens of all the tenants
+	// revoked after the given version, ordered by version; the tokens
+	// are revoked when deleted
+	GetRevokedTokens(ctx context.Context, since int64) ([]model.RevokedToken, error)
+	// GetRevocationVersion returns nil,nil if no token was ever revoked
+	GetRevocationVersion(ctx context.Context) (*model.RevocationVersion, error)
+}
+
+// Invalidation identifies the token verifications made stale by a change
+// in the database: the token was deleted, the user was deleted, changed
+// the password or was granted other roles, or the tenant's roles or
+// groups changed.
+type Invalidation struct {
+	TokenID  string
+	UserID   string
+	TenantID string
+	// All is set when the change cannot be attributed to a tenant, e.g.
+	// a deleted role
+	All bool
+}
+
+// InvalidationStream is an open stream of invalidations.
+type InvalidationStream interface {
+	// Next blocks until the next invalidation; an error means the
+	// stream is broken and invalidations may have been missed
+	Next(ctx context.Context) (*Invalidation, error)
+	Close(ctx context.Context) error
 }
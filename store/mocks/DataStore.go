@@ -18,13 +18,12 @@ package mocks
 
 import (
 	context "context"
-
+	oid "github.com/mendersoftware/go-lib-micro/mongo/oid"
 	jwt "github.com/mendersoftware/useradm/jwt"
-	mock "github.com/stretchr/testify/mock"
-
 	model "github.com/mendersoftware/useradm/model"
-
-	oid "github.com/mendersoftware/go-lib-micro/mongo/oid"
+	store "github.com/mendersoftware/useradm/store"
+	mock "github.com/stretchr/testify/mock"
+	time "time"
 )
 
 // DataStore is an autogenerated mock type for the DataStore type
@@ -32,6 +31,20 @@ type DataStore struct {
 	mock.Mock
 }
 
+// AddGroupMember provides a mock function with given fields: ctx, id, userID
+func (_m *DataStore) AddGroupMember(ctx context.Context, id string, userID string) error {
+	ret := _m.Called(ctx, id, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CountPersonalAccessTokens provides a mock function with given fields: ctx, userID
 func (_m *DataStore) CountPersonalAccessTokens(ctx context.Context, userID string) (int64, error) {
 	ret := _m.Called(ctx, userID)
@@ -53,6 +66,97 @@ func (_m *DataStore) CountPersonalAccessTokens(ctx context.Context, userID strin
 	return r0, r1
 }
 
+// CountUsers provides a mock function with given fields: ctx, fltr
+func (_m *DataStore) CountUsers(ctx context.Context, fltr model.UserFilter) (int64, error) {
+	ret := _m.Called(ctx, fltr)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter) int64); ok {
+		r0 = rf(ctx, fltr)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserFilter) error); ok {
+		r1 = rf(ctx, fltr)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateApproval provides a mock function with given fields: ctx, approval
+func (_m *DataStore) CreateApproval(ctx context.Context, approval *model.Approval) error {
+	ret := _m.Called(ctx, approval)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Approval) error); ok {
+		r0 = rf(ctx, approval)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateElevation provides a mock function with given fields: ctx, elevation
+func (_m *DataStore) CreateElevation(ctx context.Context, elevation *model.Elevation) error {
+	ret := _m.Called(ctx, elevation)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Elevation) error); ok {
+		r0 = rf(ctx, elevation)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateGroup provides a mock function with given fields: ctx, group
+func (_m *DataStore) CreateGroup(ctx context.Context, group *model.Group) error {
+	ret := _m.Called(ctx, group)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Group) error); ok {
+		r0 = rf(ctx, group)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateRole provides a mock function with given fields: ctx, role
+func (_m *DataStore) CreateRole(ctx context.Context, role *model.Role) error {
+	ret := _m.Called(ctx, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Role) error); ok {
+		r0 = rf(ctx, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateServiceAccount provides a mock function with given fields: ctx, sa
+func (_m *DataStore) CreateServiceAccount(ctx context.Context, sa *model.ServiceAccount) error {
+	ret := _m.Called(ctx, sa)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ServiceAccount) error); ok {
+		r0 = rf(ctx, sa)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateUser provides a mock function with given fields: ctx, u
 func (_m *DataStore) CreateUser(ctx context.Context, u *model.User) error {
 	ret := _m.Called(ctx, u)
@@ -67,6 +171,90 @@ func (_m *DataStore) CreateUser(ctx context.Context, u *model.User) error {
 	return r0
 }
 
+// DeleteGroup provides a mock function with given fields: ctx, id
+func (_m *DataStore) DeleteGroup(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteOIDCConfig provides a mock function with given fields: ctx
+func (_m *DataStore) DeleteOIDCConfig(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteRole provides a mock function with given fields: ctx, name
+func (_m *DataStore) DeleteRole(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSAMLConfig provides a mock function with given fields: ctx
+func (_m *DataStore) DeleteSAMLConfig(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSCIMToken provides a mock function with given fields: ctx
+func (_m *DataStore) DeleteSCIMToken(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteServiceAccount provides a mock function with given fields: ctx, id
+func (_m *DataStore) DeleteServiceAccount(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteToken provides a mock function with given fields: ctx, userID, tokenID
 func (_m *DataStore) DeleteToken(ctx context.Context, userID oid.ObjectID, tokenID oid.ObjectID) error {
 	ret := _m.Called(ctx, userID, tokenID)
@@ -137,22 +325,542 @@ func (_m *DataStore) DeleteUser(ctx context.Context, id string) error {
 	return r0
 }
 
-// GetPersonalAccessTokens provides a mock function with given fields: ctx, userID
-func (_m *DataStore) GetPersonalAccessTokens(ctx context.Context, userID string) ([]model.PersonalAccessToken, error) {
+// DeleteUserSettings provides a mock function with given fields: ctx, userID
+func (_m *DataStore) DeleteUserSettings(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetActiveElevations provides a mock function with given fields: ctx, userID
+func (_m *DataStore) GetActiveElevations(ctx context.Context, userID string) ([]model.Elevation, error) {
 	ret := _m.Called(ctx, userID)
 
-	var r0 []model.PersonalAccessToken
-	if rf, ok := ret.Get(0).(func(context.Context, string) []model.PersonalAccessToken); ok {
-		r0 = rf(ctx, userID)
+	var r0 []model.Elevation
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.Elevation); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Elevation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetApproval provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetApproval(ctx context.Context, id string) (*model.Approval, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Approval
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Approval); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Approval)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetApprovalSettings provides a mock function with given fields: ctx
+func (_m *DataStore) GetApprovalSettings(ctx context.Context) (*model.ApprovalSettings, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.ApprovalSettings
+	if rf, ok := ret.Get(0).(func(context.Context) *model.ApprovalSettings); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ApprovalSettings)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetApprovals provides a mock function with given fields: ctx
+func (_m *DataStore) GetApprovals(ctx context.Context) ([]model.Approval, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Approval
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Approval); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Approval)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeletedUserTenants provides a mock function with given fields: ctx, before
+func (_m *DataStore) GetDeletedUserTenants(ctx context.Context, before time.Time) ([]string, error) {
+	ret := _m.Called(ctx, before)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []string); ok {
+		r0 = rf(ctx, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetElevation provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetElevation(ctx context.Context, id string) (*model.Elevation, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Elevation
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Elevation); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Elevation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetElevations provides a mock function with given fields: ctx, userID
+func (_m *DataStore) GetElevations(ctx context.Context, userID string) ([]model.Elevation, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []model.Elevation
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.Elevation); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Elevation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroup provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Group
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Group); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroups provides a mock function with given fields: ctx
+func (_m *DataStore) GetGroups(ctx context.Context) ([]model.Group, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Group
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Group); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Group)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroupsByMember provides a mock function with given fields: ctx, userID
+func (_m *DataStore) GetGroupsByMember(ctx context.Context, userID string) ([]model.Group, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []model.Group
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.Group); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Group)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOIDCConfig provides a mock function with given fields: ctx
+func (_m *DataStore) GetOIDCConfig(ctx context.Context) (*model.OIDCConfig, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.OIDCConfig
+	if rf, ok := ret.Get(0).(func(context.Context) *model.OIDCConfig); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.OIDCConfig)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPersonalAccessTokens provides a mock function with given fields: ctx, userID
+func (_m *DataStore) GetPersonalAccessTokens(ctx context.Context, userID string) ([]model.PersonalAccessToken, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []model.PersonalAccessToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.PersonalAccessToken); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.PersonalAccessToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRevocationVersion provides a mock function with given fields: ctx
+func (_m *DataStore) GetRevocationVersion(ctx context.Context) (*model.RevocationVersion, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.RevocationVersion
+	if rf, ok := ret.Get(0).(func(context.Context) *model.RevocationVersion); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.RevocationVersion)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRevokedTokens provides a mock function with given fields: ctx, since
+func (_m *DataStore) GetRevokedTokens(ctx context.Context, since int64) ([]model.RevokedToken, error) {
+	ret := _m.Called(ctx, since)
+
+	var r0 []model.RevokedToken
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []model.RevokedToken); ok {
+		r0 = rf(ctx, since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.RevokedToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRole provides a mock function with given fields: ctx, name
+func (_m *DataStore) GetRole(ctx context.Context, name string) (*model.Role, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *model.Role
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Role); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Role)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRoles provides a mock function with given fields: ctx
+func (_m *DataStore) GetRoles(ctx context.Context) ([]model.Role, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Role
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Role); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Role)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRolesByNames provides a mock function with given fields: ctx, names
+func (_m *DataStore) GetRolesByNames(ctx context.Context, names []string) ([]model.Role, error) {
+	ret := _m.Called(ctx, names)
+
+	var r0 []model.Role
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []model.Role); ok {
+		r0 = rf(ctx, names)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Role)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, names)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSAMLConfig provides a mock function with given fields: ctx
+func (_m *DataStore) GetSAMLConfig(ctx context.Context) (*model.SAMLConfig, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.SAMLConfig
+	if rf, ok := ret.Get(0).(func(context.Context) *model.SAMLConfig); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SAMLConfig)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSCIMToken provides a mock function with given fields: ctx
+func (_m *DataStore) GetSCIMToken(ctx context.Context) (*model.SCIMToken, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.SCIMToken
+	if rf, ok := ret.Get(0).(func(context.Context) *model.SCIMToken); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SCIMToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSCIMTokenByHash provides a mock function with given fields: ctx, hash
+func (_m *DataStore) GetSCIMTokenByHash(ctx context.Context, hash string) (*model.SCIMToken, error) {
+	ret := _m.Called(ctx, hash)
+
+	var r0 *model.SCIMToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.SCIMToken); ok {
+		r0 = rf(ctx, hash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SCIMToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetServiceAccount provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetServiceAccount(ctx context.Context, id string) (*model.ServiceAccount, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.ServiceAccount
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ServiceAccount); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ServiceAccount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetServiceAccountByClientID provides a mock function with given fields: ctx, clientID
+func (_m *DataStore) GetServiceAccountByClientID(ctx context.Context, clientID string) (*model.ServiceAccount, error) {
+	ret := _m.Called(ctx, clientID)
+
+	var r0 *model.ServiceAccount
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ServiceAccount); ok {
+		r0 = rf(ctx, clientID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ServiceAccount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, clientID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetServiceAccounts provides a mock function with given fields: ctx
+func (_m *DataStore) GetServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.ServiceAccount
+	if rf, ok := ret.Get(0).(func(context.Context) []model.ServiceAccount); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.PersonalAccessToken)
+			r0 = ret.Get(0).([]model.ServiceAccount)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -229,6 +937,29 @@ func (_m *DataStore) GetUserAndPasswordById(ctx context.Context, id string) (*mo
 	return r0, r1
 }
 
+// GetUserAttributeSchema provides a mock function with given fields: ctx
+func (_m *DataStore) GetUserAttributeSchema(ctx context.Context) (*model.UserAttributeSchema, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.UserAttributeSchema
+	if rf, ok := ret.Get(0).(func(context.Context) *model.UserAttributeSchema); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAttributeSchema)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserByEmail provides a mock function with given fields: ctx, email
 func (_m *DataStore) GetUserByEmail(ctx context.Context, email model.Email) (*model.User, error) {
 	ret := _m.Called(ctx, email)
@@ -335,6 +1066,188 @@ func (_m *DataStore) Ping(ctx context.Context) error {
 	return r0
 }
 
+// PurgeDeletedUser provides a mock function with given fields: ctx, id, before
+func (_m *DataStore) PurgeDeletedUser(ctx context.Context, id string, before time.Time) error {
+	ret := _m.Called(ctx, id, before)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, id, before)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveGroupMember provides a mock function with given fields: ctx, id, userID
+func (_m *DataStore) RemoveGroupMember(ctx context.Context, id string, userID string) error {
+	ret := _m.Called(ctx, id, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveRoleFromGroups provides a mock function with given fields: ctx, name
+func (_m *DataStore) RemoveRoleFromGroups(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveRoleFromUsers provides a mock function with given fields: ctx, name
+func (_m *DataStore) RemoveRoleFromUsers(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveUserFromGroups provides a mock function with given fields: ctx, userID
+func (_m *DataStore) RemoveUserFromGroups(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreUser provides a mock function with given fields: ctx, id
+func (_m *DataStore) RestoreUser(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReviewElevation provides a mock function with given fields: ctx, id, review
+func (_m *DataStore) ReviewElevation(ctx context.Context, id string, review *model.ElevationReview) error {
+	ret := _m.Called(ctx, id, review)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.ElevationReview) error); ok {
+		r0 = rf(ctx, id, review)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveApprovalSettings provides a mock function with given fields: ctx, settings
+func (_m *DataStore) SaveApprovalSettings(ctx context.Context, settings *model.ApprovalSettings) error {
+	ret := _m.Called(ctx, settings)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ApprovalSettings) error); ok {
+		r0 = rf(ctx, settings)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveClientAssertionID provides a mock function with given fields: ctx, clientID, id, expiresAt
+func (_m *DataStore) SaveClientAssertionID(ctx context.Context, clientID string, id string, expiresAt time.Time) error {
+	ret := _m.Called(ctx, clientID, id, expiresAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) error); ok {
+		r0 = rf(ctx, clientID, id, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveOIDCConfig provides a mock function with given fields: ctx, config
+func (_m *DataStore) SaveOIDCConfig(ctx context.Context, config *model.OIDCConfig) error {
+	ret := _m.Called(ctx, config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.OIDCConfig) error); ok {
+		r0 = rf(ctx, config)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveSAMLAssertionID provides a mock function with given fields: ctx, id, expiresAt
+func (_m *DataStore) SaveSAMLAssertionID(ctx context.Context, id string, expiresAt time.Time) error {
+	ret := _m.Called(ctx, id, expiresAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, id, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveSAMLConfig provides a mock function with given fields: ctx, config
+func (_m *DataStore) SaveSAMLConfig(ctx context.Context, config *model.SAMLConfig) error {
+	ret := _m.Called(ctx, config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.SAMLConfig) error); ok {
+		r0 = rf(ctx, config)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveSCIMToken provides a mock function with given fields: ctx, token
+func (_m *DataStore) SaveSCIMToken(ctx context.Context, token *model.SCIMToken) error {
+	ret := _m.Called(ctx, token)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.SCIMToken) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SaveSettings provides a mock function with given fields: ctx, s, etag
 func (_m *DataStore) SaveSettings(ctx context.Context, s *model.Settings, etag string) error {
 	ret := _m.Called(ctx, s, etag)
@@ -363,6 +1276,20 @@ func (_m *DataStore) SaveToken(ctx context.Context, token *jwt.Token) error {
 	return r0
 }
 
+// SaveUserAttributeSchema provides a mock function with given fields: ctx, schema
+func (_m *DataStore) SaveUserAttributeSchema(ctx context.Context, schema *model.UserAttributeSchema) error {
+	ret := _m.Called(ctx, schema)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserAttributeSchema) error); ok {
+		r0 = rf(ctx, schema)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SaveUserSettings provides a mock function with given fields: ctx, userID, s, etag
 func (_m *DataStore) SaveUserSettings(ctx context.Context, userID string, s *model.Settings, etag string) error {
 	ret := _m.Called(ctx, userID, s, etag)
@@ -377,6 +1304,90 @@ func (_m *DataStore) SaveUserSettings(ctx context.Context, userID string, s *mod
 	return r0
 }
 
+// SetServiceAccountSecrets provides a mock function with given fields: ctx, id, secrets
+func (_m *DataStore) SetServiceAccountSecrets(ctx context.Context, id string, secrets []model.ServiceAccountSecret) error {
+	ret := _m.Called(ctx, id, secrets)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []model.ServiceAccountSecret) error); ok {
+		r0 = rf(ctx, id, secrets)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUserRoles provides a mock function with given fields: ctx, userID, roles
+func (_m *DataStore) SetUserRoles(ctx context.Context, userID string, roles []string) error {
+	ret := _m.Called(ctx, userID, roles)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, userID, roles)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUserStatus provides a mock function with given fields: ctx, id, status
+func (_m *DataStore) SetUserStatus(ctx context.Context, id string, status *model.UserStatusUpdate) error {
+	ret := _m.Called(ctx, id, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.UserStatusUpdate) error); ok {
+		r0 = rf(ctx, id, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SoftDeleteUser provides a mock function with given fields: ctx, id
+func (_m *DataStore) SoftDeleteUser(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateApprovalStatus provides a mock function with given fields: ctx, id, status, review
+func (_m *DataStore) UpdateApprovalStatus(ctx context.Context, id string, status string, review *model.ApprovalReview) error {
+	ret := _m.Called(ctx, id, status, review)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *model.ApprovalReview) error); ok {
+		r0 = rf(ctx, id, status, review)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateGroup provides a mock function with given fields: ctx, id, group
+func (_m *DataStore) UpdateGroup(ctx context.Context, id string, group *model.GroupUpdate) error {
+	ret := _m.Called(ctx, id, group)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.GroupUpdate) error); ok {
+		r0 = rf(ctx, id, group)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateLoginTs provides a mock function with given fields: ctx, id
 func (_m *DataStore) UpdateLoginTs(ctx context.Context, id string) error {
 	ret := _m.Called(ctx, id)
@@ -391,6 +1402,34 @@ func (_m *DataStore) UpdateLoginTs(ctx context.Context, id string) error {
 	return r0
 }
 
+// UpdateRole provides a mock function with given fields: ctx, name, role
+func (_m *DataStore) UpdateRole(ctx context.Context, name string, role *model.RoleUpdate) error {
+	ret := _m.Called(ctx, name, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.RoleUpdate) error); ok {
+		r0 = rf(ctx, name, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateServiceAccount provides a mock function with given fields: ctx, id, sa
+func (_m *DataStore) UpdateServiceAccount(ctx context.Context, id string, sa *model.ServiceAccountUpdate) error {
+	ret := _m.Called(ctx, id, sa)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.ServiceAccountUpdate) error); ok {
+		r0 = rf(ctx, id, sa)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateTokenLastUsed provides a mock function with given fields: ctx, id
 func (_m *DataStore) UpdateTokenLastUsed(ctx context.Context, id oid.ObjectID) error {
 	ret := _m.Called(ctx, id)
@@ -427,3 +1466,26 @@ func (_m *DataStore) UpdateUser(ctx context.Context, id string, u *model.UserUpd
 
 	return r0, r1
 }
+
+// WatchInvalidations provides a mock function with given fields: ctx
+func (_m *DataStore) WatchInvalidations(ctx context.Context) (store.InvalidationStream, error) {
+	ret := _m.Called(ctx)
+
+	var r0 store.InvalidationStream
+	if rf, ok := ret.Get(0).(func(context.Context) store.InvalidationStream); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.InvalidationStream)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
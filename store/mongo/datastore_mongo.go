@@ -17,6 +17,7 @@ package mongo
 import (
 	"context"
 	"crypto/tls"
+	"regexp"
 	"strings"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	mstore "github.com/mendersoftware/go-lib-micro/store/v2"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	mopts "go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
@@ -35,14 +37,34 @@ import (
 )
 
 const (
-	DbUsersColl        = "users"
-	DbTokensColl       = "tokens"
-	DbSettingsColl     = "settings"
-	DbUserSettingsColl = "user_settings"
+	DbUsersColl            = "users"
+	DbTokensColl           = "tokens"
+	DbSettingsColl         = "settings"
+	DbUserSettingsColl     = "user_settings"
+	DbServiceAccountsColl  = "service_accounts"
+	DbRolesColl            = "roles"
+	DbGroupsColl           = "groups"
+	DbElevationsColl       = "elevations"
+	DbApprovalsColl        = "approvals"
+	DbApprovalSettingsColl = "approval_settings"
+	DbSAMLConfigColl       = "saml_config"
+	DbSAMLAssertionsColl   = "saml_assertions"
+	DbClientAssertionsColl = "client_assertions"
+	DbOIDCConfigColl       = "oidc_config"
+	DbUserAttributesColl   = "user_attribute_schema"
+	DbSCIMTokensColl       = "scim_tokens"
+	DbRevokedTokensColl    = "revoked_tokens"
+	DbCountersColl         = "counters"
 
 	DbUserEmail      = "email"
 	DbUserPass       = "password"
 	DbUserLoginTs    = "login_ts"
+	DbUserRoles      = "roles"
+	DbUserCreatedTs  = "created_ts"
+	DbUserUpdatedTs  = "updated_ts"
+	DbUserAttributes = "attributes"
+	DbUserStatus     = "status"
+	DbUserDeletedTs  = "deleted_ts"
 	DbTokenSubject   = "sub"
 	DbTokenExpiresAt = "exp"
 	DbTokenIssuedAt  = "iat"
@@ -61,9 +83,68 @@ const (
 	DbTokenSubjectIndexName    = "token_subject_1"
 	DbTokenExpirationIndexName = "token_expiration"
 
+	DbTenantUserCreatedIndexName = "tenant_1_created_ts_1__id_1"
+	DbTenantUserUpdatedIndexName = "tenant_1_updated_ts_1__id_1"
+	DbTenantUserLoginIndexName   = "tenant_1_login_ts_1__id_1"
+	DbTenantUserEmailIndexName   = "tenant_1_email_1"
+	DbUserDeletedIndexName       = "deleted_ts_1"
+
 	DbTenantUniqueTokenNameIndexName = "tenant_1_subject_1_name_1"
 	DbTenantTokenSubjectIndexName    = "tenant_1_subject_1"
 
+	DbServiceAccountName                = "name"
+	DbServiceAccountSecrets             = "secrets"
+	DbTenantServiceAccountNameIndexName = "tenant_1_name_1"
+
+	DbClientAssertionClientID            = "client_id"
+	DbClientAssertionID                  = "jti"
+	DbClientAssertionExpiresAt           = "exp"
+	DbClientAssertionIDIndexName         = "client_id_1_jti_1"
+	DbClientAssertionExpirationIndexName = "exp_ttl"
+
+	DbRoleName                = "name"
+	DbTenantRoleNameIndexName = "tenant_1_name_1"
+
+	DbGroupName                   = "name"
+	DbGroupRoles                  = "roles"
+	DbGroupMembers                = "members"
+	DbTenantGroupNameIndexName    = "tenant_1_name_1"
+	DbTenantGroupMembersIndexName = "tenant_1_members_1"
+
+	DbElevationUserID                 = "user_id"
+	DbElevationStatus                 = "status"
+	DbElevationCreatedTs              = "created_ts"
+	DbElevationExpiresTs              = "expires_ts"
+	DbTenantElevationUserIndexName    = "tenant_1_user_id_1_status_1_expires_ts_1"
+	DbTenantElevationCreatedIndexName = "tenant_1_created_ts_-1"
+
+	DbApprovalStatus                  = "status"
+	DbApprovalCreatedTs               = "created_ts"
+	DbTenantApprovalCreatedIndexName  = "tenant_1_created_ts_-1"
+	DbTenantApprovalSettingsIndexName = "tenant_1"
+
+	DbTenantSAMLConfigIndexName = "tenant_1"
+
+	DbSAMLAssertionID                  = "assertion_id"
+	DbSAMLAssertionExpiresAt           = "exp"
+	DbTenantSAMLAssertionIDIndexName   = "tenant_1_assertion_id_1"
+	DbSAMLAssertionExpirationIndexName = "exp_ttl"
+	DbTenantOIDCConfigIndexName        = "tenant_1"
+
+	DbTenantUserAttributesIndexName = "tenant_1"
+
+	DbSCIMTokenHash            = "hash"
+	DbTenantSCIMTokenIndexName = "tenant_1"
+	DbUniqueSCIMTokenIndexName = "hash_1"
+
+	DbRevokedTokenVersion             = "version"
+	DbRevokedTokenExpiresAt           = "exp"
+	DbRevokedTokenVersionIndexName    = "version_1"
+	DbRevokedTokenExpirationIndexName = "exp_ttl"
+	DbCounterRevocations              = "revocations"
+	DbCounterVersion                  = "version"
+	DbCounterUpdatedTs                = "updated_ts"
+
 	DbSettingsEtag            = "etag"
 	DbSettingsTenantIndexName = "tenant"
 	DbSettingsUserID          = "user_id"
@@ -225,11 +306,35 @@ func (db *DataStoreMongo) UpdateUser(
 		Database(mstore.DbFromContext(ctx, DbName)).
 		Collection(DbUsersColl)
 
-	f := bson.M{"_id": id}
-	up := bson.M{"$set": u}
+	set, err := bson.Marshal(u)
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to encode the update")
+	}
+	var setDoc, unsetDoc bson.D
+	if err = bson.Unmarshal(set, &setDoc); err != nil {
+		return nil, errors.Wrap(err, "store: failed to encode the update")
+	}
+	// the attributes are changed one by one
+	for name, value := range u.Attributes {
+		key := DbUserAttributes + "." + name
+		if value == nil {
+			unsetDoc = append(unsetDoc, bson.E{Key: key, Value: ""})
+		} else {
+			setDoc = append(setDoc, bson.E{Key: key, Value: value})
+		}
+	}
+
+	f := bson.M{
+		"_id":           id,
+		DbUserDeletedTs: bson.M{"$exists": false},
+	}
+	up := bson.D{{Key: "$set", Value: setDoc}}
+	if len(unsetDoc) > 0 {
+		up = append(up, bson.E{Key: "$unset", Value: unsetDoc})
+	}
 	fuOpts := mopts.FindOneAndUpdate().
 		SetReturnDocument(mopts.Before)
-	err := collUsers.FindOneAndUpdate(ctx, mstore.WithTenantID(ctx, f), up, fuOpts).
+	err = collUsers.FindOneAndUpdate(ctx, mstore.WithTenantID(ctx, f), up, fuOpts).
 		Decode(updatedUser)
 
 	switch {
@@ -258,6 +363,32 @@ func (db *DataStoreMongo) UpdateLoginTs(ctx context.Context, id string) error {
 	return err
 }
 
+func (db *DataStoreMongo) SetUserStatus(
+	ctx context.Context,
+	id string,
+	status *model.UserStatusUpdate,
+) error {
+	now := time.Now().UTC()
+	status.StatusTs = &now
+
+	res, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUsersColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.D{
+				{Key: DbID, Value: id},
+				{Key: DbUserDeletedTs, Value: bson.D{{Key: "$exists", Value: false}}},
+			}),
+			bson.D{{Key: "$set", Value: status}},
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to update user status")
+	} else if res.MatchedCount == 0 {
+		return store.ErrUserNotFound
+	}
+	return nil
+}
+
 func (db *DataStoreMongo) GetUserByEmail(
 	ctx context.Context,
 	email model.Email,
@@ -329,18 +460,23 @@ func (db *DataStoreMongo) GetTokenById(ctx context.Context, id oid.ObjectID) (*j
 	return &token, nil
 }
 
-func (db *DataStoreMongo) GetUsers(
+// usersFilter translates the user filter to the query, the pagination
+// aside
+func (db *DataStoreMongo) usersFilter(
 	ctx context.Context,
 	fltr model.UserFilter,
-) ([]model.User, error) {
-	findOpts := mopts.Find().
-		SetProjection(bson.M{DbUserPass: 0})
-
-	collUsers := db.client.
-		Database(mstore.DbFromContext(ctx, DbName)).
-		Collection(DbUsersColl)
-
+) (bson.D, error) {
 	var mgoFltr = bson.D{}
+	if fltr.Group != nil {
+		ids, err := db.getGroupMembers(ctx, fltr.Group)
+		if err != nil {
+			return nil, err
+		}
+		if fltr.ID != nil {
+			ids = intersect(ids, fltr.ID)
+		}
+		fltr.ID = ids
+	}
 	if fltr.ID != nil {
 		mgoFltr = append(mgoFltr, bson.E{Key: "_id", Value: bson.D{{
 			Key: "$in", Value: fltr.ID,
@@ -379,6 +515,185 @@ func (db *DataStoreMongo) GetUsers(
 			}},
 		})
 	}
+	// emails are stored in lower case: the anchored regex uses the index
+	if fltr.EmailPrefix != "" {
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserEmail, Value: primitive.Regex{
+				Pattern: "^" + regexp.QuoteMeta(fltr.EmailPrefix),
+			},
+		})
+	}
+	if fltr.EmailContains != "" {
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserEmail, Value: primitive.Regex{
+				Pattern: regexp.QuoteMeta(fltr.EmailContains),
+			},
+		})
+	}
+	if fltr.LoginAfter != nil {
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserLoginTs, Value: bson.D{{
+				Key: "$gt", Value: *fltr.LoginAfter,
+			}},
+		})
+	}
+	if fltr.LoginBefore != nil {
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserLoginTs, Value: bson.D{{
+				Key: "$lt", Value: *fltr.LoginBefore,
+			}},
+		})
+	}
+	if fltr.InactiveSince != nil {
+		// unlike $lt, $not also matches the users without login_ts
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserLoginTs, Value: bson.D{{
+				Key: "$not", Value: bson.D{{
+					Key: "$gte", Value: *fltr.InactiveSince,
+				}},
+			}},
+		})
+	}
+	switch fltr.Status {
+	case model.UserStatusActive:
+		// the status of the users who were never disabled is not set
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserStatus, Value: bson.D{{
+				Key: "$ne", Value: model.UserStatusDisabled,
+			}},
+		})
+	case model.UserStatusDisabled:
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserStatus, Value: fltr.Status,
+		})
+	}
+	// the deleted users are listed only on request
+	if fltr.DeletedBefore != nil {
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserDeletedTs, Value: bson.D{{
+				Key: "$lt", Value: *fltr.DeletedBefore,
+			}},
+		})
+	} else {
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserDeletedTs, Value: bson.D{{
+				Key: "$exists", Value: fltr.Deleted,
+			}},
+		})
+	}
+	for name, value := range fltr.Attributes {
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserAttributes + "." + name, Value: value,
+		})
+	}
+	if fltr.NeverLoggedIn != nil {
+		mgoFltr = append(mgoFltr, bson.E{
+			Key: DbUserLoginTs, Value: bson.D{{
+				Key: "$exists", Value: !*fltr.NeverLoggedIn,
+			}},
+		})
+	}
+	return andDuplicates(mgoFltr), nil
+}
+
+// andDuplicates moves the conditions on the fields filtered more than
+// once under $and: in a single document, the last condition on a field
+// would override the others.
+func andDuplicates(fltr bson.D) bson.D {
+	count := make(map[string]int, len(fltr))
+	for _, cond := range fltr {
+		count[cond.Key]++
+	}
+	ret := make(bson.D, 0, len(fltr))
+	var and bson.A
+	for _, cond := range fltr {
+		if count[cond.Key] > 1 {
+			and = append(and, bson.D{cond})
+		} else {
+			ret = append(ret, cond)
+		}
+	}
+	if len(and) > 0 {
+		ret = append(ret, bson.E{Key: "$and", Value: and})
+	}
+	return ret
+}
+
+// usersAfter matches the users sorted after the cursor; the users
+// without the timestamp sort first
+func usersAfter(c *model.UserCursor) bson.E {
+	var value interface{} = c.Email
+	if c.Sort.Field != model.UserSortEmail {
+		value = c.Time
+		if c.Time == nil {
+			value = nil
+		}
+	}
+	cmp := "$gt"
+	if c.Sort.Desc {
+		cmp = "$lt"
+	}
+	sameValue := bson.D{
+		{Key: c.Sort.Field, Value: value},
+		{Key: DbID, Value: bson.D{{Key: cmp, Value: c.ID}}},
+	}
+	var or bson.A
+	switch {
+	case value != nil:
+		or = bson.A{
+			bson.D{{Key: c.Sort.Field, Value: bson.D{{Key: cmp, Value: value}}}},
+			sameValue,
+		}
+		if c.Sort.Desc {
+			or = append(or, bson.D{{Key: c.Sort.Field, Value: nil}})
+		}
+	case c.Sort.Desc:
+		or = bson.A{sameValue}
+	default:
+		or = bson.A{
+			sameValue,
+			bson.D{{Key: c.Sort.Field, Value: bson.D{{Key: "$ne", Value: nil}}}},
+		}
+	}
+	return bson.E{Key: "$or", Value: or}
+}
+
+func (db *DataStoreMongo) GetUsers(
+	ctx context.Context,
+	fltr model.UserFilter,
+) ([]model.User, error) {
+	sort := model.UserSort{Field: model.UserSortEmail}
+	if fltr.Sort != nil {
+		sort = *fltr.Sort
+	}
+	order := 1
+	if sort.Desc {
+		order = -1
+	}
+	findOpts := mopts.Find().
+		SetProjection(bson.M{DbUserPass: 0}).
+		SetSort(bson.D{
+			{Key: sort.Field, Value: order},
+			{Key: DbID, Value: order},
+		})
+	if fltr.Skip > 0 {
+		findOpts.SetSkip(fltr.Skip)
+	}
+	if fltr.Limit > 0 {
+		findOpts.SetLimit(fltr.Limit)
+	}
+
+	collUsers := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUsersColl)
+
+	mgoFltr, err := db.usersFilter(ctx, fltr)
+	if err != nil {
+		return nil, err
+	}
+	if fltr.After != nil {
+		mgoFltr = append(mgoFltr, usersAfter(fltr.After))
+	}
 	cur, err := collUsers.Find(ctx, mstore.WithTenantID(ctx, mgoFltr), findOpts)
 	if err != nil {
 		return nil, errors.Wrap(err, "store: failed to fetch users")
@@ -394,6 +709,24 @@ func (db *DataStoreMongo) GetUsers(
 	}
 }
 
+func (db *DataStoreMongo) CountUsers(
+	ctx context.Context,
+	fltr model.UserFilter,
+) (int64, error) {
+	mgoFltr, err := db.usersFilter(ctx, fltr)
+	if err != nil {
+		return -1, err
+	}
+	count, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUsersColl).
+		CountDocuments(ctx, mstore.WithTenantID(ctx, mgoFltr))
+	if err != nil {
+		return -1, errors.Wrap(err, "store: failed to count users")
+	}
+	return count, nil
+}
+
 func (db *DataStoreMongo) DeleteUser(ctx context.Context, id string) error {
 	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
 		Collection(DbUsersColl).
@@ -406,6 +739,109 @@ func (db *DataStoreMongo) DeleteUser(ctx context.Context, id string) error {
 	return nil
 }
 
+func (db *DataStoreMongo) SoftDeleteUser(ctx context.Context, id string) error {
+	res, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUsersColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.D{
+				{Key: DbID, Value: id},
+				{Key: DbUserDeletedTs, Value: bson.D{{Key: "$exists", Value: false}}},
+			}),
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: DbUserDeletedTs, Value: time.Now().UTC()},
+			}}},
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to delete user")
+	} else if res.MatchedCount == 0 {
+		return store.ErrUserNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) RestoreUser(ctx context.Context, id string) error {
+	res, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUsersColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.D{
+				{Key: DbID, Value: id},
+				{Key: DbUserDeletedTs, Value: bson.D{{Key: "$exists", Value: true}}},
+			}),
+			bson.D{{Key: "$unset", Value: bson.D{
+				{Key: DbUserDeletedTs, Value: ""},
+			}}},
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to restore user")
+	} else if res.MatchedCount == 0 {
+		return store.ErrUserNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) PurgeDeletedUser(
+	ctx context.Context,
+	id string,
+	before time.Time,
+) error {
+	res, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUsersColl).
+		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.D{
+			{Key: DbID, Value: id},
+			{Key: DbUserDeletedTs, Value: bson.D{{Key: "$lt", Value: before}}},
+		}))
+	if err != nil {
+		return errors.Wrap(err, "store: failed to purge user")
+	} else if res.DeletedCount == 0 {
+		return store.ErrUserNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) GetDeletedUserTenants(
+	ctx context.Context,
+	before time.Time,
+) ([]string, error) {
+	collUsers := db.client.
+		Database(DbName).
+		Collection(DbUsersColl)
+	deletedBefore := bson.E{
+		Key: DbUserDeletedTs, Value: bson.D{{Key: "$lt", Value: before}},
+	}
+	values, err := collUsers.Distinct(ctx, mstore.FieldTenantID,
+		bson.D{deletedBefore})
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to get tenants")
+	}
+	tenants := make([]string, 0, len(values)+1)
+	singleTenant := false
+	for _, value := range values {
+		if tenantID, ok := value.(string); ok {
+			singleTenant = singleTenant || tenantID == ""
+			tenants = append(tenants, tenantID)
+		}
+	}
+	if singleTenant {
+		return tenants, nil
+	}
+
+	// Distinct skips the users without a tenant ID, i.e. those of the
+	// single-tenant setup, which are reported as the empty tenant ID
+	count, err := collUsers.CountDocuments(ctx, bson.D{
+		{Key: mstore.FieldTenantID, Value: bson.D{{Key: "$exists", Value: false}}},
+		deletedBefore,
+	}, mopts.Count().SetLimit(1))
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to get tenants")
+	} else if count > 0 {
+		tenants = append(tenants, "")
+	}
+	return tenants, nil
+}
+
 func (db *DataStoreMongo) SaveToken(ctx context.Context, token *jwt.Token) error {
 	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
 		Collection(DbTokensColl).
@@ -440,20 +876,96 @@ func (db *DataStoreMongo) WithAutomigrate() *DataStoreMongo {
 	}
 }
 
+// revokeTokens records the unexpired tokens matching the filter in the
+// revocation list; called before deleting the tokens, so that the
+// tokens are never deleted without being revoked
+func (db *DataStoreMongo) revokeTokens(ctx context.Context, filter interface{}) error {
+	cur, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbTokensColl).
+		Find(ctx, filter, mopts.Find().
+			SetProjection(bson.M{DbID: 1, DbTokenExpiresAt: 1}))
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch tokens")
+	}
+	var tokens []struct {
+		ID        oid.ObjectID `bson:"_id"`
+		ExpiresAt jwt.Time     `bson:"exp"`
+	}
+	if err = cur.All(ctx, &tokens); err != nil {
+		return errors.Wrap(err, "failed to decode tokens")
+	}
+
+	now := time.Now().UTC()
+	revoked := make([]interface{}, 0, len(tokens))
+	for _, token := range tokens {
+		if token.ExpiresAt.After(now) {
+			revoked = append(revoked, &model.RevokedToken{
+				ID:        token.ID,
+				ExpiresAt: token.ExpiresAt.UTC(),
+				RevokedAt: now,
+			})
+		}
+	}
+	if len(revoked) == 0 {
+		return nil
+	}
+
+	// allocate the versions of the revocations
+	var ver model.RevocationVersion
+	err = db.client.Database(DbName).
+		Collection(DbCountersColl).
+		FindOneAndUpdate(ctx,
+			bson.M{DbID: DbCounterRevocations},
+			bson.M{
+				"$inc": bson.M{DbCounterVersion: len(revoked)},
+				"$set": bson.M{DbCounterUpdatedTs: now},
+			},
+			mopts.FindOneAndUpdate().
+				SetUpsert(true).
+				SetReturnDocument(mopts.After),
+		).Decode(&ver)
+	if err != nil {
+		return errors.Wrap(err, "failed to allocate revocation versions")
+	}
+	first := ver.Version - int64(len(revoked)) + 1
+	for i := range revoked {
+		revoked[i].(*model.RevokedToken).Version = first + int64(i)
+	}
+
+	// the token may be revoked concurrently; the version allocated
+	// here is then skipped
+	_, err = db.client.Database(DbName).
+		Collection(DbRevokedTokensColl).
+		InsertMany(ctx, revoked, mopts.InsertMany().SetOrdered(false))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return errors.Wrap(err, "failed to revoke tokens")
+	}
+	return nil
+}
+
 func (db *DataStoreMongo) DeleteToken(ctx context.Context, userID, tokenID oid.ObjectID) error {
+	filter := mstore.WithTenantID(ctx, bson.M{DbID: tokenID, DbTokenSubject: userID})
+	if err := db.revokeTokens(ctx, filter); err != nil {
+		return err
+	}
 	_, err := db.client.
 		Database(mstore.DbFromContext(ctx, DbName)).
 		Collection(DbTokensColl).
-		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.M{DbID: tokenID, DbTokenSubject: userID}))
+		DeleteOne(ctx, filter)
 	return err
 }
 
 // deletes all tenant's tokens (identity in context)
 func (db *DataStoreMongo) DeleteTokens(ctx context.Context) error {
+	filter := mstore.WithTenantID(ctx, bson.M{})
+	if err := db.revokeTokens(ctx, filter); err != nil {
+		return err
+	}
 	d, err := db.client.
 		Database(mstore.DbFromContext(ctx, DbName)).
 		Collection(DbTokensColl).
-		DeleteMany(ctx, mstore.WithTenantID(ctx, bson.M{}))
+		DeleteMany(ctx, filter)
 
 	if err != nil {
 		return err
@@ -492,7 +1004,11 @@ func (db *DataStoreMongo) DeleteTokensByUserIdExceptCurrentOne(
 		}
 	}
 
-	_, err := c.DeleteMany(ctx, mstore.WithTenantID(ctx, filter))
+	tenantFilter := mstore.WithTenantID(ctx, filter)
+	if err := db.revokeTokens(ctx, tenantFilter); err != nil {
+		return err
+	}
+	_, err := c.DeleteMany(ctx, tenantFilter)
 	if err != nil {
 		return errors.Wrap(err, "failed to remove tokens")
 	}
@@ -596,6 +1112,18 @@ func (db *DataStoreMongo) GetUserSettings(ctx context.Context,
 	}
 }
 
+func (db *DataStoreMongo) DeleteUserSettings(ctx context.Context, userID string) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUserSettingsColl).
+		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.M{
+			DbSettingsUserID: userID,
+		}))
+	if err != nil {
+		return errors.Wrap(err, "failed to delete settings")
+	}
+	return nil
+}
+
 func (db *DataStoreMongo) GetPersonalAccessTokens(
 	ctx context.Context,
 	userID string,
@@ -608,6 +1136,7 @@ func (db *DataStoreMongo) GetPersonalAccessTokens(
 				DbTokenExpiresAt: 1,
 				DbTokenLastUsed:  1,
 				DbTokenIssuedAt:  1,
+				DbTokenScope:     1,
 			},
 		)
 
@@ -667,3 +1196,1133 @@ func (db *DataStoreMongo) CountPersonalAccessTokens(
 	}
 	return count, nil
 }
+
+func (db *DataStoreMongo) CreateServiceAccount(
+	ctx context.Context,
+	sa *model.ServiceAccount,
+) error {
+	now := time.Now().UTC()
+
+	sa.CreatedTs = &now
+	sa.UpdatedTs = &now
+
+	_, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbServiceAccountsColl).
+		InsertOne(ctx, mstore.WithTenantID(ctx, sa))
+
+	if isDuplicateKeyError(err) {
+		return store.ErrDuplicateServiceAccountName
+	} else if err != nil {
+		return errors.Wrap(err, "failed to insert service account")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetServiceAccount(
+	ctx context.Context,
+	id string,
+) (*model.ServiceAccount, error) {
+	var sa model.ServiceAccount
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbServiceAccountsColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{DbID: id})).
+		Decode(&sa)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		} else {
+			return nil, errors.Wrap(err, "failed to fetch service account")
+		}
+	}
+
+	return &sa, nil
+}
+
+func (db *DataStoreMongo) GetServiceAccounts(
+	ctx context.Context,
+) ([]model.ServiceAccount, error) {
+	findOpts := mopts.Find().
+		SetProjection(bson.M{DbServiceAccountSecrets: 0}).
+		SetSort(bson.D{{Key: DbServiceAccountName, Value: 1}})
+
+	cur, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbServiceAccountsColl).
+		Find(ctx, mstore.WithTenantID(ctx, bson.M{}), findOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to fetch service accounts")
+	}
+
+	serviceAccounts := []model.ServiceAccount{}
+	err = cur.All(ctx, &serviceAccounts)
+	switch err {
+	case nil, mongo.ErrNoDocuments:
+		return serviceAccounts, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to decode service accounts")
+	}
+}
+
+func (db *DataStoreMongo) UpdateServiceAccount(
+	ctx context.Context,
+	id string,
+	sa *model.ServiceAccountUpdate,
+) error {
+	now := time.Now().UTC()
+	sa.UpdatedTs = &now
+
+	res, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbServiceAccountsColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbID: id}),
+			bson.M{"$set": sa},
+		)
+
+	switch {
+	case isDuplicateKeyError(err):
+		return store.ErrDuplicateServiceAccountName
+	case err != nil:
+		return errors.Wrap(err, "store: failed to update service account")
+	case res.MatchedCount == 0:
+		return store.ErrServiceAccountNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteServiceAccount(ctx context.Context, id string) error {
+	res, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbServiceAccountsColl).
+		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.M{DbID: id}))
+
+	if err != nil {
+		return errors.Wrap(err, "store: failed to delete service account")
+	} else if res.DeletedCount == 0 {
+		return store.ErrServiceAccountNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetServiceAccountByClientID(
+	ctx context.Context,
+	clientID string,
+) (*model.ServiceAccount, error) {
+	var sa model.ServiceAccount
+
+	// the client ID is globally unique: don't filter by tenant, the
+	// caller is not authenticated yet
+	err := db.client.Database(DbName).
+		Collection(DbServiceAccountsColl).
+		FindOne(ctx, bson.M{DbID: clientID}).
+		Decode(&sa)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		} else {
+			return nil, errors.Wrap(err, "failed to fetch service account")
+		}
+	}
+
+	return &sa, nil
+}
+
+func (db *DataStoreMongo) SetServiceAccountSecrets(
+	ctx context.Context,
+	id string,
+	secrets []model.ServiceAccountSecret,
+) error {
+	res, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbServiceAccountsColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbID: id}),
+			bson.M{"$set": bson.M{
+				DbServiceAccountSecrets: secrets,
+				"updated_ts":            time.Now().UTC(),
+			}},
+		)
+
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "store: failed to update service account secrets")
+	case res.MatchedCount == 0:
+		return store.ErrServiceAccountNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) SaveClientAssertionID(
+	ctx context.Context,
+	clientID string,
+	id string,
+	expiresAt time.Time,
+) error {
+	// the client ID is globally unique, as in GetServiceAccountByClientID
+	_, err := db.client.Database(DbName).
+		Collection(DbClientAssertionsColl).
+		InsertOne(ctx, bson.D{
+			{Key: DbClientAssertionClientID, Value: clientID},
+			{Key: DbClientAssertionID, Value: id},
+			{Key: DbClientAssertionExpiresAt, Value: expiresAt},
+		})
+	if isDuplicateKeyError(err) {
+		return store.ErrClientAssertionReplayed
+	} else if err != nil {
+		return errors.Wrap(err, "store: failed to save client assertion ID")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) CreateRole(ctx context.Context, role *model.Role) error {
+	now := time.Now().UTC()
+
+	role.CreatedTs = &now
+	role.UpdatedTs = &now
+
+	_, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbRolesColl).
+		InsertOne(ctx, mstore.WithTenantID(ctx, role))
+
+	if isDuplicateKeyError(err) {
+		return store.ErrDuplicateRoleName
+	} else if err != nil {
+		return errors.Wrap(err, "store: failed to insert role")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetRole(ctx context.Context, name string) (*model.Role, error) {
+	var role model.Role
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbRolesColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{DbRoleName: name})).
+		Decode(&role)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		} else {
+			return nil, errors.Wrap(err, "store: failed to fetch role")
+		}
+	}
+
+	return &role, nil
+}
+
+func (db *DataStoreMongo) GetRoles(ctx context.Context) ([]model.Role, error) {
+	return db.findRoles(ctx, bson.M{})
+}
+
+func (db *DataStoreMongo) GetRolesByNames(
+	ctx context.Context,
+	names []string,
+) ([]model.Role, error) {
+	return db.findRoles(ctx, bson.M{DbRoleName: bson.M{"$in": names}})
+}
+
+func (db *DataStoreMongo) findRoles(ctx context.Context, fltr bson.M) ([]model.Role, error) {
+	findOpts := mopts.Find().
+		SetSort(bson.D{{Key: DbRoleName, Value: 1}})
+
+	cur, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbRolesColl).
+		Find(ctx, mstore.WithTenantID(ctx, fltr), findOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to fetch roles")
+	}
+
+	roles := []model.Role{}
+	err = cur.All(ctx, &roles)
+	switch err {
+	case nil, mongo.ErrNoDocuments:
+		return roles, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to decode roles")
+	}
+}
+
+func (db *DataStoreMongo) UpdateRole(
+	ctx context.Context,
+	name string,
+	role *model.RoleUpdate,
+) error {
+	now := time.Now().UTC()
+	role.UpdatedTs = &now
+
+	res, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbRolesColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbRoleName: name}),
+			bson.M{"$set": role},
+		)
+
+	if err != nil {
+		return errors.Wrap(err, "store: failed to update role")
+	} else if res.MatchedCount == 0 {
+		return store.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteRole(ctx context.Context, name string) error {
+	res, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbRolesColl).
+		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.M{DbRoleName: name}))
+
+	if err != nil {
+		return errors.Wrap(err, "store: failed to delete role")
+	} else if res.DeletedCount == 0 {
+		return store.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) SetUserRoles(ctx context.Context, userID string, roles []string) error {
+	res, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUsersColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbID: userID}),
+			bson.M{"$set": bson.M{
+				DbUserRoles:  roles,
+				"updated_ts": time.Now().UTC(),
+			}},
+		)
+
+	if err != nil {
+		return errors.Wrap(err, "store: failed to update user roles")
+	} else if res.MatchedCount == 0 {
+		return store.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) RemoveRoleFromUsers(ctx context.Context, name string) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUsersColl).
+		UpdateMany(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbUserRoles: name}),
+			bson.M{"$pull": bson.M{DbUserRoles: name}},
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to unassign role")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) CreateGroup(ctx context.Context, group *model.Group) error {
+	now := time.Now().UTC()
+
+	group.CreatedTs = &now
+	group.UpdatedTs = &now
+
+	_, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbGroupsColl).
+		InsertOne(ctx, mstore.WithTenantID(ctx, group))
+
+	if isDuplicateKeyError(err) {
+		return store.ErrDuplicateGroupName
+	} else if err != nil {
+		return errors.Wrap(err, "store: failed to insert group")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	var group model.Group
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbGroupsColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{DbID: id})).
+		Decode(&group)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		} else {
+			return nil, errors.Wrap(err, "store: failed to fetch group")
+		}
+	}
+
+	return &group, nil
+}
+
+func (db *DataStoreMongo) GetGroups(ctx context.Context) ([]model.Group, error) {
+	return db.findGroups(ctx, bson.M{})
+}
+
+func (db *DataStoreMongo) GetGroupsByMember(
+	ctx context.Context,
+	userID string,
+) ([]model.Group, error) {
+	return db.findGroups(ctx, bson.M{DbGroupMembers: userID})
+}
+
+func (db *DataStoreMongo) findGroups(ctx context.Context, fltr bson.M) ([]model.Group, error) {
+	findOpts := mopts.Find().
+		SetSort(bson.D{{Key: DbGroupName, Value: 1}})
+
+	cur, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbGroupsColl).
+		Find(ctx, mstore.WithTenantID(ctx, fltr), findOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to fetch groups")
+	}
+
+	groups := []model.Group{}
+	err = cur.All(ctx, &groups)
+	switch err {
+	case nil, mongo.ErrNoDocuments:
+		return groups, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to decode groups")
+	}
+}
+
+// getGroupMembers returns the IDs of the members of any of the groups
+func (db *DataStoreMongo) getGroupMembers(ctx context.Context, ids []string) ([]string, error) {
+	groups, err := db.findGroups(ctx, bson.M{DbID: bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	members := []string{}
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		for _, member := range group.Members {
+			if !seen[member] {
+				seen[member] = true
+				members = append(members, member)
+			}
+		}
+	}
+	return members, nil
+}
+
+func (db *DataStoreMongo) UpdateGroup(
+	ctx context.Context,
+	id string,
+	group *model.GroupUpdate,
+) error {
+	now := time.Now().UTC()
+	group.UpdatedTs = &now
+
+	res, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbGroupsColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbID: id}),
+			bson.M{"$set": group},
+		)
+
+	if isDuplicateKeyError(err) {
+		return store.ErrDuplicateGroupName
+	} else if err != nil {
+		return errors.Wrap(err, "store: failed to update group")
+	} else if res.MatchedCount == 0 {
+		return store.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteGroup(ctx context.Context, id string) error {
+	res, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbGroupsColl).
+		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.M{DbID: id}))
+
+	if err != nil {
+		return errors.Wrap(err, "store: failed to delete group")
+	} else if res.DeletedCount == 0 {
+		return store.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) AddGroupMember(ctx context.Context, id, userID string) error {
+	return db.updateGroupMembers(ctx, id, bson.M{
+		"$addToSet": bson.M{DbGroupMembers: userID},
+	})
+}
+
+func (db *DataStoreMongo) RemoveGroupMember(ctx context.Context, id, userID string) error {
+	return db.updateGroupMembers(ctx, id, bson.M{
+		"$pull": bson.M{DbGroupMembers: userID},
+	})
+}
+
+func (db *DataStoreMongo) updateGroupMembers(ctx context.Context, id string, update bson.M) error {
+	update["$set"] = bson.M{"updated_ts": time.Now().UTC()}
+	res, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbGroupsColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbID: id}),
+			update,
+		)
+
+	if err != nil {
+		return errors.Wrap(err, "store: failed to update group members")
+	} else if res.MatchedCount == 0 {
+		return store.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) RemoveUserFromGroups(ctx context.Context, userID string) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbGroupsColl).
+		UpdateMany(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbGroupMembers: userID}),
+			bson.M{"$pull": bson.M{DbGroupMembers: userID}},
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to remove user from groups")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) RemoveRoleFromGroups(ctx context.Context, name string) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbGroupsColl).
+		UpdateMany(ctx,
+			mstore.WithTenantID(ctx, bson.M{DbGroupRoles: name}),
+			bson.M{"$pull": bson.M{DbGroupRoles: name}},
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to unassign role from groups")
+	}
+	return nil
+}
+
+// intersect returns the items of a which are also in b
+func intersect(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, item := range b {
+		in[item] = true
+	}
+	ret := []string{}
+	for _, item := range a {
+		if in[item] {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+func (db *DataStoreMongo) CreateElevation(
+	ctx context.Context,
+	elevation *model.Elevation,
+) error {
+	_, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbElevationsColl).
+		InsertOne(ctx, mstore.WithTenantID(ctx, elevation))
+	if err != nil {
+		return errors.Wrap(err, "store: failed to insert elevation")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetElevation(ctx context.Context, id string) (*model.Elevation, error) {
+	var elevation model.Elevation
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbElevationsColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{DbID: id})).
+		Decode(&elevation)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		} else {
+			return nil, errors.Wrap(err, "store: failed to fetch elevation")
+		}
+	}
+
+	return &elevation, nil
+}
+
+func (db *DataStoreMongo) GetElevations(
+	ctx context.Context,
+	userID string,
+) ([]model.Elevation, error) {
+	fltr := bson.M{}
+	if userID != "" {
+		fltr[DbElevationUserID] = userID
+	}
+	return db.findElevations(ctx, fltr)
+}
+
+func (db *DataStoreMongo) GetActiveElevations(
+	ctx context.Context,
+	userID string,
+) ([]model.Elevation, error) {
+	return db.findElevations(ctx, bson.M{
+		DbElevationUserID:    userID,
+		DbElevationStatus:    model.ElevationStatusActive,
+		DbElevationExpiresTs: bson.M{"$gt": time.Now().UTC()},
+	})
+}
+
+func (db *DataStoreMongo) findElevations(
+	ctx context.Context,
+	fltr bson.M,
+) ([]model.Elevation, error) {
+	findOpts := mopts.Find().
+		SetSort(bson.D{{Key: DbElevationCreatedTs, Value: -1}})
+
+	cur, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbElevationsColl).
+		Find(ctx, mstore.WithTenantID(ctx, fltr), findOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to fetch elevations")
+	}
+
+	elevations := []model.Elevation{}
+	err = cur.All(ctx, &elevations)
+	switch err {
+	case nil, mongo.ErrNoDocuments:
+		return elevations, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to decode elevations")
+	}
+}
+
+func (db *DataStoreMongo) ReviewElevation(
+	ctx context.Context,
+	id string,
+	review *model.ElevationReview,
+) error {
+	res, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbElevationsColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{
+				DbID:              id,
+				DbElevationStatus: model.ElevationStatusPending,
+			}),
+			bson.M{"$set": review},
+		)
+
+	if err != nil {
+		return errors.Wrap(err, "store: failed to update elevation")
+	} else if res.MatchedCount == 0 {
+		return store.ErrElevationNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) CreateApproval(ctx context.Context, approval *model.Approval) error {
+	_, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbApprovalsColl).
+		InsertOne(ctx, mstore.WithTenantID(ctx, approval))
+	if err != nil {
+		return errors.Wrap(err, "store: failed to insert approval")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetApproval(ctx context.Context, id string) (*model.Approval, error) {
+	var approval model.Approval
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbApprovalsColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{DbID: id})).
+		Decode(&approval)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		} else {
+			return nil, errors.Wrap(err, "store: failed to fetch approval")
+		}
+	}
+
+	return &approval, nil
+}
+
+func (db *DataStoreMongo) GetApprovals(ctx context.Context) ([]model.Approval, error) {
+	findOpts := mopts.Find().
+		SetSort(bson.D{{Key: DbApprovalCreatedTs, Value: -1}})
+
+	cur, err := db.client.
+		Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbApprovalsColl).
+		Find(ctx, mstore.WithTenantID(ctx, bson.M{}), findOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to fetch approvals")
+	}
+
+	approvals := []model.Approval{}
+	err = cur.All(ctx, &approvals)
+	switch err {
+	case nil, mongo.ErrNoDocuments:
+		return approvals, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to decode approvals")
+	}
+}
+
+func (db *DataStoreMongo) UpdateApprovalStatus(
+	ctx context.Context,
+	id string,
+	status string,
+	review *model.ApprovalReview,
+) error {
+	res, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbApprovalsColl).
+		UpdateOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{
+				DbID:             id,
+				DbApprovalStatus: status,
+			}),
+			bson.M{"$set": review},
+		)
+
+	if err != nil {
+		return errors.Wrap(err, "store: failed to update approval")
+	} else if res.MatchedCount == 0 {
+		return store.ErrApprovalNotFound
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetApprovalSettings(
+	ctx context.Context,
+) (*model.ApprovalSettings, error) {
+	var settings model.ApprovalSettings
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbApprovalSettingsColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{})).
+		Decode(&settings)
+
+	switch err {
+	case nil:
+		return &settings, nil
+	case mongo.ErrNoDocuments:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to fetch approval settings")
+	}
+}
+
+func (db *DataStoreMongo) SaveApprovalSettings(
+	ctx context.Context,
+	settings *model.ApprovalSettings,
+) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbApprovalSettingsColl).
+		ReplaceOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{}),
+			mstore.WithTenantID(ctx, settings),
+			mopts.Replace().SetUpsert(true),
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to save approval settings")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetSAMLConfig(ctx context.Context) (*model.SAMLConfig, error) {
+	var config model.SAMLConfig
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbSAMLConfigColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{})).
+		Decode(&config)
+
+	switch err {
+	case nil:
+		return &config, nil
+	case mongo.ErrNoDocuments:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to fetch SAML configuration")
+	}
+}
+
+func (db *DataStoreMongo) SaveSAMLConfig(ctx context.Context, config *model.SAMLConfig) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbSAMLConfigColl).
+		ReplaceOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{}),
+			mstore.WithTenantID(ctx, config),
+			mopts.Replace().SetUpsert(true),
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to save SAML configuration")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteSAMLConfig(ctx context.Context) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbSAMLConfigColl).
+		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.M{}))
+	if err != nil {
+		return errors.Wrap(err, "store: failed to delete SAML configuration")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) SaveSAMLAssertionID(
+	ctx context.Context,
+	id string,
+	expiresAt time.Time,
+) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbSAMLAssertionsColl).
+		InsertOne(ctx, mstore.WithTenantID(ctx, bson.D{
+			{Key: DbSAMLAssertionID, Value: id},
+			{Key: DbSAMLAssertionExpiresAt, Value: expiresAt},
+		}))
+	if isDuplicateKeyError(err) {
+		return store.ErrSAMLAssertionReplayed
+	} else if err != nil {
+		return errors.Wrap(err, "store: failed to save SAML assertion ID")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetOIDCConfig(ctx context.Context) (*model.OIDCConfig, error) {
+	var config model.OIDCConfig
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbOIDCConfigColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{})).
+		Decode(&config)
+
+	switch err {
+	case nil:
+		return &config, nil
+	case mongo.ErrNoDocuments:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to fetch OIDC configuration")
+	}
+}
+
+func (db *DataStoreMongo) SaveOIDCConfig(ctx context.Context, config *model.OIDCConfig) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbOIDCConfigColl).
+		ReplaceOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{}),
+			mstore.WithTenantID(ctx, config),
+			mopts.Replace().SetUpsert(true),
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to save OIDC configuration")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteOIDCConfig(ctx context.Context) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbOIDCConfigColl).
+		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.M{}))
+	if err != nil {
+		return errors.Wrap(err, "store: failed to delete OIDC configuration")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetUserAttributeSchema(
+	ctx context.Context,
+) (*model.UserAttributeSchema, error) {
+	var schema model.UserAttributeSchema
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUserAttributesColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{})).
+		Decode(&schema)
+
+	switch err {
+	case nil:
+		return &schema, nil
+	case mongo.ErrNoDocuments:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to fetch user attribute schema")
+	}
+}
+
+func (db *DataStoreMongo) SaveUserAttributeSchema(
+	ctx context.Context,
+	schema *model.UserAttributeSchema,
+) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbUserAttributesColl).
+		ReplaceOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{}),
+			mstore.WithTenantID(ctx, schema),
+			mopts.Replace().SetUpsert(true),
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to save user attribute schema")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) GetSCIMToken(ctx context.Context) (*model.SCIMToken, error) {
+	var token model.SCIMToken
+
+	err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbSCIMTokensColl).
+		FindOne(ctx, mstore.WithTenantID(ctx, bson.M{})).
+		Decode(&token)
+
+	switch err {
+	case nil:
+		token.TenantID = ""
+		return &token, nil
+	case mongo.ErrNoDocuments:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to fetch SCIM token")
+	}
+}
+
+func (db *DataStoreMongo) GetSCIMTokenByHash(
+	ctx context.Context,
+	hash string,
+) (*model.SCIMToken, error) {
+	var token model.SCIMToken
+
+	// the hash is globally unique: don't filter by tenant, the
+	// caller is not authenticated yet
+	err := db.client.Database(DbName).
+		Collection(DbSCIMTokensColl).
+		FindOne(ctx, bson.M{DbSCIMTokenHash: hash}).
+		Decode(&token)
+
+	switch err {
+	case nil:
+		return &token, nil
+	case mongo.ErrNoDocuments:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to fetch SCIM token")
+	}
+}
+
+func (db *DataStoreMongo) SaveSCIMToken(ctx context.Context, token *model.SCIMToken) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbSCIMTokensColl).
+		ReplaceOne(ctx,
+			mstore.WithTenantID(ctx, bson.M{}),
+			mstore.WithTenantID(ctx, token),
+			mopts.Replace().SetUpsert(true),
+		)
+	if err != nil {
+		return errors.Wrap(err, "store: failed to save SCIM token")
+	}
+
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteSCIMToken(ctx context.Context) error {
+	_, err := db.client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbSCIMTokensColl).
+		DeleteOne(ctx, mstore.WithTenantID(ctx, bson.M{}))
+	if err != nil {
+		return errors.Wrap(err, "store: failed to delete SCIM token")
+	}
+
+	return nil
+}
+
+// WatchInvalidations opens a change stream on the tokens, users, roles,
+// groups and elevations of all the tenants; change streams require a
+// replica set.
+func (db *DataStoreMongo) WatchInvalidations(
+	ctx context.Context,
+) (store.InvalidationStream, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"ns.db": bson.M{"$regex": "^" + regexp.QuoteMeta(DbName) + "(-.+)?$"},
+		"$or": bson.A{
+			bson.M{
+				"ns.coll":       DbTokensColl,
+				"operationType": "delete",
+			},
+			bson.M{
+				"ns.coll":       DbUsersColl,
+				"operationType": bson.M{"$in": bson.A{"delete", "replace"}},
+			},
+			bson.M{
+				"ns.coll":       DbUsersColl,
+				"operationType": "update",
+				"updateDescription.updatedFields." + DbUserPass: bson.M{
+					"$exists": true,
+				},
+			},
+			bson.M{
+				"ns.coll":       DbUsersColl,
+				"operationType": "update",
+				"updateDescription.updatedFields." + DbUserStatus: bson.M{
+					"$exists": true,
+				},
+			},
+			bson.M{
+				"ns.coll":       DbUsersColl,
+				"operationType": "update",
+				"updateDescription.updatedFields." + DbUserDeletedTs: bson.M{
+					"$exists": true,
+				},
+			},
+			bson.M{
+				"ns.coll":       DbUsersColl,
+				"operationType": "update",
+				"updateDescription.updatedFields." + DbUserRoles: bson.M{
+					"$exists": true,
+				},
+			},
+			bson.M{
+				"ns.coll": bson.M{"$in": bson.A{DbRolesColl, DbGroupsColl}},
+				"operationType": bson.M{
+					"$in": bson.A{"insert", "update", "replace", "delete"},
+				},
+			},
+			bson.M{
+				"ns.coll": DbElevationsColl,
+				"operationType": bson.M{
+					"$in": bson.A{"insert", "update", "replace"},
+				},
+			},
+		},
+	}}}}
+	// the tenant of the changed roles and groups, and the user of the
+	// changed elevations, are looked up in the full document
+	cs, err := db.client.Watch(ctx, pipeline,
+		mopts.ChangeStream().SetFullDocument(mopts.UpdateLookup))
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to watch the changes")
+	}
+	return &invalidationStream{cs: cs}, nil
+}
+
+type invalidationStream struct {
+	cs *mongo.ChangeStream
+}
+
+func (s *invalidationStream) Next(ctx context.Context) (*store.Invalidation, error) {
+	if !s.cs.Next(ctx) {
+		err := s.cs.Err()
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = errors.New("change stream closed")
+		}
+		return nil, errors.Wrap(err, "store: failed to watch the changes")
+	}
+	var event struct {
+		Namespace struct {
+			Collection string `bson:"coll"`
+		} `bson:"ns"`
+		DocumentKey struct {
+			ID oid.ObjectID `bson:"_id"`
+		} `bson:"documentKey"`
+		// missing for deletions, or if deleted since the update
+		FullDocument *struct {
+			TenantID string `bson:"tenant_id"`
+			UserID   string `bson:"user_id"`
+		} `bson:"fullDocument"`
+	}
+	if err := s.cs.Decode(&event); err != nil {
+		return nil, errors.Wrap(err, "store: failed to decode the change")
+	}
+	switch event.Namespace.Collection {
+	case DbTokensColl:
+		return &store.Invalidation{TokenID: event.DocumentKey.ID.String()}, nil
+	case DbUsersColl:
+		return &store.Invalidation{UserID: event.DocumentKey.ID.String()}, nil
+	case DbElevationsColl:
+		if event.FullDocument != nil {
+			return &store.Invalidation{UserID: event.FullDocument.UserID}, nil
+		}
+	default:
+		if event.FullDocument != nil && event.FullDocument.TenantID != "" {
+			return &store.Invalidation{TenantID: event.FullDocument.TenantID}, nil
+		}
+	}
+	return &store.Invalidation{All: true}, nil
+}
+
+func (s *invalidationStream) Close(ctx context.Context) error {
+	return s.cs.Close(ctx)
+}
+
+func (db *DataStoreMongo) GetRevokedTokens(
+	ctx context.Context,
+	since int64,
+) ([]model.RevokedToken, error) {
+	cur, err := db.client.Database(DbName).
+		Collection(DbRevokedTokensColl).
+		Find(ctx,
+			bson.M{
+				DbRevokedTokenVersion:   bson.M{"$gt": since},
+				DbRevokedTokenExpiresAt: bson.M{"$gt": time.Now()},
+			},
+			mopts.Find().SetSort(bson.D{{Key: DbRevokedTokenVersion, Value: 1}}),
+		)
+	if err != nil {
+		return nil, errors.Wrap(err, "store: failed to fetch revoked tokens")
+	}
+
+	revoked := []model.RevokedToken{}
+	if err = cur.All(ctx, &revoked); err != nil {
+		return nil, errors.Wrap(err, "store: failed to decode revoked tokens")
+	}
+	return revoked, nil
+}
+
+func (db *DataStoreMongo) GetRevocationVersion(
+	ctx context.Context,
+) (*model.RevocationVersion, error) {
+	var ver model.RevocationVersion
+	err := db.client.Database(DbName).
+		Collection(DbCountersColl).
+		FindOne(ctx, bson.M{DbID: DbCounterRevocations}).
+		Decode(&ver)
+	switch err {
+	case nil:
+		return &ver, nil
+	case mongo.ErrNoDocuments:
+		return nil, nil
+	default:
+		return nil, errors.Wrap(err, "store: failed to fetch revocation version")
+	}
+}
@@ -2517,8 +2517,9 @@ func TestMongoGetPersonalAccessTokens(t *testing.T) {
 			inTokens: tokens,
 			outTokens: []model.PersonalAccessToken{
 				{
-					ID:   tokens[1].ID,
-					Name: tokens[1].TokenName,
+					ID:    tokens[1].ID,
+					Name:  tokens[1].TokenName,
+					Scope: tokens[1].Scope,
 				},
 			},
 		},
@@ -2527,8 +2528,9 @@ func TestMongoGetPersonalAccessTokens(t *testing.T) {
 			inTokens: tokens,
 			outTokens: []model.PersonalAccessToken{
 				{
-					ID:   tokens[1].ID,
-					Name: tokens[1].TokenName,
+					ID:    tokens[1].ID,
+					Name:  tokens[1].TokenName,
+					Scope: tokens[1].Scope,
 				},
 			},
 		},
@@ -2775,8 +2777,9 @@ func TestMongoUpdateTokenLastUsed(t *testing.T) {
 			inTokens: tokens,
 			outTokens: []model.PersonalAccessToken{
 				{
-					ID:   tokens[1].ID,
-					Name: tokens[1].TokenName,
+					ID:    tokens[1].ID,
+					Name:  tokens[1].TokenName,
+					Scope: tokens[1].Scope,
 				},
 			},
 		},
@@ -2785,8 +2788,9 @@ func TestMongoUpdateTokenLastUsed(t *testing.T) {
 			inTokens: tokens,
 			outTokens: []model.PersonalAccessToken{
 				{
-					ID:   tokens[1].ID,
-					Name: tokens[1].TokenName,
+					ID:    tokens[1].ID,
+					Name:  tokens[1].TokenName,
+					Scope: tokens[1].Scope,
 				},
 			},
 		},
@@ -2834,3 +2838,1473 @@ func TestMongoUpdateTokenLastUsed(t *testing.T) {
 		})
 	}
 }
+
+func TestMongoServiceAccounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			client := db.Client()
+			ds, err := NewDataStoreMongoWithClient(client)
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			sa := &model.ServiceAccount{
+				ID:   "sa-1",
+				Name: "ci",
+				Secrets: []model.ServiceAccountSecret{
+					{Hash: "hash", CreatedTs: time.Now()},
+				},
+			}
+			err = ds.CreateServiceAccount(ctx, sa)
+			assert.NoError(t, err)
+			err = ds.CreateServiceAccount(ctx, &model.ServiceAccount{
+				ID:   "sa-2",
+				Name: "ci",
+			})
+			assert.Equal(t, store.ErrDuplicateServiceAccountName, err)
+			err = ds.CreateServiceAccount(ctx, &model.ServiceAccount{
+				ID:   "sa-2",
+				Name: "backup",
+			})
+			assert.NoError(t, err)
+
+			// the accounts of other tenants are not visible
+			otherCtx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "tenant-2"})
+			other, err := ds.GetServiceAccount(otherCtx, "sa-1")
+			assert.NoError(t, err)
+			assert.Nil(t, other)
+
+			dbSA, err := ds.GetServiceAccount(ctx, "sa-1")
+			assert.NoError(t, err)
+			if assert.NotNil(t, dbSA) {
+				assert.Equal(t, "ci", dbSA.Name)
+				assert.Len(t, dbSA.Secrets, 1)
+			}
+
+			// listing does not expose the secrets
+			dbSAs, err := ds.GetServiceAccounts(ctx)
+			assert.NoError(t, err)
+			if assert.Len(t, dbSAs, 2) {
+				assert.Equal(t, "backup", dbSAs[0].Name)
+				assert.Equal(t, "ci", dbSAs[1].Name)
+				assert.Empty(t, dbSAs[1].Secrets)
+			}
+
+			description := "pipeline"
+			err = ds.UpdateServiceAccount(ctx, "sa-1",
+				&model.ServiceAccountUpdate{Description: &description})
+			assert.NoError(t, err)
+			name := "backup"
+			err = ds.UpdateServiceAccount(ctx, "sa-1",
+				&model.ServiceAccountUpdate{Name: &name})
+			assert.Equal(t, store.ErrDuplicateServiceAccountName, err)
+			err = ds.UpdateServiceAccount(ctx, "sa-3",
+				&model.ServiceAccountUpdate{Description: &description})
+			assert.Equal(t, store.ErrServiceAccountNotFound, err)
+
+			dbSA, err = ds.GetServiceAccount(ctx, "sa-1")
+			assert.NoError(t, err)
+			if assert.NotNil(t, dbSA) {
+				assert.Equal(t, "pipeline", dbSA.Description)
+			}
+
+			// the client ID lookup ignores the tenant in the context
+			dbSA, err = ds.GetServiceAccountByClientID(otherCtx, "sa-1")
+			assert.NoError(t, err)
+			if assert.NotNil(t, dbSA) {
+				assert.Equal(t, tc.tenant, dbSA.TenantID)
+			}
+
+			expires := time.Now().Add(time.Hour).Round(time.Millisecond).UTC()
+			err = ds.SetServiceAccountSecrets(ctx, "sa-1",
+				[]model.ServiceAccountSecret{
+					{Hash: "hash", ExpiresTs: &expires},
+					{Hash: "hash-2"},
+				})
+			assert.NoError(t, err)
+			dbSA, err = ds.GetServiceAccount(ctx, "sa-1")
+			assert.NoError(t, err)
+			if assert.NotNil(t, dbSA) && assert.Len(t, dbSA.Secrets, 2) {
+				assert.Equal(t, "hash-2", dbSA.Secrets[1].Hash)
+				assert.Equal(t, expires, dbSA.Secrets[0].ExpiresTs.UTC())
+			}
+			err = ds.SetServiceAccountSecrets(ctx, "sa-3", nil)
+			assert.Equal(t, store.ErrServiceAccountNotFound, err)
+
+			err = ds.DeleteServiceAccount(ctx, "sa-1")
+			assert.NoError(t, err)
+			err = ds.DeleteServiceAccount(ctx, "sa-1")
+			assert.Equal(t, store.ErrServiceAccountNotFound, err)
+		})
+	}
+}
+
+func TestMongoRoles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			client := db.Client()
+			ds, err := NewDataStoreMongoWithClient(client)
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			permissions := []model.Permission{
+				{Resource: "deviceconnect:*", Methods: []string{model.MethodAny}},
+			}
+			err = ds.CreateRole(ctx, &model.Role{
+				Name:        "device-operator",
+				Permissions: permissions,
+			})
+			assert.NoError(t, err)
+			err = ds.CreateRole(ctx, &model.Role{
+				Name:        "device-operator",
+				Permissions: permissions,
+			})
+			assert.Equal(t, store.ErrDuplicateRoleName, err)
+			err = ds.CreateRole(ctx, &model.Role{
+				Name:        "auditor",
+				Permissions: permissions,
+			})
+			assert.NoError(t, err)
+
+			// the roles of other tenants are not visible
+			otherCtx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "tenant-2"})
+			other, err := ds.GetRole(otherCtx, "device-operator")
+			assert.NoError(t, err)
+			assert.Nil(t, other)
+
+			role, err := ds.GetRole(ctx, "device-operator")
+			assert.NoError(t, err)
+			if assert.NotNil(t, role) {
+				assert.Equal(t, permissions, role.Permissions)
+			}
+
+			roles, err := ds.GetRoles(ctx)
+			assert.NoError(t, err)
+			if assert.Len(t, roles, 2) {
+				assert.Equal(t, "auditor", roles[0].Name)
+				assert.Equal(t, "device-operator", roles[1].Name)
+			}
+
+			roles, err = ds.GetRolesByNames(ctx, []string{"device-operator", "foo"})
+			assert.NoError(t, err)
+			if assert.Len(t, roles, 1) {
+				assert.Equal(t, "device-operator", roles[0].Name)
+			}
+
+			description := "remote terminal"
+			err = ds.UpdateRole(ctx, "device-operator",
+				&model.RoleUpdate{Description: &description})
+			assert.NoError(t, err)
+			err = ds.UpdateRole(ctx, "foo",
+				&model.RoleUpdate{Description: &description})
+			assert.Equal(t, store.ErrRoleNotFound, err)
+			role, err = ds.GetRole(ctx, "device-operator")
+			assert.NoError(t, err)
+			if assert.NotNil(t, role) {
+				assert.Equal(t, description, role.Description)
+			}
+
+			user := &model.User{
+				ID:       "1",
+				Email:    "foo@bar.com",
+				Password: "correcthorsebatterystaple",
+				Roles:    []string{model.RoleReadOnly},
+			}
+			err = ds.CreateUser(ctx, user)
+			assert.NoError(t, err)
+			err = ds.SetUserRoles(ctx, "1",
+				[]string{model.RoleReadOnly, "device-operator"})
+			assert.NoError(t, err)
+			err = ds.SetUserRoles(ctx, "2", []string{model.RoleReadOnly})
+			assert.Equal(t, store.ErrUserNotFound, err)
+
+			err = ds.DeleteRole(ctx, "device-operator")
+			assert.NoError(t, err)
+			err = ds.DeleteRole(ctx, "device-operator")
+			assert.Equal(t, store.ErrRoleNotFound, err)
+			err = ds.RemoveRoleFromUsers(ctx, "device-operator")
+			assert.NoError(t, err)
+
+			dbUser, err := ds.GetUserById(ctx, "1")
+			assert.NoError(t, err)
+			if assert.NotNil(t, dbUser) {
+				assert.Equal(t, []string{model.RoleReadOnly}, dbUser.Roles)
+			}
+		})
+	}
+}
+
+func TestMongoGroups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			client := db.Client()
+			ds, err := NewDataStoreMongoWithClient(client)
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			for _, id := range []string{"1", "2", "3"} {
+				err = ds.CreateUser(ctx, &model.User{
+					ID:       id,
+					Email:    model.Email(id + "@bar.com"),
+					Password: "correcthorsebatterystaple",
+				})
+				assert.NoError(t, err)
+			}
+
+			err = ds.CreateGroup(ctx, &model.Group{
+				ID:    "g1",
+				Name:  "operators",
+				Roles: []string{model.RoleReadOnly, "device-operator"},
+			})
+			assert.NoError(t, err)
+			err = ds.CreateGroup(ctx, &model.Group{
+				ID:   "g2",
+				Name: "operators",
+			})
+			assert.Equal(t, store.ErrDuplicateGroupName, err)
+			err = ds.CreateGroup(ctx, &model.Group{
+				ID:    "g2",
+				Name:  "admins",
+				Roles: []string{model.RoleAdmin},
+			})
+			assert.NoError(t, err)
+
+			// the groups of other tenants are not visible
+			otherCtx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "tenant-2"})
+			other, err := ds.GetGroup(otherCtx, "g1")
+			assert.NoError(t, err)
+			assert.Nil(t, other)
+
+			groups, err := ds.GetGroups(ctx)
+			assert.NoError(t, err)
+			if assert.Len(t, groups, 2) {
+				assert.Equal(t, "admins", groups[0].Name)
+				assert.Equal(t, "operators", groups[1].Name)
+			}
+
+			name := "admins"
+			err = ds.UpdateGroup(ctx, "g1", &model.GroupUpdate{Name: &name})
+			assert.Equal(t, store.ErrDuplicateGroupName, err)
+			name = "ops"
+			err = ds.UpdateGroup(ctx, "g1", &model.GroupUpdate{Name: &name})
+			assert.NoError(t, err)
+			err = ds.UpdateGroup(ctx, "foo", &model.GroupUpdate{Name: &name})
+			assert.Equal(t, store.ErrGroupNotFound, err)
+
+			assert.NoError(t, ds.AddGroupMember(ctx, "g1", "1"))
+			assert.NoError(t, ds.AddGroupMember(ctx, "g1", "1"))
+			assert.NoError(t, ds.AddGroupMember(ctx, "g1", "2"))
+			assert.NoError(t, ds.AddGroupMember(ctx, "g2", "2"))
+			assert.Equal(t, store.ErrGroupNotFound, ds.AddGroupMember(ctx, "foo", "1"))
+
+			group, err := ds.GetGroup(ctx, "g1")
+			assert.NoError(t, err)
+			if assert.NotNil(t, group) {
+				assert.Equal(t, "ops", group.Name)
+				assert.Equal(t, []string{"1", "2"}, group.Members)
+			}
+
+			groups, err = ds.GetGroupsByMember(ctx, "2")
+			assert.NoError(t, err)
+			assert.Len(t, groups, 2)
+
+			users, err := ds.GetUsers(ctx, model.UserFilter{Group: []string{"g1"}})
+			assert.NoError(t, err)
+			assert.Len(t, users, 2)
+			users, err = ds.GetUsers(ctx, model.UserFilter{
+				Group: []string{"g1"},
+				ID:    []string{"2", "3"},
+			})
+			assert.NoError(t, err)
+			if assert.Len(t, users, 1) {
+				assert.Equal(t, "2", users[0].ID)
+			}
+			users, err = ds.GetUsers(ctx, model.UserFilter{Group: []string{"foo"}})
+			assert.NoError(t, err)
+			assert.Len(t, users, 0)
+
+			assert.NoError(t, ds.RemoveGroupMember(ctx, "g1", "1"))
+			assert.NoError(t, ds.RemoveUserFromGroups(ctx, "2"))
+			groups, err = ds.GetGroupsByMember(ctx, "2")
+			assert.NoError(t, err)
+			assert.Len(t, groups, 0)
+
+			assert.NoError(t, ds.RemoveRoleFromGroups(ctx, "device-operator"))
+			group, err = ds.GetGroup(ctx, "g1")
+			assert.NoError(t, err)
+			if assert.NotNil(t, group) {
+				assert.Equal(t, []string{model.RoleReadOnly}, group.Roles)
+				assert.Empty(t, group.Members)
+			}
+
+			assert.NoError(t, ds.DeleteGroup(ctx, "g1"))
+			assert.Equal(t, store.ErrGroupNotFound, ds.DeleteGroup(ctx, "g1"))
+		})
+	}
+}
+
+func TestMongoElevations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			client := db.Client()
+			ds, err := NewDataStoreMongoWithClient(client)
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			earlier := now.Add(-time.Hour)
+			later := now.Add(time.Hour)
+			for _, elevation := range []model.Elevation{{
+				ID:        "e1",
+				UserID:    "1",
+				Role:      model.RoleAdmin,
+				Status:    model.ElevationStatusActive,
+				CreatedTs: &earlier,
+				ExpiresTs: &earlier,
+			}, {
+				ID:        "e2",
+				UserID:    "1",
+				Role:      model.RoleAdmin,
+				Status:    model.ElevationStatusActive,
+				CreatedTs: &now,
+				ExpiresTs: &later,
+			}, {
+				ID:        "e3",
+				UserID:    "2",
+				Role:      model.RoleAdmin,
+				Status:    model.ElevationStatusPending,
+				CreatedTs: &now,
+			}} {
+				elevation := elevation
+				assert.NoError(t, ds.CreateElevation(ctx, &elevation))
+			}
+
+			// the elevations of other tenants are not visible
+			otherCtx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "tenant-2"})
+			elevations, err := ds.GetElevations(otherCtx, "")
+			assert.NoError(t, err)
+			assert.Empty(t, elevations)
+
+			elevations, err = ds.GetElevations(ctx, "")
+			assert.NoError(t, err)
+			assert.Len(t, elevations, 3)
+
+			elevations, err = ds.GetElevations(ctx, "1")
+			assert.NoError(t, err)
+			if assert.Len(t, elevations, 2) {
+				assert.Equal(t, "e2", elevations[0].ID)
+				assert.Equal(t, "e1", elevations[1].ID)
+			}
+
+			// the expired elevation is not active
+			elevations, err = ds.GetActiveElevations(ctx, "1")
+			assert.NoError(t, err)
+			if assert.Len(t, elevations, 1) {
+				assert.Equal(t, "e2", elevations[0].ID)
+			}
+
+			// only pending elevations are reviewed
+			review := &model.ElevationReview{
+				Status:     model.ElevationStatusActive,
+				ReviewedBy: "1",
+				ReviewedTs: &now,
+				ExpiresTs:  &later,
+			}
+			err = ds.ReviewElevation(ctx, "e2", review)
+			assert.Equal(t, store.ErrElevationNotFound, err)
+			err = ds.ReviewElevation(ctx, "e3", review)
+			assert.NoError(t, err)
+			err = ds.ReviewElevation(ctx, "e3", review)
+			assert.Equal(t, store.ErrElevationNotFound, err)
+
+			elevation, err := ds.GetElevation(ctx, "e3")
+			assert.NoError(t, err)
+			if assert.NotNil(t, elevation) {
+				assert.Equal(t, model.ElevationStatusActive, elevation.Status)
+				assert.Equal(t, "1", elevation.ReviewedBy)
+				assert.Equal(t, later, elevation.ExpiresTs.UTC())
+			}
+
+			elevation, err = ds.GetElevation(ctx, "e4")
+			assert.NoError(t, err)
+			assert.Nil(t, elevation)
+		})
+	}
+}
+
+func TestMongoApprovals(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			client := db.Client()
+			ds, err := NewDataStoreMongoWithClient(client)
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			settings, err := ds.GetApprovalSettings(ctx)
+			assert.NoError(t, err)
+			assert.Nil(t, settings)
+			for _, actions := range [][]string{
+				{model.ApprovalActionDeleteUser},
+				{model.ApprovalActionSaveSettings},
+			} {
+				err = ds.SaveApprovalSettings(ctx,
+					&model.ApprovalSettings{Actions: actions})
+				assert.NoError(t, err)
+			}
+			settings, err = ds.GetApprovalSettings(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.ApprovalSettings{
+				Actions: []string{model.ApprovalActionSaveSettings},
+			}, settings)
+
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			later := now.Add(time.Hour)
+			err = ds.CreateApproval(ctx, &model.Approval{
+				ID: "a1",
+				ApprovalRequest: model.ApprovalRequest{
+					Action: model.ApprovalActionSaveSettings,
+					Settings: &model.Settings{
+						ETag:   "etag",
+						Values: model.SettingsValues{"foo": "bar"},
+					},
+					SettingsETag: "old-etag",
+				},
+				RequestedBy: "1",
+				Status:      model.ApprovalStatusPending,
+				CreatedTs:   &now,
+				ExpiresTs:   &later,
+			})
+			assert.NoError(t, err)
+			err = ds.CreateApproval(ctx, &model.Approval{
+				ID: "a2",
+				ApprovalRequest: model.ApprovalRequest{
+					Action: model.ApprovalActionDeleteUser,
+					UserID: "2",
+				},
+				Status:    model.ApprovalStatusPending,
+				CreatedTs: &later,
+				ExpiresTs: &later,
+			})
+			assert.NoError(t, err)
+
+			// the approvals of other tenants are not visible
+			otherCtx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "tenant-2"})
+			approvals, err := ds.GetApprovals(otherCtx)
+			assert.NoError(t, err)
+			assert.Empty(t, approvals)
+
+			approvals, err = ds.GetApprovals(ctx)
+			assert.NoError(t, err)
+			if assert.Len(t, approvals, 2) {
+				assert.Equal(t, "a2", approvals[0].ID)
+				assert.Equal(t, "2", approvals[0].UserID)
+				assert.Equal(t, "a1", approvals[1].ID)
+			}
+
+			review := &model.ApprovalReview{
+				Status:     model.ApprovalStatusApproved,
+				ReviewedBy: "2",
+				ReviewedTs: &now,
+			}
+			err = ds.UpdateApprovalStatus(ctx, "a1",
+				model.ApprovalStatusPending, review)
+			assert.NoError(t, err)
+			err = ds.UpdateApprovalStatus(ctx, "a1",
+				model.ApprovalStatusPending, review)
+			assert.Equal(t, store.ErrApprovalNotFound, err)
+
+			approval, err := ds.GetApproval(ctx, "a1")
+			assert.NoError(t, err)
+			if assert.NotNil(t, approval) {
+				assert.Equal(t, model.ApprovalStatusApproved, approval.Status)
+				assert.Equal(t, "2", approval.ReviewedBy)
+				assert.Equal(t, "old-etag", approval.SettingsETag)
+				if assert.NotNil(t, approval.Settings) {
+					assert.Equal(t, model.SettingsValues{"foo": "bar"},
+						approval.Settings.Values)
+				}
+			}
+
+			approval, err = ds.GetApproval(ctx, "a3")
+			assert.NoError(t, err)
+			assert.Nil(t, approval)
+		})
+	}
+}
+
+func TestMongoSAMLConfig(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			ds, err := NewDataStoreMongoWithClient(db.Client())
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			config, err := ds.GetSAMLConfig(ctx)
+			assert.NoError(t, err)
+			assert.Nil(t, config)
+
+			for _, email := range []string{"mail", "email"} {
+				err = ds.SaveSAMLConfig(ctx, &model.SAMLConfig{
+					IdPMetadata: "<EntityDescriptor/>",
+					AttributeMapping: model.SAMLAttributeMapping{
+						Email: email,
+					},
+				})
+				assert.NoError(t, err)
+			}
+			config, err = ds.GetSAMLConfig(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.SAMLConfig{
+				IdPMetadata: "<EntityDescriptor/>",
+				AttributeMapping: model.SAMLAttributeMapping{
+					Email: "email",
+				},
+			}, config)
+
+			// not visible to other tenants
+			config, err = ds.GetSAMLConfig(identity.WithContext(
+				context.Background(),
+				&identity.Identity{Tenant: "tenant-2"},
+			))
+			assert.NoError(t, err)
+			assert.Nil(t, config)
+
+			assert.NoError(t, ds.DeleteSAMLConfig(ctx))
+			config, err = ds.GetSAMLConfig(ctx)
+			assert.NoError(t, err)
+			assert.Nil(t, config)
+		})
+	}
+}
+
+func TestMongoOIDCConfig(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			ds, err := NewDataStoreMongoWithClient(db.Client())
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			config, err := ds.GetOIDCConfig(ctx)
+			assert.NoError(t, err)
+			assert.Nil(t, config)
+
+			for _, clientID := range []string{"client-1", "client-2"} {
+				err = ds.SaveOIDCConfig(ctx, &model.OIDCConfig{
+					Issuer:   "https://accounts.google.com",
+					ClientID: clientID,
+				})
+				assert.NoError(t, err)
+			}
+			config, err = ds.GetOIDCConfig(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.OIDCConfig{
+				Issuer:   "https://accounts.google.com",
+				ClientID: "client-2",
+			}, config)
+
+			// not visible to other tenants
+			config, err = ds.GetOIDCConfig(identity.WithContext(
+				context.Background(),
+				&identity.Identity{Tenant: "tenant-2"},
+			))
+			assert.NoError(t, err)
+			assert.Nil(t, config)
+
+			assert.NoError(t, ds.DeleteOIDCConfig(ctx))
+			config, err = ds.GetOIDCConfig(ctx)
+			assert.NoError(t, err)
+			assert.Nil(t, config)
+		})
+	}
+}
+
+func TestMongoSaveSAMLAssertionID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	db.Wipe()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant-1",
+	})
+
+	ds, err := NewDataStoreMongoWithClient(db.Client())
+	assert.NoError(t, err)
+	err = ds.Migrate(ctx, DbVersion)
+	assert.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Minute)
+	assert.NoError(t, ds.SaveSAMLAssertionID(ctx, "id-1", expiresAt))
+	assert.Equal(t, store.ErrSAMLAssertionReplayed,
+		ds.SaveSAMLAssertionID(ctx, "id-1", expiresAt))
+	assert.NoError(t, ds.SaveSAMLAssertionID(ctx, "id-2", expiresAt))
+
+	// the IDs are recorded per tenant
+	assert.NoError(t, ds.SaveSAMLAssertionID(identity.WithContext(
+		context.Background(),
+		&identity.Identity{Tenant: "tenant-2"},
+	), "id-1", expiresAt))
+}
+
+func TestMongoSaveClientAssertionID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	db.Wipe()
+
+	ctx := context.Background()
+
+	ds, err := NewDataStoreMongoWithClient(db.Client())
+	assert.NoError(t, err)
+	err = ds.Migrate(ctx, DbVersion)
+	assert.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Minute)
+	assert.NoError(t, ds.SaveClientAssertionID(ctx, "client-1", "id-1", expiresAt))
+	assert.Equal(t, store.ErrClientAssertionReplayed,
+		ds.SaveClientAssertionID(ctx, "client-1", "id-1", expiresAt))
+	assert.NoError(t, ds.SaveClientAssertionID(ctx, "client-1", "id-2", expiresAt))
+
+	// the IDs are recorded per client, whatever the tenant in the context
+	assert.NoError(t, ds.SaveClientAssertionID(ctx, "client-2", "id-1", expiresAt))
+	assert.Equal(t, store.ErrClientAssertionReplayed,
+		ds.SaveClientAssertionID(identity.WithContext(
+			context.Background(),
+			&identity.Identity{Tenant: "tenant-2"},
+		), "client-1", "id-1", expiresAt))
+}
+
+func TestMongoSCIMToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			ds, err := NewDataStoreMongoWithClient(db.Client())
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			token, err := ds.GetSCIMToken(ctx)
+			assert.NoError(t, err)
+			assert.Nil(t, token)
+
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			for _, hash := range []string{"hash-1", "hash-2"} {
+				err = ds.SaveSCIMToken(ctx, &model.SCIMToken{
+					Hash:              hash,
+					ProvisioningRoles: []string{model.RoleReadOnly},
+					CreatedTs:         now,
+				})
+				assert.NoError(t, err)
+			}
+			token, err = ds.GetSCIMToken(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.SCIMToken{
+				Hash:              "hash-2",
+				ProvisioningRoles: []string{model.RoleReadOnly},
+				CreatedTs:         now,
+			}, token)
+
+			// the replaced token is gone
+			token, err = ds.GetSCIMTokenByHash(context.Background(), "hash-1")
+			assert.NoError(t, err)
+			assert.Nil(t, token)
+
+			// looked up without the tenant's identity
+			token, err = ds.GetSCIMTokenByHash(context.Background(), "hash-2")
+			assert.NoError(t, err)
+			if assert.NotNil(t, token) {
+				assert.Equal(t, tc.tenant, token.TenantID)
+			}
+
+			// not visible to other tenants
+			if tc.tenant != "" {
+				token, err = ds.GetSCIMToken(identity.WithContext(
+					context.Background(),
+					&identity.Identity{Tenant: "tenant-2"},
+				))
+				assert.NoError(t, err)
+				assert.Nil(t, token)
+			}
+
+			assert.NoError(t, ds.DeleteSCIMToken(ctx))
+			token, err = ds.GetSCIMToken(ctx)
+			assert.NoError(t, err)
+			assert.Nil(t, token)
+		})
+	}
+}
+
+func TestMongoRevokedTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	testCases := map[string]struct {
+		tenant string
+	}{
+		"ok": {},
+		"ok, tenant": {
+			tenant: "tenant-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			ds, err := NewDataStoreMongoWithClient(db.Client())
+			assert.NoError(t, err)
+			err = ds.Migrate(ctx, DbVersion)
+			assert.NoError(t, err)
+
+			ver, err := ds.GetRevocationVersion(ctx)
+			assert.NoError(t, err)
+			assert.Nil(t, ver)
+
+			now := time.Now().UTC().Truncate(time.Second)
+			userID := oid.NewUUIDv5("user-1")
+			tokens := map[string]time.Time{
+				"token-1": now.Add(time.Hour),
+				"token-2": now.Add(2 * time.Hour),
+				"token-3": now.Add(-time.Hour),
+			}
+			for id, exp := range tokens {
+				err = ds.SaveToken(ctx, &jwt.Token{Claims: jwt.Claims{
+					ID:        oid.NewUUIDv5(id),
+					Subject:   userID,
+					ExpiresAt: jwt.Time{Time: exp},
+				}})
+				assert.NoError(t, err)
+			}
+
+			err = ds.DeleteToken(ctx, userID, oid.NewUUIDv5("token-1"))
+			assert.NoError(t, err)
+			// the expired token is not revoked
+			err = ds.DeleteTokensByUserId(ctx, userID.String())
+			assert.NoError(t, err)
+
+			ver, err = ds.GetRevocationVersion(ctx)
+			assert.NoError(t, err)
+			if assert.NotNil(t, ver) {
+				assert.Equal(t, int64(2), ver.Version)
+				assert.WithinDuration(t, time.Now(), ver.UpdatedAt, time.Minute)
+			}
+
+			revoked, err := ds.GetRevokedTokens(context.Background(), 0)
+			assert.NoError(t, err)
+			if assert.Len(t, revoked, 2) {
+				for i, id := range []string{"token-1", "token-2"} {
+					assert.Equal(t, oid.NewUUIDv5(id), revoked[i].ID)
+					assert.Equal(t, int64(i+1), revoked[i].Version)
+					assert.True(t, tokens[id].Equal(revoked[i].ExpiresAt))
+				}
+			}
+
+			revoked, err = ds.GetRevokedTokens(context.Background(), 1)
+			assert.NoError(t, err)
+			if assert.Len(t, revoked, 1) {
+				assert.Equal(t, oid.NewUUIDv5("token-2"), revoked[0].ID)
+			}
+		})
+	}
+}
+
+func TestMongoGetUsersPagination(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	db.Wipe()
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant-1",
+	})
+	client := db.Client()
+	ds, err := NewDataStoreMongoWithClient(client)
+	assert.NoError(t, err)
+	err = ds.Migrate(ctx, DbVersion)
+	assert.NoError(t, err)
+
+	// the login timestamps of the users 1 and 4 are not set
+	base := time.Now().UTC().Truncate(time.Millisecond)
+	ts := func(d int) *time.Time {
+		t := base.Add(time.Duration(d) * time.Minute)
+		return &t
+	}
+	users := []interface{}{
+		model.User{ID: "1", Email: "d@acme.com", CreatedTs: ts(1), UpdatedTs: ts(1)},
+		model.User{ID: "2", Email: "c@acme.com", CreatedTs: ts(2), UpdatedTs: ts(2),
+			LoginTs: ts(3)},
+		model.User{ID: "3", Email: "b@acme.com", CreatedTs: ts(2), UpdatedTs: ts(2),
+			LoginTs: ts(1)},
+		model.User{ID: "4", Email: "a@acme.com", CreatedTs: ts(4), UpdatedTs: ts(4)},
+		model.User{ID: "5", Email: "e@acme.com", CreatedTs: ts(5), UpdatedTs: ts(5),
+			LoginTs: ts(2)},
+	}
+	_, err = client.Database(DbName).
+		Collection(DbUsersColl).
+		InsertMany(ctx, mstore.ArrayWithTenantID(ctx, users))
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		sort model.UserSort
+
+		ids []string
+	}{
+		"email": {
+			sort: model.UserSort{Field: model.UserSortEmail},
+			ids:  []string{"4", "3", "2", "1", "5"},
+		},
+		"created_ts, descending": {
+			sort: model.UserSort{Field: model.UserSortCreatedTs, Desc: true},
+			ids:  []string{"5", "4", "3", "2", "1"},
+		},
+		"login_ts": {
+			sort: model.UserSort{Field: model.UserSortLoginTs},
+			ids:  []string{"1", "4", "3", "5", "2"},
+		},
+		"login_ts, descending": {
+			sort: model.UserSort{Field: model.UserSortLoginTs, Desc: true},
+			ids:  []string{"2", "5", "3", "4", "1"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sort := tc.sort
+
+			// pages
+			ids := []string{}
+			for skip := int64(0); skip < 5; skip += 2 {
+				page, err := ds.GetUsers(ctx, model.UserFilter{
+					Sort:  &sort,
+					Skip:  skip,
+					Limit: 2,
+				})
+				assert.NoError(t, err)
+				for _, u := range page {
+					ids = append(ids, u.ID)
+				}
+			}
+			assert.Equal(t, tc.ids, ids)
+
+			// cursors
+			ids = []string{}
+			var after *model.UserCursor
+			for i := 0; i < 5; i++ {
+				page, err := ds.GetUsers(ctx, model.UserFilter{
+					Sort:  &sort,
+					After: after,
+					Limit: 1,
+				})
+				assert.NoError(t, err)
+				if !assert.Len(t, page, 1) {
+					break
+				}
+				ids = append(ids, page[0].ID)
+				after = model.NewUserCursor(sort, &page[0])
+			}
+			assert.Equal(t, tc.ids, ids)
+			page, err := ds.GetUsers(ctx, model.UserFilter{
+				Sort:  &sort,
+				After: after,
+			})
+			assert.NoError(t, err)
+			assert.Empty(t, page)
+		})
+	}
+
+	count, err := ds.CountUsers(ctx, model.UserFilter{
+		CreatedBefore: ts(3),
+		Limit:         1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestMongoGetUsersSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	db.Wipe()
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant-1",
+	})
+	client := db.Client()
+	ds, err := NewDataStoreMongoWithClient(client)
+	assert.NoError(t, err)
+	err = ds.Migrate(ctx, DbVersion)
+	assert.NoError(t, err)
+
+	base := time.Now().UTC().Truncate(time.Millisecond)
+	ts := func(d int) *time.Time {
+		t := base.Add(time.Duration(d) * time.Minute)
+		return &t
+	}
+	never, ever := true, false
+	users := []interface{}{
+		model.User{ID: "1", Email: "alice@acme.com"},
+		model.User{ID: "2", Email: "bob@acme.com", LoginTs: ts(1)},
+		model.User{ID: "3", Email: "alice.b@foo.io", LoginTs: ts(2)},
+		model.User{ID: "4", Email: "carol@a.cme.com", LoginTs: ts(3)},
+	}
+	_, err = client.Database(DbName).
+		Collection(DbUsersColl).
+		InsertMany(ctx, mstore.ArrayWithTenantID(ctx, users))
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		fltr model.UserFilter
+
+		ids []string
+	}{
+		"email prefix": {
+			fltr: model.UserFilter{EmailPrefix: "alice"},
+			ids:  []string{"3", "1"},
+		},
+		"email contains, quoted": {
+			fltr: model.UserFilter{EmailContains: "@acme."},
+			ids:  []string{"1", "2"},
+		},
+		"email prefix and contains": {
+			fltr: model.UserFilter{
+				EmailPrefix:   "alice",
+				EmailContains: "acme",
+			},
+			ids: []string{"1"},
+		},
+		"email and contains": {
+			fltr: model.UserFilter{
+				Email:         []model.Email{"bob@acme.com", "alice.b@foo.io"},
+				EmailContains: "acme",
+			},
+			ids: []string{"2"},
+		},
+		"login range": {
+			fltr: model.UserFilter{
+				LoginAfter:  ts(1),
+				LoginBefore: ts(3),
+			},
+			ids: []string{"3"},
+		},
+		"login range, inactive": {
+			fltr: model.UserFilter{
+				LoginAfter:    ts(0),
+				InactiveSince: ts(3),
+			},
+			ids: []string{"3", "2"},
+		},
+		"inactive": {
+			fltr: model.UserFilter{InactiveSince: ts(2)},
+			ids:  []string{"1", "2"},
+		},
+		"never logged in": {
+			fltr: model.UserFilter{NeverLoggedIn: &never},
+			ids:  []string{"1"},
+		},
+		"logged in": {
+			fltr: model.UserFilter{
+				NeverLoggedIn: &ever,
+				EmailPrefix:   "alice",
+			},
+			ids: []string{"3"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ds.GetUsers(ctx, tc.fltr)
+			assert.NoError(t, err)
+			ids := []string{}
+			for _, u := range res {
+				ids = append(ids, u.ID)
+			}
+			assert.Equal(t, tc.ids, ids)
+		})
+	}
+}
+
+func TestMongoUserAttributes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	db.Wipe()
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant-1",
+	})
+	ds, err := NewDataStoreMongoWithClient(db.Client())
+	assert.NoError(t, err)
+	err = ds.Migrate(ctx, DbVersion)
+	assert.NoError(t, err)
+
+	schema, err := ds.GetUserAttributeSchema(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, schema)
+
+	schema = &model.UserAttributeSchema{
+		Attributes: []model.UserAttribute{{
+			Name:       "department",
+			Type:       model.UserAttributeTypeString,
+			Searchable: true,
+		}, {
+			Name: "level",
+			Type: model.UserAttributeTypeNumber,
+		}},
+	}
+	err = ds.SaveUserAttributeSchema(ctx, schema)
+	assert.NoError(t, err)
+	saved, err := ds.GetUserAttributeSchema(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, schema, saved)
+
+	// not visible to other tenants
+	saved, err = ds.GetUserAttributeSchema(identity.WithContext(
+		context.Background(),
+		&identity.Identity{Tenant: "tenant-2"},
+	))
+	assert.NoError(t, err)
+	assert.Nil(t, saved)
+
+	for _, user := range []*model.User{{
+		ID:       "1",
+		Email:    "foo@acme.com",
+		Name:     "Foo",
+		Timezone: "Europe/Oslo",
+		Attributes: model.UserAttributes{
+			"department": "R&D",
+			"level":      float64(2),
+		},
+	}, {
+		ID:         "2",
+		Email:      "bar@acme.com",
+		Attributes: model.UserAttributes{"department": "Sales"},
+	}} {
+		assert.NoError(t, ds.CreateUser(ctx, user))
+	}
+
+	_, err = ds.UpdateUser(ctx, "1", &model.UserUpdate{
+		Locale: "nb-NO",
+		Attributes: model.UserAttributes{
+			"department": "Sales",
+			"level":      nil,
+		},
+	})
+	assert.NoError(t, err)
+	user, err := ds.GetUserById(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Foo", user.Name)
+	assert.Equal(t, "nb-NO", user.Locale)
+	assert.Equal(t, "Europe/Oslo", user.Timezone)
+	assert.Equal(t, model.UserAttributes{"department": "Sales"}, user.Attributes)
+
+	users, err := ds.GetUsers(ctx, model.UserFilter{
+		Attributes: map[string]interface{}{"department": "Sales"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	users, err = ds.GetUsers(ctx, model.UserFilter{
+		Attributes: map[string]interface{}{"department": "R&D"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestMongoSetUserStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	db.Wipe()
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant-1",
+	})
+	ds, err := NewDataStoreMongoWithClient(db.Client())
+	assert.NoError(t, err)
+	err = ds.Migrate(ctx, DbVersion)
+	assert.NoError(t, err)
+
+	for _, user := range []*model.User{
+		{ID: "1", Email: "foo@acme.com"},
+		{ID: "2", Email: "bar@acme.com"},
+	} {
+		assert.NoError(t, ds.CreateUser(ctx, user))
+	}
+
+	err = ds.SetUserStatus(ctx, "1", &model.UserStatusUpdate{
+		Status: model.UserStatusDisabled,
+		Reason: "left the company",
+	})
+	assert.NoError(t, err)
+	user, err := ds.GetUserById(ctx, "1")
+	assert.NoError(t, err)
+	assert.True(t, user.IsDisabled())
+	assert.Equal(t, "left the company", user.StatusReason)
+	assert.NotNil(t, user.StatusTs)
+
+	for status, id := range map[model.UserStatus]string{
+		model.UserStatusActive:   "2",
+		model.UserStatusDisabled: "1",
+	} {
+		users, err := ds.GetUsers(ctx, model.UserFilter{Status: status})
+		assert.NoError(t, err)
+		if assert.Len(t, users, 1) {
+			assert.Equal(t, id, users[0].ID)
+		}
+	}
+
+	err = ds.SetUserStatus(ctx, "1", &model.UserStatusUpdate{
+		Status: model.UserStatusActive,
+	})
+	assert.NoError(t, err)
+	user, err = ds.GetUserById(ctx, "1")
+	assert.NoError(t, err)
+	assert.False(t, user.IsDisabled())
+
+	err = ds.SetUserStatus(ctx, "3", &model.UserStatusUpdate{
+		Status: model.UserStatusDisabled,
+	})
+	assert.Equal(t, store.ErrUserNotFound, err)
+}
+
+func TestMongoSoftDeleteUser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	db.Wipe()
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant-1",
+	})
+	ds, err := NewDataStoreMongoWithClient(db.Client())
+	assert.NoError(t, err)
+	err = ds.Migrate(ctx, DbVersion)
+	assert.NoError(t, err)
+
+	for _, user := range []*model.User{
+		{ID: "1", Email: "foo@acme.com"},
+		{ID: "2", Email: "bar@acme.com"},
+	} {
+		assert.NoError(t, ds.CreateUser(ctx, user))
+	}
+	err = ds.SaveUserSettings(ctx, "1", &model.Settings{
+		ID:     "1",
+		Values: model.SettingsValues{"theme": "dark"},
+	}, "")
+	assert.NoError(t, err)
+
+	err = ds.SoftDeleteUser(ctx, "1")
+	assert.NoError(t, err)
+	err = ds.SoftDeleteUser(ctx, "1")
+	assert.Equal(t, store.ErrUserNotFound, err)
+
+	// the deleted user is hidden, and the email stays reserved
+	users, err := ds.GetUsers(ctx, model.UserFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "2", users[0].ID)
+	}
+	users, err = ds.GetUsers(ctx, model.UserFilter{Deleted: true})
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "1", users[0].ID)
+		assert.True(t, users[0].IsDeleted())
+	}
+	err = ds.CreateUser(ctx, &model.User{ID: "3", Email: "foo@acme.com"})
+	assert.Equal(t, store.ErrDuplicateEmail, err)
+	_, err = ds.UpdateUser(ctx, "1", &model.UserUpdate{Name: "Foo"})
+	assert.Equal(t, store.ErrUserNotFound, err)
+
+	// the tenants with users to purge
+	tenants, err := ds.GetDeletedUserTenants(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tenant-1"}, tenants)
+	tenants, err = ds.GetDeletedUserTenants(ctx, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, tenants)
+	before := time.Now().Add(time.Minute)
+	users, err = ds.GetUsers(ctx, model.UserFilter{DeletedBefore: &before})
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	err = ds.RestoreUser(ctx, "1")
+	assert.NoError(t, err)
+	err = ds.RestoreUser(ctx, "1")
+	assert.Equal(t, store.ErrUserNotFound, err)
+	count, err := ds.CountUsers(ctx, model.UserFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// only the users deleted before the time are purged, once
+	err = ds.PurgeDeletedUser(ctx, "1", before)
+	assert.Equal(t, store.ErrUserNotFound, err)
+	err = ds.SoftDeleteUser(ctx, "1")
+	assert.NoError(t, err)
+	err = ds.PurgeDeletedUser(ctx, "1", time.Now().Add(-time.Minute))
+	assert.Equal(t, store.ErrUserNotFound, err)
+	err = ds.PurgeDeletedUser(ctx, "1", before)
+	assert.NoError(t, err)
+	err = ds.PurgeDeletedUser(ctx, "1", before)
+	assert.Equal(t, store.ErrUserNotFound, err)
+	count, err = ds.CountUsers(ctx, model.UserFilter{Deleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	err = ds.DeleteUserSettings(ctx, "1")
+	assert.NoError(t, err)
+	settings, err := ds.GetUserSettings(ctx, "1")
+	assert.NoError(t, err)
+	assert.Nil(t, settings)
+}
+
+func TestMongoPurgeDeletedUserSingleTenant(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode.")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	ds, err := NewDataStoreMongoWithClient(db.Client())
+	assert.NoError(t, err)
+	err = ds.Migrate(ctx, DbVersion)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ds.CreateUser(ctx, &model.User{ID: "1", Email: "foo@acme.com"}))
+	assert.NoError(t, ds.CreateUser(ctx, &model.User{ID: "2", Email: "bar@acme.com"}))
+	err = ds.SoftDeleteUser(ctx, "1")
+	assert.NoError(t, err)
+
+	tenants, err := ds.GetDeletedUserTenants(ctx, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, tenants)
+
+	// the users without a tenant are purged as those of the empty tenant
+	before := time.Now().Add(time.Minute)
+	tenants, err = ds.GetDeletedUserTenants(ctx, before)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, tenants)
+	users, err := ds.GetUsers(ctx, model.UserFilter{DeletedBefore: &before})
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "1", users[0].ID)
+	}
+	err = ds.PurgeDeletedUser(ctx, "1", before)
+	assert.NoError(t, err)
+
+	tenants, err = ds.GetDeletedUserTenants(ctx, before)
+	assert.NoError(t, err)
+	assert.Empty(t, tenants)
+	count, err := ds.CountUsers(ctx, model.UserFilter{Deleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	count, err = ds.CountUsers(ctx, model.UserFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// the users stored without the tenant ID are reported as well
+	_, err = db.Client().Database(DbName).Collection(DbUsersColl).
+		InsertOne(ctx, bson.D{
+			{Key: DbID, Value: "3"},
+			{Key: DbUserEmail, Value: "baz@acme.com"},
+			{Key: DbUserDeletedTs, Value: time.Now()},
+		})
+	assert.NoError(t, err)
+	tenants, err = ds.GetDeletedUserTenants(ctx, before)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, tenants)
+}
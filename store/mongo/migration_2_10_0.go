@@ -0,0 +1,66 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	mstore "github.com/mendersoftware/go-lib-micro/store/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migration_2_10_0 creates the indexes sorting the users
+type migration_2_10_0 struct {
+	ds     *DataStoreMongo
+	dbName string
+	ctx    context.Context
+}
+
+func (m *migration_2_10_0) Up(from migrate.Version) error {
+	if m.dbName != DbName {
+		return nil
+	}
+
+	indexes := make([]mongo.IndexModel, 0, 3)
+	for field, name := range map[string]string{
+		DbUserCreatedTs: DbTenantUserCreatedIndexName,
+		DbUserUpdatedTs: DbTenantUserUpdatedIndexName,
+		DbUserLoginTs:   DbTenantUserLoginIndexName,
+	} {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: mstore.FieldTenantID, Value: 1},
+				{Key: field, Value: 1},
+				{Key: DbID, Value: 1},
+			},
+			Options: mopts.Index().
+				SetName(name),
+		})
+	}
+
+	ctx := context.Background()
+	_, err := m.ds.client.Database(m.dbName).
+		Collection(DbUsersColl).
+		Indexes().
+		CreateMany(ctx, indexes)
+	return err
+}
+
+func (m *migration_2_10_0) Version() migrate.Version {
+	return migrate.MakeVersion(2, 10, 0)
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMigration_2_14_0(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping TestMigration_2_14_0 in short mode")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	client := db.Client()
+	ds, err := NewDataStoreMongoWithClient(client)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	migrations := []migrate.Migration{
+		&migration_2_14_0{
+			ds:     ds,
+			ctx:    ctx,
+			dbName: DbName,
+		},
+	}
+
+	m := migrate.SimpleMigrator{
+		Client:      client,
+		Db:          DbName,
+		Automigrate: true,
+	}
+	err = m.Apply(ctx, migrate.MakeVersion(2, 14, 0), migrations)
+	assert.NoError(t, err)
+
+	cur, err := client.Database(DbName).
+		Collection(DbSAMLAssertionsColl).
+		Indexes().
+		List(ctx)
+	assert.NoError(t, err)
+
+	var indexes []bson.M
+	assert.NoError(t, cur.All(ctx, &indexes))
+	names := []string{}
+	for _, index := range indexes {
+		names = append(names, index["name"].(string))
+	}
+	assert.Contains(t, names, DbTenantSAMLAssertionIDIndexName)
+	assert.Contains(t, names, DbSAMLAssertionExpirationIndexName)
+}
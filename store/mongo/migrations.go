@@ -28,7 +28,7 @@ import (
 )
 
 const (
-	DbVersion = "2.0.1"
+	DbVersion = "2.15.0"
 	DbName    = "useradm"
 )
 
@@ -71,6 +71,81 @@ func (db *DataStoreMongo) MigrateTenant(ctx context.Context, version string, ten
 			dbName: mstore.DbFromContext(tenantCtx, DbName),
 			ctx:    tenantCtx,
 		},
+		&migration_2_1_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_2_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_3_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_4_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_5_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_6_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_7_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_8_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_9_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_10_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_11_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_12_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_13_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_14_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
+		&migration_2_15_0{
+			ds:     db,
+			dbName: mstore.DbFromContext(tenantCtx, DbName),
+			ctx:    tenantCtx,
+		},
 	}
 
 	err = m.Apply(tenantCtx, *ver, migrations)
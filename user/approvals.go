@@ -0,0 +1,227 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+)
+
+var (
+	ErrApprovalNotFound   = errors.New("approval not found")
+	ErrApprovalNotPending = errors.New("approval is not pending")
+	ErrApprovalExpired    = errors.New("approval expired")
+	ErrApprovalSelfReview = errors.New(
+		"action must be reviewed by another user")
+)
+
+// RequestApproval records the action for the approval of a second admin,
+// if the tenant requires it; returns nil if the action can be carried out
+// right away.
+func (ua *UserAdm) RequestApproval(
+	ctx context.Context,
+	request *model.ApprovalRequest,
+) (*model.Approval, error) {
+	id := identity.FromContext(ctx)
+	if id == nil || !id.IsUser || id.Subject == "" {
+		// the approval needs a requester distinct from the reviewer
+		return nil, errors.New("identity not present in the context")
+	}
+	settings, err := ua.db.GetApprovalSettings(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get approval settings")
+	} else if settings == nil || !settings.Requires(request.Action) {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	expires := now.Add(time.Duration(ua.getConfig().ApprovalExpiration) * time.Second)
+	approval := &model.Approval{
+		ID:              oid.NewUUIDv4().String(),
+		ApprovalRequest: *request,
+		RequestedBy:     id.Subject,
+		Status:          model.ApprovalStatusPending,
+		CreatedTs:       &now,
+		ExpiresTs:       &expires,
+	}
+	if err := ua.db.CreateApproval(ctx, approval); err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to create approval")
+	}
+	return approval, nil
+}
+
+func (ua *UserAdm) GetApprovals(ctx context.Context) ([]model.Approval, error) {
+	approvals, err := ua.db.GetApprovals(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get approvals")
+	}
+	now := time.Now()
+	for i := range approvals {
+		setApprovalExpired(&approvals[i], now)
+	}
+	return approvals, nil
+}
+
+func (ua *UserAdm) GetApproval(ctx context.Context, id string) (*model.Approval, error) {
+	approval, err := ua.db.GetApproval(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get approval")
+	} else if approval == nil {
+		return nil, ErrApprovalNotFound
+	}
+	setApprovalExpired(approval, time.Now())
+	return approval, nil
+}
+
+// ApproveAction approves the pending action and carries it out; if the
+// action fails, the approval is marked as failed.
+func (ua *UserAdm) ApproveAction(ctx context.Context, id string) error {
+	approval, err := ua.reviewAction(ctx, id, model.ApprovalStatusApproved)
+	if err != nil {
+		return err
+	}
+
+	if err = ua.runAction(ctx, &approval.ApprovalRequest); err != nil {
+		review := &model.ApprovalReview{
+			Status: model.ApprovalStatusFailed,
+			Error:  err.Error(),
+		}
+		if err := ua.db.UpdateApprovalStatus(ctx, id,
+			model.ApprovalStatusApproved, review); err != nil {
+			return errors.Wrap(err, "useradm: failed to update approval")
+		}
+		return err
+	}
+	return nil
+}
+
+func (ua *UserAdm) RejectAction(ctx context.Context, id string) error {
+	_, err := ua.reviewAction(ctx, id, model.ApprovalStatusRejected)
+	return err
+}
+
+func (ua *UserAdm) reviewAction(
+	ctx context.Context,
+	id string,
+	status string,
+) (*model.Approval, error) {
+	reviewer := identity.FromContext(ctx)
+	if reviewer == nil || !reviewer.IsUser {
+		return nil, errors.New("identity not present in the context")
+	}
+	approval, err := ua.GetApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch approval.Status {
+	case model.ApprovalStatusPending:
+	case model.ApprovalStatusExpired:
+		return nil, ErrApprovalExpired
+	default:
+		return nil, ErrApprovalNotPending
+	}
+	if approval.RequestedBy == reviewer.Subject {
+		return nil, ErrApprovalSelfReview
+	}
+
+	now := time.Now().UTC()
+	err = ua.db.UpdateApprovalStatus(ctx, id, model.ApprovalStatusPending,
+		&model.ApprovalReview{
+			Status:     status,
+			ReviewedBy: reviewer.Subject,
+			ReviewedTs: &now,
+		})
+	if err == store.ErrApprovalNotFound {
+		// reviewed in the meantime
+		return nil, ErrApprovalNotPending
+	} else if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to review approval")
+	}
+	return approval, nil
+}
+
+// runAction carries out the approved action
+func (ua *UserAdm) runAction(ctx context.Context, request *model.ApprovalRequest) error {
+	switch request.Action {
+	case model.ApprovalActionDeleteUser:
+		return ua.DeleteUser(ctx, request.UserID)
+	case model.ApprovalActionDeleteTokens:
+		var tenantID string
+		if id := identity.FromContext(ctx); id != nil {
+			tenantID = id.Tenant
+		}
+		return ua.DeleteTokens(ctx, tenantID, request.UserID)
+	case model.ApprovalActionSaveSettings:
+		err := ua.db.SaveSettings(ctx, request.Settings, request.SettingsETag)
+		if err != nil {
+			return errors.Wrap(err, "useradm: failed to save settings")
+		}
+		return nil
+	case model.ApprovalActionSaveApprovalSettings:
+		return ua.SaveApprovalSettings(ctx, request.ApprovalSettings)
+	case model.ApprovalActionSaveSAMLConfig:
+		return ua.SaveSAMLConfig(ctx, request.SAMLConfig)
+	case model.ApprovalActionDeleteSAMLConfig:
+		return ua.DeleteSAMLConfig(ctx)
+	case model.ApprovalActionSaveOIDCConfig:
+		return ua.SaveOIDCConfig(ctx, request.OIDCConfig)
+	case model.ApprovalActionDeleteOIDCConfig:
+		return ua.DeleteOIDCConfig(ctx)
+	case model.ApprovalActionCreateSCIMToken:
+		return ua.SaveSCIMToken(ctx, request.SCIMToken)
+	case model.ApprovalActionDeleteSCIMToken:
+		return ua.DeleteSCIMToken(ctx)
+	default:
+		return errors.Errorf("useradm: unknown action: %s", request.Action)
+	}
+}
+
+// GetApprovalSettings returns the actions which need an approval in the
+// tenant; none by default.
+func (ua *UserAdm) GetApprovalSettings(ctx context.Context) (*model.ApprovalSettings, error) {
+	settings, err := ua.db.GetApprovalSettings(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get approval settings")
+	} else if settings == nil {
+		settings = &model.ApprovalSettings{Actions: []string{}}
+	}
+	return settings, nil
+}
+
+func (ua *UserAdm) SaveApprovalSettings(
+	ctx context.Context,
+	settings *model.ApprovalSettings,
+) error {
+	if settings.Actions == nil {
+		settings.Actions = []string{}
+	}
+	if err := ua.db.SaveApprovalSettings(ctx, settings); err != nil {
+		return errors.Wrap(err, "useradm: failed to save approval settings")
+	}
+	return nil
+}
+
+func setApprovalExpired(approval *model.Approval, now time.Time) {
+	if approval.IsExpired(now) {
+		approval.Status = model.ApprovalStatusExpired
+	}
+}
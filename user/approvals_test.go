@@ -0,0 +1,438 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+func TestUserAdmRequestApproval(t *testing.T) {
+	testCases := map[string]struct {
+		identity *identity.Identity
+
+		callSettings  bool
+		dbSettings    *model.ApprovalSettings
+		dbSettingsErr error
+		callDb        bool
+		dbErr         error
+
+		outApproval bool
+		outErr      error
+	}{
+		"ok": {
+			identity:     &identity.Identity{Subject: "1", IsUser: true},
+			callSettings: true,
+			dbSettings: &model.ApprovalSettings{
+				Actions: []string{model.ApprovalActionDeleteUser},
+			},
+			callDb: true,
+
+			outApproval: true,
+		},
+		"ok, not required": {
+			identity:     &identity.Identity{Subject: "1", IsUser: true},
+			callSettings: true,
+			dbSettings: &model.ApprovalSettings{
+				Actions: []string{model.ApprovalActionSaveSettings},
+			},
+		},
+		"ok, no settings": {
+			identity:     &identity.Identity{Subject: "1", IsUser: true},
+			callSettings: true,
+		},
+		"error: no requester": {
+			identity: &identity.Identity{Tenant: "tenant"},
+
+			outErr: errors.New("identity not present in the context"),
+		},
+		"error: get settings": {
+			identity:      &identity.Identity{Subject: "1", IsUser: true},
+			callSettings:  true,
+			dbSettingsErr: errors.New("db error"),
+
+			outErr: errors.New(
+				"useradm: failed to get approval settings: db error"),
+		},
+		"error: db": {
+			identity:     &identity.Identity{Subject: "1", IsUser: true},
+			callSettings: true,
+			dbSettings: &model.ApprovalSettings{
+				Actions: []string{model.ApprovalActionDeleteUser},
+			},
+			callDb: true,
+			dbErr:  errors.New("db error"),
+
+			outErr: errors.New("useradm: failed to create approval: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = identity.WithContext(ctx, tc.identity)
+			}
+			request := &model.ApprovalRequest{
+				Action: model.ApprovalActionDeleteUser,
+				UserID: "2",
+			}
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.callSettings {
+				db.On("GetApprovalSettings", ctx).
+					Return(tc.dbSettings, tc.dbSettingsErr)
+			}
+			if tc.callDb {
+				db.On("CreateApproval", ctx,
+					mock.AnythingOfType("*model.Approval")).
+					Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{ApprovalExpiration: 3600})
+			approval, err := useradm.RequestApproval(ctx, request)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, approval)
+			} else if !tc.outApproval {
+				assert.NoError(t, err)
+				assert.Nil(t, approval)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, approval.ID)
+				assert.Equal(t, *request, approval.ApprovalRequest)
+				assert.Equal(t, "1", approval.RequestedBy)
+				assert.Equal(t, model.ApprovalStatusPending, approval.Status)
+				assert.WithinDuration(t, time.Now().Add(time.Hour),
+					*approval.ExpiresTs, time.Second)
+			}
+		})
+	}
+}
+
+func TestUserAdmGetApprovals(t *testing.T) {
+	ctx := context.Background()
+	earlier := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Minute)
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetApprovals", ctx).Return([]model.Approval{
+		{ID: "a1", Status: model.ApprovalStatusPending, ExpiresTs: &later},
+		{ID: "a2", Status: model.ApprovalStatusPending, ExpiresTs: &earlier},
+		{ID: "a3", Status: model.ApprovalStatusApproved, ExpiresTs: &earlier},
+	}, nil).Once()
+	db.On("GetApprovals", ctx).Return(nil, errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	approvals, err := useradm.GetApprovals(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, approvals, 3) {
+		assert.Equal(t, model.ApprovalStatusPending, approvals[0].Status)
+		assert.Equal(t, model.ApprovalStatusExpired, approvals[1].Status)
+		assert.Equal(t, model.ApprovalStatusApproved, approvals[2].Status)
+	}
+
+	_, err = useradm.GetApprovals(ctx)
+	assert.EqualError(t, err, "useradm: failed to get approvals: db error")
+}
+
+func TestUserAdmApproveAction(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Hour)
+	makeApproval := func(request model.ApprovalRequest) *model.Approval {
+		return &model.Approval{
+			ID:              "a1",
+			ApprovalRequest: request,
+			RequestedBy:     "1",
+			Status:          model.ApprovalStatusPending,
+			ExpiresTs:       &later,
+		}
+	}
+	settings := &model.Settings{Values: model.SettingsValues{"foo": "bar"}}
+
+	testCases := map[string]struct {
+		reviewer string
+
+		dbApproval *model.Approval
+		dbErr      error
+		callReview bool
+		reviewErr  error
+
+		setupAction func(db *mstore.DataStore)
+		actionErr   bool
+
+		outErr error
+	}{
+		"ok, delete user": {
+			reviewer: "2",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action: model.ApprovalActionDeleteUser,
+				UserID: "3",
+			}),
+			callReview: true,
+			setupAction: func(db *mstore.DataStore) {
+				db.On("DeleteUser", mock.Anything, "3").Return(nil)
+				db.On("DeleteTokensByUserId", mock.Anything, "3").Return(nil)
+				db.On("RemoveUserFromGroups", mock.Anything, "3").Return(nil)
+				db.On("DeleteUserSettings", mock.Anything, "3").Return(nil)
+			},
+		},
+		"ok, delete tokens": {
+			reviewer: "2",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action: model.ApprovalActionDeleteTokens,
+			}),
+			callReview: true,
+			setupAction: func(db *mstore.DataStore) {
+				db.On("DeleteTokens",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						return identity.FromContext(ctx).Tenant == "tenant"
+					})).Return(nil)
+			},
+		},
+		"ok, save settings": {
+			reviewer: "2",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action:       model.ApprovalActionSaveSettings,
+				Settings:     settings,
+				SettingsETag: "etag",
+			}),
+			callReview: true,
+			setupAction: func(db *mstore.DataStore) {
+				db.On("SaveSettings", mock.Anything, settings, "etag").Return(nil)
+			},
+		},
+		"ok, save approval settings": {
+			reviewer: "2",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action:           model.ApprovalActionSaveApprovalSettings,
+				ApprovalSettings: &model.ApprovalSettings{},
+			}),
+			callReview: true,
+			setupAction: func(db *mstore.DataStore) {
+				db.On("SaveApprovalSettings", mock.Anything,
+					&model.ApprovalSettings{Actions: []string{}}).Return(nil)
+			},
+		},
+		"ok, create SCIM token": {
+			reviewer: "2",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action:    model.ApprovalActionCreateSCIMToken,
+				SCIMToken: &model.SCIMToken{Hash: "hash"},
+			}),
+			callReview: true,
+			setupAction: func(db *mstore.DataStore) {
+				db.On("SaveSCIMToken", mock.Anything,
+					&model.SCIMToken{Hash: "hash"}).Return(nil)
+			},
+		},
+		"ok, delete OIDC config": {
+			reviewer: "2",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action: model.ApprovalActionDeleteOIDCConfig,
+			}),
+			callReview: true,
+			setupAction: func(db *mstore.DataStore) {
+				db.On("DeleteOIDCConfig", mock.Anything).Return(nil)
+			},
+		},
+		"error: action failed": {
+			reviewer: "2",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action:       model.ApprovalActionSaveSettings,
+				Settings:     settings,
+				SettingsETag: "etag",
+			}),
+			callReview: true,
+			setupAction: func(db *mstore.DataStore) {
+				db.On("SaveSettings", mock.Anything, settings, "etag").
+					Return(store.ErrETagMismatch)
+			},
+			actionErr: true,
+
+			outErr: errors.New(
+				"useradm: failed to save settings: ETag doesn't match"),
+		},
+		"error: self review": {
+			reviewer: "1",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action: model.ApprovalActionDeleteUser,
+				UserID: "3",
+			}),
+
+			outErr: ErrApprovalSelfReview,
+		},
+		"error: expired": {
+			reviewer: "2",
+			dbApproval: &model.Approval{
+				ID:        "a1",
+				Status:    model.ApprovalStatusPending,
+				ExpiresTs: &earlier,
+			},
+
+			outErr: ErrApprovalExpired,
+		},
+		"error: not pending": {
+			reviewer: "2",
+			dbApproval: &model.Approval{
+				ID:     "a1",
+				Status: model.ApprovalStatusRejected,
+			},
+
+			outErr: ErrApprovalNotPending,
+		},
+		"error: reviewed in the meantime": {
+			reviewer: "2",
+			dbApproval: makeApproval(model.ApprovalRequest{
+				Action: model.ApprovalActionDeleteUser,
+				UserID: "3",
+			}),
+			callReview: true,
+			reviewErr:  store.ErrApprovalNotFound,
+
+			outErr: ErrApprovalNotPending,
+		},
+		"error: not found": {
+			reviewer: "2",
+
+			outErr: ErrApprovalNotFound,
+		},
+		"error: db": {
+			reviewer: "2",
+			dbErr:    errors.New("db error"),
+
+			outErr: errors.New("useradm: failed to get approval: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := identity.WithContext(context.Background(),
+				&identity.Identity{
+					Subject: tc.reviewer,
+					Tenant:  "tenant",
+					IsUser:  true,
+				})
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetApproval", ctx, "a1").Return(tc.dbApproval, tc.dbErr)
+			if tc.callReview {
+				db.On("UpdateApprovalStatus", ctx, "a1",
+					model.ApprovalStatusPending,
+					mock.MatchedBy(func(review *model.ApprovalReview) bool {
+						return review.Status == model.ApprovalStatusApproved &&
+							review.ReviewedBy == tc.reviewer
+					})).
+					Return(tc.reviewErr)
+			}
+			if tc.setupAction != nil {
+				tc.setupAction(db)
+			}
+			if tc.actionErr {
+				db.On("UpdateApprovalStatus", ctx, "a1",
+					model.ApprovalStatusApproved,
+					&model.ApprovalReview{
+						Status: model.ApprovalStatusFailed,
+						Error:  tc.outErr.Error(),
+					}).
+					Return(nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.ApproveAction(ctx, "a1")
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmRejectAction(t *testing.T) {
+	ctx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "2", IsUser: true})
+	later := time.Now().Add(time.Hour)
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetApproval", ctx, "a1").Return(&model.Approval{
+		ID: "a1",
+		ApprovalRequest: model.ApprovalRequest{
+			Action: model.ApprovalActionDeleteUser,
+			UserID: "3",
+		},
+		RequestedBy: "1",
+		Status:      model.ApprovalStatusPending,
+		ExpiresTs:   &later,
+	}, nil)
+	db.On("UpdateApprovalStatus", ctx, "a1", model.ApprovalStatusPending,
+		mock.MatchedBy(func(review *model.ApprovalReview) bool {
+			return review.Status == model.ApprovalStatusRejected &&
+				review.ReviewedBy == "2"
+		})).Return(nil)
+
+	useradm := NewUserAdm(nil, db, Config{})
+	assert.NoError(t, useradm.RejectAction(ctx, "a1"))
+}
+
+func TestUserAdmApprovalSettings(t *testing.T) {
+	ctx := context.Background()
+	settings := &model.ApprovalSettings{
+		Actions: []string{model.ApprovalActionDeleteUser},
+	}
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetApprovalSettings", ctx).Return(nil, nil).Once()
+	db.On("GetApprovalSettings", ctx).Return(settings, nil).Once()
+	db.On("GetApprovalSettings", ctx).Return(nil, errors.New("db error")).Once()
+	db.On("SaveApprovalSettings", ctx, settings).Return(nil).Once()
+	db.On("SaveApprovalSettings", ctx, settings).Return(errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	out, err := useradm.GetApprovalSettings(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ApprovalSettings{Actions: []string{}}, out)
+
+	out, err = useradm.GetApprovalSettings(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, settings, out)
+
+	_, err = useradm.GetApprovalSettings(ctx)
+	assert.EqualError(t, err,
+		"useradm: failed to get approval settings: db error")
+
+	assert.NoError(t, useradm.SaveApprovalSettings(ctx, settings))
+	assert.EqualError(t, useradm.SaveApprovalSettings(ctx, settings),
+		"useradm: failed to save approval settings: db error")
+}
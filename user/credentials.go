@@ -0,0 +1,181 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mendersoftware/useradm/model"
+)
+
+// CredentialChecker verifies the credentials presented on login.
+type CredentialChecker interface {
+	// CheckCredentials verifies the email/password pair; local is the
+	// matching user record, nil if the user is not known locally.
+	// On success it returns the attributes of the authenticated user;
+	// Roles are left nil when the backend does not manage them.
+	// Invalid credentials are reported with a nil user and nil error;
+	// model.ErrCredentialsNotFound tells the backend has no entry for
+	// the user.
+	CheckCredentials(
+		ctx context.Context,
+		email model.Email,
+		password string,
+		local *model.User,
+	) (*model.User, error)
+}
+
+// passwordChecker verifies the credentials against the password hash
+// stored in the local user record.
+type passwordChecker struct{}
+
+func (passwordChecker) CheckCredentials(
+	ctx context.Context,
+	email model.Email,
+	password string,
+	local *model.User,
+) (*model.User, error) {
+	if local == nil {
+		return nil, nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(local.Password), []byte(password))
+	if err != nil {
+		return nil, nil
+	}
+	return local, nil
+}
+
+// fallbackChecker verifies the credentials with the backend first, and
+// against the local password for the users the backend has no entry for
+// and for the users marked local, e.g. the break-glass administrators.
+type fallbackChecker struct {
+	backend    CredentialChecker
+	local      CredentialChecker
+	localUsers map[model.Email]struct{}
+}
+
+func (c *fallbackChecker) CheckCredentials(
+	ctx context.Context,
+	email model.Email,
+	password string,
+	local *model.User,
+) (*model.User, error) {
+	if _, ok := c.localUsers[model.Email(strings.ToLower(string(email)))]; !ok {
+		user, err := c.backend.CheckCredentials(ctx, email, password, local)
+		if errors.Cause(err) != model.ErrCredentialsNotFound {
+			return user, err
+		}
+	}
+	return c.local.CheckCredentials(ctx, email, password, local)
+}
+
+// WithCredentialChecker produces a UserAdm instance which delegates the
+// credential check upon /login to the given backend, falling back to the
+// local passwords for the users the backend does not know and for the
+// localUsers.
+func (u *UserAdm) WithCredentialChecker(
+	c CredentialChecker,
+	localUsers ...model.Email,
+) *UserAdm {
+	checker := &fallbackChecker{
+		backend:    c,
+		local:      passwordChecker{},
+		localUsers: make(map[model.Email]struct{}, len(localUsers)),
+	}
+	for _, email := range localUsers {
+		checker.localUsers[model.Email(strings.ToLower(string(email)))] = struct{}{}
+	}
+	u.credentials = checker
+	return u
+}
+
+// syncUser creates or updates the local record of a user authenticated
+// by the credential backend.
+func (u *UserAdm) syncUser(
+	ctx context.Context,
+	local *model.User,
+	authenticated *model.User,
+) (*model.User, error) {
+	l := log.FromContext(ctx)
+
+	if local == nil {
+		user := &model.User{
+			Email: authenticated.Email,
+			Name:  authenticated.Name,
+			Roles: authenticated.Roles,
+		}
+		// the provisioned users are not required the custom attributes
+		err := u.doCreateUser(ctx, user, true)
+		switch errors.Cause(err) {
+		case nil:
+		case ErrUnknownRole:
+			l.Warnf("login: refusing to provision %s: %s",
+				authenticated.Email, err.Error())
+			return nil, ErrUnauthorized
+		default:
+			return nil, errors.Wrap(err, "useradm: failed to provision user")
+		}
+		l.Infof("login: provisioned user %s", user.ID)
+		return user, nil
+	}
+
+	if authenticated.Roles != nil && !sameRoles(local.Roles, authenticated.Roles) {
+		err := u.SetUserRoles(ctx, local.ID, authenticated.Roles)
+		switch errors.Cause(err) {
+		case nil:
+		case ErrUnknownRole:
+			l.Warnf("login: refusing to update the roles of user %s: %s",
+				local.ID, err.Error())
+			return nil, ErrUnauthorized
+		default:
+			return nil, errors.Wrap(err, "useradm: failed to update user roles")
+		}
+		local.Roles = authenticated.Roles
+		l.Infof("login: updated the roles of user %s", local.ID)
+	}
+
+	if authenticated.Name != "" && authenticated.Name != local.Name {
+		_, err := u.db.UpdateUser(ctx, local.ID, &model.UserUpdate{
+			Name: authenticated.Name,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "useradm: failed to update user")
+		}
+		local.Name = authenticated.Name
+		l.Infof("login: updated the name of user %s", local.ID)
+	}
+	return local, nil
+}
+
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]struct{}, len(a))
+	for _, role := range a {
+		set[role] = struct{}{}
+	}
+	for _, role := range b {
+		if _, ok := set[role]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,297 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mendersoftware/useradm/model"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+type fakeCredentialChecker struct {
+	user *model.User
+	err  error
+}
+
+func (c *fakeCredentialChecker) CheckCredentials(
+	ctx context.Context,
+	email model.Email,
+	password string,
+	local *model.User,
+) (*model.User, error) {
+	if password != "secret" {
+		return nil, nil
+	}
+	return c.user, c.err
+}
+
+func TestUserAdmLoginCredentialChecker(t *testing.T) {
+	const userID = "e3b4c9d2-5d8c-4a5e-9a63-3ffb3e0f3a1e"
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		password   string
+		localUsers []model.Email
+
+		dbUser       *model.User
+		checkerUser  *model.User
+		checkerErr   error
+		dbOIDCConfig *model.OIDCConfig
+
+		createdRoles []string
+		createErr    error
+		updatedRoles []string
+		updateErr    error
+		updatedName  string
+		dbRoles      []model.Role
+
+		outErr error
+	}{
+		"ok, user provisioned": {
+			password: "secret",
+			checkerUser: &model.User{
+				Email: "user@example.com",
+				Name:  "Jane Doe",
+				Roles: []string{model.RoleReadOnly},
+			},
+			createdRoles: []string{model.RoleReadOnly},
+		},
+		"ok, user provisioned with the default roles": {
+			password:     "secret",
+			checkerUser:  &model.User{Email: "user@example.com"},
+			createdRoles: []string{model.RoleReadOnly},
+		},
+		"ok, roles updated": {
+			password: "secret",
+			dbUser: &model.User{
+				ID:    userID,
+				Email: "user@example.com",
+				Roles: []string{model.RoleAdmin},
+			},
+			checkerUser: &model.User{
+				Email: "user@example.com",
+				Roles: []string{"ops", model.RoleReadOnly},
+			},
+			dbRoles:      []model.Role{{Name: "ops"}},
+			updatedRoles: []string{"ops", model.RoleReadOnly},
+		},
+		"ok, roles unchanged": {
+			password: "secret",
+			dbUser: &model.User{
+				ID:    userID,
+				Email: "user@example.com",
+				Roles: []string{model.RoleReadOnly, model.RoleAdmin},
+			},
+			checkerUser: &model.User{
+				Email: "user@example.com",
+				Roles: []string{model.RoleAdmin, model.RoleReadOnly},
+			},
+		},
+		"ok, roles not managed by the backend": {
+			password: "secret",
+			dbUser: &model.User{
+				ID:    userID,
+				Email: "user@example.com",
+				Roles: []string{model.RoleAdmin},
+			},
+			checkerUser: &model.User{Email: "user@example.com"},
+		},
+		"ok, name updated": {
+			password: "secret",
+			dbUser: &model.User{
+				ID:    userID,
+				Email: "user@example.com",
+				Name:  "Jane Roe",
+			},
+			checkerUser: &model.User{
+				Email: "user@example.com",
+				Name:  "Jane Doe",
+			},
+			updatedName: "Jane Doe",
+		},
+		"ok, unknown to the backend": {
+			password: "secret",
+			dbUser: &model.User{
+				ID:       userID,
+				Email:    "user@example.com",
+				Password: string(hash),
+			},
+			checkerErr: model.ErrCredentialsNotFound,
+		},
+		"ok, local user": {
+			password:   "secret",
+			localUsers: []model.Email{"User@example.com"},
+			dbUser: &model.User{
+				ID:       userID,
+				Email:    "user@example.com",
+				Password: string(hash),
+			},
+			checkerErr: errors.New("connection refused"),
+		},
+		"error: unknown to the backend, invalid local password": {
+			password: "secret",
+			dbUser: &model.User{
+				ID:       userID,
+				Email:    "user@example.com",
+				Password: "$2a$04$invalid",
+			},
+			checkerErr: model.ErrCredentialsNotFound,
+			outErr:     ErrUnauthorized,
+		},
+		"error: unknown to the backend and locally": {
+			password:   "secret",
+			checkerErr: model.ErrCredentialsNotFound,
+			outErr:     ErrUnauthorized,
+		},
+		"error: invalid credentials": {
+			password: "wrong",
+			dbUser: &model.User{
+				ID:    userID,
+				Email: "user@example.com",
+			},
+			outErr: ErrUnauthorized,
+		},
+		"error: backend failure": {
+			password:   "secret",
+			checkerErr: errors.New("connection refused"),
+			outErr: errors.New("useradm: failed to check credentials: " +
+				"connection refused"),
+		},
+		"error: password login disabled": {
+			password:     "secret",
+			checkerUser:  &model.User{Email: "user@example.com"},
+			dbOIDCConfig: &model.OIDCConfig{DisablePasswordLogin: true},
+			outErr:       ErrPasswordLoginDisabled,
+		},
+		"error: unknown role on provisioning": {
+			password: "secret",
+			checkerUser: &model.User{
+				Email: "user@example.com",
+				Roles: []string{"ops"},
+			},
+			outErr: ErrUnauthorized,
+		},
+		"error: unknown role on update": {
+			password: "secret",
+			dbUser: &model.User{
+				ID:    userID,
+				Email: "user@example.com",
+				Roles: []string{model.RoleAdmin},
+			},
+			checkerUser: &model.User{
+				Email: "user@example.com",
+				Roles: []string{"ops"},
+			},
+			outErr: ErrUnauthorized,
+		},
+		"error: provisioning": {
+			password:     "secret",
+			checkerUser:  &model.User{Email: "user@example.com"},
+			createdRoles: []string{model.RoleReadOnly},
+			createErr:    errors.New("db error"),
+			outErr: errors.New("useradm: failed to provision user: " +
+				"useradm: failed to create user in the db: db error"),
+		},
+		"error: updating the roles": {
+			password: "secret",
+			dbUser: &model.User{
+				ID:    userID,
+				Email: "user@example.com",
+				Roles: []string{model.RoleAdmin},
+			},
+			checkerUser: &model.User{
+				Email: "user@example.com",
+				Roles: []string{model.RoleReadOnly},
+			},
+			updatedRoles: []string{model.RoleReadOnly},
+			updateErr:    errors.New("db error"),
+			outErr: errors.New("useradm: failed to update user roles: " +
+				"useradm: failed to update user roles: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+
+			db.On("GetUserByEmail", mtesting.ContextMatcher(),
+				model.Email("user@example.com")).
+				Return(tc.dbUser, nil)
+			db.On("GetOIDCConfig", mtesting.ContextMatcher()).
+				Return(tc.dbOIDCConfig, nil).Maybe()
+			db.On("GetRolesByNames", mtesting.ContextMatcher(),
+				mock.AnythingOfType("[]string")).
+				Return(tc.dbRoles, nil).Maybe()
+			if tc.createdRoles != nil {
+				db.On("CreateUser", mtesting.ContextMatcher(),
+					mock.MatchedBy(func(u *model.User) bool {
+						return u.Email == "user@example.com" &&
+							u.Name == tc.checkerUser.Name &&
+							u.Password == "" &&
+							assert.ObjectsAreEqual(tc.createdRoles, u.Roles)
+					})).
+					Return(tc.createErr)
+			}
+			if tc.updatedRoles != nil {
+				db.On("SetUserRoles", mtesting.ContextMatcher(),
+					userID, tc.updatedRoles).
+					Return(tc.updateErr)
+			}
+			if tc.updatedName != "" {
+				db.On("UpdateUser", mtesting.ContextMatcher(), userID,
+					&model.UserUpdate{Name: tc.updatedName}).
+					Return(nil, nil)
+			}
+			if tc.outErr == nil {
+				db.On("GetActiveElevations", mtesting.ContextMatcher(),
+					mock.AnythingOfType("string")).Return(nil, nil)
+				db.On("SaveToken", mtesting.ContextMatcher(),
+					mock.AnythingOfType("*jwt.Token")).Return(nil)
+				db.On("UpdateLoginTs", mtesting.ContextMatcher(),
+					mock.AnythingOfType("string")).Return(nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{
+				Issuer:         "mender",
+				ExpirationTime: 10,
+			}).WithCredentialChecker(&fakeCredentialChecker{
+				user: tc.checkerUser,
+				err:  tc.checkerErr,
+			}, tc.localUsers...)
+
+			token, err := useradm.Login(ctx, "user@example.com", tc.password)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, token)
+			} else if assert.NoError(t, err) {
+				if tc.dbUser != nil {
+					assert.Equal(t, userID, token.Claims.Subject.String())
+				}
+				assert.True(t, token.Claims.User)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+)
+
+var (
+	ErrElevationNotFound   = errors.New("elevation not found")
+	ErrElevationNotPending = errors.New("elevation is not pending approval")
+	ErrElevationSelfReview = errors.New(
+		"elevation must be reviewed by another user")
+	ErrElevationTooLong = errors.New(
+		"elevation duration exceeds the maximum")
+	ErrElevationRoleNotAllowed = errors.New(
+		"the role may not be requested by the user")
+)
+
+// RequestElevation requests the role for the calling user for a limited
+// time; the role is granted right away, unless the elevations require
+// the approval of another admin. The users may only request the roles
+// configured for their own roles.
+func (ua *UserAdm) RequestElevation(
+	ctx context.Context,
+	elevationNew *model.ElevationNew,
+) (*model.Elevation, error) {
+	id := identity.FromContext(ctx)
+	if id == nil || !id.IsUser {
+		return nil, errors.New("identity not present in the context")
+	}
+	config := ua.getConfig()
+	if config.ElevationMaxDuration > 0 &&
+		elevationNew.Duration > config.ElevationMaxDuration {
+		return nil, ErrElevationTooLong
+	}
+	if err := ua.checkElevationRole(ctx, id.Subject, elevationNew.Role); err != nil {
+		return nil, err
+	}
+	if err := ua.checkRoles(ctx, []string{elevationNew.Role}); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	elevation := &model.Elevation{
+		ID:            oid.NewUUIDv4().String(),
+		UserID:        id.Subject,
+		Role:          elevationNew.Role,
+		Justification: elevationNew.Justification,
+		Duration:      elevationNew.Duration,
+		Status:        model.ElevationStatusPending,
+		CreatedTs:     &now,
+	}
+	if !config.ElevationApproval {
+		expires := now.Add(elevation.Window())
+		elevation.Status = model.ElevationStatusActive
+		elevation.ExpiresTs = &expires
+	}
+	if err := ua.db.CreateElevation(ctx, elevation); err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to create elevation")
+	}
+	if elevation.Status == model.ElevationStatusActive {
+		// the user is granted the role right away
+		ua.verifyCache.InvalidateUser(elevation.UserID)
+	}
+	return elevation, nil
+}
+
+// checkElevationRole checks that the role may be requested by the user;
+// the roles of the user's groups count, the elevated roles do not, so
+// that the elevations cannot be chained.
+func (ua *UserAdm) checkElevationRole(ctx context.Context, userID, role string) error {
+	elevationRoles := ua.getConfig().ElevationRoles
+	if len(elevationRoles) == 0 {
+		return ErrElevationRoleNotAllowed
+	}
+	user, err := ua.db.GetUserById(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get user")
+	} else if user == nil {
+		return ErrUserNotFound
+	}
+	groups, err := ua.db.GetGroupsByMember(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get groups")
+	}
+	roles := user.Roles
+	for _, group := range groups {
+		roles = append(roles, group.Roles...)
+	}
+	for _, own := range roles {
+		for _, allowed := range elevationRoles[strings.ToLower(own)] {
+			if allowed == role {
+				return nil
+			}
+		}
+	}
+	return ErrElevationRoleNotAllowed
+}
+
+// GetElevations returns the elevations of the user, or of all the users
+// if userID is empty.
+func (ua *UserAdm) GetElevations(ctx context.Context, userID string) ([]model.Elevation, error) {
+	elevations, err := ua.db.GetElevations(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get elevations")
+	}
+	now := time.Now()
+	for i := range elevations {
+		setElevationExpired(&elevations[i], now)
+	}
+	return elevations, nil
+}
+
+func (ua *UserAdm) GetElevation(ctx context.Context, id string) (*model.Elevation, error) {
+	elevation, err := ua.db.GetElevation(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get elevation")
+	} else if elevation == nil {
+		return nil, ErrElevationNotFound
+	}
+	setElevationExpired(elevation, time.Now())
+	return elevation, nil
+}
+
+// ApproveElevation grants the role of a pending elevation; the elevation
+// window starts with the approval.
+func (ua *UserAdm) ApproveElevation(ctx context.Context, id string) error {
+	return ua.reviewElevation(ctx, id, model.ElevationStatusActive)
+}
+
+func (ua *UserAdm) RejectElevation(ctx context.Context, id string) error {
+	return ua.reviewElevation(ctx, id, model.ElevationStatusRejected)
+}
+
+func (ua *UserAdm) reviewElevation(ctx context.Context, id, status string) error {
+	reviewer := identity.FromContext(ctx)
+	if reviewer == nil || !reviewer.IsUser {
+		return errors.New("identity not present in the context")
+	}
+	elevation, err := ua.GetElevation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if elevation.Status != model.ElevationStatusPending {
+		return ErrElevationNotPending
+	}
+	if elevation.UserID == reviewer.Subject {
+		return ErrElevationSelfReview
+	}
+
+	now := time.Now().UTC()
+	review := &model.ElevationReview{
+		Status:     status,
+		ReviewedBy: reviewer.Subject,
+		ReviewedTs: &now,
+	}
+	if status == model.ElevationStatusActive {
+		expires := now.Add(elevation.Window())
+		review.ExpiresTs = &expires
+	}
+	err = ua.db.ReviewElevation(ctx, id, review)
+	if status == model.ElevationStatusActive {
+		// the user is granted the role right away
+		ua.verifyCache.InvalidateUser(elevation.UserID)
+	}
+	if err == store.ErrElevationNotFound {
+		// reviewed in the meantime
+		return ErrElevationNotPending
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to review elevation")
+	}
+	return nil
+}
+
+// elevationClaim describes the user's active elevations for the tokens
+// issued during the elevation window, or returns nil if there are none.
+func (ua *UserAdm) elevationClaim(ctx context.Context, userID string) (*jwt.Elevation, error) {
+	elevations, err := ua.db.GetActiveElevations(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get elevations")
+	}
+	var claim *jwt.Elevation
+	now := time.Now()
+	for _, elevation := range elevations {
+		if !elevation.IsActive(now) {
+			continue
+		}
+		if claim == nil {
+			claim = &jwt.Elevation{ExpiresAt: jwt.Time{Time: *elevation.ExpiresTs}}
+		} else if elevation.ExpiresTs.Before(claim.ExpiresAt.Time) {
+			claim.ExpiresAt = jwt.Time{Time: *elevation.ExpiresTs}
+		}
+		claim.Roles = append(claim.Roles, elevation.Role)
+	}
+	return claim, nil
+}
+
+func setElevationExpired(elevation *model.Elevation, now time.Time) {
+	if elevation.Status == model.ElevationStatusActive && !elevation.IsActive(now) {
+		elevation.Status = model.ElevationStatusExpired
+	}
+}
@@ -0,0 +1,400 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+func TestUserAdmRequestElevation(t *testing.T) {
+	elevationRoles := map[string][]string{
+		model.RoleReleaseManager: {model.RoleAdmin, testRole.Name},
+	}
+	releaseManager := &model.User{ID: "1", Roles: []string{model.RoleReleaseManager}}
+	readOnly := &model.User{ID: "1", Roles: []string{model.RoleReadOnly}}
+
+	testCases := map[string]struct {
+		elevation *model.ElevationNew
+		config    Config
+		identity  *identity.Identity
+
+		dbUser       *model.User
+		dbUserErr    error
+		dbGroups     []model.Group
+		callGetRoles bool
+		dbRoles      []model.Role
+		callDb       bool
+		dbErr        error
+
+		outStatus string
+		outErr    error
+	}{
+		"ok": {
+			elevation: &model.ElevationNew{
+				Role:          model.RoleAdmin,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			config: Config{
+				ElevationMaxDuration: 60,
+				ElevationRoles:       elevationRoles,
+			},
+			identity: &identity.Identity{Subject: "1", IsUser: true},
+			dbUser:   releaseManager,
+			callDb:   true,
+
+			outStatus: model.ElevationStatusActive,
+		},
+		"ok, approval": {
+			elevation: &model.ElevationNew{
+				Role:          testRole.Name,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			config: Config{
+				ElevationApproval: true,
+				ElevationRoles:    elevationRoles,
+			},
+			identity:     &identity.Identity{Subject: "1", IsUser: true},
+			dbUser:       releaseManager,
+			callGetRoles: true,
+			dbRoles:      []model.Role{testRole},
+			callDb:       true,
+
+			outStatus: model.ElevationStatusPending,
+		},
+		"ok, role of a group": {
+			elevation: &model.ElevationNew{
+				Role:          model.RoleAdmin,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			config: Config{
+				ElevationApproval: true,
+				ElevationRoles:    elevationRoles,
+			},
+			identity: &identity.Identity{Subject: "1", IsUser: true},
+			dbUser:   readOnly,
+			dbGroups: []model.Group{{
+				Name:  "releases",
+				Roles: []string{model.RoleReleaseManager},
+			}},
+			callDb: true,
+
+			outStatus: model.ElevationStatusPending,
+		},
+		"error: read-only user requests admin": {
+			elevation: &model.ElevationNew{
+				Role:          model.RoleAdmin,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			config:   Config{ElevationRoles: elevationRoles},
+			identity: &identity.Identity{Subject: "1", IsUser: true},
+			dbUser:   readOnly,
+
+			outErr: ErrElevationRoleNotAllowed,
+		},
+		"error: no roles may be requested": {
+			elevation: &model.ElevationNew{
+				Role:          model.RoleAdmin,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			identity: &identity.Identity{Subject: "1", IsUser: true},
+
+			outErr: ErrElevationRoleNotAllowed,
+		},
+		"error: too long": {
+			elevation: &model.ElevationNew{
+				Role:          model.RoleAdmin,
+				Justification: "incident #42",
+				Duration:      90,
+			},
+			config: Config{
+				ElevationMaxDuration: 60,
+				ElevationRoles:       elevationRoles,
+			},
+			identity: &identity.Identity{Subject: "1", IsUser: true},
+
+			outErr: ErrElevationTooLong,
+		},
+		"error: unknown role": {
+			elevation: &model.ElevationNew{
+				Role:          "foo",
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			config: Config{ElevationRoles: map[string][]string{
+				model.RoleReleaseManager: {"foo"},
+			}},
+			identity:     &identity.Identity{Subject: "1", IsUser: true},
+			dbUser:       releaseManager,
+			callGetRoles: true,
+			dbRoles:      []model.Role{},
+
+			outErr: ErrUnknownRole,
+		},
+		"error: no identity": {
+			elevation: &model.ElevationNew{
+				Role:          model.RoleAdmin,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+
+			outErr: errors.New("identity not present in the context"),
+		},
+		"error: get user": {
+			elevation: &model.ElevationNew{
+				Role:          model.RoleAdmin,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			config:    Config{ElevationRoles: elevationRoles},
+			identity:  &identity.Identity{Subject: "1", IsUser: true},
+			dbUserErr: errors.New("db error"),
+
+			outErr: errors.New("useradm: failed to get user: db error"),
+		},
+		"error: db": {
+			elevation: &model.ElevationNew{
+				Role:          model.RoleAdmin,
+				Justification: "incident #42",
+				Duration:      30,
+			},
+			config:   Config{ElevationRoles: elevationRoles},
+			identity: &identity.Identity{Subject: "1", IsUser: true},
+			dbUser:   releaseManager,
+			callDb:   true,
+			dbErr:    errors.New("db error"),
+
+			outErr: errors.New("useradm: failed to create elevation: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = identity.WithContext(ctx, tc.identity)
+			}
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.dbUser != nil || tc.dbUserErr != nil {
+				db.On("GetUserById", ctx, "1").
+					Return(tc.dbUser, tc.dbUserErr)
+			}
+			if tc.dbUser != nil {
+				db.On("GetGroupsByMember", ctx, "1").
+					Return(tc.dbGroups, nil)
+			}
+			if tc.callGetRoles {
+				db.On("GetRolesByNames", ctx, []string{tc.elevation.Role}).
+					Return(tc.dbRoles, nil)
+			}
+			if tc.callDb {
+				db.On("CreateElevation", ctx,
+					mock.AnythingOfType("*model.Elevation")).
+					Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, tc.config)
+			elevation, err := useradm.RequestElevation(ctx, tc.elevation)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, elevation)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, elevation.ID)
+				assert.Equal(t, "1", elevation.UserID)
+				assert.Equal(t, tc.outStatus, elevation.Status)
+				if tc.outStatus == model.ElevationStatusActive {
+					assert.WithinDuration(t,
+						time.Now().Add(30*time.Minute),
+						*elevation.ExpiresTs, time.Second)
+				} else {
+					assert.Nil(t, elevation.ExpiresTs)
+				}
+			}
+		})
+	}
+}
+
+func TestUserAdmGetElevations(t *testing.T) {
+	ctx := context.Background()
+	earlier := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Minute)
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetElevations", ctx, "1").Return([]model.Elevation{
+		{ID: "e1", Status: model.ElevationStatusActive, ExpiresTs: &later},
+		{ID: "e2", Status: model.ElevationStatusActive, ExpiresTs: &earlier},
+		{ID: "e3", Status: model.ElevationStatusRejected},
+	}, nil).Once()
+	db.On("GetElevations", ctx, "").Return(nil, errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	elevations, err := useradm.GetElevations(ctx, "1")
+	assert.NoError(t, err)
+	if assert.Len(t, elevations, 3) {
+		assert.Equal(t, model.ElevationStatusActive, elevations[0].Status)
+		assert.Equal(t, model.ElevationStatusExpired, elevations[1].Status)
+		assert.Equal(t, model.ElevationStatusRejected, elevations[2].Status)
+	}
+
+	_, err = useradm.GetElevations(ctx, "")
+	assert.EqualError(t, err, "useradm: failed to get elevations: db error")
+}
+
+func TestUserAdmReviewElevation(t *testing.T) {
+	pending := &model.Elevation{
+		ID:       "e1",
+		UserID:   "1",
+		Role:     model.RoleAdmin,
+		Duration: 30,
+		Status:   model.ElevationStatusPending,
+	}
+	testCases := map[string]struct {
+		approve  bool
+		reviewer string
+
+		dbElevation *model.Elevation
+		dbErr       error
+		callReview  bool
+		reviewErr   error
+
+		outErr error
+	}{
+		"ok, approve": {
+			approve:     true,
+			reviewer:    "2",
+			dbElevation: pending,
+			callReview:  true,
+		},
+		"ok, reject": {
+			reviewer:    "2",
+			dbElevation: pending,
+			callReview:  true,
+		},
+		"error: self review": {
+			approve:     true,
+			reviewer:    "1",
+			dbElevation: pending,
+
+			outErr: ErrElevationSelfReview,
+		},
+		"error: not pending": {
+			approve:  true,
+			reviewer: "2",
+			dbElevation: &model.Elevation{
+				ID:     "e1",
+				UserID: "1",
+				Status: model.ElevationStatusRejected,
+			},
+
+			outErr: ErrElevationNotPending,
+		},
+		"error: reviewed in the meantime": {
+			approve:     true,
+			reviewer:    "2",
+			dbElevation: pending,
+			callReview:  true,
+			reviewErr:   store.ErrElevationNotFound,
+
+			outErr: ErrElevationNotPending,
+		},
+		"error: not found": {
+			reviewer: "2",
+
+			outErr: ErrElevationNotFound,
+		},
+		"error: db": {
+			reviewer: "2",
+			dbErr:    errors.New("db error"),
+
+			outErr: errors.New("useradm: failed to get elevation: db error"),
+		},
+		"error: db review": {
+			reviewer:    "2",
+			dbElevation: pending,
+			callReview:  true,
+			reviewErr:   errors.New("db error"),
+
+			outErr: errors.New("useradm: failed to review elevation: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := identity.WithContext(context.Background(),
+				&identity.Identity{Subject: tc.reviewer, IsUser: true})
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			var dbElevation *model.Elevation
+			if tc.dbElevation != nil {
+				elevation := *tc.dbElevation
+				dbElevation = &elevation
+			}
+			db.On("GetElevation", ctx, "e1").Return(dbElevation, tc.dbErr)
+			if tc.callReview {
+				db.On("ReviewElevation", ctx, "e1",
+					mock.MatchedBy(func(review *model.ElevationReview) bool {
+						if review.ReviewedBy != tc.reviewer {
+							return false
+						}
+						if tc.approve {
+							return review.Status == model.ElevationStatusActive &&
+								review.ExpiresTs != nil &&
+								review.ExpiresTs.Sub(*review.ReviewedTs) ==
+									30*time.Minute
+						}
+						return review.Status == model.ElevationStatusRejected &&
+							review.ExpiresTs == nil
+					})).
+					Return(tc.reviewErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			var err error
+			if tc.approve {
+				err = useradm.ApproveElevation(ctx, "e1")
+			} else {
+				err = useradm.RejectElevation(ctx, "e1")
+			}
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
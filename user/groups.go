@@ -0,0 +1,150 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+)
+
+var (
+	ErrGroupNotFound      = errors.New("group not found")
+	ErrDuplicateGroupName = errors.New("group with a given name already exists")
+)
+
+func (ua *UserAdm) GetGroups(ctx context.Context) ([]model.Group, error) {
+	groups, err := ua.db.GetGroups(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get groups")
+	}
+	return groups, nil
+}
+
+func (ua *UserAdm) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	group, err := ua.db.GetGroup(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get group")
+	} else if group == nil {
+		return nil, ErrGroupNotFound
+	}
+	return group, nil
+}
+
+func (ua *UserAdm) CreateGroup(
+	ctx context.Context,
+	groupNew *model.GroupNew,
+) (*model.Group, error) {
+	roles := groupNew.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	if err := ua.checkRoles(ctx, roles); err != nil {
+		return nil, err
+	}
+	group := &model.Group{
+		ID:          oid.NewUUIDv4().String(),
+		Name:        groupNew.Name,
+		Description: groupNew.Description,
+		Roles:       roles,
+	}
+	err := ua.db.CreateGroup(ctx, group)
+	if err == store.ErrDuplicateGroupName {
+		return nil, ErrDuplicateGroupName
+	} else if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to create group")
+	}
+	return group, nil
+}
+
+func (ua *UserAdm) UpdateGroup(ctx context.Context, id string, group *model.GroupUpdate) error {
+	if group.Roles != nil {
+		if err := ua.checkRoles(ctx, *group.Roles); err != nil {
+			return err
+		}
+	}
+	err := ua.db.UpdateGroup(ctx, id, group)
+	if group.Roles != nil {
+		// the roles of the members change
+		ua.invalidateTenant(ctx)
+	}
+	switch err {
+	case nil:
+		return nil
+	case store.ErrGroupNotFound:
+		return ErrGroupNotFound
+	case store.ErrDuplicateGroupName:
+		return ErrDuplicateGroupName
+	default:
+		return errors.Wrap(err, "useradm: failed to update group")
+	}
+}
+
+func (ua *UserAdm) DeleteGroup(ctx context.Context, id string) error {
+	err := ua.db.DeleteGroup(ctx, id)
+	ua.invalidateTenant(ctx)
+	if err == store.ErrGroupNotFound {
+		return ErrGroupNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete group")
+	}
+	return nil
+}
+
+func (ua *UserAdm) AddGroupMember(ctx context.Context, id, userID string) error {
+	user, err := ua.db.GetUserById(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get user")
+	} else if user == nil {
+		return ErrUserNotFound
+	}
+	err = ua.db.AddGroupMember(ctx, id, userID)
+	ua.verifyCache.InvalidateUser(userID)
+	if err == store.ErrGroupNotFound {
+		return ErrGroupNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to add group member")
+	}
+	return nil
+}
+
+func (ua *UserAdm) RemoveGroupMember(ctx context.Context, id, userID string) error {
+	err := ua.db.RemoveGroupMember(ctx, id, userID)
+	ua.verifyCache.InvalidateUser(userID)
+	if err == store.ErrGroupNotFound {
+		return ErrGroupNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to remove group member")
+	}
+	return nil
+}
+
+func (ua *UserAdm) GetUserGroups(ctx context.Context, userID string) ([]model.Group, error) {
+	user, err := ua.db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get user")
+	} else if user == nil {
+		return nil, ErrUserNotFound
+	}
+	groups, err := ua.db.GetGroupsByMember(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get groups")
+	}
+	return groups, nil
+}
@@ -0,0 +1,322 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+var testGroup = model.Group{
+	ID:    "1",
+	Name:  "operators",
+	Roles: []string{model.RoleReadOnly, testRole.Name},
+}
+
+func TestUserAdmGetGroups(t *testing.T) {
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetGroups", ctx).Return([]model.Group{testGroup}, nil).Once()
+	db.On("GetGroups", ctx).Return(nil, errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	groups, err := useradm.GetGroups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Group{testGroup}, groups)
+
+	_, err = useradm.GetGroups(ctx)
+	assert.EqualError(t, err, "useradm: failed to get groups: db error")
+}
+
+func TestUserAdmGetGroup(t *testing.T) {
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetGroup", ctx, "1").Return(&testGroup, nil).Once()
+	db.On("GetGroup", ctx, "2").Return(nil, nil).Once()
+	db.On("GetGroup", ctx, "3").Return(nil, errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	group, err := useradm.GetGroup(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &testGroup, group)
+
+	_, err = useradm.GetGroup(ctx, "2")
+	assert.Equal(t, ErrGroupNotFound, err)
+
+	_, err = useradm.GetGroup(ctx, "3")
+	assert.EqualError(t, err, "useradm: failed to get group: db error")
+}
+
+func TestUserAdmCreateGroup(t *testing.T) {
+	testCases := map[string]struct {
+		roles []string
+
+		dbRoles    []model.Role
+		callCreate bool
+		dbErr      error
+
+		outErr error
+	}{
+		"ok": {
+			roles:      testGroup.Roles,
+			dbRoles:    []model.Role{testRole},
+			callCreate: true,
+		},
+		"ok, no roles": {
+			callCreate: true,
+		},
+		"error: unknown role": {
+			roles:   testGroup.Roles,
+			dbRoles: []model.Role{},
+			outErr:  ErrUnknownRole,
+		},
+		"error: duplicate name": {
+			roles:      []string{model.RoleReadOnly},
+			callCreate: true,
+			dbErr:      store.ErrDuplicateGroupName,
+			outErr:     ErrDuplicateGroupName,
+		},
+		"error: db": {
+			roles:      []string{model.RoleReadOnly},
+			callCreate: true,
+			dbErr:      errors.New("db error"),
+			outErr:     errors.New("useradm: failed to create group: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.dbRoles != nil {
+				db.On("GetRolesByNames", ctx, []string{testRole.Name}).
+					Return(tc.dbRoles, nil)
+			}
+			if tc.callCreate {
+				db.On("CreateGroup", ctx, mock.MatchedBy(func(g *model.Group) bool {
+					return g.ID != "" && g.Name == testGroup.Name && g.Roles != nil
+				})).Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			group, err := useradm.CreateGroup(ctx, &model.GroupNew{
+				Name:  testGroup.Name,
+				Roles: tc.roles,
+			})
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testGroup.Name, group.Name)
+			}
+		})
+	}
+}
+
+func TestUserAdmUpdateGroup(t *testing.T) {
+	name := "ops"
+	roles := []string{model.RoleAdmin}
+	unknownRoles := []string{"foo"}
+
+	testCases := map[string]struct {
+		update *model.GroupUpdate
+
+		callDb bool
+		dbErr  error
+
+		outErr error
+	}{
+		"ok": {
+			update: &model.GroupUpdate{Name: &name, Roles: &roles},
+			callDb: true,
+		},
+		"error: unknown role": {
+			update: &model.GroupUpdate{Roles: &unknownRoles},
+			outErr: ErrUnknownRole,
+		},
+		"error: not found": {
+			update: &model.GroupUpdate{Name: &name},
+			callDb: true,
+			dbErr:  store.ErrGroupNotFound,
+			outErr: ErrGroupNotFound,
+		},
+		"error: duplicate name": {
+			update: &model.GroupUpdate{Name: &name},
+			callDb: true,
+			dbErr:  store.ErrDuplicateGroupName,
+			outErr: ErrDuplicateGroupName,
+		},
+		"error: db": {
+			update: &model.GroupUpdate{Name: &name},
+			callDb: true,
+			dbErr:  errors.New("db error"),
+			outErr: errors.New("useradm: failed to update group: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetRolesByNames", ctx, unknownRoles).Return([]model.Role{}, nil).Maybe()
+			if tc.callDb {
+				db.On("UpdateGroup", ctx, "1", tc.update).Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.UpdateGroup(ctx, "1", tc.update)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmDeleteGroup(t *testing.T) {
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("DeleteGroup", ctx, "1").Return(nil).Once()
+	db.On("DeleteGroup", ctx, "2").Return(store.ErrGroupNotFound).Once()
+	db.On("DeleteGroup", ctx, "3").Return(errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	assert.NoError(t, useradm.DeleteGroup(ctx, "1"))
+	assert.Equal(t, ErrGroupNotFound, useradm.DeleteGroup(ctx, "2"))
+	assert.EqualError(t, useradm.DeleteGroup(ctx, "3"),
+		"useradm: failed to delete group: db error")
+}
+
+func TestUserAdmAddGroupMember(t *testing.T) {
+	testCases := map[string]struct {
+		dbUser    *model.User
+		dbUserErr error
+
+		callAdd bool
+		dbErr   error
+
+		outErr error
+	}{
+		"ok": {
+			dbUser:  &model.User{ID: "2"},
+			callAdd: true,
+		},
+		"error: user not found": {
+			outErr: ErrUserNotFound,
+		},
+		"error: get user": {
+			dbUserErr: errors.New("db error"),
+			outErr:    errors.New("useradm: failed to get user: db error"),
+		},
+		"error: group not found": {
+			dbUser:  &model.User{ID: "2"},
+			callAdd: true,
+			dbErr:   store.ErrGroupNotFound,
+			outErr:  ErrGroupNotFound,
+		},
+		"error: db": {
+			dbUser:  &model.User{ID: "2"},
+			callAdd: true,
+			dbErr:   errors.New("db error"),
+			outErr:  errors.New("useradm: failed to add group member: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetUserById", ctx, "2").Return(tc.dbUser, tc.dbUserErr)
+			if tc.callAdd {
+				db.On("AddGroupMember", ctx, "1", "2").Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.AddGroupMember(ctx, "1", "2")
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmRemoveGroupMember(t *testing.T) {
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("RemoveGroupMember", ctx, "1", "2").Return(nil).Once()
+	db.On("RemoveGroupMember", ctx, "1", "2").Return(store.ErrGroupNotFound).Once()
+	db.On("RemoveGroupMember", ctx, "1", "2").Return(errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	assert.NoError(t, useradm.RemoveGroupMember(ctx, "1", "2"))
+	assert.Equal(t, ErrGroupNotFound, useradm.RemoveGroupMember(ctx, "1", "2"))
+	assert.EqualError(t, useradm.RemoveGroupMember(ctx, "1", "2"),
+		"useradm: failed to remove group member: db error")
+}
+
+func TestUserAdmGetUserGroups(t *testing.T) {
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserById", ctx, "2").Return(&model.User{ID: "2"}, nil).Twice()
+	db.On("GetUserById", ctx, "3").Return(nil, nil).Once()
+	db.On("GetGroupsByMember", ctx, "2").Return([]model.Group{testGroup}, nil).Once()
+	db.On("GetGroupsByMember", ctx, "2").Return(nil, errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	groups, err := useradm.GetUserGroups(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Group{testGroup}, groups)
+
+	_, err = useradm.GetUserGroups(ctx, "2")
+	assert.EqualError(t, err, "useradm: failed to get groups: db error")
+
+	_, err = useradm.GetUserGroups(ctx, "3")
+	assert.Equal(t, ErrUserNotFound, err)
+}
@@ -30,27 +30,1048 @@ type App struct {
 	mock.Mock
 }
 
+// AddGroupMember provides a mock function with given fields: ctx, id, userID
+func (_m *App) AddGroupMember(ctx context.Context, id string, userID string) error {
+	ret := _m.Called(ctx, id, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ApproveAction provides a mock function with given fields: ctx, id
+func (_m *App) ApproveAction(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ApproveElevation provides a mock function with given fields: ctx, id
+func (_m *App) ApproveElevation(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AuthenticateSCIM provides a mock function with given fields: ctx, token
+func (_m *App) AuthenticateSCIM(ctx context.Context, token string) (*model.SCIMToken, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 *model.SCIMToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.SCIMToken); ok {
+		r0 = rf(ctx, token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SCIMToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountUsers provides a mock function with given fields: ctx, fltr
+func (_m *App) CountUsers(ctx context.Context, fltr model.UserFilter) (int64, error) {
+	ret := _m.Called(ctx, fltr)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter) int64); ok {
+		r0 = rf(ctx, fltr)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserFilter) error); ok {
+		r1 = rf(ctx, fltr)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateGroup provides a mock function with given fields: ctx, group
+func (_m *App) CreateGroup(ctx context.Context, group *model.GroupNew) (*model.Group, error) {
+	ret := _m.Called(ctx, group)
+
+	var r0 *model.Group
+	if rf, ok := ret.Get(0).(func(context.Context, *model.GroupNew) *model.Group); ok {
+		r0 = rf(ctx, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.GroupNew) error); ok {
+		r1 = rf(ctx, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateRole provides a mock function with given fields: ctx, role
+func (_m *App) CreateRole(ctx context.Context, role *model.RoleNew) (*model.Role, error) {
+	ret := _m.Called(ctx, role)
+
+	var r0 *model.Role
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RoleNew) *model.Role); ok {
+		r0 = rf(ctx, role)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Role)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.RoleNew) error); ok {
+		r1 = rf(ctx, role)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateServiceAccount provides a mock function with given fields: ctx, sa
+func (_m *App) CreateServiceAccount(ctx context.Context, sa *model.ServiceAccountNew) (*model.ServiceAccountWithCredentials, error) {
+	ret := _m.Called(ctx, sa)
+
+	var r0 *model.ServiceAccountWithCredentials
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ServiceAccountNew) *model.ServiceAccountWithCredentials); ok {
+		r0 = rf(ctx, sa)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ServiceAccountWithCredentials)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ServiceAccountNew) error); ok {
+		r1 = rf(ctx, sa)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateTenant provides a mock function with given fields: ctx, tenant
 func (_m *App) CreateTenant(ctx context.Context, tenant model.NewTenant) error {
 	ret := _m.Called(ctx, tenant)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.NewTenant) error); ok {
-		r0 = rf(ctx, tenant)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.NewTenant) error); ok {
+		r0 = rf(ctx, tenant)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateUser provides a mock function with given fields: ctx, u
+func (_m *App) CreateUser(ctx context.Context, u *model.User) error {
+	ret := _m.Called(ctx, u)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.User) error); ok {
+		r0 = rf(ctx, u)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateUserInternal provides a mock function with given fields: ctx, u
+func (_m *App) CreateUserInternal(ctx context.Context, u *model.UserInternal) error {
+	ret := _m.Called(ctx, u)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserInternal) error); ok {
+		r0 = rf(ctx, u)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteGroup provides a mock function with given fields: ctx, id
+func (_m *App) DeleteGroup(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteOIDCConfig provides a mock function with given fields: ctx
+func (_m *App) DeleteOIDCConfig(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteRole provides a mock function with given fields: ctx, name
+func (_m *App) DeleteRole(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSAMLConfig provides a mock function with given fields: ctx
+func (_m *App) DeleteSAMLConfig(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSCIMToken provides a mock function with given fields: ctx
+func (_m *App) DeleteSCIMToken(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteServiceAccount provides a mock function with given fields: ctx, id
+func (_m *App) DeleteServiceAccount(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteServiceAccountToken provides a mock function with given fields: ctx, id, tokenID
+func (_m *App) DeleteServiceAccountToken(ctx context.Context, id string, tokenID string) error {
+	ret := _m.Called(ctx, id, tokenID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, tokenID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteToken provides a mock function with given fields: ctx, id
+func (_m *App) DeleteToken(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteTokens provides a mock function with given fields: ctx, tenantId, userId
+func (_m *App) DeleteTokens(ctx context.Context, tenantId string, userId string) error {
+	ret := _m.Called(ctx, tenantId, userId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, tenantId, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteUser provides a mock function with given fields: ctx, id
+func (_m *App) DeleteUser(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetApproval provides a mock function with given fields: ctx, id
+func (_m *App) GetApproval(ctx context.Context, id string) (*model.Approval, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Approval
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Approval); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Approval)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetApprovalSettings provides a mock function with given fields: ctx
+func (_m *App) GetApprovalSettings(ctx context.Context) (*model.ApprovalSettings, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.ApprovalSettings
+	if rf, ok := ret.Get(0).(func(context.Context) *model.ApprovalSettings); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ApprovalSettings)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetApprovals provides a mock function with given fields: ctx
+func (_m *App) GetApprovals(ctx context.Context) ([]model.Approval, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Approval
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Approval); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Approval)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetElevation provides a mock function with given fields: ctx, id
+func (_m *App) GetElevation(ctx context.Context, id string) (*model.Elevation, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Elevation
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Elevation); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Elevation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetElevations provides a mock function with given fields: ctx, userID
+func (_m *App) GetElevations(ctx context.Context, userID string) ([]model.Elevation, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []model.Elevation
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.Elevation); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Elevation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroup provides a mock function with given fields: ctx, id
+func (_m *App) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Group
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Group); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroups provides a mock function with given fields: ctx
+func (_m *App) GetGroups(ctx context.Context) ([]model.Group, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Group
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Group); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Group)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOIDCConfig provides a mock function with given fields: ctx
+func (_m *App) GetOIDCConfig(ctx context.Context) (*model.OIDCConfig, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.OIDCConfig
+	if rf, ok := ret.Get(0).(func(context.Context) *model.OIDCConfig); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.OIDCConfig)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPersonalAccessTokens provides a mock function with given fields: ctx, userID
+func (_m *App) GetPersonalAccessTokens(ctx context.Context, userID string) ([]model.PersonalAccessToken, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []model.PersonalAccessToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.PersonalAccessToken); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.PersonalAccessToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRevocationList provides a mock function with given fields: ctx, since
+func (_m *App) GetRevocationList(ctx context.Context, since int64) (string, error) {
+	ret := _m.Called(ctx, since)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, int64) string); ok {
+		r0 = rf(ctx, since)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRole provides a mock function with given fields: ctx, name
+func (_m *App) GetRole(ctx context.Context, name string) (*model.Role, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *model.Role
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Role); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Role)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRoles provides a mock function with given fields: ctx
+func (_m *App) GetRoles(ctx context.Context) ([]model.Role, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Role
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Role); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Role)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSAMLConfig provides a mock function with given fields: ctx
+func (_m *App) GetSAMLConfig(ctx context.Context) (*model.SAMLConfig, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.SAMLConfig
+	if rf, ok := ret.Get(0).(func(context.Context) *model.SAMLConfig); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SAMLConfig)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSAMLMetadata provides a mock function with given fields: ctx, sp
+func (_m *App) GetSAMLMetadata(ctx context.Context, sp *model.SAMLServiceProvider) ([]byte, error) {
+	ret := _m.Called(ctx, sp)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(context.Context, *model.SAMLServiceProvider) []byte); ok {
+		r0 = rf(ctx, sp)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.SAMLServiceProvider) error); ok {
+		r1 = rf(ctx, sp)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSCIMToken provides a mock function with given fields: ctx
+func (_m *App) GetSCIMToken(ctx context.Context) (*model.SCIMToken, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.SCIMToken
+	if rf, ok := ret.Get(0).(func(context.Context) *model.SCIMToken); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SCIMToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetServiceAccount provides a mock function with given fields: ctx, id
+func (_m *App) GetServiceAccount(ctx context.Context, id string) (*model.ServiceAccount, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.ServiceAccount
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ServiceAccount); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ServiceAccount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetServiceAccountTokens provides a mock function with given fields: ctx, id
+func (_m *App) GetServiceAccountTokens(ctx context.Context, id string) ([]model.PersonalAccessToken, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []model.PersonalAccessToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.PersonalAccessToken); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.PersonalAccessToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetServiceAccounts provides a mock function with given fields: ctx
+func (_m *App) GetServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.ServiceAccount
+	if rf, ok := ret.Get(0).(func(context.Context) []model.ServiceAccount); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ServiceAccount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUser provides a mock function with given fields: ctx, id
+func (_m *App) GetUser(ctx context.Context, id string) (*model.User, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserAttributeSchema provides a mock function with given fields: ctx
+func (_m *App) GetUserAttributeSchema(ctx context.Context) (*model.UserAttributeSchema, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.UserAttributeSchema
+	if rf, ok := ret.Get(0).(func(context.Context) *model.UserAttributeSchema); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAttributeSchema)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserGroups provides a mock function with given fields: ctx, userID
+func (_m *App) GetUserGroups(ctx context.Context, userID string) ([]model.Group, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []model.Group
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.Group); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Group)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserRoles provides a mock function with given fields: ctx, userID
+func (_m *App) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsers provides a mock function with given fields: ctx, fltr
+func (_m *App) GetUsers(ctx context.Context, fltr model.UserFilter) ([]model.User, error) {
+	ret := _m.Called(ctx, fltr)
+
+	var r0 []model.User
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter) []model.User); ok {
+		r0 = rf(ctx, fltr)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserFilter) error); ok {
+		r1 = rf(ctx, fltr)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HealthCheck provides a mock function with given fields: ctx
+func (_m *App) HealthCheck(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IntrospectToken provides a mock function with given fields: ctx, token
+func (_m *App) IntrospectToken(ctx context.Context, token *jwt.Token) (*model.TokenIntrospection, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 *model.TokenIntrospection
+	if rf, ok := ret.Get(0).(func(context.Context, *jwt.Token) *model.TokenIntrospection); ok {
+		r0 = rf(ctx, token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.TokenIntrospection)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *jwt.Token) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IssueClientCredentialsToken provides a mock function with given fields: ctx, req
+func (_m *App) IssueClientCredentialsToken(ctx context.Context, req *model.ClientCredentialsRequest) (*jwt.Token, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *jwt.Token
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ClientCredentialsRequest) *jwt.Token); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*jwt.Token)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ClientCredentialsRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IssuePersonalAccessToken provides a mock function with given fields: ctx, tr
+func (_m *App) IssuePersonalAccessToken(ctx context.Context, tr *model.TokenRequest) (string, error) {
+	ret := _m.Called(ctx, tr)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, *model.TokenRequest) string); ok {
+		r0 = rf(ctx, tr)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.TokenRequest) error); ok {
+		r1 = rf(ctx, tr)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IssueServiceAccountToken provides a mock function with given fields: ctx, id, tr
+func (_m *App) IssueServiceAccountToken(ctx context.Context, id string, tr *model.TokenRequest) (string, error) {
+	ret := _m.Called(ctx, id, tr)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.TokenRequest) string); ok {
+		r0 = rf(ctx, id, tr)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *model.TokenRequest) error); ok {
+		r1 = rf(ctx, id, tr)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Login provides a mock function with given fields: ctx, email, pass
+func (_m *App) Login(ctx context.Context, email model.Email, pass string) (*jwt.Token, error) {
+	ret := _m.Called(ctx, email, pass)
+
+	var r0 *jwt.Token
+	if rf, ok := ret.Get(0).(func(context.Context, model.Email, string) *jwt.Token); ok {
+		r0 = rf(ctx, email, pass)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*jwt.Token)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Email, string) error); ok {
+		r1 = rf(ctx, email, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginOIDC provides a mock function with given fields: ctx, rp, code, state, request
+func (_m *App) LoginOIDC(ctx context.Context, rp *model.OIDCRelyingParty, code string, state string, request *model.OIDCAuthRequest) (*jwt.Token, error) {
+	ret := _m.Called(ctx, rp, code, state, request)
+
+	var r0 *jwt.Token
+	if rf, ok := ret.Get(0).(func(context.Context, *model.OIDCRelyingParty, string, string, *model.OIDCAuthRequest) *jwt.Token); ok {
+		r0 = rf(ctx, rp, code, state, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*jwt.Token)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.OIDCRelyingParty, string, string, *model.OIDCAuthRequest) error); ok {
+		r1 = rf(ctx, rp, code, state, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginSAML provides a mock function with given fields: ctx, sp, response, requestIDs
+func (_m *App) LoginSAML(ctx context.Context, sp *model.SAMLServiceProvider, response []byte, requestIDs []string) (*jwt.Token, error) {
+	ret := _m.Called(ctx, sp, response, requestIDs)
+
+	var r0 *jwt.Token
+	if rf, ok := ret.Get(0).(func(context.Context, *model.SAMLServiceProvider, []byte, []string) *jwt.Token); ok {
+		r0 = rf(ctx, sp, response, requestIDs)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*jwt.Token)
+		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.SAMLServiceProvider, []byte, []string) error); ok {
+		r1 = rf(ctx, sp, response, requestIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// CreateUser provides a mock function with given fields: ctx, u
-func (_m *App) CreateUser(ctx context.Context, u *model.User) error {
-	ret := _m.Called(ctx, u)
+// Logout provides a mock function with given fields: ctx, token
+func (_m *App) Logout(ctx context.Context, token *jwt.Token) error {
+	ret := _m.Called(ctx, token)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *model.User) error); ok {
-		r0 = rf(ctx, u)
+	if rf, ok := ret.Get(0).(func(context.Context, *jwt.Token) error); ok {
+		r0 = rf(ctx, token)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -58,13 +1079,82 @@ func (_m *App) CreateUser(ctx context.Context, u *model.User) error {
 	return r0
 }
 
-// CreateUserInternal provides a mock function with given fields: ctx, u
-func (_m *App) CreateUserInternal(ctx context.Context, u *model.UserInternal) error {
-	ret := _m.Called(ctx, u)
+// MakeOIDCAuthRequest provides a mock function with given fields: ctx, rp, redirect
+func (_m *App) MakeOIDCAuthRequest(ctx context.Context, rp *model.OIDCRelyingParty, redirect string) (*model.OIDCAuthRequest, error) {
+	ret := _m.Called(ctx, rp, redirect)
+
+	var r0 *model.OIDCAuthRequest
+	if rf, ok := ret.Get(0).(func(context.Context, *model.OIDCRelyingParty, string) *model.OIDCAuthRequest); ok {
+		r0 = rf(ctx, rp, redirect)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.OIDCAuthRequest)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.OIDCRelyingParty, string) error); ok {
+		r1 = rf(ctx, rp, redirect)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MakeSAMLAuthnRequest provides a mock function with given fields: ctx, sp, relayState
+func (_m *App) MakeSAMLAuthnRequest(ctx context.Context, sp *model.SAMLServiceProvider, relayState string) (*model.SAMLAuthnRequest, error) {
+	ret := _m.Called(ctx, sp, relayState)
+
+	var r0 *model.SAMLAuthnRequest
+	if rf, ok := ret.Get(0).(func(context.Context, *model.SAMLServiceProvider, string) *model.SAMLAuthnRequest); ok {
+		r0 = rf(ctx, sp, relayState)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SAMLAuthnRequest)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.SAMLServiceProvider, string) error); ok {
+		r1 = rf(ctx, sp, relayState)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewSCIMToken provides a mock function with given fields: ctx, req
+func (_m *App) NewSCIMToken(ctx context.Context, req *model.SCIMTokenRequest) (*model.SCIMToken, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *model.SCIMToken
+	if rf, ok := ret.Get(0).(func(context.Context, *model.SCIMTokenRequest) *model.SCIMToken); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SCIMToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.SCIMTokenRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeletedUsers provides a mock function with given fields: ctx
+func (_m *App) PurgeDeletedUsers(ctx context.Context) error {
+	ret := _m.Called(ctx)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *model.UserInternal) error); ok {
-		r0 = rf(ctx, u)
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -72,8 +1162,8 @@ func (_m *App) CreateUserInternal(ctx context.Context, u *model.UserInternal) er
 	return r0
 }
 
-// DeleteToken provides a mock function with given fields: ctx, id
-func (_m *App) DeleteToken(ctx context.Context, id string) error {
+// PurgeUser provides a mock function with given fields: ctx, id
+func (_m *App) PurgeUser(ctx context.Context, id string) error {
 	ret := _m.Called(ctx, id)
 
 	var r0 error
@@ -86,13 +1176,13 @@ func (_m *App) DeleteToken(ctx context.Context, id string) error {
 	return r0
 }
 
-// DeleteTokens provides a mock function with given fields: ctx, tenantId, userId
-func (_m *App) DeleteTokens(ctx context.Context, tenantId string, userId string) error {
-	ret := _m.Called(ctx, tenantId, userId)
+// RejectAction provides a mock function with given fields: ctx, id
+func (_m *App) RejectAction(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
-		r0 = rf(ctx, tenantId, userId)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -100,8 +1190,8 @@ func (_m *App) DeleteTokens(ctx context.Context, tenantId string, userId string)
 	return r0
 }
 
-// DeleteUser provides a mock function with given fields: ctx, id
-func (_m *App) DeleteUser(ctx context.Context, id string) error {
+// RejectElevation provides a mock function with given fields: ctx, id
+func (_m *App) RejectElevation(ctx context.Context, id string) error {
 	ret := _m.Called(ctx, id)
 
 	var r0 error
@@ -114,45 +1204,36 @@ func (_m *App) DeleteUser(ctx context.Context, id string) error {
 	return r0
 }
 
-// GetPersonalAccessTokens provides a mock function with given fields: ctx, userID
-func (_m *App) GetPersonalAccessTokens(ctx context.Context, userID string) ([]model.PersonalAccessToken, error) {
-	ret := _m.Called(ctx, userID)
-
-	var r0 []model.PersonalAccessToken
-	if rf, ok := ret.Get(0).(func(context.Context, string) []model.PersonalAccessToken); ok {
-		r0 = rf(ctx, userID)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.PersonalAccessToken)
-		}
-	}
+// RemoveGroupMember provides a mock function with given fields: ctx, id, userID
+func (_m *App) RemoveGroupMember(ctx context.Context, id string, userID string) error {
+	ret := _m.Called(ctx, id, userID)
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, userID)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// GetUser provides a mock function with given fields: ctx, id
-func (_m *App) GetUser(ctx context.Context, id string) (*model.User, error) {
-	ret := _m.Called(ctx, id)
+// RequestApproval provides a mock function with given fields: ctx, request
+func (_m *App) RequestApproval(ctx context.Context, request *model.ApprovalRequest) (*model.Approval, error) {
+	ret := _m.Called(ctx, request)
 
-	var r0 *model.User
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
-		r0 = rf(ctx, id)
+	var r0 *model.Approval
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ApprovalRequest) *model.Approval); ok {
+		r0 = rf(ctx, request)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.User)
+			r0 = ret.Get(0).(*model.Approval)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ApprovalRequest) error); ok {
+		r1 = rf(ctx, request)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -160,22 +1241,22 @@ func (_m *App) GetUser(ctx context.Context, id string) (*model.User, error) {
 	return r0, r1
 }
 
-// GetUsers provides a mock function with given fields: ctx, fltr
-func (_m *App) GetUsers(ctx context.Context, fltr model.UserFilter) ([]model.User, error) {
-	ret := _m.Called(ctx, fltr)
+// RequestElevation provides a mock function with given fields: ctx, elevation
+func (_m *App) RequestElevation(ctx context.Context, elevation *model.ElevationNew) (*model.Elevation, error) {
+	ret := _m.Called(ctx, elevation)
 
-	var r0 []model.User
-	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter) []model.User); ok {
-		r0 = rf(ctx, fltr)
+	var r0 *model.Elevation
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ElevationNew) *model.Elevation); ok {
+		r0 = rf(ctx, elevation)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.User)
+			r0 = ret.Get(0).(*model.Elevation)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, model.UserFilter) error); ok {
-		r1 = rf(ctx, fltr)
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ElevationNew) error); ok {
+		r1 = rf(ctx, elevation)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -183,13 +1264,13 @@ func (_m *App) GetUsers(ctx context.Context, fltr model.UserFilter) ([]model.Use
 	return r0, r1
 }
 
-// HealthCheck provides a mock function with given fields: ctx
-func (_m *App) HealthCheck(ctx context.Context) error {
-	ret := _m.Called(ctx)
+// RestoreUser provides a mock function with given fields: ctx, id
+func (_m *App) RestoreUser(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -197,20 +1278,22 @@ func (_m *App) HealthCheck(ctx context.Context) error {
 	return r0
 }
 
-// IssuePersonalAccessToken provides a mock function with given fields: ctx, tr
-func (_m *App) IssuePersonalAccessToken(ctx context.Context, tr *model.TokenRequest) (string, error) {
-	ret := _m.Called(ctx, tr)
+// RotateServiceAccountSecret provides a mock function with given fields: ctx, id
+func (_m *App) RotateServiceAccountSecret(ctx context.Context, id string) (*model.ServiceAccountCredentials, error) {
+	ret := _m.Called(ctx, id)
 
-	var r0 string
-	if rf, ok := ret.Get(0).(func(context.Context, *model.TokenRequest) string); ok {
-		r0 = rf(ctx, tr)
+	var r0 *model.ServiceAccountCredentials
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ServiceAccountCredentials); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r0 = ret.Get(0).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ServiceAccountCredentials)
+		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *model.TokenRequest) error); ok {
-		r1 = rf(ctx, tr)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -218,35 +1301,54 @@ func (_m *App) IssuePersonalAccessToken(ctx context.Context, tr *model.TokenRequ
 	return r0, r1
 }
 
-// Login provides a mock function with given fields: ctx, email, pass
-func (_m *App) Login(ctx context.Context, email model.Email, pass string) (*jwt.Token, error) {
-	ret := _m.Called(ctx, email, pass)
+// SaveApprovalSettings provides a mock function with given fields: ctx, settings
+func (_m *App) SaveApprovalSettings(ctx context.Context, settings *model.ApprovalSettings) error {
+	ret := _m.Called(ctx, settings)
 
-	var r0 *jwt.Token
-	if rf, ok := ret.Get(0).(func(context.Context, model.Email, string) *jwt.Token); ok {
-		r0 = rf(ctx, email, pass)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ApprovalSettings) error); ok {
+		r0 = rf(ctx, settings)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*jwt.Token)
-		}
+		r0 = ret.Error(0)
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, model.Email, string) error); ok {
-		r1 = rf(ctx, email, pass)
+	return r0
+}
+
+// SaveOIDCConfig provides a mock function with given fields: ctx, config
+func (_m *App) SaveOIDCConfig(ctx context.Context, config *model.OIDCConfig) error {
+	ret := _m.Called(ctx, config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.OIDCConfig) error); ok {
+		r0 = rf(ctx, config)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// Logout provides a mock function with given fields: ctx, token
-func (_m *App) Logout(ctx context.Context, token *jwt.Token) error {
+// SaveSAMLConfig provides a mock function with given fields: ctx, config
+func (_m *App) SaveSAMLConfig(ctx context.Context, config *model.SAMLConfig) error {
+	ret := _m.Called(ctx, config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.SAMLConfig) error); ok {
+		r0 = rf(ctx, config)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveSCIMToken provides a mock function with given fields: ctx, token
+func (_m *App) SaveSCIMToken(ctx context.Context, token *model.SCIMToken) error {
 	ret := _m.Called(ctx, token)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *jwt.Token) error); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, *model.SCIMToken) error); ok {
 		r0 = rf(ctx, token)
 	} else {
 		r0 = ret.Error(0)
@@ -255,6 +1357,20 @@ func (_m *App) Logout(ctx context.Context, token *jwt.Token) error {
 	return r0
 }
 
+// SaveUserAttributeSchema provides a mock function with given fields: ctx, schema
+func (_m *App) SaveUserAttributeSchema(ctx context.Context, schema *model.UserAttributeSchema) error {
+	ret := _m.Called(ctx, schema)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserAttributeSchema) error); ok {
+		r0 = rf(ctx, schema)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetPassword provides a mock function with given fields: ctx, u
 func (_m *App) SetPassword(ctx context.Context, u model.UserUpdate) error {
 	ret := _m.Called(ctx, u)
@@ -269,6 +1385,34 @@ func (_m *App) SetPassword(ctx context.Context, u model.UserUpdate) error {
 	return r0
 }
 
+// SetUserRoles provides a mock function with given fields: ctx, userID, roles
+func (_m *App) SetUserRoles(ctx context.Context, userID string, roles []string) error {
+	ret := _m.Called(ctx, userID, roles)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, userID, roles)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUserStatus provides a mock function with given fields: ctx, id, status
+func (_m *App) SetUserStatus(ctx context.Context, id string, status *model.UserStatusUpdate) error {
+	ret := _m.Called(ctx, id, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.UserStatusUpdate) error); ok {
+		r0 = rf(ctx, id, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SignToken provides a mock function with given fields: ctx, t
 func (_m *App) SignToken(ctx context.Context, t *jwt.Token) (string, error) {
 	ret := _m.Called(ctx, t)
@@ -290,6 +1434,48 @@ func (_m *App) SignToken(ctx context.Context, t *jwt.Token) (string, error) {
 	return r0, r1
 }
 
+// UpdateGroup provides a mock function with given fields: ctx, id, group
+func (_m *App) UpdateGroup(ctx context.Context, id string, group *model.GroupUpdate) error {
+	ret := _m.Called(ctx, id, group)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.GroupUpdate) error); ok {
+		r0 = rf(ctx, id, group)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateRole provides a mock function with given fields: ctx, name, role
+func (_m *App) UpdateRole(ctx context.Context, name string, role *model.RoleUpdate) error {
+	ret := _m.Called(ctx, name, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.RoleUpdate) error); ok {
+		r0 = rf(ctx, name, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateServiceAccount provides a mock function with given fields: ctx, id, sa
+func (_m *App) UpdateServiceAccount(ctx context.Context, id string, sa *model.ServiceAccountUpdate) error {
+	ret := _m.Called(ctx, id, sa)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.ServiceAccountUpdate) error); ok {
+		r0 = rf(ctx, id, sa)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateUser provides a mock function with given fields: ctx, id, u
 func (_m *App) UpdateUser(ctx context.Context, id string, u *model.UserUpdate) error {
 	ret := _m.Called(ctx, id, u)
@@ -305,15 +1491,24 @@ func (_m *App) UpdateUser(ctx context.Context, id string, u *model.UserUpdate) e
 }
 
 // Verify provides a mock function with given fields: ctx, token
-func (_m *App) Verify(ctx context.Context, token *jwt.Token) error {
+func (_m *App) Verify(ctx context.Context, token *jwt.Token) (*model.TokenIdentity, error) {
 	ret := _m.Called(ctx, token)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *jwt.Token) error); ok {
+	var r0 *model.TokenIdentity
+	if rf, ok := ret.Get(0).(func(context.Context, *jwt.Token) *model.TokenIdentity); ok {
 		r0 = rf(ctx, token)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.TokenIdentity)
+		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *jwt.Token) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
@@ -0,0 +1,287 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+)
+
+const (
+	oidcDefaultEmailClaim = "email"
+	oidcHTTPTimeout       = 10 * time.Second
+)
+
+var (
+	ErrOIDCNotConfigured     = errors.New("OpenID Connect single sign-on is not configured")
+	ErrOIDCInvalidConfig     = errors.New("invalid OpenID Connect configuration")
+	ErrPasswordLoginDisabled = errors.New("password login is disabled, " +
+		"log in through single sign-on")
+
+	// the identity provider is discovered for each login, so that
+	// the configuration changes take effect immediately
+	oidcHTTPClient = &http.Client{Timeout: oidcHTTPTimeout}
+)
+
+func (ua *UserAdm) GetOIDCConfig(ctx context.Context) (*model.OIDCConfig, error) {
+	config, err := ua.db.GetOIDCConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get OIDC configuration")
+	} else if config == nil {
+		return nil, ErrOIDCNotConfigured
+	}
+	return config, nil
+}
+
+// SaveOIDCConfig checks that the identity provider can be discovered and
+// that the provisioning roles exist, and saves the configuration.
+func (ua *UserAdm) SaveOIDCConfig(ctx context.Context, config *model.OIDCConfig) error {
+	if err := ua.checkRoles(ctx, config.ProvisioningRoles); err != nil {
+		return err
+	}
+	if _, err := discoverOIDCProvider(ctx, config); err != nil {
+		log.FromContext(ctx).Warnf("invalid OIDC configuration: %s", err.Error())
+		return ErrOIDCInvalidConfig
+	}
+	if err := ua.db.SaveOIDCConfig(ctx, config); err != nil {
+		return errors.Wrap(err, "useradm: failed to save OIDC configuration")
+	}
+	return nil
+}
+
+func (ua *UserAdm) DeleteOIDCConfig(ctx context.Context) error {
+	if err := ua.db.DeleteOIDCConfig(ctx); err != nil {
+		return errors.Wrap(err, "useradm: failed to delete OIDC configuration")
+	}
+	return nil
+}
+
+// MakeOIDCAuthRequest starts the single sign-on with an authorization code
+// request protected by PKCE; the returned request must be kept by the user
+// agent and passed to LoginOIDC.
+func (ua *UserAdm) MakeOIDCAuthRequest(
+	ctx context.Context,
+	rp *model.OIDCRelyingParty,
+	redirect string,
+) (*model.OIDCAuthRequest, error) {
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: ua.ssoTenant(rp.TenantID)})
+	config, err := ua.GetOIDCConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := discoverOIDCProvider(ctx, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to discover the identity provider")
+	}
+
+	request := &model.OIDCAuthRequest{Redirect: redirect}
+	for _, value := range []*string{
+		&request.State, &request.Nonce, &request.CodeVerifier,
+	} {
+		if *value, err = randomValue(); err != nil {
+			return nil, err
+		}
+	}
+	challenge := sha256.Sum256([]byte(request.CodeVerifier))
+	request.URL = newOAuth2Config(rp, config, provider).AuthCodeURL(
+		request.State,
+		oidc.Nonce(request.Nonce),
+		oauth2.SetAuthURLParam("code_challenge",
+			base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	return request, nil
+}
+
+// LoginOIDC exchanges the authorization code for the ID token, validates
+// it against the keys of the identity provider and issues the session
+// token of the user, as Login does; users logging in for the first time
+// are created without a password.
+func (ua *UserAdm) LoginOIDC(
+	ctx context.Context,
+	rp *model.OIDCRelyingParty,
+	code string,
+	state string,
+	request *model.OIDCAuthRequest,
+) (*jwt.Token, error) {
+	l := log.FromContext(ctx)
+
+	if request == nil || code == "" || subtle.ConstantTimeCompare(
+		[]byte(state), []byte(request.State)) != 1 {
+		l.Warnf("OIDC login: the state does not match the request")
+		return nil, ErrUnauthorized
+	}
+
+	tenantID := ua.ssoTenant(rp.TenantID)
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+	config, err := ua.GetOIDCConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := discoverOIDCProvider(ctx, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to discover the identity provider")
+	}
+
+	token, err := newOAuth2Config(rp, config, provider).Exchange(
+		oidc.ClientContext(ctx, oidcHTTPClient), code,
+		oauth2.SetAuthURLParam("code_verifier", request.CodeVerifier))
+	if _, ok := err.(*oauth2.RetrieveError); ok {
+		l.Warnf("OIDC login: code exchange failed: %s", err.Error())
+		return nil, ErrUnauthorized
+	} else if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to exchange the authorization code")
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		l.Warnf("OIDC login: the token response does not include an ID token")
+		return nil, ErrUnauthorized
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.ClientID}).
+		Verify(oidc.ClientContext(ctx, oidcHTTPClient), rawIDToken)
+	if err != nil {
+		l.Warnf("OIDC login: invalid ID token: %s", err.Error())
+		return nil, ErrUnauthorized
+	}
+	if subtle.ConstantTimeCompare(
+		[]byte(idToken.Nonce), []byte(request.Nonce)) != 1 {
+		l.Warnf("OIDC login: the nonce does not match the request")
+		return nil, ErrUnauthorized
+	}
+	email, err := idTokenEmail(idToken, config)
+	if err != nil {
+		l.Warnf("OIDC login: %s", err.Error())
+		return nil, ErrUnauthorized
+	}
+
+	if ua.verifyTenant {
+		tenant, err := ua.cTenant.GetTenant(ctx, string(email), ua.clientGetter())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to check user's tenant")
+		}
+		// unknown users are provisioned in the tenant
+		if tenant != nil && tenant.ID != tenantID {
+			return nil, ErrUnauthorized
+		}
+		if tenant != nil && tenant.Status == TenantStatusSuspended {
+			return nil, ErrTenantAccountSuspended
+		}
+	}
+
+	user, err := ua.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get user")
+	} else if user == nil {
+		user = &model.User{
+			Email: email,
+			Roles: config.ProvisioningRoles,
+		}
+		// the provisioned users are not required the custom attributes
+		if err := ua.doCreateUser(ctx, user, true); err != nil {
+			return nil, errors.Wrap(err, "useradm: failed to provision user")
+		}
+		l.Infof("OIDC login: provisioned user %s", user.ID)
+	}
+
+	return ua.issueLoginToken(ctx, user, tenantID)
+}
+
+// checkPasswordLogin checks that the tenant allows the users to log in
+// with their passwords.
+func (ua *UserAdm) checkPasswordLogin(ctx context.Context) error {
+	config, err := ua.db.GetOIDCConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get OIDC configuration")
+	}
+	if config != nil && config.DisablePasswordLogin {
+		return ErrPasswordLoginDisabled
+	}
+	return nil
+}
+
+func discoverOIDCProvider(
+	ctx context.Context,
+	config *model.OIDCConfig,
+) (*oidc.Provider, error) {
+	return oidc.NewProvider(oidc.ClientContext(ctx, oidcHTTPClient), config.Issuer)
+}
+
+func newOAuth2Config(
+	rp *model.OIDCRelyingParty,
+	config *model.OIDCConfig,
+	provider *oidc.Provider,
+) *oauth2.Config {
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email"}
+	} else {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+	return &oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  rp.RedirectURL,
+		Scopes:       scopes,
+	}
+}
+
+// idTokenEmail returns the verified email of the user from the configured
+// claim of the ID token.
+func idTokenEmail(idToken *oidc.IDToken, config *model.OIDCConfig) (model.Email, error) {
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return "", errors.Wrap(err, "failed to decode the ID token claims")
+	}
+	claim := config.EmailClaim
+	if claim == "" {
+		claim = oidcDefaultEmailClaim
+	}
+	value, _ := claims[claim].(string)
+	if value == "" {
+		return "", errors.Errorf("the ID token does not carry the %q claim", claim)
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return "", errors.New("the email is not verified")
+	}
+	email := model.Email(strings.ToLower(value))
+	if err := email.Validate(); err != nil {
+		return "", errors.Wrap(err, "invalid email")
+	}
+	return email, nil
+}
+
+func randomValue() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "useradm: failed to generate random value")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,509 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/apiclient"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	ct "github.com/mendersoftware/useradm/client/tenant"
+	mct "github.com/mendersoftware/useradm/client/tenant/mocks"
+	"github.com/mendersoftware/useradm/model"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+var testOIDCRelyingParty = &model.OIDCRelyingParty{
+	TenantID:    "tenant",
+	RedirectURL: "https://mender.example.com/sso/oidc/tenant/callback",
+}
+
+func TestUserAdmSaveOIDCConfig(t *testing.T) {
+	provider, err := mtesting.NewOIDCProvider("client", "secret")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer provider.Close()
+
+	testCases := map[string]struct {
+		config *model.OIDCConfig
+
+		dbRoles []model.Role
+		dbErr   error
+
+		outErr error
+	}{
+		"ok": {
+			config: &model.OIDCConfig{
+				Issuer:   provider.Issuer(),
+				ClientID: "client",
+			},
+		},
+		"ok, custom role": {
+			config: &model.OIDCConfig{
+				Issuer:            provider.Issuer(),
+				ClientID:          "client",
+				ProvisioningRoles: []string{"operator"},
+			},
+			dbRoles: []model.Role{{Name: "operator"}},
+		},
+		"error: unknown role": {
+			config: &model.OIDCConfig{
+				Issuer:            provider.Issuer(),
+				ClientID:          "client",
+				ProvisioningRoles: []string{"operator"},
+			},
+
+			outErr: ErrUnknownRole,
+		},
+		"error: issuer mismatch": {
+			config: &model.OIDCConfig{
+				Issuer:   provider.Issuer() + "/",
+				ClientID: "client",
+			},
+
+			outErr: ErrOIDCInvalidConfig,
+		},
+		"error: not an identity provider": {
+			config: &model.OIDCConfig{
+				Issuer:   provider.Issuer() + "/realms/other",
+				ClientID: "client",
+			},
+
+			outErr: ErrOIDCInvalidConfig,
+		},
+		"error: db": {
+			config: &model.OIDCConfig{
+				Issuer:   provider.Issuer(),
+				ClientID: "client",
+			},
+			dbErr: errors.New("db error"),
+
+			outErr: errors.New(
+				"useradm: failed to save OIDC configuration: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if len(tc.config.ProvisioningRoles) > 0 {
+				db.On("GetRolesByNames", ctx, tc.config.ProvisioningRoles).
+					Return(tc.dbRoles, nil)
+			}
+			if tc.outErr != ErrOIDCInvalidConfig && tc.outErr != ErrUnknownRole {
+				db.On("SaveOIDCConfig", ctx, tc.config).Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.SaveOIDCConfig(ctx, tc.config)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmMakeOIDCAuthRequest(t *testing.T) {
+	provider, err := mtesting.NewOIDCProvider("client", "secret")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer provider.Close()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetOIDCConfig", tenantMatcher("")).Return(&model.OIDCConfig{
+		Issuer:   provider.Issuer(),
+		ClientID: "client",
+	}, nil).Once()
+	db.On("GetOIDCConfig", tenantMatcher("")).Return(&model.OIDCConfig{
+		Issuer:   provider.Issuer(),
+		ClientID: "client",
+		Scopes:   []string{"email", "profile"},
+	}, nil).Once()
+	db.On("GetOIDCConfig", tenantMatcher("")).Return(nil, nil).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	for _, scope := range []string{"openid email", "openid email profile"} {
+		request, err := useradm.MakeOIDCAuthRequest(context.Background(),
+			testOIDCRelyingParty, "/ui/")
+		assert.NoError(t, err)
+		if !assert.NotNil(t, request) {
+			continue
+		}
+		assert.Equal(t, "/ui/", request.Redirect)
+		assert.NotEmpty(t, request.State)
+		assert.NotEmpty(t, request.Nonce)
+		assert.NotEmpty(t, request.CodeVerifier)
+
+		authURL, err := url.Parse(request.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, provider.Issuer()+"/authorize",
+			authURL.Scheme+"://"+authURL.Host+authURL.Path)
+		challenge := sha256.Sum256([]byte(request.CodeVerifier))
+		assert.Equal(t, url.Values{
+			"response_type":         []string{"code"},
+			"client_id":             []string{"client"},
+			"redirect_uri":          []string{testOIDCRelyingParty.RedirectURL},
+			"scope":                 []string{scope},
+			"state":                 []string{request.State},
+			"nonce":                 []string{request.Nonce},
+			"code_challenge_method": []string{"S256"},
+			"code_challenge": []string{
+				base64.RawURLEncoding.EncodeToString(challenge[:]),
+			},
+		}, authURL.Query())
+	}
+
+	_, err = useradm.MakeOIDCAuthRequest(context.Background(),
+		testOIDCRelyingParty, "")
+	assert.Equal(t, ErrOIDCNotConfigured, err)
+
+	// the tenant in the path is only used in the multi-tenant setup
+	db.On("GetOIDCConfig", tenantMatcher("tenant")).Return(nil, nil).Once()
+	_, err = useradm.WithTenantVerification(&mct.ClientRunner{}).
+		MakeOIDCAuthRequest(context.Background(), testOIDCRelyingParty, "")
+	assert.Equal(t, ErrOIDCNotConfigured, err)
+}
+
+func TestUserAdmLoginOIDC(t *testing.T) {
+	provider, err := mtesting.NewOIDCProvider("client", "secret")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer provider.Close()
+
+	config := &model.OIDCConfig{
+		Issuer:       provider.Issuer(),
+		ClientID:     "client",
+		ClientSecret: "secret",
+	}
+	user := &model.User{
+		ID:    "2b7c5d4e-6f1a-4b8c-9d0e-1f2a3b4c5d6e",
+		Email: "user@example.com",
+	}
+
+	testCases := map[string]struct {
+		config    *model.OIDCConfig
+		configErr error
+
+		claims map[string]interface{}
+		// tamper with the flow
+		modify func(request *model.OIDCAuthRequest, code, state *string)
+
+		verifyTenant bool
+		tenant       *ct.Tenant
+
+		dbUser       *model.User
+		createUser   bool
+		createErr    error
+		createdRoles []string
+
+		outErr error
+	}{
+		"ok": {
+			config: config,
+			claims: map[string]interface{}{
+				"email":          "User@example.com",
+				"email_verified": true,
+			},
+			dbUser: user,
+		},
+		"ok, email claim": {
+			config: &model.OIDCConfig{
+				Issuer:       provider.Issuer(),
+				ClientID:     "client",
+				ClientSecret: "secret",
+				EmailClaim:   "upn",
+			},
+			claims: map[string]interface{}{
+				"email": "other@example.com",
+				"upn":   "user@example.com",
+			},
+			dbUser: user,
+		},
+		"ok, provisioned": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			createUser:   true,
+			createdRoles: []string{model.RoleReadOnly},
+		},
+		"ok, provisioned with roles": {
+			config: &model.OIDCConfig{
+				Issuer:            provider.Issuer(),
+				ClientID:          "client",
+				ClientSecret:      "secret",
+				ProvisioningRoles: []string{model.RoleReadOnly},
+			},
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			createUser:   true,
+			createdRoles: []string{model.RoleReadOnly},
+		},
+		"ok, tenant": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			verifyTenant: true,
+			tenant:       &ct.Tenant{ID: "tenant"},
+			dbUser:       user,
+		},
+		"error: not configured": {
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+
+			outErr: ErrOIDCNotConfigured,
+		},
+		"error: db": {
+			configErr: errors.New("db error"),
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+
+			outErr: errors.New(
+				"useradm: failed to get OIDC configuration: db error"),
+		},
+		"error: state mismatch": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			modify: func(_ *model.OIDCAuthRequest, _, state *string) {
+				*state = "forged"
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: no code": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			modify: func(_ *model.OIDCAuthRequest, code, _ *string) {
+				*code = ""
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: wrong code verifier": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			modify: func(request *model.OIDCAuthRequest, _, _ *string) {
+				request.CodeVerifier = "forged"
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: wrong nonce": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			modify: func(request *model.OIDCAuthRequest, _, _ *string) {
+				request.Nonce = "forged"
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: wrong client secret": {
+			config: &model.OIDCConfig{
+				Issuer:       provider.Issuer(),
+				ClientID:     "client",
+				ClientSecret: "wrong",
+			},
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: ID token for another client": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+				"aud":   "other",
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: ID token expired": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+				"exp":   time.Now().Add(-time.Minute).Unix(),
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: no email": {
+			config: config,
+			claims: map[string]interface{}{},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: email not verified": {
+			config: config,
+			claims: map[string]interface{}{
+				"email":          "user@example.com",
+				"email_verified": false,
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: invalid email": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "jdoe",
+			},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: another tenant's user": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			verifyTenant: true,
+			tenant:       &ct.Tenant{ID: "other"},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: tenant suspended": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			verifyTenant: true,
+			tenant: &ct.Tenant{
+				ID:     "tenant",
+				Status: TenantStatusSuspended,
+			},
+
+			outErr: ErrTenantAccountSuspended,
+		},
+		"error: provisioning": {
+			config: config,
+			claims: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			createUser:   true,
+			createErr:    errors.New("db error"),
+			createdRoles: []string{model.RoleReadOnly},
+
+			outErr: errors.New("useradm: failed to provision user: " +
+				"useradm: failed to create user in the db: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// the tenant in the path is ignored in the single-tenant setup
+			tenantID := ""
+			if tc.verifyTenant {
+				tenantID = "tenant"
+			}
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+
+			// the request is made with a valid configuration
+			db.On("GetOIDCConfig", tenantMatcher("")).
+				Return(config, nil).Once()
+			request, err := NewUserAdm(nil, db, Config{}).MakeOIDCAuthRequest(
+				context.Background(), testOIDCRelyingParty, "")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			code, state, err := provider.Authorize(request.URL, tc.claims)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if tc.modify != nil {
+				tc.modify(request, &code, &state)
+			}
+
+			db.On("GetOIDCConfig", tenantMatcher(tenantID)).
+				Return(tc.config, tc.configErr).Maybe()
+			db.On("GetUserByEmail", tenantMatcher(tenantID),
+				model.Email("user@example.com")).
+				Return(tc.dbUser, nil).Maybe()
+			if tc.createUser {
+				db.On("CreateUser", tenantMatcher(tenantID),
+					mock.MatchedBy(func(u *model.User) bool {
+						return u.Email == "user@example.com" &&
+							u.Password == "" &&
+							assert.ObjectsAreEqual(tc.createdRoles, u.Roles)
+					})).
+					Return(tc.createErr)
+			}
+			if tc.dbUser != nil || tc.createUser && tc.createErr == nil {
+				db.On("GetActiveElevations", tenantMatcher(tenantID),
+					mock.AnythingOfType("string")).Return(nil, nil)
+				db.On("SaveToken", tenantMatcher(tenantID),
+					mock.AnythingOfType("*jwt.Token")).Return(nil)
+				db.On("UpdateLoginTs", tenantMatcher(tenantID),
+					mock.AnythingOfType("string")).Return(nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{
+				Issuer:         "mender",
+				ExpirationTime: 10,
+			})
+			if tc.verifyTenant {
+				cTenant := &mct.ClientRunner{}
+				cTenant.On("GetTenant", tenantMatcher(tenantID),
+					"user@example.com", &apiclient.HttpApi{}).
+					Return(tc.tenant, nil)
+				useradm = useradm.WithTenantVerification(cTenant)
+			}
+
+			token, err := useradm.LoginOIDC(context.Background(),
+				testOIDCRelyingParty, code, state, request)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, token)
+			} else if assert.NoError(t, err) {
+				if tc.dbUser != nil {
+					assert.Equal(t, tc.dbUser.ID, token.Claims.Subject.String())
+				}
+				assert.Equal(t, tenantID, token.Claims.Tenant)
+				assert.True(t, token.Claims.User)
+			}
+		})
+	}
+}
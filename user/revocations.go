@@ -0,0 +1,86 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/jwt"
+)
+
+const (
+	// revocationSettleTime bounds the time between the allocation of
+	// the version of a revocation and its insertion; a missing version
+	// allocated earlier is considered skipped, rather than in flight.
+	revocationSettleTime = 30 * time.Second
+)
+
+func (ua *UserAdm) GetRevocationList(ctx context.Context, since int64) (string, error) {
+	// read the version first: the versions allocated before are
+	// settled if the last allocation is
+	ver, err := ua.db.GetRevocationVersion(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "useradm: failed to get revocation version")
+	}
+	var current int64
+	if ver != nil {
+		current = ver.Version
+	}
+	if since < 0 || since > current {
+		// unknown version: resend the full list
+		since = 0
+	}
+	revoked, err := ua.db.GetRevokedTokens(ctx, since)
+	if err != nil {
+		return "", errors.Wrap(err, "useradm: failed to get revoked tokens")
+	}
+
+	now := time.Now()
+	settled := now.Add(-revocationSettleTime)
+	list := &jwt.RevocationList{
+		Issuer:   ua.getConfig().Issuer,
+		IssuedAt: jwt.Time{Time: now},
+		Version:  since,
+		Since:    since,
+		Revoked:  make([]jwt.RevokedToken, 0, len(revoked)),
+	}
+	// advance the version while no revocation may still be in flight;
+	// the revocations past the version are sent again in the next delta
+	inFlight := false
+	for _, r := range revoked {
+		if !inFlight && (r.Version == list.Version+1 || r.RevokedAt.Before(settled)) {
+			list.Version = r.Version
+		} else {
+			inFlight = true
+		}
+		list.Revoked = append(list.Revoked, jwt.RevokedToken{
+			ID:        r.ID,
+			ExpiresAt: jwt.Time{Time: r.ExpiresAt},
+		})
+	}
+	if ver != nil && ver.UpdatedAt.Before(settled) && current > list.Version {
+		// the revocations since have expired, or were skipped
+		list.Version = current
+	}
+
+	signed, err := ua.jwtHandler.SignRevocationList(ctx, list)
+	if err != nil {
+		return "", errors.Wrap(err, "useradm: failed to sign revocation list")
+	}
+	return signed, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/keys"
+	"github.com/mendersoftware/useradm/model"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+func TestUserAdmGetRevocationList(t *testing.T) {
+	privKey, err := keys.LoadRSAPrivate("../crypto/private.pem")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	old := now.Add(-time.Hour)
+	exp := time.Unix(now.Add(time.Hour).Unix(), 0)
+	revoked := func(version int64, revokedAt time.Time) model.RevokedToken {
+		return model.RevokedToken{
+			ID:        oid.NewUUIDv5(string(rune('a' + version))),
+			ExpiresAt: exp,
+			Version:   version,
+			RevokedAt: revokedAt,
+		}
+	}
+
+	testCases := map[string]struct {
+		since int64
+
+		dbVersion    *model.RevocationVersion
+		dbVersionErr error
+
+		dbSince   int64
+		dbRevoked []model.RevokedToken
+		dbErr     error
+
+		version int64
+		listed  []int64
+		err     error
+	}{
+		"ok, never revoked": {
+			dbRevoked: []model.RevokedToken{},
+		},
+		"ok, full list": {
+			dbVersion: &model.RevocationVersion{Version: 2, UpdatedAt: now},
+			dbRevoked: []model.RevokedToken{revoked(1, now), revoked(2, now)},
+
+			version: 2,
+			listed:  []int64{1, 2},
+		},
+		"ok, delta": {
+			since:     3,
+			dbVersion: &model.RevocationVersion{Version: 5, UpdatedAt: now},
+			dbSince:   3,
+			dbRevoked: []model.RevokedToken{revoked(4, now), revoked(5, now)},
+
+			version: 5,
+			listed:  []int64{4, 5},
+		},
+		"ok, revocation in flight": {
+			since:     3,
+			dbVersion: &model.RevocationVersion{Version: 6, UpdatedAt: now},
+			dbSince:   3,
+			dbRevoked: []model.RevokedToken{revoked(4, now), revoked(6, now)},
+
+			version: 4,
+			listed:  []int64{4, 6},
+		},
+		"ok, revocation skipped": {
+			since:     3,
+			dbVersion: &model.RevocationVersion{Version: 7, UpdatedAt: now},
+			dbSince:   3,
+			dbRevoked: []model.RevokedToken{revoked(4, now), revoked(6, old), revoked(7, now)},
+
+			version: 7,
+			listed:  []int64{4, 6, 7},
+		},
+		"ok, revocations expired": {
+			since:     3,
+			dbVersion: &model.RevocationVersion{Version: 10, UpdatedAt: old},
+			dbSince:   3,
+			dbRevoked: []model.RevokedToken{},
+
+			version: 10,
+		},
+		"ok, unknown version": {
+			since:     20,
+			dbVersion: &model.RevocationVersion{Version: 2, UpdatedAt: old},
+			dbRevoked: []model.RevokedToken{revoked(2, old)},
+
+			version: 2,
+			listed:  []int64{2},
+		},
+		"error, version": {
+			dbVersionErr: errors.New("db error"),
+
+			err: errors.New("useradm: failed to get revocation version: db error"),
+		},
+		"error, revoked tokens": {
+			dbErr: errors.New("db error"),
+
+			err: errors.New("useradm: failed to get revoked tokens: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetRevocationVersion", ctx).
+				Return(tc.dbVersion, tc.dbVersionErr)
+			if tc.dbVersionErr == nil {
+				db.On("GetRevokedTokens", ctx, tc.dbSince).
+					Return(tc.dbRevoked, tc.dbErr)
+			}
+
+			useradm := NewUserAdm(jwt.NewJWTHandlerRS256(privKey, nil), db,
+				Config{Issuer: "mender"})
+
+			signed, err := useradm.GetRevocationList(ctx, tc.since)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+				return
+			}
+			assert.NoError(t, err)
+
+			list, err := jwt.ParseRevocationList(signed, &privKey.PublicKey)
+			assert.NoError(t, err)
+			assert.Equal(t, "mender", list.Issuer)
+			assert.Equal(t, tc.dbSince, list.Since)
+			assert.Equal(t, tc.version, list.Version)
+			listed := []jwt.RevokedToken{}
+			for _, version := range tc.listed {
+				r := revoked(version, now)
+				listed = append(listed, jwt.RevokedToken{
+					ID:        r.ID,
+					ExpiresAt: jwt.Time{Time: exp},
+				})
+			}
+			assert.Equal(t, listed, list.Revoked)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/authz"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrDuplicateRoleName = errors.New("role with a given name already exists")
+	ErrBuiltInRole       = errors.New("built-in roles cannot be modified")
+	ErrUnknownRole       = errors.New("unknown role")
+	ErrRoleNotPermitted  = errors.New("not permitted to assign the role")
+)
+
+func (ua *UserAdm) GetRoles(ctx context.Context) ([]model.Role, error) {
+	roles, err := ua.db.GetRoles(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get roles")
+	}
+	return append(model.BuiltInRoles(), roles...), nil
+}
+
+func (ua *UserAdm) GetRole(ctx context.Context, name string) (*model.Role, error) {
+	if role := model.BuiltInRole(name); role != nil {
+		return role, nil
+	}
+	role, err := ua.db.GetRole(ctx, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get role")
+	} else if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (ua *UserAdm) CreateRole(ctx context.Context, roleNew *model.RoleNew) (*model.Role, error) {
+	role := &model.Role{
+		Name:        roleNew.Name,
+		Description: roleNew.Description,
+		Permissions: roleNew.Permissions,
+	}
+	err := ua.db.CreateRole(ctx, role)
+	if err == store.ErrDuplicateRoleName {
+		return nil, ErrDuplicateRoleName
+	} else if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to create role")
+	}
+	return role, nil
+}
+
+func (ua *UserAdm) UpdateRole(ctx context.Context, name string, role *model.RoleUpdate) error {
+	if model.BuiltInRole(name) != nil {
+		return ErrBuiltInRole
+	}
+	err := ua.db.UpdateRole(ctx, name, role)
+	// the permissions of the users with the role change
+	ua.invalidateTenant(ctx)
+	if err == store.ErrRoleNotFound {
+		return ErrRoleNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to update role")
+	}
+	return nil
+}
+
+func (ua *UserAdm) DeleteRole(ctx context.Context, name string) error {
+	if model.BuiltInRole(name) != nil {
+		return ErrBuiltInRole
+	}
+	err := ua.db.DeleteRole(ctx, name)
+	if err == store.ErrRoleNotFound {
+		return ErrRoleNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete role")
+	}
+
+	// unassign the role; the users and the groups are left with their
+	// other roles
+	err = ua.db.RemoveRoleFromUsers(ctx, name)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to unassign role")
+	}
+	err = ua.db.RemoveRoleFromGroups(ctx, name)
+	ua.invalidateTenant(ctx)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to unassign role from groups")
+	}
+	return nil
+}
+
+func (ua *UserAdm) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	user, err := ua.db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get user")
+	} else if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Roles == nil {
+		return []string{}, nil
+	}
+	return user.Roles, nil
+}
+
+func (ua *UserAdm) SetUserRoles(ctx context.Context, userID string, roles []string) error {
+	if err := ua.checkRoles(ctx, roles); err != nil {
+		return err
+	}
+	err := ua.db.SetUserRoles(ctx, userID, roles)
+	ua.verifyCache.InvalidateUser(userID)
+	if err == store.ErrUserNotFound {
+		return ErrUserNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to update user roles")
+	}
+	return nil
+}
+
+// checkAssignableRoles verifies that the calling user holds the roles to
+// assign, or is an admin, so that the users cannot grant more than they
+// have; the callers other than users, e.g. the command line, may assign
+// any role.
+func (ua *UserAdm) checkAssignableRoles(ctx context.Context, roles []string) error {
+	id := identity.FromContext(ctx)
+	if id == nil || !id.IsUser {
+		return nil
+	}
+	own, err := authz.UserEffectiveRoles(ctx, ua.db, id.Subject)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get roles")
+	}
+	held := make(map[string]bool, len(own))
+	for _, name := range own {
+		if name == model.RoleAdmin {
+			return nil
+		}
+		held[name] = true
+	}
+	for _, name := range roles {
+		if !held[name] {
+			return ErrRoleNotPermitted
+		}
+	}
+	return nil
+}
+
+// checkRoles verifies that all the roles exist
+func (ua *UserAdm) checkRoles(ctx context.Context, roles []string) error {
+	custom := make([]string, 0, len(roles))
+	for _, name := range roles {
+		if model.BuiltInRole(name) == nil {
+			custom = append(custom, name)
+		}
+	}
+	if len(custom) == 0 {
+		return nil
+	}
+	found, err := ua.db.GetRolesByNames(ctx, custom)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get roles")
+	}
+	for _, name := range custom {
+		known := false
+		for _, role := range found {
+			if role.Name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return ErrUnknownRole
+		}
+	}
+	return nil
+}
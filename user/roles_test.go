@@ -0,0 +1,496 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+var testRole = model.Role{
+	Name:        "device-operator",
+	Description: "remote terminal",
+	Permissions: []model.Permission{
+		{Resource: "deviceconnect:*", Methods: []string{model.MethodAny}},
+	},
+}
+
+func TestUserAdmGetRoles(t *testing.T) {
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetRoles", ctx).Return([]model.Role{testRole}, nil).Once()
+	db.On("GetRoles", ctx).Return(nil, errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	roles, err := useradm.GetRoles(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, append(model.BuiltInRoles(), testRole), roles)
+
+	_, err = useradm.GetRoles(ctx)
+	assert.EqualError(t, err, "useradm: failed to get roles: db error")
+}
+
+func TestUserAdmGetRole(t *testing.T) {
+	testCases := map[string]struct {
+		name string
+
+		callDb bool
+		dbRole *model.Role
+		dbErr  error
+
+		outRole *model.Role
+		outErr  error
+	}{
+		"ok, built-in": {
+			name:    model.RoleAdmin,
+			outRole: model.BuiltInRole(model.RoleAdmin),
+		},
+		"ok, custom": {
+			name:    testRole.Name,
+			callDb:  true,
+			dbRole:  &testRole,
+			outRole: &testRole,
+		},
+		"error: not found": {
+			name:   "foo",
+			callDb: true,
+			outErr: ErrRoleNotFound,
+		},
+		"error: db": {
+			name:   "foo",
+			callDb: true,
+			dbErr:  errors.New("db error"),
+			outErr: errors.New("useradm: failed to get role: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.callDb {
+				db.On("GetRole", ctx, tc.name).Return(tc.dbRole, tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			role, err := useradm.GetRole(ctx, tc.name)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.outRole, role)
+			}
+		})
+	}
+}
+
+func TestUserAdmCreateRole(t *testing.T) {
+	testCases := map[string]struct {
+		dbErr error
+
+		outErr error
+	}{
+		"ok": {},
+		"error: duplicate name": {
+			dbErr:  store.ErrDuplicateRoleName,
+			outErr: ErrDuplicateRoleName,
+		},
+		"error: db": {
+			dbErr:  errors.New("db error"),
+			outErr: errors.New("useradm: failed to create role: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("CreateRole", ctx, &model.Role{
+				Name:        testRole.Name,
+				Description: testRole.Description,
+				Permissions: testRole.Permissions,
+			}).Return(tc.dbErr)
+
+			useradm := NewUserAdm(nil, db, Config{})
+			role, err := useradm.CreateRole(ctx, &model.RoleNew{
+				Name:        testRole.Name,
+				Description: testRole.Description,
+				Permissions: testRole.Permissions,
+			})
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, role)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testRole.Name, role.Name)
+			}
+		})
+	}
+}
+
+func TestUserAdmUpdateRole(t *testing.T) {
+	description := "foo"
+	testCases := map[string]struct {
+		name string
+
+		callDb bool
+		dbErr  error
+
+		outErr error
+	}{
+		"ok": {
+			name:   testRole.Name,
+			callDb: true,
+		},
+		"error: built-in": {
+			name:   model.RoleReadOnly,
+			outErr: ErrBuiltInRole,
+		},
+		"error: not found": {
+			name:   testRole.Name,
+			callDb: true,
+			dbErr:  store.ErrRoleNotFound,
+			outErr: ErrRoleNotFound,
+		},
+		"error: db": {
+			name:   testRole.Name,
+			callDb: true,
+			dbErr:  errors.New("db error"),
+			outErr: errors.New("useradm: failed to update role: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			update := &model.RoleUpdate{Description: &description}
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.callDb {
+				db.On("UpdateRole", ctx, tc.name, update).Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.UpdateRole(ctx, tc.name, update)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmDeleteRole(t *testing.T) {
+	testCases := map[string]struct {
+		name string
+
+		callDb       bool
+		dbErr        error
+		callUnassign bool
+		unassignErr  error
+		callGroups   bool
+		groupsErr    error
+
+		outErr error
+	}{
+		"ok": {
+			name:         testRole.Name,
+			callDb:       true,
+			callUnassign: true,
+			callGroups:   true,
+		},
+		"error: built-in": {
+			name:   model.RoleAdmin,
+			outErr: ErrBuiltInRole,
+		},
+		"error: not found": {
+			name:   testRole.Name,
+			callDb: true,
+			dbErr:  store.ErrRoleNotFound,
+			outErr: ErrRoleNotFound,
+		},
+		"error: db": {
+			name:   testRole.Name,
+			callDb: true,
+			dbErr:  errors.New("db error"),
+			outErr: errors.New("useradm: failed to delete role: db error"),
+		},
+		"error: unassign": {
+			name:         testRole.Name,
+			callDb:       true,
+			callUnassign: true,
+			unassignErr:  errors.New("db error"),
+			outErr:       errors.New("useradm: failed to unassign role: db error"),
+		},
+		"error: unassign from groups": {
+			name:         testRole.Name,
+			callDb:       true,
+			callUnassign: true,
+			callGroups:   true,
+			groupsErr:    errors.New("db error"),
+			outErr: errors.New(
+				"useradm: failed to unassign role from groups: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.callDb {
+				db.On("DeleteRole", ctx, tc.name).Return(tc.dbErr)
+			}
+			if tc.callUnassign {
+				db.On("RemoveRoleFromUsers", ctx, tc.name).Return(tc.unassignErr)
+			}
+			if tc.callGroups {
+				db.On("RemoveRoleFromGroups", ctx, tc.name).Return(tc.groupsErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.DeleteRole(ctx, tc.name)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmGetUserRoles(t *testing.T) {
+	testCases := map[string]struct {
+		dbUser *model.User
+		dbErr  error
+
+		outRoles []string
+		outErr   error
+	}{
+		"ok": {
+			dbUser:   &model.User{ID: "1", Roles: []string{model.RoleReadOnly}},
+			outRoles: []string{model.RoleReadOnly},
+		},
+		"ok, no roles": {
+			dbUser:   &model.User{ID: "1"},
+			outRoles: []string{},
+		},
+		"error: not found": {
+			outErr: ErrUserNotFound,
+		},
+		"error: db": {
+			dbErr:  errors.New("db error"),
+			outErr: errors.New("useradm: failed to get user: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetUserById", ctx, "1").Return(tc.dbUser, tc.dbErr)
+
+			useradm := NewUserAdm(nil, db, Config{})
+			roles, err := useradm.GetUserRoles(ctx, "1")
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.outRoles, roles)
+			}
+		})
+	}
+}
+
+func TestUserAdmSetUserRoles(t *testing.T) {
+	testCases := map[string]struct {
+		roles []string
+
+		callGetRoles bool
+		dbRoles      []model.Role
+		dbRolesErr   error
+
+		callDb bool
+		dbErr  error
+
+		outErr error
+	}{
+		"ok, built-in": {
+			roles:  []string{model.RoleReadOnly, model.RoleReleaseManager},
+			callDb: true,
+		},
+		"ok, custom": {
+			roles:        []string{model.RoleReadOnly, testRole.Name},
+			callGetRoles: true,
+			dbRoles:      []model.Role{testRole},
+			callDb:       true,
+		},
+		"error: unknown role": {
+			roles:        []string{model.RoleReadOnly, "foo"},
+			callGetRoles: true,
+			dbRoles:      []model.Role{},
+			outErr:       ErrUnknownRole,
+		},
+		"error: get roles": {
+			roles:        []string{"foo"},
+			callGetRoles: true,
+			dbRolesErr:   errors.New("db error"),
+			outErr:       errors.New("useradm: failed to get roles: db error"),
+		},
+		"error: user not found": {
+			roles:  []string{model.RoleAdmin},
+			callDb: true,
+			dbErr:  store.ErrUserNotFound,
+			outErr: ErrUserNotFound,
+		},
+		"error: db": {
+			roles:  []string{model.RoleAdmin},
+			callDb: true,
+			dbErr:  errors.New("db error"),
+			outErr: errors.New("useradm: failed to update user roles: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.callGetRoles {
+				db.On("GetRolesByNames", ctx, mock.AnythingOfType("[]string")).
+					Return(tc.dbRoles, tc.dbRolesErr)
+			}
+			if tc.callDb {
+				db.On("SetUserRoles", ctx, "1", tc.roles).Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.SetUserRoles(ctx, "1", tc.roles)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmCreateUserRoles(t *testing.T) {
+	callerCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: "caller",
+		IsUser:  true,
+	})
+
+	testCases := map[string]struct {
+		ctx   context.Context
+		roles []string
+
+		callerRoles  []string
+		callerGroups []model.Group
+
+		outErr error
+	}{
+		"ok, admin assigns admin": {
+			ctx:         callerCtx,
+			roles:       []string{model.RoleAdmin},
+			callerRoles: []string{model.RoleAdmin},
+		},
+		"ok, assigns own role": {
+			ctx:         callerCtx,
+			roles:       []string{model.RoleReleaseManager},
+			callerRoles: []string{model.RoleReadOnly, model.RoleReleaseManager},
+		},
+		"ok, admin through a group": {
+			ctx:         callerCtx,
+			roles:       []string{model.RoleAdmin},
+			callerRoles: []string{model.RoleReadOnly},
+			callerGroups: []model.Group{
+				{ID: "group-1", Roles: []string{model.RoleAdmin}},
+			},
+		},
+		"ok, not a user": {
+			ctx:   context.Background(),
+			roles: []string{model.RoleAdmin},
+		},
+		"error: assigns admin": {
+			ctx:         callerCtx,
+			roles:       []string{model.RoleAdmin},
+			callerRoles: []string{model.RoleReadOnly},
+			outErr:      ErrRoleNotPermitted,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetUserAttributeSchema", tc.ctx).
+				Return((*model.UserAttributeSchema)(nil), nil)
+			if tc.callerRoles != nil {
+				db.On("GetUserById", tc.ctx, "caller").
+					Return(&model.User{ID: "caller", Roles: tc.callerRoles}, nil)
+				db.On("GetGroupsByMember", tc.ctx, "caller").
+					Return(tc.callerGroups, nil)
+				db.On("GetActiveElevations", tc.ctx, "caller").
+					Return([]model.Elevation{}, nil)
+			}
+			if tc.outErr == nil {
+				db.On("CreateUser", tc.ctx, mock.MatchedBy(func(u *model.User) bool {
+					return assert.ObjectsAreEqual(tc.roles, u.Roles)
+				})).Return(nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.CreateUser(tc.ctx, &model.User{
+				Email: "foo@acme.com",
+				Roles: tc.roles,
+			})
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
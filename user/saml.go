@@ -0,0 +1,337 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+)
+
+var (
+	ErrSAMLNotConfigured = errors.New("SAML single sign-on is not configured")
+	ErrSAMLInvalidConfig = errors.New("invalid SAML configuration")
+)
+
+func (ua *UserAdm) GetSAMLConfig(ctx context.Context) (*model.SAMLConfig, error) {
+	config, err := ua.db.GetSAMLConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get SAML configuration")
+	} else if config == nil {
+		return nil, ErrSAMLNotConfigured
+	}
+	return config, nil
+}
+
+// SaveSAMLConfig checks that the identity provider metadata can be used
+// for the single sign-on and saves the configuration.
+func (ua *UserAdm) SaveSAMLConfig(ctx context.Context, config *model.SAMLConfig) error {
+	if _, err := parseIdPMetadata(config); err != nil {
+		log.FromContext(ctx).Warnf("invalid SAML configuration: %s", err.Error())
+		return ErrSAMLInvalidConfig
+	}
+	if err := ua.db.SaveSAMLConfig(ctx, config); err != nil {
+		return errors.Wrap(err, "useradm: failed to save SAML configuration")
+	}
+	return nil
+}
+
+func (ua *UserAdm) DeleteSAMLConfig(ctx context.Context) error {
+	if err := ua.db.DeleteSAMLConfig(ctx); err != nil {
+		return errors.Wrap(err, "useradm: failed to delete SAML configuration")
+	}
+	return nil
+}
+
+// GetSAMLMetadata returns the XML metadata of the service provider, to be
+// registered with the tenant's identity provider.
+func (ua *UserAdm) GetSAMLMetadata(
+	ctx context.Context,
+	sp *model.SAMLServiceProvider,
+) ([]byte, error) {
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: ua.ssoTenant(sp.TenantID)})
+	config, err := ua.db.GetSAMLConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get SAML configuration")
+	}
+	provider, err := newServiceProvider(sp, config, nil)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := xml.MarshalIndent(provider.Metadata(), "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to marshal SAML metadata")
+	}
+	return metadata, nil
+}
+
+// MakeSAMLAuthnRequest starts the single sign-on, returning the request
+// to redirect the browser to the tenant's identity provider with.
+func (ua *UserAdm) MakeSAMLAuthnRequest(
+	ctx context.Context,
+	sp *model.SAMLServiceProvider,
+	relayState string,
+) (*model.SAMLAuthnRequest, error) {
+	provider, _, err := ua.getServiceProvider(ctx, sp)
+	if err != nil {
+		return nil, err
+	}
+	location := provider.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if location == "" {
+		return nil, ErrSAMLInvalidConfig
+	}
+	request, err := provider.MakeAuthenticationRequest(
+		location, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to make SAML request")
+	}
+	redirect, err := request.Redirect(url.QueryEscape(relayState), provider)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to make SAML request")
+	}
+	return &model.SAMLAuthnRequest{
+		ID:  request.ID,
+		URL: redirect.String(),
+	}, nil
+}
+
+// LoginSAML validates the signed response of the identity provider and
+// issues the session token of the user it asserts, as Login does;
+// requestIDs are the IDs of the authentication requests the response
+// can answer.
+func (ua *UserAdm) LoginSAML(
+	ctx context.Context,
+	sp *model.SAMLServiceProvider,
+	response []byte,
+	requestIDs []string,
+) (*jwt.Token, error) {
+	l := log.FromContext(ctx)
+
+	tenantID := ua.ssoTenant(sp.TenantID)
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+	provider, config, err := ua.getServiceProvider(ctx, sp)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, err := provider.ParseXMLResponse(response, requestIDs)
+	if err != nil {
+		if rerr, ok := err.(*saml.InvalidResponseError); ok {
+			err = rerr.PrivateErr
+		}
+		l.Warnf("SAML login: invalid response: %s", err.Error())
+		return nil, ErrUnauthorized
+	}
+	// a captured response must not log the user in again
+	err = ua.db.SaveSAMLAssertionID(ctx, assertion.ID, assertionExpiry(assertion))
+	if err == store.ErrSAMLAssertionReplayed {
+		l.Warnf("SAML login: assertion %s replayed", assertion.ID)
+		return nil, ErrUnauthorized
+	} else if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to save SAML assertion ID")
+	}
+	email := assertionEmail(assertion, config.AttributeMapping)
+	if email == "" {
+		l.Warnf("SAML login: the assertion does not carry the user's email")
+		return nil, ErrUnauthorized
+	}
+
+	if ua.verifyTenant {
+		tenant, err := ua.cTenant.GetTenant(ctx, email, ua.clientGetter())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to check user's tenant")
+		}
+		if tenant == nil || tenant.ID != tenantID {
+			return nil, ErrUnauthorized
+		}
+		if tenant.Status == TenantStatusSuspended {
+			return nil, ErrTenantAccountSuspended
+		}
+	}
+
+	user, err := ua.db.GetUserByEmail(ctx, model.Email(email))
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get user")
+	} else if user == nil {
+		return nil, ErrUnauthorized
+	}
+
+	return ua.issueLoginToken(ctx, user, tenantID)
+}
+
+// getServiceProvider sets up the service provider with the tenant's
+// configuration
+func (ua *UserAdm) getServiceProvider(
+	ctx context.Context,
+	sp *model.SAMLServiceProvider,
+) (*saml.ServiceProvider, *model.SAMLConfig, error) {
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: ua.ssoTenant(sp.TenantID)})
+	config, err := ua.GetSAMLConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	idp, err := parseIdPMetadata(config)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "useradm: invalid SAML configuration")
+	}
+	provider, err := newServiceProvider(sp, config, idp)
+	return provider, config, err
+}
+
+// ssoTenant returns the tenant the single sign-on endpoints act for;
+// without the tenant verification there are no tenants, and the tenant ID
+// in the endpoint path is ignored.
+func (ua *UserAdm) ssoTenant(tenantID string) string {
+	if !ua.verifyTenant {
+		return ""
+	}
+	return tenantID
+}
+
+func newServiceProvider(
+	sp *model.SAMLServiceProvider,
+	config *model.SAMLConfig,
+	idp *saml.EntityDescriptor,
+) (*saml.ServiceProvider, error) {
+	metadataURL, err := url.Parse(sp.MetadataURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: invalid SAML metadata URL")
+	}
+	acsURL, err := url.Parse(sp.ACSURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: invalid SAML ACS URL")
+	}
+	provider := &saml.ServiceProvider{
+		MetadataURL: *metadataURL,
+		AcsURL:      *acsURL,
+		IDPMetadata: idp,
+		// without an attribute mapping the user is identified
+		// by the subject's NameID
+		AuthnNameIDFormat: saml.EmailAddressNameIDFormat,
+	}
+	if config != nil {
+		provider.AllowIDPInitiated = config.AllowIdPInitiated
+		if config.AttributeMapping.Email != "" {
+			provider.AuthnNameIDFormat = saml.UnspecifiedNameIDFormat
+		}
+	}
+	return provider, nil
+}
+
+// parseIdPMetadata parses the identity provider metadata, replacing its
+// signing certificates with the configured one, if any.
+func parseIdPMetadata(config *model.SAMLConfig) (*saml.EntityDescriptor, error) {
+	idp, err := samlsp.ParseMetadata([]byte(config.IdPMetadata))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the identity provider metadata")
+	}
+	if len(idp.IDPSSODescriptors) == 0 {
+		return nil, errors.New("the metadata does not describe an identity provider")
+	}
+
+	if config.IdPCertificate != "" {
+		block, _ := pem.Decode([]byte(config.IdPCertificate))
+		if block == nil {
+			return nil, errors.New("failed to decode the certificate")
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, errors.Wrap(err, "failed to parse the certificate")
+		}
+		keyDescriptors := []saml.KeyDescriptor{{
+			Use: "signing",
+			KeyInfo: saml.KeyInfo{
+				X509Data: saml.X509Data{
+					X509Certificates: []saml.X509Certificate{{
+						Data: base64.StdEncoding.EncodeToString(block.Bytes),
+					}},
+				},
+			},
+		}}
+		for i := range idp.IDPSSODescriptors {
+			idp.IDPSSODescriptors[i].KeyDescriptors = keyDescriptors
+		}
+	} else if !hasSigningCertificate(idp) {
+		return nil, errors.New("the metadata does not include a signing certificate")
+	}
+
+	return idp, nil
+}
+
+func hasSigningCertificate(idp *saml.EntityDescriptor) bool {
+	for _, descriptor := range idp.IDPSSODescriptors {
+		for _, key := range descriptor.KeyDescriptors {
+			if (key.Use == "" || key.Use == "signing") &&
+				len(key.KeyInfo.X509Data.X509Certificates) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// assertionExpiry returns the time after which the service provider no
+// longer accepts the assertion: the latest of its validity bounds, with
+// the clock skew the service provider allows.
+func assertionExpiry(assertion *saml.Assertion) time.Time {
+	expiry := assertion.IssueInstant.Add(saml.MaxIssueDelay)
+	if assertion.Conditions != nil &&
+		assertion.Conditions.NotOnOrAfter.Add(saml.MaxClockSkew).After(expiry) {
+		expiry = assertion.Conditions.NotOnOrAfter.Add(saml.MaxClockSkew)
+	}
+	if assertion.Subject != nil {
+		for _, confirmation := range assertion.Subject.SubjectConfirmations {
+			data := confirmation.SubjectConfirmationData
+			if data != nil && data.NotOnOrAfter.Add(saml.MaxClockSkew).After(expiry) {
+				expiry = data.NotOnOrAfter.Add(saml.MaxClockSkew)
+			}
+		}
+	}
+	return expiry
+}
+
+// assertionEmail returns the email of the asserted user: the value of the
+// mapped attribute, or the subject's NameID.
+func assertionEmail(assertion *saml.Assertion, mapping model.SAMLAttributeMapping) string {
+	if mapping.Email == "" {
+		if assertion.Subject == nil || assertion.Subject.NameID == nil {
+			return ""
+		}
+		return strings.ToLower(assertion.Subject.NameID.Value)
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if (attr.Name == mapping.Email || attr.FriendlyName == mapping.Email) &&
+				len(attr.Values) > 0 {
+				return strings.ToLower(attr.Values[0].Value)
+			}
+		}
+	}
+	return ""
+}
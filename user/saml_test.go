@@ -0,0 +1,496 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/mendersoftware/go-lib-micro/apiclient"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	ct "github.com/mendersoftware/useradm/client/tenant"
+	mct "github.com/mendersoftware/useradm/client/tenant/mocks"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+var testSAMLServiceProvider = &model.SAMLServiceProvider{
+	TenantID:    "tenant",
+	MetadataURL: "https://mender.example.com/sso/saml/tenant/metadata",
+	ACSURL:      "https://mender.example.com/sso/saml/tenant/acs",
+}
+
+func tenantMatcher(tenantID string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		id := identity.FromContext(ctx)
+		return id != nil && id.Tenant == tenantID
+	})
+}
+
+func TestUserAdmSaveSAMLConfig(t *testing.T) {
+	idp, err := mtesting.NewSAMLIdP()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// metadata without the signing certificate
+	var bare saml.EntityDescriptor
+	assert.NoError(t, xml.Unmarshal([]byte(idp.Metadata()), &bare))
+	bare.IDPSSODescriptors[0].KeyDescriptors = nil
+	bareMetadata, _ := xml.Marshal(bare)
+
+	testCases := map[string]struct {
+		config *model.SAMLConfig
+		dbErr  error
+
+		outErr error
+	}{
+		"ok": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+		},
+		"ok, certificate": {
+			config: &model.SAMLConfig{
+				IdPMetadata:    string(bareMetadata),
+				IdPCertificate: idp.Certificate(),
+			},
+		},
+		"error: no certificate": {
+			config: &model.SAMLConfig{
+				IdPMetadata: string(bareMetadata),
+			},
+			outErr: ErrSAMLInvalidConfig,
+		},
+		"error: not a certificate": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+				IdPCertificate: "-----BEGIN CERTIFICATE-----\n" +
+					"MIIBszCCAV2gAwIBAgIJAJ==\n" +
+					"-----END CERTIFICATE-----\n",
+			},
+			outErr: ErrSAMLInvalidConfig,
+		},
+		"error: not metadata": {
+			config: &model.SAMLConfig{
+				IdPMetadata: "<foo/>",
+			},
+			outErr: ErrSAMLInvalidConfig,
+		},
+		"error: db": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			dbErr: errors.New("db error"),
+
+			outErr: errors.New(
+				"useradm: failed to save SAML configuration: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.outErr != ErrSAMLInvalidConfig {
+				db.On("SaveSAMLConfig", ctx, tc.config).Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.SaveSAMLConfig(ctx, tc.config)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmGetSAMLMetadata(t *testing.T) {
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetSAMLConfig", tenantMatcher("")).Return(nil, nil).Once()
+	db.On("GetSAMLConfig", tenantMatcher("")).Return(&model.SAMLConfig{
+		AttributeMapping: model.SAMLAttributeMapping{Email: "mail"},
+	}, nil).Once()
+	db.On("GetSAMLConfig", tenantMatcher("")).
+		Return(nil, errors.New("db error")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	for _, format := range []saml.NameIDFormat{
+		saml.EmailAddressNameIDFormat,
+		saml.UnspecifiedNameIDFormat,
+	} {
+		out, err := useradm.GetSAMLMetadata(context.Background(),
+			testSAMLServiceProvider)
+		assert.NoError(t, err)
+
+		var metadata saml.EntityDescriptor
+		assert.NoError(t, xml.Unmarshal(out, &metadata))
+		assert.Equal(t, testSAMLServiceProvider.MetadataURL, metadata.EntityID)
+		if assert.Len(t, metadata.SPSSODescriptors, 1) {
+			descriptor := metadata.SPSSODescriptors[0]
+			assert.Equal(t, testSAMLServiceProvider.ACSURL,
+				descriptor.AssertionConsumerServices[0].Location)
+			assert.Equal(t, []saml.NameIDFormat{format}, descriptor.NameIDFormats)
+		}
+	}
+
+	_, err := useradm.GetSAMLMetadata(context.Background(), testSAMLServiceProvider)
+	assert.EqualError(t, err,
+		"useradm: failed to get SAML configuration: db error")
+}
+
+func TestUserAdmMakeSAMLAuthnRequest(t *testing.T) {
+	idp, err := mtesting.NewSAMLIdP()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetSAMLConfig", tenantMatcher("")).Return(&model.SAMLConfig{
+		IdPMetadata: idp.Metadata(),
+	}, nil).Once()
+	db.On("GetSAMLConfig", tenantMatcher("")).Return(nil, nil).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	request, err := useradm.MakeSAMLAuthnRequest(context.Background(),
+		testSAMLServiceProvider, "/ui/")
+	assert.NoError(t, err)
+	if assert.NotNil(t, request) {
+		assert.NotEmpty(t, request.ID)
+		assert.Regexp(t, `^https://idp\.example\.com/sso\?SAMLRequest=.+`+
+			`&RelayState=%2Fui%2F$`, request.URL)
+	}
+
+	_, err = useradm.MakeSAMLAuthnRequest(context.Background(),
+		testSAMLServiceProvider, "")
+	assert.Equal(t, ErrSAMLNotConfigured, err)
+
+	// the tenant in the path is only used in the multi-tenant setup
+	db.On("GetSAMLConfig", tenantMatcher("tenant")).Return(nil, nil).Once()
+	_, err = useradm.WithTenantVerification(&mct.ClientRunner{}).
+		MakeSAMLAuthnRequest(context.Background(), testSAMLServiceProvider, "")
+	assert.Equal(t, ErrSAMLNotConfigured, err)
+}
+
+func TestUserAdmLoginSAML(t *testing.T) {
+	idp, err := mtesting.NewSAMLIdP()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	otherIdP, err := mtesting.NewSAMLIdP()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the metadata the service provider registers with the IdP
+	db := &mstore.DataStore{}
+	db.On("GetSAMLConfig", mock.Anything).Return(nil, nil)
+	spMetadata, err := NewUserAdm(nil, db, Config{}).
+		GetSAMLMetadata(context.Background(), testSAMLServiceProvider)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	user := &model.User{
+		ID:    "2b7c5d4e-6f1a-4b8c-9d0e-1f2a3b4c5d6e",
+		Email: "user@example.com",
+	}
+
+	testCases := map[string]struct {
+		config    *model.SAMLConfig
+		configErr error
+
+		idp        *mtesting.SAMLIdP
+		requestID  string
+		requestIDs []string
+		session    *saml.Session
+
+		verifyTenant bool
+		tenant       *ct.Tenant
+
+		dbUser    *model.User
+		replayErr error
+
+		outErr error
+	}{
+		"ok": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:        idp,
+			requestID:  "id-1",
+			requestIDs: []string{"id-1"},
+			session:    &saml.Session{NameID: "User@example.com"},
+			dbUser:     user,
+		},
+		"ok, attribute mapping": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+				AttributeMapping: model.SAMLAttributeMapping{
+					Email: "mail",
+				},
+			},
+			idp:        idp,
+			requestID:  "id-1",
+			requestIDs: []string{"id-1"},
+			session: &saml.Session{
+				NameID: "jdoe",
+				CustomAttributes: []saml.Attribute{{
+					Name:   "mail",
+					Values: []saml.AttributeValue{{Value: "user@example.com"}},
+				}},
+			},
+			dbUser: user,
+		},
+		"ok, IdP-initiated": {
+			config: &model.SAMLConfig{
+				IdPMetadata:       idp.Metadata(),
+				AllowIdPInitiated: true,
+			},
+			idp:     idp,
+			session: &saml.Session{NameID: "user@example.com"},
+			dbUser:  user,
+		},
+		"ok, configured certificate": {
+			config: &model.SAMLConfig{
+				IdPMetadata:    otherIdP.Metadata(),
+				IdPCertificate: idp.Certificate(),
+			},
+			idp:        idp,
+			requestID:  "id-1",
+			requestIDs: []string{"id-1"},
+			session:    &saml.Session{NameID: "user@example.com"},
+			dbUser:     user,
+		},
+		"ok, tenant": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:          idp,
+			requestID:    "id-1",
+			requestIDs:   []string{"id-1"},
+			session:      &saml.Session{NameID: "user@example.com"},
+			verifyTenant: true,
+			tenant:       &ct.Tenant{ID: "tenant"},
+			dbUser:       user,
+		},
+		"error: not configured": {
+			idp:       idp,
+			requestID: "id-1",
+			session:   &saml.Session{NameID: "user@example.com"},
+
+			outErr: ErrSAMLNotConfigured,
+		},
+		"error: db": {
+			configErr: errors.New("db error"),
+			idp:       idp,
+			requestID: "id-1",
+			session:   &saml.Session{NameID: "user@example.com"},
+
+			outErr: errors.New(
+				"useradm: failed to get SAML configuration: db error"),
+		},
+		"error: signed by another IdP": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:        otherIdP,
+			requestID:  "id-1",
+			requestIDs: []string{"id-1"},
+			session:    &saml.Session{NameID: "user@example.com"},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: unknown request": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:        idp,
+			requestID:  "id-2",
+			requestIDs: []string{"id-1"},
+			session:    &saml.Session{NameID: "user@example.com"},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: IdP-initiated not allowed": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:     idp,
+			session: &saml.Session{NameID: "user@example.com"},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: no email attribute": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+				AttributeMapping: model.SAMLAttributeMapping{
+					Email: "mail",
+				},
+			},
+			idp:        idp,
+			requestID:  "id-1",
+			requestIDs: []string{"id-1"},
+			session:    &saml.Session{NameID: "user@example.com"},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: another tenant's user": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:          idp,
+			requestID:    "id-1",
+			requestIDs:   []string{"id-1"},
+			session:      &saml.Session{NameID: "user@example.com"},
+			verifyTenant: true,
+			tenant:       &ct.Tenant{ID: "other"},
+
+			outErr: ErrUnauthorized,
+		},
+		"error: tenant suspended": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:          idp,
+			requestID:    "id-1",
+			requestIDs:   []string{"id-1"},
+			session:      &saml.Session{NameID: "user@example.com"},
+			verifyTenant: true,
+			tenant: &ct.Tenant{
+				ID:     "tenant",
+				Status: TenantStatusSuspended,
+			},
+
+			outErr: ErrTenantAccountSuspended,
+		},
+		"error: replayed": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:        idp,
+			requestID:  "id-1",
+			requestIDs: []string{"id-1"},
+			session:    &saml.Session{NameID: "user@example.com"},
+			replayErr:  store.ErrSAMLAssertionReplayed,
+
+			outErr: ErrUnauthorized,
+		},
+		"error: save assertion ID": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:        idp,
+			requestID:  "id-1",
+			requestIDs: []string{"id-1"},
+			session:    &saml.Session{NameID: "user@example.com"},
+			replayErr:  errors.New("db error"),
+
+			outErr: errors.New(
+				"useradm: failed to save SAML assertion ID: db error"),
+		},
+		"error: unknown user": {
+			config: &model.SAMLConfig{
+				IdPMetadata: idp.Metadata(),
+			},
+			idp:        idp,
+			requestID:  "id-1",
+			requestIDs: []string{"id-1"},
+			session:    &saml.Session{NameID: "user@example.com"},
+
+			outErr: ErrUnauthorized,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			response, err := tc.idp.MakeResponse(spMetadata, tc.requestID, tc.session)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			// the tenant in the path is ignored in the single-tenant setup
+			tenantID := ""
+			if tc.verifyTenant {
+				tenantID = "tenant"
+			}
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetSAMLConfig", tenantMatcher(tenantID)).
+				Return(tc.config, tc.configErr)
+			// the assertion is recorded until it expires
+			db.On("SaveSAMLAssertionID", tenantMatcher(tenantID),
+				mock.AnythingOfType("string"),
+				mock.MatchedBy(func(exp time.Time) bool {
+					return exp.After(time.Now())
+				})).
+				Return(tc.replayErr).Maybe()
+			db.On("GetUserByEmail", tenantMatcher(tenantID),
+				model.Email("user@example.com")).
+				Return(tc.dbUser, nil).Maybe()
+			if tc.dbUser != nil {
+				db.On("GetActiveElevations", tenantMatcher(tenantID),
+					tc.dbUser.ID).Return(nil, nil)
+				db.On("SaveToken", tenantMatcher(tenantID),
+					mock.AnythingOfType("*jwt.Token")).Return(nil)
+				db.On("UpdateLoginTs", tenantMatcher(tenantID),
+					tc.dbUser.ID).Return(nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{
+				Issuer:         "mender",
+				ExpirationTime: 10,
+			})
+			if tc.verifyTenant {
+				cTenant := &mct.ClientRunner{}
+				cTenant.On("GetTenant", tenantMatcher(tenantID),
+					"user@example.com", &apiclient.HttpApi{}).
+					Return(tc.tenant, nil)
+				useradm = useradm.WithTenantVerification(cTenant)
+			}
+
+			token, err := useradm.LoginSAML(context.Background(),
+				testSAMLServiceProvider,
+				mtesting.DecodeSAMLResponse(response),
+				tc.requestIDs)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, token)
+			} else if assert.NoError(t, err) {
+				db.AssertNumberOfCalls(t, "SaveSAMLAssertionID", 1)
+				assert.Equal(t, tc.dbUser.ID, token.Claims.Subject.String())
+				assert.Equal(t, tenantID, token.Claims.Tenant)
+				assert.True(t, token.Claims.User)
+			}
+		})
+	}
+}
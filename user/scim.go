@@ -0,0 +1,100 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+)
+
+var (
+	ErrSCIMNotConfigured = errors.New("SCIM token not generated")
+)
+
+func hashSCIMToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSCIMToken generates a SCIM token for the tenant; the token itself is
+// returned only once, and is not valid until saved.
+func (ua *UserAdm) NewSCIMToken(
+	ctx context.Context,
+	req *model.SCIMTokenRequest,
+) (*model.SCIMToken, error) {
+	if err := ua.checkRoles(ctx, req.ProvisioningRoles); err != nil {
+		return nil, err
+	}
+	secret, err := randomValue()
+	if err != nil {
+		return nil, err
+	}
+	return &model.SCIMToken{
+		Token:             secret,
+		Hash:              hashSCIMToken(secret),
+		ProvisioningRoles: req.ProvisioningRoles,
+		CreatedTs:         time.Now().UTC(),
+	}, nil
+}
+
+// SaveSCIMToken makes the token the tenant's SCIM token, replacing the
+// previous one.
+func (ua *UserAdm) SaveSCIMToken(ctx context.Context, token *model.SCIMToken) error {
+	if err := ua.db.SaveSCIMToken(ctx, token); err != nil {
+		return errors.Wrap(err, "useradm: failed to save SCIM token")
+	}
+	return nil
+}
+
+func (ua *UserAdm) GetSCIMToken(ctx context.Context) (*model.SCIMToken, error) {
+	token, err := ua.db.GetSCIMToken(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get SCIM token")
+	} else if token == nil {
+		return nil, ErrSCIMNotConfigured
+	}
+	return token, nil
+}
+
+func (ua *UserAdm) DeleteSCIMToken(ctx context.Context) error {
+	if err := ua.db.DeleteSCIMToken(ctx); err != nil {
+		return errors.Wrap(err, "useradm: failed to delete SCIM token")
+	}
+	return nil
+}
+
+// AuthenticateSCIM looks up the SCIM token presented by the client,
+// returning it with the ID of the owning tenant.
+func (ua *UserAdm) AuthenticateSCIM(
+	ctx context.Context,
+	secret string,
+) (*model.SCIMToken, error) {
+	if secret == "" {
+		return nil, ErrUnauthorized
+	}
+	token, err := ua.db.GetSCIMTokenByHash(ctx, hashSCIMToken(secret))
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get SCIM token")
+	} else if token == nil {
+		return nil, ErrUnauthorized
+	}
+	return token, nil
+}
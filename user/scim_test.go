@@ -0,0 +1,209 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/useradm/model"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+	mtesting "github.com/mendersoftware/useradm/utils/testing"
+)
+
+func TestUserAdmNewSCIMToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		req *model.SCIMTokenRequest
+
+		dbRoles []model.Role
+
+		outErr error
+	}{
+		"ok": {
+			req: &model.SCIMTokenRequest{},
+		},
+		"ok, custom role": {
+			req: &model.SCIMTokenRequest{
+				ProvisioningRoles: []string{"devs"},
+			},
+			dbRoles: []model.Role{{Name: "devs"}},
+		},
+		"error: unknown role": {
+			req: &model.SCIMTokenRequest{
+				ProvisioningRoles: []string{"devs"},
+			},
+			dbRoles: []model.Role{},
+
+			outErr: ErrUnknownRole,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.dbRoles != nil {
+				db.On("GetRolesByNames", ctx, tc.req.ProvisioningRoles).
+					Return(tc.dbRoles, nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			token, err := useradm.NewSCIMToken(ctx, tc.req)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, token)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.NotNil(t, token) {
+				assert.NotEmpty(t, token.Token)
+				assert.Equal(t, hashSCIMToken(token.Token), token.Hash)
+				assert.Equal(t, tc.req.ProvisioningRoles, token.ProvisioningRoles)
+				assert.False(t, token.CreatedTs.IsZero())
+			}
+		})
+	}
+}
+
+func TestUserAdmSaveSCIMToken(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	token := &model.SCIMToken{Hash: hashSCIMToken("secret")}
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("SaveSCIMToken", ctx, token).Return(nil).Once()
+	db.On("SaveSCIMToken", ctx, token).Return(errors.New("db failed")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+	assert.NoError(t, useradm.SaveSCIMToken(ctx, token))
+	assert.EqualError(t, useradm.SaveSCIMToken(ctx, token),
+		"useradm: failed to save SCIM token: db failed")
+}
+
+func TestUserAdmGetSCIMToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		dbToken *model.SCIMToken
+		dbErr   error
+
+		outErr error
+	}{
+		"ok": {
+			dbToken: &model.SCIMToken{},
+		},
+		"error: not configured": {
+			outErr: ErrSCIMNotConfigured,
+		},
+		"error: db": {
+			dbErr: errors.New("db failed"),
+
+			outErr: errors.New("useradm: failed to get SCIM token: db failed"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetSCIMToken", ctx).Return(tc.dbToken, tc.dbErr)
+
+			useradm := NewUserAdm(nil, db, Config{})
+			token, err := useradm.GetSCIMToken(ctx)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.dbToken, token)
+			}
+		})
+	}
+}
+
+func TestUserAdmAuthenticateSCIM(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		secret string
+
+		dbToken *model.SCIMToken
+		dbErr   error
+
+		outErr error
+	}{
+		"ok": {
+			secret:  "secret",
+			dbToken: &model.SCIMToken{TenantID: "tenant"},
+		},
+		"error: no token": {
+			outErr: ErrUnauthorized,
+		},
+		"error: unknown token": {
+			secret: "secret",
+
+			outErr: ErrUnauthorized,
+		},
+		"error: db": {
+			secret: "secret",
+			dbErr:  errors.New("db failed"),
+
+			outErr: errors.New("useradm: failed to get SCIM token: db failed"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.secret != "" {
+				db.On("GetSCIMTokenByHash", mtesting.ContextMatcher(),
+					hashSCIMToken(tc.secret)).
+					Return(tc.dbToken, tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			token, err := useradm.AuthenticateSCIM(ctx, tc.secret)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, token)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.dbToken, token)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
+	"github.com/mendersoftware/useradm/store"
+)
+
+const (
+	// clientSecretLength is the number of random bytes in a generated
+	// client secret
+	clientSecretLength = 32
+)
+
+var (
+	ErrServiceAccountNotFound      = errors.New("service account not found")
+	ErrDuplicateServiceAccountName = errors.New(
+		"service account with a given name already exists")
+	ErrInvalidClient = errors.New("client authentication failed")
+	ErrInvalidScope  = errors.New("requested scope is invalid or unknown")
+)
+
+// generateClientSecret returns a new random client secret and its hash.
+func generateClientSecret() (string, *model.ServiceAccountSecret, error) {
+	buf := make([]byte, clientSecretLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, errors.Wrap(err, "failed to generate client secret")
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to hash client secret")
+	}
+	return secret, &model.ServiceAccountSecret{
+		Hash:      string(hash),
+		CreatedTs: time.Now().UTC(),
+	}, nil
+}
+
+func (ua *UserAdm) CreateServiceAccount(
+	ctx context.Context,
+	saNew *model.ServiceAccountNew,
+) (*model.ServiceAccountWithCredentials, error) {
+	secret, hashed, err := generateClientSecret()
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm")
+	}
+	sa := &model.ServiceAccount{
+		ID:          oid.NewUUIDv4().String(),
+		Name:        saNew.Name,
+		Description: saNew.Description,
+		PublicKey:   saNew.PublicKey,
+		Secrets:     []model.ServiceAccountSecret{*hashed},
+	}
+
+	err = ua.db.CreateServiceAccount(ctx, sa)
+	if err == store.ErrDuplicateServiceAccountName {
+		return nil, ErrDuplicateServiceAccountName
+	} else if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to create service account")
+	}
+
+	return &model.ServiceAccountWithCredentials{
+		ServiceAccount: *sa,
+		ServiceAccountCredentials: model.ServiceAccountCredentials{
+			ClientID:     sa.ID,
+			ClientSecret: secret,
+		},
+	}, nil
+}
+
+func (ua *UserAdm) GetServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error) {
+	serviceAccounts, err := ua.db.GetServiceAccounts(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get service accounts")
+	}
+	return serviceAccounts, nil
+}
+
+func (ua *UserAdm) GetServiceAccount(
+	ctx context.Context,
+	id string,
+) (*model.ServiceAccount, error) {
+	sa, err := ua.db.GetServiceAccount(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get service account")
+	}
+	return sa, nil
+}
+
+func (ua *UserAdm) UpdateServiceAccount(
+	ctx context.Context,
+	id string,
+	sa *model.ServiceAccountUpdate,
+) error {
+	err := ua.db.UpdateServiceAccount(ctx, id, sa)
+	switch err {
+	case nil:
+		return nil
+	case store.ErrServiceAccountNotFound:
+		return ErrServiceAccountNotFound
+	case store.ErrDuplicateServiceAccountName:
+		return ErrDuplicateServiceAccountName
+	default:
+		return errors.Wrap(err, "useradm: failed to update service account")
+	}
+}
+
+func (ua *UserAdm) DeleteServiceAccount(ctx context.Context, id string) error {
+	err := ua.db.DeleteServiceAccount(ctx, id)
+	if err == store.ErrServiceAccountNotFound {
+		return ErrServiceAccountNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete service account")
+	}
+
+	// remove service account tokens
+	err = ua.db.DeleteTokensByUserId(ctx, id)
+	ua.verifyCache.InvalidateUser(id)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete service account tokens")
+	}
+
+	return nil
+}
+
+func (ua *UserAdm) IssueServiceAccountToken(
+	ctx context.Context,
+	id string,
+	tr *model.TokenRequest,
+) (string, error) {
+	identity := identity.FromContext(ctx)
+	if identity == nil {
+		return "", errors.New("identity not present in the context")
+	}
+	sa, err := ua.db.GetServiceAccount(ctx, id)
+	if err != nil {
+		return "", errors.Wrap(err, "useradm: failed to get service account")
+	} else if sa == nil {
+		return "", ErrServiceAccountNotFound
+	}
+	return ua.issuePersonalAccessToken(ctx, sa.ID, identity.Tenant, true, tr)
+}
+
+func (ua *UserAdm) GetServiceAccountTokens(
+	ctx context.Context,
+	id string,
+) ([]model.PersonalAccessToken, error) {
+	sa, err := ua.db.GetServiceAccount(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get service account")
+	} else if sa == nil {
+		return nil, ErrServiceAccountNotFound
+	}
+	return ua.GetPersonalAccessTokens(ctx, sa.ID)
+}
+
+func (ua *UserAdm) DeleteServiceAccountToken(ctx context.Context, id, tokenID string) error {
+	tid := oid.FromString(tokenID)
+	err := ua.db.DeleteToken(ctx, oid.FromString(id), tid)
+	ua.verifyCache.InvalidateToken(tid.String())
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete token")
+	}
+	return nil
+}
+
+func (ua *UserAdm) RotateServiceAccountSecret(
+	ctx context.Context,
+	id string,
+) (*model.ServiceAccountCredentials, error) {
+	sa, err := ua.db.GetServiceAccount(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get service account")
+	} else if sa == nil {
+		return nil, ErrServiceAccountNotFound
+	}
+
+	secret, hashed, err := generateClientSecret()
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm")
+	}
+
+	// keep the previous secrets valid for the overlap window, so that
+	// the clients can be updated without downtime
+	now := hashed.CreatedTs
+	expires := now.Add(time.Duration(ua.getConfig().ClientSecretRotationOverlap) *
+		time.Second)
+	secrets := make([]model.ServiceAccountSecret, 0, len(sa.Secrets)+1)
+	for _, s := range sa.Secrets {
+		if !s.Active(now) || !expires.After(now) {
+			continue
+		}
+		if s.ExpiresTs == nil || s.ExpiresTs.After(expires) {
+			s.ExpiresTs = &expires
+		}
+		secrets = append(secrets, s)
+	}
+	secrets = append(secrets, *hashed)
+
+	err = ua.db.SetServiceAccountSecrets(ctx, id, secrets)
+	if err == store.ErrServiceAccountNotFound {
+		return nil, ErrServiceAccountNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to update client secrets")
+	}
+
+	return &model.ServiceAccountCredentials{
+		ClientID:     sa.ID,
+		ClientSecret: secret,
+	}, nil
+}
+
+func (ua *UserAdm) IssueClientCredentialsToken(
+	ctx context.Context,
+	req *model.ClientCredentialsRequest,
+) (*jwt.Token, error) {
+	config := ua.getConfig()
+
+	var sa *model.ServiceAccount
+	if req.ClientAssertion != "" {
+		assertion, err := jwt.VerifyAssertion(req.ClientAssertion, config.Issuer,
+			func(clientID string) (*rsa.PublicKey, error) {
+				var err error
+				sa, err = ua.getServiceAccountByClientID(ctx, clientID)
+				if err != nil {
+					return nil, err
+				}
+				if sa.PublicKey == "" {
+					return nil, ErrInvalidClient
+				}
+				return jwt.ParseRSAPublicKey([]byte(sa.PublicKey))
+			})
+		if err == jwt.ErrTokenExpired || errors.Cause(err) == jwt.ErrTokenInvalid {
+			return nil, ErrInvalidClient
+		} else if err != nil {
+			return nil, err
+		}
+		if req.ClientID != "" && req.ClientID != assertion.ClientID {
+			return nil, ErrInvalidClient
+		}
+		// a captured assertion must not be exchanged for a token again
+		err = ua.db.SaveClientAssertionID(ctx,
+			assertion.ClientID, assertion.ID, assertion.ExpiresAt)
+		if err == store.ErrClientAssertionReplayed {
+			log.FromContext(ctx).Warnf("client assertion %s of %s replayed",
+				assertion.ID, assertion.ClientID)
+			return nil, ErrInvalidClient
+		} else if err != nil {
+			return nil, errors.Wrap(err, "useradm: failed to save client assertion ID")
+		}
+	} else {
+		var err error
+		sa, err = ua.getServiceAccountByClientID(ctx, req.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		if !sa.VerifySecret(req.ClientSecret, time.Now()) {
+			return nil, ErrInvalidClient
+		}
+	}
+
+	tokenScope, err := grantScope(ctx, scope.Parse(req.Scope))
+	if err == ErrScopeNotPermitted {
+		return nil, ErrInvalidScope
+	} else if err != nil {
+		return nil, err
+	}
+
+	ctx = identity.WithContext(ctx, &identity.Identity{
+		Subject: sa.ID,
+		Tenant:  sa.TenantID,
+	})
+	t, err := ua.generateToken(sa.ID, tokenScope, sa.TenantID)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to generate token")
+	}
+	t.ServiceAccount = true
+	t.ExpiresAt = jwt.Time{
+		Time: t.IssuedAt.Add(time.Second *
+			time.Duration(config.ClientCredentialsExpirationTime)),
+	}
+
+	err = ua.db.SaveToken(ctx, t)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to save token")
+	}
+
+	return t, nil
+}
+
+func (ua *UserAdm) getServiceAccountByClientID(
+	ctx context.Context,
+	clientID string,
+) (*model.ServiceAccount, error) {
+	sa, err := ua.db.GetServiceAccountByClientID(ctx, clientID)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get service account")
+	} else if sa == nil {
+		return nil, ErrInvalidClient
+	}
+	return sa, nil
+}
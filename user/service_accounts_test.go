@@ -0,0 +1,633 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package useradm
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mendersoftware/useradm/jwt"
+	mjwt "github.com/mendersoftware/useradm/jwt/mocks"
+	"github.com/mendersoftware/useradm/keys"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/scope"
+	"github.com/mendersoftware/useradm/store"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+func TestUserAdmCreateServiceAccount(t *testing.T) {
+	testCases := map[string]struct {
+		dbErr error
+
+		outErr error
+	}{
+		"ok": {},
+		"error: duplicate name": {
+			dbErr:  store.ErrDuplicateServiceAccountName,
+			outErr: ErrDuplicateServiceAccountName,
+		},
+		"error: db": {
+			dbErr: errors.New("db error"),
+			outErr: errors.New(
+				"useradm: failed to create service account: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			var stored *model.ServiceAccount
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("CreateServiceAccount", ctx,
+				mock.AnythingOfType("*model.ServiceAccount")).
+				Run(func(args mock.Arguments) {
+					stored = args.Get(1).(*model.ServiceAccount)
+				}).
+				Return(tc.dbErr)
+
+			useradm := NewUserAdm(nil, db, Config{})
+			sa, err := useradm.CreateServiceAccount(ctx, &model.ServiceAccountNew{
+				Name:        "ci",
+				Description: "pipeline",
+			})
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, sa)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "ci", sa.Name)
+			assert.Equal(t, "pipeline", sa.Description)
+			assert.Equal(t, sa.ID, sa.ClientID)
+			assert.NotEmpty(t, sa.ClientSecret)
+
+			// only the hash of the secret is stored
+			if assert.Len(t, stored.Secrets, 1) {
+				assert.NotEqual(t, sa.ClientSecret, stored.Secrets[0].Hash)
+				assert.NoError(t, bcrypt.CompareHashAndPassword(
+					[]byte(stored.Secrets[0].Hash), []byte(sa.ClientSecret)))
+			}
+		})
+	}
+}
+
+func TestUserAdmUpdateServiceAccount(t *testing.T) {
+	testCases := map[string]struct {
+		dbErr error
+
+		outErr error
+	}{
+		"ok": {},
+		"error: not found": {
+			dbErr:  store.ErrServiceAccountNotFound,
+			outErr: ErrServiceAccountNotFound,
+		},
+		"error: duplicate name": {
+			dbErr:  store.ErrDuplicateServiceAccountName,
+			outErr: ErrDuplicateServiceAccountName,
+		},
+		"error: db": {
+			dbErr: errors.New("db error"),
+			outErr: errors.New(
+				"useradm: failed to update service account: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			update := &model.ServiceAccountUpdate{Name: stringPtr("ci")}
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("UpdateServiceAccount", ctx, "1", update).Return(tc.dbErr)
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.UpdateServiceAccount(ctx, "1", update)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmDeleteServiceAccount(t *testing.T) {
+	testCases := map[string]struct {
+		dbDeleteErr error
+
+		callDeleteTokens bool
+		dbDeleteTokenErr error
+
+		outErr error
+	}{
+		"ok": {
+			callDeleteTokens: true,
+		},
+		"error: not found": {
+			dbDeleteErr: store.ErrServiceAccountNotFound,
+			outErr:      ErrServiceAccountNotFound,
+		},
+		"error: db": {
+			dbDeleteErr: errors.New("db error"),
+			outErr: errors.New(
+				"useradm: failed to delete service account: db error"),
+		},
+		"error: db tokens": {
+			callDeleteTokens: true,
+			dbDeleteTokenErr: errors.New("db error"),
+			outErr: errors.New(
+				"useradm: failed to delete service account tokens: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("DeleteServiceAccount", ctx, "1").Return(tc.dbDeleteErr)
+			if tc.callDeleteTokens {
+				db.On("DeleteTokensByUserId", ctx, "1").
+					Return(tc.dbDeleteTokenErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			err := useradm.DeleteServiceAccount(ctx, "1")
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmIssueServiceAccountToken(t *testing.T) {
+	saID := oid.NewUUIDv5("sa-1").String()
+
+	testCases := map[string]struct {
+		dbSA    *model.ServiceAccount
+		dbSAErr error
+
+		outErr error
+	}{
+		"ok": {
+			dbSA: &model.ServiceAccount{ID: saID},
+		},
+		"error: not found": {
+			outErr: ErrServiceAccountNotFound,
+		},
+		"error: db": {
+			dbSAErr: errors.New("db error"),
+			outErr: errors.New(
+				"useradm: failed to get service account: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := identity.WithContext(context.Background(),
+				&identity.Identity{
+					Subject: "admin",
+					Tenant:  "tenant",
+				})
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetServiceAccount", ctx, saID).Return(tc.dbSA, tc.dbSAErr)
+
+			var saved *jwt.Token
+			if tc.dbSA != nil {
+				db.On("SaveToken", ctx, mock.AnythingOfType("*jwt.Token")).
+					Run(func(args mock.Arguments) {
+						saved = args.Get(1).(*jwt.Token)
+					}).
+					Return(nil)
+			}
+
+			mockJWTHandler := &mjwt.Handler{}
+			mockJWTHandler.On("ToJWT", ContextMatcher(), mock.AnythingOfType("*jwt.Token")).
+				Return("signed", nil)
+
+			useradm := NewUserAdm(mockJWTHandler, db, Config{
+				Issuer:         "mender",
+				ExpirationTime: 10,
+			})
+			token, err := useradm.IssueServiceAccountToken(ctx, saID,
+				&model.TokenRequest{
+					Name:      stringPtr("ci"),
+					ExpiresIn: 3600,
+				})
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "signed", token)
+			assert.Equal(t, saID, saved.Claims.Subject.String())
+			assert.Equal(t, "tenant", saved.Claims.Tenant)
+			assert.True(t, saved.Claims.User)
+			assert.True(t, saved.Claims.ServiceAccount)
+		})
+	}
+}
+
+func TestUserAdmGetServiceAccountTokens(t *testing.T) {
+	testCases := map[string]struct {
+		dbSA *model.ServiceAccount
+
+		outTokens []model.PersonalAccessToken
+		outErr    error
+	}{
+		"ok": {
+			dbSA: &model.ServiceAccount{ID: "1"},
+			outTokens: []model.PersonalAccessToken{
+				{Name: stringPtr("ci")},
+			},
+		},
+		"error: not found": {
+			outErr: ErrServiceAccountNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetServiceAccount", ctx, "1").Return(tc.dbSA, nil)
+			if tc.dbSA != nil {
+				db.On("GetPersonalAccessTokens", ctx, "1").
+					Return(tc.outTokens, nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			tokens, err := useradm.GetServiceAccountTokens(ctx, "1")
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.outTokens, tokens)
+			}
+		})
+	}
+}
+
+func TestUserAdmRotateServiceAccountSecret(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	expiresLater := now.Add(48 * time.Hour)
+	expiresSooner := now.Add(time.Hour)
+
+	testCases := map[string]struct {
+		dbSA    *model.ServiceAccount
+		dbSAErr error
+		overlap int64
+
+		dbSetErr error
+
+		// expected number of secrets and expiration of the previous
+		// ones, relative to now
+		outSecrets int
+		outExpires []time.Duration
+		outErr     error
+	}{
+		"ok": {
+			dbSA: &model.ServiceAccount{
+				ID: "1",
+				Secrets: []model.ServiceAccountSecret{
+					{Hash: "expired", ExpiresTs: &expired},
+					{Hash: "expires later", ExpiresTs: &expiresLater},
+					{Hash: "expires sooner", ExpiresTs: &expiresSooner},
+					{Hash: "current"},
+				},
+			},
+			overlap:    86400,
+			outSecrets: 4,
+			outExpires: []time.Duration{24 * time.Hour, time.Hour, 24 * time.Hour},
+		},
+		"ok, no overlap": {
+			dbSA: &model.ServiceAccount{
+				ID: "1",
+				Secrets: []model.ServiceAccountSecret{
+					{Hash: "current"},
+				},
+			},
+			outSecrets: 1,
+		},
+		"error: not found": {
+			outErr: ErrServiceAccountNotFound,
+		},
+		"error: db": {
+			dbSAErr: errors.New("db error"),
+			outErr:  errors.New("useradm: failed to get service account: db error"),
+		},
+		"error: db set secrets": {
+			dbSA: &model.ServiceAccount{
+				ID: "1",
+			},
+			dbSetErr: errors.New("db error"),
+			outErr:   errors.New("useradm: failed to update client secrets: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetServiceAccount", ctx, "1").Return(tc.dbSA, tc.dbSAErr)
+
+			var secrets []model.ServiceAccountSecret
+			if tc.dbSA != nil {
+				db.On("SetServiceAccountSecrets", ctx, "1",
+					mock.AnythingOfType("[]model.ServiceAccountSecret")).
+					Run(func(args mock.Arguments) {
+						secrets = args.Get(2).([]model.ServiceAccountSecret)
+					}).
+					Return(tc.dbSetErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{
+				ClientSecretRotationOverlap: tc.overlap,
+			})
+			creds, err := useradm.RotateServiceAccountSecret(ctx, "1")
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "1", creds.ClientID)
+			if !assert.Len(t, secrets, tc.outSecrets) {
+				return
+			}
+			for i, expires := range tc.outExpires {
+				if assert.NotNil(t, secrets[i].ExpiresTs) {
+					assert.WithinDuration(t, now.Add(expires),
+						*secrets[i].ExpiresTs, time.Minute)
+				}
+			}
+			current := secrets[len(secrets)-1]
+			assert.Nil(t, current.ExpiresTs)
+			assert.NoError(t, bcrypt.CompareHashAndPassword(
+				[]byte(current.Hash), []byte(creds.ClientSecret)))
+		})
+	}
+}
+
+func TestUserAdmIssueClientCredentialsToken(t *testing.T) {
+	privKey, err := keys.LoadRSAPrivate("../crypto/private.pem")
+	assert.NoError(t, err)
+	publicKey, err := ioutil.ReadFile("../crypto/public.pem")
+	assert.NoError(t, err)
+
+	secretHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	sa := &model.ServiceAccount{
+		ID:        oid.NewUUIDv5("sa-1").String(),
+		PublicKey: string(publicKey),
+		Secrets: []model.ServiceAccountSecret{
+			{Hash: string(secretHash)},
+		},
+		TenantID: "tenant",
+	}
+	assertion := func(clientID, audience string) string {
+		raw, err := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256,
+			jwtgo.RegisteredClaims{
+				Issuer:    clientID,
+				Subject:   clientID,
+				Audience:  jwtgo.ClaimStrings{audience},
+				ExpiresAt: jwtgo.NewNumericDate(time.Now().Add(time.Minute)),
+				IssuedAt:  jwtgo.NewNumericDate(time.Now()),
+				ID:        "jti-1",
+			}).SignedString(privKey)
+		assert.NoError(t, err)
+		return raw
+	}
+
+	testCases := map[string]struct {
+		req *model.ClientCredentialsRequest
+
+		dbClientID string
+		dbSA       *model.ServiceAccount
+		dbSAErr    error
+
+		dbAssertion    bool
+		dbAssertionErr error
+
+		dbSaveErr error
+
+		outScope string
+		outErr   error
+	}{
+		"ok, client secret": {
+			req: &model.ClientCredentialsRequest{
+				ClientID:     sa.ID,
+				ClientSecret: "secret",
+			},
+			dbClientID: sa.ID,
+			dbSA:       sa,
+		},
+		"ok, client assertion": {
+			req: &model.ClientCredentialsRequest{
+				ClientAssertionType: model.ClientAssertionTypeJWTBearer,
+				ClientAssertion:     assertion(sa.ID, "mender"),
+			},
+			dbClientID:  sa.ID,
+			dbSA:        sa,
+			dbAssertion: true,
+		},
+		"error: client assertion replayed": {
+			req: &model.ClientCredentialsRequest{
+				ClientAssertionType: model.ClientAssertionTypeJWTBearer,
+				ClientAssertion:     assertion(sa.ID, "mender"),
+			},
+			dbClientID:     sa.ID,
+			dbSA:           sa,
+			dbAssertion:    true,
+			dbAssertionErr: store.ErrClientAssertionReplayed,
+			outErr:         ErrInvalidClient,
+		},
+		"error: save client assertion": {
+			req: &model.ClientCredentialsRequest{
+				ClientAssertionType: model.ClientAssertionTypeJWTBearer,
+				ClientAssertion:     assertion(sa.ID, "mender"),
+			},
+			dbClientID:     sa.ID,
+			dbSA:           sa,
+			dbAssertion:    true,
+			dbAssertionErr: errors.New("db error"),
+			outErr: errors.New("useradm: failed to save client assertion ID: " +
+				"db error"),
+		},
+		"error: wrong secret": {
+			req: &model.ClientCredentialsRequest{
+				ClientID:     sa.ID,
+				ClientSecret: "foo",
+			},
+			dbClientID: sa.ID,
+			dbSA:       sa,
+			outErr:     ErrInvalidClient,
+		},
+		"error: unknown client": {
+			req: &model.ClientCredentialsRequest{
+				ClientID:     "foo",
+				ClientSecret: "secret",
+			},
+			dbClientID: "foo",
+			outErr:     ErrInvalidClient,
+		},
+		"error: db": {
+			req: &model.ClientCredentialsRequest{
+				ClientID:     "foo",
+				ClientSecret: "secret",
+			},
+			dbClientID: "foo",
+			dbSAErr:    errors.New("db error"),
+			outErr:     errors.New("useradm: failed to get service account: db error"),
+		},
+		"error: assertion for another audience": {
+			req: &model.ClientCredentialsRequest{
+				ClientAssertionType: model.ClientAssertionTypeJWTBearer,
+				ClientAssertion:     assertion(sa.ID, "foo"),
+			},
+			dbClientID: sa.ID,
+			dbSA:       sa,
+			outErr:     ErrInvalidClient,
+		},
+		"error: assertion for another client": {
+			req: &model.ClientCredentialsRequest{
+				ClientID:            "foo",
+				ClientAssertionType: model.ClientAssertionTypeJWTBearer,
+				ClientAssertion:     assertion(sa.ID, "mender"),
+			},
+			dbClientID: sa.ID,
+			dbSA:       sa,
+			outErr:     ErrInvalidClient,
+		},
+		"error: no public key": {
+			req: &model.ClientCredentialsRequest{
+				ClientAssertionType: model.ClientAssertionTypeJWTBearer,
+				ClientAssertion:     assertion(sa.ID, "mender"),
+			},
+			dbClientID: sa.ID,
+			dbSA: &model.ServiceAccount{
+				ID: sa.ID,
+			},
+			outErr: ErrInvalidClient,
+		},
+		"ok, scoped": {
+			req: &model.ClientCredentialsRequest{
+				ClientID:     sa.ID,
+				ClientSecret: "secret",
+				Scope:        "mender.devices.read mender.deployments.write",
+			},
+			dbClientID: sa.ID,
+			dbSA:       sa,
+			outScope:   "mender.devices.read mender.deployments.write",
+		},
+		"error: invalid scope": {
+			req: &model.ClientCredentialsRequest{
+				ClientID:     sa.ID,
+				ClientSecret: "secret",
+				Scope:        "foo",
+			},
+			dbClientID: sa.ID,
+			dbSA:       sa,
+			outErr:     ErrInvalidScope,
+		},
+		"error: save token": {
+			req: &model.ClientCredentialsRequest{
+				ClientID:     sa.ID,
+				ClientSecret: "secret",
+			},
+			dbClientID: sa.ID,
+			dbSA:       sa,
+			dbSaveErr:  errors.New("db error"),
+			outErr:     errors.New("useradm: failed to save token: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetServiceAccountByClientID", ctx, tc.dbClientID).
+				Return(tc.dbSA, tc.dbSAErr)
+			if tc.dbAssertion {
+				db.On("SaveClientAssertionID", ctx, sa.ID, "jti-1",
+					mock.AnythingOfType("time.Time")).
+					Return(tc.dbAssertionErr)
+			}
+			if tc.outErr == nil || tc.dbSaveErr != nil {
+				db.On("SaveToken",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						return id != nil && id.Tenant == "tenant"
+					}),
+					mock.AnythingOfType("*jwt.Token")).
+					Return(tc.dbSaveErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{
+				Issuer:                          "mender",
+				ExpirationTime:                  604800,
+				ClientCredentialsExpirationTime: 3600,
+			})
+			token, err := useradm.IssueClientCredentialsToken(ctx, tc.req)
+
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, sa.ID, token.Claims.Subject.String())
+			assert.Equal(t, "tenant", token.Claims.Tenant)
+			assert.True(t, token.Claims.ServiceAccount)
+			assert.Nil(t, token.TokenName)
+			if tc.outScope != "" {
+				assert.Equal(t, tc.outScope, token.Claims.Scope)
+			} else {
+				assert.Equal(t, scope.All, token.Claims.Scope)
+			}
+			assert.Equal(t, time.Hour,
+				token.Claims.ExpiresAt.Sub(token.Claims.IssuedAt.Time))
+		})
+	}
+}
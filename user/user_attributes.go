@@ -0,0 +1,141 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/model"
+)
+
+var (
+	ErrDuplicateUserAttribute = errors.New(
+		"user with a given attribute value already exists")
+)
+
+// GetUserAttributeSchema returns the custom attributes defined by the
+// tenant; none if the tenant did not define any.
+func (ua *UserAdm) GetUserAttributeSchema(
+	ctx context.Context,
+) (*model.UserAttributeSchema, error) {
+	schema, err := ua.db.GetUserAttributeSchema(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get user attribute schema")
+	} else if schema == nil {
+		schema = &model.UserAttributeSchema{
+			Attributes: []model.UserAttribute{},
+		}
+	}
+	return schema, nil
+}
+
+// SaveUserAttributeSchema replaces the custom attributes defined by the
+// tenant; the schema applies to the users created or updated afterwards.
+func (ua *UserAdm) SaveUserAttributeSchema(
+	ctx context.Context,
+	schema *model.UserAttributeSchema,
+) error {
+	if err := ua.db.SaveUserAttributeSchema(ctx, schema); err != nil {
+		return errors.Wrap(err, "useradm: failed to save user attribute schema")
+	}
+	return nil
+}
+
+// checkUserAttributes checks the attributes of the user, or their change
+// if update is set, against the tenant's schema and the values of the
+// unique attributes of the other users.
+func (ua *UserAdm) checkUserAttributes(
+	ctx context.Context,
+	userID string,
+	attrs model.UserAttributes,
+	update bool,
+) error {
+	schema, err := ua.db.GetUserAttributeSchema(ctx)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get user attribute schema")
+	}
+	if err = schema.CheckAttributes(attrs, update); err != nil {
+		return err
+	}
+	for name, value := range attrs {
+		if value == nil || !schema.Attribute(name).Unique {
+			continue
+		}
+		users, err := ua.db.GetUsers(ctx, model.UserFilter{
+			Attributes: map[string]interface{}{name: value},
+			Limit:      2,
+		})
+		if err != nil {
+			return errors.Wrap(err, "useradm: failed to get users")
+		}
+		for _, user := range users {
+			if user.ID != userID {
+				return errors.Wrap(ErrDuplicateUserAttribute, name)
+			}
+		}
+	}
+	return nil
+}
+
+// userAttributeFilter converts the values of the attributes filtering the
+// users to the types of the tenant's schema; only the searchable
+// attributes can filter the users.
+func (ua *UserAdm) userAttributeFilter(
+	ctx context.Context,
+	fltr *model.UserFilter,
+) error {
+	if len(fltr.Attributes) == 0 {
+		return nil
+	}
+	schema, err := ua.db.GetUserAttributeSchema(ctx)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get user attribute schema")
+	}
+	attrs := make(map[string]interface{}, len(fltr.Attributes))
+	for name, value := range fltr.Attributes {
+		a := schema.Attribute(name)
+		if a == nil || !a.Searchable {
+			return errors.Wrapf(model.ErrInvalidUserAttribute,
+				"%s: not searchable", name)
+		}
+		if s, ok := value.(string); ok {
+			if value, err = a.ParseValue(s); err != nil {
+				return err
+			}
+		}
+		attrs[name] = value
+	}
+	// a new map, so that the caller's filter can be reused
+	fltr.Attributes = attrs
+	return nil
+}
+
+// attributeClaims returns the attributes of the user embedded in the
+// login tokens.
+func (ua *UserAdm) attributeClaims(
+	ctx context.Context,
+	user *model.User,
+) (map[string]interface{}, error) {
+	if len(user.Attributes) == 0 {
+		return nil, nil
+	}
+	schema, err := ua.db.GetUserAttributeSchema(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get user attribute schema")
+	}
+	return schema.Claims(user.Attributes), nil
+}
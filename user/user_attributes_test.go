@@ -0,0 +1,238 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+var testUserAttributeSchema = &model.UserAttributeSchema{
+	Attributes: []model.UserAttribute{{
+		Name:     "department",
+		Type:     model.UserAttributeTypeString,
+		Required: true,
+		Claim:    true,
+	}, {
+		Name:       "employee_id",
+		Type:       model.UserAttributeTypeNumber,
+		Unique:     true,
+		Searchable: true,
+	}},
+}
+
+func TestUserAdmGetUserAttributeSchema(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserAttributeSchema", ctx).Return(nil, nil).Once()
+	db.On("GetUserAttributeSchema", ctx).
+		Return(nil, errors.New("connection refused")).Once()
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	schema, err := useradm.GetUserAttributeSchema(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UserAttributeSchema{
+		Attributes: []model.UserAttribute{},
+	}, schema)
+
+	_, err = useradm.GetUserAttributeSchema(ctx)
+	assert.EqualError(t, err, "useradm: failed to get user attribute schema: "+
+		"connection refused")
+}
+
+func TestUserAdmCreateUserAttributes(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		attrs model.UserAttributes
+
+		dbUsers []model.User
+
+		outErr error
+	}{
+		"ok": {
+			attrs: model.UserAttributes{
+				"department":  "R&D",
+				"employee_id": float64(42),
+			},
+			dbUsers: []model.User{},
+		},
+		"error: required": {
+			attrs: model.UserAttributes{
+				"employee_id": float64(42),
+			},
+			outErr: model.ErrInvalidUserAttribute,
+		},
+		"error: duplicate": {
+			attrs: model.UserAttributes{
+				"department":  "R&D",
+				"employee_id": float64(42),
+			},
+			dbUsers: []model.User{{ID: "other"}},
+			outErr:  ErrDuplicateUserAttribute,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetUserAttributeSchema", ctx).
+				Return(testUserAttributeSchema, nil)
+			if tc.dbUsers != nil {
+				db.On("GetUsers", ctx, model.UserFilter{
+					Attributes: map[string]interface{}{
+						"employee_id": float64(42),
+					},
+					Limit: 2,
+				}).Return(tc.dbUsers, nil)
+			}
+			if tc.outErr == nil {
+				db.On("CreateUser", ctx, mock.AnythingOfType("*model.User")).
+					Return(nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+
+			err := useradm.CreateUser(ctx, &model.User{
+				Email:      "foo@acme.com",
+				Attributes: tc.attrs,
+			})
+			if tc.outErr != nil {
+				assert.Equal(t, tc.outErr, errors.Cause(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmUpdateUserAttributes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserAttributeSchema", ctx).
+		Return(testUserAttributeSchema, nil)
+	// the user keeps the unique value
+	db.On("GetUsers", ctx, model.UserFilter{
+		Attributes: map[string]interface{}{
+			"employee_id": float64(42),
+		},
+		Limit: 2,
+	}).Return([]model.User{{ID: "1"}}, nil)
+	update := &model.UserUpdate{
+		Attributes: model.UserAttributes{"employee_id": float64(42)},
+	}
+	db.On("UpdateUser", ctx, "1", update).Return(nil, nil)
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	err := useradm.UpdateUser(ctx, "1", update)
+	assert.NoError(t, err)
+
+	err = useradm.UpdateUser(ctx, "2", update)
+	assert.EqualError(t, err, "employee_id: "+ErrDuplicateUserAttribute.Error())
+
+	err = useradm.UpdateUser(ctx, "1", &model.UserUpdate{
+		Attributes: model.UserAttributes{"department": nil},
+	})
+	assert.Equal(t, model.ErrInvalidUserAttribute, errors.Cause(err))
+}
+
+func TestUserAdmGetUsersAttributes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserAttributeSchema", ctx).
+		Return(testUserAttributeSchema, nil)
+	db.On("GetUsers", ctx, model.UserFilter{
+		Attributes: map[string]interface{}{
+			"employee_id": float64(42),
+		},
+	}).Return([]model.User{{ID: "1"}}, nil)
+
+	useradm := NewUserAdm(nil, db, Config{})
+
+	fltr := model.UserFilter{
+		Attributes: map[string]interface{}{"employee_id": "42"},
+	}
+	users, err := useradm.GetUsers(ctx, fltr)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.User{{ID: "1"}}, users)
+	assert.Equal(t, "42", fltr.Attributes["employee_id"])
+
+	_, err = useradm.GetUsers(ctx, model.UserFilter{
+		Attributes: map[string]interface{}{"employee_id": "forty-two"},
+	})
+	assert.Equal(t, model.ErrInvalidUserAttribute, errors.Cause(err))
+
+	_, err = useradm.CountUsers(ctx, model.UserFilter{
+		Attributes: map[string]interface{}{"department": "R&D"},
+	})
+	assert.EqualError(t, err, "department: not searchable: "+
+		model.ErrInvalidUserAttribute.Error())
+}
+
+func TestUserAdmIssueLoginTokenAttributes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := &model.User{
+		ID: "2e8fb2f0-6fda-4ca1-8d2e-7ab8bbdd8b3e",
+		Attributes: model.UserAttributes{
+			"department":  "R&D",
+			"employee_id": float64(42),
+		},
+	}
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetActiveElevations", ctx, user.ID).Return(nil, nil)
+	db.On("GetUserAttributeSchema", ctx).
+		Return(testUserAttributeSchema, nil)
+	db.On("SaveToken", ctx, mock.MatchedBy(func(token *jwt.Token) bool {
+		return assert.Equal(t, map[string]interface{}{
+			"department": "R&D",
+		}, token.Attributes)
+	})).Return(nil)
+	db.On("UpdateLoginTs", ctx, user.ID).Return(nil)
+
+	useradm := NewUserAdm(nil, db, Config{
+		Issuer:         "mender",
+		ExpirationTime: 60,
+	})
+
+	token, err := useradm.issueLoginToken(ctx, user, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "R&D", token.Attributes["department"])
+}
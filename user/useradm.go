@@ -16,6 +16,7 @@ package useradm
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/mendersoftware/go-lib-micro/apiclient"
@@ -25,6 +26,7 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/mendersoftware/useradm/authz"
 	"github.com/mendersoftware/useradm/client/tenant"
 	"github.com/mendersoftware/useradm/jwt"
 	"github.com/mendersoftware/useradm/model"
@@ -43,6 +45,9 @@ var (
 		"maximum number of personal acess tokens reached for this user")
 	ErrDuplicateTokenName = errors.New(
 		"Personal Access Token with a given name already exists")
+	ErrUserDisabled      = errors.New("user account disabled")
+	ErrScopeNotPermitted = errors.New(
+		"requested scope exceeds the scope of the token owner")
 )
 
 const (
@@ -58,16 +63,36 @@ type App interface {
 	CreateUser(ctx context.Context, u *model.User) error
 	CreateUserInternal(ctx context.Context, u *model.UserInternal) error
 	UpdateUser(ctx context.Context, id string, u *model.UserUpdate) error
-	Verify(ctx context.Context, token *jwt.Token) error
+	// Verify checks the token and returns the identity of its subject
+	Verify(ctx context.Context, token *jwt.Token) (*model.TokenIdentity, error)
+	// IntrospectToken runs the same checks as Verify and describes the
+	// token; tokens failing the checks are reported as inactive.
+	IntrospectToken(ctx context.Context, token *jwt.Token) (*model.TokenIntrospection, error)
 	GetUsers(ctx context.Context, fltr model.UserFilter) ([]model.User, error)
+	// CountUsers counts the users matching the filter, regardless of
+	// the pagination
+	CountUsers(ctx context.Context, fltr model.UserFilter) (int64, error)
 	GetUser(ctx context.Context, id string) (*model.User, error)
 	DeleteUser(ctx context.Context, id string) error
+	// SetUserStatus disables or re-enables the user account
+	SetUserStatus(ctx context.Context, id string, status *model.UserStatusUpdate) error
+	// RestoreUser restores a deleted user which was not purged yet
+	RestoreUser(ctx context.Context, id string) error
+	// PurgeUser removes the user right away, regardless of the
+	// retention period
+	PurgeUser(ctx context.Context, id string) error
+	// PurgeDeletedUsers removes the users deleted longer than the
+	// retention period ago
+	PurgeDeletedUsers(ctx context.Context) error
 	SetPassword(ctx context.Context, u model.UserUpdate) error
 
 	// SignToken generates a signed
 	// token using configuration & method set up in UserAdmApp
 	SignToken(ctx context.Context, t *jwt.Token) (string, error)
 	DeleteToken(ctx context.Context, id string) error
+	// GetRevocationList returns the signed list of the tokens revoked
+	// after the given version, or the full list if since is 0
+	GetRevocationList(ctx context.Context, since int64) (string, error)
 
 	// IssuePersonalAccessToken issues Personal Access Token
 	IssuePersonalAccessToken(ctx context.Context, tr *model.TokenRequest) (string, error)
@@ -77,6 +102,141 @@ type App interface {
 	DeleteTokens(ctx context.Context, tenantId, userId string) error
 
 	CreateTenant(ctx context.Context, tenant model.NewTenant) error
+
+	// CreateServiceAccount creates a service account owned by the tenant
+	// and returns it together with its generated credentials
+	CreateServiceAccount(
+		ctx context.Context,
+		sa *model.ServiceAccountNew,
+	) (*model.ServiceAccountWithCredentials, error)
+	GetServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error)
+	GetServiceAccount(ctx context.Context, id string) (*model.ServiceAccount, error)
+	UpdateServiceAccount(ctx context.Context, id string, sa *model.ServiceAccountUpdate) error
+	// DeleteServiceAccount deletes the service account and its tokens
+	DeleteServiceAccount(ctx context.Context, id string) error
+	// IssueServiceAccountToken issues a personal access token for
+	// the service account
+	IssueServiceAccountToken(
+		ctx context.Context,
+		id string,
+		tr *model.TokenRequest,
+	) (string, error)
+	GetServiceAccountTokens(
+		ctx context.Context,
+		id string,
+	) ([]model.PersonalAccessToken, error)
+	DeleteServiceAccountToken(ctx context.Context, id, tokenID string) error
+	// RotateServiceAccountSecret generates a new client secret; the
+	// previous secrets stay valid for the configured overlap window
+	RotateServiceAccountSecret(
+		ctx context.Context,
+		id string,
+	) (*model.ServiceAccountCredentials, error)
+	// IssueClientCredentialsToken authenticates the service account
+	// with its client secret or JWT assertion and issues a short-lived
+	// access token (OAuth 2.0 client credentials grant)
+	IssueClientCredentialsToken(
+		ctx context.Context,
+		req *model.ClientCredentialsRequest,
+	) (*jwt.Token, error)
+
+	// GetRoles returns the built-in and the tenant's custom roles
+	GetRoles(ctx context.Context) ([]model.Role, error)
+	GetRole(ctx context.Context, name string) (*model.Role, error)
+	CreateRole(ctx context.Context, role *model.RoleNew) (*model.Role, error)
+	UpdateRole(ctx context.Context, name string, role *model.RoleUpdate) error
+	DeleteRole(ctx context.Context, name string) error
+	GetUserRoles(ctx context.Context, userID string) ([]string, error)
+	SetUserRoles(ctx context.Context, userID string, roles []string) error
+
+	GetGroups(ctx context.Context) ([]model.Group, error)
+	GetGroup(ctx context.Context, id string) (*model.Group, error)
+	CreateGroup(ctx context.Context, group *model.GroupNew) (*model.Group, error)
+	UpdateGroup(ctx context.Context, id string, group *model.GroupUpdate) error
+	DeleteGroup(ctx context.Context, id string) error
+	AddGroupMember(ctx context.Context, id, userID string) error
+	RemoveGroupMember(ctx context.Context, id, userID string) error
+	// GetUserGroups returns the groups the user belongs to
+	GetUserGroups(ctx context.Context, userID string) ([]model.Group, error)
+
+	// RequestElevation requests a role for the calling user for
+	// a limited time
+	RequestElevation(
+		ctx context.Context,
+		elevation *model.ElevationNew,
+	) (*model.Elevation, error)
+	GetElevations(ctx context.Context, userID string) ([]model.Elevation, error)
+	GetElevation(ctx context.Context, id string) (*model.Elevation, error)
+	ApproveElevation(ctx context.Context, id string) error
+	RejectElevation(ctx context.Context, id string) error
+
+	// RequestApproval records the action for the approval of a second
+	// admin if the tenant requires it; returns nil if it does not
+	RequestApproval(
+		ctx context.Context,
+		request *model.ApprovalRequest,
+	) (*model.Approval, error)
+	GetApprovals(ctx context.Context) ([]model.Approval, error)
+	GetApproval(ctx context.Context, id string) (*model.Approval, error)
+	// ApproveAction approves the pending action and carries it out
+	ApproveAction(ctx context.Context, id string) error
+	RejectAction(ctx context.Context, id string) error
+	GetApprovalSettings(ctx context.Context) (*model.ApprovalSettings, error)
+	SaveApprovalSettings(ctx context.Context, settings *model.ApprovalSettings) error
+
+	GetSAMLConfig(ctx context.Context) (*model.SAMLConfig, error)
+	SaveSAMLConfig(ctx context.Context, config *model.SAMLConfig) error
+	DeleteSAMLConfig(ctx context.Context) error
+	// GetSAMLMetadata returns the XML metadata of the service provider
+	GetSAMLMetadata(ctx context.Context, sp *model.SAMLServiceProvider) ([]byte, error)
+	// MakeSAMLAuthnRequest starts the single sign-on with the tenant's
+	// identity provider
+	MakeSAMLAuthnRequest(
+		ctx context.Context,
+		sp *model.SAMLServiceProvider,
+		relayState string,
+	) (*model.SAMLAuthnRequest, error)
+	// LoginSAML validates the response of the identity provider and
+	// issues the session token of the asserted user
+	LoginSAML(
+		ctx context.Context,
+		sp *model.SAMLServiceProvider,
+		response []byte,
+		requestIDs []string,
+	) (*jwt.Token, error)
+
+	GetOIDCConfig(ctx context.Context) (*model.OIDCConfig, error)
+	SaveOIDCConfig(ctx context.Context, config *model.OIDCConfig) error
+	DeleteOIDCConfig(ctx context.Context) error
+	// GetUserAttributeSchema returns the custom attributes defined by
+	// the tenant
+	GetUserAttributeSchema(ctx context.Context) (*model.UserAttributeSchema, error)
+	SaveUserAttributeSchema(ctx context.Context, schema *model.UserAttributeSchema) error
+	// MakeOIDCAuthRequest starts the single sign-on with the tenant's
+	// OpenID Connect provider
+	MakeOIDCAuthRequest(
+		ctx context.Context,
+		rp *model.OIDCRelyingParty,
+		redirect string,
+	) (*model.OIDCAuthRequest, error)
+	// LoginOIDC exchanges the authorization code for the ID token and
+	// issues the session token of the user, provisioning new users
+	LoginOIDC(
+		ctx context.Context,
+		rp *model.OIDCRelyingParty,
+		code string,
+		state string,
+		request *model.OIDCAuthRequest,
+	) (*jwt.Token, error)
+
+	// NewSCIMToken generates a SCIM token for the tenant; the token is
+	// valid once saved with SaveSCIMToken, replacing the previous one
+	NewSCIMToken(ctx context.Context, req *model.SCIMTokenRequest) (*model.SCIMToken, error)
+	SaveSCIMToken(ctx context.Context, token *model.SCIMToken) error
+	GetSCIMToken(ctx context.Context) (*model.SCIMToken, error)
+	DeleteSCIMToken(ctx context.Context) error
+	// AuthenticateSCIM looks up the token presented by a SCIM client
+	AuthenticateSCIM(ctx context.Context, token string) (*model.SCIMToken, error)
 }
 
 type Config struct {
@@ -90,6 +250,27 @@ type Config struct {
 	// how often we should update personal access token
 	// with last used timestamp
 	TokenLastUsedUpdateFreqMinutes int
+	// expiration time of the tokens issued with the client
+	// credentials grant, in seconds
+	ClientCredentialsExpirationTime int64
+	// how long the previous client secrets remain valid after
+	// a secret rotation, in seconds
+	ClientSecretRotationOverlap int64
+	// maximum length of a privilege elevation, in minutes;
+	// zero means no limit
+	ElevationMaxDuration int
+	// whether the privilege elevations need the approval of another
+	// admin before the role is granted
+	ElevationApproval bool
+	// the roles the users may request with a privilege elevation, by
+	// the (lowercase) names of their own roles
+	ElevationRoles map[string][]string
+	// how long the actions wait for the approval of a second admin,
+	// in seconds
+	ApprovalExpiration int64
+	// how long the deleted users are kept before they are purged, in
+	// seconds; zero means the users are removed right away
+	UserRetention int64
 }
 
 type ApiClientGetter func() apiclient.HttpRunner
@@ -102,10 +283,13 @@ type UserAdm struct {
 	// JWT serialized/deserializer
 	jwtHandler   jwt.Handler
 	db           store.DataStore
+	configMutex  sync.RWMutex
 	config       Config
 	verifyTenant bool
 	cTenant      tenant.ClientRunner
 	clientGetter ApiClientGetter
+	credentials  CredentialChecker
+	verifyCache  *VerificationCache
 }
 
 func NewUserAdm(jwtHandler jwt.Handler, db store.DataStore, config Config) *UserAdm {
@@ -115,9 +299,25 @@ func NewUserAdm(jwtHandler jwt.Handler, db store.DataStore, config Config) *User
 		db:           db,
 		config:       config,
 		clientGetter: simpleApiClientGetter,
+		credentials:  passwordChecker{},
 	}
 }
 
+// UpdateConfig replaces the configuration at run time, e.g. when the
+// service configuration is reloaded.
+func (u *UserAdm) UpdateConfig(config Config) {
+	u.configMutex.Lock()
+	defer u.configMutex.Unlock()
+	u.config = config
+	u.verifyCache.Purge()
+}
+
+func (u *UserAdm) getConfig() Config {
+	u.configMutex.RLock()
+	defer u.configMutex.RUnlock()
+	return u.config
+}
+
 func (u *UserAdm) HealthCheck(ctx context.Context) error {
 	err := u.db.Ping(ctx)
 	if err != nil {
@@ -136,7 +336,6 @@ func (u *UserAdm) HealthCheck(ctx context.Context) error {
 
 func (u *UserAdm) Login(ctx context.Context, email model.Email, pass string) (*jwt.Token, error) {
 	var ident identity.Identity
-	l := log.FromContext(ctx)
 
 	if email == "" {
 		return nil, ErrUnauthorized
@@ -164,26 +363,58 @@ func (u *UserAdm) Login(ctx context.Context, email model.Email, pass string) (*j
 
 	//get user
 	user, err := u.db.GetUserByEmail(ctx, email)
-
-	if user == nil && err == nil {
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get user")
+	} else if user != nil && user.IsDeleted() {
 		return nil, ErrUnauthorized
 	}
 
+	//verify credentials
+	authenticated, err := u.credentials.CheckCredentials(ctx, email, pass, user)
 	if err != nil {
-		return nil, errors.Wrap(err, "useradm: failed to get user")
+		return nil, errors.Wrap(err, "useradm: failed to check credentials")
+	} else if authenticated == nil {
+		return nil, ErrUnauthorized
+	}
+	if err = u.checkPasswordLogin(ctx); err != nil {
+		return nil, err
 	}
 
-	//verify password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pass))
+	user, err = u.syncUser(ctx, user, authenticated)
 	if err != nil {
+		return nil, err
+	}
+
+	return u.issueLoginToken(ctx, user, ident.Tenant)
+}
+
+// issueLoginToken generates and saves the session token of the
+// authenticated user.
+func (u *UserAdm) issueLoginToken(
+	ctx context.Context,
+	user *model.User,
+	tenantID string,
+) (*jwt.Token, error) {
+	l := log.FromContext(ctx)
+
+	if user.IsDeleted() {
 		return nil, ErrUnauthorized
+	} else if user.IsDisabled() {
+		return nil, ErrUserDisabled
 	}
 
-	//generate and save token
-	t, err := u.generateToken(user.ID, scope.All, ident.Tenant)
+	t, err := u.generateToken(user.ID, scope.All, tenantID)
 	if err != nil {
 		return nil, errors.Wrap(err, "useradm: failed to generate token")
 	}
+	t.Elevation, err = u.elevationClaim(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	t.Attributes, err = u.attributeClaims(ctx, user)
+	if err != nil {
+		return nil, err
+	}
 
 	err = u.db.SaveToken(ctx, t)
 	if err != nil {
@@ -198,18 +429,19 @@ func (u *UserAdm) Login(ctx context.Context, email model.Email, pass string) (*j
 }
 
 func (u *UserAdm) generateToken(subject, scope, tenant string) (*jwt.Token, error) {
+	config := u.getConfig()
 	id := oid.NewUUIDv4()
 	subjectID := oid.FromString(subject)
 	now := jwt.Time{Time: time.Now()}
 	ret := &jwt.Token{Claims: jwt.Claims{
 		ID:        id,
 		Subject:   subjectID,
-		Issuer:    u.config.Issuer,
+		Issuer:    config.Issuer,
 		IssuedAt:  now,
 		NotBefore: now,
 		ExpiresAt: jwt.Time{
 			Time: now.Add(time.Second *
-				time.Duration(u.config.ExpirationTime)),
+				time.Duration(config.ExpirationTime)),
 		},
 		Tenant: tenant,
 		Scope:  scope,
@@ -219,24 +451,41 @@ func (u *UserAdm) generateToken(subject, scope, tenant string) (*jwt.Token, erro
 }
 
 func (u *UserAdm) SignToken(ctx context.Context, t *jwt.Token) (string, error) {
-	return u.jwtHandler.ToJWT(t)
+	return u.jwtHandler.ToJWT(ctx, t)
 }
 
 func (u *UserAdm) Logout(ctx context.Context, token *jwt.Token) error {
-	return u.db.DeleteToken(ctx, token.Subject, token.ID)
+	err := u.db.DeleteToken(ctx, token.Subject, token.ID)
+	u.verifyCache.InvalidateToken(token.ID.String())
+	return err
 }
 
+// CreateUser creates the user; users created without a password can only
+// log in through single sign-on.
 func (ua *UserAdm) CreateUser(ctx context.Context, u *model.User) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return errors.Wrap(err, "failed to generate password hash")
+	if err := ua.checkUserAttributes(ctx, u.ID, u.Attributes, false); err != nil {
+		return err
+	}
+	if u.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return errors.Wrap(err, "failed to generate password hash")
+		}
+		u.Password = string(hash)
+	}
+	if len(u.Roles) > 0 {
+		if err := ua.checkAssignableRoles(ctx, u.Roles); err != nil {
+			return err
+		}
 	}
-	u.Password = string(hash)
 
 	return ua.doCreateUser(ctx, u, true)
 }
 
 func (ua *UserAdm) CreateUserInternal(ctx context.Context, u *model.UserInternal) error {
+	if err := ua.checkUserAttributes(ctx, u.ID, u.Attributes, false); err != nil {
+		return err
+	}
 	if u.PasswordHash != "" {
 		u.Password = u.PasswordHash
 	} else {
@@ -246,6 +495,10 @@ func (ua *UserAdm) CreateUserInternal(ctx context.Context, u *model.UserInternal
 		}
 		u.Password = string(hash)
 	}
+	// tenantadm creates the initial user of the tenant
+	if len(u.Roles) == 0 {
+		u.Roles = []string{model.RoleAdmin}
+	}
 
 	return ua.doCreateUser(ctx, &u.User, u.ShouldPropagate())
 }
@@ -266,6 +519,20 @@ func (ua *UserAdm) doCreateUser(ctx context.Context, u *model.User, propagate bo
 		u.ID = id.String()
 	}
 
+	// the users are created active and not deleted; the status is
+	// changed with SetUserStatus only, and the users are deleted with
+	// DeleteUser only
+	u.Status, u.StatusReason, u.StatusTs = "", "", nil
+	u.DeletedTs = nil
+
+	// the users get read-only access unless told otherwise; this also
+	// applies to the users provisioned on their first login
+	if len(u.Roles) == 0 {
+		u.Roles = []string{model.RoleReadOnly}
+	} else if err := ua.checkRoles(ctx, u.Roles); err != nil {
+		return err
+	}
+
 	id := identity.FromContext(ctx)
 	if ua.verifyTenant && propagate {
 		tenantErr = ua.cTenant.CreateUser(ctx,
@@ -334,6 +601,11 @@ func (ua *UserAdm) compensateTenantUser(ctx context.Context, userId, tenantId st
 }
 
 func (ua *UserAdm) UpdateUser(ctx context.Context, id string, u *model.UserUpdate) error {
+	if len(u.Attributes) > 0 {
+		if err := ua.checkUserAttributes(ctx, id, u.Attributes, true); err != nil {
+			return err
+		}
+	}
 	if len(u.Password) > 0 {
 		user, err := ua.db.GetUserAndPasswordById(ctx, id)
 		if err != nil {
@@ -380,6 +652,7 @@ func (ua *UserAdm) UpdateUser(ctx context.Context, id string, u *model.UserUpdat
 		} else {
 			err = ua.db.DeleteTokensByUserId(ctx, id)
 		}
+		ua.verifyCache.InvalidateUser(id)
 	}
 
 	if err != nil {
@@ -392,68 +665,190 @@ func (ua *UserAdm) UpdateUser(ctx context.Context, id string, u *model.UserUpdat
 	return nil
 }
 
-func (ua *UserAdm) Verify(ctx context.Context, token *jwt.Token) error {
+func (ua *UserAdm) Verify(
+	ctx context.Context,
+	token *jwt.Token,
+) (*model.TokenIdentity, error) {
+	epoch := ua.verifyCache.Epoch()
+	if token != nil {
+		if ti := ua.verifyCache.Get(token.ID.String()); ti != nil {
+			if ti.TokenType == model.TokenTypePersonalAccessToken {
+				if err := ua.updateCachedTokenLastUsed(ctx, token); err != nil {
+					return nil, err
+				}
+			}
+			return ti, nil
+		}
+	}
+
+	dbToken, user, err := ua.verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	ti := &model.TokenIdentity{
+		UserID:    token.Claims.Subject.String(),
+		Tenant:    token.Claims.Tenant,
+		TokenType: model.TokenTypeSession,
+		TokenID:   token.Claims.ID.String(),
+	}
+	if token.Claims.ServiceAccount {
+		// service accounts are not assigned roles
+		ti.TokenType = model.TokenTypeServiceAccount
+		ua.verifyCache.Add(token, ti, time.Time{}, epoch)
+		return ti, nil
+	}
+	if dbToken.TokenName != nil {
+		ti.TokenType = model.TokenTypePersonalAccessToken
+	}
+	ti.Email = user.Email
+	var rolesExpire time.Time
+	ti.Roles, rolesExpire, err = authz.EffectiveRoles(ctx, ua.db, token, user)
+	if err != nil {
+		return nil, errors.Wrap(err, "useradm: failed to get user roles")
+	}
+	ua.verifyCache.Add(token, ti, rolesExpire, epoch)
+	if dbToken.LastUsed != nil {
+		ua.verifyCache.SetLastUsed(ti.TokenID, *dbToken.LastUsed)
+	}
+	return ti, nil
+}
+
+// updateCachedTokenLastUsed updates the last used timestamp of the
+// personal access token whose verification is cached, as often as verify
+// does.
+func (ua *UserAdm) updateCachedTokenLastUsed(ctx context.Context, token *jwt.Token) error {
+	freq := ua.getConfig().TokenLastUsedUpdateFreqMinutes
+	if freq <= 0 {
+		return nil
+	}
+	t := time.Now().Add(-time.Minute * time.Duration(freq))
+	if ua.verifyCache.LastUsedBefore(token.ID.String(), t) {
+		if err := ua.db.UpdateTokenLastUsed(ctx, token.ID); err != nil {
+			return errors.Wrap(err, "useradm: failed to update token")
+		}
+	}
+	return nil
+}
+
+func (ua *UserAdm) IntrospectToken(
+	ctx context.Context,
+	token *jwt.Token,
+) (*model.TokenIntrospection, error) {
+	if token == nil {
+		return &model.TokenIntrospection{Active: false}, nil
+	}
+
+	// the caller is not the token bearer: set up the identity of the
+	// token for the tenant-scoped lookups
+	ctx = identity.WithContext(ctx, &identity.Identity{
+		Subject: token.Claims.Subject.String(),
+		Tenant:  token.Claims.Tenant,
+		IsUser:  token.Claims.User,
+	})
+	dbToken, _, err := ua.verify(ctx, token)
+	switch err {
+	case nil:
+		return model.NewTokenIntrospection(token, dbToken), nil
+	case ErrUnauthorized, jwt.ErrTokenInvalid, jwt.ErrTokenExpired:
+		return &model.TokenIntrospection{Active: false}, nil
+	default:
+		return nil, err
+	}
+}
+
+// verify checks the token and returns its database record together with
+// the user the token was issued to (nil for service accounts)
+func (ua *UserAdm) verify(
+	ctx context.Context,
+	token *jwt.Token,
+) (*jwt.Token, *model.User, error) {
 
 	if token == nil {
-		return ErrUnauthorized
+		return nil, nil, ErrUnauthorized
 	}
 
 	l := log.FromContext(ctx)
+	config := ua.getConfig()
 
 	if !token.Claims.User {
 		l.Errorf("not a user token")
-		return ErrUnauthorized
+		return nil, nil, ErrUnauthorized
 	}
 
 	if ua.verifyTenant {
 		if token.Claims.Tenant == "" {
 			l.Errorf("Token has no tenant claim")
-			return jwt.ErrTokenInvalid
+			return nil, nil, jwt.ErrTokenInvalid
 		}
 	} else if token.Claims.Tenant != "" {
 		l.Errorf("Unexpected tenant claim: %s in the token", token.Claims.Tenant)
-		return jwt.ErrTokenInvalid
+		return nil, nil, jwt.ErrTokenInvalid
 	}
 
 	//check service-specific claims - iss
-	if token.Claims.Issuer != ua.config.Issuer {
-		return ErrUnauthorized
+	if token.Claims.Issuer != config.Issuer {
+		return nil, nil, ErrUnauthorized
 	}
 
-	user, err := ua.db.GetUserById(ctx, token.Claims.Subject.String())
-	if user == nil && err == nil {
-		return ErrUnauthorized
-	}
-	if err != nil {
-		return errors.Wrap(err, "useradm: failed to get user")
+	var user *model.User
+	if token.Claims.ServiceAccount {
+		sa, err := ua.db.GetServiceAccount(ctx, token.Claims.Subject.String())
+		if sa == nil && err == nil {
+			return nil, nil, ErrUnauthorized
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "useradm: failed to get service account")
+		}
+	} else {
+		var err error
+		user, err = ua.db.GetUserById(ctx, token.Claims.Subject.String())
+		if user == nil && err == nil {
+			return nil, nil, ErrUnauthorized
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "useradm: failed to get user")
+		}
+		if user.IsDisabled() {
+			l.Errorf("User account disabled")
+			return nil, nil, ErrUnauthorized
+		} else if user.IsDeleted() {
+			l.Errorf("User deleted")
+			return nil, nil, ErrUnauthorized
+		}
 	}
 
 	dbToken, err := ua.db.GetTokenById(ctx, token.ID)
 	if dbToken == nil && err == nil {
-		return ErrUnauthorized
+		return nil, nil, ErrUnauthorized
 	}
 	if err != nil {
-		return errors.Wrap(err, "useradm: failed to get token")
+		return nil, nil, errors.Wrap(err, "useradm: failed to get token")
 	}
 
 	// in case the token is a personal access token, update last used timestam
 	// to not overload the database with writes to tokens collection, we do not
 	// update the timestamp every time, but instead we wait some configurable
 	// amount of time between updates
-	if dbToken.TokenName != nil && ua.config.TokenLastUsedUpdateFreqMinutes > 0 {
+	if dbToken.TokenName != nil && config.TokenLastUsedUpdateFreqMinutes > 0 {
 		t := time.Now().Add(
-			(-time.Minute * time.Duration(ua.config.TokenLastUsedUpdateFreqMinutes)))
+			(-time.Minute * time.Duration(config.TokenLastUsedUpdateFreqMinutes)))
 		if dbToken.LastUsed == nil || dbToken.LastUsed.Before(t) {
 			if err := ua.db.UpdateTokenLastUsed(ctx, token.ID); err != nil {
-				return err
+				return nil, nil, err
 			}
+			now := time.Now()
+			dbToken.LastUsed = &now
 		}
 	}
 
-	return nil
+	return dbToken, user, nil
 }
 
 func (ua *UserAdm) GetUsers(ctx context.Context, fltr model.UserFilter) ([]model.User, error) {
+	if err := ua.userAttributeFilter(ctx, &fltr); err != nil {
+		return nil, err
+	}
 	users, err := ua.db.GetUsers(ctx, fltr)
 	if err != nil {
 		return nil, errors.Wrap(err, "useradm: failed to get users")
@@ -462,16 +857,72 @@ func (ua *UserAdm) GetUsers(ctx context.Context, fltr model.UserFilter) ([]model
 	return users, nil
 }
 
+func (ua *UserAdm) CountUsers(ctx context.Context, fltr model.UserFilter) (int64, error) {
+	if err := ua.userAttributeFilter(ctx, &fltr); err != nil {
+		return -1, err
+	}
+	count, err := ua.db.CountUsers(ctx, fltr)
+	if err != nil {
+		return -1, errors.Wrap(err, "useradm: failed to count users")
+	}
+
+	return count, nil
+}
+
 func (ua *UserAdm) GetUser(ctx context.Context, id string) (*model.User, error) {
 	user, err := ua.db.GetUserById(ctx, id)
 	if err != nil {
 		return nil, errors.Wrap(err, "useradm: failed to get user")
+	} else if user != nil && user.IsDeleted() {
+		return nil, nil
 	}
 
 	return user, nil
 }
 
+// DeleteUser deletes the user; the user is kept, hidden and unable to
+// log in, for the retention period, and can be restored until purged.
+// Deleting a user which does not exist, or is deleted, is not an error.
 func (ua *UserAdm) DeleteUser(ctx context.Context, id string) error {
+	if ua.getConfig().UserRetention <= 0 {
+		return ua.PurgeUser(ctx, id)
+	}
+
+	// the user is disabled in tenantadm, keeping the email reserved
+	if ua.verifyTenant {
+		ident := identity.FromContext(ctx)
+		err := ua.cTenant.UpdateUser(ctx,
+			ident.Tenant,
+			id,
+			&tenant.UserUpdate{
+				Status: string(model.UserStatusDisabled),
+			},
+			ua.clientGetter())
+
+		if err != nil && err != tenant.ErrUserNotFound {
+			return errors.Wrap(err, "useradm: failed to update user in tenantadm")
+		}
+	}
+
+	err := ua.db.SoftDeleteUser(ctx, id)
+	ua.verifyCache.InvalidateUser(id)
+	if err == store.ErrUserNotFound {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete user")
+	}
+
+	// remove user tokens
+	err = ua.db.DeleteTokensByUserId(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete user tokens")
+	}
+	return nil
+}
+
+// PurgeUser removes the user together with the user's tokens, settings
+// and group memberships.
+func (ua *UserAdm) PurgeUser(ctx context.Context, id string) error {
 	if ua.verifyTenant {
 		identity := identity.FromContext(ctx)
 		err := ua.cTenant.DeleteUser(ctx, identity.Tenant, id, ua.clientGetter())
@@ -482,16 +933,190 @@ func (ua *UserAdm) DeleteUser(ctx context.Context, id string) error {
 	}
 
 	err := ua.db.DeleteUser(ctx, id)
+	ua.verifyCache.InvalidateUser(id)
 	if err != nil {
 		return errors.Wrap(err, "useradm: failed to delete user")
 	}
 
+	return ua.deleteUserData(ctx, id)
+}
+
+// purgeDeletedUser removes the user deleted before the time, unless the
+// user was restored or purged by another replica meanwhile; the user is
+// removed from the database first, so that only one replica proceeds.
+func (ua *UserAdm) purgeDeletedUser(ctx context.Context, id string, before time.Time) error {
+	err := ua.db.PurgeDeletedUser(ctx, id, before)
+	if err == store.ErrUserNotFound {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete user")
+	}
+
+	err = ua.deleteUserData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if ua.verifyTenant {
+		identity := identity.FromContext(ctx)
+		err := ua.cTenant.DeleteUser(ctx, identity.Tenant, id, ua.clientGetter())
+
+		if err != nil {
+			return errors.Wrap(err, "useradm: failed to delete user in tenantadm")
+		}
+	}
+	return nil
+}
+
+// deleteUserData removes the user's tokens, settings and group
+// memberships.
+func (ua *UserAdm) deleteUserData(ctx context.Context, id string) error {
 	// remove user tokens
-	err = ua.db.DeleteTokensByUserId(ctx, id)
+	err := ua.db.DeleteTokensByUserId(ctx, id)
 	if err != nil {
 		return errors.Wrap(err, "useradm: failed to delete user tokens")
 	}
 
+	// remove the user from the groups
+	err = ua.db.RemoveUserFromGroups(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to remove user from groups")
+	}
+
+	err = ua.db.DeleteUserSettings(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to delete user settings")
+	}
+
+	return nil
+}
+
+// RestoreUser restores the deleted user, with the status, roles, groups
+// and settings the user had when deleted.
+func (ua *UserAdm) RestoreUser(ctx context.Context, id string) error {
+	user, err := ua.db.GetUserById(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get user")
+	} else if user == nil || !user.IsDeleted() {
+		return store.ErrUserNotFound
+	}
+
+	if ua.verifyTenant {
+		status := model.UserStatusActive
+		if user.IsDisabled() {
+			status = model.UserStatusDisabled
+		}
+		ident := identity.FromContext(ctx)
+		err := ua.cTenant.UpdateUser(ctx,
+			ident.Tenant,
+			id,
+			&tenant.UserUpdate{
+				Status: string(status),
+			},
+			ua.clientGetter())
+
+		switch err {
+		case nil:
+		case tenant.ErrUserNotFound:
+			return store.ErrUserNotFound
+		default:
+			return errors.Wrap(err, "useradm: failed to update user in tenantadm")
+		}
+	}
+
+	err = ua.db.RestoreUser(ctx, id)
+	if err == store.ErrUserNotFound {
+		return err
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to restore user")
+	}
+	return nil
+}
+
+// PurgeDeletedUsers removes the users of all the tenants deleted longer
+// than the retention period ago; the failures are logged, and the other
+// tenants and users are purged nevertheless.
+func (ua *UserAdm) PurgeDeletedUsers(ctx context.Context) error {
+	l := log.FromContext(ctx)
+	retention := time.Duration(ua.getConfig().UserRetention) * time.Second
+	before := time.Now().Add(-retention)
+
+	tenants, err := ua.db.GetDeletedUserTenants(ctx, before)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to get deleted users")
+	}
+	failed := 0
+	for _, tenantID := range tenants {
+		tenantCtx := ctx
+		if tenantID != "" {
+			tenantCtx = identity.WithContext(ctx, &identity.Identity{
+				Tenant: tenantID,
+			})
+		}
+		users, err := ua.db.GetUsers(tenantCtx, model.UserFilter{
+			DeletedBefore: &before,
+		})
+		if err != nil {
+			l.Errorf("failed to get the deleted users of the tenant %q: %s",
+				tenantID, err.Error())
+			failed++
+			continue
+		}
+		for _, user := range users {
+			if err := ua.purgeDeletedUser(tenantCtx, user.ID, before); err != nil {
+				l.Errorf("failed to purge the user %s of the tenant %q: %s",
+					user.ID, tenantID, err.Error())
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("useradm: %d failures purging the deleted users", failed)
+	}
+	return nil
+}
+
+// SetUserStatus disables or re-enables the user account; the tokens of the
+// disabled users are revoked, while their settings, roles and groups are
+// kept for when they are re-enabled.
+func (ua *UserAdm) SetUserStatus(
+	ctx context.Context,
+	id string,
+	status *model.UserStatusUpdate,
+) error {
+	if ua.verifyTenant {
+		ident := identity.FromContext(ctx)
+		err := ua.cTenant.UpdateUser(ctx,
+			ident.Tenant,
+			id,
+			&tenant.UserUpdate{
+				Status: string(status.Status),
+			},
+			ua.clientGetter())
+
+		switch err {
+		case nil:
+		case tenant.ErrUserNotFound:
+			return store.ErrUserNotFound
+		default:
+			return errors.Wrap(err, "useradm: failed to update user in tenantadm")
+		}
+	}
+
+	err := ua.db.SetUserStatus(ctx, id, status)
+	if err == store.ErrUserNotFound {
+		return err
+	} else if err != nil {
+		return errors.Wrap(err, "useradm: failed to update user status")
+	}
+
+	if status.Status == model.UserStatusDisabled {
+		err = ua.db.DeleteTokensByUserId(ctx, id)
+		ua.verifyCache.InvalidateUser(id)
+		if err != nil {
+			return errors.Wrap(err, "useradm: failed to delete user tokens")
+		}
+	}
 	return nil
 }
 
@@ -526,6 +1151,7 @@ func (ua *UserAdm) SetPassword(ctx context.Context, uu model.UserUpdate) error {
 		} else {
 			err = ua.db.DeleteTokensByUserId(ctx, u.ID)
 		}
+		ua.verifyCache.InvalidateUser(u.ID)
 	}
 	if err != nil {
 		return errors.Wrap(err, "useradm: failed to update user information")
@@ -543,8 +1169,10 @@ func (ua *UserAdm) DeleteTokens(ctx context.Context, tenantId, userId string) er
 
 	if userId != "" {
 		err = ua.db.DeleteTokensByUserId(ctx, userId)
+		ua.verifyCache.InvalidateUser(userId)
 	} else {
 		err = ua.db.DeleteTokens(ctx)
+		ua.verifyCache.InvalidateTenant(tenantId)
 	}
 
 	if err != nil && err != store.ErrTokenNotFound {
@@ -567,21 +1195,39 @@ func (u *UserAdm) IssuePersonalAccessToken(
 	if id == nil {
 		return "", errors.New("identity not present in the context")
 	}
-	if u.config.LimitTokensPerUser > 0 {
-		count, err := u.db.CountPersonalAccessTokens(ctx, id.Subject)
+	return u.issuePersonalAccessToken(ctx, id.Subject, id.Tenant, false, tr)
+}
+
+// issuePersonalAccessToken issues a long-lived, named token for the
+// subject, which is either a user or a service account
+func (u *UserAdm) issuePersonalAccessToken(
+	ctx context.Context,
+	subject string,
+	tenant string,
+	serviceAccount bool,
+	tr *model.TokenRequest,
+) (string, error) {
+	config := u.getConfig()
+	if config.LimitTokensPerUser > 0 {
+		count, err := u.db.CountPersonalAccessTokens(ctx, subject)
 		if err != nil {
 			return "", errors.Wrap(err, "useradm: failed to count personal access tokens")
 		}
-		if count >= int64(u.config.LimitTokensPerUser) {
+		if count >= int64(config.LimitTokensPerUser) {
 			return "", ErrTooManyTokens
 		}
 	}
+	tokenScope, err := grantScope(ctx, tr.Scopes)
+	if err != nil {
+		return "", err
+	}
 	//generate and save token
-	t, err := u.generateToken(id.Subject, scope.All, id.Tenant)
+	t, err := u.generateToken(subject, tokenScope, tenant)
 	if err != nil {
 		return "", errors.Wrap(err, "useradm: failed to generate token")
 	}
 	// update claims
+	t.ServiceAccount = serviceAccount
 	t.TokenName = tr.Name
 	now := jwt.Time{Time: time.Now()}
 	t.ExpiresAt = jwt.Time{
@@ -597,7 +1243,25 @@ func (u *UserAdm) IssuePersonalAccessToken(
 	}
 
 	// sign token
-	return u.jwtHandler.ToJWT(t)
+	return u.jwtHandler.ToJWT(ctx, t)
+}
+
+// grantScope returns the scope of a new token; the requested scopes must
+// not exceed the scope of the owner, which is further limited by the scope
+// of the token the request was made with (if known).
+func grantScope(ctx context.Context, requested []string) (string, error) {
+	// users and service accounts hold the full scope of the tenant
+	granted := []string{scope.All}
+	if callerScopes := scope.FromContext(ctx); callerScopes != nil {
+		granted = callerScopes
+	}
+	if len(requested) == 0 {
+		return scope.Join(granted), nil
+	}
+	if !scope.Covers(granted, requested) {
+		return "", ErrScopeNotPermitted
+	}
+	return scope.Join(requested), nil
 }
 
 func (ua *UserAdm) GetPersonalAccessTokens(
@@ -617,7 +1281,9 @@ func (ua *UserAdm) DeleteToken(ctx context.Context, id string) error {
 	if identity == nil {
 		return errors.New("identity not present in the context")
 	}
-	err := ua.db.DeleteToken(ctx, oid.FromString(identity.Subject), oid.FromString(id))
+	tokenID := oid.FromString(id)
+	err := ua.db.DeleteToken(ctx, oid.FromString(identity.Subject), tokenID)
+	ua.verifyCache.InvalidateToken(tokenID.String())
 	if err != nil {
 		return errors.Wrap(err, "useradm: failed to delete token")
 	}
@@ -115,6 +115,7 @@ func TestUserAdmSignToken(t *testing.T) {
 
 			mockJWTHandler := mjwt.Handler{}
 			mockJWTHandler.On("ToJWT",
+				ContextMatcher(),
 				mock.AnythingOfType("*jwt.Token"),
 			).Return(tc.signed, tc.signErr)
 
@@ -132,7 +133,29 @@ func TestUserAdmSignToken(t *testing.T) {
 
 }
 
+func TestUserAdmUpdateConfig(t *testing.T) {
+	useradm := NewUserAdm(nil, nil, Config{
+		Issuer:         "mender",
+		ExpirationTime: 3600,
+	})
+	token, err := useradm.generateToken(oid.NewUUIDv4().String(), scope.All, "")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.Claims.ExpiresAt.Time,
+		time.Minute)
+
+	useradm.UpdateConfig(Config{
+		Issuer:         "mender",
+		ExpirationTime: 60,
+	})
+	token, err = useradm.generateToken(oid.NewUUIDv4().String(), scope.All, "")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), token.Claims.ExpiresAt.Time,
+		10*time.Second)
+}
+
 func TestUserAdmLogin(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	soon := time.Now().Add(time.Minute)
 	testCases := map[string]struct {
 		inEmail    model.Email
 		inPassword string
@@ -144,6 +167,12 @@ func TestUserAdmLogin(t *testing.T) {
 		dbUser    *model.User
 		dbUserErr error
 
+		dbElevations    []model.Elevation
+		dbElevationsErr error
+
+		dbOIDCConfig    *model.OIDCConfig
+		dbOIDCConfigErr error
+
 		dbTokenErr  error
 		dbUpdateErr error
 
@@ -176,6 +205,59 @@ func TestUserAdmLogin(t *testing.T) {
 				ExpirationTime: 10,
 			},
 		},
+		"ok, elevated": {
+			inEmail:    "foo@bar.com",
+			inPassword: "correcthorsebatterystaple",
+
+			dbUser: &model.User{
+				ID:       oid.NewUUIDv5("1234").String(),
+				Email:    "foo@bar.com",
+				Password: `$2a$10$wMW4kC6o1fY87DokgO.lDektJO7hBXydf4B.yIWmE8hR9jOiO8way`,
+			},
+			dbElevations: []model.Elevation{{
+				Role:      model.RoleAdmin,
+				Status:    model.ElevationStatusActive,
+				ExpiresTs: &later,
+			}, {
+				Role:      model.RoleReleaseManager,
+				Status:    model.ElevationStatusActive,
+				ExpiresTs: &soon,
+			}},
+
+			outToken: &jwt.Token{
+				Claims: jwt.Claims{
+					Subject: oid.NewUUIDv5("1234"),
+					Scope:   scope.All,
+					Elevation: &jwt.Elevation{
+						Roles:     []string{model.RoleAdmin, model.RoleReleaseManager},
+						ExpiresAt: jwt.Time{Time: soon},
+					},
+				},
+			},
+
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+		},
+		"error: get elevations": {
+			inEmail:    "foo@bar.com",
+			inPassword: "correcthorsebatterystaple",
+
+			dbUser: &model.User{
+				ID:       oid.NewUUIDv5("1234").String(),
+				Email:    "foo@bar.com",
+				Password: `$2a$10$wMW4kC6o1fY87DokgO.lDektJO7hBXydf4B.yIWmE8hR9jOiO8way`,
+			},
+			dbElevationsErr: errors.New("db failed"),
+
+			outErr: errors.New("useradm: failed to get elevations: db failed"),
+
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+		},
 		"ok, multitenant": {
 			inEmail:    "foo@bar.com",
 			inPassword: "correcthorsebatterystaple",
@@ -292,6 +374,88 @@ func TestUserAdmLogin(t *testing.T) {
 				ExpirationTime: 10,
 			},
 		},
+		"ok, password login enabled": {
+			inEmail:    "foo@bar.com",
+			inPassword: "correcthorsebatterystaple",
+
+			dbUser: &model.User{
+				ID:       oid.NewUUIDv5("1234").String(),
+				Email:    "foo@bar.com",
+				Password: `$2a$10$wMW4kC6o1fY87DokgO.lDektJO7hBXydf4B.yIWmE8hR9jOiO8way`,
+			},
+			dbOIDCConfig: &model.OIDCConfig{
+				Issuer:   "https://accounts.google.com",
+				ClientID: "client",
+			},
+
+			outToken: &jwt.Token{
+				Claims: jwt.Claims{
+					Subject: oid.NewUUIDv5("1234"),
+					Scope:   scope.All,
+				},
+			},
+
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+		},
+		"error: password login disabled": {
+			inEmail:    "foo@bar.com",
+			inPassword: "correcthorsebatterystaple",
+
+			dbUser: &model.User{
+				ID:       oid.NewUUIDv5("1234").String(),
+				Email:    "foo@bar.com",
+				Password: `$2a$10$wMW4kC6o1fY87DokgO.lDektJO7hBXydf4B.yIWmE8hR9jOiO8way`,
+			},
+			dbOIDCConfig: &model.OIDCConfig{
+				Issuer:               "https://accounts.google.com",
+				ClientID:             "client",
+				DisablePasswordLogin: true,
+			},
+
+			outErr: ErrPasswordLoginDisabled,
+
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+		},
+		"error: get OIDC config": {
+			inEmail:    "foo@bar.com",
+			inPassword: "correcthorsebatterystaple",
+
+			dbUser: &model.User{
+				ID:       oid.NewUUIDv5("1234").String(),
+				Email:    "foo@bar.com",
+				Password: `$2a$10$wMW4kC6o1fY87DokgO.lDektJO7hBXydf4B.yIWmE8hR9jOiO8way`,
+			},
+			dbOIDCConfigErr: errors.New("db failed"),
+
+			outErr: errors.New("useradm: failed to get OIDC configuration: db failed"),
+
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+		},
+		"error: user without password": {
+			inEmail:    "foo@bar.com",
+			inPassword: "",
+
+			dbUser: &model.User{
+				ID:    oid.NewUUIDv5("1234").String(),
+				Email: "foo@bar.com",
+			},
+
+			outErr: ErrUnauthorized,
+
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+		},
 		"error: regular login, db.GetUserByEmail() error": {
 			inEmail:    "foo@bar.com",
 			inPassword: "correcthorsebatterystaple",
@@ -338,9 +502,13 @@ func TestUserAdmLogin(t *testing.T) {
 			db.On("GetUserByEmail", ContextMatcher(), tc.inEmail).Return(tc.dbUser, tc.dbUserErr)
 
 			db.On("SaveToken", ContextMatcher(), mock.AnythingOfType("*jwt.Token")).Return(tc.dbTokenErr)
+			db.On("GetOIDCConfig", ContextMatcher()).
+				Return(tc.dbOIDCConfig, tc.dbOIDCConfigErr)
 			if tc.dbUser != nil {
 				db.On("UpdateLoginTs", ContextMatcher(), tc.dbUser.ID).
 					Return(tc.dbUpdateErr)
+				db.On("GetActiveElevations", ContextMatcher(), tc.dbUser.ID).
+					Return(tc.dbElevations, tc.dbElevationsErr)
 			}
 
 			useradm := NewUserAdm(nil, db, tc.config)
@@ -363,6 +531,7 @@ func TestUserAdmLogin(t *testing.T) {
 					assert.NotEmpty(t, token.Claims.ID)
 					assert.Equal(t, tc.config.Issuer, token.Claims.Issuer)
 					assert.Equal(t, tc.outToken.Claims.Scope, token.Claims.Scope)
+					assert.Equal(t, tc.outToken.Claims.Elevation, token.Claims.Elevation)
 					assert.WithinDuration(t,
 						time.Now().Add(time.Duration(tc.config.ExpirationTime)*time.Second),
 						token.Claims.ExpiresAt.Time,
@@ -455,6 +624,15 @@ func TestUserAdmDoCreateUser(t *testing.T) {
 			propagate:          true,
 			shouldVerifyTenant: false,
 		},
+		"ok, not disabled nor deleted": {
+			inUser: model.User{
+				Email:     "foo@bar.com",
+				Password:  "correcthorsebatterystaple",
+				Status:    model.UserStatusDisabled,
+				DeletedTs: &time.Time{},
+			},
+			propagate: true,
+		},
 		"ok, multitenant": {
 			inUser: model.User{
 				Email:    "foo@bar.com",
@@ -665,6 +843,8 @@ func TestUserAdmDoCreateUser(t *testing.T) {
 				assert.EqualError(t, err, tc.outErr.Error())
 			} else {
 				assert.NoError(t, err)
+				assert.False(t, tc.inUser.IsDisabled())
+				assert.False(t, tc.inUser.IsDeleted())
 			}
 
 			cTenant.AssertExpectations(t)
@@ -916,18 +1096,91 @@ func TestUserAdmUpdateUser(t *testing.T) {
 }
 
 func TestUserAdmVerify(t *testing.T) {
+	tokenName := "ci"
+
 	testCases := map[string]struct {
 		token *jwt.Token
 
 		dbUser    *model.User
 		dbUserErr error
 
+		dbServiceAccount    *model.ServiceAccount
+		dbServiceAccountErr error
+
 		dbToken    *jwt.Token
 		dbTokenErr error
 
-		err error
+		dbGroups    []model.Group
+		dbGroupsErr error
+
+		identity *model.TokenIdentity
+		err      error
 	}{
 		"ok": {
+			token: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:      oid.NewUUIDv5("token-1"),
+					Subject: oid.NewUUIDv5("1234"),
+					Issuer:  "mender",
+					User:    true,
+				},
+			},
+			dbUser: &model.User{
+				ID:    oid.NewUUIDv5("1234").String(),
+				Email: "foo@acme.com",
+				Roles: []string{model.RoleReadOnly},
+			},
+			dbToken: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:      oid.NewUUIDv5("token-1"),
+					Subject: oid.NewUUIDv5("1234"),
+					Issuer:  "mender",
+					User:    true,
+				},
+			},
+			dbGroups: []model.Group{{
+				Roles: []string{model.RoleReadOnly, "devs"},
+			}},
+
+			identity: &model.TokenIdentity{
+				UserID:    oid.NewUUIDv5("1234").String(),
+				Email:     "foo@acme.com",
+				Roles:     []string{model.RoleReadOnly, "devs"},
+				TokenType: model.TokenTypeSession,
+				TokenID:   oid.NewUUIDv5("token-1").String(),
+			},
+		},
+		"ok, personal access token": {
+			token: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:      oid.NewUUIDv5("token-1"),
+					Subject: oid.NewUUIDv5("1234"),
+					Issuer:  "mender",
+					User:    true,
+				},
+			},
+			dbUser: &model.User{
+				ID:    oid.NewUUIDv5("1234").String(),
+				Email: "foo@acme.com",
+			},
+			dbToken: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:      oid.NewUUIDv5("token-1"),
+					Subject: oid.NewUUIDv5("1234"),
+					Issuer:  "mender",
+					User:    true,
+				},
+				TokenName: &tokenName,
+			},
+
+			identity: &model.TokenIdentity{
+				UserID:    oid.NewUUIDv5("1234").String(),
+				Email:     "foo@acme.com",
+				TokenType: model.TokenTypePersonalAccessToken,
+				TokenID:   oid.NewUUIDv5("token-1").String(),
+			},
+		},
+		"error: db groups": {
 			token: &jwt.Token{
 				Claims: jwt.Claims{
 					ID:      oid.NewUUIDv5("token-1"),
@@ -947,6 +1200,66 @@ func TestUserAdmVerify(t *testing.T) {
 					User:    true,
 				},
 			},
+			dbGroupsErr: errors.New("db failed"),
+
+			err: errors.New("useradm: failed to get user roles: " +
+				"authz: failed to get groups: db failed"),
+		},
+		"ok, service account": {
+			token: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:             oid.NewUUIDv5("token-1"),
+					Subject:        oid.NewUUIDv5("sa-1"),
+					Issuer:         "mender",
+					User:           true,
+					ServiceAccount: true,
+				},
+			},
+			dbServiceAccount: &model.ServiceAccount{
+				ID: oid.NewUUIDv5("sa-1").String(),
+			},
+			dbToken: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:             oid.NewUUIDv5("token-1"),
+					Subject:        oid.NewUUIDv5("sa-1"),
+					Issuer:         "mender",
+					User:           true,
+					ServiceAccount: true,
+				},
+			},
+
+			identity: &model.TokenIdentity{
+				UserID:    oid.NewUUIDv5("sa-1").String(),
+				TokenType: model.TokenTypeServiceAccount,
+				TokenID:   oid.NewUUIDv5("token-1").String(),
+			},
+		},
+		"error: service account not found": {
+			token: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:             oid.NewUUIDv5("token-1"),
+					Subject:        oid.NewUUIDv5("sa-1"),
+					Issuer:         "mender",
+					User:           true,
+					ServiceAccount: true,
+				},
+			},
+			err: ErrUnauthorized,
+		},
+		"error: db service account": {
+			token: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:             oid.NewUUIDv5("token-1"),
+					Subject:        oid.NewUUIDv5("sa-1"),
+					Issuer:         "mender",
+					User:           true,
+					ServiceAccount: true,
+				},
+			},
+			dbServiceAccountErr: errors.New("db internal error"),
+
+			err: errors.New(
+				"useradm: failed to get service account: db internal error"),
 		},
 		"error: invalid token issuer": {
 			token: &jwt.Token{
@@ -959,6 +1272,66 @@ func TestUserAdmVerify(t *testing.T) {
 			},
 			err: ErrUnauthorized,
 		},
+		"ok, elevated": {
+			token: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:      oid.NewUUIDv5("token-1"),
+					Subject: oid.NewUUIDv5("1234"),
+					Issuer:  "mender",
+					User:    true,
+					Elevation: &jwt.Elevation{
+						Roles:     []string{model.RoleAdmin},
+						ExpiresAt: jwt.Time{Time: time.Now().Add(time.Hour)},
+					},
+				},
+			},
+			dbUser: &model.User{
+				ID: oid.NewUUIDv5("1234").String(),
+			},
+			dbToken: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:      oid.NewUUIDv5("token-1"),
+					Subject: oid.NewUUIDv5("1234"),
+					Issuer:  "mender",
+					User:    true,
+				},
+			},
+		},
+		"ok, elevation expired": {
+			token: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:      oid.NewUUIDv5("token-1"),
+					Subject: oid.NewUUIDv5("1234"),
+					Issuer:  "mender",
+					User:    true,
+					Elevation: &jwt.Elevation{
+						Roles:     []string{model.RoleAdmin},
+						ExpiresAt: jwt.Time{Time: time.Now().Add(-time.Minute)},
+					},
+				},
+			},
+			dbUser: &model.User{
+				ID:    oid.NewUUIDv5("1234").String(),
+				Email: "foo@bar.com",
+				Roles: []string{model.RoleReadOnly},
+			},
+			dbToken: &jwt.Token{
+				Claims: jwt.Claims{
+					ID:      oid.NewUUIDv5("token-1"),
+					Subject: oid.NewUUIDv5("1234"),
+					Issuer:  "mender",
+					User:    true,
+				},
+			},
+			// the session stays valid without the elevated roles
+			identity: &model.TokenIdentity{
+				UserID:    oid.NewUUIDv5("1234").String(),
+				TokenType: model.TokenTypeSession,
+				TokenID:   oid.NewUUIDv5("token-1").String(),
+				Email:     "foo@bar.com",
+				Roles:     []string{model.RoleReadOnly},
+			},
+		},
 		"error: not a user token": {
 			token: &jwt.Token{
 				Claims: jwt.Claims{
@@ -1042,50 +1415,169 @@ func TestUserAdmVerify(t *testing.T) {
 			db.On("GetUserById", ctx,
 				tc.token.Claims.Subject.String()).
 				Return(tc.dbUser, tc.dbUserErr)
+			db.On("GetServiceAccount", ctx,
+				tc.token.Claims.Subject.String()).
+				Return(tc.dbServiceAccount, tc.dbServiceAccountErr)
 			db.On("GetTokenById", ctx, tc.token.ID).
 				Return(tc.dbToken, tc.dbTokenErr)
+			db.On("GetGroupsByMember", ctx,
+				tc.token.Claims.Subject.String()).
+				Return(tc.dbGroups, tc.dbGroupsErr)
+			db.On("GetActiveElevations", ctx,
+				tc.token.Claims.Subject.String()).
+				Return([]model.Elevation{}, nil)
 
 			useradm := NewUserAdm(nil, db, config)
 
-			err := useradm.Verify(ctx, tc.token)
+			identity, err := useradm.Verify(ctx, tc.token)
 
 			if tc.err != nil {
 				assert.EqualError(t, err, tc.err.Error())
+				assert.Nil(t, identity)
 			} else {
 				assert.NoError(t, err)
+				if tc.identity != nil {
+					assert.Equal(t, tc.identity, identity)
+				}
 			}
 		})
 	}
 }
 
-func TestUserAdmGetUsers(t *testing.T) {
-	t.Parallel()
-	ts := time.Now()
+func TestUserAdmIntrospectToken(t *testing.T) {
+	now := time.Now()
+	tokenName := "ci"
+	claims := jwt.Claims{
+		ID:        oid.NewUUIDv5("token-1"),
+		Subject:   oid.NewUUIDv5("1234"),
+		Issuer:    "mender",
+		Scope:     scope.All,
+		IssuedAt:  jwt.Time{Time: now},
+		ExpiresAt: jwt.Time{Time: now.Add(time.Hour)},
+		User:      true,
+	}
 	testCases := map[string]struct {
-		dbUsers []model.User
-		dbErr   error
+		token *jwt.Token
+
+		dbUser    *model.User
+		dbToken   *jwt.Token
+		dbUserErr error
 
+		res *model.TokenIntrospection
 		err error
 	}{
-		"ok: some users": {
-			dbUsers: []model.User{
-				{
-					ID:        "1",
-					Email:     "foo",
-					CreatedTs: &ts,
-				},
-				{
-					ID:        "2",
-					Email:     "bar",
-					UpdatedTs: &ts,
-				},
-			},
-			dbErr: nil,
-			err:   nil,
+		"ok, session token": {
+			token:   &jwt.Token{Claims: claims},
+			dbUser:  &model.User{ID: oid.NewUUIDv5("1234").String()},
+			dbToken: &jwt.Token{Claims: claims},
+			res: &model.TokenIntrospection{
+				Active:    true,
+				ID:        oid.NewUUIDv5("token-1").String(),
+				Subject:   oid.NewUUIDv5("1234").String(),
+				Scope:     scope.All,
+				ExpiresAt: now.Add(time.Hour).Unix(),
+				IssuedAt:  now.Unix(),
+				Issuer:    "mender",
+				TokenType: model.TokenTypeSession,
+			},
+		},
+		"ok, personal access token": {
+			token:  &jwt.Token{Claims: claims},
+			dbUser: &model.User{ID: oid.NewUUIDv5("1234").String()},
+			dbToken: &jwt.Token{
+				Claims:    claims,
+				TokenName: &tokenName,
+				LastUsed:  &now,
+			},
+			res: &model.TokenIntrospection{
+				Active:    true,
+				ID:        oid.NewUUIDv5("token-1").String(),
+				Subject:   oid.NewUUIDv5("1234").String(),
+				Scope:     scope.All,
+				ExpiresAt: now.Add(time.Hour).Unix(),
+				IssuedAt:  now.Unix(),
+				Issuer:    "mender",
+				TokenType: model.TokenTypePersonalAccessToken,
+				TokenName: tokenName,
+			},
+		},
+		"ok, inactive: token revoked": {
+			token:  &jwt.Token{Claims: claims},
+			dbUser: &model.User{ID: oid.NewUUIDv5("1234").String()},
+			res:    &model.TokenIntrospection{Active: false},
+		},
+		"ok, inactive: user deleted": {
+			token: &jwt.Token{Claims: claims},
+			res:   &model.TokenIntrospection{Active: false},
 		},
-		"ok: no users": {
-			dbUsers: []model.User{},
-			dbErr:   nil,
+		"error: db user": {
+			token:     &jwt.Token{Claims: claims},
+			dbUserErr: errors.New("db internal error"),
+			err:       errors.New("useradm: failed to get user: db internal error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			matchIdentity := mock.MatchedBy(func(ctx context.Context) bool {
+				id := identity.FromContext(ctx)
+				return id != nil && id.Subject == claims.Subject.String()
+			})
+			db.On("GetUserById", matchIdentity, claims.Subject.String()).
+				Return(tc.dbUser, tc.dbUserErr)
+			if tc.dbUser != nil {
+				db.On("GetTokenById", matchIdentity, claims.ID).
+					Return(tc.dbToken, nil)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{
+				Issuer:                         "mender",
+				TokenLastUsedUpdateFreqMinutes: 5,
+			})
+
+			res, err := useradm.IntrospectToken(ctx, tc.token)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.res, res)
+			}
+		})
+	}
+}
+
+func TestUserAdmGetUsers(t *testing.T) {
+	t.Parallel()
+	ts := time.Now()
+	testCases := map[string]struct {
+		dbUsers []model.User
+		dbErr   error
+
+		err error
+	}{
+		"ok: some users": {
+			dbUsers: []model.User{
+				{
+					ID:        "1",
+					Email:     "foo",
+					CreatedTs: &ts,
+				},
+				{
+					ID:        "2",
+					Email:     "bar",
+					UpdatedTs: &ts,
+				},
+			},
+			dbErr: nil,
+			err:   nil,
+		},
+		"ok: no users": {
+			dbUsers: []model.User{},
+			dbErr:   nil,
 			err:     nil,
 		},
 		"error: db": {
@@ -1185,6 +1677,8 @@ func TestUserAdmDeleteUser(t *testing.T) {
 		tenantErr         error
 		dbDeleteUserErr   error
 		dbDeleteTokensErr error
+		dbGroupsErr       error
+		dbSettingsErr     error
 		err               error
 	}{
 		"ok": {
@@ -1207,6 +1701,16 @@ func TestUserAdmDeleteUser(t *testing.T) {
 			dbDeleteTokensErr: errors.New("db connection failed"),
 			err:               errors.New("useradm: failed to delete user tokens: db connection failed"),
 		},
+		"error removing user from groups": {
+			dbGroupsErr: errors.New("db connection failed"),
+			err: errors.New("useradm: failed to remove user from groups: " +
+				"db connection failed"),
+		},
+		"error deleting user settings": {
+			dbSettingsErr: errors.New("db connection failed"),
+			err: errors.New("useradm: failed to delete user settings: " +
+				"db connection failed"),
+		},
 	}
 
 	for name := range testCases {
@@ -1220,6 +1724,8 @@ func TestUserAdmDeleteUser(t *testing.T) {
 			db := &mstore.DataStore{}
 			db.On("DeleteUser", ContextMatcher(), "foo").Return(tc.dbDeleteUserErr)
 			db.On("DeleteTokensByUserId", ContextMatcher(), "foo").Return(tc.dbDeleteTokensErr)
+			db.On("RemoveUserFromGroups", ContextMatcher(), "foo").Return(tc.dbGroupsErr)
+			db.On("DeleteUserSettings", ContextMatcher(), "foo").Return(tc.dbSettingsErr)
 
 			useradm := NewUserAdm(nil, db, Config{})
 			if tc.verifyTenant {
@@ -1248,6 +1754,481 @@ func TestUserAdmDeleteUser(t *testing.T) {
 	}
 }
 
+func TestUserAdmSoftDeleteUser(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		verifyTenant bool
+
+		tenantErr         error
+		dbErr             error
+		dbDeleteTokensErr error
+
+		err error
+	}{
+		"ok": {},
+		"ok, multitenant": {
+			verifyTenant: true,
+		},
+		"ok, multitenant, tenantadm not found": {
+			verifyTenant: true,
+			tenantErr:    ct.ErrUserNotFound,
+		},
+		"ok, not found": {
+			dbErr: store.ErrUserNotFound,
+		},
+		"multitenant, tenantadm error": {
+			verifyTenant: true,
+			tenantErr:    errors.New("http 500"),
+			err:          errors.New("useradm: failed to update user in tenantadm: http 500"),
+		},
+		"error deleting user": {
+			dbErr: errors.New("db connection failed"),
+			err:   errors.New("useradm: failed to delete user: db connection failed"),
+		},
+		"error deleting user tokens": {
+			dbDeleteTokensErr: errors.New("db connection failed"),
+			err:               errors.New("useradm: failed to delete user tokens: db connection failed"),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			tenantOK := tc.tenantErr == nil || tc.tenantErr == ct.ErrUserNotFound
+			if tenantOK {
+				db.On("SoftDeleteUser", ContextMatcher(), "foo").Return(tc.dbErr)
+			}
+			if tenantOK && tc.dbErr == nil {
+				db.On("DeleteTokensByUserId", ContextMatcher(), "foo").
+					Return(tc.dbDeleteTokensErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{UserRetention: 86400})
+			if tc.verifyTenant {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: "bar",
+				})
+
+				cTenant := &mct.ClientRunner{}
+				defer cTenant.AssertExpectations(t)
+				cTenant.On("UpdateUser",
+					ContextMatcher(),
+					"bar", "foo",
+					&ct.UserUpdate{Status: string(model.UserStatusDisabled)},
+					&apiclient.HttpApi{}).
+					Return(tc.tenantErr)
+				useradm = useradm.WithTenantVerification(cTenant)
+			}
+
+			err := useradm.DeleteUser(ctx, "foo")
+
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmRestoreUser(t *testing.T) {
+	t.Parallel()
+
+	deletedTs := time.Now()
+	testCases := map[string]struct {
+		verifyTenant bool
+		tenantStatus model.UserStatus
+
+		dbUser    *model.User
+		dbGetErr  error
+		tenantErr error
+		dbErr     error
+
+		err error
+	}{
+		"ok": {
+			dbUser: &model.User{ID: "foo", DeletedTs: &deletedTs},
+		},
+		"ok, multitenant": {
+			verifyTenant: true,
+			tenantStatus: model.UserStatusActive,
+			dbUser:       &model.User{ID: "foo", DeletedTs: &deletedTs},
+		},
+		"ok, multitenant, disabled user": {
+			verifyTenant: true,
+			tenantStatus: model.UserStatusDisabled,
+			dbUser: &model.User{
+				ID:        "foo",
+				Status:    model.UserStatusDisabled,
+				DeletedTs: &deletedTs,
+			},
+		},
+		"multitenant, tenantadm error": {
+			verifyTenant: true,
+			tenantStatus: model.UserStatusActive,
+			dbUser:       &model.User{ID: "foo", DeletedTs: &deletedTs},
+			tenantErr:    errors.New("http 500"),
+			err:          errors.New("useradm: failed to update user in tenantadm: http 500"),
+		},
+		"error, not found": {
+			err: store.ErrUserNotFound,
+		},
+		"error, not deleted": {
+			dbUser: &model.User{ID: "foo"},
+			err:    store.ErrUserNotFound,
+		},
+		"error getting user": {
+			dbGetErr: errors.New("db connection failed"),
+			err:      errors.New("useradm: failed to get user: db connection failed"),
+		},
+		"error restoring user": {
+			dbUser: &model.User{ID: "foo", DeletedTs: &deletedTs},
+			dbErr:  errors.New("db connection failed"),
+			err:    errors.New("useradm: failed to restore user: db connection failed"),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetUserById", ContextMatcher(), "foo").
+				Return(tc.dbUser, tc.dbGetErr)
+			if tc.dbUser != nil && tc.dbUser.IsDeleted() && tc.tenantErr == nil {
+				db.On("RestoreUser", ContextMatcher(), "foo").Return(tc.dbErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{UserRetention: 86400})
+			if tc.verifyTenant {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: "bar",
+				})
+
+				cTenant := &mct.ClientRunner{}
+				defer cTenant.AssertExpectations(t)
+				cTenant.On("UpdateUser",
+					ContextMatcher(),
+					"bar", "foo",
+					&ct.UserUpdate{Status: string(tc.tenantStatus)},
+					&apiclient.HttpApi{}).
+					Return(tc.tenantErr)
+				useradm = useradm.WithTenantVerification(cTenant)
+			}
+
+			err := useradm.RestoreUser(ctx, "foo")
+
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmPurgeDeletedUsers(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		tenants      []string
+		verifyTenant bool
+		dbTenantsErr error
+		// the errors of the tenant "bar"
+		dbUsersErr error
+		dbPurgeErr error
+		tenantErr  error
+
+		err error
+	}{
+		"ok": {
+			tenants: []string{"", "bar"},
+		},
+		"ok, tenantadm": {
+			tenants:      []string{"bar"},
+			verifyTenant: true,
+		},
+		"ok, nothing to purge": {
+			tenants: []string{},
+		},
+		"ok, restored or purged meanwhile": {
+			tenants:    []string{"", "bar"},
+			dbPurgeErr: store.ErrUserNotFound,
+		},
+		"error getting tenants": {
+			dbTenantsErr: errors.New("db connection failed"),
+			err:          errors.New("useradm: failed to get deleted users: db connection failed"),
+		},
+		"error getting users, the other tenants purged": {
+			tenants:    []string{"", "bar"},
+			dbUsersErr: errors.New("db connection failed"),
+			err:        errors.New("useradm: 1 failures purging the deleted users"),
+		},
+		"error purging user, the other users purged": {
+			tenants:    []string{"", "bar"},
+			dbPurgeErr: errors.New("db connection failed"),
+			err:        errors.New("useradm: 1 failures purging the deleted users"),
+		},
+		"error deleting user in tenantadm": {
+			tenants:      []string{"bar"},
+			verifyTenant: true,
+			tenantErr:    errors.New("http 500"),
+			err:          errors.New("useradm: 1 failures purging the deleted users"),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			retention := time.Hour
+			isBefore := mock.MatchedBy(func(before time.Time) bool {
+				return time.Since(before) >= retention &&
+					time.Since(before) < retention+time.Minute
+			})
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			cTenant := &mct.ClientRunner{}
+			defer cTenant.AssertExpectations(t)
+			db.On("GetDeletedUserTenants", ctx, isBefore).
+				Return(tc.tenants, tc.dbTenantsErr)
+			for _, tenantID := range tc.tenants {
+				tenantID := tenantID
+				tenantCtx := mock.MatchedBy(func(ctx context.Context) bool {
+					id := identity.FromContext(ctx)
+					if tenantID == "" {
+						return id == nil
+					}
+					return id != nil && id.Tenant == tenantID
+				})
+				var usersErr, purgeErr, tenantErr error
+				if tenantID == "bar" {
+					usersErr, purgeErr, tenantErr = tc.dbUsersErr, tc.dbPurgeErr, tc.tenantErr
+				}
+				userID := "foo-" + tenantID
+				db.On("GetUsers", tenantCtx,
+					mock.MatchedBy(func(fltr model.UserFilter) bool {
+						return fltr.DeletedBefore != nil
+					})).
+					Return([]model.User{{ID: userID}}, usersErr)
+				if usersErr != nil {
+					continue
+				}
+				if tc.dbPurgeErr == store.ErrUserNotFound {
+					purgeErr = tc.dbPurgeErr
+				}
+				db.On("PurgeDeletedUser", tenantCtx, userID, isBefore).Return(purgeErr)
+				if purgeErr != nil {
+					continue
+				}
+				db.On("DeleteTokensByUserId", tenantCtx, userID).Return(nil)
+				db.On("RemoveUserFromGroups", tenantCtx, userID).Return(nil)
+				db.On("DeleteUserSettings", tenantCtx, userID).Return(nil)
+				if tc.verifyTenant {
+					cTenant.On("DeleteUser",
+						ContextMatcher(),
+						tenantID, userID,
+						&apiclient.HttpApi{}).
+						Return(tenantErr)
+				}
+			}
+
+			useradm := NewUserAdm(nil, db, Config{
+				UserRetention: int64(retention / time.Second),
+			})
+			if tc.verifyTenant {
+				useradm = useradm.WithTenantVerification(cTenant)
+			}
+
+			err := useradm.PurgeDeletedUsers(ctx)
+
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmSetUserStatus(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		status       model.UserStatus
+		verifyTenant bool
+
+		tenantErr         error
+		dbErr             error
+		dbDeleteTokensErr error
+
+		err error
+	}{
+		"ok, disable": {
+			status: model.UserStatusDisabled,
+		},
+		"ok, enable": {
+			status: model.UserStatusActive,
+		},
+		"ok, disable, multitenant": {
+			status:       model.UserStatusDisabled,
+			verifyTenant: true,
+		},
+		"multitenant, tenantadm not found": {
+			status:       model.UserStatusDisabled,
+			verifyTenant: true,
+			tenantErr:    ct.ErrUserNotFound,
+			err:          store.ErrUserNotFound,
+		},
+		"multitenant, tenantadm error": {
+			status:       model.UserStatusDisabled,
+			verifyTenant: true,
+			tenantErr:    errors.New("http 500"),
+			err:          errors.New("useradm: failed to update user in tenantadm: http 500"),
+		},
+		"error, not found": {
+			status: model.UserStatusDisabled,
+			dbErr:  store.ErrUserNotFound,
+			err:    store.ErrUserNotFound,
+		},
+		"error updating user": {
+			status: model.UserStatusDisabled,
+			dbErr:  errors.New("db connection failed"),
+			err:    errors.New("useradm: failed to update user status: db connection failed"),
+		},
+		"error deleting user tokens": {
+			status:            model.UserStatusDisabled,
+			dbDeleteTokensErr: errors.New("db connection failed"),
+			err:               errors.New("useradm: failed to delete user tokens: db connection failed"),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			status := &model.UserStatusUpdate{
+				Status: tc.status,
+				Reason: "left the company",
+			}
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.tenantErr == nil {
+				db.On("SetUserStatus", ContextMatcher(), "foo", status).
+					Return(tc.dbErr)
+			}
+			if tc.dbErr == nil && tc.tenantErr == nil &&
+				tc.status == model.UserStatusDisabled {
+				db.On("DeleteTokensByUserId", ContextMatcher(), "foo").
+					Return(tc.dbDeleteTokensErr)
+			}
+
+			useradm := NewUserAdm(nil, db, Config{})
+			if tc.verifyTenant {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: "bar",
+				})
+
+				cTenant := &mct.ClientRunner{}
+				defer cTenant.AssertExpectations(t)
+				cTenant.On("UpdateUser",
+					ContextMatcher(),
+					"bar", "foo",
+					&ct.UserUpdate{Status: string(tc.status)},
+					&apiclient.HttpApi{}).
+					Return(tc.tenantErr)
+				useradm = useradm.WithTenantVerification(cTenant)
+			}
+
+			err := useradm.SetUserStatus(ctx, "foo", status)
+
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserAdmDisabledUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	user := &model.User{
+		ID:     "2e8fb2f0-6fda-4ca1-8d2e-7ab8bbdd8b3e",
+		Email:  "foo@acme.com",
+		Status: model.UserStatusDisabled,
+	}
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserById", ctx, user.ID).Return(user, nil)
+
+	useradm := NewUserAdm(nil, db, Config{Issuer: "mender"})
+
+	// no token is issued
+	_, err := useradm.issueLoginToken(ctx, user, "")
+	assert.Equal(t, ErrUserDisabled, err)
+
+	// the tokens issued before are rejected
+	_, _, err = useradm.verify(ctx, &jwt.Token{Claims: jwt.Claims{
+		ID:      oid.NewUUIDv4(),
+		Subject: oid.FromString(user.ID),
+		Issuer:  "mender",
+		User:    true,
+	}})
+	assert.Equal(t, ErrUnauthorized, err)
+}
+
+func TestUserAdmDeletedUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	deletedTs := time.Now()
+	user := &model.User{
+		ID:        "2e8fb2f0-6fda-4ca1-8d2e-7ab8bbdd8b3e",
+		Email:     "foo@acme.com",
+		DeletedTs: &deletedTs,
+	}
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserByEmail", ctx, user.Email).Return(user, nil)
+	db.On("GetUserById", ctx, user.ID).Return(user, nil)
+
+	useradm := NewUserAdm(nil, db, Config{Issuer: "mender"})
+
+	// the deleted user cannot log in
+	_, err := useradm.Login(ctx, user.Email, "correcthorsebatterystaple")
+	assert.Equal(t, ErrUnauthorized, err)
+	_, err = useradm.issueLoginToken(ctx, user, "")
+	assert.Equal(t, ErrUnauthorized, err)
+
+	// the tokens issued before are rejected
+	_, _, err = useradm.verify(ctx, &jwt.Token{Claims: jwt.Claims{
+		ID:      oid.NewUUIDv4(),
+		Subject: oid.FromString(user.ID),
+		Issuer:  "mender",
+		User:    true,
+	}})
+	assert.Equal(t, ErrUnauthorized, err)
+
+	// and the user is hidden
+	found, err := useradm.GetUser(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+}
+
 func TestUserAdmCreateTenant(t *testing.T) {
 	t.Parallel()
 
@@ -1455,6 +2436,7 @@ func stringPtr(s string) *string {
 func TestUserAdmIssuePersonalAccessToken(t *testing.T) {
 	testCases := map[string]struct {
 		tokenRequest model.TokenRequest
+		callerScopes []string
 
 		callDbSaveToken   bool
 		dbSaveTokenErr    error
@@ -1464,7 +2446,8 @@ func TestUserAdmIssuePersonalAccessToken(t *testing.T) {
 
 		config Config
 
-		outErr error
+		outScope string
+		outErr   error
 	}{
 		"ok": {
 			tokenRequest: model.TokenRequest{
@@ -1491,6 +2474,59 @@ func TestUserAdmIssuePersonalAccessToken(t *testing.T) {
 				ExpirationTime: 10,
 			},
 		},
+		"ok, scoped": {
+			tokenRequest: model.TokenRequest{
+				Name:      stringPtr("foo"),
+				ExpiresIn: 3600,
+				Scopes:    []string{scope.DevicesRead, scope.DeploymentsWrite},
+			},
+			callDbSaveToken: true,
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+			outScope: "mender.devices.read mender.deployments.write",
+		},
+		"ok, scoped by the caller's token": {
+			tokenRequest: model.TokenRequest{
+				Name:      stringPtr("foo"),
+				ExpiresIn: 3600,
+				Scopes:    []string{scope.DevicesRead},
+			},
+			callerScopes:    []string{scope.DevicesWrite, scope.UsersWrite},
+			callDbSaveToken: true,
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+			outScope: scope.DevicesRead,
+		},
+		"ok, caller's scope by default": {
+			tokenRequest: model.TokenRequest{
+				Name:      stringPtr("foo"),
+				ExpiresIn: 3600,
+			},
+			callerScopes:    []string{scope.DevicesWrite, scope.UsersWrite},
+			callDbSaveToken: true,
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+			outScope: "mender.devices.write mender.users.write",
+		},
+		"error: scope exceeds the caller's scope": {
+			tokenRequest: model.TokenRequest{
+				Name:      stringPtr("foo"),
+				ExpiresIn: 3600,
+				Scopes:    []string{scope.ReadOnly},
+			},
+			callerScopes: []string{scope.DevicesWrite, scope.UsersWrite},
+			config: Config{
+				Issuer:         "foobar",
+				ExpirationTime: 10,
+			},
+			outErr: ErrScopeNotPermitted,
+		},
 		"error: too many tokens": {
 			tokenRequest: model.TokenRequest{
 				Name:      stringPtr("foo"),
@@ -1564,7 +2600,9 @@ func TestUserAdmIssuePersonalAccessToken(t *testing.T) {
 			if tc.callDbSaveToken {
 				db.On("SaveToken",
 					ContextMatcher(),
-					mock.AnythingOfType("*jwt.Token")).
+					mock.MatchedBy(func(t *jwt.Token) bool {
+						return tc.outScope == "" || t.Scope == tc.outScope
+					})).
 					Return(tc.dbSaveTokenErr)
 			}
 			if tc.callDbCountTokens {
@@ -1576,6 +2614,7 @@ func TestUserAdmIssuePersonalAccessToken(t *testing.T) {
 
 			mockJWTHandler := mjwt.Handler{}
 			mockJWTHandler.On("ToJWT",
+				ContextMatcher(),
 				mock.AnythingOfType("*jwt.Token"),
 			).Return("signed", nil)
 
@@ -1586,6 +2625,9 @@ func TestUserAdmIssuePersonalAccessToken(t *testing.T) {
 				Tenant:  "bar",
 			}
 			ctx = identity.WithContext(ctx, id)
+			if tc.callerScopes != nil {
+				ctx = scope.WithContext(ctx, tc.callerScopes)
+			}
 
 			_, err := useradm.IssuePersonalAccessToken(ctx, &tc.tokenRequest)
 
@@ -0,0 +1,419 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+)
+
+const (
+	verifyCacheRetryMin = time.Second
+	verifyCacheRetryMax = time.Minute
+
+	// the requests allowed to a token are cached up to this number, e.g.
+	// for the tokens used on many devices
+	verifyCacheAllowedMax = 64
+)
+
+// VerificationCache is a bounded LRU cache of the successful token
+// verifications, keyed by the token ID. The entries expire after the
+// TTL, or earlier with the token; they are invalidated on logout, token
+// deletion, password change, user deletion and on the changes of the
+// roles, groups and elevations. A nil cache caches nothing.
+type VerificationCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	enabled bool
+	epoch   uint64
+	lru     *list.List
+	tokens  map[string]*list.Element
+	users   map[string]map[string]*list.Element
+	now     func() time.Time
+}
+
+type verifyCacheEntry struct {
+	identity *model.TokenIdentity
+	expires  time.Time
+	allowed  map[verifyCacheRequest]struct{}
+	// last use of the token recorded in the database
+	lastUsed time.Time
+}
+
+type verifyCacheRequest struct {
+	resource string
+	action   string
+}
+
+// NewVerificationCache returns the cache of at most size entries; the
+// cache is disabled until SetEnabled(true), which WatchVerificationCache
+// calls once it is notified of the changes made by the other replicas.
+func NewVerificationCache(size int, ttl time.Duration) *VerificationCache {
+	return &VerificationCache{
+		size:   size,
+		ttl:    ttl,
+		lru:    list.New(),
+		tokens: make(map[string]*list.Element, size),
+		users:  make(map[string]map[string]*list.Element),
+		now:    time.Now,
+	}
+}
+
+// Get returns the identity of the verified token, or nil.
+func (c *VerificationCache) Get(tokenID string) *model.TokenIdentity {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.get(tokenID)
+	if entry == nil {
+		return nil
+	}
+	return entry.identity
+}
+
+func (c *VerificationCache) get(tokenID string) *verifyCacheEntry {
+	elem, ok := c.tokens[tokenID]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*verifyCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(elem)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return entry
+}
+
+// Allowed checks if the request was allowed to the token since its
+// verification was cached; makes VerificationCache implement the
+// authz.DecisionCache interface.
+func (c *VerificationCache) Allowed(tokenID, resource, action string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.get(tokenID)
+	if entry == nil {
+		return false
+	}
+	_, ok := entry.allowed[verifyCacheRequest{resource: resource, action: action}]
+	return ok
+}
+
+// AddAllowed caches the request allowed to the token together with its
+// verification, if cached; nothing is cached if anything has been
+// invalidated since the epoch.
+func (c *VerificationCache) AddAllowed(tokenID, resource, action string, epoch uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled || epoch != c.epoch {
+		return
+	}
+	entry := c.get(tokenID)
+	if entry == nil || len(entry.allowed) >= verifyCacheAllowedMax {
+		return
+	}
+	if entry.allowed == nil {
+		entry.allowed = make(map[verifyCacheRequest]struct{})
+	}
+	entry.allowed[verifyCacheRequest{resource: resource, action: action}] = struct{}{}
+}
+
+// SetLastUsed sets the last use of the cached token, as recorded in the
+// database.
+func (c *VerificationCache) SetLastUsed(tokenID string, lastUsed time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry := c.get(tokenID); entry != nil {
+		entry.lastUsed = lastUsed
+	}
+}
+
+// LastUsedBefore checks if the last use of the cached token was recorded
+// before t, in which case it is set to now, for the caller to record the
+// use in the database; the concurrent callers are not told twice.
+func (c *VerificationCache) LastUsedBefore(tokenID string, t time.Time) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.get(tokenID)
+	if entry == nil || !entry.lastUsed.Before(t) {
+		return false
+	}
+	entry.lastUsed = c.now()
+	return true
+}
+
+// Epoch returns the number of invalidations so far; it must be read
+// before the verification, for Add to discard the verifications made
+// stale meanwhile.
+func (c *VerificationCache) Epoch() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// Add caches the identity of the verified token until the TTL elapses,
+// the token expires or, if until is set, until then (e.g. when an elevated
+// role drops off), whichever comes first; nothing is cached if anything
+// has been invalidated since the epoch.
+func (c *VerificationCache) Add(
+	token *jwt.Token,
+	identity *model.TokenIdentity,
+	until time.Time,
+	epoch uint64,
+) {
+	if c == nil {
+		return
+	}
+	expires := c.now().Add(c.ttl)
+	if exp := token.Claims.ExpiresAt; !exp.IsZero() && exp.Before(expires) {
+		expires = exp.Time
+	}
+	if !until.IsZero() && until.Before(expires) {
+		expires = until
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled || c.size <= 0 || epoch != c.epoch ||
+		!c.now().Before(expires) {
+		return
+	}
+	if elem, ok := c.tokens[identity.TokenID]; ok {
+		c.remove(elem)
+	}
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+	elem := c.lru.PushFront(&verifyCacheEntry{
+		identity: identity,
+		expires:  expires,
+	})
+	c.tokens[identity.TokenID] = elem
+	userTokens, ok := c.users[identity.UserID]
+	if !ok {
+		userTokens = make(map[string]*list.Element)
+		c.users[identity.UserID] = userTokens
+	}
+	userTokens[identity.TokenID] = elem
+}
+
+func (c *VerificationCache) remove(elem *list.Element) {
+	identity := elem.Value.(*verifyCacheEntry).identity
+	c.lru.Remove(elem)
+	delete(c.tokens, identity.TokenID)
+	if userTokens, ok := c.users[identity.UserID]; ok {
+		delete(userTokens, identity.TokenID)
+		if len(userTokens) == 0 {
+			delete(c.users, identity.UserID)
+		}
+	}
+}
+
+// InvalidateToken removes the verification of the token.
+func (c *VerificationCache) InvalidateToken(tokenID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	if elem, ok := c.tokens[tokenID]; ok {
+		c.remove(elem)
+	}
+}
+
+// InvalidateUser removes the verifications of all the tokens of the user
+// (or service account).
+func (c *VerificationCache) InvalidateUser(userID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	for _, elem := range c.users[userID] {
+		c.remove(elem)
+	}
+}
+
+// InvalidateTenant removes the verifications of all the tokens of the
+// tenant.
+func (c *VerificationCache) InvalidateTenant(tenantID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*verifyCacheEntry).identity.Tenant == tenantID {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Invalidate applies the invalidation received from the database.
+func (c *VerificationCache) Invalidate(inv *store.Invalidation) {
+	if inv.All {
+		c.Purge()
+		return
+	}
+	if inv.TokenID != "" {
+		c.InvalidateToken(inv.TokenID)
+	}
+	if inv.UserID != "" {
+		c.InvalidateUser(inv.UserID)
+	}
+	if inv.TenantID != "" {
+		c.InvalidateTenant(inv.TenantID)
+	}
+}
+
+// Purge drops all the entries, e.g. when the configuration changes.
+func (c *VerificationCache) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purge()
+}
+
+func (c *VerificationCache) purge() {
+	c.epoch++
+	c.lru.Init()
+	c.tokens = make(map[string]*list.Element, c.size)
+	c.users = make(map[string]map[string]*list.Element)
+}
+
+// SetEnabled enables or disables the cache; disabling it drops all the
+// entries, and nothing is cached until it is enabled again.
+func (c *VerificationCache) SetEnabled(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	c.enabled = enabled
+	if !enabled {
+		c.purge()
+	}
+}
+
+// Len returns the number of cached verifications.
+func (c *VerificationCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// WithVerificationCache enables caching the successful token
+// verifications; WatchVerificationCache must run to invalidate the
+// verifications on the changes made by the other replicas.
+func (ua *UserAdm) WithVerificationCache(c *VerificationCache) *UserAdm {
+	ua.verifyCache = c
+	return ua
+}
+
+// invalidateTenant removes the verifications of the tenant in the
+// context, e.g. when the roles of its users may have changed.
+func (ua *UserAdm) invalidateTenant(ctx context.Context) {
+	var tenantID string
+	if id := identity.FromContext(ctx); id != nil {
+		tenantID = id.Tenant
+	}
+	ua.verifyCache.InvalidateTenant(tenantID)
+}
+
+// WatchVerificationCache applies the invalidations streamed from the
+// database to the verification cache until the context is canceled. The
+// cache is disabled whenever the stream is broken, as invalidations may
+// be missed; it returns an error if the stream cannot be opened at all,
+// e.g. if the database does not support change streams.
+func (ua *UserAdm) WatchVerificationCache(ctx context.Context) error {
+	c := ua.verifyCache
+	if c == nil {
+		return nil
+	}
+	l := log.FromContext(ctx)
+
+	stream, err := ua.db.WatchInvalidations(ctx)
+	if err != nil {
+		return errors.Wrap(err, "useradm: failed to watch the invalidations")
+	}
+	retry := verifyCacheRetryMin
+	for {
+		c.SetEnabled(true)
+		for {
+			var inv *store.Invalidation
+			inv, err = stream.Next(ctx)
+			if err != nil {
+				break
+			}
+			c.Invalidate(inv)
+			retry = verifyCacheRetryMin
+		}
+		c.SetEnabled(false)
+		_ = stream.Close(context.Background())
+		for {
+			if ctx.Err() != nil {
+				return nil
+			}
+			l.Errorf("verification cache disabled: %s; retrying in %s",
+				err.Error(), retry)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(retry):
+			}
+			if retry *= 2; retry > verifyCacheRetryMax {
+				retry = verifyCacheRetryMax
+			}
+			stream, err = ua.db.WatchInvalidations(ctx)
+			if err == nil {
+				l.Info("verification cache enabled")
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,755 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package useradm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/useradm/jwt"
+	"github.com/mendersoftware/useradm/model"
+	"github.com/mendersoftware/useradm/store"
+	mstore "github.com/mendersoftware/useradm/store/mocks"
+)
+
+func testVerifiedToken(tokenID, userID, tenantID string) (
+	*jwt.Token,
+	*model.TokenIdentity,
+) {
+	token := &jwt.Token{
+		Claims: jwt.Claims{
+			ID:      oid.NewUUIDv5(tokenID),
+			Subject: oid.NewUUIDv5(userID),
+			Tenant:  tenantID,
+			User:    true,
+		},
+	}
+	return token, &model.TokenIdentity{
+		UserID:    token.Claims.Subject.String(),
+		Tenant:    tenantID,
+		TokenType: model.TokenTypeSession,
+		TokenID:   token.Claims.ID.String(),
+	}
+}
+
+func newTestVerificationCache(size int, ttl time.Duration) (
+	*VerificationCache,
+	*time.Time,
+) {
+	now := time.Now()
+	c := NewVerificationCache(size, ttl)
+	c.now = func() time.Time { return now }
+	c.SetEnabled(true)
+	return c, &now
+}
+
+func TestVerificationCacheLRU(t *testing.T) {
+	c, _ := newTestVerificationCache(2, time.Minute)
+
+	token1, ti1 := testVerifiedToken("token-1", "user-1", "")
+	token2, ti2 := testVerifiedToken("token-2", "user-1", "")
+	token3, ti3 := testVerifiedToken("token-3", "user-2", "")
+
+	c.Add(token1, ti1, time.Time{}, c.Epoch())
+	c.Add(token2, ti2, time.Time{}, c.Epoch())
+	assert.Equal(t, ti1, c.Get(ti1.TokenID))
+
+	// token-2 is the least recently used
+	c.Add(token3, ti3, time.Time{}, c.Epoch())
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, ti1, c.Get(ti1.TokenID))
+	assert.Nil(t, c.Get(ti2.TokenID))
+	assert.Equal(t, ti3, c.Get(ti3.TokenID))
+}
+
+func TestVerificationCacheExpiry(t *testing.T) {
+	c, now := newTestVerificationCache(10, time.Minute)
+
+	token1, ti1 := testVerifiedToken("token-1", "user-1", "")
+	c.Add(token1, ti1, time.Time{}, c.Epoch())
+
+	token2, ti2 := testVerifiedToken("token-2", "user-1", "")
+	token2.Claims.ExpiresAt = jwt.Time{Time: now.Add(10 * time.Second)}
+	c.Add(token2, ti2, time.Time{}, c.Epoch())
+
+	// the elevated role drops off
+	token3, ti3 := testVerifiedToken("token-3", "user-1", "")
+	c.Add(token3, ti3, now.Add(20*time.Second), c.Epoch())
+
+	token4, ti4 := testVerifiedToken("token-4", "user-1", "")
+	token4.Claims.ExpiresAt = jwt.Time{Time: now.Add(-time.Second)}
+	c.Add(token4, ti4, time.Time{}, c.Epoch())
+	assert.Equal(t, 3, c.Len())
+
+	*now = now.Add(15 * time.Second)
+	assert.Equal(t, ti1, c.Get(ti1.TokenID))
+	assert.Nil(t, c.Get(ti2.TokenID))
+	assert.Equal(t, ti3, c.Get(ti3.TokenID))
+
+	*now = now.Add(10 * time.Second)
+	assert.Equal(t, ti1, c.Get(ti1.TokenID))
+	assert.Nil(t, c.Get(ti3.TokenID))
+
+	*now = now.Add(time.Minute)
+	assert.Nil(t, c.Get(ti1.TokenID))
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestVerificationCacheInvalidate(t *testing.T) {
+	token1, ti1 := testVerifiedToken("token-1", "user-1", "tenant-1")
+	token2, ti2 := testVerifiedToken("token-2", "user-1", "tenant-1")
+	token3, ti3 := testVerifiedToken("token-3", "user-2", "tenant-1")
+	token4, ti4 := testVerifiedToken("token-4", "user-3", "tenant-2")
+
+	testCases := map[string]struct {
+		invalidate func(c *VerificationCache)
+
+		cached []*model.TokenIdentity
+	}{
+		"token": {
+			invalidate: func(c *VerificationCache) {
+				c.InvalidateToken(ti1.TokenID)
+			},
+			cached: []*model.TokenIdentity{ti2, ti3, ti4},
+		},
+		"user": {
+			invalidate: func(c *VerificationCache) {
+				c.InvalidateUser(ti1.UserID)
+			},
+			cached: []*model.TokenIdentity{ti3, ti4},
+		},
+		"tenant": {
+			invalidate: func(c *VerificationCache) {
+				c.InvalidateTenant("tenant-1")
+			},
+			cached: []*model.TokenIdentity{ti4},
+		},
+		"invalidation, token": {
+			invalidate: func(c *VerificationCache) {
+				c.Invalidate(&store.Invalidation{TokenID: ti3.TokenID})
+			},
+			cached: []*model.TokenIdentity{ti1, ti2, ti4},
+		},
+		"invalidation, user": {
+			invalidate: func(c *VerificationCache) {
+				c.Invalidate(&store.Invalidation{UserID: ti4.UserID})
+			},
+			cached: []*model.TokenIdentity{ti1, ti2, ti3},
+		},
+		"invalidation, tenant": {
+			invalidate: func(c *VerificationCache) {
+				c.Invalidate(&store.Invalidation{TenantID: "tenant-2"})
+			},
+			cached: []*model.TokenIdentity{ti1, ti2, ti3},
+		},
+		"invalidation, all": {
+			invalidate: func(c *VerificationCache) {
+				c.Invalidate(&store.Invalidation{All: true})
+			},
+		},
+		"purge": {
+			invalidate: func(c *VerificationCache) {
+				c.Purge()
+			},
+		},
+		"disable": {
+			invalidate: func(c *VerificationCache) {
+				c.SetEnabled(false)
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c, _ := newTestVerificationCache(10, time.Minute)
+			c.Add(token1, ti1, time.Time{}, c.Epoch())
+			c.Add(token2, ti2, time.Time{}, c.Epoch())
+			c.Add(token3, ti3, time.Time{}, c.Epoch())
+			c.Add(token4, ti4, time.Time{}, c.Epoch())
+
+			epoch := c.Epoch()
+			tc.invalidate(c)
+			assert.NotEqual(t, epoch, c.Epoch())
+
+			assert.Equal(t, len(tc.cached), c.Len())
+			for _, ti := range tc.cached {
+				assert.Equal(t, ti, c.Get(ti.TokenID))
+			}
+		})
+	}
+}
+
+func TestVerificationCacheStale(t *testing.T) {
+	c, _ := newTestVerificationCache(10, time.Minute)
+
+	// invalidated while verifying
+	token, ti := testVerifiedToken("token-1", "user-1", "")
+	epoch := c.Epoch()
+	c.InvalidateUser(ti.UserID)
+	c.Add(token, ti, time.Time{}, epoch)
+	assert.Nil(t, c.Get(ti.TokenID))
+
+	// disabled
+	c.SetEnabled(false)
+	c.Add(token, ti, time.Time{}, c.Epoch())
+	assert.Nil(t, c.Get(ti.TokenID))
+
+	// no cache
+	var nilCache *VerificationCache
+	nilCache.Add(token, ti, time.Time{}, nilCache.Epoch())
+	nilCache.InvalidateToken(ti.TokenID)
+	nilCache.InvalidateUser(ti.UserID)
+	nilCache.InvalidateTenant(ti.Tenant)
+	nilCache.Purge()
+	assert.Nil(t, nilCache.Get(ti.TokenID))
+}
+
+func TestVerificationCacheAllowed(t *testing.T) {
+	c, now := newTestVerificationCache(10, time.Minute)
+
+	token1, ti1 := testVerifiedToken("token-1", "user-1", "tenant-1")
+	token2, ti2 := testVerifiedToken("token-2", "user-2", "tenant-1")
+
+	// not verified
+	c.AddAllowed(ti1.TokenID, "foo:bar", "GET", c.Epoch())
+	assert.False(t, c.Allowed(ti1.TokenID, "foo:bar", "GET"))
+
+	c.Add(token1, ti1, time.Time{}, c.Epoch())
+	c.Add(token2, ti2, time.Time{}, c.Epoch())
+	c.AddAllowed(ti1.TokenID, "foo:bar", "GET", c.Epoch())
+	c.AddAllowed(ti2.TokenID, "foo:bar", "GET", c.Epoch())
+	assert.True(t, c.Allowed(ti1.TokenID, "foo:bar", "GET"))
+	assert.False(t, c.Allowed(ti1.TokenID, "foo:bar", "POST"))
+	assert.False(t, c.Allowed(ti1.TokenID, "foo:baz", "GET"))
+
+	// invalidated while authorizing
+	epoch := c.Epoch()
+	c.InvalidateToken(ti2.TokenID)
+	c.Add(token2, ti2, time.Time{}, c.Epoch())
+	c.AddAllowed(ti2.TokenID, "foo:bar", "POST", epoch)
+	assert.False(t, c.Allowed(ti2.TokenID, "foo:bar", "POST"))
+
+	// the allowed requests go with the verification
+	assert.False(t, c.Allowed(ti2.TokenID, "foo:bar", "GET"))
+	c.InvalidateTenant(ti1.Tenant)
+	assert.False(t, c.Allowed(ti1.TokenID, "foo:bar", "GET"))
+
+	c.Add(token1, ti1, time.Time{}, c.Epoch())
+	for i := 0; i < verifyCacheAllowedMax+1; i++ {
+		c.AddAllowed(ti1.TokenID, "foo:bar", fmt.Sprintf("GET%d", i), c.Epoch())
+	}
+	assert.True(t, c.Allowed(ti1.TokenID, "foo:bar",
+		fmt.Sprintf("GET%d", verifyCacheAllowedMax-1)))
+	assert.False(t, c.Allowed(ti1.TokenID, "foo:bar",
+		fmt.Sprintf("GET%d", verifyCacheAllowedMax)))
+
+	*now = now.Add(time.Minute)
+	assert.False(t, c.Allowed(ti1.TokenID, "foo:bar", "GET0"))
+	assert.Equal(t, 0, c.Len())
+
+	// no cache
+	var nilCache *VerificationCache
+	nilCache.AddAllowed(ti1.TokenID, "foo:bar", "GET", nilCache.Epoch())
+	assert.False(t, nilCache.Allowed(ti1.TokenID, "foo:bar", "GET"))
+}
+
+func TestVerificationCacheLastUsed(t *testing.T) {
+	c, now := newTestVerificationCache(10, time.Hour)
+
+	token1, ti1 := testVerifiedToken("token-1", "user-1", "")
+
+	// not verified
+	c.SetLastUsed(ti1.TokenID, *now)
+	assert.False(t, c.LastUsedBefore(ti1.TokenID, *now))
+
+	c.Add(token1, ti1, time.Time{}, c.Epoch())
+	c.SetLastUsed(ti1.TokenID, now.Add(-10*time.Minute))
+	assert.False(t, c.LastUsedBefore(ti1.TokenID, now.Add(-15*time.Minute)))
+	assert.True(t, c.LastUsedBefore(ti1.TokenID, now.Add(-5*time.Minute)))
+	// recorded now
+	assert.False(t, c.LastUsedBefore(ti1.TokenID, now.Add(-5*time.Minute)))
+
+	*now = now.Add(10 * time.Minute)
+	assert.True(t, c.LastUsedBefore(ti1.TokenID, now.Add(-5*time.Minute)))
+
+	// no cache
+	var nilCache *VerificationCache
+	nilCache.SetLastUsed(ti1.TokenID, *now)
+	assert.False(t, nilCache.LastUsedBefore(ti1.TokenID, *now))
+}
+
+func TestUserAdmVerifyCached(t *testing.T) {
+	ctx := context.Background()
+	token, _ := testVerifiedToken("token-1", "1234", "")
+	token.Claims.Issuer = "mender"
+	user := &model.User{
+		ID:    token.Claims.Subject.String(),
+		Email: "foo@acme.com",
+		Roles: []string{model.RoleReadOnly},
+	}
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserById", ctx, user.ID).
+		Return(user, nil).
+		Twice()
+	db.On("GetTokenById", ctx, token.ID).
+		Return(token, nil).
+		Twice()
+	db.On("GetGroupsByMember", ctx, user.ID).
+		Return([]model.Group{}, nil).
+		Twice()
+	db.On("GetActiveElevations", ctx, user.ID).
+		Return([]model.Elevation{}, nil).
+		Twice()
+	db.On("DeleteToken", ctx, token.Subject, token.ID).
+		Return(nil)
+
+	cache, _ := newTestVerificationCache(10, time.Minute)
+	useradm := NewUserAdm(nil, db, Config{Issuer: "mender"}).
+		WithVerificationCache(cache)
+
+	identity, err := useradm.Verify(ctx, token)
+	assert.NoError(t, err)
+	cached, err := useradm.Verify(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, identity, cached)
+
+	// logging out invalidates the verification
+	err = useradm.Logout(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cache.Len())
+
+	_, err = useradm.Verify(ctx, token)
+	assert.NoError(t, err)
+}
+
+func TestUserAdmVerifyCachedLastUsed(t *testing.T) {
+	ctx := context.Background()
+	token, _ := testVerifiedToken("token-1", "1234", "")
+	token.Claims.Issuer = "mender"
+	tokenName := "my-token"
+	lastUsed := time.Now().Add(-time.Hour)
+	dbToken := *token
+	dbToken.TokenName = &tokenName
+	dbToken.LastUsed = &lastUsed
+	user := &model.User{
+		ID:    token.Claims.Subject.String(),
+		Email: "foo@acme.com",
+		Roles: []string{model.RoleReadOnly},
+	}
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserById", ctx, user.ID).
+		Return(user, nil).
+		Once()
+	db.On("GetTokenById", ctx, token.ID).
+		Return(&dbToken, nil).
+		Once()
+	db.On("GetGroupsByMember", ctx, user.ID).
+		Return([]model.Group{}, nil).
+		Once()
+	db.On("GetActiveElevations", ctx, user.ID).
+		Return([]model.Elevation{}, nil).
+		Once()
+	db.On("UpdateTokenLastUsed", ctx, token.ID).
+		Return(nil).
+		Twice()
+
+	cache, _ := newTestVerificationCache(10, time.Hour)
+	useradm := NewUserAdm(nil, db, Config{
+		Issuer:                         "mender",
+		TokenLastUsedUpdateFreqMinutes: 5,
+	}).WithVerificationCache(cache)
+
+	identity, err := useradm.Verify(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenTypePersonalAccessToken, identity.TokenType)
+
+	// used again within the update period
+	_, err = useradm.Verify(ctx, token)
+	assert.NoError(t, err)
+
+	// used again after the update period
+	cache.SetLastUsed(identity.TokenID, time.Now().Add(-10*time.Minute))
+	_, err = useradm.Verify(ctx, token)
+	assert.NoError(t, err)
+	_, err = useradm.Verify(ctx, token)
+	assert.NoError(t, err)
+}
+
+type testInvalidationStream struct {
+	invalidations chan *store.Invalidation
+	err           error
+	next          chan struct{}
+	closed        chan struct{}
+}
+
+func (s *testInvalidationStream) Next(
+	ctx context.Context,
+) (*store.Invalidation, error) {
+	s.next <- struct{}{}
+	select {
+	case inv, ok := <-s.invalidations:
+		if !ok {
+			return nil, s.err
+		}
+		return inv, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *testInvalidationStream) Close(ctx context.Context) error {
+	close(s.closed)
+	return nil
+}
+
+func newTestInvalidationStream(err error) *testInvalidationStream {
+	return &testInvalidationStream{
+		invalidations: make(chan *store.Invalidation),
+		err:           err,
+		next:          make(chan struct{}, 10),
+		closed:        make(chan struct{}),
+	}
+}
+
+func TestUserAdmWatchVerificationCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	token1, ti1 := testVerifiedToken("token-1", "user-1", "")
+	token2, ti2 := testVerifiedToken("token-2", "user-2", "")
+
+	broken := newTestInvalidationStream(errors.New("connection reset"))
+	stream := newTestInvalidationStream(nil)
+	db := &mstore.DataStore{}
+	db.On("WatchInvalidations", ctx).
+		Return(broken, nil).
+		Once()
+	db.On("WatchInvalidations", ctx).
+		Return(stream, nil).
+		Once()
+
+	cache, _ := newTestVerificationCache(10, time.Minute)
+	cache.SetEnabled(false)
+	useradm := NewUserAdm(nil, db, Config{}).
+		WithVerificationCache(cache)
+
+	done := make(chan error)
+	go func() {
+		done <- useradm.WatchVerificationCache(ctx)
+	}()
+
+	// the invalidations are applied once the stream is open
+	<-broken.next
+	cache.Add(token1, ti1, time.Time{}, cache.Epoch())
+	cache.Add(token2, ti2, time.Time{}, cache.Epoch())
+	broken.invalidations <- &store.Invalidation{TokenID: ti1.TokenID}
+	<-broken.next
+	assert.Nil(t, cache.Get(ti1.TokenID))
+	assert.Equal(t, ti2, cache.Get(ti2.TokenID))
+
+	// the cache is disabled while the stream is broken
+	close(broken.invalidations)
+	<-broken.closed
+	assert.Equal(t, 0, cache.Len())
+
+	<-stream.next
+	cache.Add(token2, ti2, time.Time{}, cache.Epoch())
+	assert.Equal(t, ti2, cache.Get(ti2.TokenID))
+
+	cancel()
+	assert.NoError(t, <-done)
+	<-stream.closed
+	assert.Equal(t, 0, cache.Len())
+	db.AssertExpectations(t)
+}
+
+func TestUserAdmWatchVerificationCacheError(t *testing.T) {
+	ctx := context.Background()
+
+	db := &mstore.DataStore{}
+	db.On("WatchInvalidations", ctx).
+		Return(nil, errors.New("change streams are not supported"))
+
+	cache := NewVerificationCache(10, time.Minute)
+	useradm := NewUserAdm(nil, db, Config{}).
+		WithVerificationCache(cache)
+
+	err := useradm.WatchVerificationCache(ctx)
+	assert.EqualError(t, err, "useradm: failed to watch the invalidations: "+
+		"change streams are not supported")
+
+	token, ti := testVerifiedToken("token-1", "user-1", "")
+	cache.Add(token, ti, time.Time{}, cache.Epoch())
+	assert.Nil(t, cache.Get(ti.TokenID))
+
+	// no cache, nothing to watch
+	assert.NoError(t, NewUserAdm(nil, db, Config{}).WatchVerificationCache(ctx))
+}
+
+func TestUserAdmInvalidateRoleChanges(t *testing.T) {
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: "admin",
+		Tenant:  "tenant-1",
+		IsUser:  true,
+	})
+	token1, ti1 := testVerifiedToken("token-1", "user-1", "tenant-1")
+	token2, ti2 := testVerifiedToken("token-2", "user-2", "tenant-1")
+	token3, ti3 := testVerifiedToken("token-3", "user-3", "tenant-2")
+	groupRoles := []string{model.RoleReadOnly}
+	groupName := "group"
+	userCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: ti1.UserID,
+		Tenant:  "tenant-1",
+		IsUser:  true,
+	})
+
+	testCases := map[string]struct {
+		config Config
+		setup  func(db *mstore.DataStore)
+		change func(ua *UserAdm) error
+
+		cached []*model.TokenIdentity
+	}{
+		"update role": {
+			setup: func(db *mstore.DataStore) {
+				db.On("UpdateRole", ctx, "role", &model.RoleUpdate{}).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.UpdateRole(ctx, "role", &model.RoleUpdate{})
+			},
+			cached: []*model.TokenIdentity{ti3},
+		},
+		"delete role": {
+			setup: func(db *mstore.DataStore) {
+				db.On("DeleteRole", ctx, "role").Return(nil)
+				db.On("RemoveRoleFromUsers", ctx, "role").Return(nil)
+				db.On("RemoveRoleFromGroups", ctx, "role").Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.DeleteRole(ctx, "role")
+			},
+			cached: []*model.TokenIdentity{ti3},
+		},
+		"set user roles": {
+			setup: func(db *mstore.DataStore) {
+				db.On("SetUserRoles", ctx, ti1.UserID, groupRoles).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.SetUserRoles(ctx, ti1.UserID, groupRoles)
+			},
+			cached: []*model.TokenIdentity{ti2, ti3},
+		},
+		"update group roles": {
+			setup: func(db *mstore.DataStore) {
+				db.On("UpdateGroup", ctx, "group-1",
+					&model.GroupUpdate{Roles: &groupRoles}).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.UpdateGroup(ctx, "group-1",
+					&model.GroupUpdate{Roles: &groupRoles})
+			},
+			cached: []*model.TokenIdentity{ti3},
+		},
+		"rename group": {
+			setup: func(db *mstore.DataStore) {
+				db.On("UpdateGroup", ctx, "group-1",
+					&model.GroupUpdate{Name: &groupName}).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.UpdateGroup(ctx, "group-1",
+					&model.GroupUpdate{Name: &groupName})
+			},
+			cached: []*model.TokenIdentity{ti1, ti2, ti3},
+		},
+		"delete group": {
+			setup: func(db *mstore.DataStore) {
+				db.On("DeleteGroup", ctx, "group-1").Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.DeleteGroup(ctx, "group-1")
+			},
+			cached: []*model.TokenIdentity{ti3},
+		},
+		"add group member": {
+			setup: func(db *mstore.DataStore) {
+				db.On("GetUserById", ctx, ti1.UserID).
+					Return(&model.User{ID: ti1.UserID}, nil)
+				db.On("AddGroupMember", ctx, "group-1", ti1.UserID).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.AddGroupMember(ctx, "group-1", ti1.UserID)
+			},
+			cached: []*model.TokenIdentity{ti2, ti3},
+		},
+		"remove group member": {
+			setup: func(db *mstore.DataStore) {
+				db.On("RemoveGroupMember", ctx, "group-1", ti1.UserID).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.RemoveGroupMember(ctx, "group-1", ti1.UserID)
+			},
+			cached: []*model.TokenIdentity{ti2, ti3},
+		},
+		"approve elevation": {
+			setup: func(db *mstore.DataStore) {
+				db.On("GetElevation", ctx, "elevation-1").
+					Return(&model.Elevation{
+						ID:       "elevation-1",
+						UserID:   ti1.UserID,
+						Role:     model.RoleAdmin,
+						Duration: 30,
+						Status:   model.ElevationStatusPending,
+					}, nil)
+				db.On("ReviewElevation", ctx, "elevation-1",
+					mock.AnythingOfType("*model.ElevationReview")).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				return ua.ApproveElevation(ctx, "elevation-1")
+			},
+			cached: []*model.TokenIdentity{ti2, ti3},
+		},
+		"request elevation without approval": {
+			config: Config{
+				ElevationRoles: map[string][]string{
+					model.RoleReadOnly: {model.RoleAdmin},
+				},
+			},
+			setup: func(db *mstore.DataStore) {
+				db.On("GetUserById", userCtx, ti1.UserID).
+					Return(&model.User{
+						ID:    ti1.UserID,
+						Roles: []string{model.RoleReadOnly},
+					}, nil)
+				db.On("GetGroupsByMember", userCtx, ti1.UserID).
+					Return([]model.Group{}, nil)
+				db.On("CreateElevation", userCtx,
+					mock.AnythingOfType("*model.Elevation")).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				_, err := ua.RequestElevation(userCtx, &model.ElevationNew{
+					Role:     model.RoleAdmin,
+					Duration: 30,
+				})
+				return err
+			},
+			cached: []*model.TokenIdentity{ti2, ti3},
+		},
+		"request elevation with approval": {
+			config: Config{
+				ElevationApproval: true,
+				ElevationRoles: map[string][]string{
+					model.RoleReadOnly: {model.RoleAdmin},
+				},
+			},
+			setup: func(db *mstore.DataStore) {
+				db.On("GetUserById", userCtx, ti1.UserID).
+					Return(&model.User{
+						ID:    ti1.UserID,
+						Roles: []string{model.RoleReadOnly},
+					}, nil)
+				db.On("GetGroupsByMember", userCtx, ti1.UserID).
+					Return([]model.Group{}, nil)
+				db.On("CreateElevation", userCtx,
+					mock.AnythingOfType("*model.Elevation")).
+					Return(nil)
+			},
+			change: func(ua *UserAdm) error {
+				_, err := ua.RequestElevation(userCtx, &model.ElevationNew{
+					Role:     model.RoleAdmin,
+					Duration: 30,
+				})
+				return err
+			},
+			cached: []*model.TokenIdentity{ti1, ti2, ti3},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			tc.setup(db)
+
+			cache, _ := newTestVerificationCache(10, time.Minute)
+			cache.Add(token1, ti1, time.Time{}, cache.Epoch())
+			cache.Add(token2, ti2, time.Time{}, cache.Epoch())
+			cache.Add(token3, ti3, time.Time{}, cache.Epoch())
+			useradm := NewUserAdm(nil, db, tc.config).
+				WithVerificationCache(cache)
+
+			assert.NoError(t, tc.change(useradm))
+			assert.Equal(t, len(tc.cached), cache.Len())
+			for _, ti := range tc.cached {
+				assert.Equal(t, ti, cache.Get(ti.TokenID))
+			}
+		})
+	}
+}
+
+func TestUserAdmVerifyCachedElevation(t *testing.T) {
+	ctx := context.Background()
+	token, _ := testVerifiedToken("token-1", "1234", "")
+	token.Claims.Issuer = "mender"
+	user := &model.User{
+		ID:    token.Claims.Subject.String(),
+		Roles: []string{model.RoleReadOnly},
+	}
+	expires := time.Now().Add(10 * time.Second)
+
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetUserById", ctx, user.ID).Return(user, nil)
+	db.On("GetTokenById", ctx, token.ID).Return(token, nil)
+	db.On("GetGroupsByMember", ctx, user.ID).Return([]model.Group{}, nil)
+	db.On("GetActiveElevations", ctx, user.ID).Return([]model.Elevation{{
+		UserID:    user.ID,
+		Role:      model.RoleAdmin,
+		Status:    model.ElevationStatusActive,
+		ExpiresTs: &expires,
+	}}, nil)
+
+	cache, now := newTestVerificationCache(10, time.Minute)
+	useradm := NewUserAdm(nil, db, Config{Issuer: "mender"}).
+		WithVerificationCache(cache)
+
+	identity, err := useradm.Verify(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.RoleReadOnly, model.RoleAdmin}, identity.Roles)
+	assert.Equal(t, identity, cache.Get(identity.TokenID))
+
+	// the verification is dropped with the elevated role
+	*now = now.Add(15 * time.Second)
+	assert.Nil(t, cache.Get(identity.TokenID))
+}
@@ -0,0 +1,391 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package testing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+const ldapOIDStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// LDAPEntry is an entry of the LDAPServer directory.
+type LDAPEntry struct {
+	DN         string
+	Attributes map[string][]string
+	// Password accepted when binding as the entry, none if empty
+	Password string
+}
+
+// LDAPServer is an in-process stand-in for an LDAP server supporting
+// simple binds, searches and StartTLS; searches are only allowed to
+// authenticated sessions.
+type LDAPServer struct {
+	Entries []LDAPEntry
+
+	listener  net.Listener
+	scheme    string
+	tlsConfig *tls.Config
+	cert      *x509.Certificate
+	certPool  *x509.CertPool
+	wg        sync.WaitGroup
+}
+
+// NewLDAPServer starts a plain ldap:// server offering StartTLS.
+func NewLDAPServer(entries ...LDAPEntry) (*LDAPServer, error) {
+	return newLDAPServer("ldap", entries)
+}
+
+// NewLDAPSServer starts an ldaps:// server.
+func NewLDAPSServer(entries ...LDAPEntry) (*LDAPServer, error) {
+	return newLDAPServer("ldaps", entries)
+}
+
+func newLDAPServer(scheme string, entries []LDAPEntry) (*LDAPServer, error) {
+	srv := &LDAPServer{
+		Entries: entries,
+		scheme:  scheme,
+	}
+	if err := srv.makeCertificate(); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	if scheme == "ldaps" {
+		listener = tls.NewListener(listener, srv.tlsConfig)
+	}
+	srv.listener = listener
+	srv.wg.Add(1)
+	go srv.serve()
+	return srv, nil
+}
+
+func (srv *LDAPServer) makeCertificate() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	srv.cert = cert
+	srv.certPool = x509.NewCertPool()
+	srv.certPool.AddCert(cert)
+	srv.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+		}},
+	}
+	return nil
+}
+
+// URL returns the URL of the server.
+func (srv *LDAPServer) URL() string {
+	return srv.scheme + "://" + srv.listener.Addr().String()
+}
+
+// Certificate returns the PEM-encoded certificate of the server.
+func (srv *LDAPServer) Certificate() string {
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: srv.cert.Raw,
+	}))
+}
+
+// CertPool returns the pool trusting the server's certificate.
+func (srv *LDAPServer) CertPool() *x509.CertPool {
+	return srv.certPool
+}
+
+// Close stops the server.
+func (srv *LDAPServer) Close() {
+	srv.listener.Close()
+	srv.wg.Wait()
+}
+
+func (srv *LDAPServer) serve() {
+	defer srv.wg.Done()
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+		go srv.handle(conn)
+	}
+}
+
+func (srv *LDAPServer) handle(conn net.Conn) {
+	var bound *LDAPEntry
+	defer func() { conn.Close() }()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		msgID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		if op.ClassType != ber.ClassApplication {
+			return
+		}
+		switch op.Tag {
+		case ldapv3.ApplicationBindRequest:
+			var code uint16 = ldapv3.LDAPResultInvalidCredentials
+			bound = srv.bind(op)
+			if bound != nil || isAnonymousBind(op) {
+				code = ldapv3.LDAPResultSuccess
+			}
+			writeLDAPResult(conn, msgID, ldapv3.ApplicationBindResponse, code)
+
+		case ldapv3.ApplicationUnbindRequest:
+			return
+
+		case ldapv3.ApplicationSearchRequest:
+			if bound == nil {
+				writeLDAPResult(conn, msgID, ldapv3.ApplicationSearchResultDone,
+					ldapv3.LDAPResultInsufficientAccessRights)
+				continue
+			}
+			srv.search(conn, msgID, op)
+
+		case ldapv3.ApplicationExtendedRequest:
+			if len(op.Children) == 0 || op.Children[0].Data.String() != ldapOIDStartTLS ||
+				srv.scheme == "ldaps" {
+				writeLDAPResult(conn, msgID, ldapv3.ApplicationExtendedResponse,
+					ldapv3.LDAPResultProtocolError)
+				continue
+			}
+			writeLDAPResult(conn, msgID, ldapv3.ApplicationExtendedResponse,
+				ldapv3.LDAPResultSuccess)
+			tlsConn := tls.Server(conn, srv.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+
+		default:
+			return
+		}
+	}
+}
+
+func isAnonymousBind(op *ber.Packet) bool {
+	return len(op.Children) == 3 && op.Children[2].Data.Len() == 0
+}
+
+func (srv *LDAPServer) bind(op *ber.Packet) *LDAPEntry {
+	if len(op.Children) != 3 || op.Children[2].Tag != 0 {
+		return nil
+	}
+	dn, _ := op.Children[1].Value.(string)
+	password := op.Children[2].Data.String()
+	for i := range srv.Entries {
+		entry := &srv.Entries[i]
+		if strings.EqualFold(entry.DN, dn) &&
+			entry.Password != "" && entry.Password == password {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (srv *LDAPServer) search(conn net.Conn, msgID int64, op *ber.Packet) {
+	if len(op.Children) < 8 {
+		writeLDAPResult(conn, msgID, ldapv3.ApplicationSearchResultDone,
+			ldapv3.LDAPResultProtocolError)
+		return
+	}
+	baseDN, _ := op.Children[0].Value.(string)
+	scope, _ := op.Children[1].Value.(int64)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+	var attributes []string
+	for _, attr := range op.Children[7].Children {
+		name, _ := attr.Value.(string)
+		attributes = append(attributes, name)
+	}
+
+	found := int64(0)
+	for i := range srv.Entries {
+		entry := &srv.Entries[i]
+		if !inLDAPScope(entry.DN, baseDN, scope) || !matchLDAPFilter(entry, filter) {
+			continue
+		}
+		if sizeLimit > 0 && found == sizeLimit {
+			writeLDAPResult(conn, msgID, ldapv3.ApplicationSearchResultDone,
+				ldapv3.LDAPResultSizeLimitExceeded)
+			return
+		}
+		found++
+		writeLDAPEntry(conn, msgID, entry, attributes)
+	}
+	writeLDAPResult(conn, msgID, ldapv3.ApplicationSearchResultDone,
+		ldapv3.LDAPResultSuccess)
+}
+
+func inLDAPScope(dn, baseDN string, scope int64) bool {
+	dn, baseDN = strings.ToLower(dn), strings.ToLower(baseDN)
+	switch scope {
+	case ldapv3.ScopeBaseObject:
+		return dn == baseDN
+	case ldapv3.ScopeSingleLevel:
+		i := strings.Index(dn, ",")
+		return i >= 0 && dn[i+1:] == baseDN
+	default:
+		return dn == baseDN || strings.HasSuffix(dn, ","+baseDN)
+	}
+}
+
+func (entry *LDAPEntry) values(attr string) []string {
+	if strings.EqualFold(attr, "dn") {
+		return []string{entry.DN}
+	}
+	for name, values := range entry.Attributes {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+	return nil
+}
+
+func matchLDAPFilter(entry *LDAPEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldapv3.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchLDAPFilter(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldapv3.FilterOr:
+		for _, child := range filter.Children {
+			if matchLDAPFilter(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldapv3.FilterNot:
+		return len(filter.Children) == 1 && !matchLDAPFilter(entry, filter.Children[0])
+	case ldapv3.FilterEqualityMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		attr, _ := filter.Children[0].Value.(string)
+		value, _ := filter.Children[1].Value.(string)
+		for _, v := range entry.values(attr) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case ldapv3.FilterPresent:
+		return len(entry.values(filter.Data.String())) > 0
+	default:
+		return false
+	}
+}
+
+func writeLDAPResult(conn net.Conn, msgID int64, tag ber.Tag, code uint16) {
+	packet := ber.NewSequence("LDAP Response")
+	packet.AppendChild(ber.NewInteger(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "MessageID",
+	))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Code",
+	))
+	result.AppendChild(ber.NewString(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "MatchedDN",
+	))
+	result.AppendChild(ber.NewString(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString,
+		ldapv3.LDAPResultCodeMap[code], "Message",
+	))
+	packet.AppendChild(result)
+	_, _ = conn.Write(packet.Bytes())
+}
+
+func writeLDAPEntry(conn net.Conn, msgID int64, entry *LDAPEntry, attributes []string) {
+	packet := ber.NewSequence("LDAP Response")
+	packet.AppendChild(ber.NewInteger(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "MessageID",
+	))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed,
+		ldapv3.ApplicationSearchResultEntry, nil, "Entry")
+	result.AppendChild(ber.NewString(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN",
+	))
+	all := len(attributes) == 0
+	for _, attr := range attributes {
+		all = all || attr == "*"
+	}
+	attrs := ber.NewSequence("Attributes")
+	for name, values := range entry.Attributes {
+		selected := all
+		for _, attr := range attributes {
+			selected = selected || strings.EqualFold(attr, name)
+		}
+		if !selected {
+			continue
+		}
+		attr := ber.NewSequence("Attribute")
+		attr.AppendChild(ber.NewString(
+			ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type",
+		))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(
+				ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value",
+			))
+		}
+		attr.AppendChild(set)
+		attrs.AppendChild(attr)
+	}
+	result.AppendChild(attrs)
+	packet.AppendChild(result)
+	_, _ = conn.Write(packet.Bytes())
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package testing
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/crewjam/saml"
+)
+
+// SAMLIdP is an in-process stand-in for a SAML identity provider,
+// issuing signed responses to the service provider under test.
+type SAMLIdP struct {
+	IdP *saml.IdentityProvider
+}
+
+// NewSAMLIdP creates an identity provider with a fresh signing key and
+// a self-signed certificate.
+func NewSAMLIdP() (*SAMLIdP, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	metadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	ssoURL, _ := url.Parse("https://idp.example.com/sso")
+	return &SAMLIdP{
+		IdP: &saml.IdentityProvider{
+			Key:         key,
+			Certificate: cert,
+			MetadataURL: *metadataURL,
+			SSOURL:      *ssoURL,
+		},
+	}, nil
+}
+
+// Metadata returns the XML metadata of the identity provider.
+func (idp *SAMLIdP) Metadata() string {
+	metadata, _ := xml.Marshal(idp.IdP.Metadata())
+	return string(metadata)
+}
+
+// Certificate returns the PEM-encoded signing certificate.
+func (idp *SAMLIdP) Certificate() string {
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: idp.IdP.Certificate.Raw,
+	}))
+}
+
+// MakeResponse authenticates the session with the service provider
+// described by the XML metadata, returning the signed response which the
+// browser would post to the assertion consumer service, base64-encoded;
+// requestID is the ID of the authentication request, empty for the logins
+// started at the identity provider.
+func (idp *SAMLIdP) MakeResponse(
+	spMetadata []byte,
+	requestID string,
+	session *saml.Session,
+) (string, error) {
+	var sp saml.EntityDescriptor
+	if err := xml.Unmarshal(spMetadata, &sp); err != nil {
+		return "", err
+	}
+	req := &saml.IdpAuthnRequest{
+		IDP:                     idp.IdP,
+		HTTPRequest:             httptest.NewRequest("POST", idp.IdP.SSOURL.String(), nil),
+		Request:                 saml.AuthnRequest{ID: requestID},
+		ServiceProviderMetadata: &sp,
+		SPSSODescriptor:         &sp.SPSSODescriptors[0],
+		ACSEndpoint:             &sp.SPSSODescriptors[0].AssertionConsumerServices[0],
+		Now:                     saml.TimeNow(),
+	}
+	if session.CreateTime.IsZero() {
+		session.CreateTime = req.Now
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		return "", err
+	}
+	form, err := req.PostBinding()
+	if err != nil {
+		return "", err
+	}
+	return form.SAMLResponse, nil
+}
+
+// DecodeSAMLResponse decodes the base64-encoded response.
+func DecodeSAMLResponse(response string) []byte {
+	decoded, _ := base64.StdEncoding.DecodeString(response)
+	return decoded
+}